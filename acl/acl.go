@@ -41,6 +41,14 @@ type ACL interface {
 	// ACLModify checks for permission to manipulate ACLs
 	ACLModify() bool
 
+	// AgentRead checks for permission to read from agent endpoints for a
+	// given node.
+	AgentRead(string) bool
+
+	// AgentWrite checks for permission to make changes via agent endpoints
+	// for a given node.
+	AgentWrite(string) bool
+
 	// EventRead determines if a specific event can be queried.
 	EventRead(string) bool
 
@@ -95,6 +103,13 @@ type ACL interface {
 	// service
 	ServiceWrite(string) bool
 
+	// SessionRead checks for permission to read sessions for a given node.
+	SessionRead(string) bool
+
+	// SessionWrite checks for permission to create or destroy sessions for
+	// a given node.
+	SessionWrite(string) bool
+
 	// Snapshot checks for permission to take and restore snapshots.
 	Snapshot() bool
 }
@@ -115,6 +130,14 @@ func (s *StaticACL) ACLModify() bool {
 	return s.allowManage
 }
 
+func (s *StaticACL) AgentRead(string) bool {
+	return s.defaultAllow
+}
+
+func (s *StaticACL) AgentWrite(string) bool {
+	return s.defaultAllow
+}
+
 func (s *StaticACL) EventRead(string) bool {
 	return s.defaultAllow
 }
@@ -175,6 +198,14 @@ func (s *StaticACL) ServiceWrite(string) bool {
 	return s.defaultAllow
 }
 
+func (s *StaticACL) SessionRead(string) bool {
+	return s.defaultAllow
+}
+
+func (s *StaticACL) SessionWrite(string) bool {
+	return s.defaultAllow
+}
+
 func (s *StaticACL) Snapshot() bool {
 	return s.allowManage
 }
@@ -215,6 +246,9 @@ type PolicyACL struct {
 	// no matching rule.
 	parent ACL
 
+	// agentRules contains the agent policies
+	agentRules *radix.Tree
+
 	// keyRules contains the key policies
 	keyRules *radix.Tree
 
@@ -224,6 +258,9 @@ type PolicyACL struct {
 	// serviceRules contains the service policies
 	serviceRules *radix.Tree
 
+	// sessionRules contains the session policies
+	sessionRules *radix.Tree
+
 	// eventRules contains the user event policies
 	eventRules *radix.Tree
 
@@ -244,13 +281,20 @@ type PolicyACL struct {
 func New(parent ACL, policy *Policy) (*PolicyACL, error) {
 	p := &PolicyACL{
 		parent:             parent,
+		agentRules:         radix.New(),
 		keyRules:           radix.New(),
 		nodeRules:          radix.New(),
 		serviceRules:       radix.New(),
+		sessionRules:       radix.New(),
 		eventRules:         radix.New(),
 		preparedQueryRules: radix.New(),
 	}
 
+	// Load the agent policy
+	for _, ap := range policy.Agents {
+		p.agentRules.Insert(ap.Node, ap.Policy)
+	}
+
 	// Load the key policy
 	for _, kp := range policy.Keys {
 		p.keyRules.Insert(kp.Prefix, kp.Policy)
@@ -266,6 +310,11 @@ func New(parent ACL, policy *Policy) (*PolicyACL, error) {
 		p.serviceRules.Insert(sp.Name, sp.Policy)
 	}
 
+	// Load the session policy
+	for _, sp := range policy.Sessions {
+		p.sessionRules.Insert(sp.Node, sp.Policy)
+	}
+
 	// Load the event policy
 	for _, ep := range policy.Events {
 		p.eventRules.Insert(ep.Event, ep.Policy)
@@ -295,6 +344,44 @@ func (p *PolicyACL) ACLModify() bool {
 	return p.parent.ACLModify()
 }
 
+// AgentRead checks for permission to read from agent endpoints for a given
+// node.
+func (p *PolicyACL) AgentRead(node string) bool {
+	// Check for an exact rule or catch-all
+	_, rule, ok := p.agentRules.LongestPrefix(node)
+
+	if ok {
+		switch rule {
+		case PolicyRead, PolicyWrite:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// No matching rule, use the parent.
+	return p.parent.AgentRead(node)
+}
+
+// AgentWrite checks for permission to make changes via agent endpoints for a
+// given node.
+func (p *PolicyACL) AgentWrite(node string) bool {
+	// Check for an exact rule or catch-all
+	_, rule, ok := p.agentRules.LongestPrefix(node)
+
+	if ok {
+		switch rule {
+		case PolicyWrite:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// No matching rule, use the parent.
+	return p.parent.AgentWrite(node)
+}
+
 // Snapshot checks if taking and restoring snapshots is allowed.
 func (p *PolicyACL) Snapshot() bool {
 	return p.parent.Snapshot()
@@ -547,3 +634,40 @@ func (p *PolicyACL) ServiceWrite(name string) bool {
 	// No matching rule, use the parent.
 	return p.parent.ServiceWrite(name)
 }
+
+// SessionRead checks for permission to read sessions for a given node.
+func (p *PolicyACL) SessionRead(node string) bool {
+	// Check for an exact rule or catch-all
+	_, rule, ok := p.sessionRules.LongestPrefix(node)
+
+	if ok {
+		switch rule {
+		case PolicyRead, PolicyWrite:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// No matching rule, use the parent.
+	return p.parent.SessionRead(node)
+}
+
+// SessionWrite checks for permission to create or destroy sessions for a
+// given node.
+func (p *PolicyACL) SessionWrite(node string) bool {
+	// Check for an exact rule or catch-all
+	_, rule, ok := p.sessionRules.LongestPrefix(node)
+
+	if ok {
+		switch rule {
+		case PolicyWrite:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// No matching rule, use the parent.
+	return p.parent.SessionWrite(node)
+}
@@ -41,6 +41,12 @@ func TestStaticACL(t *testing.T) {
 	if all.ACLModify() {
 		t.Fatalf("should not allow")
 	}
+	if !all.AgentRead("foobar") {
+		t.Fatalf("should allow")
+	}
+	if !all.AgentWrite("foobar") {
+		t.Fatalf("should allow")
+	}
 	if !all.EventRead("foobar") {
 		t.Fatalf("should allow")
 	}
@@ -83,6 +89,12 @@ func TestStaticACL(t *testing.T) {
 	if !all.ServiceWrite("foobar") {
 		t.Fatalf("should allow")
 	}
+	if !all.SessionRead("foobar") {
+		t.Fatalf("should allow")
+	}
+	if !all.SessionWrite("foobar") {
+		t.Fatalf("should allow")
+	}
 	if all.Snapshot() {
 		t.Fatalf("should not allow")
 	}
@@ -93,6 +105,12 @@ func TestStaticACL(t *testing.T) {
 	if none.ACLModify() {
 		t.Fatalf("should not allow")
 	}
+	if none.AgentRead("foobar") {
+		t.Fatalf("should not allow")
+	}
+	if none.AgentWrite("foobar") {
+		t.Fatalf("should not allow")
+	}
 	if none.EventRead("foobar") {
 		t.Fatalf("should not allow")
 	}
@@ -141,6 +159,12 @@ func TestStaticACL(t *testing.T) {
 	if none.ServiceWrite("foobar") {
 		t.Fatalf("should not allow")
 	}
+	if none.SessionRead("foobar") {
+		t.Fatalf("should not allow")
+	}
+	if none.SessionWrite("foobar") {
+		t.Fatalf("should not allow")
+	}
 	if none.Snapshot() {
 		t.Fatalf("should not allow")
 	}
@@ -151,6 +175,12 @@ func TestStaticACL(t *testing.T) {
 	if !manage.ACLModify() {
 		t.Fatalf("should allow")
 	}
+	if !manage.AgentRead("foobar") {
+		t.Fatalf("should allow")
+	}
+	if !manage.AgentWrite("foobar") {
+		t.Fatalf("should allow")
+	}
 	if !manage.EventRead("foobar") {
 		t.Fatalf("should allow")
 	}
@@ -193,6 +223,12 @@ func TestStaticACL(t *testing.T) {
 	if !manage.ServiceWrite("foobar") {
 		t.Fatalf("should allow")
 	}
+	if !manage.SessionRead("foobar") {
+		t.Fatalf("should allow")
+	}
+	if !manage.SessionWrite("foobar") {
+		t.Fatalf("should allow")
+	}
 	if !manage.Snapshot() {
 		t.Fatalf("should allow")
 	}
@@ -661,3 +697,169 @@ func TestPolicyACL_Node(t *testing.T) {
 		}
 	}
 }
+
+func TestPolicyACL_Agent(t *testing.T) {
+	deny := DenyAll()
+	policyRoot := &Policy{
+		Agents: []*AgentPolicy{
+			&AgentPolicy{
+				Node:   "root-nope",
+				Policy: PolicyDeny,
+			},
+			&AgentPolicy{
+				Node:   "root-ro",
+				Policy: PolicyRead,
+			},
+			&AgentPolicy{
+				Node:   "root-rw",
+				Policy: PolicyWrite,
+			},
+			&AgentPolicy{
+				Node:   "override",
+				Policy: PolicyDeny,
+			},
+		},
+	}
+	root, err := New(deny, policyRoot)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	policy := &Policy{
+		Agents: []*AgentPolicy{
+			&AgentPolicy{
+				Node:   "child-nope",
+				Policy: PolicyDeny,
+			},
+			&AgentPolicy{
+				Node:   "child-ro",
+				Policy: PolicyRead,
+			},
+			&AgentPolicy{
+				Node:   "child-rw",
+				Policy: PolicyWrite,
+			},
+			&AgentPolicy{
+				Node:   "override",
+				Policy: PolicyWrite,
+			},
+		},
+	}
+	acl, err := New(root, policy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type agentcase struct {
+		inp   string
+		read  bool
+		write bool
+	}
+	cases := []agentcase{
+		{"nope", false, false},
+		{"root-nope", false, false},
+		{"root-ro", true, false},
+		{"root-rw", true, true},
+		{"root-nope-prefix", false, false},
+		{"root-ro-prefix", true, false},
+		{"root-rw-prefix", true, true},
+		{"child-nope", false, false},
+		{"child-ro", true, false},
+		{"child-rw", true, true},
+		{"child-nope-prefix", false, false},
+		{"child-ro-prefix", true, false},
+		{"child-rw-prefix", true, true},
+		{"override", true, true},
+	}
+	for _, c := range cases {
+		if c.read != acl.AgentRead(c.inp) {
+			t.Fatalf("Read fail: %#v", c)
+		}
+		if c.write != acl.AgentWrite(c.inp) {
+			t.Fatalf("Write fail: %#v", c)
+		}
+	}
+}
+
+func TestPolicyACL_Session(t *testing.T) {
+	deny := DenyAll()
+	policyRoot := &Policy{
+		Sessions: []*SessionPolicy{
+			&SessionPolicy{
+				Node:   "root-nope",
+				Policy: PolicyDeny,
+			},
+			&SessionPolicy{
+				Node:   "root-ro",
+				Policy: PolicyRead,
+			},
+			&SessionPolicy{
+				Node:   "root-rw",
+				Policy: PolicyWrite,
+			},
+			&SessionPolicy{
+				Node:   "override",
+				Policy: PolicyDeny,
+			},
+		},
+	}
+	root, err := New(deny, policyRoot)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	policy := &Policy{
+		Sessions: []*SessionPolicy{
+			&SessionPolicy{
+				Node:   "child-nope",
+				Policy: PolicyDeny,
+			},
+			&SessionPolicy{
+				Node:   "child-ro",
+				Policy: PolicyRead,
+			},
+			&SessionPolicy{
+				Node:   "child-rw",
+				Policy: PolicyWrite,
+			},
+			&SessionPolicy{
+				Node:   "override",
+				Policy: PolicyWrite,
+			},
+		},
+	}
+	acl, err := New(root, policy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type sessioncase struct {
+		inp   string
+		read  bool
+		write bool
+	}
+	cases := []sessioncase{
+		{"nope", false, false},
+		{"root-nope", false, false},
+		{"root-ro", true, false},
+		{"root-rw", true, true},
+		{"root-nope-prefix", false, false},
+		{"root-ro-prefix", true, false},
+		{"root-rw-prefix", true, true},
+		{"child-nope", false, false},
+		{"child-ro", true, false},
+		{"child-rw", true, true},
+		{"child-nope-prefix", false, false},
+		{"child-ro-prefix", true, false},
+		{"child-rw-prefix", true, true},
+		{"override", true, true},
+	}
+	for _, c := range cases {
+		if c.read != acl.SessionRead(c.inp) {
+			t.Fatalf("Read fail: %#v", c)
+		}
+		if c.write != acl.SessionWrite(c.inp) {
+			t.Fatalf("Write fail: %#v", c)
+		}
+	}
+}
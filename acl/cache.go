@@ -3,13 +3,17 @@ package acl
 import (
 	"crypto/md5"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/golang-lru"
 )
 
 // FaultFunc is a function used to fault in the parent,
-// rules for an ACL given its ID
-type FaultFunc func(id string) (string, string, error)
+// rules for an ACL given its ID. An ACL may have more than
+// one set of rules, such as a token linked to several ACL
+// policies, in which case they are merged into one policy.
+type FaultFunc func(id string) (string, []string, error)
 
 // aclEntry allows us to store the ACL with it's policy ID
 type aclEntry struct {
@@ -84,6 +88,60 @@ func RuleID(rules string) string {
 	return fmt.Sprintf("%x", md5.Sum([]byte(rules)))
 }
 
+// RuleSetID is used to generate an ID for a set of rules that
+// are merged together. The order of the rules doesn't matter,
+// and a single set of rules gets the same ID as from RuleID.
+func RuleSetID(rules []string) string {
+	switch len(rules) {
+	case 0:
+		return RuleID("")
+	case 1:
+		return RuleID(rules[0])
+	}
+
+	ids := make([]string, 0, len(rules))
+	for _, r := range rules {
+		ids = append(ids, RuleID(r))
+	}
+	sort.Strings(ids)
+	return RuleID(strings.Join(ids, ","))
+}
+
+// GetMergedPolicy is used to get a potentially cached policy
+// made by merging the given sets of rules. If not cached, each
+// set will be parsed and merged, and then cached.
+func (c *Cache) GetMergedPolicy(rules []string) (*Policy, error) {
+	return c.getMergedPolicy(RuleSetID(rules), rules)
+}
+
+// getMergedPolicy is an internal method to get a cached merged
+// policy, but it assumes a pre-computed ID
+func (c *Cache) getMergedPolicy(id string, rules []string) (*Policy, error) {
+	switch len(rules) {
+	case 0:
+		return c.getPolicy(id, "")
+	case 1:
+		return c.getPolicy(id, rules[0])
+	}
+
+	raw, ok := c.ruleCache.Get(id)
+	if ok {
+		return raw.(*Policy), nil
+	}
+	var policies []*Policy
+	for _, r := range rules {
+		policy, err := c.GetPolicy(r)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	policy := Merge(policies...)
+	policy.ID = id
+	c.ruleCache.Add(id, policy)
+	return policy, nil
+}
+
 // policyID returns the cache ID for a policy
 func (c *Cache) policyID(parent, ruleID string) string {
 	return parent + ":" + ruleID
@@ -107,7 +165,7 @@ func (c *Cache) GetACLPolicy(id string) (string, *Policy, error) {
 	}
 
 	// Get cached
-	policy, err := c.GetPolicy(rules)
+	policy, err := c.GetMergedPolicy(rules)
 	return parent, policy, err
 }
 
@@ -125,7 +183,7 @@ func (c *Cache) GetACL(id string) (ACL, error) {
 	if err != nil {
 		return nil, err
 	}
-	ruleID := RuleID(rules)
+	ruleID := RuleSetID(rules)
 
 	// Check for a compiled ACL
 	policyID := c.policyID(parentID, ruleID)
@@ -134,7 +192,7 @@ func (c *Cache) GetACL(id string) (ACL, error) {
 		compiled = raw.(ACL)
 	} else {
 		// Get the policy
-		policy, err := c.getPolicy(ruleID, rules)
+		policy, err := c.getMergedPolicy(ruleID, rules)
 		if err != nil {
 			return nil, err
 		}
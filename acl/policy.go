@@ -16,15 +16,28 @@ const (
 // an ACL configuration.
 type Policy struct {
 	ID              string                 `hcl:"-"`
+	Agents          []*AgentPolicy         `hcl:"agent,expand"`
 	Keys            []*KeyPolicy           `hcl:"key,expand"`
 	Nodes           []*NodePolicy          `hcl:"node,expand"`
 	Services        []*ServicePolicy       `hcl:"service,expand"`
+	Sessions        []*SessionPolicy       `hcl:"session,expand"`
 	Events          []*EventPolicy         `hcl:"event,expand"`
 	PreparedQueries []*PreparedQueryPolicy `hcl:"query,expand"`
 	Keyring         string                 `hcl:"keyring"`
 	Operator        string                 `hcl:"operator"`
 }
 
+// AgentPolicy represents a policy for working with agent endpoints on nodes
+// with specific name prefixes.
+type AgentPolicy struct {
+	Node   string `hcl:",key"`
+	Policy string
+}
+
+func (a *AgentPolicy) GoString() string {
+	return fmt.Sprintf("%#v", *a)
+}
+
 // KeyPolicy represents a policy for a key
 type KeyPolicy struct {
 	Prefix string `hcl:",key"`
@@ -55,6 +68,17 @@ func (s *ServicePolicy) GoString() string {
 	return fmt.Sprintf("%#v", *s)
 }
 
+// SessionPolicy represents a policy for making sessions tied to nodes with
+// specific name prefixes.
+type SessionPolicy struct {
+	Node   string `hcl:",key"`
+	Policy string
+}
+
+func (s *SessionPolicy) GoString() string {
+	return fmt.Sprintf("%#v", *s)
+}
+
 // EventPolicy represents a user event policy.
 type EventPolicy struct {
 	Event  string `hcl:",key"`
@@ -104,6 +128,13 @@ func Parse(rules string) (*Policy, error) {
 		return nil, fmt.Errorf("Failed to parse ACL rules: %v", err)
 	}
 
+	// Validate the agent policy
+	for _, ap := range p.Agents {
+		if !isPolicyValid(ap.Policy) {
+			return nil, fmt.Errorf("Invalid agent policy: %#v", ap)
+		}
+	}
+
 	// Validate the key policy
 	for _, kp := range p.Keys {
 		if !isPolicyValid(kp.Policy) {
@@ -125,6 +156,13 @@ func Parse(rules string) (*Policy, error) {
 		}
 	}
 
+	// Validate the session policies
+	for _, sp := range p.Sessions {
+		if !isPolicyValid(sp.Policy) {
+			return nil, fmt.Errorf("Invalid session policy: %#v", sp)
+		}
+	}
+
 	// Validate the user event policies
 	for _, ep := range p.Events {
 		if !isPolicyValid(ep.Policy) {
@@ -151,3 +189,121 @@ func Parse(rules string) (*Policy, error) {
 
 	return p, nil
 }
+
+// policyPrecedence is used to settle conflicts between rules for the same
+// resource when merging policies. An explicit deny always wins, followed by
+// write and then read.
+var policyPrecedence = map[string]int{
+	"":          0,
+	PolicyRead:  1,
+	PolicyWrite: 2,
+	PolicyDeny:  3,
+}
+
+// mergePolicy returns whichever of the two policies takes precedence.
+func mergePolicy(a, b string) string {
+	if policyPrecedence[b] > policyPrecedence[a] {
+		return b
+	}
+	return a
+}
+
+// mergeRules merges rules given as resource and policy pairs into the
+// given map, keeping the order in which resources were first seen.
+func mergeRules(merged map[string]string, order []string, resource, policy string) []string {
+	if existing, ok := merged[resource]; ok {
+		merged[resource] = mergePolicy(existing, policy)
+		return order
+	}
+	merged[resource] = policy
+	return append(order, resource)
+}
+
+// Merge combines a set of policies into a single one that grants everything
+// any of them grant. When more than one policy has a rule for the exact same
+// resource, a deny takes precedence over write, which takes precedence over
+// read. The ID of the returned policy is left empty.
+func Merge(policies ...*Policy) *Policy {
+	out := &Policy{}
+
+	agents, agentOrder := make(map[string]string), []string{}
+	keys, keyOrder := make(map[string]string), []string{}
+	nodes, nodeOrder := make(map[string]string), []string{}
+	services, serviceOrder := make(map[string]string), []string{}
+	sessions, sessionOrder := make(map[string]string), []string{}
+	events, eventOrder := make(map[string]string), []string{}
+	queries, queryOrder := make(map[string]string), []string{}
+	for _, p := range policies {
+		for _, ap := range p.Agents {
+			agentOrder = mergeRules(agents, agentOrder, ap.Node, ap.Policy)
+		}
+		for _, kp := range p.Keys {
+			keyOrder = mergeRules(keys, keyOrder, kp.Prefix, kp.Policy)
+		}
+		for _, np := range p.Nodes {
+			nodeOrder = mergeRules(nodes, nodeOrder, np.Name, np.Policy)
+		}
+		for _, sp := range p.Services {
+			serviceOrder = mergeRules(services, serviceOrder, sp.Name, sp.Policy)
+		}
+		for _, sp := range p.Sessions {
+			sessionOrder = mergeRules(sessions, sessionOrder, sp.Node, sp.Policy)
+		}
+		for _, ep := range p.Events {
+			eventOrder = mergeRules(events, eventOrder, ep.Event, ep.Policy)
+		}
+		for _, pq := range p.PreparedQueries {
+			queryOrder = mergeRules(queries, queryOrder, pq.Prefix, pq.Policy)
+		}
+		out.Keyring = mergePolicy(out.Keyring, p.Keyring)
+		out.Operator = mergePolicy(out.Operator, p.Operator)
+	}
+
+	for _, node := range agentOrder {
+		out.Agents = append(out.Agents, &AgentPolicy{Node: node, Policy: agents[node]})
+	}
+	for _, prefix := range keyOrder {
+		out.Keys = append(out.Keys, &KeyPolicy{Prefix: prefix, Policy: keys[prefix]})
+	}
+	for _, name := range nodeOrder {
+		out.Nodes = append(out.Nodes, &NodePolicy{Name: name, Policy: nodes[name]})
+	}
+	for _, name := range serviceOrder {
+		out.Services = append(out.Services, &ServicePolicy{Name: name, Policy: services[name]})
+	}
+	for _, node := range sessionOrder {
+		out.Sessions = append(out.Sessions, &SessionPolicy{Node: node, Policy: sessions[node]})
+	}
+	for _, event := range eventOrder {
+		out.Events = append(out.Events, &EventPolicy{Event: event, Policy: events[event]})
+	}
+	for _, prefix := range queryOrder {
+		out.PreparedQueries = append(out.PreparedQueries, &PreparedQueryPolicy{Prefix: prefix, Policy: queries[prefix]})
+	}
+	return out
+}
+
+// ParseAll parses each of the given sets of rules and merges them into a
+// single policy, whose ID is the RuleSetID of the rules.
+func ParseAll(rules []string) (*Policy, error) {
+	if len(rules) == 1 {
+		policy, err := Parse(rules[0])
+		if err != nil {
+			return nil, err
+		}
+		policy.ID = RuleID(rules[0])
+		return policy, nil
+	}
+
+	var policies []*Policy
+	for _, r := range rules {
+		policy, err := Parse(r)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	merged := Merge(policies...)
+	merged.ID = RuleSetID(rules)
+	return merged, nil
+}
@@ -8,6 +8,12 @@ import (
 
 func TestACLPolicy_Parse_HCL(t *testing.T) {
 	inp := `
+agent "foo" {
+	policy = "read"
+}
+agent "bar" {
+	policy = "write"
+}
 event "" {
 	policy = "read"
 }
@@ -54,9 +60,25 @@ query "foo" {
 }
 query "bar" {
 	policy = "deny"
+}
+session "foo" {
+	policy = "write"
+}
+session "bar" {
+	policy = "deny"
 }
 	`
 	exp := &Policy{
+		Agents: []*AgentPolicy{
+			&AgentPolicy{
+				Node:   "foo",
+				Policy: PolicyRead,
+			},
+			&AgentPolicy{
+				Node:   "bar",
+				Policy: PolicyWrite,
+			},
+		},
 		Events: []*EventPolicy{
 			&EventPolicy{
 				Event:  "",
@@ -129,6 +151,16 @@ query "bar" {
 				Policy: PolicyRead,
 			},
 		},
+		Sessions: []*SessionPolicy{
+			&SessionPolicy{
+				Node:   "foo",
+				Policy: PolicyWrite,
+			},
+			&SessionPolicy{
+				Node:   "bar",
+				Policy: PolicyDeny,
+			},
+		},
 	}
 
 	out, err := Parse(inp)
@@ -143,6 +175,14 @@ query "bar" {
 
 func TestACLPolicy_Parse_JSON(t *testing.T) {
 	inp := `{
+	"agent": {
+		"foo": {
+			"policy": "read"
+		},
+		"bar": {
+			"policy": "write"
+		}
+	},
 	"event": {
 		"": {
 			"policy": "read"
@@ -199,9 +239,27 @@ func TestACLPolicy_Parse_JSON(t *testing.T) {
 		"foo": {
 			"policy": "read"
 		}
+	},
+	"session": {
+		"foo": {
+			"policy": "write"
+		},
+		"bar": {
+			"policy": "deny"
+		}
 	}
 }`
 	exp := &Policy{
+		Agents: []*AgentPolicy{
+			&AgentPolicy{
+				Node:   "foo",
+				Policy: PolicyRead,
+			},
+			&AgentPolicy{
+				Node:   "bar",
+				Policy: PolicyWrite,
+			},
+		},
 		Events: []*EventPolicy{
 			&EventPolicy{
 				Event:  "",
@@ -274,6 +332,16 @@ func TestACLPolicy_Parse_JSON(t *testing.T) {
 				Policy: PolicyRead,
 			},
 		},
+		Sessions: []*SessionPolicy{
+			&SessionPolicy{
+				Node:   "foo",
+				Policy: PolicyWrite,
+			},
+			&SessionPolicy{
+				Node:   "bar",
+				Policy: PolicyDeny,
+			},
+		},
 	}
 
 	out, err := Parse(inp)
@@ -324,6 +392,7 @@ operator = ""
 
 func TestACLPolicy_Bad_Policy(t *testing.T) {
 	cases := []string{
+		`agent "" { policy = "nope" }`,
 		`event "" { policy = "nope" }`,
 		`key "" { policy = "nope" }`,
 		`keyring = "nope"`,
@@ -331,6 +400,7 @@ func TestACLPolicy_Bad_Policy(t *testing.T) {
 		`operator = "nope"`,
 		`query "" { policy = "nope" }`,
 		`service "" { policy = "nope" }`,
+		`session "" { policy = "nope" }`,
 	}
 	for _, c := range cases {
 		_, err := Parse(c)
@@ -339,3 +409,102 @@ func TestACLPolicy_Bad_Policy(t *testing.T) {
 		}
 	}
 }
+
+func TestACLPolicy_Merge(t *testing.T) {
+	p1, err := Parse(`
+key "foo/" { policy = "read" }
+key "bar/" { policy = "write" }
+service "web" { policy = "write" }
+event "deploy" { policy = "read" }
+agent "web-" { policy = "read" }
+keyring = "read"
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	p2, err := Parse(`
+key "foo/" { policy = "write" }
+key "bar/" { policy = "deny" }
+node "" { policy = "read" }
+event "deploy" { policy = "write" }
+query "" { policy = "read" }
+agent "web-" { policy = "write" }
+session "" { policy = "read" }
+operator = "write"
+keyring = "deny"
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	exp := &Policy{
+		Agents: []*AgentPolicy{
+			&AgentPolicy{Node: "web-", Policy: PolicyWrite},
+		},
+		Keys: []*KeyPolicy{
+			&KeyPolicy{Prefix: "foo/", Policy: PolicyWrite},
+			&KeyPolicy{Prefix: "bar/", Policy: PolicyDeny},
+		},
+		Nodes: []*NodePolicy{
+			&NodePolicy{Name: "", Policy: PolicyRead},
+		},
+		Services: []*ServicePolicy{
+			&ServicePolicy{Name: "web", Policy: PolicyWrite},
+		},
+		Sessions: []*SessionPolicy{
+			&SessionPolicy{Node: "", Policy: PolicyRead},
+		},
+		Events: []*EventPolicy{
+			&EventPolicy{Event: "deploy", Policy: PolicyWrite},
+		},
+		PreparedQueries: []*PreparedQueryPolicy{
+			&PreparedQueryPolicy{Prefix: "", Policy: PolicyRead},
+		},
+		Keyring:  PolicyDeny,
+		Operator: PolicyWrite,
+	}
+	out := Merge(p1, p2)
+	if !reflect.DeepEqual(out, exp) {
+		t.Fatalf("bad: %#v %#v", out, exp)
+	}
+
+	// Merging a single policy gives back the same rules.
+	out = Merge(p1)
+	p1.ID = ""
+	if !reflect.DeepEqual(out, p1) {
+		t.Fatalf("bad: %#v %#v", out, p1)
+	}
+
+	// Merging nothing gives an empty policy.
+	if out := Merge(); !reflect.DeepEqual(out, &Policy{}) {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
+func TestACLPolicy_ParseAll(t *testing.T) {
+	rules := []string{
+		`key "foo/" { policy = "read" }`,
+		`key "bar/" { policy = "read" }`,
+	}
+	out, err := ParseAll(rules)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Keys) != 2 || out.ID != RuleSetID(rules) {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// The ID doesn't depend on the order of the rules, and a single set of
+	// rules gets the usual ID.
+	if RuleSetID(rules) != RuleSetID([]string{rules[1], rules[0]}) {
+		t.Fatalf("should match")
+	}
+	if RuleSetID(rules[:1]) != RuleID(rules[0]) || RuleSetID(nil) != RuleID("") {
+		t.Fatalf("should match")
+	}
+
+	// Any bad set of rules fails the whole thing.
+	if _, err := ParseAll([]string{rules[0], `key "" { policy = "nope" }`}); err == nil {
+		t.Fatalf("should fail")
+	}
+}
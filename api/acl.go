@@ -1,5 +1,9 @@
 package api
 
+import (
+	"time"
+)
+
 const (
 	// ACLCLientType is the client type token
 	ACLClientType = "client"
@@ -16,6 +20,35 @@ type ACLEntry struct {
 	Name        string
 	Type        string
 	Rules       string
+	Policies    []string
+
+	// ExpirationTime is when the token expires, if ever. ExpirationTTL
+	// can be given instead when creating or updating a token, to have it
+	// expire that long after the change is applied.
+	ExpirationTime *time.Time    `json:",omitempty"`
+	ExpirationTTL  time.Duration `json:",omitempty"`
+}
+
+// ACLPolicyEntry is used to represent an ACL policy, which is a named set of
+// rules that can be linked to any number of tokens
+type ACLPolicyEntry struct {
+	CreateIndex uint64
+	ModifyIndex uint64
+	ID          string
+	Name        string
+	Rules       string
+	Datacenters []string
+}
+
+// ACLReplicationStatus is used to represent the status of ACL replication
+// in a datacenter
+type ACLReplicationStatus struct {
+	Enabled          bool
+	Running          bool
+	SourceDatacenter string
+	ReplicatedIndex  uint64
+	LastSuccess      time.Time
+	LastError        time.Time
 }
 
 // ACL can be used to query the ACL endpoints
@@ -138,3 +171,117 @@ func (a *ACL) List(q *QueryOptions) ([]*ACLEntry, *QueryMeta, error) {
 	}
 	return entries, qm, nil
 }
+
+// PolicyCreate is used to create a new ACL policy
+func (a *ACL) PolicyCreate(policy *ACLPolicyEntry, q *WriteOptions) (string, *WriteMeta, error) {
+	r := a.c.newRequest("PUT", "/v1/acl/policy/create")
+	r.setWriteOptions(q)
+	r.obj = policy
+	rtt, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	wm := &WriteMeta{RequestTime: rtt}
+	var out struct{ ID string }
+	if err := decodeBody(resp, &out); err != nil {
+		return "", nil, err
+	}
+	return out.ID, wm, nil
+}
+
+// PolicyUpdate is used to update an existing ACL policy
+func (a *ACL) PolicyUpdate(policy *ACLPolicyEntry, q *WriteOptions) (*WriteMeta, error) {
+	r := a.c.newRequest("PUT", "/v1/acl/policy/update")
+	r.setWriteOptions(q)
+	r.obj = policy
+	rtt, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	wm := &WriteMeta{RequestTime: rtt}
+	return wm, nil
+}
+
+// PolicyDestroy is used to destroy a given ACL policy ID
+func (a *ACL) PolicyDestroy(id string, q *WriteOptions) (*WriteMeta, error) {
+	r := a.c.newRequest("PUT", "/v1/acl/policy/destroy/"+id)
+	r.setWriteOptions(q)
+	rtt, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	wm := &WriteMeta{RequestTime: rtt}
+	return wm, nil
+}
+
+// PolicyInfo is used to query for information about an ACL policy
+func (a *ACL) PolicyInfo(id string, q *QueryOptions) (*ACLPolicyEntry, *QueryMeta, error) {
+	r := a.c.newRequest("GET", "/v1/acl/policy/info/"+id)
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var entries []*ACLPolicyEntry
+	if err := decodeBody(resp, &entries); err != nil {
+		return nil, nil, err
+	}
+	if len(entries) > 0 {
+		return entries[0], qm, nil
+	}
+	return nil, qm, nil
+}
+
+// PolicyList is used to get all the ACL policies
+func (a *ACL) PolicyList(q *QueryOptions) ([]*ACLPolicyEntry, *QueryMeta, error) {
+	r := a.c.newRequest("GET", "/v1/acl/policies")
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var entries []*ACLPolicyEntry
+	if err := decodeBody(resp, &entries); err != nil {
+		return nil, nil, err
+	}
+	return entries, qm, nil
+}
+
+// Replication returns the status of ACL replication in the datacenter
+func (a *ACL) Replication(q *QueryOptions) (*ACLReplicationStatus, *QueryMeta, error) {
+	r := a.c.newRequest("GET", "/v1/acl/replication")
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(a.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var entry ACLReplicationStatus
+	if err := decodeBody(resp, &entry); err != nil {
+		return nil, nil, err
+	}
+	return &entry, qm, nil
+}
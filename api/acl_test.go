@@ -2,6 +2,7 @@ package api
 
 import (
 	"testing"
+	"time"
 )
 
 func TestACL_CreateDestroy(t *testing.T) {
@@ -49,6 +50,49 @@ func TestACL_CreateDestroy(t *testing.T) {
 	}
 }
 
+func TestACL_Expiration(t *testing.T) {
+	t.Parallel()
+	c, s := makeACLClient(t)
+	defer s.Stop()
+
+	acl := c.ACL()
+
+	start := time.Now()
+	ae := ACLEntry{
+		Name:          "API test",
+		Type:          ACLClientType,
+		ExpirationTTL: time.Hour,
+	}
+	id, _, err := acl.Create(&ae, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ae2, _, err := acl.Info(id, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ae2.ExpirationTTL != 0 || ae2.ExpirationTime == nil ||
+		ae2.ExpirationTime.Before(start.Add(time.Hour)) ||
+		ae2.ExpirationTime.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("Bad: %#v", ae2)
+	}
+
+	// Updating with the expiration time from the info keeps it.
+	ae2.Name = "API test 2"
+	if _, err := acl.Update(ae2, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ae3, _, err := acl.Info(id, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ae3.Name != "API test 2" || ae3.ExpirationTime == nil ||
+		!ae3.ExpirationTime.Equal(*ae2.ExpirationTime) {
+		t.Fatalf("Bad: %#v", ae3)
+	}
+}
+
 func TestACL_CloneDestroy(t *testing.T) {
 	t.Parallel()
 	c, s := makeACLClient(t)
@@ -126,3 +170,96 @@ func TestACL_List(t *testing.T) {
 		t.Fatalf("bad: %v", qm)
 	}
 }
+
+func TestACL_Policies(t *testing.T) {
+	t.Parallel()
+	c, s := makeACLClient(t)
+	defer s.Stop()
+
+	acl := c.ACL()
+
+	policy := ACLPolicyEntry{
+		Name:        "web",
+		Rules:       `service "web" { policy = "write" }`,
+		Datacenters: []string{"dc1"},
+	}
+	id, wm, err := acl.PolicyCreate(&policy, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if wm.RequestTime == 0 || id == "" {
+		t.Fatalf("bad: %v %v", wm, id)
+	}
+
+	// Link the policy to a token.
+	tokenID, _, err := acl.Create(&ACLEntry{Name: "web", Type: ACLClientType, Policies: []string{id}}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	token, _, err := acl.Info(tokenID, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(token.Policies) != 1 || token.Policies[0] != id {
+		t.Fatalf("bad: %#v", token)
+	}
+
+	// Update the policy and read it back.
+	policy.ID = id
+	policy.Rules = `service "web" { policy = "read" }`
+	if _, err := acl.PolicyUpdate(&policy, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	p, qm, err := acl.PolicyInfo(id, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if qm.LastIndex == 0 || p == nil || p.Name != "web" || p.Rules != policy.Rules ||
+		len(p.Datacenters) != 1 || p.CreateIndex == p.ModifyIndex {
+		t.Fatalf("bad: %v %#v", qm, p)
+	}
+
+	policies, _, err := acl.PolicyList(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(policies) != 1 || policies[0].ID != id {
+		t.Fatalf("bad: %v", policies)
+	}
+
+	// The policy can only be destroyed once it's not linked.
+	if _, err := acl.PolicyDestroy(id, nil); err == nil {
+		t.Fatalf("should fail")
+	}
+	if _, err := acl.Destroy(tokenID, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := acl.PolicyDestroy(id, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	p, _, err = acl.PolicyInfo(id, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if p != nil {
+		t.Fatalf("bad: %#v", p)
+	}
+}
+
+func TestACL_Replication(t *testing.T) {
+	t.Parallel()
+	c, s := makeACLClient(t)
+	defer s.Stop()
+
+	acl := c.ACL()
+
+	status, _, err := acl.Replication(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Replication isn't set up for the test server.
+	if status.Enabled || status.Running {
+		t.Fatalf("bad: %v", status)
+	}
+}
@@ -15,6 +15,12 @@ type AgentCheck struct {
 	Output      string
 	ServiceID   string
 	ServiceName string
+
+	// SuccessCount and FailureCount are the number of consecutive
+	// successful and failed results seen by the agent. They are only set
+	// for checks returned by Checks.
+	SuccessCount int `json:",omitempty"`
+	FailureCount int `json:",omitempty"`
 }
 
 // AgentService represents a service known to the agent
@@ -22,9 +28,20 @@ type AgentService struct {
 	ID                string
 	Service           string
 	Tags              []string
+	Meta              map[string]string
 	Port              int
 	Address           string
+	Weights           *AgentWeights
 	EnableTagOverride bool
+	CreateIndex       uint64
+	ModifyIndex       uint64
+}
+
+// AgentWeights represent the relative share of traffic a service instance
+// gets while its checks are passing or warning.
+type AgentWeights struct {
+	Passing int
+	Warning int
 }
 
 // AgentMember represents a cluster member known to the agent
@@ -44,12 +61,14 @@ type AgentMember struct {
 
 // AgentServiceRegistration is used to register a new service
 type AgentServiceRegistration struct {
-	ID                string   `json:",omitempty"`
-	Name              string   `json:",omitempty"`
-	Tags              []string `json:",omitempty"`
-	Port              int      `json:",omitempty"`
-	Address           string   `json:",omitempty"`
-	EnableTagOverride bool     `json:",omitempty"`
+	ID                string            `json:",omitempty"`
+	Name              string            `json:",omitempty"`
+	Tags              []string          `json:",omitempty"`
+	Port              int               `json:",omitempty"`
+	Address           string            `json:",omitempty"`
+	EnableTagOverride bool              `json:",omitempty"`
+	Meta              map[string]string `json:",omitempty"`
+	Weights           *AgentWeights     `json:",omitempty"`
 	Check             *AgentServiceCheck
 	Checks            AgentServiceChecks
 }
@@ -76,6 +95,33 @@ type AgentServiceCheck struct {
 	Status            string `json:",omitempty"`
 	Notes             string `json:",omitempty"`
 	TLSSkipVerify     bool   `json:",omitempty"`
+	GRPC              string `json:",omitempty"`
+	GRPCUseTLS        bool   `json:",omitempty"`
+
+	// Method, Header and Body customize the request made by an HTTP check.
+	Method string              `json:",omitempty"`
+	Header map[string][]string `json:",omitempty"`
+	Body   string              `json:",omitempty"`
+
+	// ExpectedStatusMin and ExpectedStatusMax are the range of response
+	// codes that make an HTTP check pass, and ExpectedBody and
+	// ExpectedBodyRegex must be found in the response body for it to pass.
+	ExpectedStatusMin int    `json:",omitempty"`
+	ExpectedStatusMax int    `json:",omitempty"`
+	ExpectedBody      string `json:",omitempty"`
+	ExpectedBodyRegex string `json:",omitempty"`
+
+	// SuccessBeforePassing and FailuresBeforeCritical are the number of
+	// consecutive passing or critical results needed before the check's
+	// status changes.
+	SuccessBeforePassing   int `json:",omitempty"`
+	FailuresBeforeCritical int `json:",omitempty"`
+
+	// AliasNode and AliasService make the check mirror the health of
+	// another node, or of a service instance on a node. If only the
+	// service is given, it's looked up on the agent's node.
+	AliasNode    string `json:",omitempty"`
+	AliasService string `json:",omitempty"`
 
 	// In Consul 0.7 and later, checks that are associated with a service
 	// may also contain this optional DeregisterCriticalServiceAfter field,
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"fmt"
 	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -136,6 +138,97 @@ func TestAgent_Services(t *testing.T) {
 	}
 }
 
+func TestAgent_Services_Meta(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	agent := c.Agent()
+
+	reg := &AgentServiceRegistration{
+		Name: "foo",
+		Port: 8000,
+		Meta: map[string]string{"version": "1.2.3"},
+	}
+	if err := agent.ServiceRegister(reg); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	services, err := agent.Services()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(services["foo"].Meta, reg.Meta) {
+		t.Fatalf("bad: %v", services["foo"])
+	}
+
+	// The metadata should make it into the catalog after a sync
+	testutil.WaitForResult(func() (bool, error) {
+		nodes, _, err := c.Catalog().Service("foo", "", nil)
+		if err != nil {
+			return false, err
+		}
+		if len(nodes) != 1 || !reflect.DeepEqual(nodes[0].ServiceMeta, reg.Meta) {
+			return false, fmt.Errorf("bad: %v", nodes)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+
+	if err := agent.ServiceDeregister("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestAgent_Services_Weights(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	agent := c.Agent()
+
+	reg := &AgentServiceRegistration{
+		Name:    "foo",
+		Port:    8000,
+		Weights: &AgentWeights{Passing: 0, Warning: 1},
+	}
+	if err := agent.ServiceRegister(reg); err == nil {
+		t.Fatalf("should fail")
+	}
+
+	reg.Weights.Passing = 10
+	if err := agent.ServiceRegister(reg); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	services, err := agent.Services()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(services["foo"].Weights, reg.Weights) {
+		t.Fatalf("bad: %v", services["foo"])
+	}
+
+	// The weights should make it into the catalog after a sync
+	testutil.WaitForResult(func() (bool, error) {
+		nodes, _, err := c.Catalog().Service("foo", "", nil)
+		if err != nil {
+			return false, err
+		}
+		if len(nodes) != 1 || !reflect.DeepEqual(nodes[0].ServiceWeights, reg.Weights) {
+			return false, fmt.Errorf("bad: %v", nodes)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+
+	if err := agent.ServiceDeregister("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestAgent_Services_CheckPassing(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
@@ -485,6 +578,53 @@ func TestAgent_CheckStartPassing(t *testing.T) {
 	}
 }
 
+func TestAgent_Checks_FailuresBeforeCritical(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	agent := c.Agent()
+
+	// Point a TCP check at a port nobody is listening on.
+	reg := &AgentCheckRegistration{
+		Name: "foo",
+		AgentServiceCheck: AgentServiceCheck{
+			TCP:                    "127.0.0.1:1",
+			Interval:               "1s",
+			Status:                 HealthPassing,
+			FailuresBeforeCritical: 100,
+		},
+	}
+	if err := agent.CheckRegister(reg); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The failures should be counted without the check going critical.
+	testutil.WaitForResult(func() (bool, error) {
+		checks, err := agent.Checks()
+		if err != nil {
+			return false, err
+		}
+		chk, ok := checks["foo"]
+		if !ok {
+			return false, fmt.Errorf("missing check: %v", checks)
+		}
+		if chk.Status != HealthPassing {
+			return false, fmt.Errorf("check not passing: %v", chk)
+		}
+		if chk.FailureCount < 1 || chk.SuccessCount != 0 {
+			return false, fmt.Errorf("bad: %v", chk)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+
+	if err := agent.CheckDeregister("foo"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestAgent_Checks_serviceBound(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
@@ -74,6 +74,25 @@ type QueryOptions struct {
 	// that node. Setting this to "_agent" will use the agent's node
 	// for the sort.
 	Near string
+
+	// NodeMeta is used to filter results by nodes with the given
+	// metadata key/value pairs. All pairs must match for a node to be
+	// included.
+	NodeMeta map[string]string
+
+	// Filter is an expression used to filter the results on the server
+	// before they are returned, for endpoints that support it.
+	Filter string
+
+	// Limit is used to page through a listing, for endpoints that support
+	// it, by returning at most this many results. If there are more, the
+	// NextAfter in the QueryMeta is set, and can be given as After to get
+	// the next page.
+	Limit int
+
+	// After is used with Limit to return only the results that sort after
+	// the given cursor.
+	After string
 }
 
 // WriteOptions are used to parameterize a write
@@ -105,6 +124,10 @@ type QueryMeta struct {
 
 	// Is address translation enabled for HTTP responses on this agent
 	AddressTranslationEnabled bool
+
+	// NextAfter is set if a paged listing has more results, and is the
+	// After to use to get the next page.
+	NextAfter string
 }
 
 // WriteMeta is used to return meta data about a write
@@ -386,6 +409,20 @@ func (r *request) setQueryOptions(q *QueryOptions) {
 	if q.Near != "" {
 		r.params.Set("near", q.Near)
 	}
+	if q.Filter != "" {
+		r.params.Set("filter", q.Filter)
+	}
+	if q.Limit != 0 {
+		r.params.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.After != "" {
+		r.params.Set("after", q.After)
+	}
+	if len(q.NodeMeta) > 0 {
+		for key, value := range q.NodeMeta {
+			r.params.Add("node-meta", key+":"+value)
+		}
+	}
 }
 
 // durToMsec converts a duration to a millisecond specified string. If the
@@ -576,6 +613,9 @@ func parseQueryMeta(resp *http.Response, q *QueryMeta) error {
 		q.AddressTranslationEnabled = false
 	}
 
+	// Parse the X-Consul-Next-After
+	q.NextAfter = header.Get("X-Consul-Next-After")
+
 	return nil
 }
 
@@ -229,6 +229,9 @@ func TestSetQueryOptions(t *testing.T) {
 		WaitTime:          100 * time.Second,
 		Token:             "12345",
 		Near:              "nodex",
+		Filter:            `Node == "foo"`,
+		Limit:             10,
+		After:             "foo/bar",
 	}
 	r.setQueryOptions(q)
 
@@ -253,6 +256,15 @@ func TestSetQueryOptions(t *testing.T) {
 	if r.params.Get("near") != "nodex" {
 		t.Fatalf("bad: %v", r.params)
 	}
+	if r.params.Get("filter") != `Node == "foo"` {
+		t.Fatalf("bad: %v", r.params)
+	}
+	if r.params.Get("limit") != "10" {
+		t.Fatalf("bad: %v", r.params)
+	}
+	if r.params.Get("after") != "foo/bar" {
+		t.Fatalf("bad: %v", r.params)
+	}
 }
 
 func TestSetWriteOptions(t *testing.T) {
@@ -307,6 +319,7 @@ func TestParseQueryMeta(t *testing.T) {
 	resp.Header.Set("X-Consul-LastContact", "80")
 	resp.Header.Set("X-Consul-KnownLeader", "true")
 	resp.Header.Set("X-Consul-Translate-Addresses", "true")
+	resp.Header.Set("X-Consul-Next-After", "foo/bar")
 
 	qm := &QueryMeta{}
 	if err := parseQueryMeta(resp, qm); err != nil {
@@ -325,6 +338,9 @@ func TestParseQueryMeta(t *testing.T) {
 	if !qm.AddressTranslationEnabled {
 		t.Fatalf("Bad: %v", qm)
 	}
+	if qm.NextAfter != "foo/bar" {
+		t.Fatalf("Bad: %v", qm)
+	}
 }
 
 func TestAPI_UnixSocket(t *testing.T) {
@@ -2,9 +2,11 @@ package api
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/go-uuid"
 )
 
 func TestCatalog_Datacenters(t *testing.T) {
@@ -50,6 +52,10 @@ func TestCatalog_Nodes(t *testing.T) {
 			return false, fmt.Errorf("Bad: %v", nodes)
 		}
 
+		if _, err := uuid.ParseUUID(nodes[0].ID); err != nil {
+			return false, fmt.Errorf("Bad: %v", nodes[0])
+		}
+
 		if _, ok := nodes[0].TaggedAddresses["wan"]; !ok {
 			return false, fmt.Errorf("Bad: %v", nodes[0])
 		}
@@ -60,6 +66,96 @@ func TestCatalog_Nodes(t *testing.T) {
 	})
 }
 
+func TestCatalog_Nodes_MetaFilter(t *testing.T) {
+	nodeMeta := map[string]string{"somekey": "somevalue"}
+	c, s := makeClientWithConfig(t, nil, func(conf *testutil.TestServerConfig) {
+		conf.NodeMeta = nodeMeta
+	})
+	defer s.Stop()
+
+	catalog := c.Catalog()
+
+	// Make sure we get the node back when filtering by its metadata
+	testutil.WaitForResult(func() (bool, error) {
+		nodes, meta, err := catalog.Nodes(&QueryOptions{NodeMeta: nodeMeta})
+		if err != nil {
+			return false, err
+		}
+
+		if meta.LastIndex == 0 {
+			return false, fmt.Errorf("Bad: %v", meta)
+		}
+
+		if len(nodes) == 0 {
+			return false, fmt.Errorf("Bad: %v", nodes)
+		}
+
+		if v, ok := nodes[0].Meta["somekey"]; !ok || v != "somevalue" {
+			return false, fmt.Errorf("Bad: %v", nodes[0].Meta)
+		}
+
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+
+	// Get nothing back when we use an invalid filter
+	testutil.WaitForResult(func() (bool, error) {
+		nodes, meta, err := catalog.Nodes(&QueryOptions{NodeMeta: map[string]string{"nope": "nope"}})
+		if err != nil {
+			return false, err
+		}
+
+		if meta.LastIndex == 0 {
+			return false, fmt.Errorf("Bad: %v", meta)
+		}
+
+		if len(nodes) != 0 {
+			return false, fmt.Errorf("Bad: %v", nodes)
+		}
+
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+}
+
+func TestCatalog_Nodes_Filter(t *testing.T) {
+	c, s := makeClientWithConfig(t, nil, func(conf *testutil.TestServerConfig) {
+		conf.NodeMeta = map[string]string{"rack": "b"}
+	})
+	defer s.Stop()
+
+	catalog := c.Catalog()
+
+	testutil.WaitForResult(func() (bool, error) {
+		nodes, _, err := catalog.Nodes(&QueryOptions{Filter: `Meta.rack == "b"`})
+		if err != nil {
+			return false, err
+		}
+		if len(nodes) != 1 {
+			return false, fmt.Errorf("Bad: %v", nodes)
+		}
+
+		nodes, _, err = catalog.Nodes(&QueryOptions{Filter: `Meta.rack != "b"`})
+		if err != nil {
+			return false, err
+		}
+		if len(nodes) != 0 {
+			return false, fmt.Errorf("Bad: %v", nodes)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+
+	// Invalid expressions should come back as an error
+	_, _, err := catalog.Nodes(&QueryOptions{Filter: `Meta.rack ==`})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestCatalog_Services(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+const (
+	// StreamTopicServiceHealth carries the health of individual service
+	// instances, keyed by service name.
+	StreamTopicServiceHealth = "service-health"
+)
+
+const (
+	// StreamAdd, StreamUpdate and StreamDelete are used for changes to a
+	// single item. A snapshot is made up of StreamAdd events.
+	StreamAdd    = "add"
+	StreamUpdate = "update"
+	StreamDelete = "delete"
+
+	// StreamEndOfSnapshot follows the events that make up a snapshot of the
+	// current state, after which only changes are sent.
+	StreamEndOfSnapshot = "end-of-snapshot"
+
+	// StreamReset means that whatever was built up from the events so far
+	// should be thrown away, as a fresh snapshot follows.
+	StreamReset = "reset"
+)
+
+// StreamEvent is a single change delivered by a Subscription.
+type StreamEvent struct {
+	Topic string
+	Key   string
+	Index uint64
+	Op    string
+
+	// ServiceHealth is set for add, update and delete events on the
+	// service health topic. For deletes it holds the last known state.
+	ServiceHealth *ServiceEntry
+}
+
+// Subscription is used to receive the changes on a topic for a given key,
+// such as the instances of a service. It starts with a snapshot of the
+// current state, and then delivers changes as they happen. If the connection
+// to the agent is lost, the subscription transparently picks up where it
+// left off the next time Next is called.
+type Subscription struct {
+	e     *Event
+	topic string
+	key   string
+	q     QueryOptions
+
+	// index is the index of the last event for which all the events at or
+	// before it have been delivered.
+	index uint64
+
+	// partial is the index of the most recently delivered change, of
+	// which seen events have been delivered so far. A single index can
+	// have many events, so we can't count on having all of them until we
+	// see a later one.
+	partial uint64
+	seen    int
+
+	// skip is the number of events at the partial index that have already
+	// been delivered and will be sent again after a reconnect.
+	skip int
+
+	// inSnapshot is true while a snapshot is being delivered, and dirty
+	// is true if some of it has been delivered.
+	inSnapshot bool
+	dirty      bool
+
+	// resetPending is set when a synthetic reset needs to be delivered
+	// because a snapshot was interrupted.
+	resetPending bool
+
+	body   io.ReadCloser
+	dec    *json.Decoder
+	closed bool
+	lock   sync.Mutex
+}
+
+// Subscribe returns a subscription to the changes on the given topic and
+// key. If q has a WaitIndex, the subscription resumes after that index
+// instead of starting with a snapshot; if the agent can no longer provide
+// the changes since then, a reset event and a fresh snapshot are delivered.
+// The connection is made on the first call to Next.
+func (e *Event) Subscribe(topic, key string, q *QueryOptions) (*Subscription, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("Missing topic")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("Missing key")
+	}
+
+	s := &Subscription{
+		e:     e,
+		topic: topic,
+		key:   key,
+	}
+	if q != nil {
+		s.q = *q
+	}
+	s.index = s.q.WaitIndex
+	s.inSnapshot = s.index == 0
+	return s, nil
+}
+
+// Index returns the index that a new subscription could resume from without
+// missing any events. Some of the events after it may have been delivered
+// already.
+func (s *Subscription) Index() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.partial != 0 {
+		return s.partial - 1
+	}
+	return s.index
+}
+
+// Close ends the subscription. Any blocked call to Next returns an error.
+func (s *Subscription) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	if s.body != nil {
+		return s.body.Close()
+	}
+	return nil
+}
+
+// connect opens the stream, resuming from the last index that was fully
+// delivered.
+func (s *Subscription) connect() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("Subscription closed")
+	}
+
+	// Work out where to resume from. An interrupted snapshot means we
+	// have to start over, and an interrupted index means the events we
+	// have already seen at that index will be sent again.
+	var index uint64
+	switch {
+	case s.inSnapshot:
+		index = 0
+		if s.dirty {
+			s.resetPending = true
+			s.dirty = false
+		}
+	case s.partial != 0:
+		index = s.partial - 1
+		s.skip = s.seen
+	default:
+		index = s.index
+	}
+
+	r := s.e.c.newRequest("GET", "/v1/event/stream")
+	q := s.q
+	q.WaitIndex = 0
+	r.setQueryOptions(&q)
+	r.params.Set("topic", s.topic)
+	r.params.Set("key", s.key)
+	if index != 0 {
+		r.params.Set("index", strconv.FormatUint(index, 10))
+	}
+	_, resp, err := requireOK(s.e.c.doRequest(r))
+	if err != nil {
+		return err
+	}
+
+	s.body = resp.Body
+	s.dec = json.NewDecoder(resp.Body)
+	return nil
+}
+
+// disconnect closes the current connection, if there is one.
+func (s *Subscription) disconnect() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.body != nil {
+		s.body.Close()
+		s.body, s.dec = nil, nil
+	}
+	return s.closed
+}
+
+// Next blocks until the next event is available. Errors are returned if the
+// subscription is closed or the agent can't be reached; Next can be called
+// again to retry.
+func (s *Subscription) Next() (*StreamEvent, error) {
+	for {
+		if s.dec == nil {
+			if err := s.connect(); err != nil {
+				return nil, err
+			}
+		}
+
+		if s.resetPending {
+			s.resetPending = false
+			return &StreamEvent{Topic: s.topic, Key: s.key, Op: StreamReset}, nil
+		}
+
+		var event StreamEvent
+		if err := s.dec.Decode(&event); err != nil {
+			if closed := s.disconnect(); closed {
+				return nil, fmt.Errorf("Subscription closed")
+			}
+			continue
+		}
+
+		s.lock.Lock()
+		deliver := s.track(&event)
+		s.lock.Unlock()
+		if deliver {
+			return &event, nil
+		}
+	}
+}
+
+// track updates the resume state for the given event, and returns false if
+// the event has already been delivered.
+func (s *Subscription) track(event *StreamEvent) bool {
+	switch {
+	case event.Op == StreamReset:
+		s.inSnapshot, s.dirty = true, true
+		s.partial, s.seen, s.skip = 0, 0, 0
+
+	case event.Op == StreamEndOfSnapshot:
+		s.inSnapshot, s.dirty = false, false
+		s.index = event.Index
+		s.partial, s.seen, s.skip = 0, 0, 0
+
+	case s.inSnapshot:
+		s.dirty = true
+
+	default:
+		if s.skip > 0 && event.Index == s.partial {
+			s.skip--
+			return false
+		}
+		s.skip = 0
+
+		if event.Index != s.partial {
+			if s.partial != 0 {
+				s.index = s.partial
+			}
+			s.partial, s.seen = event.Index, 0
+		}
+		s.seen++
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvent_Subscribe(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	catalog := c.Catalog()
+	reg := &CatalogRegistration{
+		Datacenter: "dc1",
+		Node:       "foobar",
+		Address:    "192.168.10.10",
+		Service: &AgentService{
+			ID:      "web1",
+			Service: "web",
+		},
+		Check: &AgentCheck{
+			Node:      "foobar",
+			CheckID:   "service:web1",
+			Name:      "web alive",
+			Status:    HealthPassing,
+			ServiceID: "web1",
+		},
+	}
+	if _, err := catalog.Register(reg, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := c.Event().Subscribe("", "web", nil); err == nil {
+		t.Fatalf("should fail")
+	}
+	sub, err := c.Event().Subscribe(StreamTopicServiceHealth, "web", &QueryOptions{WaitTime: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer sub.Close()
+
+	next := func(op string) *StreamEvent {
+		event, err := sub.Next()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if event.Op != op || event.Topic != StreamTopicServiceHealth || event.Key != "web" {
+			t.Fatalf("bad: %#v", event)
+		}
+		return event
+	}
+
+	// Start with the snapshot.
+	add := next(StreamAdd)
+	if add.ServiceHealth == nil || add.ServiceHealth.Service.ID != "web1" ||
+		add.ServiceHealth.Node.Node != "foobar" || len(add.ServiceHealth.Checks) != 1 {
+		t.Fatalf("bad: %#v", add)
+	}
+	end := next(StreamEndOfSnapshot)
+	if sub.Index() != end.Index {
+		t.Fatalf("bad: %d", sub.Index())
+	}
+
+	// Fail the check.
+	reg.Check.Status = HealthCritical
+	if _, err := catalog.Register(reg, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	update := next(StreamUpdate)
+	if update.Index <= end.Index || update.ServiceHealth.Checks[0].Status != HealthCritical {
+		t.Fatalf("bad: %#v", update)
+	}
+
+	// Drop the connection. The update will be sent again when we
+	// reconnect, but it shouldn't be delivered twice.
+	sub.disconnect()
+	reg.Service.Port = 8080
+	if _, err := catalog.Register(reg, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	update = next(StreamUpdate)
+	if update.ServiceHealth.Service.Port != 8080 {
+		t.Fatalf("bad: %#v", update)
+	}
+
+	// A closed subscription returns an error.
+	if err := sub.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := sub.Next(); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestEvent_Subscribe_Resume(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	catalog := c.Catalog()
+	reg := &CatalogRegistration{
+		Datacenter: "dc1",
+		Node:       "foobar",
+		Address:    "192.168.10.10",
+		Service: &AgentService{
+			ID:      "web1",
+			Service: "web",
+		},
+	}
+	if _, err := catalog.Register(reg, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Get the index of the registration.
+	services, qm, err := catalog.Service("web", "", nil)
+	if err != nil || len(services) != 1 {
+		t.Fatalf("bad: %v %v", services, err)
+	}
+
+	// Make a change and then subscribe from before it, which should
+	// skip the snapshot.
+	if _, err := catalog.Deregister(&CatalogDeregistration{
+		Datacenter: "dc1",
+		Node:       "foobar",
+		ServiceID:  "web1",
+	}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	q := &QueryOptions{WaitIndex: qm.LastIndex, WaitTime: 100 * time.Millisecond}
+	sub, err := c.Event().Subscribe(StreamTopicServiceHealth, "web", q)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer sub.Close()
+
+	event, err := sub.Next()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if event.Op != StreamDelete || event.ServiceHealth.Service.ID != "web1" {
+		t.Fatalf("bad: %#v", event)
+	}
+}
@@ -33,6 +33,8 @@ type HealthCheck struct {
 	Output      string
 	ServiceID   string
 	ServiceName string
+	CreateIndex uint64
+	ModifyIndex uint64
 }
 
 // HealthChecks is a collection of HealthCheck structs.
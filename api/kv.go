@@ -40,11 +40,44 @@ type KVPair struct {
 	// interactions with this key over the same session must specify the same
 	// session ID.
 	Session string
+
+	// TTL, if set, is how long the key lives after it was last written
+	// before it's deleted, as a duration string such as "30s". Writing the
+	// key again restarts the TTL, and writing it without one removes it.
+	TTL string
 }
 
 // KVPairs is a list of KVPair objects
 type KVPairs []*KVPair
 
+// KVHistoryEntry is a prior version of a key, kept because the key is
+// covered by one of the servers' KV history rules.
+type KVHistoryEntry struct {
+	// Key is the name of the key.
+	Key string
+
+	// CreateIndex and ModifyIndex are the indexes the key had while this
+	// version was current.
+	CreateIndex uint64
+	ModifyIndex uint64
+
+	// LockIndex, Flags, Value, Session and TTL are the same as for a
+	// KVPair, as of this version.
+	LockIndex uint64
+	Flags     uint64
+	Value     []byte
+	Session   string
+	TTL       string
+
+	// ReplacedIndex is the index at which this version was overwritten or
+	// deleted. The version was current from its ModifyIndex up to, but not
+	// including, this index.
+	ReplacedIndex uint64
+}
+
+// KVHistory is a list of prior versions of a key, oldest first.
+type KVHistory []*KVHistoryEntry
+
 // KVOp constants give possible operations available in a KVTxn.
 type KVOp string
 
@@ -70,6 +103,7 @@ type KVTxnOp struct {
 	Flags   uint64
 	Index   uint64
 	Session string
+	TTL     string
 }
 
 // KVTxnOps defines a set of operations to be performed inside a single
@@ -114,6 +148,50 @@ func (k *KV) Get(key string, q *QueryOptions) (*KVPair, *QueryMeta, error) {
 	return nil, qm, nil
 }
 
+// GetAt is used to lookup a single key as it was at the given index. Older
+// versions are only known for keys covered by a KV history rule. The
+// returned pointer to the KVPair will be nil if the key did not exist at
+// that index, or if its version there has not been kept.
+func (k *KV) GetAt(key string, index uint64, q *QueryOptions) (*KVPair, *QueryMeta, error) {
+	params := map[string]string{"at": strconv.FormatUint(index, 10)}
+	resp, qm, err := k.getInternal(key, params, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		return nil, qm, nil
+	}
+	defer resp.Body.Close()
+
+	var entries []*KVPair
+	if err := decodeBody(resp, &entries); err != nil {
+		return nil, nil, err
+	}
+	if len(entries) > 0 {
+		return entries[0], qm, nil
+	}
+	return nil, qm, nil
+}
+
+// History is used to list the prior versions of a key that have been kept
+// by a KV history rule, oldest first.
+func (k *KV) History(key string, q *QueryOptions) (KVHistory, *QueryMeta, error) {
+	resp, qm, err := k.getInternal(key, map[string]string{"history": ""}, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		return nil, qm, nil
+	}
+	defer resp.Body.Close()
+
+	var entries []*KVHistoryEntry
+	if err := decodeBody(resp, &entries); err != nil {
+		return nil, nil, err
+	}
+	return entries, qm, nil
+}
+
 // List is used to lookup all keys under a prefix
 func (k *KV) List(prefix string, q *QueryOptions) (KVPairs, *QueryMeta, error) {
 	resp, qm, err := k.getInternal(prefix, map[string]string{"recurse": ""}, q)
@@ -183,10 +261,13 @@ func (k *KV) getInternal(key string, params map[string]string, q *QueryOptions)
 // Put is used to write a new value. Only the
 // Key, Flags and Value is respected.
 func (k *KV) Put(p *KVPair, q *WriteOptions) (*WriteMeta, error) {
-	params := make(map[string]string, 1)
+	params := make(map[string]string, 2)
 	if p.Flags != 0 {
 		params["flags"] = strconv.FormatUint(p.Flags, 10)
 	}
+	if p.TTL != "" {
+		params["ttl"] = p.TTL
+	}
 	_, wm, err := k.put(p.Key, params, p.Value, q)
 	return wm, err
 }
@@ -195,10 +276,13 @@ func (k *KV) Put(p *KVPair, q *WriteOptions) (*WriteMeta, error) {
 // ModifyIndex, Flags and Value are respected. Returns true
 // on success or false on failures.
 func (k *KV) CAS(p *KVPair, q *WriteOptions) (bool, *WriteMeta, error) {
-	params := make(map[string]string, 2)
+	params := make(map[string]string, 3)
 	if p.Flags != 0 {
 		params["flags"] = strconv.FormatUint(p.Flags, 10)
 	}
+	if p.TTL != "" {
+		params["ttl"] = p.TTL
+	}
 	params["cas"] = strconv.FormatUint(p.ModifyIndex, 10)
 	return k.put(p.Key, params, p.Value, q)
 }
@@ -207,10 +291,13 @@ func (k *KV) CAS(p *KVPair, q *WriteOptions) (bool, *WriteMeta, error) {
 // Flags, Value and Session are respected. Returns true
 // on success or false on failures.
 func (k *KV) Acquire(p *KVPair, q *WriteOptions) (bool, *WriteMeta, error) {
-	params := make(map[string]string, 2)
+	params := make(map[string]string, 3)
 	if p.Flags != 0 {
 		params["flags"] = strconv.FormatUint(p.Flags, 10)
 	}
+	if p.TTL != "" {
+		params["ttl"] = p.TTL
+	}
 	params["acquire"] = p.Session
 	return k.put(p.Key, params, p.Value, q)
 }
@@ -299,38 +386,6 @@ func (k *KV) deleteInternal(key string, params map[string]string, q *WriteOption
 	return res, qm, nil
 }
 
-// TxnOp is the internal format we send to Consul. It's not specific to KV,
-// though currently only KV operations are supported.
-type TxnOp struct {
-	KV *KVTxnOp
-}
-
-// TxnOps is a list of transaction operations.
-type TxnOps []*TxnOp
-
-// TxnResult is the internal format we receive from Consul.
-type TxnResult struct {
-	KV *KVPair
-}
-
-// TxnResults is a list of TxnResult objects.
-type TxnResults []*TxnResult
-
-// TxnError is used to return information about an operation in a transaction.
-type TxnError struct {
-	OpIndex int
-	What    string
-}
-
-// TxnErrors is a list of TxnError objects.
-type TxnErrors []*TxnError
-
-// TxnResponse is the internal format we receive from Consul.
-type TxnResponse struct {
-	Results TxnResults
-	Errors  TxnErrors
-}
-
 // Txn is used to apply multiple KV operations in a single, atomic transaction.
 //
 // Note that Go will perform the required base64 encoding on the values
@@ -339,9 +394,7 @@ type TxnResponse struct {
 // to define operations. If any operation fails, none of the changes are applied
 // to the state store. Note that this hides the internal raw transaction interface
 // and munges the input and output types into KV-specific ones for ease of use.
-// If there are more non-KV operations in the future we may break out a new
-// transaction API client, but it will be easy to keep this KV-specific variant
-// supported.
+// Use the Txn client to mix KV operations with catalog and session operations.
 //
 // Even though this is generally a write operation, we take a QueryOptions input
 // and return a QueryMeta output. If the transaction contains only read ops, then
@@ -376,44 +429,22 @@ type TxnResponse struct {
 // entries referencing the index of the operation that failed along with an error
 // message.
 func (k *KV) Txn(txn KVTxnOps, q *QueryOptions) (bool, *KVTxnResponse, *QueryMeta, error) {
-	r := k.c.newRequest("PUT", "/v1/txn")
-	r.setQueryOptions(q)
-
 	// Convert into the internal format since this is an all-KV txn.
 	ops := make(TxnOps, 0, len(txn))
 	for _, kvOp := range txn {
 		ops = append(ops, &TxnOp{KV: kvOp})
 	}
-	r.obj = ops
-	rtt, resp, err := k.c.doRequest(r)
+	ok, txnResp, qm, err := k.c.Txn().Txn(ops, q)
 	if err != nil {
 		return false, nil, nil, err
 	}
-	defer resp.Body.Close()
-
-	qm := &QueryMeta{}
-	parseQueryMeta(resp, qm)
-	qm.RequestTime = rtt
 
-	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict {
-		var txnResp TxnResponse
-		if err := decodeBody(resp, &txnResp); err != nil {
-			return false, nil, nil, err
-		}
-
-		// Convert from the internal format.
-		kvResp := KVTxnResponse{
-			Errors: txnResp.Errors,
-		}
-		for _, result := range txnResp.Results {
-			kvResp.Results = append(kvResp.Results, result.KV)
-		}
-		return resp.StatusCode == http.StatusOK, &kvResp, qm, nil
+	// Convert from the internal format.
+	kvResp := KVTxnResponse{
+		Errors: txnResp.Errors,
 	}
-
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, resp.Body); err != nil {
-		return false, nil, nil, fmt.Errorf("Failed to read response: %v", err)
+	for _, result := range txnResp.Results {
+		kvResp.Results = append(kvResp.Results, result.KV)
 	}
-	return false, nil, nil, fmt.Errorf("Failed request: %s", buf.String())
+	return ok, &kvResp, qm, nil
 }
@@ -2,10 +2,14 @@ package api
 
 import (
 	"bytes"
+	"fmt"
 	"path"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/hashicorp/consul/testutil"
 )
 
 func TestClientPutGetDelete(t *testing.T) {
@@ -73,6 +77,99 @@ func TestClientPutGetDelete(t *testing.T) {
 	}
 }
 
+func TestClient_PutTTL(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	kv := c.KV()
+
+	// Put the key with a TTL
+	key := testKey()
+	p := &KVPair{Key: key, Value: []byte("test"), TTL: "100ms"}
+	if _, err := kv.Put(p, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Get should show the TTL
+	pair, _, err := kv.Get(key, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if pair == nil || pair.TTL != "100ms" {
+		t.Fatalf("unexpected value: %#v", pair)
+	}
+
+	// The key should expire
+	testutil.WaitForResult(func() (bool, error) {
+		pair, _, err := kv.Get(key, nil)
+		return pair == nil, err
+	}, func(err error) {
+		t.Fatalf("key should have expired: %v", err)
+	})
+}
+
+func TestClient_History(t *testing.T) {
+	t.Parallel()
+	c, s := makeClientWithConfig(t, nil, func(conf *testutil.TestServerConfig) {
+		conf.KVHistory = []*testutil.TestKVHistoryConfig{
+			&testutil.TestKVHistoryConfig{Prefix: ""},
+		}
+	})
+	defer s.Stop()
+
+	kv := c.KV()
+
+	// Write two versions of the key
+	key := testKey()
+	var indexes []uint64
+	for _, value := range []string{"one", "two"} {
+		if _, err := kv.Put(&KVPair{Key: key, Value: []byte(value)}, nil); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		pair, _, err := kv.Get(key, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		indexes = append(indexes, pair.ModifyIndex)
+	}
+
+	// The first version should be in the history
+	history, meta, err := kv.History(key, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if meta.LastIndex == 0 {
+		t.Fatalf("unexpected value: %#v", meta)
+	}
+	if len(history) != 1 {
+		t.Fatalf("unexpected value: %#v", history)
+	}
+	if v := history[0]; string(v.Value) != "one" || v.ModifyIndex != indexes[0] || v.ReplacedIndex != indexes[1] {
+		t.Fatalf("unexpected value: %#v", v)
+	}
+
+	// Read the key as of each version
+	for i, value := range []string{"one", "two"} {
+		pair, _, err := kv.GetAt(key, indexes[i], nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if pair == nil || string(pair.Value) != value {
+			t.Fatalf("unexpected value: %#v", pair)
+		}
+	}
+
+	// There was nothing before the key was written
+	pair, _, err := kv.GetAt(key, indexes[0]-1, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if pair != nil {
+		t.Fatalf("unexpected value: %#v", pair)
+	}
+}
+
 func TestClient_List_DeleteRecurse(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
@@ -128,6 +225,57 @@ func TestClient_List_DeleteRecurse(t *testing.T) {
 	}
 }
 
+func TestClient_List_Paging(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	kv := c.KV()
+
+	// Write some keys
+	prefix := testKey()
+	var keys []string
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("%s/%d", prefix, i)
+		keys = append(keys, key)
+		if _, err := kv.Put(&KVPair{Key: key, Value: []byte("test")}, nil); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Page through the pairs
+	var got []string
+	q := &QueryOptions{Limit: 2}
+	for {
+		pairs, meta, err := kv.List(prefix, q)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if len(pairs) > 2 {
+			t.Fatalf("unexpected value: %#v", pairs)
+		}
+		for _, pair := range pairs {
+			got = append(got, pair.Key)
+		}
+		if meta.NextAfter == "" {
+			break
+		}
+		q.After = meta.NextAfter
+	}
+	if !reflect.DeepEqual(got, keys) {
+		t.Fatalf("unexpected value: %#v", got)
+	}
+
+	// Get a page of the keys
+	list, meta, err := kv.Keys(prefix, "", &QueryOptions{Limit: 3, After: keys[0]})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(list, keys[1:4]) || meta.NextAfter != keys[3] {
+		t.Fatalf("unexpected value: %#v %#v", list, meta)
+	}
+}
+
 func TestClient_DeleteCAS(t *testing.T) {
 	t.Parallel()
 	c, s := makeClient(t)
@@ -1,5 +1,9 @@
 package api
 
+import (
+	"time"
+)
+
 // Operator can be used to perform low-level operator tasks for Consul.
 type Operator struct {
 	c *Client
@@ -43,6 +47,40 @@ type RaftConfiguration struct {
 	Index uint64
 }
 
+// KVHistoryRule keeps the prior versions of the keys under a prefix.
+type KVHistoryRule struct {
+	// Prefix is the key prefix the rule covers. The rule with the longest
+	// matching prefix applies to a key.
+	Prefix string
+
+	// Versions, if >0, is how many prior versions of each key are kept.
+	Versions int
+
+	// Window, if >0, is how long a version is kept after it's replaced.
+	Window time.Duration
+}
+
+// KVQuotaUsage has the current usage of a KV quota, along with its limits.
+type KVQuotaUsage struct {
+	// Prefix is the key prefix the quota applies to.
+	Prefix string
+
+	// TokenScoped is true if the quota only applies to writes made with
+	// a particular ACL token. The token itself is not reported.
+	TokenScoped bool
+
+	// MaxKeys, MaxBytes and MaxValueSize are the limits of the quota, where
+	// a zero means that dimension is unlimited.
+	MaxKeys      int
+	MaxBytes     int64
+	MaxValueSize int
+
+	// Keys is the number of keys under the prefix, and Bytes is the total
+	// size of their values.
+	Keys  int
+	Bytes int64
+}
+
 // keyringRequest is used for performing Keyring operations
 type keyringRequest struct {
 	Key string
@@ -80,6 +118,66 @@ func (op *Operator) RaftGetConfiguration(q *QueryOptions) (*RaftConfiguration, e
 	return &out, nil
 }
 
+// KVQuotas is used to query the current usage of each configured KV quota.
+func (op *Operator) KVQuotas(q *QueryOptions) ([]*KVQuotaUsage, *QueryMeta, error) {
+	r := op.c.newRequest("GET", "/v1/operator/kv/quotas")
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var out []*KVQuotaUsage
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return out, qm, nil
+}
+
+// KVHistoryRules is used to query the rules that decide which keys have
+// their prior versions kept.
+func (op *Operator) KVHistoryRules(q *QueryOptions) ([]*KVHistoryRule, *QueryMeta, error) {
+	r := op.c.newRequest("GET", "/v1/operator/kv/history")
+	r.setQueryOptions(q)
+	rtt, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	var out []*KVHistoryRule
+	if err := decodeBody(resp, &out); err != nil {
+		return nil, nil, err
+	}
+	return out, qm, nil
+}
+
+// KVHistorySetRules is used to replace the rules that decide which keys have
+// their prior versions kept. Versions that have already been kept are not
+// dropped when the rules change.
+func (op *Operator) KVHistorySetRules(rules []*KVHistoryRule, q *WriteOptions) (*WriteMeta, error) {
+	r := op.c.newRequest("PUT", "/v1/operator/kv/history")
+	r.setWriteOptions(q)
+	r.obj = rules
+	rtt, resp, err := requireOK(op.c.doRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	wm := &WriteMeta{RequestTime: rtt}
+	return wm, nil
+}
+
 // RaftRemovePeerByAddress is used to kick a stale peer (one that it in the Raft
 // quorum but no longer known to Serf or the catalog) by address in the form of
 // "IP:port".
@@ -1,8 +1,10 @@
 package api
 
 import (
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/testutil"
 )
@@ -104,3 +106,87 @@ func TestOperator_KeyringInstallListPutRemove(t *testing.T) {
 		}
 	}
 }
+
+func TestOperator_KVHistoryRules(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	operator := c.Operator()
+	out, _, err := operator.KVHistoryRules(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != 0 {
+		t.Fatalf("bad: %v", out)
+	}
+
+	rules := []*KVHistoryRule{
+		&KVHistoryRule{Prefix: "app/", Versions: 3},
+		&KVHistoryRule{Prefix: "config/", Window: time.Hour},
+	}
+	if _, err := operator.KVHistorySetRules(rules, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, qm, err := operator.KVHistoryRules(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if qm.LastIndex == 0 || !reflect.DeepEqual(out, rules) {
+		t.Fatalf("bad: %d %v", qm.LastIndex, out)
+	}
+
+	// Bad rules are rejected.
+	rules = append(rules, &KVHistoryRule{Prefix: "app/"})
+	if _, err := operator.KVHistorySetRules(rules, nil); err == nil ||
+		!strings.Contains(err.Error(), "more than once") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestOperator_KVQuotas(t *testing.T) {
+	t.Parallel()
+	c, s := makeClientWithConfig(t, nil, func(c *testutil.TestServerConfig) {
+		c.KVQuotas = []*testutil.TestKVQuotaConfig{
+			&testutil.TestKVQuotaConfig{Prefix: "tmp/", MaxKeys: 2, MaxValueSize: 4},
+		}
+	})
+	defer s.Stop()
+
+	kv := c.KV()
+	for _, key := range []string{"tmp/a", "tmp/b", "other"} {
+		if _, err := kv.Put(&KVPair{Key: key, Value: []byte("abc")}, nil); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Writes over the quota are rejected.
+	_, err := kv.Put(&KVPair{Key: "tmp/c", Value: []byte("abc")}, nil)
+	if err == nil || !strings.Contains(err.Error(), "429") ||
+		!strings.Contains(err.Error(), "Quota exceeded") {
+		t.Fatalf("err: %v", err)
+	}
+	_, err = kv.Put(&KVPair{Key: "tmp/a", Value: []byte("abcde")}, nil)
+	if err == nil || !strings.Contains(err.Error(), "Quota exceeded") {
+		t.Fatalf("err: %v", err)
+	}
+
+	operator := c.Operator()
+	out, _, err := operator.KVQuotas(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("bad: %v", out)
+	}
+	expected := &KVQuotaUsage{
+		Prefix:       "tmp/",
+		MaxKeys:      2,
+		MaxValueSize: 4,
+		Keys:         2,
+		Bytes:        6,
+	}
+	if !reflect.DeepEqual(out[0], expected) {
+		t.Fatalf("bad: %#v", out[0])
+	}
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// TxnVerb is the operation performed on a node, service, check or session
+// in a transaction. Sessions only support TxnGet and TxnDelete.
+type TxnVerb string
+
+const (
+	TxnGet       TxnVerb = "get"
+	TxnSet       TxnVerb = "set"
+	TxnCAS       TxnVerb = "cas"
+	TxnDelete    TxnVerb = "delete"
+	TxnDeleteCAS TxnVerb = "delete-cas"
+)
+
+// NodeTxnOp defines a single operation on a node inside a transaction. The
+// ModifyIndex of the node is used for the check-and-set verbs.
+type NodeTxnOp struct {
+	Verb TxnVerb
+	Node Node
+}
+
+// ServiceTxnOp defines a single operation on a service registered on the
+// given node inside a transaction. The ModifyIndex of the service is used
+// for the check-and-set verbs.
+type ServiceTxnOp struct {
+	Verb    TxnVerb
+	Node    string
+	Service AgentService
+}
+
+// CheckTxnOp defines a single operation on a health check inside a
+// transaction. The ModifyIndex of the check is used for the check-and-set
+// verbs.
+type CheckTxnOp struct {
+	Verb  TxnVerb
+	Check HealthCheck
+}
+
+// SessionTxnOp defines a single operation on a session inside a
+// transaction. Only the ID of the session is used.
+type SessionTxnOp struct {
+	Verb    TxnVerb
+	Session SessionEntry
+}
+
+// TxnOp is the internal format we send to Consul. Only one of the types
+// should be filled out per entry.
+type TxnOp struct {
+	KV      *KVTxnOp
+	Node    *NodeTxnOp
+	Service *ServiceTxnOp
+	Check   *CheckTxnOp
+	Session *SessionTxnOp
+}
+
+// TxnOps is a list of transaction operations.
+type TxnOps []*TxnOp
+
+// TxnResult is the internal format we receive from Consul.
+type TxnResult struct {
+	KV      *KVPair
+	Node    *Node
+	Service *AgentService
+	Check   *HealthCheck
+	Session *SessionEntry
+}
+
+// TxnResults is a list of TxnResult objects.
+type TxnResults []*TxnResult
+
+// TxnError is used to return information about an operation in a transaction.
+type TxnError struct {
+	OpIndex int
+	What    string
+}
+
+// Error returns the string representation of the error, including the index
+// of the operation that failed.
+func (e TxnError) Error() string {
+	return fmt.Sprintf("op %d: %s", e.OpIndex, e.What)
+}
+
+// TxnErrors is a list of TxnError objects.
+type TxnErrors []*TxnError
+
+// Error returns all the errors in the list joined into a single string, so
+// the list can be returned as an error when a transaction is rolled back.
+func (e TxnErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// TxnResponse is the internal format we receive from Consul.
+type TxnResponse struct {
+	Results TxnResults
+	Errors  TxnErrors
+}
+
+// Txn is used to manipulate the transaction API
+type Txn struct {
+	c *Client
+}
+
+// Txn is used to return a handle to the transaction API
+func (c *Client) Txn() *Txn {
+	return &Txn{c}
+}
+
+// Txn is used to apply multiple operations in a single, atomic transaction.
+// Unlike KV.Txn, the operations can mix KV, node, service, check and session
+// operations, and the results are returned in the same internal format, with
+// only the member matching each operation filled out. The helper functions
+// below, such as TxnKVSet and TxnServiceDelete, build the operations.
+//
+// As with KV.Txn, we take a QueryOptions input and return a QueryMeta output.
+// A transaction with only read operations supports consistency controls but
+// not blocking, and a transaction with any write operations is always routed
+// through raft.
+//
+// Here's an example:
+//
+// ops := TxnOps{
+//     TxnKVCheckIndex("config/version", 42),
+//     TxnKVSet(&KVPair{Key: "config/version", Value: []byte("43")}),
+//     TxnServiceDelete("node1", "web-v1"),
+// }
+// ok, response, _, err := txn.Txn(ops, nil)
+//
+// If there is a problem making the transaction request then an error will be
+// returned. Otherwise, the ok value will be true if the transaction succeeded
+// or false if it was rolled back, in which case the Errors member of the
+// response will have entries referencing the index of each operation that
+// failed.
+func (t *Txn) Txn(txn TxnOps, q *QueryOptions) (bool, *TxnResponse, *QueryMeta, error) {
+	r := t.c.newRequest("PUT", "/v1/txn")
+	r.setQueryOptions(q)
+	r.obj = txn
+	rtt, resp, err := t.c.doRequest(r)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	qm := &QueryMeta{}
+	parseQueryMeta(resp, qm)
+	qm.RequestTime = rtt
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict {
+		var txnResp TxnResponse
+		if err := decodeBody(resp, &txnResp); err != nil {
+			return false, nil, nil, err
+		}
+		return resp.StatusCode == http.StatusOK, &txnResp, qm, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, resp.Body); err != nil {
+		return false, nil, nil, fmt.Errorf("Failed to read response: %v", err)
+	}
+	return false, nil, nil, fmt.Errorf("Failed request: %s", buf.String())
+}
+
+// TxnKVSet returns an operation that sets the key to the pair's value and
+// flags.
+func TxnKVSet(p *KVPair) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVSet, Key: p.Key, Value: p.Value, Flags: p.Flags, TTL: p.TTL}}
+}
+
+// TxnKVCAS returns an operation that sets the key only if its modify index
+// still matches the pair's ModifyIndex. An index of zero means the key must
+// not exist yet.
+func TxnKVCAS(p *KVPair) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVCAS, Key: p.Key, Value: p.Value, Flags: p.Flags, Index: p.ModifyIndex, TTL: p.TTL}}
+}
+
+// TxnKVLock returns an operation that sets the key and acquires it with the
+// pair's Session.
+func TxnKVLock(p *KVPair) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVLock, Key: p.Key, Value: p.Value, Flags: p.Flags, Session: p.Session, TTL: p.TTL}}
+}
+
+// TxnKVUnlock returns an operation that sets the key and releases the lock
+// held on it by the pair's Session.
+func TxnKVUnlock(p *KVPair) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVUnlock, Key: p.Key, Value: p.Value, Flags: p.Flags, Session: p.Session, TTL: p.TTL}}
+}
+
+// TxnKVGet returns an operation that reads the key. The transaction fails if
+// the key doesn't exist.
+func TxnKVGet(key string) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVGet, Key: key}}
+}
+
+// TxnKVGetTree returns an operation that reads all the keys with the given
+// prefix.
+func TxnKVGetTree(prefix string) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVGetTree, Key: prefix}}
+}
+
+// TxnKVCheckIndex returns an operation that fails the transaction unless the
+// key's modify index matches the given index.
+func TxnKVCheckIndex(key string, index uint64) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVCheckIndex, Key: key, Index: index}}
+}
+
+// TxnKVCheckSession returns an operation that fails the transaction unless
+// the key is locked by the given session.
+func TxnKVCheckSession(key, session string) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVCheckSession, Key: key, Session: session}}
+}
+
+// TxnKVDelete returns an operation that deletes the key.
+func TxnKVDelete(key string) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVDelete, Key: key}}
+}
+
+// TxnKVDeleteCAS returns an operation that deletes the key only if its
+// modify index still matches the given index.
+func TxnKVDeleteCAS(key string, index uint64) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVDeleteCAS, Key: key, Index: index}}
+}
+
+// TxnKVDeleteTree returns an operation that deletes all the keys with the
+// given prefix.
+func TxnKVDeleteTree(prefix string) *TxnOp {
+	return &TxnOp{KV: &KVTxnOp{Verb: KVDeleteTree, Key: prefix}}
+}
+
+// TxnNodeGet returns an operation that reads the node. The transaction fails
+// if the node doesn't exist.
+func TxnNodeGet(node string) *TxnOp {
+	return &TxnOp{Node: &NodeTxnOp{Verb: TxnGet, Node: Node{Node: node}}}
+}
+
+// TxnNodeSet returns an operation that registers or updates the node.
+func TxnNodeSet(n *Node) *TxnOp {
+	return &TxnOp{Node: &NodeTxnOp{Verb: TxnSet, Node: *n}}
+}
+
+// TxnNodeCAS returns an operation that registers or updates the node only if
+// its modify index still matches the node's ModifyIndex.
+func TxnNodeCAS(n *Node) *TxnOp {
+	return &TxnOp{Node: &NodeTxnOp{Verb: TxnCAS, Node: *n}}
+}
+
+// TxnNodeDelete returns an operation that deregisters the node along with
+// its services and checks.
+func TxnNodeDelete(node string) *TxnOp {
+	return &TxnOp{Node: &NodeTxnOp{Verb: TxnDelete, Node: Node{Node: node}}}
+}
+
+// TxnNodeDeleteCAS returns an operation that deregisters the node only if
+// its modify index still matches the given index.
+func TxnNodeDeleteCAS(node string, index uint64) *TxnOp {
+	return &TxnOp{Node: &NodeTxnOp{Verb: TxnDeleteCAS, Node: Node{Node: node, ModifyIndex: index}}}
+}
+
+// TxnServiceGet returns an operation that reads the service registered on
+// the node. The transaction fails if the service doesn't exist.
+func TxnServiceGet(node, serviceID string) *TxnOp {
+	return &TxnOp{Service: &ServiceTxnOp{Verb: TxnGet, Node: node, Service: AgentService{ID: serviceID}}}
+}
+
+// TxnServiceSet returns an operation that registers or updates the service
+// on the node.
+func TxnServiceSet(node string, s *AgentService) *TxnOp {
+	return &TxnOp{Service: &ServiceTxnOp{Verb: TxnSet, Node: node, Service: *s}}
+}
+
+// TxnServiceCAS returns an operation that registers or updates the service
+// on the node only if its modify index still matches the service's
+// ModifyIndex.
+func TxnServiceCAS(node string, s *AgentService) *TxnOp {
+	return &TxnOp{Service: &ServiceTxnOp{Verb: TxnCAS, Node: node, Service: *s}}
+}
+
+// TxnServiceDelete returns an operation that deregisters the service from
+// the node.
+func TxnServiceDelete(node, serviceID string) *TxnOp {
+	return &TxnOp{Service: &ServiceTxnOp{Verb: TxnDelete, Node: node, Service: AgentService{ID: serviceID}}}
+}
+
+// TxnServiceDeleteCAS returns an operation that deregisters the service from
+// the node only if its modify index still matches the given index.
+func TxnServiceDeleteCAS(node, serviceID string, index uint64) *TxnOp {
+	return &TxnOp{Service: &ServiceTxnOp{Verb: TxnDeleteCAS, Node: node, Service: AgentService{ID: serviceID, ModifyIndex: index}}}
+}
+
+// TxnCheckGet returns an operation that reads the health check registered on
+// the node. The transaction fails if the check doesn't exist.
+func TxnCheckGet(node, checkID string) *TxnOp {
+	return &TxnOp{Check: &CheckTxnOp{Verb: TxnGet, Check: HealthCheck{Node: node, CheckID: checkID}}}
+}
+
+// TxnCheckSet returns an operation that registers or updates the health
+// check.
+func TxnCheckSet(c *HealthCheck) *TxnOp {
+	return &TxnOp{Check: &CheckTxnOp{Verb: TxnSet, Check: *c}}
+}
+
+// TxnCheckCAS returns an operation that registers or updates the health
+// check only if its modify index still matches the check's ModifyIndex.
+func TxnCheckCAS(c *HealthCheck) *TxnOp {
+	return &TxnOp{Check: &CheckTxnOp{Verb: TxnCAS, Check: *c}}
+}
+
+// TxnCheckDelete returns an operation that deregisters the health check from
+// the node.
+func TxnCheckDelete(node, checkID string) *TxnOp {
+	return &TxnOp{Check: &CheckTxnOp{Verb: TxnDelete, Check: HealthCheck{Node: node, CheckID: checkID}}}
+}
+
+// TxnCheckDeleteCAS returns an operation that deregisters the health check
+// from the node only if its modify index still matches the given index.
+func TxnCheckDeleteCAS(node, checkID string, index uint64) *TxnOp {
+	return &TxnOp{Check: &CheckTxnOp{Verb: TxnDeleteCAS, Check: HealthCheck{Node: node, CheckID: checkID, ModifyIndex: index}}}
+}
+
+// TxnSessionGet returns an operation that reads the session. The transaction
+// fails if the session doesn't exist.
+func TxnSessionGet(id string) *TxnOp {
+	return &TxnOp{Session: &SessionTxnOp{Verb: TxnGet, Session: SessionEntry{ID: id}}}
+}
+
+// TxnSessionDelete returns an operation that destroys the session, releasing
+// or deleting any keys it holds.
+func TxnSessionDelete(id string) *TxnOp {
+	return &TxnOp{Session: &SessionTxnOp{Verb: TxnDelete, Session: SessionEntry{ID: id}}}
+}
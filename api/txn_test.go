@@ -0,0 +1,228 @@
+package api
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTxnErrors_Error(t *testing.T) {
+	t.Parallel()
+	errs := TxnErrors{
+		&TxnError{OpIndex: 0, What: "missing session"},
+		&TxnError{OpIndex: 2, What: `key "foo" doesn't exist`},
+	}
+	expected := `op 0: missing session; op 2: key "foo" doesn't exist`
+	if errs.Error() != expected {
+		t.Fatalf("bad: %s", errs.Error())
+	}
+}
+
+func TestTxn_KV(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	session := c.Session()
+	txn := c.Txn()
+
+	// Make a session.
+	id, _, err := session.CreateNoChecks(nil, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer session.Destroy(id, nil)
+
+	// Create one key, lock another and read back the tree.
+	ops := TxnOps{
+		TxnKVCAS(&KVPair{Key: "txn/a", Value: []byte("a"), Flags: 1, TTL: "10m"}),
+		TxnKVLock(&KVPair{Key: "txn/b", Value: []byte("b"), Session: id}),
+		TxnKVCheckSession("txn/b", id),
+		TxnKVGetTree("txn/"),
+	}
+	ok, ret, _, err := txn.Txn(ops, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	} else if !ok {
+		t.Fatalf("transaction failure: %v", ret.Errors)
+	}
+	if len(ret.Errors) != 0 || len(ret.Results) != 5 {
+		t.Fatalf("bad: %v", ret)
+	}
+	if ret.Results[0].KV.Key != "txn/a" || ret.Results[0].KV.Flags != 1 ||
+		ret.Results[0].KV.TTL != "10m" {
+		t.Fatalf("bad: %v", ret.Results[0].KV)
+	}
+	if ret.Results[1].KV.Session != id || ret.Results[1].KV.LockIndex != 1 {
+		t.Fatalf("bad: %v", ret.Results[1].KV)
+	}
+	if ret.Results[3].KV.Key != "txn/a" || !bytes.Equal(ret.Results[3].KV.Value, []byte("a")) ||
+		ret.Results[4].KV.Key != "txn/b" || !bytes.Equal(ret.Results[4].KV.Value, []byte("b")) {
+		t.Fatalf("bad: %v %v", ret.Results[3].KV, ret.Results[4].KV)
+	}
+	index := ret.Results[0].KV.ModifyIndex
+
+	// A stale index rolls back the whole transaction and the errors come
+	// back typed.
+	ops = TxnOps{
+		TxnKVSet(&KVPair{Key: "txn/c", Value: []byte("c")}),
+		TxnKVCheckIndex("txn/a", index+1),
+	}
+	ok, ret, _, err = txn.Txn(ops, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	} else if ok {
+		t.Fatalf("transaction should have failed")
+	}
+	if len(ret.Errors) != 1 || ret.Errors[0].OpIndex != 1 ||
+		!strings.Contains(ret.Errors[0].What, "index") ||
+		!strings.HasPrefix(ret.Errors.Error(), "op 1: ") {
+		t.Fatalf("bad: %v", ret.Errors)
+	}
+	pair, _, err := c.KV().Get("txn/c", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if pair != nil {
+		t.Fatalf("should not be set: %v", pair)
+	}
+
+	// Unlock, then clean up with the delete verbs.
+	ops = TxnOps{
+		TxnKVCheckIndex("txn/a", index),
+		TxnKVUnlock(&KVPair{Key: "txn/b", Value: []byte("b"), Session: id}),
+		TxnKVDeleteCAS("txn/a", index),
+		TxnKVDelete("txn/b"),
+		TxnKVSet(&KVPair{Key: "txn/sub/c", Value: []byte("c")}),
+		TxnKVDeleteTree("txn/sub/"),
+	}
+	ok, ret, _, err = txn.Txn(ops, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	} else if !ok {
+		t.Fatalf("transaction failure: %v", ret.Errors)
+	}
+	if ret.Results[1].KV.Session != "" {
+		t.Fatalf("bad: %v", ret.Results[1].KV)
+	}
+	pairs, _, err := c.KV().List("txn/", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(pairs) != 0 {
+		t.Fatalf("bad: %v", pairs)
+	}
+
+	// A missing key fails a read-only transaction.
+	ok, ret, _, err = txn.Txn(TxnOps{TxnKVGet("txn/a")}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	} else if ok {
+		t.Fatalf("transaction should have failed")
+	}
+	if len(ret.Errors) != 1 || !strings.Contains(ret.Errors[0].What, "doesn't exist") {
+		t.Fatalf("bad: %v", ret.Errors)
+	}
+}
+
+func TestTxn_Catalog(t *testing.T) {
+	t.Parallel()
+	c, s := makeClient(t)
+	defer s.Stop()
+
+	txn := c.Txn()
+
+	// Register a node with a service and a check in one go.
+	ops := TxnOps{
+		TxnNodeSet(&Node{Node: "txn-node", Address: "10.0.0.2"}),
+		TxnServiceSet("txn-node", &AgentService{ID: "web", Service: "web", Port: 80}),
+		TxnCheckSet(&HealthCheck{Node: "txn-node", CheckID: "web-alive", Name: "web-alive", Status: HealthPassing, ServiceID: "web"}),
+	}
+	ok, ret, _, err := txn.Txn(ops, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	} else if !ok {
+		t.Fatalf("transaction failure: %v", ret.Errors)
+	}
+	if len(ret.Results) != 3 {
+		t.Fatalf("bad: %v", ret)
+	}
+	if ret.Results[0].Node == nil || ret.Results[0].Node.Address != "10.0.0.2" {
+		t.Fatalf("bad: %v", ret.Results[0])
+	}
+	if ret.Results[1].Service == nil || ret.Results[1].Service.Port != 80 {
+		t.Fatalf("bad: %v", ret.Results[1])
+	}
+	if ret.Results[2].Check == nil || ret.Results[2].Check.ServiceName != "web" {
+		t.Fatalf("bad: %v", ret.Results[2])
+	}
+	nodeIndex := ret.Results[0].Node.ModifyIndex
+	svcIndex := ret.Results[1].Service.ModifyIndex
+
+	// Read everything back, using a CAS update on the service.
+	ops = TxnOps{
+		TxnNodeGet("txn-node"),
+		TxnServiceCAS("txn-node", &AgentService{ID: "web", Service: "web", Port: 8080, ModifyIndex: svcIndex}),
+		TxnCheckGet("txn-node", "web-alive"),
+	}
+	ok, ret, _, err = txn.Txn(ops, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	} else if !ok {
+		t.Fatalf("transaction failure: %v", ret.Errors)
+	}
+	if ret.Results[0].Node.ModifyIndex != nodeIndex ||
+		ret.Results[1].Service.Port != 8080 ||
+		ret.Results[2].Check.Status != HealthPassing {
+		t.Fatalf("bad: %v", ret.Results)
+	}
+
+	// A stale service index is rejected.
+	ok, ret, _, err = txn.Txn(TxnOps{TxnServiceDeleteCAS("txn-node", "web", svcIndex)}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	} else if ok {
+		t.Fatalf("transaction should have failed")
+	}
+	if len(ret.Errors) != 1 || ret.Errors[0].OpIndex != 0 {
+		t.Fatalf("bad: %v", ret.Errors)
+	}
+
+	// Create a session on the new node, then tear everything down.
+	id, _, err := c.Session().CreateNoChecks(&SessionEntry{Node: "txn-node"}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ops = TxnOps{
+		TxnSessionGet(id),
+		TxnSessionDelete(id),
+		TxnCheckDelete("txn-node", "web-alive"),
+		TxnServiceDelete("txn-node", "web"),
+		TxnNodeDeleteCAS("txn-node", nodeIndex),
+	}
+	ok, ret, _, err = txn.Txn(ops, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	} else if !ok {
+		t.Fatalf("transaction failure: %v", ret.Errors)
+	}
+	if ret.Results[0].Session == nil || ret.Results[0].Session.Node != "txn-node" {
+		t.Fatalf("bad: %v", ret.Results[0])
+	}
+
+	// Sanity check using the regular catalog and session APIs.
+	node, _, err := c.Catalog().Node("txn-node", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if node != nil {
+		t.Fatalf("should be deleted: %v", node)
+	}
+	entry, _, err := c.Session().Info(id, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry != nil {
+		t.Fatalf("should be deleted: %v", entry)
+	}
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// ACLCloneCommand is a Command implementation that is used to create a new
+// ACL token from an existing one.
+type ACLCloneCommand struct {
+	Ui cli.Ui
+}
+
+func (c *ACLCloneCommand) Help() string {
+	helpText := `
+Usage: consul acl clone [options] ID
+
+  Creates a new ACL token with the same name, type, rules, and policies as the
+  token with the given ID, and outputs the new token's ID. This lets a token
+  serve as a template for others. A management token is required.
+
+      $ consul acl clone 8f246b77-f3e1-ff88-5b48-8ec93abf3e05
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ACLCloneCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("clone", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	id, err := aclIDFromArgs(cmdFlags.Args())
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	cloned, _, err := client.ACL().Clone(id, &api.WriteOptions{
+		Datacenter: *datacenter,
+		Token:      *token,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! Failed to clone token: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(cloned)
+	return 0
+}
+
+func (c *ACLCloneCommand) Synopsis() string {
+	return "Creates a new ACL token from an existing one"
+}
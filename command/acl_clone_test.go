@@ -0,0 +1,54 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+func TestACLCloneCommand_implements(t *testing.T) {
+	var _ cli.Command = &ACLCloneCommand{}
+}
+
+func TestACLCloneCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(ACLCloneCommand))
+}
+
+func TestACLCloneCommand_Run(t *testing.T) {
+	srv, client := testACLAgent(t)
+	defer srv.Shutdown()
+
+	id, _, err := client.ACL().Create(&api.ACLEntry{
+		Name:  "web",
+		Type:  api.ACLClientType,
+		Rules: `key "foo/" { policy = "write" }`,
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &ACLCloneCommand{Ui: ui}
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		id,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	cloned := strings.TrimSpace(ui.OutputWriter.String())
+	if cloned == "" || cloned == id {
+		t.Fatalf("bad: %q", cloned)
+	}
+
+	entry, _, err := client.ACL().Info(cloned, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry == nil || entry.Name != "web" || entry.Rules != `key "foo/" { policy = "write" }` {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
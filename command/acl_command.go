@@ -0,0 +1,275 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/command/agent"
+	"github.com/hashicorp/consul/consul/audit"
+	"github.com/mitchellh/cli"
+)
+
+// ACLCommand is a Command implementation that just shows help for
+// the subcommands nested below it.
+type ACLCommand struct {
+	Ui cli.Ui
+}
+
+func (c *ACLCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+func (c *ACLCommand) Help() string {
+	helpText := `
+Usage: consul acl <subcommand> [options] [args]
+
+  This command has subcommands for managing Consul's ACL tokens. Most of them
+  require a management token, which can be given with the -token flag or the
+  CONSUL_HTTP_TOKEN environment variable. Here are some simple examples, and
+  more detailed examples are available in the subcommands or the
+  documentation.
+
+  Create a token that can write the "web" service, with the rules read from a
+  file:
+
+      $ consul acl create -name=web -rules=@web.hcl
+
+  List the tokens:
+
+      $ consul acl list
+
+  Show the details of a token:
+
+      $ consul acl info 8f246b77-f3e1-ff88-5b48-8ec93abf3e05
+
+  Finally, delete the token:
+
+      $ consul acl delete 8f246b77-f3e1-ff88-5b48-8ec93abf3e05
+
+  For more examples, ask for subcommand help or view the documentation.
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ACLCommand) Synopsis() string {
+	return "Interact with ACL tokens"
+}
+
+// aclTokenOptsText is the help text for the options shared by the commands
+// that write tokens.
+var aclTokenOptsText = strings.TrimSpace(`
+  -name=<string>          Name of the token, to help operators tell what it's
+                          used for.
+
+  -type=<string>          Type of the token, either "client" or "management".
+
+  -rules=<string>         Rules of the token. The rules can be read from a file
+                          by prefixing the path with "@", or from stdin by
+                          giving "-".
+
+  -policy=<id>            ID of an ACL policy to link to the token. This can
+                          be specified multiple times to link several policies.
+
+  -ttl=<duration>         How long the token lives before it expires, such as
+                          "30m" or "24h". This can't be used with -expiration.
+
+  -expiration=<time>      Time the token expires, in RFC 3339 format such as
+                          "2017-06-01T18:30:00Z". This can't be used with -ttl.
+
+  -validate               Parse the rules locally and fail without making any
+                          changes if they aren't valid. The default value is
+                          false.
+`)
+
+// aclFormatOptsText is the help text for the -format option of the commands
+// that show ACL information.
+var aclFormatOptsText = strings.TrimSpace(`
+  -format=<string>        Output format, either "table" or "json". The default
+                          value is "table".
+`)
+
+// aclTokenFlags holds the flags shared by the commands that write tokens.
+type aclTokenFlags struct {
+	name       *string
+	tokenType  *string
+	rules      *string
+	policies   agent.AppendSliceValue
+	ttl        *string
+	expiration *string
+	validate   *bool
+}
+
+// newACLTokenFlags registers the shared token flags with the given flag set.
+func newACLTokenFlags(f *flag.FlagSet) *aclTokenFlags {
+	t := &aclTokenFlags{
+		name:       f.String("name", "", ""),
+		tokenType:  f.String("type", api.ACLClientType, ""),
+		rules:      f.String("rules", "", ""),
+		ttl:        f.String("ttl", "", ""),
+		expiration: f.String("expiration", "", ""),
+		validate:   f.Bool("validate", false, ""),
+	}
+	f.Var(&t.policies, "policy", "")
+	return t
+}
+
+// apply sets the fields of the token from the flags. If set is non-nil,
+// only the flags named in it are applied, so an existing token can be
+// updated with just the flags that were given.
+func (t *aclTokenFlags) apply(entry *api.ACLEntry, set map[string]bool, stdin io.Reader) error {
+	given := func(name string) bool {
+		return set == nil || set[name]
+	}
+
+	if given("name") {
+		entry.Name = *t.name
+	}
+	if given("type") {
+		switch *t.tokenType {
+		case api.ACLClientType, api.ACLManagementType:
+			entry.Type = *t.tokenType
+		default:
+			return fmt.Errorf("Invalid -type %q, must be %q or %q",
+				*t.tokenType, api.ACLClientType, api.ACLManagementType)
+		}
+	}
+	if given("rules") {
+		rules, err := aclRulesFromArg(*t.rules, stdin)
+		if err != nil {
+			return err
+		}
+		entry.Rules = rules
+	}
+	if given("policy") {
+		entry.Policies = []string(t.policies)
+	}
+
+	if *t.ttl != "" && *t.expiration != "" {
+		return fmt.Errorf("Only one of -ttl and -expiration can be given")
+	}
+	if *t.ttl != "" {
+		ttl, err := time.ParseDuration(*t.ttl)
+		if err != nil {
+			return fmt.Errorf("Invalid -ttl: %s", err)
+		}
+		entry.ExpirationTime = nil
+		entry.ExpirationTTL = ttl
+	}
+	if *t.expiration != "" {
+		expires, err := time.Parse(time.RFC3339, *t.expiration)
+		if err != nil {
+			return fmt.Errorf("Invalid -expiration: %s", err)
+		}
+		entry.ExpirationTime = &expires
+		entry.ExpirationTTL = 0
+	}
+
+	if *t.validate {
+		if _, err := acl.Parse(entry.Rules); err != nil {
+			return fmt.Errorf("Invalid rules: %s", err)
+		}
+	}
+	return nil
+}
+
+// aclRulesFromArg returns the ACL rules given by a command line argument.
+// The rules can be given inline, read from a file by prefixing the path with
+// "@", or read from stdin with "-".
+func aclRulesFromArg(arg string, stdin io.Reader) (string, error) {
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	switch {
+	case strings.HasPrefix(arg, "@"):
+		data, err := ioutil.ReadFile(arg[1:])
+		if err != nil {
+			return "", fmt.Errorf("Failed to read file: %s", err)
+		}
+		return string(data), nil
+	case arg == "-":
+		var b bytes.Buffer
+		if _, err := io.Copy(&b, stdin); err != nil {
+			return "", fmt.Errorf("Failed to read stdin: %s", err)
+		}
+		return b.String(), nil
+	default:
+		return arg, nil
+	}
+}
+
+// aclIDFromArgs returns the single token ID argument of a command.
+func aclIDFromArgs(args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		return "", fmt.Errorf("Missing ID argument")
+	case 1:
+		return args[0], nil
+	default:
+		return "", fmt.Errorf("Too many arguments (expected 1, got %d)", len(args))
+	}
+}
+
+// validACLFormat checks the value of a -format flag.
+func validACLFormat(format string) error {
+	switch format {
+	case "table", "json":
+		return nil
+	default:
+		return fmt.Errorf("Invalid -format %q, must be \"table\" or \"json\"", format)
+	}
+}
+
+// aclJSON renders the given value as indented JSON.
+func aclJSON(v interface{}) (string, error) {
+	marshaled, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(marshaled), nil
+}
+
+// prettyACLEntry writes the details of a token as a table, followed by its
+// rules.
+func prettyACLEntry(w io.Writer, entry *api.ACLEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 2, 6, ' ', 0)
+	fmt.Fprintf(tw, "ID\t%s\n", entry.ID)
+	fmt.Fprintf(tw, "Accessor\t%s\n", audit.Accessor(entry.ID))
+	fmt.Fprintf(tw, "Name\t%s\n", entry.Name)
+	fmt.Fprintf(tw, "Type\t%s\n", entry.Type)
+	if len(entry.Policies) > 0 {
+		fmt.Fprintf(tw, "Policies\t%s\n", strings.Join(entry.Policies, ", "))
+	} else {
+		fmt.Fprintf(tw, "Policies\t%s\n", "-")
+	}
+	fmt.Fprintf(tw, "Expires\t%s\n", aclExpirationString(entry))
+	fmt.Fprintf(tw, "CreateIndex\t%d\n", entry.CreateIndex)
+	fmt.Fprintf(tw, "ModifyIndex\t%d\n", entry.ModifyIndex)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if rules := strings.TrimSpace(entry.Rules); rules != "" {
+		fmt.Fprintf(w, "\nRules:\n%s\n", rules)
+	}
+	return nil
+}
+
+// aclExpirationString returns when the token expires, for display.
+func aclExpirationString(entry *api.ACLEntry) string {
+	if entry.ExpirationTime == nil {
+		return "-"
+	}
+	return entry.ExpirationTime.UTC().Format(time.RFC3339)
+}
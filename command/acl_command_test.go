@@ -0,0 +1,79 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/command/agent"
+	"github.com/mitchellh/cli"
+)
+
+// testACLAgent returns an agent with ACLs enabled and an API client that
+// uses the master token, once the agent has a leader.
+func testACLAgent(t *testing.T) (*agentWrapper, *api.Client) {
+	srv := testAgentWithConfig(t, func(c *agent.Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+	})
+	client, err := api.NewClient(&api.Config{Address: srv.httpAddr, Token: "root"})
+	if err != nil {
+		t.Fatalf("consul client: %#v", err)
+	}
+	waitForLeader(t, srv.httpAddr)
+	return srv, client
+}
+
+func TestACLCommand_implements(t *testing.T) {
+	var _ cli.Command = &ACLCommand{}
+}
+
+func TestACLCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(ACLCommand))
+}
+
+func TestACLRulesFromArg(t *testing.T) {
+	// Inline rules are used as is.
+	rules, err := aclRulesFromArg(`key "" { policy = "read" }`, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rules != `key "" { policy = "read" }` {
+		t.Fatalf("bad: %q", rules)
+	}
+
+	// Rules can be read from a file.
+	f, err := ioutil.TempFile("", "acl-rules")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(`key "foo/" { policy = "write" }`); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f.Close()
+	rules, err = aclRulesFromArg("@"+f.Name(), nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rules != `key "foo/" { policy = "write" }` {
+		t.Fatalf("bad: %q", rules)
+	}
+
+	// Or from stdin.
+	rules, err = aclRulesFromArg("-", strings.NewReader(`keyring = "read"`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rules != `keyring = "read"` {
+		t.Fatalf("bad: %q", rules)
+	}
+
+	// A missing file is an error.
+	if _, err := aclRulesFromArg("@"+f.Name()+"-nope", nil); err == nil ||
+		!strings.Contains(err.Error(), "Failed to read file") {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// ACLCreateCommand is a Command implementation that is used to create a new
+// ACL token.
+type ACLCreateCommand struct {
+	Ui cli.Ui
+
+	// testStdin is the input for testing.
+	testStdin io.Reader
+}
+
+func (c *ACLCreateCommand) Help() string {
+	helpText := `
+Usage: consul acl create [options]
+
+  Creates a new ACL token and outputs its ID. A management token is required.
+
+  To create a client token with the rules read from a file:
+
+      $ consul acl create -name=web -rules=@web.hcl
+
+  Or read from stdin using the "-" symbol:
+
+      $ cat web.hcl | consul acl create -name=web -rules=-
+
+  To make sure the rules are valid before the token is created, add the
+  -validate flag. Tokens that only need to live for a while can be given a TTL:
+
+      $ consul acl create -name=deploy -rules=@deploy.hcl -ttl=1h
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText + `
+
+ACL Create Options:
+
+  -id=<string>            ID of the new token. If omitted, a random UUID will be
+                          generated.
+
+` + aclTokenOptsText + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ACLCreateCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("create", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	id := cmdFlags.String("id", "", "")
+	tokenFlags := newACLTokenFlags(cmdFlags)
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	if args = cmdFlags.Args(); len(args) > 0 {
+		c.Ui.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+
+	entry := &api.ACLEntry{ID: *id}
+	if err := tokenFlags.apply(entry, nil, c.testStdin); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	created, _, err := client.ACL().Create(entry, &api.WriteOptions{
+		Datacenter: *datacenter,
+		Token:      *token,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! Failed to create token: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(created)
+	return 0
+}
+
+func (c *ACLCreateCommand) Synopsis() string {
+	return "Creates a new ACL token"
+}
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestACLCreateCommand_implements(t *testing.T) {
+	var _ cli.Command = &ACLCreateCommand{}
+}
+
+func TestACLCreateCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(ACLCreateCommand))
+}
+
+func TestACLCreateCommand_Validation(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &ACLCreateCommand{Ui: ui}
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"bad type": {
+			[]string{"-type", "nope"},
+			"Invalid -type",
+		},
+		"-ttl and -expiration": {
+			[]string{"-ttl", "1h", "-expiration", "2017-06-01T18:30:00Z"},
+			"Only one of -ttl and -expiration",
+		},
+		"-ttl invalid": {
+			[]string{"-ttl", "nope"},
+			"Invalid -ttl",
+		},
+		"-expiration invalid": {
+			[]string{"-expiration", "nope"},
+			"Invalid -expiration",
+		},
+		"-validate bad rules": {
+			[]string{"-validate", "-rules", `key "" { policy = "nope" }`},
+			"Invalid rules",
+		},
+		"extra args": {
+			[]string{"foo"},
+			"Too many arguments",
+		},
+	}
+
+	for name, tc := range cases {
+		// Ensure our buffer is always clear
+		if ui.ErrorWriter != nil {
+			ui.ErrorWriter.Reset()
+		}
+		if ui.OutputWriter != nil {
+			ui.OutputWriter.Reset()
+		}
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestACLCreateCommand_Run(t *testing.T) {
+	srv, client := testACLAgent(t)
+	defer srv.Shutdown()
+
+	ui := new(cli.MockUi)
+	c := &ACLCreateCommand{
+		Ui:        ui,
+		testStdin: strings.NewReader(`key "foo/" { policy = "write" }`),
+	}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		"-name=web",
+		"-rules=-",
+		"-ttl=1h",
+		"-validate",
+	}
+
+	start := time.Now()
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	id := strings.TrimSpace(ui.OutputWriter.String())
+
+	entry, _, err := client.ACL().Info(id, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry == nil || entry.Name != "web" || entry.Type != "client" ||
+		entry.Rules != `key "foo/" { policy = "write" }` {
+		t.Fatalf("bad: %#v", entry)
+	}
+	if entry.ExpirationTime == nil || entry.ExpirationTime.Before(start.Add(time.Hour)) {
+		t.Fatalf("bad: %v", entry.ExpirationTime)
+	}
+}
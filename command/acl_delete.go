@@ -0,0 +1,74 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// ACLDeleteCommand is a Command implementation that is used to delete an ACL
+// token.
+type ACLDeleteCommand struct {
+	Ui cli.Ui
+}
+
+func (c *ACLDeleteCommand) Help() string {
+	helpText := `
+Usage: consul acl delete [options] ID
+
+  Deletes the ACL token with the given ID. A management token is required.
+  Deleting a token that doesn't exist is not an error.
+
+      $ consul acl delete 8f246b77-f3e1-ff88-5b48-8ec93abf3e05
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ACLDeleteCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	id, err := aclIDFromArgs(cmdFlags.Args())
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	if _, err := client.ACL().Destroy(id, &api.WriteOptions{
+		Datacenter: *datacenter,
+		Token:      *token,
+	}); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! Failed to delete token: %s", err))
+		return 1
+	}
+
+	c.Ui.Info(fmt.Sprintf("Success! Deleted token: %s", id))
+	return 0
+}
+
+func (c *ACLDeleteCommand) Synopsis() string {
+	return "Deletes an ACL token"
+}
@@ -0,0 +1,88 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+func TestACLDeleteCommand_implements(t *testing.T) {
+	var _ cli.Command = &ACLDeleteCommand{}
+}
+
+func TestACLDeleteCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(ACLDeleteCommand))
+}
+
+func TestACLDeleteCommand_Validation(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &ACLDeleteCommand{Ui: ui}
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"no id": {
+			[]string{},
+			"Missing ID argument",
+		},
+		"extra args": {
+			[]string{"foo", "bar"},
+			"Too many arguments",
+		},
+	}
+
+	for name, tc := range cases {
+		// Ensure our buffer is always clear
+		if ui.ErrorWriter != nil {
+			ui.ErrorWriter.Reset()
+		}
+		if ui.OutputWriter != nil {
+			ui.OutputWriter.Reset()
+		}
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestACLDeleteCommand_Run(t *testing.T) {
+	srv, client := testACLAgent(t)
+	defer srv.Shutdown()
+
+	id, _, err := client.ACL().Create(&api.ACLEntry{Name: "web", Type: api.ACLClientType}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &ACLDeleteCommand{Ui: ui}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		id,
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	entry, _, err := client.ACL().Info(id, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry != nil {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
@@ -0,0 +1,109 @@
+package command
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// ACLInfoCommand is a Command implementation that is used to show the
+// details of an ACL token.
+type ACLInfoCommand struct {
+	Ui cli.Ui
+}
+
+func (c *ACLInfoCommand) Help() string {
+	helpText := `
+Usage: consul acl info [options] ID
+
+  Shows the details of the ACL token with the given ID, including its rules.
+
+      $ consul acl info 8f246b77-f3e1-ff88-5b48-8ec93abf3e05
+
+  To get the token as JSON, as returned by the HTTP API:
+
+      $ consul acl info -format=json 8f246b77-f3e1-ff88-5b48-8ec93abf3e05
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText + `
+
+ACL Info Options:
+
+` + aclFormatOptsText + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ACLInfoCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("info", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	stale := cmdFlags.Bool("stale", false, "")
+	format := cmdFlags.String("format", "table", "")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	id, err := aclIDFromArgs(cmdFlags.Args())
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+	if err := validACLFormat(*format); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	entry, _, err := client.ACL().Info(id, &api.QueryOptions{
+		Datacenter: *datacenter,
+		AllowStale: *stale,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+		return 1
+	}
+	if entry == nil {
+		c.Ui.Error(fmt.Sprintf("Error! No token exists with ID: %s", id))
+		return 1
+	}
+
+	if *format == "json" {
+		out, err := aclJSON(entry)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error rendering token: %s", err))
+			return 1
+		}
+		c.Ui.Output(out)
+		return 0
+	}
+
+	var b bytes.Buffer
+	if err := prettyACLEntry(&b, entry); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error rendering token: %s", err))
+		return 1
+	}
+	c.Ui.Output(strings.TrimSpace(b.String()))
+	return 0
+}
+
+func (c *ACLInfoCommand) Synopsis() string {
+	return "Shows the details of an ACL token"
+}
@@ -0,0 +1,129 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/consul/audit"
+	"github.com/mitchellh/cli"
+)
+
+func TestACLInfoCommand_implements(t *testing.T) {
+	var _ cli.Command = &ACLInfoCommand{}
+}
+
+func TestACLInfoCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(ACLInfoCommand))
+}
+
+func TestACLInfoCommand_Validation(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &ACLInfoCommand{Ui: ui}
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"no id": {
+			[]string{},
+			"Missing ID argument",
+		},
+		"extra args": {
+			[]string{"foo", "bar"},
+			"Too many arguments",
+		},
+		"bad format": {
+			[]string{"-format", "nope", "foo"},
+			"Invalid -format",
+		},
+	}
+
+	for name, tc := range cases {
+		// Ensure our buffer is always clear
+		if ui.ErrorWriter != nil {
+			ui.ErrorWriter.Reset()
+		}
+		if ui.OutputWriter != nil {
+			ui.OutputWriter.Reset()
+		}
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestACLInfoCommand_Run(t *testing.T) {
+	srv, client := testACLAgent(t)
+	defer srv.Shutdown()
+
+	id, _, err := client.ACL().Create(&api.ACLEntry{
+		Name:  "web",
+		Type:  api.ACLClientType,
+		Rules: `key "foo/" { policy = "write" }`,
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The table shows the token's details followed by its rules.
+	ui := new(cli.MockUi)
+	c := &ACLInfoCommand{Ui: ui}
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		id,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	output := ui.OutputWriter.String()
+	for _, s := range []string{id, audit.Accessor(id), "web", "client", `key "foo/" { policy = "write" }`} {
+		if !strings.Contains(output, s) {
+			t.Fatalf("bad: %s", output)
+		}
+	}
+
+	// The JSON matches the HTTP API.
+	ui = new(cli.MockUi)
+	c = &ACLInfoCommand{Ui: ui}
+	args = []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		"-format=json",
+		id,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	var entry api.ACLEntry
+	if err := json.Unmarshal(ui.OutputWriter.Bytes(), &entry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry.ID != id || entry.Name != "web" {
+		t.Fatalf("bad: %#v", entry)
+	}
+
+	// Asking for a token that doesn't exist fails.
+	ui = new(cli.MockUi)
+	c = &ACLInfoCommand{Ui: ui}
+	args = []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		"nope",
+	}
+	if code := c.Run(args); code == 0 {
+		t.Fatalf("expected non-zero exit")
+	}
+	if output := ui.ErrorWriter.String(); !strings.Contains(output, "No token exists") {
+		t.Fatalf("bad: %s", output)
+	}
+}
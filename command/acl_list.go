@@ -0,0 +1,106 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+	"github.com/ryanuber/columnize"
+)
+
+// ACLListCommand is a Command implementation that is used to list the ACL
+// tokens.
+type ACLListCommand struct {
+	Ui cli.Ui
+}
+
+func (c *ACLListCommand) Help() string {
+	helpText := `
+Usage: consul acl list [options]
+
+  Lists all the ACL tokens, with their ID, name, type, and when they expire. A
+  management token is required.
+
+      $ consul acl list
+
+  To get the tokens as JSON, including their rules, as returned by the HTTP
+  API:
+
+      $ consul acl list -format=json
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText + `
+
+ACL List Options:
+
+` + aclFormatOptsText + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ACLListCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("list", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	stale := cmdFlags.Bool("stale", false, "")
+	format := cmdFlags.String("format", "table", "")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	if args = cmdFlags.Args(); len(args) > 0 {
+		c.Ui.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+	if err := validACLFormat(*format); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	entries, _, err := client.ACL().List(&api.QueryOptions{
+		Datacenter: *datacenter,
+		AllowStale: *stale,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+		return 1
+	}
+
+	if *format == "json" {
+		out, err := aclJSON(entries)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error rendering tokens: %s", err))
+			return 1
+		}
+		c.Ui.Output(out)
+		return 0
+	}
+
+	result := []string{"ID|Name|Type|Expires"}
+	for _, entry := range entries {
+		result = append(result, fmt.Sprintf("%s|%s|%s|%s",
+			entry.ID, entry.Name, entry.Type, aclExpirationString(entry)))
+	}
+	c.Ui.Output(columnize.SimpleFormat(result))
+	return 0
+}
+
+func (c *ACLListCommand) Synopsis() string {
+	return "Lists the ACL tokens"
+}
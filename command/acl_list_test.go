@@ -0,0 +1,68 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+func TestACLListCommand_implements(t *testing.T) {
+	var _ cli.Command = &ACLListCommand{}
+}
+
+func TestACLListCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(ACLListCommand))
+}
+
+func TestACLListCommand_Run(t *testing.T) {
+	srv, client := testACLAgent(t)
+	defer srv.Shutdown()
+
+	id, _, err := client.ACL().Create(&api.ACLEntry{Name: "web", Type: api.ACLClientType}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &ACLListCommand{Ui: ui}
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	output := ui.OutputWriter.String()
+	for _, s := range []string{"ID", id, "web", "anonymous"} {
+		if !strings.Contains(output, s) {
+			t.Fatalf("bad: %s", output)
+		}
+	}
+
+	ui = new(cli.MockUi)
+	c = &ACLListCommand{Ui: ui}
+	args = []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		"-format=json",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	var entries []*api.ACLEntry
+	if err := json.Unmarshal(ui.OutputWriter.Bytes(), &entries); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	found := false
+	for _, entry := range entries {
+		if entry.ID == id {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("bad: %v", entries)
+	}
+}
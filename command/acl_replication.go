@@ -0,0 +1,128 @@
+package command
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// ACLReplicationCommand is a Command implementation that is used to show the
+// status of ACL replication.
+type ACLReplicationCommand struct {
+	Ui cli.Ui
+}
+
+func (c *ACLReplicationCommand) Help() string {
+	helpText := `
+Usage: consul acl replication [options]
+
+  Shows the status of ACL replication in a datacenter. By default the
+  datacenter of the agent is queried, and another one can be given with the
+  -datacenter flag.
+
+      $ consul acl replication -datacenter=dc2
+
+  If the last error is more recent than the last success, replication is not
+  in a good state.
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText + `
+
+ACL Replication Options:
+
+` + aclFormatOptsText + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ACLReplicationCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("replication", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	stale := cmdFlags.Bool("stale", false, "")
+	format := cmdFlags.String("format", "table", "")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	if args = cmdFlags.Args(); len(args) > 0 {
+		c.Ui.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+	if err := validACLFormat(*format); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	status, _, err := client.ACL().Replication(&api.QueryOptions{
+		Datacenter: *datacenter,
+		AllowStale: *stale,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+		return 1
+	}
+
+	if *format == "json" {
+		out, err := aclJSON(status)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error rendering replication status: %s", err))
+			return 1
+		}
+		c.Ui.Output(out)
+		return 0
+	}
+
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 2, 6, ' ', 0)
+	fmt.Fprintf(tw, "Enabled\t%t\n", status.Enabled)
+	fmt.Fprintf(tw, "Running\t%t\n", status.Running)
+	if status.SourceDatacenter != "" {
+		fmt.Fprintf(tw, "SourceDatacenter\t%s\n", status.SourceDatacenter)
+	} else {
+		fmt.Fprintf(tw, "SourceDatacenter\t%s\n", "-")
+	}
+	fmt.Fprintf(tw, "ReplicatedIndex\t%d\n", status.ReplicatedIndex)
+	fmt.Fprintf(tw, "LastSuccess\t%s\n", aclReplicationTime(status.LastSuccess))
+	fmt.Fprintf(tw, "LastError\t%s\n", aclReplicationTime(status.LastError))
+	if err := tw.Flush(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error rendering replication status: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(strings.TrimSpace(b.String()))
+	return 0
+}
+
+func (c *ACLReplicationCommand) Synopsis() string {
+	return "Shows the status of ACL replication"
+}
+
+// aclReplicationTime formats a replication timestamp for display, showing
+// the zero time as never having happened.
+func aclReplicationTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,58 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+func TestACLReplicationCommand_implements(t *testing.T) {
+	var _ cli.Command = &ACLReplicationCommand{}
+}
+
+func TestACLReplicationCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(ACLReplicationCommand))
+}
+
+func TestACLReplicationCommand_Run(t *testing.T) {
+	srv, _ := testACLAgent(t)
+	defer srv.Shutdown()
+
+	// Replication isn't set up for the test agent.
+	ui := new(cli.MockUi)
+	c := &ACLReplicationCommand{Ui: ui}
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	output := ui.OutputWriter.String()
+	for _, s := range []string{"Enabled", "false", "LastSuccess"} {
+		if !strings.Contains(output, s) {
+			t.Fatalf("bad: %s", output)
+		}
+	}
+
+	ui = new(cli.MockUi)
+	c = &ACLReplicationCommand{Ui: ui}
+	args = []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		"-format=json",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	var status api.ACLReplicationStatus
+	if err := json.Unmarshal(ui.OutputWriter.Bytes(), &status); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if status.Enabled || status.Running {
+		t.Fatalf("bad: %#v", status)
+	}
+}
@@ -0,0 +1,196 @@
+package command
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/mitchellh/cli"
+)
+
+// ACLTranslateRulesCommand is a Command implementation that is used to
+// check ACL rules and translate them between HCL and JSON.
+type ACLTranslateRulesCommand struct {
+	Ui cli.Ui
+
+	// testStdin is the input for testing.
+	testStdin io.Reader
+}
+
+func (c *ACLTranslateRulesCommand) Help() string {
+	helpText := `
+Usage: consul acl translate-rules [options] RULES
+
+  Parses ACL rules locally and outputs them in a canonical form, either as HCL
+  or as JSON. Rules can be given in either format, and the command fails if
+  they aren't valid, so it can also be used to check rules before they are
+  given to a token. This command doesn't contact the Consul agent.
+
+  The rules can be read from a file by prefixing the path with the "@" symbol:
+
+      $ consul acl translate-rules @web.hcl
+
+  Or they can be read from stdin using the "-" symbol. For example, to turn
+  HCL rules into JSON:
+
+      $ cat web.hcl | consul acl translate-rules -format=json -
+
+  For a full list of options and examples, please see the Consul documentation.
+
+ACL Translate Rules Options:
+
+  -format=<string>        Output format, either "hcl" or "json". The default
+                          value is "hcl".
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ACLTranslateRulesCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("translate-rules", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	format := cmdFlags.String("format", "hcl", "")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	var arg string
+	args = cmdFlags.Args()
+	switch len(args) {
+	case 0:
+		c.Ui.Error("Error! Missing RULES argument")
+		return 1
+	case 1:
+		arg = args[0]
+	default:
+		c.Ui.Error(fmt.Sprintf("Too many arguments (expected 1, got %d)", len(args)))
+		return 1
+	}
+	if *format != "hcl" && *format != "json" {
+		c.Ui.Error(fmt.Sprintf("Error! Invalid -format %q, must be \"hcl\" or \"json\"", *format))
+		return 1
+	}
+
+	rules, err := aclRulesFromArg(arg, c.testStdin)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+	policy, err := acl.Parse(rules)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! Invalid rules: %s", err))
+		return 1
+	}
+
+	if *format == "json" {
+		out, err := aclJSON(aclRulesMap(policy))
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error rendering rules: %s", err))
+			return 1
+		}
+		c.Ui.Output(out)
+		return 0
+	}
+
+	c.Ui.Output(aclRulesHCL(policy))
+	return 0
+}
+
+func (c *ACLTranslateRulesCommand) Synopsis() string {
+	return "Checks ACL rules and translates them between HCL and JSON"
+}
+
+// aclRule is a single named rule of a policy, such as a key prefix or a
+// service name along with its policy.
+type aclRule struct {
+	name   string
+	policy string
+}
+
+// aclRuleSection is a kind of named rule, in the order the sections are
+// written out.
+type aclRuleSection struct {
+	kind  string
+	rules []aclRule
+}
+
+// aclRuleSections returns the named rules of the policy grouped by kind, in
+// a fixed order.
+func aclRuleSections(p *acl.Policy) []aclRuleSection {
+	var agents, events, keys, nodes, queries, services, sessions []aclRule
+	for _, r := range p.Agents {
+		agents = append(agents, aclRule{r.Node, r.Policy})
+	}
+	for _, r := range p.Events {
+		events = append(events, aclRule{r.Event, r.Policy})
+	}
+	for _, r := range p.Keys {
+		keys = append(keys, aclRule{r.Prefix, r.Policy})
+	}
+	for _, r := range p.Nodes {
+		nodes = append(nodes, aclRule{r.Name, r.Policy})
+	}
+	for _, r := range p.PreparedQueries {
+		queries = append(queries, aclRule{r.Prefix, r.Policy})
+	}
+	for _, r := range p.Services {
+		services = append(services, aclRule{r.Name, r.Policy})
+	}
+	for _, r := range p.Sessions {
+		sessions = append(sessions, aclRule{r.Node, r.Policy})
+	}
+
+	return []aclRuleSection{
+		{"agent", agents},
+		{"event", events},
+		{"key", keys},
+		{"node", nodes},
+		{"query", queries},
+		{"service", services},
+		{"session", sessions},
+	}
+}
+
+// aclRulesHCL renders the policy as HCL rules.
+func aclRulesHCL(p *acl.Policy) string {
+	var b bytes.Buffer
+	for _, section := range aclRuleSections(p) {
+		for _, r := range section.rules {
+			fmt.Fprintf(&b, "%s %s {\n  policy = %s\n}\n",
+				section.kind, strconv.Quote(r.name), strconv.Quote(r.policy))
+		}
+	}
+	if p.Keyring != "" {
+		fmt.Fprintf(&b, "keyring = %s\n", strconv.Quote(p.Keyring))
+	}
+	if p.Operator != "" {
+		fmt.Fprintf(&b, "operator = %s\n", strconv.Quote(p.Operator))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// aclRulesMap returns the policy in the structure used for JSON rules.
+func aclRulesMap(p *acl.Policy) map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, section := range aclRuleSections(p) {
+		if len(section.rules) == 0 {
+			continue
+		}
+		rules := make(map[string]interface{})
+		for _, r := range section.rules {
+			rules[r.name] = map[string]string{"policy": r.policy}
+		}
+		out[section.kind] = rules
+	}
+	if p.Keyring != "" {
+		out["keyring"] = p.Keyring
+	}
+	if p.Operator != "" {
+		out["operator"] = p.Operator
+	}
+	return out
+}
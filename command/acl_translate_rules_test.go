@@ -0,0 +1,117 @@
+package command
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/mitchellh/cli"
+)
+
+func TestACLTranslateRulesCommand_implements(t *testing.T) {
+	var _ cli.Command = &ACLTranslateRulesCommand{}
+}
+
+func TestACLTranslateRulesCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(ACLTranslateRulesCommand))
+}
+
+func TestACLTranslateRulesCommand_Validation(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &ACLTranslateRulesCommand{Ui: ui}
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"no rules": {
+			[]string{},
+			"Missing RULES argument",
+		},
+		"extra args": {
+			[]string{"foo", "bar"},
+			"Too many arguments",
+		},
+		"bad format": {
+			[]string{"-format", "table", `keyring = "read"`},
+			"Invalid -format",
+		},
+		"bad rules": {
+			[]string{`key "" { policy = "nope" }`},
+			"Invalid rules",
+		},
+	}
+
+	for name, tc := range cases {
+		// Ensure our buffer is always clear
+		if ui.ErrorWriter != nil {
+			ui.ErrorWriter.Reset()
+		}
+		if ui.OutputWriter != nil {
+			ui.OutputWriter.Reset()
+		}
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestACLTranslateRulesCommand_Run(t *testing.T) {
+	rules := `
+agent "web-" { policy = "write" }
+key "" { policy = "read" }
+key "foo/" { policy = "write" }
+service "" { policy = "write" }
+session "" { policy = "read" }
+keyring = "deny"
+operator = "read"
+`
+	exp, err := acl.Parse(rules)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Translate the rules to JSON.
+	ui := new(cli.MockUi)
+	c := &ACLTranslateRulesCommand{
+		Ui:        ui,
+		testStdin: strings.NewReader(rules),
+	}
+	if code := c.Run([]string{"-format=json", "-"}); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	asJSON := ui.OutputWriter.String()
+	out, err := acl.Parse(asJSON)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(out, exp) {
+		t.Fatalf("bad: %s", asJSON)
+	}
+
+	// And back to HCL.
+	ui = new(cli.MockUi)
+	c = &ACLTranslateRulesCommand{Ui: ui}
+	if code := c.Run([]string{asJSON}); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	asHCL := ui.OutputWriter.String()
+	if !strings.Contains(asHCL, "agent \"web-\" {\n  policy = \"write\"\n}") {
+		t.Fatalf("bad: %s", asHCL)
+	}
+	out, err = acl.Parse(asHCL)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(out, exp) {
+		t.Fatalf("bad: %s", asHCL)
+	}
+}
@@ -0,0 +1,120 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// ACLUpdateCommand is a Command implementation that is used to update an
+// existing ACL token.
+type ACLUpdateCommand struct {
+	Ui cli.Ui
+
+	// testStdin is the input for testing.
+	testStdin io.Reader
+}
+
+func (c *ACLUpdateCommand) Help() string {
+	helpText := `
+Usage: consul acl update [options] ID
+
+  Updates an existing ACL token. Only the fields given by options are changed,
+  and the rest are kept as they are, including the token's expiration. A
+  management token is required.
+
+  To replace the rules of a token with the rules in a file:
+
+      $ consul acl update -rules=@web.hcl 8f246b77-f3e1-ff88-5b48-8ec93abf3e05
+
+  To link a token to a set of policies, replacing the ones it had before:
+
+      $ consul acl update -policy=b5a3ffe4-cb0e-4a1d-b3c0-2df1e6c8a7a3 \
+          8f246b77-f3e1-ff88-5b48-8ec93abf3e05
+
+  To push the expiration of a token out by another hour:
+
+      $ consul acl update -ttl=1h 8f246b77-f3e1-ff88-5b48-8ec93abf3e05
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText + `
+
+ACL Update Options:
+
+` + aclTokenOptsText + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ACLUpdateCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("update", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	tokenFlags := newACLTokenFlags(cmdFlags)
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	id, err := aclIDFromArgs(cmdFlags.Args())
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+
+	// Track which flags were given, so only those fields get changed.
+	set := make(map[string]bool)
+	cmdFlags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	entry, _, err := client.ACL().Info(id, &api.QueryOptions{
+		Datacenter: *datacenter,
+		Token:      *token,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+		return 1
+	}
+	if entry == nil {
+		c.Ui.Error(fmt.Sprintf("Error! No token exists with ID: %s", id))
+		return 1
+	}
+
+	if err := tokenFlags.apply(entry, set, c.testStdin); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+
+	if _, err := client.ACL().Update(entry, &api.WriteOptions{
+		Datacenter: *datacenter,
+		Token:      *token,
+	}); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! Failed to update token: %s", err))
+		return 1
+	}
+
+	c.Ui.Info(fmt.Sprintf("Success! Updated token: %s", id))
+	return 0
+}
+
+func (c *ACLUpdateCommand) Synopsis() string {
+	return "Updates an ACL token"
+}
@@ -0,0 +1,110 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+func TestACLUpdateCommand_implements(t *testing.T) {
+	var _ cli.Command = &ACLUpdateCommand{}
+}
+
+func TestACLUpdateCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(ACLUpdateCommand))
+}
+
+func TestACLUpdateCommand_Validation(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &ACLUpdateCommand{Ui: ui}
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"no id": {
+			[]string{},
+			"Missing ID argument",
+		},
+		"extra args": {
+			[]string{"foo", "bar"},
+			"Too many arguments",
+		},
+	}
+
+	for name, tc := range cases {
+		// Ensure our buffer is always clear
+		if ui.ErrorWriter != nil {
+			ui.ErrorWriter.Reset()
+		}
+		if ui.OutputWriter != nil {
+			ui.OutputWriter.Reset()
+		}
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestACLUpdateCommand_Run(t *testing.T) {
+	srv, client := testACLAgent(t)
+	defer srv.Shutdown()
+
+	id, _, err := client.ACL().Create(&api.ACLEntry{
+		Name:  "web",
+		Type:  api.ACLClientType,
+		Rules: `key "foo/" { policy = "write" }`,
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &ACLUpdateCommand{Ui: ui}
+
+	// Only the name should change.
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		"-name=web-renamed",
+		id,
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	entry, _, err := client.ACL().Info(id, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entry == nil || entry.Name != "web-renamed" || entry.Rules != `key "foo/" { policy = "write" }` {
+		t.Fatalf("bad: %#v", entry)
+	}
+
+	// Updating a token that doesn't exist fails.
+	ui = new(cli.MockUi)
+	c = &ACLUpdateCommand{Ui: ui}
+	args = []string{
+		"-http-addr=" + srv.httpAddr,
+		"-token=root",
+		"-name=nope",
+		"nope",
+	}
+	if code := c.Run(args); code == 0 {
+		t.Fatalf("expected non-zero exit")
+	}
+	if output := ui.ErrorWriter.String(); !strings.Contains(output, "No token exists") {
+		t.Fatalf("bad: %s", output)
+	}
+}
@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"errors"
+
+	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/consul/audit"
+)
+
+// errPermissionDenied is returned by endpoints the agent serves itself when
+// the token doesn't have the needed ACL rights.
+var errPermissionDenied = errors.New(permissionDenied)
+
+// resolveToken resolves the given token ID to an ACL for requests that are
+// handled by the agent without going through the servers. A nil ACL is
+// returned if ACLs are disabled, or if the version 8 ACL rules aren't being
+// enforced, in which case the caller should allow the request.
+func (a *Agent) resolveToken(id string) (acl.ACL, error) {
+	if a.config.ACLEnforceVersion8 == nil || !*a.config.ACLEnforceVersion8 {
+		return nil, nil
+	}
+
+	if a.server != nil {
+		return a.server.ResolveToken(id)
+	}
+	return a.client.ResolveToken(id)
+}
+
+// auditACL records a decision about an endpoint the agent serves itself in
+// the audit log, if one is configured. Failing to record a decision is
+// logged but doesn't fail the request.
+func (a *Agent) auditACL(token, endpoint string, decision audit.Decision) {
+	if err := a.auditLog.Record(token, endpoint, audit.ResourceAgent, a.config.NodeName, decision); err != nil {
+		a.logger.Printf("[ERR] agent: Failed to record audit entry for %s: %v", endpoint, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/consul/consul/structs"
 )
@@ -74,7 +75,7 @@ func (s *HTTPServer) aclSet(resp http.ResponseWriter, req *http.Request, update
 
 	// Handle optional request body
 	if req.ContentLength > 0 {
-		if err := decodeBody(req, &args.ACL, nil); err != nil {
+		if err := decodeBody(req, &args.ACL, FixupACLExpiration); err != nil {
 			resp.WriteHeader(400)
 			resp.Write([]byte(fmt.Sprintf("Request decode failed: %v", err)))
 			return nil, nil
@@ -99,6 +100,37 @@ func (s *HTTPServer) aclSet(resp http.ResponseWriter, req *http.Request, update
 	return aclCreateResponse{out}, nil
 }
 
+// FixupACLExpiration is used to handle parsing the JSON body to acl/create
+// and acl/update, where the expiration time is given as an RFC 3339 string,
+// and the expiration TTL as either a duration string or nanoseconds.
+func FixupACLExpiration(raw interface{}) error {
+	rawMap, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for k, val := range rawMap {
+		vStr, ok := val.(string)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(k) {
+		case "expirationtime":
+			t, err := time.Parse(time.RFC3339, vStr)
+			if err != nil {
+				return err
+			}
+			rawMap[k] = t
+		case "expirationttl":
+			dur, err := time.ParseDuration(vStr)
+			if err != nil {
+				return err
+			}
+			rawMap[k] = dur
+		}
+	}
+	return nil
+}
+
 func (s *HTTPServer) ACLClone(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	// Mandate a PUT request
 	if req.Method != "PUT" {
@@ -206,6 +238,134 @@ func (s *HTTPServer) ACLList(resp http.ResponseWriter, req *http.Request) (inter
 	return out.ACLs, nil
 }
 
+func (s *HTTPServer) ACLPolicyDestroy(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Mandate a PUT request
+	if req.Method != "PUT" {
+		resp.WriteHeader(405)
+		return nil, nil
+	}
+
+	args := structs.ACLPolicyEntryRequest{
+		Datacenter: s.agent.config.ACLDatacenter,
+		Op:         structs.ACLDelete,
+	}
+	s.parseToken(req, &args.Token)
+
+	// Pull out the policy id
+	args.Policy.ID = strings.TrimPrefix(req.URL.Path, "/v1/acl/policy/destroy/")
+	if args.Policy.ID == "" {
+		resp.WriteHeader(400)
+		resp.Write([]byte("Missing ACL policy"))
+		return nil, nil
+	}
+
+	var out string
+	if err := s.agent.RPC("ACL.PolicyApply", &args, &out); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+func (s *HTTPServer) ACLPolicyCreate(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	return s.aclPolicySet(resp, req, false)
+}
+
+func (s *HTTPServer) ACLPolicyUpdate(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	return s.aclPolicySet(resp, req, true)
+}
+
+func (s *HTTPServer) aclPolicySet(resp http.ResponseWriter, req *http.Request, update bool) (interface{}, error) {
+	// Mandate a PUT request
+	if req.Method != "PUT" {
+		resp.WriteHeader(405)
+		return nil, nil
+	}
+
+	args := structs.ACLPolicyEntryRequest{
+		Datacenter: s.agent.config.ACLDatacenter,
+		Op:         structs.ACLSet,
+	}
+	s.parseToken(req, &args.Token)
+
+	// Handle optional request body
+	if req.ContentLength > 0 {
+		if err := decodeBody(req, &args.Policy, nil); err != nil {
+			resp.WriteHeader(400)
+			resp.Write([]byte(fmt.Sprintf("Request decode failed: %v", err)))
+			return nil, nil
+		}
+	}
+
+	// Ensure there is an ID set for update. ID is optional for
+	// create, as one will be generated if not provided.
+	if update && args.Policy.ID == "" {
+		resp.WriteHeader(400)
+		resp.Write([]byte(fmt.Sprintf("ACL policy ID must be set")))
+		return nil, nil
+	}
+
+	// Create the policy, get the ID
+	var out string
+	if err := s.agent.RPC("ACL.PolicyApply", &args, &out); err != nil {
+		return nil, err
+	}
+
+	// Format the response as a JSON object
+	return aclCreateResponse{out}, nil
+}
+
+func (s *HTTPServer) ACLPolicyGet(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.ACLPolicyEntrySpecificRequest{
+		Datacenter: s.agent.config.ACLDatacenter,
+	}
+	var dc string
+	if done := s.parse(resp, req, &dc, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	// Pull out the policy id
+	args.PolicyID = strings.TrimPrefix(req.URL.Path, "/v1/acl/policy/info/")
+	if args.PolicyID == "" {
+		resp.WriteHeader(400)
+		resp.Write([]byte("Missing ACL policy"))
+		return nil, nil
+	}
+
+	var out structs.IndexedACLPolicyEntries
+	defer setMeta(resp, &out.QueryMeta)
+	if err := s.agent.RPC("ACL.PolicyGet", &args, &out); err != nil {
+		return nil, err
+	}
+
+	// Use empty list instead of nil
+	if out.Policies == nil {
+		out.Policies = make(structs.ACLPolicyEntries, 0)
+	}
+	return out.Policies, nil
+}
+
+func (s *HTTPServer) ACLPolicyList(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.DCSpecificRequest{
+		Datacenter: s.agent.config.ACLDatacenter,
+	}
+	var dc string
+	if done := s.parse(resp, req, &dc, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var out structs.IndexedACLPolicyEntries
+	defer setMeta(resp, &out.QueryMeta)
+	if err := s.agent.RPC("ACL.PolicyList", &args, &out); err != nil {
+		return nil, err
+	}
+
+	// Use empty list instead of nil
+	if out.Policies == nil {
+		out.Policies = make(structs.ACLPolicyEntries, 0)
+	}
+	return out.Policies, nil
+}
+
 func (s *HTTPServer) ACLReplicationStatus(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	// Note that we do not forward to the ACL DC here. This is a query for
 	// any DC that's doing replication.
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/consul/structs"
 )
@@ -194,6 +196,88 @@ func TestACLGet(t *testing.T) {
 	})
 }
 
+func TestACLCreate_Expiration(t *testing.T) {
+	httpTest(t, func(srv *HTTPServer) {
+		create := func(raw map[string]interface{}) (*httptest.ResponseRecorder, string) {
+			body := bytes.NewBuffer(nil)
+			enc := json.NewEncoder(body)
+			enc.Encode(raw)
+
+			req, err := http.NewRequest("PUT", "/v1/acl/create?token=root", body)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			resp := httptest.NewRecorder()
+			obj, err := srv.ACLCreate(resp, req)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if obj == nil {
+				return resp, ""
+			}
+			return resp, obj.(aclCreateResponse).ID
+		}
+		info := func(id string) *structs.ACL {
+			req, err := http.NewRequest("GET", "/v1/acl/info/"+id, nil)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			resp := httptest.NewRecorder()
+			obj, err := srv.ACLGet(resp, req)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			acls := obj.(structs.ACLs)
+			if len(acls) != 1 {
+				t.Fatalf("bad: %v", acls)
+			}
+			return acls[0]
+		}
+
+		// A TTL is turned into an expiration time.
+		start := time.Now()
+		_, id := create(map[string]interface{}{
+			"Name":          "CI Token",
+			"ExpirationTTL": "1h",
+		})
+		acl := info(id)
+		if acl.ExpirationTime == nil ||
+			acl.ExpirationTime.Before(start.Add(time.Hour)) ||
+			acl.ExpirationTime.After(time.Now().Add(time.Hour)) {
+			t.Fatalf("bad: %#v", acl)
+		}
+
+		// The expiration shows up in the JSON, but not the TTL.
+		out, err := json.Marshal(acl)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !strings.Contains(string(out), `"ExpirationTime":`) ||
+			strings.Contains(string(out), "ExpirationTTL") {
+			t.Fatalf("bad: %s", out)
+		}
+
+		// An expiration time can be given directly.
+		expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		_, id = create(map[string]interface{}{
+			"Name":           "CI Token",
+			"ExpirationTime": expires.Format(time.RFC3339),
+		})
+		acl = info(id)
+		if acl.ExpirationTime == nil || !acl.ExpirationTime.Equal(expires) {
+			t.Fatalf("bad: %#v", acl)
+		}
+
+		// Bad values are rejected.
+		resp, _ := create(map[string]interface{}{
+			"ExpirationTTL": "nope",
+		})
+		if resp.Code != 400 {
+			t.Fatalf("bad: %d", resp.Code)
+		}
+	})
+}
+
 func TestACLList(t *testing.T) {
 	httpTest(t, func(srv *HTTPServer) {
 		var ids []string
@@ -233,3 +317,146 @@ func TestACLReplicationStatus(t *testing.T) {
 		}
 	})
 }
+
+func makeTestACLPolicy(t *testing.T, srv *HTTPServer, name string) string {
+	body := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(body)
+	raw := map[string]interface{}{
+		"Name":  name,
+		"Rules": `key "foo/" { policy = "read" }`,
+	}
+	enc.Encode(raw)
+
+	req, err := http.NewRequest("PUT", "/v1/acl/policy/create?token=root", body)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp := httptest.NewRecorder()
+	obj, err := srv.ACLPolicyCreate(resp, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return obj.(aclCreateResponse).ID
+}
+
+func TestACLPolicy_CRUD(t *testing.T) {
+	httpTest(t, func(srv *HTTPServer) {
+		id := makeTestACLPolicy(t, srv, "web")
+
+		// Update it, which needs the ID.
+		body := bytes.NewBuffer(nil)
+		enc := json.NewEncoder(body)
+		raw := map[string]interface{}{
+			"Name":        "web",
+			"Rules":       `key "foo/" { policy = "write" }`,
+			"Datacenters": []string{"dc1"},
+		}
+		enc.Encode(raw)
+		req, err := http.NewRequest("PUT", "/v1/acl/policy/update?token=root", body)
+		resp := httptest.NewRecorder()
+		if _, err := srv.ACLPolicyUpdate(resp, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.Code != 400 {
+			t.Fatalf("bad: %d", resp.Code)
+		}
+
+		raw["ID"] = id
+		body.Reset()
+		enc.Encode(raw)
+		req, err = http.NewRequest("PUT", "/v1/acl/policy/update?token=root", body)
+		resp = httptest.NewRecorder()
+		obj, err := srv.ACLPolicyUpdate(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if obj.(aclCreateResponse).ID != id {
+			t.Fatalf("bad: %v", obj)
+		}
+
+		// Read it back.
+		req, err = http.NewRequest("GET", "/v1/acl/policy/info/"+id+"?token=root", nil)
+		resp = httptest.NewRecorder()
+		obj, err = srv.ACLPolicyGet(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		policies, ok := obj.(structs.ACLPolicyEntries)
+		if !ok || len(policies) != 1 {
+			t.Fatalf("bad: %v", obj)
+		}
+		if policies[0].Rules != `key "foo/" { policy = "write" }` ||
+			len(policies[0].Datacenters) != 1 || policies[0].Datacenters[0] != "dc1" {
+			t.Fatalf("bad: %v", policies[0])
+		}
+
+		// Link it to a token, which blocks deleting it.
+		body.Reset()
+		enc.Encode(map[string]interface{}{
+			"Name":     "User Token",
+			"Policies": []string{id},
+		})
+		req, err = http.NewRequest("PUT", "/v1/acl/create?token=root", body)
+		resp = httptest.NewRecorder()
+		obj, err = srv.ACLCreate(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		token := obj.(aclCreateResponse).ID
+
+		req, err = http.NewRequest("PUT", "/v1/acl/policy/destroy/"+id+"?token=root", nil)
+		resp = httptest.NewRecorder()
+		if _, err := srv.ACLPolicyDestroy(resp, req); err == nil {
+			t.Fatalf("should fail")
+		}
+
+		// Destroy the token and then the policy.
+		req, err = http.NewRequest("PUT", "/v1/acl/destroy/"+token+"?token=root", nil)
+		resp = httptest.NewRecorder()
+		if _, err := srv.ACLDestroy(resp, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		req, err = http.NewRequest("PUT", "/v1/acl/policy/destroy/"+id+"?token=root", nil)
+		resp = httptest.NewRecorder()
+		obj, err = srv.ACLPolicyDestroy(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if ok, _ := obj.(bool); !ok {
+			t.Fatalf("should work")
+		}
+
+		req, err = http.NewRequest("GET", "/v1/acl/policy/info/"+id+"?token=root", nil)
+		resp = httptest.NewRecorder()
+		obj, err = srv.ACLPolicyGet(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		policies, ok = obj.(structs.ACLPolicyEntries)
+		if !ok || policies == nil || len(policies) != 0 {
+			t.Fatalf("bad: %v", obj)
+		}
+	})
+}
+
+func TestACLPolicyList(t *testing.T) {
+	httpTest(t, func(srv *HTTPServer) {
+		for _, name := range []string{"web", "db", "cache"} {
+			makeTestACLPolicy(t, srv, name)
+		}
+
+		req, err := http.NewRequest("GET", "/v1/acl/policies?token=root", nil)
+		resp := httptest.NewRecorder()
+		obj, err := srv.ACLPolicyList(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		policies, ok := obj.(structs.ACLPolicyEntries)
+		if !ok {
+			t.Fatalf("should work")
+		}
+		if len(policies) != 3 {
+			t.Fatalf("bad: %v", policies)
+		}
+	})
+}
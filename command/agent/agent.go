@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/consul/consul"
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/state"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/lib"
@@ -25,6 +26,7 @@ import (
 	"github.com/hashicorp/consul/types"
 	"github.com/hashicorp/go-sockaddr/template"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/raft"
 	"github.com/hashicorp/serf/coordinate"
 	"github.com/hashicorp/serf/serf"
 )
@@ -37,6 +39,9 @@ const (
 	checksDir     = "checks"
 	checkStateDir = "checks/state"
 
+	// Path to save the agent's node ID
+	nodeIDFile = "node-id"
+
 	// Default reasons for node/service maintenance mode
 	defaultNodeMaintReason = "Maintenance mode is enabled for this node, " +
 		"but no reason was provided. This is a default message."
@@ -74,6 +79,10 @@ type Agent struct {
 	server *consul.Server
 	client *consul.Client
 
+	// auditLog records ACL decisions made by the agent, and by its server
+	// if it runs one. It's nil if no audit log is configured.
+	auditLog *audit.Logger
+
 	// state stores a local representation of the node,
 	// services and checks. Used for anti-entropy.
 	state localState
@@ -91,12 +100,22 @@ type Agent struct {
 	// checkTCPs maps the check ID to an associated TCP check
 	checkTCPs map[types.CheckID]*CheckTCP
 
+	// checkGRPCs maps the check ID to an associated gRPC check
+	checkGRPCs map[types.CheckID]*CheckGRPC
+
 	// checkTTLs maps the check ID to an associated check TTL
 	checkTTLs map[types.CheckID]*CheckTTL
 
 	// checkDockers maps the check ID to an associated Docker Exec based check
 	checkDockers map[types.CheckID]*CheckDocker
 
+	// checkAliases maps the check ID to an associated alias check
+	checkAliases map[types.CheckID]*CheckAlias
+
+	// checkHandlers maps the check ID to the status handler that counts
+	// consecutive results for interval-based checks
+	checkHandlers map[types.CheckID]*StatusHandler
+
 	// checkLock protects updates to the check* maps
 	checkLock sync.Mutex
 
@@ -199,7 +218,10 @@ func Create(config *Config, logOutput io.Writer, logWriter *logger.LogWriter,
 		checkTTLs:      make(map[types.CheckID]*CheckTTL),
 		checkHTTPs:     make(map[types.CheckID]*CheckHTTP),
 		checkTCPs:      make(map[types.CheckID]*CheckTCP),
+		checkGRPCs:     make(map[types.CheckID]*CheckGRPC),
 		checkDockers:   make(map[types.CheckID]*CheckDocker),
+		checkAliases:   make(map[types.CheckID]*CheckAlias),
+		checkHandlers:  make(map[types.CheckID]*StatusHandler),
 		eventCh:        make(chan serf.UserEvent, 1024),
 		eventBuf:       make([]*UserEvent, 256),
 		reloadCh:       reloadCh,
@@ -211,9 +233,28 @@ func Create(config *Config, logOutput io.Writer, logWriter *logger.LogWriter,
 		return nil, err
 	}
 
+	// Make sure we have a node ID before we talk to anyone.
+	if err := agent.setupNodeID(config); err != nil {
+		return nil, fmt.Errorf("Failed to setup node ID: %v", err)
+	}
+
 	// Initialize the local state.
 	agent.state.Init(config, agent.logger)
 
+	// Open the audit log before the server is set up, so they can share it.
+	if config.AuditLog.Path != "" {
+		auditLog, err := audit.NewLogger(&audit.Config{
+			Path:      config.AuditLog.Path,
+			MaxBytes:  config.AuditLog.MaxBytes,
+			MaxFiles:  config.AuditLog.MaxFiles,
+			Resources: config.AuditLog.Resources,
+		})
+		if err != nil {
+			return nil, err
+		}
+		agent.auditLog = auditLog
+	}
+
 	// Setup either the client or the server.
 	var err error
 	if config.Server {
@@ -237,13 +278,16 @@ func Create(config *Config, logOutput io.Writer, logWriter *logger.LogWriter,
 		return nil, err
 	}
 
-	// Load checks/services.
+	// Load checks/services/metadata.
 	if err := agent.loadServices(config); err != nil {
 		return nil, err
 	}
 	if err := agent.loadChecks(config); err != nil {
 		return nil, err
 	}
+	if err := agent.loadMetadata(config); err != nil {
+		return nil, err
+	}
 
 	// Start watching for critical services to deregister, based on their
 	// checks.
@@ -291,6 +335,9 @@ func (a *Agent) consulConfig() *consul.Config {
 	if a.config.DataDir != "" {
 		base.DataDir = a.config.DataDir
 	}
+	if a.config.NodeID != "" {
+		base.NodeID = types.NodeID(a.config.NodeID)
+	}
 	if a.config.NodeName != "" {
 		base.NodeName = a.config.NodeName
 	}
@@ -361,6 +408,9 @@ func (a *Agent) consulConfig() *consul.Config {
 	if a.config.Protocol > 0 {
 		base.ProtocolVersion = uint8(a.config.Protocol)
 	}
+	if a.config.RaftProtocol != 0 {
+		base.RaftConfig.ProtocolVersion = raft.ProtocolVersion(a.config.RaftProtocol)
+	}
 	if a.config.ACLToken != "" {
 		base.ACLToken = a.config.ACLToken
 	}
@@ -388,6 +438,31 @@ func (a *Agent) consulConfig() *consul.Config {
 	if a.config.SessionTTLMinRaw != "" {
 		base.SessionTTLMin = a.config.SessionTTLMin
 	}
+	for _, history := range a.config.KVHistory {
+		base.KVSHistoryRules = append(base.KVSHistoryRules, &structs.KVSHistoryRule{
+			Prefix:   history.Prefix,
+			Versions: history.Versions,
+			Window:   history.Window,
+		})
+	}
+	for _, quota := range a.config.KVQuotas {
+		base.KVSQuotas = append(base.KVSQuotas, &structs.KVSQuota{
+			Prefix:       quota.Prefix,
+			Token:        quota.Token,
+			MaxKeys:      quota.MaxKeys,
+			MaxBytes:     quota.MaxBytes,
+			MaxValueSize: quota.MaxValueSize,
+		})
+	}
+	for _, replication := range a.config.KVReplication {
+		base.KVSReplication = append(base.KVSReplication, &consul.KVSReplicationConfig{
+			SourceDatacenter: replication.SourceDC,
+			Prefix:           replication.Prefix,
+			DestPrefix:       replication.DestPrefix,
+			Token:            replication.Token,
+		})
+	}
+	base.AuditLog = a.auditLog
 
 	// Format the build string
 	revision := a.config.Revision
@@ -554,6 +629,56 @@ func (a *Agent) setupServer() error {
 	return nil
 }
 
+// setupNodeID makes sure the agent has a node ID. A configured ID is used
+// as-is once validated, otherwise we load the one persisted in the data
+// directory, generating and saving a new one if this is our first start.
+func (a *Agent) setupNodeID(config *Config) error {
+	if config.NodeID != "" {
+		if _, err := uuid.ParseUUID(config.NodeID); err != nil {
+			return fmt.Errorf("invalid node ID %q: %v", config.NodeID, err)
+		}
+		return nil
+	}
+
+	// Dev mode doesn't persist anything, so just make a fresh ID.
+	if config.DevMode {
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			return err
+		}
+		config.NodeID = id
+		a.logger.Printf("[DEBUG] agent: Generated node ID %q (not persisted in dev mode)", id)
+		return nil
+	}
+
+	// Load the saved ID, if any. Since a user could edit this, we also
+	// validate it.
+	file := filepath.Join(config.DataDir, nodeIDFile)
+	raw, err := ioutil.ReadFile(file)
+	if err == nil {
+		id := strings.TrimSpace(string(raw))
+		if _, err := uuid.ParseUUID(id); err != nil {
+			return fmt.Errorf("invalid node ID %q in %q: %v", id, file, err)
+		}
+		config.NodeID = id
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// This is our first start, so make a new ID and save it.
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(file, []byte(id)); err != nil {
+		return err
+	}
+	config.NodeID = id
+	a.logger.Printf("[DEBUG] agent: Generated unique node ID %q for this agent (persisted)", id)
+	return nil
+}
+
 // setupClient is used to initialize the Consul client
 func (a *Agent) setupClient() error {
 	config := a.consulConfig()
@@ -670,6 +795,14 @@ func (a *Agent) Shutdown() error {
 		chk.Stop()
 	}
 
+	for _, chk := range a.checkGRPCs {
+		chk.Stop()
+	}
+
+	for _, chk := range a.checkAliases {
+		chk.Stop()
+	}
+
 	a.logger.Println("[INFO] agent: requesting shutdown")
 	var err error
 	if a.server != nil {
@@ -678,6 +811,10 @@ func (a *Agent) Shutdown() error {
 		err = a.client.Shutdown()
 	}
 
+	if err := a.auditLog.Close(); err != nil {
+		a.logger.Printf("[WARN] agent: failed to close audit log: %v", err)
+	}
+
 	pidErr := a.deletePid()
 	if pidErr != nil {
 		a.logger.Println("[WARN] agent: could not delete pid file ", pidErr)
@@ -978,6 +1115,14 @@ func (a *Agent) AddService(service *structs.NodeService, chkTypes CheckTypes, pe
 			return fmt.Errorf("Check type is not valid")
 		}
 	}
+	if err := structs.ValidateMetadata(service.Meta); err != nil {
+		return fmt.Errorf("Invalid service metadata: %v", err)
+	}
+	if service.Weights != nil {
+		if err := service.Weights.Validate(); err != nil {
+			return fmt.Errorf("Invalid service weights: %v", err)
+		}
+	}
 
 	// Warn if the service name is incompatible with DNS
 	if !dnsNameRe.MatchString(service.Service) {
@@ -1130,8 +1275,22 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *CheckType, persist
 
 			ttl.Start()
 			a.checkTTLs[check.CheckID] = ttl
+			delete(a.checkHandlers, check.CheckID)
 
 		} else if chkType.IsHTTP() {
+			var bodyRegex *regexp.Regexp
+			if chkType.ExpectedBodyRegex != "" {
+				var err error
+				bodyRegex, err = regexp.Compile(chkType.ExpectedBodyRegex)
+				if err != nil {
+					return fmt.Errorf("Invalid ExpectedBodyRegex: %v", err)
+				}
+			}
+			if chkType.ExpectedStatusMin != 0 && chkType.ExpectedStatusMax != 0 &&
+				chkType.ExpectedStatusMin > chkType.ExpectedStatusMax {
+				return fmt.Errorf("ExpectedStatusMin cannot be greater than ExpectedStatusMax")
+			}
+
 			if existing, ok := a.checkHTTPs[check.CheckID]; ok {
 				existing.Stop()
 			}
@@ -1142,13 +1301,21 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *CheckType, persist
 			}
 
 			http := &CheckHTTP{
-				Notify:        &a.state,
+				Notify:        a.checkStatusHandler(check.CheckID, chkType),
 				CheckID:       check.CheckID,
 				HTTP:          chkType.HTTP,
+				Method:        chkType.Method,
+				Header:        chkType.Header,
+				Body:          chkType.Body,
 				Interval:      chkType.Interval,
 				Timeout:       chkType.Timeout,
 				Logger:        a.logger,
 				TLSSkipVerify: chkType.TLSSkipVerify,
+
+				ExpectedStatusMin: chkType.ExpectedStatusMin,
+				ExpectedStatusMax: chkType.ExpectedStatusMax,
+				ExpectedBody:      chkType.ExpectedBody,
+				ExpectedBodyRegex: bodyRegex,
 			}
 			http.Start()
 			a.checkHTTPs[check.CheckID] = http
@@ -1164,7 +1331,7 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *CheckType, persist
 			}
 
 			tcp := &CheckTCP{
-				Notify:   &a.state,
+				Notify:   a.checkStatusHandler(check.CheckID, chkType),
 				CheckID:  check.CheckID,
 				TCP:      chkType.TCP,
 				Interval: chkType.Interval,
@@ -1174,6 +1341,29 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *CheckType, persist
 			tcp.Start()
 			a.checkTCPs[check.CheckID] = tcp
 
+		} else if chkType.IsGRPC() {
+			if existing, ok := a.checkGRPCs[check.CheckID]; ok {
+				existing.Stop()
+			}
+			if chkType.Interval < MinInterval {
+				a.logger.Println(fmt.Sprintf("[WARN] agent: check '%s' has interval below minimum of %v",
+					check.CheckID, MinInterval))
+				chkType.Interval = MinInterval
+			}
+
+			grpc := &CheckGRPC{
+				Notify:        a.checkStatusHandler(check.CheckID, chkType),
+				CheckID:       check.CheckID,
+				GRPC:          chkType.GRPC,
+				Interval:      chkType.Interval,
+				Timeout:       chkType.Timeout,
+				Logger:        a.logger,
+				TLS:           chkType.GRPCUseTLS,
+				TLSSkipVerify: chkType.TLSSkipVerify,
+			}
+			grpc.Start()
+			a.checkGRPCs[check.CheckID] = grpc
+
 		} else if chkType.IsDocker() {
 			if existing, ok := a.checkDockers[check.CheckID]; ok {
 				existing.Stop()
@@ -1185,7 +1375,7 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *CheckType, persist
 			}
 
 			dockerCheck := &CheckDocker{
-				Notify:            &a.state,
+				Notify:            a.checkStatusHandler(check.CheckID, chkType),
 				CheckID:           check.CheckID,
 				DockerContainerID: chkType.DockerContainerID,
 				Shell:             chkType.Shell,
@@ -1209,7 +1399,7 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *CheckType, persist
 			}
 
 			monitor := &CheckMonitor{
-				Notify:   &a.state,
+				Notify:   a.checkStatusHandler(check.CheckID, chkType),
 				CheckID:  check.CheckID,
 				Script:   chkType.Script,
 				Interval: chkType.Interval,
@@ -1218,6 +1408,37 @@ func (a *Agent) AddCheck(check *structs.HealthCheck, chkType *CheckType, persist
 			}
 			monitor.Start()
 			a.checkMonitors[check.CheckID] = monitor
+		} else if chkType.IsAlias() {
+			if existing, ok := a.checkAliases[check.CheckID]; ok {
+				existing.Stop()
+			}
+
+			// Alias a service on this node if no node was given.
+			node := chkType.AliasNode
+			if node == "" {
+				node = a.config.NodeName
+			}
+
+			// Use the check's token, falling back to the agent's.
+			rpcToken := token
+			if rpcToken == "" {
+				rpcToken = a.config.ACLToken
+			}
+
+			alias := &CheckAlias{
+				Notify:     &a.state,
+				RPC:        a,
+				CheckID:    check.CheckID,
+				Datacenter: a.config.Datacenter,
+				Node:       node,
+				LocalNode:  a.config.NodeName,
+				ServiceID:  chkType.AliasService,
+				Token:      rpcToken,
+				Logger:     a.logger,
+			}
+			alias.Start()
+			a.checkAliases[check.CheckID] = alias
+			delete(a.checkHandlers, check.CheckID)
 		} else {
 			return fmt.Errorf("Check type is not valid")
 		}
@@ -1274,10 +1495,19 @@ func (a *Agent) RemoveCheck(checkID types.CheckID, persist bool) error {
 		check.Stop()
 		delete(a.checkTCPs, checkID)
 	}
+	if check, ok := a.checkGRPCs[checkID]; ok {
+		check.Stop()
+		delete(a.checkGRPCs, checkID)
+	}
 	if check, ok := a.checkTTLs[checkID]; ok {
 		check.Stop()
 		delete(a.checkTTLs, checkID)
 	}
+	if check, ok := a.checkAliases[checkID]; ok {
+		check.Stop()
+		delete(a.checkAliases, checkID)
+	}
+	delete(a.checkHandlers, checkID)
 	if persist {
 		if err := a.purgeCheck(checkID); err != nil {
 			return err
@@ -1290,6 +1520,28 @@ func (a *Agent) RemoveCheck(checkID types.CheckID, persist bool) error {
 	return nil
 }
 
+// checkStatusHandler makes a new status handler for an interval-based check,
+// so that its status only changes after the configured number of consecutive
+// results. It must be called with checkLock held.
+func (a *Agent) checkStatusHandler(checkID types.CheckID, chkType *CheckType) *StatusHandler {
+	handler := NewStatusHandler(&a.state, a.logger,
+		chkType.SuccessBeforePassing, chkType.FailuresBeforeCritical)
+	a.checkHandlers[checkID] = handler
+	return handler
+}
+
+// checkCounts returns the number of consecutive successes and failures for
+// the given check. Checks that don't count their results return zeros.
+func (a *Agent) checkCounts(checkID types.CheckID) (successes, failures int) {
+	a.checkLock.Lock()
+	defer a.checkLock.Unlock()
+
+	if handler, ok := a.checkHandlers[checkID]; ok {
+		return handler.Counts()
+	}
+	return 0, 0
+}
+
 // updateTTLCheck is used to update the status of a TTL check via the Agent API.
 func (a *Agent) updateTTLCheck(checkID types.CheckID, status, output string) error {
 	a.checkLock.Lock()
@@ -1652,6 +1904,43 @@ func (a *Agent) unloadChecks() error {
 	return nil
 }
 
+// loadMetadata loads node metadata fields from the agent config and
+// updates them on the local agent.
+func (a *Agent) loadMetadata(conf *Config) error {
+	if err := structs.ValidateMetadata(conf.Meta); err != nil {
+		return err
+	}
+
+	a.state.Lock()
+	defer a.state.Unlock()
+
+	for key, value := range conf.Meta {
+		a.state.metadata[key] = value
+	}
+
+	a.state.changeMade()
+	return nil
+}
+
+// parseMetaPair parses a key/value pair of the form key:value. If there's
+// no separator then the whole string is used as the key and the value is
+// left blank.
+func parseMetaPair(raw string) (string, string) {
+	pair := strings.SplitN(raw, ":", 2)
+	if len(pair) == 2 {
+		return pair[0], pair[1]
+	}
+	return pair[0], ""
+}
+
+// unloadMetadata resets the local metadata state.
+func (a *Agent) unloadMetadata() {
+	a.state.Lock()
+	defer a.state.Unlock()
+
+	a.state.metadata = make(map[string]string)
+}
+
 // snapshotCheckState is used to snapshot the current state of the health
 // checks. This is done before we reload our checks, so that we can properly
 // restore into the same state.
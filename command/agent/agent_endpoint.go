@@ -7,6 +7,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/hashicorp/consul/consul/audit"
+	"github.com/hashicorp/consul/consul/filter"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/logger"
 	"github.com/hashicorp/consul/types"
@@ -45,6 +47,18 @@ func (s *HTTPServer) AgentReload(resp http.ResponseWriter, req *http.Request) (i
 		return nil, nil
 	}
 
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	acl, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if acl != nil && !acl.AgentWrite(s.agent.config.NodeName) {
+		s.agent.auditACL(token, req.URL.Path, audit.Deny)
+		return nil, errPermissionDenied
+	}
+
 	errCh := make(chan error, 0)
 
 	// Trigger the reload
@@ -59,17 +73,50 @@ func (s *HTTPServer) AgentReload(resp http.ResponseWriter, req *http.Request) (i
 	case <-s.agent.ShutdownCh():
 		return nil, fmt.Errorf("Agent was shutdown before reload could be completed")
 	case err := <-errCh:
+		if err == nil && acl != nil {
+			s.agent.auditACL(token, req.URL.Path, audit.Allow)
+		}
 		return nil, err
 	}
 }
 
 func (s *HTTPServer) AgentServices(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 	services := s.agent.state.Services()
+
+	// Apply the filter, if one was given
+	if expr := req.URL.Query().Get("filter"); expr != "" {
+		f, err := filter.New(expr, structs.NodeService{})
+		if err != nil {
+			return nil, err
+		}
+		raw, err := f.Execute(services)
+		if err != nil {
+			return nil, err
+		}
+		services = raw.(map[string]*structs.NodeService)
+	}
 	return services, nil
 }
 
+// AgentCheck is a check as returned by /v1/agent/checks. Along with the
+// check itself it has the number of consecutive successes and failures the
+// agent has seen, which are used to debounce status changes.
+type AgentCheck struct {
+	*structs.HealthCheck
+	SuccessCount int
+	FailureCount int
+}
+
 func (s *HTTPServer) AgentChecks(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
-	checks := s.agent.state.Checks()
+	checks := make(map[types.CheckID]*AgentCheck)
+	for id, check := range s.agent.state.Checks() {
+		successes, failures := s.agent.checkCounts(id)
+		checks[id] = &AgentCheck{
+			HealthCheck:  check,
+			SuccessCount: successes,
+			FailureCount: failures,
+		}
+	}
 	return checks, nil
 }
 
@@ -87,6 +134,18 @@ func (s *HTTPServer) AgentMembers(resp http.ResponseWriter, req *http.Request) (
 }
 
 func (s *HTTPServer) AgentJoin(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	acl, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if acl != nil && !acl.AgentWrite(s.agent.config.NodeName) {
+		s.agent.auditACL(token, req.URL.Path, audit.Deny)
+		return nil, errPermissionDenied
+	}
+
 	// Check if the WAN is being queried
 	wan := false
 	if other := req.URL.Query().Get("wan"); other != "" {
@@ -96,12 +155,17 @@ func (s *HTTPServer) AgentJoin(resp http.ResponseWriter, req *http.Request) (int
 	// Get the address
 	addr := strings.TrimPrefix(req.URL.Path, "/v1/agent/join/")
 	if wan {
-		_, err := s.agent.JoinWAN([]string{addr})
-		return nil, err
+		_, err = s.agent.JoinWAN([]string{addr})
 	} else {
-		_, err := s.agent.JoinLAN([]string{addr})
+		_, err = s.agent.JoinLAN([]string{addr})
+	}
+	if err != nil {
 		return nil, err
 	}
+	if acl != nil {
+		s.agent.auditACL(token, req.URL.Path, audit.Allow)
+	}
+	return nil, nil
 }
 
 func (s *HTTPServer) AgentLeave(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
@@ -110,15 +174,48 @@ func (s *HTTPServer) AgentLeave(resp http.ResponseWriter, req *http.Request) (in
 		return nil, nil
 	}
 
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	acl, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if acl != nil && !acl.AgentWrite(s.agent.config.NodeName) {
+		s.agent.auditACL(token, req.URL.Path, audit.Deny)
+		return nil, errPermissionDenied
+	}
+
 	if err := s.agent.Leave(); err != nil {
 		return nil, err
 	}
+	if acl != nil {
+		s.agent.auditACL(token, req.URL.Path, audit.Allow)
+	}
 	return nil, s.agent.Shutdown()
 }
 
 func (s *HTTPServer) AgentForceLeave(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Fetch the ACL token, if any, and enforce agent policy.
+	var token string
+	s.parseToken(req, &token)
+	acl, err := s.agent.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if acl != nil && !acl.AgentWrite(s.agent.config.NodeName) {
+		s.agent.auditACL(token, req.URL.Path, audit.Deny)
+		return nil, errPermissionDenied
+	}
+
 	addr := strings.TrimPrefix(req.URL.Path, "/v1/agent/force-leave/")
-	return nil, s.agent.ForceLeave(addr)
+	if err := s.agent.ForceLeave(addr); err != nil {
+		return nil, err
+	}
+	if acl != nil {
+		s.agent.auditACL(token, req.URL.Path, audit.Allow)
+	}
+	return nil, nil
 }
 
 const invalidCheckMessage = "Must provide TTL or Script/DockerContainerID/HTTP/TCP and Interval"
@@ -306,6 +403,18 @@ func (s *HTTPServer) AgentRegisterService(resp http.ResponseWriter, req *http.Re
 
 	// Get the node service
 	ns := args.NodeService()
+	if err := structs.ValidateMetadata(ns.Meta); err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(fmt.Sprintf("Invalid Service Meta: %v", err)))
+		return nil, nil
+	}
+	if ns.Weights != nil {
+		if err := ns.Weights.Validate(); err != nil {
+			resp.WriteHeader(400)
+			resp.Write([]byte(fmt.Sprintf("Invalid service weights: %v", err)))
+			return nil, nil
+		}
+	}
 
 	// Verify the check type
 	chkTypes := args.CheckTypes()
@@ -442,11 +551,24 @@ func (s *HTTPServer) AgentMonitor(resp http.ResponseWriter, req *http.Request) (
 	var args structs.DCSpecificRequest
 	args.Datacenter = s.agent.config.Datacenter
 	s.parseToken(req, &args.Token)
-	// Validate that the given token has operator permissions
-	var reply structs.RaftConfigurationResponse
-	if err := s.agent.RPC("Operator.RaftGetConfiguration", &args, &reply); err != nil {
+
+	// With the version 8 ACL rules the token needs agent read access,
+	// otherwise it has to have operator permissions.
+	acl, err := s.agent.resolveToken(args.Token)
+	if err != nil {
 		return nil, err
 	}
+	if acl != nil {
+		if !acl.AgentRead(s.agent.config.NodeName) {
+			s.agent.auditACL(args.Token, req.URL.Path, audit.Deny)
+			return nil, errPermissionDenied
+		}
+	} else {
+		var reply structs.RaftConfigurationResponse
+		if err := s.agent.RPC("Operator.RaftGetConfiguration", &args, &reply); err != nil {
+			return nil, err
+		}
+	}
 
 	// Get the provided loglevel
 	logLevel := req.URL.Query().Get("loglevel")
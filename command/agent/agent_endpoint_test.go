@@ -1,18 +1,22 @@
 package agent
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/logger"
 	"github.com/hashicorp/consul/testutil"
@@ -35,7 +39,8 @@ func TestHTTPAgentServices(t *testing.T) {
 	}
 	srv.agent.state.AddService(srv1, "")
 
-	obj, err := srv.AgentServices(nil, nil)
+	req, _ := http.NewRequest("GET", "/v1/agent/services", nil)
+	obj, err := srv.AgentServices(nil, req)
 	if err != nil {
 		t.Fatalf("Err: %v", err)
 	}
@@ -48,6 +53,42 @@ func TestHTTPAgentServices(t *testing.T) {
 	}
 }
 
+func TestHTTPAgentServices_Filter(t *testing.T) {
+	dir, srv := makeHTTPServer(t)
+	defer os.RemoveAll(dir)
+	defer srv.Shutdown()
+	defer srv.agent.Shutdown()
+
+	srv1 := &structs.NodeService{
+		ID:      "mysql",
+		Service: "mysql",
+		Tags:    []string{"master"},
+		Port:    5000,
+	}
+	srv.agent.state.AddService(srv1, "")
+
+	req, _ := http.NewRequest("GET", "/v1/agent/services?filter="+url.QueryEscape(`Tags contains "master" and Port == 5000`), nil)
+	obj, err := srv.AgentServices(nil, req)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	val := obj.(map[string]*structs.NodeService)
+	if len(val) != 1 || val["mysql"] == nil {
+		t.Fatalf("bad services: %v", obj)
+	}
+
+	// An invalid filter should be a 400 error.
+	req, _ = http.NewRequest("GET", "/v1/agent/services?filter="+url.QueryEscape(`Port == "nope"`), nil)
+	resp := httptest.NewRecorder()
+	srv.wrap(srv.AgentServices)(resp, req)
+	if resp.Code != 400 {
+		t.Fatalf("bad: %d", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), "Invalid filter expression") {
+		t.Fatalf("bad: %s", resp.Body.String())
+	}
+}
+
 func TestHTTPAgentChecks(t *testing.T) {
 	dir, srv := makeHTTPServer(t)
 	defer os.RemoveAll(dir)
@@ -66,7 +107,7 @@ func TestHTTPAgentChecks(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Err: %v", err)
 	}
-	val := obj.(map[types.CheckID]*structs.HealthCheck)
+	val := obj.(map[types.CheckID]*AgentCheck)
 	if len(val) != 1 {
 		t.Fatalf("bad checks: %v", obj)
 	}
@@ -75,6 +116,59 @@ func TestHTTPAgentChecks(t *testing.T) {
 	}
 }
 
+func TestHTTPAgentChecks_Counts(t *testing.T) {
+	dir, srv := makeHTTPServer(t)
+	defer os.RemoveAll(dir)
+	defer srv.Shutdown()
+	defer srv.agent.Shutdown()
+
+	chk := &structs.HealthCheck{
+		Node:    srv.agent.config.NodeName,
+		CheckID: "mysql",
+		Name:    "mysql",
+		Status:  structs.HealthPassing,
+	}
+	chkType := &CheckType{
+		Script:                 "exit 2",
+		Interval:               time.Hour,
+		FailuresBeforeCritical: 3,
+	}
+	if err := srv.agent.AddCheck(chk, chkType, false, ""); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Feed a couple of failures through the check's handler. The check
+	// should still be passing, with the failures counted.
+	srv.agent.checkLock.Lock()
+	handler := srv.agent.checkHandlers["mysql"]
+	srv.agent.checkLock.Unlock()
+	handler.UpdateCheck("mysql", structs.HealthCritical, "")
+	handler.UpdateCheck("mysql", structs.HealthCritical, "")
+
+	obj, err := srv.AgentChecks(nil, nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	val := obj.(map[types.CheckID]*AgentCheck)
+	if val["mysql"].Status != structs.HealthPassing ||
+		val["mysql"].SuccessCount != 0 || val["mysql"].FailureCount != 2 {
+		t.Fatalf("bad check: %#v", val["mysql"])
+	}
+
+	// The counts are flattened into the check in the JSON.
+	buf, err := json.Marshal(val["mysql"])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(buf, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out["CheckID"] != "mysql" || out["FailureCount"] != float64(2) {
+		t.Fatalf("bad: %s", buf)
+	}
+}
+
 func TestHTTPAgentSelf(t *testing.T) {
 	dir, srv := makeHTTPServer(t)
 	defer os.RemoveAll(dir)
@@ -194,6 +288,63 @@ func TestHTTPAgentReload(t *testing.T) {
 	}
 }
 
+// makeTestAgentACL creates a client token with the given rules using the
+// master token and returns its ID.
+func makeTestAgentACL(t *testing.T, srv *HTTPServer, rules string) string {
+	args := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name:  "User Token",
+			Type:  structs.ACLTypeClient,
+			Rules: rules,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var id string
+	if err := srv.agent.RPC("ACL.Apply", &args, &id); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return id
+}
+
+// denyACLs configures an agent to deny by default.
+func denyACLs(c *Config) {
+	c.ACLDefaultPolicy = "deny"
+}
+
+// enforceAgentACLs turns on the version 8 ACL rules for the agent endpoints.
+// This is done on the agent only, after it's running, so the servers still
+// let the agent register itself in the catalog without a token.
+func enforceAgentACLs(srv *HTTPServer) {
+	srv.agent.config.ACLEnforceVersion8 = Bool(true)
+}
+
+func TestHTTPAgentReload_ACLDeny(t *testing.T) {
+	httpTestWithConfig(t, func(srv *HTTPServer) {
+		enforceAgentACLs(srv)
+
+		// The anonymous token can't reload the agent.
+		req, err := http.NewRequest("PUT", "/v1/agent/reload", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := srv.AgentReload(nil, req); err == nil || !strings.Contains(err.Error(), permissionDenied) {
+			t.Fatalf("err: %v", err)
+		}
+
+		// Neither can a token with read access to the agent.
+		token := makeTestAgentACL(t, srv, `agent "" { policy = "read" }`)
+		req, err = http.NewRequest("PUT", "/v1/agent/reload?token="+token, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := srv.AgentReload(nil, req); err == nil || !strings.Contains(err.Error(), permissionDenied) {
+			t.Fatalf("err: %v", err)
+		}
+	}, denyACLs)
+}
+
 func TestHTTPAgentMembers(t *testing.T) {
 	dir, srv := makeHTTPServer(t)
 	defer os.RemoveAll(dir)
@@ -314,6 +465,117 @@ func TestHTTPAgentJoin_WAN(t *testing.T) {
 	})
 }
 
+func TestHTTPAgentJoin_ACLDeny(t *testing.T) {
+	httpTestWithConfig(t, func(srv *HTTPServer) {
+		dir2, a2 := makeAgent(t, nextConfig())
+		defer os.RemoveAll(dir2)
+		defer a2.Shutdown()
+
+		enforceAgentACLs(srv)
+
+		// The anonymous token can't join.
+		addr := fmt.Sprintf("127.0.0.1:%d", a2.config.Ports.SerfLan)
+		req, err := http.NewRequest("GET", fmt.Sprintf("/v1/agent/join/%s", addr), nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := srv.AgentJoin(nil, req); err == nil || !strings.Contains(err.Error(), permissionDenied) {
+			t.Fatalf("err: %v", err)
+		}
+
+		// A token with write access to the agent can.
+		token := makeTestAgentACL(t, srv,
+			fmt.Sprintf(`agent "%s" { policy = "write" }`, srv.agent.config.NodeName))
+		req, err = http.NewRequest("GET", fmt.Sprintf("/v1/agent/join/%s?token=%s", addr, token), nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := srv.AgentJoin(nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if len(srv.agent.LANMembers()) != 2 {
+			t.Fatalf("should have 2 members")
+		}
+	}, denyACLs)
+}
+
+func TestHTTPAgent_AuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consul")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	httpTestWithConfig(t, func(srv *HTTPServer) {
+		enforceAgentACLs(srv)
+
+		// The anonymous token can't force a node to leave.
+		req, err := http.NewRequest("PUT", "/v1/agent/force-leave/nope", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := srv.AgentForceLeave(nil, req); err == nil || !strings.Contains(err.Error(), permissionDenied) {
+			t.Fatalf("err: %v", err)
+		}
+
+		// A token with write access to the agent can.
+		token := makeTestAgentACL(t, srv,
+			fmt.Sprintf(`agent "%s" { policy = "write" }`, srv.agent.config.NodeName))
+		req, err = http.NewRequest("PUT", "/v1/agent/force-leave/nope?token="+token, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := srv.AgentForceLeave(nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// The server shares the agent's audit log.
+		args := structs.KVSRequest{
+			Datacenter:   "dc1",
+			Op:           structs.KVSSet,
+			DirEnt:       structs.DirEntry{Key: "foo", Value: []byte("bar")},
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		var ok bool
+		if err := srv.agent.RPC("KVS.Apply", &args, &ok); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if strings.Contains(string(buf), token) {
+			t.Fatalf("token leaked: %s", buf)
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+			var entry audit.Entry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			got = append(got, fmt.Sprintf("%s %s %s %s %s", entry.Accessor, entry.Endpoint,
+				entry.ResourceType, entry.Resource, entry.Decision))
+		}
+		node := srv.agent.config.NodeName
+		expected := []string{
+			fmt.Sprintf("anonymous /v1/agent/force-leave/nope agent %s deny", node),
+			fmt.Sprintf("%s /v1/agent/force-leave/nope agent %s allow", audit.Accessor(token), node),
+			fmt.Sprintf("%s KVS.Apply kv foo allow", audit.Accessor("root")),
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("bad: %#v", got)
+		}
+	}, func(c *Config) {
+		denyACLs(c)
+		c.AuditLog = AuditLogConfig{
+			Path:      path,
+			Resources: []string{"agent", "kv"},
+		}
+	})
+}
+
 func TestHTTPAgentLeave(t *testing.T) {
 	dir, srv := makeHTTPServer(t)
 	defer os.RemoveAll(dir)
@@ -357,6 +619,66 @@ func TestHTTPAgentLeave(t *testing.T) {
 	})
 }
 
+func TestHTTPAgentLeave_ACLDeny(t *testing.T) {
+	dir, srv := makeHTTPServerWithConfig(t, denyACLs)
+	defer os.RemoveAll(dir)
+	defer srv.Shutdown()
+	defer srv.agent.Shutdown()
+	testutil.WaitForLeader(t, srv.agent.RPC, "dc1")
+
+	// Use a client agent, which resolves tokens using the servers.
+	dir2, srv2 := makeHTTPServerWithConfig(t, func(c *Config) {
+		denyACLs(c)
+		c.Server = false
+		c.Bootstrap = false
+	})
+	defer os.RemoveAll(dir2)
+	defer srv2.Shutdown()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", srv2.agent.config.Ports.SerfLan)
+	if _, err := srv.agent.JoinLAN([]string{addr}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	enforceAgentACLs(srv2)
+
+	// The anonymous token can't make the agent leave. The client may take
+	// a moment to find out about the server.
+	testutil.WaitForResult(func() (bool, error) {
+		req, err := http.NewRequest("PUT", "/v1/agent/leave", nil)
+		if err != nil {
+			return false, err
+		}
+		_, err = srv2.AgentLeave(nil, req)
+		if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+			return false, fmt.Errorf("err: %v", err)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("%v", err)
+	})
+
+	// Neither can a token for a different agent.
+	token := makeTestAgentACL(t, srv, `agent "nope" { policy = "write" }`)
+	req, err := http.NewRequest("PUT", "/v1/agent/leave?token="+token, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := srv2.AgentLeave(nil, req); err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A token with write access to the client agent can.
+	token = makeTestAgentACL(t, srv,
+		fmt.Sprintf(`agent "%s" { policy = "write" }`, srv2.agent.config.NodeName))
+	req, err = http.NewRequest("PUT", "/v1/agent/leave?token="+token, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := srv2.AgentLeave(nil, req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestHTTPAgentForceLeave(t *testing.T) {
 	dir, srv := makeHTTPServer(t)
 	defer os.RemoveAll(dir)
@@ -399,6 +721,32 @@ func TestHTTPAgentForceLeave(t *testing.T) {
 	})
 }
 
+func TestHTTPAgentForceLeave_ACLDeny(t *testing.T) {
+	httpTestWithConfig(t, func(srv *HTTPServer) {
+		enforceAgentACLs(srv)
+
+		// A token with only read access to the agent can't force a
+		// node to leave.
+		token := makeTestAgentACL(t, srv, `agent "" { policy = "read" }`)
+		req, err := http.NewRequest("GET", "/v1/agent/force-leave/nope?token="+token, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := srv.AgentForceLeave(nil, req); err == nil || !strings.Contains(err.Error(), permissionDenied) {
+			t.Fatalf("err: %v", err)
+		}
+
+		// The master token can.
+		req, err = http.NewRequest("GET", "/v1/agent/force-leave/nope?token=root", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := srv.AgentForceLeave(nil, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}, denyACLs)
+}
+
 func TestHTTPAgentRegisterCheck(t *testing.T) {
 	dir, srv := makeHTTPServer(t)
 	defer os.RemoveAll(dir)
@@ -790,6 +1138,7 @@ func TestHTTPAgentRegisterService(t *testing.T) {
 	args := &ServiceDefinition{
 		Name: "test",
 		Tags: []string{"master"},
+		Meta: map[string]string{"hello": "world"},
 		Port: 8000,
 		Check: CheckType{
 			TTL: 15 * time.Second,
@@ -814,9 +1163,13 @@ func TestHTTPAgentRegisterService(t *testing.T) {
 	}
 
 	// Ensure the servie
-	if _, ok := srv.agent.state.Services()["test"]; !ok {
+	svc, ok := srv.agent.state.Services()["test"]
+	if !ok {
 		t.Fatalf("missing test service")
 	}
+	if svc.Meta["hello"] != "world" {
+		t.Fatalf("bad: %v", svc.Meta)
+	}
 
 	// Ensure we have a check mapping
 	checks := srv.agent.state.Checks()
@@ -834,6 +1187,44 @@ func TestHTTPAgentRegisterService(t *testing.T) {
 	}
 }
 
+func TestHTTPAgentRegisterService_InvalidMeta(t *testing.T) {
+	dir, srv := makeHTTPServer(t)
+	defer os.RemoveAll(dir)
+	defer srv.Shutdown()
+	defer srv.agent.Shutdown()
+
+	req, err := http.NewRequest("GET", "/v1/agent/service/register", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	args := &ServiceDefinition{
+		Name: "test",
+		Meta: map[string]string{"consul-reserved": "value"},
+		Port: 8000,
+	}
+	req.Body = encodeReq(args)
+
+	resp := httptest.NewRecorder()
+	obj, err := srv.AgentRegisterService(resp, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if obj != nil {
+		t.Fatalf("bad: %v", obj)
+	}
+	if resp.Code != 400 {
+		t.Fatalf("expected 400, got %d", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), "Invalid Service Meta") {
+		t.Fatalf("bad: %s", resp.Body.String())
+	}
+
+	// Make sure the service wasn't registered
+	if _, ok := srv.agent.state.Services()["test"]; ok {
+		t.Fatalf("should not have registered the service")
+	}
+}
+
 func TestHTTPAgentDeregisterService(t *testing.T) {
 	dir, srv := makeHTTPServer(t)
 	defer os.RemoveAll(dir)
@@ -1221,6 +1612,39 @@ func TestHTTPAgent_Monitor(t *testing.T) {
 	})
 }
 
+func TestHTTPAgent_Monitor_ACLDeny(t *testing.T) {
+	httpTestWithConfig(t, func(srv *HTTPServer) {
+		enforceAgentACLs(srv)
+
+		// The anonymous token can't read the agent's logs.
+		req, _ := http.NewRequest("GET", "/v1/agent/monitor", nil)
+		resp := newClosableRecorder()
+		if _, err := srv.AgentMonitor(resp, req); err == nil || !strings.Contains(err.Error(), permissionDenied) {
+			t.Fatalf("err: %v", err)
+		}
+
+		// A token with read access to a different agent can't either.
+		token := makeTestAgentACL(t, srv, `agent "nope" { policy = "read" }`)
+		req, _ = http.NewRequest("GET", "/v1/agent/monitor?token="+token, nil)
+		resp = newClosableRecorder()
+		if _, err := srv.AgentMonitor(resp, req); err == nil || !strings.Contains(err.Error(), permissionDenied) {
+			t.Fatalf("err: %v", err)
+		}
+
+		// A token with read access to this agent gets past the ACL check,
+		// which we can see from the log level being checked.
+		token = makeTestAgentACL(t, srv, fmt.Sprintf(`agent "%s" { policy = "read" }`, srv.agent.config.NodeName))
+		req, _ = http.NewRequest("GET", "/v1/agent/monitor?loglevel=invalid&token="+token, nil)
+		resp = newClosableRecorder()
+		if _, err := srv.AgentMonitor(resp, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.Code != 400 {
+			t.Fatalf("bad: %v", resp.Code)
+		}
+	}, denyACLs)
+}
+
 type closableRecorder struct {
 	*httptest.ResponseRecorder
 	closer chan bool
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/logger"
 	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/raft"
 )
 
@@ -159,6 +161,71 @@ func TestAgentStartStop(t *testing.T) {
 	}
 }
 
+func TestAgent_NodeID(t *testing.T) {
+	c := nextConfig()
+	dir, agent := makeAgent(t, c)
+	defer os.RemoveAll(dir)
+	defer agent.Shutdown()
+
+	// The auto-assigned ID should be valid.
+	id := agent.config.NodeID
+	if _, err := uuid.ParseUUID(id); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Running again should get the same ID (persisted in the file).
+	c.NodeID = ""
+	if err := agent.setupNodeID(c); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if newID := agent.config.NodeID; id != newID {
+		t.Fatalf("bad: %q vs. %q", id, newID)
+	}
+
+	// Set an invalid ID via config.
+	c.NodeID = "nope"
+	err := agent.setupNodeID(c)
+	if err == nil || !strings.Contains(err.Error(), "invalid node ID") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Set a valid ID via config.
+	newID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.NodeID = newID
+	if err := agent.setupNodeID(c); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if id := agent.config.NodeID; id != newID {
+		t.Fatalf("bad: %q vs. %q", id, newID)
+	}
+
+	// Set an invalid ID via the file.
+	fileID := filepath.Join(c.DataDir, nodeIDFile)
+	if err := ioutil.WriteFile(fileID, []byte("adf4238a!882b!9ddc!4a9d!5b6758e4159e"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.NodeID = ""
+	err = agent.setupNodeID(c)
+	if err == nil || !strings.Contains(err.Error(), "invalid node ID") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Set a valid ID via the file.
+	if err := ioutil.WriteFile(fileID, []byte("adf4238a-882b-9ddc-4a9d-5b6758e4159e"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c.NodeID = ""
+	if err := agent.setupNodeID(c); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if id := agent.config.NodeID; id != "adf4238a-882b-9ddc-4a9d-5b6758e4159e" {
+		t.Fatalf("bad: %q", id)
+	}
+}
+
 func TestAgent_RPCPing(t *testing.T) {
 	dir, agent := makeAgent(t, nextConfig())
 	defer os.RemoveAll(dir)
@@ -503,6 +570,54 @@ func TestAgent_RemoveService(t *testing.T) {
 	}
 }
 
+func TestAgent_AddService_InvalidMeta(t *testing.T) {
+	dir, agent := makeAgent(t, nextConfig())
+	defer os.RemoveAll(dir)
+	defer agent.Shutdown()
+
+	srv := &structs.NodeService{
+		ID:      "redis",
+		Service: "redis",
+		Meta:    map[string]string{"consul-version": "1.2.3"},
+		Port:    8000,
+	}
+	err := agent.AddService(srv, nil, false, "")
+	if err == nil || !strings.Contains(err.Error(), "Invalid service metadata") {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := agent.state.Services()["redis"]; ok {
+		t.Fatalf("should not have registered the service")
+	}
+}
+
+func TestAgent_AddService_InvalidWeights(t *testing.T) {
+	dir, agent := makeAgent(t, nextConfig())
+	defer os.RemoveAll(dir)
+	defer agent.Shutdown()
+
+	srv := &structs.NodeService{
+		ID:      "redis",
+		Service: "redis",
+		Port:    8000,
+		Weights: &structs.Weights{Passing: 0, Warning: 1},
+	}
+	err := agent.AddService(srv, nil, false, "")
+	if err == nil || !strings.Contains(err.Error(), "Invalid service weights") {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := agent.state.Services()["redis"]; ok {
+		t.Fatalf("should not have registered the service")
+	}
+
+	srv.Weights = &structs.Weights{Passing: 10, Warning: 1}
+	if err := agent.AddService(srv, nil, false, ""); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if w := agent.state.Services()["redis"].Weights; w == nil || w.Passing != 10 {
+		t.Fatalf("bad: %#v", w)
+	}
+}
+
 func TestAgent_AddCheck(t *testing.T) {
 	dir, agent := makeAgent(t, nextConfig())
 	defer os.RemoveAll(dir)
@@ -610,6 +725,98 @@ func TestAgent_AddCheck_MinInterval(t *testing.T) {
 	}
 }
 
+func TestAgent_AddCheck_HTTPExpected(t *testing.T) {
+	dir, agent := makeAgent(t, nextConfig())
+	defer os.RemoveAll(dir)
+	defer agent.Shutdown()
+
+	health := &structs.HealthCheck{
+		Node:    "foo",
+		CheckID: "web",
+		Name:    "web health",
+		Status:  structs.HealthCritical,
+	}
+
+	// Bad expectations are rejected
+	chk := &CheckType{
+		HTTP:              "http://127.0.0.1:8080/health",
+		Interval:          10 * time.Second,
+		ExpectedBodyRegex: "[",
+	}
+	if err := agent.AddCheck(health, chk, false, ""); err == nil {
+		t.Fatalf("should have failed")
+	}
+	chk = &CheckType{
+		HTTP:              "http://127.0.0.1:8080/health",
+		Interval:          10 * time.Second,
+		ExpectedStatusMin: 300,
+		ExpectedStatusMax: 200,
+	}
+	if err := agent.AddCheck(health, chk, false, ""); err == nil {
+		t.Fatalf("should have failed")
+	}
+
+	chk = &CheckType{
+		HTTP:              "http://127.0.0.1:8080/health",
+		Method:            "POST",
+		Header:            map[string][]string{"Authorization": []string{"Bearer secret"}},
+		Body:              "ping",
+		Interval:          10 * time.Second,
+		ExpectedStatusMin: 200,
+		ExpectedStatusMax: 399,
+		ExpectedBodyRegex: "^ok",
+	}
+	if err := agent.AddCheck(health, chk, false, ""); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	http, ok := agent.checkHTTPs["web"]
+	if !ok {
+		t.Fatalf("missing http check")
+	}
+	if http.Method != "POST" || http.Body != "ping" ||
+		http.Header["Authorization"][0] != "Bearer secret" ||
+		http.ExpectedStatusMin != 200 || http.ExpectedStatusMax != 399 ||
+		http.ExpectedBodyRegex.String() != "^ok" {
+		t.Fatalf("bad: %#v", http)
+	}
+}
+
+func TestAgent_AddCheck_GRPC(t *testing.T) {
+	dir, agent := makeAgent(t, nextConfig())
+	defer os.RemoveAll(dir)
+	defer agent.Shutdown()
+
+	health := &structs.HealthCheck{
+		Node:    "foo",
+		CheckID: "grpc",
+		Name:    "grpc health",
+		Status:  structs.HealthCritical,
+	}
+	chk := &CheckType{
+		GRPC:       "127.0.0.1:9090/web",
+		GRPCUseTLS: true,
+		Interval:   time.Microsecond,
+	}
+	if err := agent.AddCheck(health, chk, false, ""); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Ensure the check is set up with the minimum interval
+	if grpc, ok := agent.checkGRPCs["grpc"]; !ok {
+		t.Fatalf("missing grpc check")
+	} else if grpc.Interval != MinInterval || !grpc.TLS || grpc.GRPC != "127.0.0.1:9090/web" {
+		t.Fatalf("bad: %#v", grpc)
+	}
+
+	// Removing the check stops it
+	if err := agent.RemoveCheck("grpc", false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := agent.checkGRPCs["grpc"]; ok {
+		t.Fatalf("should have removed grpc check")
+	}
+}
+
 func TestAgent_AddCheck_MissingService(t *testing.T) {
 	dir, agent := makeAgent(t, nextConfig())
 	defer os.RemoveAll(dir)
@@ -674,6 +881,81 @@ func TestAgent_AddCheck_RestoreState(t *testing.T) {
 	}
 }
 
+func TestAgent_AddCheck_Alias(t *testing.T) {
+	dir, agent := makeAgent(t, nextConfig())
+	defer os.RemoveAll(dir)
+	defer agent.Shutdown()
+
+	testutil.WaitForLeader(t, agent.RPC, "dc1")
+
+	// Register a service on another node, with a failing check.
+	reg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "remote",
+		Address:    "127.0.0.2",
+		Service: &structs.NodeService{
+			ID:      "web1",
+			Service: "web",
+		},
+		Check: &structs.HealthCheck{
+			Node:      "remote",
+			CheckID:   "web1-health",
+			Name:      "web1 health",
+			Status:    structs.HealthCritical,
+			ServiceID: "web1",
+		},
+	}
+	var out struct{}
+	if err := agent.RPC("Catalog.Register", &reg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	health := &structs.HealthCheck{
+		Node:    agent.config.NodeName,
+		CheckID: "web-alias",
+		Name:    "web alias",
+		Status:  structs.HealthPassing,
+	}
+	chk := &CheckType{
+		AliasNode:    "remote",
+		AliasService: "web1",
+	}
+	if err := agent.AddCheck(health, chk, false, ""); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := agent.checkAliases["web-alias"]; !ok {
+		t.Fatalf("missing alias check")
+	}
+
+	expect := func(status string) {
+		testutil.WaitForResult(func() (bool, error) {
+			check := agent.state.Checks()["web-alias"]
+			if check.Status != status {
+				return false, fmt.Errorf("bad: %#v", check)
+			}
+			return true, nil
+		}, func(err error) {
+			t.Fatalf("err: %v", err)
+		})
+	}
+
+	// The alias should follow the service's check.
+	expect(structs.HealthCritical)
+	reg.Check.Status = structs.HealthPassing
+	if err := agent.RPC("Catalog.Register", &reg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expect(structs.HealthPassing)
+
+	// Removing the check stops the alias.
+	if err := agent.RemoveCheck("web-alias", false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := agent.checkAliases["web-alias"]; ok {
+		t.Fatalf("should have removed alias check")
+	}
+}
+
 func TestAgent_RemoveCheck(t *testing.T) {
 	dir, agent := makeAgent(t, nextConfig())
 	defer os.RemoveAll(dir)
@@ -1331,6 +1613,34 @@ func TestAgent_unloadServices(t *testing.T) {
 	}
 }
 
+func TestAgent_loadMetadata(t *testing.T) {
+	config := nextConfig()
+	config.Meta = map[string]string{
+		"key1": "value1",
+		"key2": "value2",
+	}
+	dir, agent := makeAgent(t, config)
+	defer os.RemoveAll(dir)
+	defer agent.Shutdown()
+
+	meta := agent.state.Metadata()
+	if !reflect.DeepEqual(meta, config.Meta) {
+		t.Fatalf("bad: %v", meta)
+	}
+
+	// Unloading should clear the metadata
+	agent.unloadMetadata()
+	if meta := agent.state.Metadata(); len(meta) != 0 {
+		t.Fatalf("bad: %v", meta)
+	}
+
+	// Invalid metadata should be rejected
+	config.Meta["consul-reserved"] = "value"
+	if err := agent.loadMetadata(config); err == nil {
+		t.Fatalf("should have failed")
+	}
+}
+
 func TestAgent_Service_MaintenanceMode(t *testing.T) {
 	config := nextConfig()
 	dir, agent := makeAgent(t, config)
@@ -1,14 +1,20 @@
 package agent
 
 import (
+	"bytes"
 	"crypto/tls"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"regexp"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -19,6 +25,7 @@ import (
 	"github.com/hashicorp/consul/lib"
 	"github.com/hashicorp/consul/types"
 	"github.com/hashicorp/go-cleanhttp"
+	"golang.org/x/net/http2"
 )
 
 const (
@@ -34,13 +41,21 @@ const (
 	// Use this user agent when doing requests for
 	// HTTP health checks.
 	HttpUserAgent = "Consul Health Check"
+
+	// aliasBlockingQueryTime is how long an alias check waits for its
+	// target to change before querying again.
+	aliasBlockingQueryTime = 5 * time.Minute
+
+	// aliasRetryInterval is how long an alias check waits before trying
+	// again after a failed query.
+	aliasRetryInterval = 10 * time.Second
 )
 
 // CheckType is used to create either the CheckMonitor or the CheckTTL.
-// Five types are supported: Script, HTTP, TCP, Docker and TTL. Script, HTTP,
-// Docker and TCP all require Interval. Only one of the types may to be
-// provided: TTL or Script/Interval or HTTP/Interval or TCP/Interval or
-// Docker/Interval.
+// Seven types are supported: Script, HTTP, TCP, gRPC, Docker, TTL and Alias.
+// Script, HTTP, gRPC, Docker and TCP all require Interval. Only one of the
+// types may to be provided: TTL or Script/Interval or HTTP/Interval or
+// TCP/Interval or GRPC/Interval or Docker/Interval or AliasNode/AliasService.
 type CheckType struct {
 	Script            string
 	HTTP              string
@@ -50,6 +65,35 @@ type CheckType struct {
 	Shell             string
 	TLSSkipVerify     bool
 
+	// Method, Header and Body customize the request made by an HTTP
+	// check, which is otherwise a GET with no body.
+	Method string
+	Header map[string][]string
+	Body   string
+
+	// ExpectedStatusMin and ExpectedStatusMax, if set, are the inclusive
+	// range of response codes that make an HTTP check pass, instead of
+	// any 2xx code. If only one is set, the response code must be exactly
+	// that. ExpectedBody and ExpectedBodyRegex also require the response
+	// body to contain the given string or match the given regular
+	// expression for the check to pass.
+	ExpectedStatusMin int
+	ExpectedStatusMax int
+	ExpectedBody      string
+	ExpectedBodyRegex string
+
+	// GRPC is the address of a server implementing the gRPC health checking
+	// protocol, optionally followed by a slash and the name of the service
+	// to check. GRPCUseTLS connects to it using TLS.
+	GRPC       string
+	GRPCUseTLS bool
+
+	// AliasNode and AliasService make the check mirror the health of
+	// another node, or of a service instance on a node. If only the
+	// service is given, it's looked up on the local node.
+	AliasNode    string
+	AliasService string
+
 	Timeout time.Duration
 	TTL     time.Duration
 
@@ -58,6 +102,13 @@ type CheckType struct {
 	// longer than this duration.
 	DeregisterCriticalServiceAfter time.Duration
 
+	// SuccessBeforePassing and FailuresBeforeCritical, if >0, are the
+	// number of consecutive passing or critical results needed before the
+	// check's status changes. This keeps a single slow or failed run from
+	// flapping the check.
+	SuccessBeforePassing   int
+	FailuresBeforeCritical int
+
 	Status string
 
 	Notes string
@@ -66,7 +117,7 @@ type CheckTypes []*CheckType
 
 // Valid checks if the CheckType is valid
 func (c *CheckType) Valid() bool {
-	return c.IsTTL() || c.IsMonitor() || c.IsHTTP() || c.IsTCP() || c.IsDocker()
+	return c.IsTTL() || c.IsMonitor() || c.IsHTTP() || c.IsTCP() || c.IsGRPC() || c.IsDocker() || c.IsAlias()
 }
 
 // IsTTL checks if this is a TTL type
@@ -89,10 +140,20 @@ func (c *CheckType) IsTCP() bool {
 	return c.TCP != "" && c.Interval != 0
 }
 
+// IsGRPC checks if this is a gRPC type
+func (c *CheckType) IsGRPC() bool {
+	return c.GRPC != "" && c.Interval != 0
+}
+
 func (c *CheckType) IsDocker() bool {
 	return c.DockerContainerID != "" && c.Script != "" && c.Interval != 0
 }
 
+// IsAlias checks if this is an Alias type
+func (c *CheckType) IsAlias() bool {
+	return c.AliasNode != "" || c.AliasService != ""
+}
+
 // CheckNotifier interface is used by the CheckMonitor
 // to notify when a check has a status update. The update
 // should take care to be idempotent.
@@ -100,6 +161,69 @@ type CheckNotifier interface {
 	UpdateCheck(checkID types.CheckID, status, output string)
 }
 
+// StatusHandler sits between a check and its CheckNotifier, and only passes
+// on results once enough consecutive runs agree. Passing and warning results
+// count as successes, and critical results count as failures. It's safe to
+// use from multiple goroutines.
+type StatusHandler struct {
+	inner                  CheckNotifier
+	logger                 *log.Logger
+	successBeforePassing   int
+	failuresBeforeCritical int
+
+	successCounter  int
+	failuresCounter int
+	lock            sync.Mutex
+}
+
+// NewStatusHandler returns a StatusHandler that notifies inner once there
+// have been successBeforePassing successes or failuresBeforeCritical failures
+// in a row. Thresholds of 0 or 1 pass every result on right away.
+func NewStatusHandler(inner CheckNotifier, logger *log.Logger, successBeforePassing, failuresBeforeCritical int) *StatusHandler {
+	return &StatusHandler{
+		inner:                  inner,
+		logger:                 logger,
+		successBeforePassing:   successBeforePassing,
+		failuresBeforeCritical: failuresBeforeCritical,
+	}
+}
+
+// UpdateCheck is used to count the result and pass it on if the threshold
+// for its status has been reached.
+func (s *StatusHandler) UpdateCheck(checkID types.CheckID, status, output string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if status == structs.HealthPassing || status == structs.HealthWarning {
+		s.successCounter++
+		s.failuresCounter = 0
+		if s.successCounter >= s.successBeforePassing {
+			s.inner.UpdateCheck(checkID, status, output)
+			return
+		}
+		s.logger.Printf("[WARN] agent: check '%v' was %s, but has only %d of %d successes needed to update",
+			checkID, status, s.successCounter, s.successBeforePassing)
+	} else {
+		s.failuresCounter++
+		s.successCounter = 0
+		if s.failuresCounter >= s.failuresBeforeCritical {
+			s.inner.UpdateCheck(checkID, status, output)
+			return
+		}
+		s.logger.Printf("[WARN] agent: check '%v' was %s, but has only %d of %d failures needed to update",
+			checkID, status, s.failuresCounter, s.failuresBeforeCritical)
+	}
+}
+
+// Counts returns the number of consecutive successes and failures seen so
+// far. At most one of them is non-zero.
+func (s *StatusHandler) Counts() (successes, failures int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.successCounter, s.failuresCounter
+}
+
 // CheckMonitor is used to periodically invoke a script to
 // determine the health of a given check. It is compatible with
 // nagios plugins and expects the output in the same format.
@@ -330,7 +454,8 @@ type persistedCheckState struct {
 
 // CheckHTTP is used to periodically make an HTTP request to
 // determine the health of a given check.
-// The check is passing if the response code is 2XX.
+// The check is passing if the response code is 2XX, or within the
+// expected range if one is given, and the body matches any expectations.
 // The check is warning if the response code is 429.
 // The check is critical if the response code is anything else
 // or if the request returns an error
@@ -338,11 +463,19 @@ type CheckHTTP struct {
 	Notify        CheckNotifier
 	CheckID       types.CheckID
 	HTTP          string
+	Method        string
+	Header        map[string][]string
+	Body          string
 	Interval      time.Duration
 	Timeout       time.Duration
 	Logger        *log.Logger
 	TLSSkipVerify bool
 
+	ExpectedStatusMin int
+	ExpectedStatusMax int
+	ExpectedBody      string
+	ExpectedBodyRegex *regexp.Regexp
+
 	httpClient *http.Client
 	stop       bool
 	stopCh     chan struct{}
@@ -420,7 +553,16 @@ func (c *CheckHTTP) run() {
 
 // check is invoked periodically to perform the HTTP check
 func (c *CheckHTTP) check() {
-	req, err := http.NewRequest("GET", c.HTTP, nil)
+	method := c.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	var body io.Reader
+	if c.Body != "" {
+		body = strings.NewReader(c.Body)
+	}
+	req, err := http.NewRequest(method, c.HTTP, body)
 	if err != nil {
 		c.Logger.Printf("[WARN] agent: http request failed '%s': %s", c.HTTP, err)
 		c.Notify.UpdateCheck(c.CheckID, structs.HealthCritical, err.Error())
@@ -430,6 +572,18 @@ func (c *CheckHTTP) check() {
 	req.Header.Set("User-Agent", HttpUserAgent)
 	req.Header.Set("Accept", "text/plain, text/*, */*")
 
+	// Custom headers replace the defaults. The Host header has to be set
+	// on the request itself, since it's ignored in the header map.
+	for k, v := range c.Header {
+		if strings.EqualFold(k, "Host") {
+			if len(v) > 0 {
+				req.Host = v[0]
+			}
+			continue
+		}
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		c.Logger.Printf("[WARN] agent: http request failed '%s': %s", c.HTTP, err)
@@ -438,17 +592,33 @@ func (c *CheckHTTP) check() {
 	}
 	defer resp.Body.Close()
 
-	// Read the response into a circular buffer to limit the size
+	// Read the response into a circular buffer to limit the size. If the
+	// body has to match, the whole of it is kept for that as well.
 	output, _ := circbuf.NewBuffer(CheckBufSize)
-	if _, err := io.Copy(output, resp.Body); err != nil {
+	var full bytes.Buffer
+	var w io.Writer = output
+	if c.ExpectedBody != "" || c.ExpectedBodyRegex != nil {
+		w = io.MultiWriter(output, &full)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
 		c.Logger.Printf("[WARN] agent: check '%v': Get error while reading body: %s", c.CheckID, err)
 	}
 
 	// Format the response body
-	result := fmt.Sprintf("HTTP GET %s: %s Output: %s", c.HTTP, resp.Status, output.String())
+	result := fmt.Sprintf("HTTP %s %s: %s Output: %s", method, c.HTTP, resp.Status, output.String())
+	if output.TotalWritten() > output.Size() {
+		result += " (truncated)"
+	}
 
-	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
-		// PASSING (2xx)
+	if c.statusExpected(resp.StatusCode) {
+		if !c.bodyExpected(full.Bytes()) {
+			c.Logger.Printf("[WARN] agent: check '%v' is now critical", c.CheckID)
+			c.Notify.UpdateCheck(c.CheckID, structs.HealthCritical,
+				fmt.Sprintf("HTTP %s %s: %s Response body did not match Output: %s", method, c.HTTP, resp.Status, output.String()))
+			return
+		}
+
+		// PASSING (2xx, or the expected status)
 		c.Logger.Printf("[DEBUG] agent: check '%v' is passing", c.CheckID)
 		c.Notify.UpdateCheck(c.CheckID, structs.HealthPassing, result)
 
@@ -466,6 +636,33 @@ func (c *CheckHTTP) check() {
 	}
 }
 
+// statusExpected returns whether the given response code should make the
+// check pass.
+func (c *CheckHTTP) statusExpected(code int) bool {
+	min, max := c.ExpectedStatusMin, c.ExpectedStatusMax
+	switch {
+	case min == 0 && max == 0:
+		min, max = 200, 299
+	case min == 0:
+		min = max
+	case max == 0:
+		max = min
+	}
+	return code >= min && code <= max
+}
+
+// bodyExpected returns whether the given response body satisfies the
+// check's expected body, if any.
+func (c *CheckHTTP) bodyExpected(body []byte) bool {
+	if c.ExpectedBody != "" && !bytes.Contains(body, []byte(c.ExpectedBody)) {
+		return false
+	}
+	if c.ExpectedBodyRegex != nil && !c.ExpectedBodyRegex.Match(body) {
+		return false
+	}
+	return true
+}
+
 // CheckTCP is used to periodically make an TCP/UDP connection to
 // determine the health of a given check.
 // The check is passing if the connection succeeds
@@ -549,6 +746,449 @@ func (c *CheckTCP) check() {
 	c.Notify.UpdateCheck(c.CheckID, structs.HealthPassing, fmt.Sprintf("TCP connect %s: Success", c.TCP))
 }
 
+// gRPC health checking protocol serving statuses, from the
+// grpc.health.v1.HealthCheckResponse message.
+const (
+	grpcHealthUnknown        = 0
+	grpcHealthServing        = 1
+	grpcHealthNotServing     = 2
+	grpcHealthServiceUnknown = 3
+)
+
+// grpcHealthStatusNames are used to describe the serving statuses in the
+// check output.
+var grpcHealthStatusNames = map[uint64]string{
+	grpcHealthUnknown:        "UNKNOWN",
+	grpcHealthServing:        "SERVING",
+	grpcHealthNotServing:     "NOT_SERVING",
+	grpcHealthServiceUnknown: "SERVICE_UNKNOWN",
+}
+
+// CheckGRPC is used to periodically call the grpc.health.v1.Health/Check
+// method of a gRPC server to determine the health of a given check.
+// The check is passing if the server reports the service as SERVING.
+// The check is critical if the server reports any other status, or if
+// the call fails.
+type CheckGRPC struct {
+	Notify        CheckNotifier
+	CheckID       types.CheckID
+	GRPC          string
+	Interval      time.Duration
+	Timeout       time.Duration
+	Logger        *log.Logger
+	TLS           bool
+	TLSSkipVerify bool
+
+	url        string
+	request    []byte
+	transport  *http2.Transport
+	httpClient *http.Client
+	stop       bool
+	stopCh     chan struct{}
+	stopLock   sync.Mutex
+}
+
+// Start is used to start a gRPC check.
+// The check runs until stop is called
+func (c *CheckGRPC) Start() {
+	c.stopLock.Lock()
+	defer c.stopLock.Unlock()
+
+	if c.httpClient == nil {
+		// Split off the service name, if one was given, and encode the
+		// request once since it never changes.
+		addr, service := c.GRPC, ""
+		if idx := strings.Index(addr, "/"); idx != -1 {
+			addr, service = addr[:idx], addr[idx+1:]
+		}
+		scheme := "http"
+		if c.TLS {
+			scheme = "https"
+		}
+		c.url = fmt.Sprintf("%s://%s/grpc.health.v1.Health/Check", scheme, addr)
+		c.request = grpcHealthRequest(service)
+
+		// For long (>10s) interval checks the timeout is 10s, otherwise the
+		// timeout is the interval. This means that a check *should* return
+		// before the next check begins.
+		timeout := 10 * time.Second
+		if c.Timeout > 0 && c.Timeout < c.Interval {
+			timeout = c.Timeout
+		} else if c.Interval < 10*time.Second {
+			timeout = c.Interval
+		}
+
+		// gRPC runs over HTTP/2, without TLS unless it's been asked for.
+		// net/http only speaks HTTP/2 over TLS, so we use the HTTP/2
+		// transport directly, and hand it plain TCP connections when TLS
+		// isn't being used.
+		dialer := &net.Dialer{Timeout: timeout}
+		c.transport = &http2.Transport{
+			AllowHTTP: !c.TLS,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: c.TLSSkipVerify,
+			},
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				if !c.TLS {
+					return dialer.Dial(network, addr)
+				}
+				return tls.DialWithDialer(dialer, network, addr, cfg)
+			},
+		}
+
+		// Create the HTTP client.
+		c.httpClient = &http.Client{
+			Timeout:   timeout,
+			Transport: c.transport,
+		}
+	}
+
+	c.stop = false
+	c.stopCh = make(chan struct{})
+	go c.run()
+}
+
+// Stop is used to stop a gRPC check.
+func (c *CheckGRPC) Stop() {
+	c.stopLock.Lock()
+	defer c.stopLock.Unlock()
+	if !c.stop {
+		c.stop = true
+		close(c.stopCh)
+	}
+}
+
+// run is invoked by a goroutine to run until Stop() is called
+func (c *CheckGRPC) run() {
+	// Get the randomized initial pause time
+	initialPauseTime := lib.RandomStagger(c.Interval)
+	c.Logger.Printf("[DEBUG] agent: pausing %v before first gRPC request of %s", initialPauseTime, c.GRPC)
+	next := time.After(initialPauseTime)
+	for {
+		select {
+		case <-next:
+			c.check()
+			next = time.After(c.Interval)
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// check is invoked periodically to perform the gRPC check
+func (c *CheckGRPC) check() {
+	status, err := c.call()
+	if err != nil {
+		c.Logger.Printf("[WARN] agent: gRPC health check failed '%s': %s", c.GRPC, err)
+		c.Notify.UpdateCheck(c.CheckID, structs.HealthCritical,
+			fmt.Sprintf("gRPC check %s: %s", c.GRPC, err))
+		return
+	}
+
+	name, ok := grpcHealthStatusNames[status]
+	if !ok {
+		name = fmt.Sprintf("unknown status %d", status)
+	}
+	result := fmt.Sprintf("gRPC check %s: %s", c.GRPC, name)
+	if status == grpcHealthServing {
+		c.Logger.Printf("[DEBUG] agent: check '%v' is passing", c.CheckID)
+		c.Notify.UpdateCheck(c.CheckID, structs.HealthPassing, result)
+	} else {
+		c.Logger.Printf("[WARN] agent: check '%v' is now critical", c.CheckID)
+		c.Notify.UpdateCheck(c.CheckID, structs.HealthCritical, result)
+	}
+}
+
+// call makes the health check call and returns the serving status.
+func (c *CheckGRPC) call() (uint64, error) {
+	req, err := http.NewRequest("POST", c.url, bytes.NewReader(c.request))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("User-Agent", HttpUserAgent)
+
+	// We don't keep connections open between calls to prevent failing
+	// checks due to the server closing idle connections.
+	defer c.transport.CloseIdleConnections()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	// The trailers are only available once the body has been read. The
+	// response is tiny, so anything bigger than the check buffer is bogus.
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, CheckBufSize+1))
+	if err != nil {
+		return 0, err
+	}
+	if len(body) > CheckBufSize {
+		return 0, fmt.Errorf("response is too large")
+	}
+
+	// The call's status is normally in the trailers, but servers send it
+	// in the headers if there's no response message.
+	code, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if code != "0" {
+		if unescaped, err := url.QueryUnescape(message); err == nil {
+			message = unescaped
+		}
+		return 0, fmt.Errorf("call failed with code %s: %s", code, message)
+	}
+	return grpcHealthResponse(body)
+}
+
+// grpcHealthRequest returns the framed grpc.health.v1.HealthCheckRequest
+// message for the given service.
+func grpcHealthRequest(service string) []byte {
+	var msg []byte
+	if service != "" {
+		// Field 1, length-delimited.
+		msg = append(msg, 0x0a)
+		msg = appendUvarint(msg, uint64(len(service)))
+		msg = append(msg, service...)
+	}
+
+	// Each message is prefixed with an uncompressed flag and its length.
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// grpcHealthResponse decodes the serving status from a framed
+// grpc.health.v1.HealthCheckResponse message.
+func grpcHealthResponse(body []byte) (uint64, error) {
+	if len(body) < 5 {
+		return 0, fmt.Errorf("response is too short")
+	}
+	if body[0] != 0 {
+		return 0, fmt.Errorf("compressed responses are not supported")
+	}
+	size := binary.BigEndian.Uint32(body[1:5])
+	msg := body[5:]
+	if uint32(len(msg)) != size {
+		return 0, fmt.Errorf("response has the wrong length")
+	}
+
+	// Unset fields aren't sent, so the status starts off as UNKNOWN.
+	var status uint64
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, fmt.Errorf("malformed response")
+		}
+		msg = msg[n:]
+
+		switch key & 0x7 {
+		case 0:
+			value, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, fmt.Errorf("malformed response")
+			}
+			msg = msg[n:]
+			if key>>3 == 1 {
+				status = value
+			}
+		case 2:
+			length, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < length {
+				return 0, fmt.Errorf("malformed response")
+			}
+			msg = msg[n+int(length):]
+		default:
+			return 0, fmt.Errorf("malformed response")
+		}
+	}
+	return status, nil
+}
+
+// appendUvarint appends the protobuf varint encoding of v to buf.
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// CheckRPC is the interface used by checks that need to query the servers.
+type CheckRPC interface {
+	RPC(method string, args interface{}, reply interface{}) error
+}
+
+// CheckAlias is used to mirror the health of another node, or of a service
+// instance on a node, using a blocking query against the catalog. The check
+// is critical if any of the node's checks, or the service's checks, are
+// critical, warning if any are warning, and passing otherwise. Node-level
+// checks count for services too, since a service on a failing node isn't
+// healthy either. A missing service is critical.
+type CheckAlias struct {
+	Notify       CheckNotifier
+	RPC          CheckRPC
+	CheckID      types.CheckID
+	Datacenter   string
+	Node         string
+	LocalNode    string
+	ServiceID    string
+	Token        string
+	Logger       *log.Logger
+	QueryTime    time.Duration
+	RetryBackoff time.Duration
+
+	stop     bool
+	stopCh   chan struct{}
+	stopLock sync.Mutex
+}
+
+// Start is used to start an alias check.
+// The check runs until stop is called
+func (c *CheckAlias) Start() {
+	c.stopLock.Lock()
+	defer c.stopLock.Unlock()
+	if c.QueryTime == 0 {
+		c.QueryTime = aliasBlockingQueryTime
+	}
+	if c.RetryBackoff == 0 {
+		c.RetryBackoff = aliasRetryInterval
+	}
+	c.stop = false
+	c.stopCh = make(chan struct{})
+	go c.run(c.stopCh)
+}
+
+// Stop is used to stop an alias check. A query that is in flight will
+// finish, but its result is thrown away.
+func (c *CheckAlias) Stop() {
+	c.stopLock.Lock()
+	defer c.stopLock.Unlock()
+	if !c.stop {
+		c.stop = true
+		close(c.stopCh)
+	}
+}
+
+// stopped returns true if the check has been stopped.
+func (c *CheckAlias) stopped(stopCh chan struct{}) bool {
+	select {
+	case <-stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// run is invoked by a goroutine to run until Stop() is called
+func (c *CheckAlias) run(stopCh chan struct{}) {
+	args := structs.NodeSpecificRequest{
+		Datacenter: c.Datacenter,
+		Node:       c.Node,
+		QueryOptions: structs.QueryOptions{
+			Token:        c.Token,
+			MaxQueryTime: c.QueryTime,
+		},
+	}
+	for !c.stopped(stopCh) {
+		var out structs.IndexedHealthChecks
+		if err := c.RPC.RPC("Health.NodeChecks", &args, &out); err != nil {
+			c.Logger.Printf("[WARN] agent: alias check '%v' failed to query node '%s': %s",
+				c.CheckID, c.Node, err)
+			c.Notify.UpdateCheck(c.CheckID, structs.HealthCritical,
+				fmt.Sprintf("Failed to query health of node %q: %s", c.Node, err))
+
+			args.MinQueryIndex = 0
+			select {
+			case <-time.After(c.RetryBackoff):
+			case <-stopCh:
+				return
+			}
+			continue
+		}
+
+		// Skip the update if we were stopped while the query was
+		// blocked, since the check may have been removed.
+		if c.stopped(stopCh) {
+			return
+		}
+		status, output, err := c.status(out.HealthChecks)
+		if err != nil {
+			c.Logger.Printf("[WARN] agent: alias check '%v' failed: %s", c.CheckID, err)
+			status, output = structs.HealthCritical, err.Error()
+		}
+		c.Notify.UpdateCheck(c.CheckID, status, output)
+
+		// Guard against the index going backwards, which can happen
+		// after a snapshot restore.
+		if out.Index < args.MinQueryIndex {
+			args.MinQueryIndex = 0
+		} else {
+			args.MinQueryIndex = out.Index
+		}
+	}
+}
+
+// status works out the check's status from the aliased node's checks.
+func (c *CheckAlias) status(checks structs.HealthChecks) (string, string, error) {
+	status, output := structs.HealthPassing, "All checks passing"
+	if len(checks) == 0 {
+		output = "No checks found"
+	}
+
+	found := false
+	for _, check := range checks {
+		// Skip the alias check itself, which shows up among the checks
+		// when aliasing the local node. Otherwise its own critical status
+		// when it starts would keep it critical forever. A check on
+		// another node can share our ID, such as the service:<id> check of
+		// a service with the same ID, so the node has to match too.
+		if check.Node == c.LocalNode && check.CheckID == c.CheckID {
+			continue
+		}
+		if c.ServiceID != "" && check.ServiceID != "" && check.ServiceID != c.ServiceID {
+			continue
+		}
+		if c.ServiceID != "" && check.ServiceID == c.ServiceID {
+			found = true
+		}
+
+		switch check.Status {
+		case structs.HealthCritical:
+			return structs.HealthCritical,
+				fmt.Sprintf("Aliased check %q is critical: %s", check.Name, check.Output), nil
+		case structs.HealthWarning:
+			if status == structs.HealthPassing {
+				status = structs.HealthWarning
+				output = fmt.Sprintf("Aliased check %q is warning: %s", check.Name, check.Output)
+			}
+		}
+	}
+
+	// A service without any checks is healthy as long as it exists, so we
+	// need to look it up to tell the difference.
+	if c.ServiceID != "" && !found {
+		args := structs.NodeSpecificRequest{
+			Datacenter:   c.Datacenter,
+			Node:         c.Node,
+			QueryOptions: structs.QueryOptions{Token: c.Token},
+		}
+		var out structs.IndexedNodeServices
+		if err := c.RPC.RPC("Catalog.NodeServices", &args, &out); err != nil {
+			return "", "", fmt.Errorf("Failed to look up service %q: %s", c.ServiceID, err)
+		}
+		if out.NodeServices == nil || out.NodeServices.Services[c.ServiceID] == nil {
+			return structs.HealthCritical,
+				fmt.Sprintf("Service %q not found on node %q", c.ServiceID, c.Node), nil
+		}
+	}
+	return status, output, nil
+}
+
 // A custom interface since go-dockerclient doesn't have one
 // We will use this interface in our test to inject a fake client
 type DockerClient interface {
@@ -2,14 +2,17 @@ package agent
 
 import (
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -18,6 +21,7 @@ import (
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/testutil"
 	"github.com/hashicorp/consul/types"
+	"golang.org/x/net/http2"
 )
 
 type MockNotify struct {
@@ -162,6 +166,260 @@ func TestCheckMonitor_LimitOutput(t *testing.T) {
 	}
 }
 
+func TestStatusHandler(t *testing.T) {
+	mock := &MockNotify{
+		state:   make(map[types.CheckID]string),
+		updates: make(map[types.CheckID]int),
+		output:  make(map[types.CheckID]string),
+	}
+	handler := NewStatusHandler(mock, log.New(os.Stderr, "", log.LstdFlags), 2, 3)
+
+	expect := func(status string, updates, successes, failures int) {
+		if mock.state["foo"] != status || mock.updates["foo"] != updates {
+			t.Fatalf("bad: %v %v", mock.state, mock.updates)
+		}
+		if s, f := handler.Counts(); s != successes || f != failures {
+			t.Fatalf("bad: %d %d", s, f)
+		}
+	}
+
+	// It takes two successes to pass.
+	handler.UpdateCheck("foo", structs.HealthPassing, "")
+	expect("", 0, 1, 0)
+	handler.UpdateCheck("foo", structs.HealthPassing, "")
+	expect(structs.HealthPassing, 1, 2, 0)
+
+	// A couple of failures don't change anything, and a success in between
+	// starts the count over.
+	handler.UpdateCheck("foo", structs.HealthCritical, "")
+	handler.UpdateCheck("foo", structs.HealthCritical, "")
+	expect(structs.HealthPassing, 1, 0, 2)
+	handler.UpdateCheck("foo", structs.HealthPassing, "")
+	expect(structs.HealthPassing, 1, 1, 0)
+
+	// Three in a row go critical.
+	handler.UpdateCheck("foo", structs.HealthCritical, "")
+	handler.UpdateCheck("foo", structs.HealthCritical, "")
+	handler.UpdateCheck("foo", structs.HealthCritical, "")
+	expect(structs.HealthCritical, 2, 0, 3)
+
+	// Warnings count as successes.
+	handler.UpdateCheck("foo", structs.HealthWarning, "")
+	expect(structs.HealthCritical, 2, 1, 0)
+	handler.UpdateCheck("foo", structs.HealthWarning, "")
+	expect(structs.HealthWarning, 3, 2, 0)
+}
+
+func TestStatusHandler_NoThresholds(t *testing.T) {
+	mock := &MockNotify{
+		state:   make(map[types.CheckID]string),
+		updates: make(map[types.CheckID]int),
+		output:  make(map[types.CheckID]string),
+	}
+	handler := NewStatusHandler(mock, log.New(os.Stderr, "", log.LstdFlags), 0, 0)
+
+	// Every result is passed straight through.
+	for i, status := range []string{
+		structs.HealthPassing,
+		structs.HealthCritical,
+		structs.HealthWarning,
+		structs.HealthCritical,
+	} {
+		handler.UpdateCheck("foo", status, "output")
+		if mock.state["foo"] != status || mock.updates["foo"] != i+1 || mock.output["foo"] != "output" {
+			t.Fatalf("bad: %v %v", mock.state, mock.updates)
+		}
+	}
+}
+
+type mockCheckRPC struct {
+	index    uint64
+	checks   structs.HealthChecks
+	services map[string]*structs.NodeService
+	err      error
+}
+
+func (m *mockCheckRPC) RPC(method string, args interface{}, reply interface{}) error {
+	if m.err != nil {
+		return m.err
+	}
+	switch method {
+	case "Health.NodeChecks":
+		// Fake a blocking query that never sees any changes.
+		if args.(*structs.NodeSpecificRequest).MinQueryIndex >= m.index {
+			time.Sleep(10 * time.Millisecond)
+		}
+		out := reply.(*structs.IndexedHealthChecks)
+		out.Index, out.HealthChecks = m.index, m.checks
+	case "Catalog.NodeServices":
+		out := reply.(*structs.IndexedNodeServices)
+		out.Index = m.index
+		out.NodeServices = &structs.NodeServices{
+			Node:     &structs.Node{Node: "node1"},
+			Services: m.services,
+		}
+	default:
+		return fmt.Errorf("unexpected RPC %q", method)
+	}
+	return nil
+}
+
+func TestCheckAlias_status(t *testing.T) {
+	check := func(id, serviceID, status string) *structs.HealthCheck {
+		return &structs.HealthCheck{
+			Node:      "node1",
+			CheckID:   types.CheckID(id),
+			Name:      id,
+			ServiceID: serviceID,
+			Status:    status,
+		}
+	}
+	rpc := &mockCheckRPC{
+		services: map[string]*structs.NodeService{
+			"web1": &structs.NodeService{ID: "web1", Service: "web"},
+			"db1":  &structs.NodeService{ID: "db1", Service: "db"},
+		},
+	}
+	alias := &CheckAlias{
+		RPC:       rpc,
+		CheckID:   "alias",
+		Node:      "node1",
+		LocalNode: "node1",
+	}
+
+	cases := []struct {
+		serviceID string
+		checks    structs.HealthChecks
+		status    string
+	}{
+		// Node aliases look at every check.
+		{"", nil, structs.HealthPassing},
+		{"", structs.HealthChecks{check("a", "", structs.HealthPassing), check("b", "web1", structs.HealthWarning)}, structs.HealthWarning},
+		{"", structs.HealthChecks{check("a", "db1", structs.HealthCritical), check("b", "web1", structs.HealthWarning)}, structs.HealthCritical},
+
+		// Service aliases only look at the service and node checks.
+		{"web1", structs.HealthChecks{check("a", "db1", structs.HealthCritical), check("b", "web1", structs.HealthPassing)}, structs.HealthPassing},
+		{"web1", structs.HealthChecks{check("a", "", structs.HealthWarning), check("b", "web1", structs.HealthPassing)}, structs.HealthWarning},
+		{"web1", structs.HealthChecks{check("a", "", structs.HealthPassing), check("b", "web1", structs.HealthCritical)}, structs.HealthCritical},
+
+		// The alias check itself is skipped.
+		{"", structs.HealthChecks{check("alias", "", structs.HealthCritical)}, structs.HealthPassing},
+		{"", structs.HealthChecks{check("alias", "", structs.HealthCritical), check("a", "", structs.HealthWarning)}, structs.HealthWarning},
+		{"web1", structs.HealthChecks{check("alias", "web1", structs.HealthCritical), check("b", "web1", structs.HealthPassing)}, structs.HealthPassing},
+
+		// A service without checks is passing if it exists.
+		{"db1", nil, structs.HealthPassing},
+		{"nope", nil, structs.HealthCritical},
+		{"nope", structs.HealthChecks{check("a", "", structs.HealthPassing)}, structs.HealthCritical},
+	}
+	for i, c := range cases {
+		alias.ServiceID = c.serviceID
+		status, output, err := alias.status(c.checks)
+		if err != nil {
+			t.Fatalf("case %d: err: %v", i, err)
+		}
+		if status != c.status || output == "" {
+			t.Fatalf("case %d: bad: %s %q", i, status, output)
+		}
+	}
+
+	// A check with the same ID on a remote node isn't the alias check, as
+	// with a service check aliasing a service with the same ID.
+	alias.Node, alias.ServiceID = "node2", "web1"
+	remote := check("alias", "web1", structs.HealthCritical)
+	remote.Node = "node2"
+	status, _, err := alias.status(structs.HealthChecks{remote})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if status != structs.HealthCritical {
+		t.Fatalf("bad: %s", status)
+	}
+	alias.Node = "node1"
+
+	// Errors looking up the service are passed back.
+	rpc.err = fmt.Errorf("nope")
+	alias.ServiceID = "web1"
+	if _, _, err := alias.status(nil); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestCheckAlias(t *testing.T) {
+	mock := &MockNotify{
+		state:   make(map[types.CheckID]string),
+		updates: make(map[types.CheckID]int),
+		output:  make(map[types.CheckID]string),
+	}
+	rpc := &mockCheckRPC{
+		index: 10,
+		checks: structs.HealthChecks{
+			&structs.HealthCheck{
+				Node:    "node1",
+				CheckID: "mem",
+				Name:    "mem",
+				Status:  structs.HealthWarning,
+				Output:  "running low",
+			},
+		},
+	}
+	check := &CheckAlias{
+		Notify:  mock,
+		RPC:     rpc,
+		CheckID: types.CheckID("foo"),
+		Node:    "node1",
+		Logger:  log.New(os.Stderr, "", log.LstdFlags),
+	}
+	check.Start()
+	defer check.Stop()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if mock.state["foo"] != structs.HealthWarning {
+			return false, fmt.Errorf("should be warning %v", mock.state)
+		}
+		if !strings.Contains(mock.output["foo"], "running low") {
+			return false, fmt.Errorf("bad: %v", mock.output)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
+func TestCheckAlias_RPCError(t *testing.T) {
+	mock := &MockNotify{
+		state:   make(map[types.CheckID]string),
+		updates: make(map[types.CheckID]int),
+		output:  make(map[types.CheckID]string),
+	}
+	check := &CheckAlias{
+		Notify:       mock,
+		RPC:          &mockCheckRPC{err: fmt.Errorf("no leader")},
+		CheckID:      types.CheckID("foo"),
+		Node:         "node1",
+		Logger:       log.New(os.Stderr, "", log.LstdFlags),
+		RetryBackoff: 10 * time.Millisecond,
+	}
+	check.Start()
+	defer check.Stop()
+
+	// The check should go critical, and keep retrying.
+	testutil.WaitForResult(func() (bool, error) {
+		if mock.updates["foo"] < 2 {
+			return false, fmt.Errorf("should have 2 updates %v", mock.updates)
+		}
+		if mock.state["foo"] != structs.HealthCritical {
+			return false, fmt.Errorf("should be critical %v", mock.state)
+		}
+		if !strings.Contains(mock.output["foo"], "no leader") {
+			return false, fmt.Errorf("bad: %v", mock.output)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
+
 func TestCheckTTL(t *testing.T) {
 	mock := &MockNotify{
 		state:   make(map[types.CheckID]string),
@@ -373,6 +631,150 @@ func TestCheckHTTPTimeout(t *testing.T) {
 	})
 }
 
+func TestCheckHTTP_Request(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != "POST" || string(body) != "ping" ||
+			r.Header.Get("Authorization") != "Bearer secret" ||
+			r.Header.Get("User-Agent") != "probe" || r.Host != "web.service" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("status: ok"))
+	}))
+	defer server.Close()
+
+	mock := &MockNotify{
+		state:   make(map[types.CheckID]string),
+		updates: make(map[types.CheckID]int),
+		output:  make(map[types.CheckID]string),
+	}
+	check := &CheckHTTP{
+		Notify:  mock,
+		CheckID: types.CheckID("foo"),
+		HTTP:    server.URL,
+		Method:  "POST",
+		Header: map[string][]string{
+			"authorization": []string{"Bearer secret"},
+			"User-Agent":    []string{"probe"},
+			"Host":          []string{"web.service"},
+		},
+		Body:     "ping",
+		Interval: 10 * time.Millisecond,
+		Logger:   log.New(os.Stderr, "", log.LstdFlags),
+	}
+	check.Start()
+	defer check.Stop()
+
+	testutil.WaitForResult(func() (bool, error) {
+		if mock.state["foo"] != structs.HealthPassing {
+			return false, fmt.Errorf("should be passing %v", mock.state)
+		}
+		if !strings.Contains(mock.output["foo"], "HTTP POST") ||
+			!strings.Contains(mock.output["foo"], "status: ok") {
+			return false, fmt.Errorf("bad output %v", mock.output)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+}
+
+func TestCheckHTTP_Expected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusFound)
+		w.Write([]byte("version: 1.2.3"))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		check  *CheckHTTP
+		status string
+	}{
+		{&CheckHTTP{}, structs.HealthCritical},
+		{&CheckHTTP{ExpectedStatusMin: 302}, structs.HealthPassing},
+		{&CheckHTTP{ExpectedStatusMax: 302}, structs.HealthPassing},
+		{&CheckHTTP{ExpectedStatusMin: 200, ExpectedStatusMax: 399}, structs.HealthPassing},
+		{&CheckHTTP{ExpectedStatusMin: 200, ExpectedStatusMax: 301}, structs.HealthCritical},
+		{&CheckHTTP{ExpectedStatusMin: 302, ExpectedBody: "version"}, structs.HealthPassing},
+		{&CheckHTTP{ExpectedStatusMin: 302, ExpectedBody: "nope"}, structs.HealthCritical},
+		{&CheckHTTP{
+			ExpectedStatusMin: 302,
+			ExpectedBodyRegex: regexp.MustCompile(`^version: 1\.\d+`),
+		}, structs.HealthPassing},
+		{&CheckHTTP{
+			ExpectedStatusMin: 302,
+			ExpectedBodyRegex: regexp.MustCompile(`^version: 2\.`),
+		}, structs.HealthCritical},
+	}
+	for i, c := range cases {
+		mock := &MockNotify{
+			state:   make(map[types.CheckID]string),
+			updates: make(map[types.CheckID]int),
+			output:  make(map[types.CheckID]string),
+		}
+		check := c.check
+		check.Notify = mock
+		check.CheckID = types.CheckID("foo")
+		check.HTTP = server.URL
+		check.Interval = 10 * time.Millisecond
+		check.Logger = log.New(os.Stderr, "", log.LstdFlags)
+		check.Start()
+
+		testutil.WaitForResult(func() (bool, error) {
+			if mock.updates["foo"] < 1 {
+				return false, fmt.Errorf("should have an update %v", mock.updates)
+			}
+			if mock.state["foo"] != c.status {
+				return false, fmt.Errorf("case %d should be %v %v", i, c.status, mock.state)
+			}
+			return true, nil
+		}, func(err error) {
+			t.Fatalf("err: %s", err)
+		})
+		check.Stop()
+	}
+}
+
+func TestCheckHTTP_Expected_LargeBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("version: 1.2.3\n"))
+		w.Write(bytes.Repeat([]byte{'a'}, 2*CheckBufSize))
+		w.Write([]byte("the end"))
+	}))
+	defer server.Close()
+
+	mock := &MockNotify{
+		state:   make(map[types.CheckID]string),
+		updates: make(map[types.CheckID]int),
+		output:  make(map[types.CheckID]string),
+	}
+	check := &CheckHTTP{
+		Notify:       mock,
+		CheckID:      types.CheckID("foo"),
+		HTTP:         server.URL,
+		ExpectedBody: "version: 1.2.3",
+		Interval:     10 * time.Millisecond,
+		Logger:       log.New(os.Stderr, "", log.LstdFlags),
+	}
+	check.Start()
+	defer check.Stop()
+
+	// The whole body is matched, but only its tail is kept as the output.
+	testutil.WaitForResult(func() (bool, error) {
+		if mock.state["foo"] != structs.HealthPassing {
+			return false, fmt.Errorf("should be passing %v", mock.state)
+		}
+		output := mock.output["foo"]
+		if strings.Contains(output, "version") || !strings.Contains(output, "the end (truncated)") {
+			return false, fmt.Errorf("bad output %v", output)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+}
+
 func TestCheckHTTP_disablesKeepAlives(t *testing.T) {
 	check := &CheckHTTP{
 		CheckID:  types.CheckID("foo"),
@@ -591,6 +993,187 @@ func TestCheckTCPPassing(t *testing.T) {
 	tcpServer.Close()
 }
 
+// mockGRPCServer returns a server that implements the gRPC health checking
+// protocol. Services in the statuses map get the given serving status, and
+// any others get a NOT_FOUND error. It returns the address the server listens
+// on and a function that stops it.
+func mockGRPCServer(t *testing.T, useTLS bool, statuses map[string]uint64) (string, func()) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Method != "POST" ||
+			r.URL.Path != "/grpc.health.v1.Health/Check" ||
+			r.Header.Get("Content-Type") != "application/grpc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// The request has at most the service name in field 1.
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil || len(body) < 5 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var service string
+		if len(body) > 5 {
+			service = string(body[7:])
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		status, ok := statuses[service]
+		if !ok {
+			// This is a trailers-only response.
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "unknown%20service")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		if status == 0 {
+			w.Write([]byte{0, 0, 0, 0, 0})
+		} else {
+			w.Write([]byte{0, 0, 0, 0, 2, 0x08, byte(status)})
+		}
+		w.Header().Set("Grpc-Status", "0")
+	})
+
+	if useTLS {
+		server := httptest.NewUnstartedServer(handler)
+		server.TLS = &tls.Config{NextProtos: []string{"h2"}}
+		if err := http2.ConfigureServer(server.Config, nil); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		server.StartTLS()
+		return server.Listener.Addr().String(), server.Close
+	}
+
+	// net/http doesn't serve HTTP/2 without TLS, so the connections are
+	// handed to the HTTP/2 server directly.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				server := &http2.Server{}
+				server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+			}()
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func expectGRPCStatus(t *testing.T, check *CheckGRPC, status, output string) {
+	mock := &MockNotify{
+		state:   make(map[types.CheckID]string),
+		updates: make(map[types.CheckID]int),
+		output:  make(map[types.CheckID]string),
+	}
+	check.Notify = mock
+	check.CheckID = types.CheckID("foo")
+	check.Interval = 10 * time.Millisecond
+	check.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	check.Start()
+	defer check.Stop()
+
+	testutil.WaitForResult(func() (bool, error) {
+		// Should have at least 2 updates
+		if mock.updates["foo"] < 2 {
+			return false, fmt.Errorf("should have 2 updates %v", mock.updates)
+		}
+
+		if mock.state["foo"] != status {
+			return false, fmt.Errorf("should be %v %v", status, mock.state)
+		}
+
+		if !strings.Contains(mock.output["foo"], output) {
+			return false, fmt.Errorf("output should contain %q: %v", output, mock.output)
+		}
+
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+}
+
+func TestCheckGRPC(t *testing.T) {
+	addr, stop := mockGRPCServer(t, false, map[string]uint64{
+		"":        grpcHealthServing,
+		"web":     grpcHealthServing,
+		"db":      grpcHealthNotServing,
+		"unknown": grpcHealthUnknown,
+	})
+	defer stop()
+
+	cases := []struct {
+		target string
+		status string
+		output string
+	}{
+		{addr, structs.HealthPassing, "SERVING"},
+		{addr + "/web", structs.HealthPassing, "SERVING"},
+		{addr + "/db", structs.HealthCritical, "NOT_SERVING"},
+		{addr + "/unknown", structs.HealthCritical, "UNKNOWN"},
+		{addr + "/nope", structs.HealthCritical, "code 5: unknown service"},
+	}
+	for _, c := range cases {
+		expectGRPCStatus(t, &CheckGRPC{GRPC: c.target}, c.status, c.output)
+	}
+}
+
+func TestCheckGRPC_TLS(t *testing.T) {
+	addr, stop := mockGRPCServer(t, true, map[string]uint64{
+		"web": grpcHealthServing,
+	})
+	defer stop()
+
+	// The test server's certificate isn't trusted.
+	expectGRPCStatus(t, &CheckGRPC{
+		GRPC: addr + "/web",
+		TLS:  true,
+	}, structs.HealthCritical, "certificate")
+
+	expectGRPCStatus(t, &CheckGRPC{
+		GRPC:          addr + "/web",
+		TLS:           true,
+		TLSSkipVerify: true,
+	}, structs.HealthPassing, "SERVING")
+
+	// Plain gRPC doesn't work against a TLS server.
+	expectGRPCStatus(t, &CheckGRPC{
+		GRPC: addr + "/web",
+	}, structs.HealthCritical, "gRPC check")
+}
+
+func TestCheckGRPC_BadResponse(t *testing.T) {
+	cases := [][]byte{
+		{},
+		{0, 0, 0, 0},
+		{1, 0, 0, 0, 0},
+		{0, 0, 0, 0, 2, 0x08},
+		{0, 0, 0, 0, 1, 0x08, 0x01},
+		{0, 0, 0, 0, 2, 0x0a, 0x05},
+		{0, 0, 0, 0, 2, 0x0d, 0x01},
+	}
+	for _, c := range cases {
+		if _, err := grpcHealthResponse(c); err == nil {
+			t.Fatalf("should fail: %v", c)
+		}
+	}
+
+	// Unknown fields are skipped.
+	status, err := grpcHealthResponse([]byte{0, 0, 0, 0, 6, 0x12, 0x02, 'h', 'i', 0x08, 0x02})
+	if err != nil || status != grpcHealthNotServing {
+		t.Fatalf("bad: %v %v", status, err)
+	}
+}
+
 // A fake docker client to test happy path scenario
 type fakeDockerClientWithNoErrors struct {
 }
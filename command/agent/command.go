@@ -24,12 +24,14 @@ import (
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/lib"
 	"github.com/hashicorp/consul/logger"
 	"github.com/hashicorp/consul/watch"
 	"github.com/hashicorp/go-checkpoint"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/logutils"
+	"github.com/hashicorp/raft"
 	scada "github.com/hashicorp/scada-client/scada"
 	"github.com/mitchellh/cli"
 )
@@ -71,6 +73,7 @@ func (c *Command) readConfig() *Config {
 	var retryInterval string
 	var retryIntervalWan string
 	var dnsRecursors []string
+	var nodeMeta []string
 	var dev bool
 	var dcDeprecated string
 	cmdFlags := flag.NewFlagSet("agent", flag.ContinueOnError)
@@ -83,6 +86,10 @@ func (c *Command) readConfig() *Config {
 
 	cmdFlags.StringVar(&cmdConfig.LogLevel, "log-level", "", "log level")
 	cmdFlags.StringVar(&cmdConfig.NodeName, "node", "", "node name")
+	cmdFlags.StringVar(&cmdConfig.NodeID, "node-id", "",
+		"a unique ID for this node across space and time, defaults to a randomly-generated ID that persists in the data-dir")
+	cmdFlags.Var((*AppendSliceValue)(&nodeMeta), "node-meta",
+		"arbitrary metadata key/value pair to attach to this node, in the form key:value")
 	cmdFlags.StringVar(&dcDeprecated, "dc", "", "node datacenter (deprecated: use 'datacenter' instead)")
 	cmdFlags.StringVar(&cmdConfig.Datacenter, "datacenter", "", "node datacenter")
 	cmdFlags.StringVar(&cmdConfig.DataDir, "data-dir", "", "path to the data directory")
@@ -111,6 +118,7 @@ func (c *Command) readConfig() *Config {
 	cmdFlags.StringVar(&cmdConfig.AtlasEndpoint, "atlas-endpoint", "", "endpoint for Atlas integration")
 
 	cmdFlags.IntVar(&cmdConfig.Protocol, "protocol", -1, "protocol version")
+	cmdFlags.IntVar(&cmdConfig.RaftProtocol, "raft-protocol", 0, "raft protocol version")
 
 	cmdFlags.BoolVar(&cmdConfig.EnableSyslog, "syslog", false,
 		"enable logging to syslog facility")
@@ -161,6 +169,14 @@ func (c *Command) readConfig() *Config {
 		cmdConfig.RetryIntervalWan = dur
 	}
 
+	if len(nodeMeta) > 0 {
+		cmdConfig.Meta = make(map[string]string)
+		for _, entry := range nodeMeta {
+			key, value := parseMetaPair(entry)
+			cmdConfig.Meta[key] = value
+		}
+	}
+
 	var config *Config
 	if dev {
 		config = DevConfig()
@@ -236,6 +252,20 @@ func (c *Command) readConfig() *Config {
 		}
 	}
 
+	// Verify the node metadata entries are valid
+	if err := structs.ValidateMetadata(config.Meta); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to parse node metadata: %v", err))
+		return nil
+	}
+
+	// Verify the Raft protocol version is one we can speak
+	if config.RaftProtocol != 0 &&
+		(config.RaftProtocol < int(raft.ProtocolVersionMin) || config.RaftProtocol > int(raft.ProtocolVersionMax)) {
+		c.Ui.Error(fmt.Sprintf("raft_protocol %d is not supported, must be in range [%d, %d]",
+			config.RaftProtocol, raft.ProtocolVersionMin, raft.ProtocolVersionMax))
+		return nil
+	}
+
 	// Verify DNS settings
 	if config.DNSConfig.UDPAnswerLimit < 1 {
 		c.Ui.Error(fmt.Sprintf("dns_config.udp_answer_limit %d too low, must always be greater than zero", config.DNSConfig.UDPAnswerLimit))
@@ -287,6 +317,19 @@ func (c *Command) readConfig() *Config {
 		}
 	}
 
+	// KV replication copies keys from other datacenters, so it can't use
+	// this one as a source.
+	for _, replication := range config.KVReplication {
+		if !validDatacenter.MatchString(replication.SourceDC) {
+			c.Ui.Error("KV replication source datacenter must be alpha-numeric with underscores and hypens only")
+			return nil
+		}
+		if replication.SourceDC == config.Datacenter {
+			c.Ui.Error(fmt.Sprintf("KV replication of prefix %q cannot use the local datacenter as its source", replication.Prefix))
+			return nil
+		}
+	}
+
 	// Only allow bootstrap mode when acting as a server
 	if config.Bootstrap && !config.Server {
 		c.Ui.Error("Bootstrap mode cannot be enabled when server mode is not enabled")
@@ -914,6 +957,7 @@ func (c *Command) Run(args []string) int {
 
 	c.Ui.Output("Consul agent running!")
 	c.Ui.Info(fmt.Sprintf("       Version: '%s'", c.HumanVersion))
+	c.Ui.Info(fmt.Sprintf("       Node ID: '%s'", config.NodeID))
 	c.Ui.Info(fmt.Sprintf("     Node name: '%s'", config.NodeName))
 	c.Ui.Info(fmt.Sprintf("    Datacenter: '%s'", config.Datacenter))
 	c.Ui.Info(fmt.Sprintf("        Server: %v (bootstrap: %v)", config.Server, config.Bootstrap))
@@ -1063,6 +1107,7 @@ func (c *Command) handleReload(config *Config) (*Config, error) {
 		errs = multierror.Append(errs, fmt.Errorf("Failed unloading checks: %s", err))
 		return nil, errs
 	}
+	c.agent.unloadMetadata()
 
 	// Reload services and check definitions.
 	if err := c.agent.loadServices(newConf); err != nil {
@@ -1073,6 +1118,10 @@ func (c *Command) handleReload(config *Config) (*Config, error) {
 		errs = multierror.Append(errs, fmt.Errorf("Failed reloading checks: %s", err))
 		return nil, errs
 	}
+	if err := c.agent.loadMetadata(newConf); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("Failed reloading metadata: %s", err))
+		return nil, errs
+	}
 
 	// Get the new client listener addr
 	httpAddr, err := newConf.ClientListener(config.Addresses.HTTP, config.Ports.HTTP)
@@ -1209,7 +1258,13 @@ Options:
                             will retry indefinitely.
   -log-level=info           Log level of the agent.
   -node=hostname            Name of this node. Must be unique in the cluster
+  -node-id=uuid             A unique ID for this node. Defaults to a random ID that
+                            is persisted in the data directory.
+  -node-meta=key:value      An arbitrary metadata key/value pair for this node.
+                            This can be specified multiple times.
   -protocol=N               Sets the protocol version. Defaults to latest.
+  -raft-protocol=N          Sets the Raft protocol version. Servers use their node
+                            ID as their Raft server ID from version 3 on.
   -rejoin                   Ignores a previous leave and attempts to rejoin the cluster.
   -server                   Switches agent to server mode.
   -syslog                   Enables logging to syslog
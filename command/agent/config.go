@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/consul/consul"
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/lib"
 	"github.com/hashicorp/consul/watch"
 	"github.com/mitchellh/mapstructure"
@@ -287,9 +288,18 @@ type Config struct {
 	// LogLevel is the level of the logs to putout
 	LogLevel string `mapstructure:"log_level"`
 
+	// NodeID is a unique identifier for this node across space and time.
+	// If not given, one is generated and persisted in the data directory.
+	NodeID string `mapstructure:"node_id"`
+
 	// Node name is the name we use to advertise. Defaults to hostname.
 	NodeName string `mapstructure:"node_name"`
 
+	// Meta is a set of arbitrary key/value pairs that are synced with the
+	// catalog as part of this node's registration, and can be used to
+	// filter catalog, health and prepared query results.
+	Meta map[string]string `mapstructure:"node_meta"`
+
 	// ClientAddr is used to control the address we bind to for
 	// client services (DNS, HTTP, HTTPS, RPC)
 	ClientAddr string `mapstructure:"client_addr"`
@@ -357,6 +367,10 @@ type Config struct {
 	// Protocol is the Consul protocol version to use.
 	Protocol int `mapstructure:"protocol"`
 
+	// RaftProtocol sets the Raft protocol version to use on this server.
+	// Version 3 and later identify servers by their node ID.
+	RaftProtocol int `mapstructure:"raft_protocol"`
+
 	// EnableDebug is used to enable various debugging features
 	EnableDebug bool `mapstructure:"enable_debug"`
 
@@ -529,6 +543,11 @@ type Config struct {
 	// are opt-in prior to Consul 0.8 and opt-out in Consul 0.8 and later.
 	ACLEnforceVersion8 *bool `mapstructure:"acl_enforce_version_8"`
 
+	// AuditLog configures a log of ACL decisions, such as requests that are
+	// denied and privileged writes that succeed. It's disabled unless a
+	// path is given.
+	AuditLog AuditLogConfig `mapstructure:"audit_log"`
+
 	// Watches are used to monitor various endpoints and to invoke a
 	// handler to act appropriately. These are managed entirely in the
 	// agent layer using the standard APIs.
@@ -617,6 +636,62 @@ type Config struct {
 	// Minimum Session TTL
 	SessionTTLMin    time.Duration `mapstructure:"-"`
 	SessionTTLMinRaw string        `mapstructure:"session_ttl_min"`
+
+	// KVHistory seeds the rules that decide which key prefixes have their
+	// prior versions kept by the servers. It's only used if no rules have
+	// been set yet, after which they're managed through the operator API.
+	KVHistory []*KVHistoryConfig `mapstructure:"kv_history"`
+
+	// KVQuotas limits the keys that can be written under key prefixes. This
+	// should be the same on all servers.
+	KVQuotas []*KVQuotaConfig `mapstructure:"kv_quotas"`
+
+	// KVReplication lists the key prefixes that the leader copies from
+	// other datacenters into the local KV store. This should be the same
+	// on all servers.
+	KVReplication []*KVReplicationConfig `mapstructure:"kv_replication"`
+}
+
+// KVHistoryConfig keeps the prior versions of the keys under a prefix,
+// either up to a number of versions per key, or for a window of time after
+// each version is replaced, or both.
+type KVHistoryConfig struct {
+	Prefix    string        `mapstructure:"prefix"`
+	Versions  int           `mapstructure:"versions"`
+	Window    time.Duration `mapstructure:"-"`
+	WindowRaw string        `mapstructure:"window"`
+}
+
+// KVQuotaConfig limits the number of keys under a prefix, the total size of
+// their values, and the size of each value. If Token is set, the quota only
+// applies to writes made with that token.
+type KVQuotaConfig struct {
+	Prefix       string `mapstructure:"prefix"`
+	Token        string `mapstructure:"token" json:"-"`
+	MaxKeys      int    `mapstructure:"max_keys"`
+	MaxBytes     int64  `mapstructure:"max_bytes"`
+	MaxValueSize int    `mapstructure:"max_value_size"`
+}
+
+// KVReplicationConfig copies the keys under a prefix in another datacenter
+// to the local KV store, under DestPrefix if it's set or else under the
+// same prefix. Token is used to read the keys from the source datacenter.
+type KVReplicationConfig struct {
+	SourceDC   string `mapstructure:"source_dc"`
+	Prefix     string `mapstructure:"prefix"`
+	DestPrefix string `mapstructure:"dest_prefix"`
+	Token      string `mapstructure:"token" json:"-"`
+}
+
+// AuditLogConfig configures the ACL audit log. Decisions are written to Path
+// as lines of JSON, and the log is rotated once it grows past MaxBytes,
+// keeping MaxFiles old logs. If Resources is given, only decisions about
+// those resource types are recorded.
+type AuditLogConfig struct {
+	Path      string   `mapstructure:"path"`
+	MaxBytes  int64    `mapstructure:"max_bytes"`
+	MaxFiles  int      `mapstructure:"max_files"`
+	Resources []string `mapstructure:"resources"`
 }
 
 // Bool is used to initialize bool pointers in struct literals.
@@ -971,6 +1046,77 @@ func DecodeConfig(r io.Reader) (*Config, error) {
 		result.SessionTTLMin = dur
 	}
 
+	prefixes := make(map[string]struct{})
+	for _, history := range result.KVHistory {
+		if _, ok := prefixes[history.Prefix]; ok {
+			return nil, fmt.Errorf("KV history for prefix %q is defined more than once", history.Prefix)
+		}
+		prefixes[history.Prefix] = struct{}{}
+
+		if history.Versions < 0 {
+			return nil, fmt.Errorf("KV history versions for prefix %q must be >= 0", history.Prefix)
+		}
+		if raw := history.WindowRaw; raw != "" {
+			dur, err := time.ParseDuration(raw)
+			if err != nil {
+				return nil, fmt.Errorf("KV history window for prefix %q invalid: %v", history.Prefix, err)
+			}
+			if dur < 0 {
+				return nil, fmt.Errorf("KV history window for prefix %q must be >= 0", history.Prefix)
+			}
+			history.Window = dur
+		}
+	}
+
+	type kvQuotaKey struct {
+		prefix string
+		token  string
+	}
+	quotas := make(map[kvQuotaKey]struct{})
+	for _, quota := range result.KVQuotas {
+		key := kvQuotaKey{quota.Prefix, quota.Token}
+		if _, ok := quotas[key]; ok {
+			return nil, fmt.Errorf("KV quota for prefix %q is defined more than once", quota.Prefix)
+		}
+		quotas[key] = struct{}{}
+
+		if quota.MaxKeys < 0 || quota.MaxBytes < 0 || quota.MaxValueSize < 0 {
+			return nil, fmt.Errorf("KV quota limits for prefix %q must be >= 0", quota.Prefix)
+		}
+		if quota.MaxKeys == 0 && quota.MaxBytes == 0 && quota.MaxValueSize == 0 {
+			return nil, fmt.Errorf("KV quota for prefix %q must set max_keys, max_bytes or max_value_size", quota.Prefix)
+		}
+	}
+
+	for i, replication := range result.KVReplication {
+		if replication.SourceDC == "" {
+			return nil, fmt.Errorf("KV replication for prefix %q must have a source_dc", replication.Prefix)
+		}
+		replication.SourceDC = strings.ToLower(replication.SourceDC)
+		if replication.DestPrefix == "" {
+			replication.DestPrefix = replication.Prefix
+		}
+
+		// Replication deletes any local key under the destination prefix
+		// that's not in the source, so a prefix has to name a whole
+		// directory. Otherwise "config" would also wipe "configuration/",
+		// and an empty prefix would wipe the whole KV store.
+		for _, prefix := range []string{replication.Prefix, replication.DestPrefix} {
+			if !strings.HasSuffix(prefix, "/") {
+				return nil, fmt.Errorf("KV replication prefix %q must be non-empty and end with a '/'", prefix)
+			}
+		}
+
+		// Replicated prefixes mustn't overlap where they're written, or
+		// the replicators would undo each other's changes.
+		for _, other := range result.KVReplication[:i] {
+			if strings.HasPrefix(replication.DestPrefix, other.DestPrefix) ||
+				strings.HasPrefix(other.DestPrefix, replication.DestPrefix) {
+				return nil, fmt.Errorf("KV replication destination prefixes %q and %q overlap", other.DestPrefix, replication.DestPrefix)
+			}
+		}
+	}
+
 	if result.AdvertiseAddrs.SerfLanRaw != "" {
 		ipStr, err := parseSingleIPTemplate(result.AdvertiseAddrs.SerfLanRaw)
 		if err != nil {
@@ -1018,6 +1164,17 @@ func DecodeConfig(r io.Reader) (*Config, error) {
 		return nil, fmt.Errorf("Performance.RaftMultiplier must be <= %d", consul.MaxRaftMultiplier)
 	}
 
+	// Validate the audit log.
+	if result.AuditLog.MaxBytes < 0 || result.AuditLog.MaxFiles < 0 {
+		return nil, fmt.Errorf("Audit log max_bytes and max_files must be >= 0")
+	}
+	for _, resource := range result.AuditLog.Resources {
+		if !audit.ValidResourceType(resource) {
+			return nil, fmt.Errorf("Invalid audit log resource type %q, must be one of: %s",
+				resource, strings.Join(audit.ResourceTypes, ", "))
+		}
+	}
+
 	return &result, nil
 }
 
@@ -1099,6 +1256,33 @@ func FixupCheckType(raw interface{}) error {
 		case "tls_skip_verify":
 			rawMap["TLSSkipVerify"] = v
 			delete(rawMap, k)
+		case "success_before_passing":
+			rawMap["SuccessBeforePassing"] = v
+			delete(rawMap, k)
+		case "failures_before_critical":
+			rawMap["FailuresBeforeCritical"] = v
+			delete(rawMap, k)
+		case "expected_status_min":
+			rawMap["ExpectedStatusMin"] = v
+			delete(rawMap, k)
+		case "expected_status_max":
+			rawMap["ExpectedStatusMax"] = v
+			delete(rawMap, k)
+		case "expected_body":
+			rawMap["ExpectedBody"] = v
+			delete(rawMap, k)
+		case "expected_body_regex":
+			rawMap["ExpectedBodyRegex"] = v
+			delete(rawMap, k)
+		case "grpc_use_tls":
+			rawMap["GRPCUseTLS"] = v
+			delete(rawMap, k)
+		case "alias_node":
+			rawMap["AliasNode"] = v
+			delete(rawMap, k)
+		case "alias_service":
+			rawMap["AliasService"] = v
+			delete(rawMap, k)
 		}
 	}
 
@@ -1210,9 +1394,23 @@ func MergeConfig(a, b *Config) *Config {
 	if b.Protocol > 0 {
 		result.Protocol = b.Protocol
 	}
+	if b.RaftProtocol != 0 {
+		result.RaftProtocol = b.RaftProtocol
+	}
+	if b.NodeID != "" {
+		result.NodeID = b.NodeID
+	}
 	if b.NodeName != "" {
 		result.NodeName = b.NodeName
 	}
+	if len(b.Meta) != 0 {
+		if result.Meta == nil {
+			result.Meta = make(map[string]string)
+		}
+		for k, v := range b.Meta {
+			result.Meta[k] = v
+		}
+	}
 	if b.ClientAddr != "" {
 		result.ClientAddr = b.ClientAddr
 	}
@@ -1488,12 +1686,33 @@ func MergeConfig(a, b *Config) *Config {
 	if b.ACLEnforceVersion8 != nil {
 		result.ACLEnforceVersion8 = b.ACLEnforceVersion8
 	}
+	if b.AuditLog.Path != "" {
+		result.AuditLog.Path = b.AuditLog.Path
+	}
+	if b.AuditLog.MaxBytes != 0 {
+		result.AuditLog.MaxBytes = b.AuditLog.MaxBytes
+	}
+	if b.AuditLog.MaxFiles != 0 {
+		result.AuditLog.MaxFiles = b.AuditLog.MaxFiles
+	}
+	if len(b.AuditLog.Resources) != 0 {
+		result.AuditLog.Resources = b.AuditLog.Resources
+	}
 	if len(b.Watches) != 0 {
 		result.Watches = append(result.Watches, b.Watches...)
 	}
 	if len(b.WatchPlans) != 0 {
 		result.WatchPlans = append(result.WatchPlans, b.WatchPlans...)
 	}
+	if len(b.KVHistory) != 0 {
+		result.KVHistory = b.KVHistory
+	}
+	if len(b.KVQuotas) != 0 {
+		result.KVQuotas = b.KVQuotas
+	}
+	if len(b.KVReplication) != 0 {
+		result.KVReplication = b.KVReplication
+	}
 	if b.DisableRemoteExec {
 		result.DisableRemoteExec = true
 	}
@@ -961,6 +961,132 @@ func TestDecodeConfig(t *testing.T) {
 	if config.SessionTTLMin != 5*time.Second {
 		t.Fatalf("bad: %s %#v", config.SessionTTLMin.String(), config)
 	}
+
+	// KV history
+	input = `{"kv_history": [{"prefix": "foo/", "versions": 5}, {"prefix": "", "window": "24h"}]}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(config.KVHistory) != 2 {
+		t.Fatalf("bad: %#v", config)
+	}
+	if h := config.KVHistory[0]; h.Prefix != "foo/" || h.Versions != 5 || h.Window != 0 {
+		t.Fatalf("bad: %#v", h)
+	}
+	if h := config.KVHistory[1]; h.Prefix != "" || h.Versions != 0 || h.Window != 24*time.Hour {
+		t.Fatalf("bad: %#v", h)
+	}
+	for _, input := range []string{
+		`{"kv_history": [{"prefix": "foo/"}, {"prefix": "foo/"}]}`,
+		`{"kv_history": [{"prefix": "foo/", "versions": -1}]}`,
+		`{"kv_history": [{"prefix": "foo/", "window": "nope"}]}`,
+		`{"kv_history": [{"prefix": "foo/", "window": "-1h"}]}`,
+	} {
+		if _, err := DecodeConfig(bytes.NewReader([]byte(input))); err == nil {
+			t.Fatalf("should have failed: %s", input)
+		}
+	}
+
+	// KV quotas
+	input = `{"kv_quotas": [{"prefix": "tmp/", "max_keys": 100, "max_bytes": 1048576}, {"prefix": "tmp/", "token": "abc", "max_value_size": 1024}]}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(config.KVQuotas) != 2 {
+		t.Fatalf("bad: %#v", config)
+	}
+	if q := config.KVQuotas[0]; q.Prefix != "tmp/" || q.Token != "" || q.MaxKeys != 100 || q.MaxBytes != 1048576 || q.MaxValueSize != 0 {
+		t.Fatalf("bad: %#v", q)
+	}
+	if q := config.KVQuotas[1]; q.Prefix != "tmp/" || q.Token != "abc" || q.MaxKeys != 0 || q.MaxBytes != 0 || q.MaxValueSize != 1024 {
+		t.Fatalf("bad: %#v", q)
+	}
+	for _, input := range []string{
+		`{"kv_quotas": [{"prefix": "tmp/", "max_keys": 1}, {"prefix": "tmp/", "max_bytes": 1}]}`,
+		`{"kv_quotas": [{"prefix": "tmp/", "max_keys": -1}]}`,
+		`{"kv_quotas": [{"prefix": "tmp/"}]}`,
+	} {
+		if _, err := DecodeConfig(bytes.NewReader([]byte(input))); err == nil {
+			t.Fatalf("should have failed: %s", input)
+		}
+	}
+
+	// KV replication
+	input = `{"kv_replication": [{"source_dc": "DC1", "prefix": "app/", "token": "abc"}, {"source_dc": "dc2", "prefix": "app/", "dest_prefix": "dc2/app/"}]}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(config.KVReplication) != 2 {
+		t.Fatalf("bad: %#v", config)
+	}
+	if r := config.KVReplication[0]; r.SourceDC != "dc1" || r.Prefix != "app/" || r.DestPrefix != "app/" || r.Token != "abc" {
+		t.Fatalf("bad: %#v", r)
+	}
+	if r := config.KVReplication[1]; r.SourceDC != "dc2" || r.Prefix != "app/" || r.DestPrefix != "dc2/app/" || r.Token != "" {
+		t.Fatalf("bad: %#v", r)
+	}
+	for _, input := range []string{
+		`{"kv_replication": [{"prefix": "app/"}]}`,
+		`{"kv_replication": [{"source_dc": "dc1", "prefix": "app/"}, {"source_dc": "dc2", "prefix": "app/"}]}`,
+		`{"kv_replication": [{"source_dc": "dc1", "prefix": "app/"}, {"source_dc": "dc2", "prefix": "foo/", "dest_prefix": "app/foo/"}]}`,
+		`{"kv_replication": [{"source_dc": "dc1", "prefix": ""}, {"source_dc": "dc2", "prefix": "app/"}]}`,
+		`{"kv_replication": [{"source_dc": "dc1", "prefix": ""}]}`,
+		`{"kv_replication": [{"source_dc": "dc1", "prefix": "app"}]}`,
+		`{"kv_replication": [{"source_dc": "dc1", "prefix": "app/", "dest_prefix": "config"}]}`,
+	} {
+		if _, err := DecodeConfig(bytes.NewReader([]byte(input))); err == nil {
+			t.Fatalf("should have failed: %s", input)
+		}
+	}
+
+	// Audit log
+	input = `{"audit_log": {"path": "/var/log/consul-audit.log", "max_bytes": 1048576, "max_files": 3, "resources": ["kv", "acl"]}}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if a := config.AuditLog; a.Path != "/var/log/consul-audit.log" || a.MaxBytes != 1048576 || a.MaxFiles != 3 ||
+		!reflect.DeepEqual(a.Resources, []string{"kv", "acl"}) {
+		t.Fatalf("bad: %#v", a)
+	}
+	for _, input := range []string{
+		`{"audit_log": {"path": "audit.log", "max_bytes": -1}}`,
+		`{"audit_log": {"path": "audit.log", "max_files": -1}}`,
+		`{"audit_log": {"path": "audit.log", "resources": ["nope"]}}`,
+	} {
+		if _, err := DecodeConfig(bytes.NewReader([]byte(input))); err == nil {
+			t.Fatalf("should have failed: %s", input)
+		}
+	}
+
+	// Node metadata fields
+	input = `{"node_meta": {"thing1": "1", "thing2": "2"}}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v, ok := config.Meta["thing1"]; !ok || v != "1" {
+		t.Fatalf("bad: %#v", config)
+	}
+	if v, ok := config.Meta["thing2"]; !ok || v != "2" {
+		t.Fatalf("bad: %#v", config)
+	}
+
+	// Node ID and Raft protocol
+	input = `{"node_id": "a-b-c-d", "raft_protocol": 3}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if config.NodeID != "a-b-c-d" {
+		t.Fatalf("bad: %#v", config)
+	}
+	if config.RaftProtocol != 3 {
+		t.Fatalf("bad: %#v", config)
+	}
 }
 
 func TestDecodeConfig_invalidKeys(t *testing.T) {
@@ -1400,11 +1526,27 @@ func TestDecodeConfig_Service(t *testing.T) {
 	if serv.Check.DeregisterCriticalServiceAfter != 90*time.Minute {
 		t.Fatalf("bad: %v", serv)
 	}
+
+	if serv.Weights != nil {
+		t.Fatalf("bad: %v", serv)
+	}
+
+	// Weights
+	input = `{"service": {"name": "redis", "weights": {"passing": 10, "warning": 1}}}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	serv = config.Services[0]
+	if serv.Weights == nil || serv.Weights.Passing != 10 || serv.Weights.Warning != 1 {
+		t.Fatalf("bad: %v", serv)
+	}
 }
 
 func TestDecodeConfig_Check(t *testing.T) {
 	// Basics
-	input := `{"check": {"id": "chk1", "name": "mem", "notes": "foobar", "script": "/bin/check_redis", "interval": "10s", "ttl": "15s", "shell": "/bin/bash", "docker_container_id": "redis", "deregister_critical_service_after": "90s" }}`
+	input := `{"check": {"id": "chk1", "name": "mem", "notes": "foobar", "script": "/bin/check_redis", "interval": "10s", "ttl": "15s", "shell": "/bin/bash", "docker_container_id": "redis", "deregister_critical_service_after": "90s", "success_before_passing": 2, "failures_before_critical": 3 }}`
 	config, err := DecodeConfig(bytes.NewReader([]byte(input)))
 	if err != nil {
 		t.Fatalf("err: %s", err)
@@ -1450,6 +1592,52 @@ func TestDecodeConfig_Check(t *testing.T) {
 	if chk.DeregisterCriticalServiceAfter != 90*time.Second {
 		t.Fatalf("bad: %v", chk)
 	}
+
+	if chk.SuccessBeforePassing != 2 || chk.FailuresBeforeCritical != 3 {
+		t.Fatalf("bad: %v", chk)
+	}
+
+	// HTTP request and expectations
+	input = `{"check": {"id": "chk4", "http": "http://localhost/health", "interval": "10s",
+		"method": "POST", "header": {"Authorization": ["Bearer secret"]}, "body": "ping",
+		"expected_status_min": 200, "expected_status_max": 399,
+		"expected_body": "ok", "expected_body_regex": "^ok"}}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	chk = config.Checks[0]
+	if chk.Method != "POST" || chk.Body != "ping" ||
+		!reflect.DeepEqual(chk.Header, map[string][]string{"Authorization": []string{"Bearer secret"}}) ||
+		chk.ExpectedStatusMin != 200 || chk.ExpectedStatusMax != 399 ||
+		chk.ExpectedBody != "ok" || chk.ExpectedBodyRegex != "^ok" {
+		t.Fatalf("bad: %v", chk)
+	}
+
+	// gRPC
+	input = `{"check": {"id": "chk3", "grpc": "localhost:9090/web", "grpc_use_tls": true, "interval": "10s"}}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	chk = config.Checks[0]
+	if chk.GRPC != "localhost:9090/web" || !chk.GRPCUseTLS || !chk.IsGRPC() {
+		t.Fatalf("bad: %v", chk)
+	}
+
+	// Alias
+	input = `{"check": {"id": "chk2", "alias_node": "node1", "alias_service": "web"}}`
+	config, err = DecodeConfig(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	chk = config.Checks[0]
+	if chk.AliasNode != "node1" || chk.AliasService != "web" || !chk.IsAlias() {
+		t.Fatalf("bad: %v", chk)
+	}
 }
 
 func TestMergeConfig(t *testing.T) {
@@ -1509,9 +1697,14 @@ func TestMergeConfig(t *testing.T) {
 			UDPAnswerLimit:  4,
 			RecursorTimeout: 30 * time.Second,
 		},
-		Domain:           "other",
-		LogLevel:         "info",
-		NodeName:         "baz",
+		Domain:       "other",
+		LogLevel:     "info",
+		NodeID:       "bar",
+		NodeName:     "baz",
+		RaftProtocol: 3,
+		Meta: map[string]string{
+			"key": "value",
+		},
 		ClientAddr:       "127.0.0.2",
 		BindAddr:         "127.0.0.2",
 		AdvertiseAddr:    "127.0.0.2",
@@ -1610,6 +1803,21 @@ func TestMergeConfig(t *testing.T) {
 		},
 		SessionTTLMinRaw: "1000s",
 		SessionTTLMin:    1000 * time.Second,
+		KVHistory: []*KVHistoryConfig{
+			&KVHistoryConfig{Prefix: "foo/", Versions: 2, WindowRaw: "1h", Window: time.Hour},
+		},
+		KVQuotas: []*KVQuotaConfig{
+			&KVQuotaConfig{Prefix: "tmp/", Token: "abc", MaxKeys: 10, MaxBytes: 100, MaxValueSize: 10},
+		},
+		KVReplication: []*KVReplicationConfig{
+			&KVReplicationConfig{SourceDC: "dc2", Prefix: "foo/", DestPrefix: "dc2/foo/", Token: "abc"},
+		},
+		AuditLog: AuditLogConfig{
+			Path:      "/var/log/consul-audit.log",
+			MaxBytes:  1024,
+			MaxFiles:  2,
+			Resources: []string{"kv"},
+		},
 		AdvertiseAddrs: AdvertiseAddrsConfig{
 			SerfLan:    &net.TCPAddr{},
 			SerfLanRaw: "127.0.0.5:1231",
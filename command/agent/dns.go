@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -327,8 +328,8 @@ PARSE:
 				tag = ""
 			}
 
-			// _name._tag.service.consul
-			d.serviceLookup(network, datacenter, labels[n-3][1:], tag, req, resp)
+			// _name._tag[+tag].service.consul
+			d.serviceLookup(network, datacenter, labels[n-3][1:], parseDNSTags(tag), req, resp)
 
 			// Consul 0.3 and prior format for SRV queries
 		} else {
@@ -339,8 +340,8 @@ PARSE:
 				tag = strings.Join(labels[:n-2], ".")
 			}
 
-			// tag[.tag].name.service.consul
-			d.serviceLookup(network, datacenter, labels[n-2], tag, req, resp)
+			// tag[.tag][+tag].name.service.consul
+			d.serviceLookup(network, datacenter, labels[n-2], parseDNSTags(tag), req, resp)
 		}
 
 	case "node":
@@ -416,9 +417,9 @@ INVALID:
 
 // nodeLookup is used to handle a node query
 func (d *DNSServer) nodeLookup(network, datacenter, node string, req, resp *dns.Msg) {
-	// Only handle ANY, A and AAAA type requests
+	// Only handle ANY, A, AAAA and TXT type requests
 	qType := req.Question[0].Qtype
-	if qType != dns.TypeANY && qType != dns.TypeA && qType != dns.TypeAAAA {
+	if qType != dns.TypeANY && qType != dns.TypeA && qType != dns.TypeAAAA && qType != dns.TypeTXT {
 		return
 	}
 
@@ -465,6 +466,35 @@ RPC:
 	if records != nil {
 		resp.Answer = append(resp.Answer, records...)
 	}
+
+	// Add the node metadata as TXT records
+	if qType == dns.TypeANY || qType == dns.TypeTXT {
+		resp.Answer = append(resp.Answer, d.formatNodeMetaRecords(n,
+			req.Question[0].Name, d.config.NodeTTL)...)
+	}
+}
+
+// formatNodeMetaRecords takes a Node and returns a TXT record of the form
+// key=value for each of its metadata entries, sorted by key.
+func (d *DNSServer) formatNodeMetaRecords(node *structs.Node, qName string, ttl time.Duration) (records []dns.RR) {
+	keys := make([]string, 0, len(node.Meta))
+	for key := range node.Meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		records = append(records, &dns.TXT{
+			Hdr: dns.RR_Header{
+				Name:   qName,
+				Rrtype: dns.TypeTXT,
+				Class:  dns.ClassINET,
+				Ttl:    uint32(ttl / time.Second),
+			},
+			Txt: []string{fmt.Sprintf("%s=%s", key, node.Meta[key])},
+		})
+	}
+	return records
 }
 
 // formatNodeRecord takes a Node and returns an A, AAAA, or CNAME record
@@ -622,19 +652,32 @@ func trimUDPResponse(config *DNSConfig, resp *dns.Msg) (trimmed bool) {
 	return len(resp.Answer) < numAnswers
 }
 
+// parseDNSTags splits the tag portion of a service lookup into the individual
+// tags to filter on. Multiple tags are joined with "+", and a tag prefixed
+// with "!" excludes services that have it, so "v1+!canary" matches services
+// tagged "v1" but not "canary".
+func parseDNSTags(label string) []string {
+	var tags []string
+	for _, tag := range strings.Split(label, "+") {
+		if tag != "" && tag != "!" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 // serviceLookup is used to handle a service query
-func (d *DNSServer) serviceLookup(network, datacenter, service, tag string, req, resp *dns.Msg) {
+func (d *DNSServer) serviceLookup(network, datacenter, service string, tags []string, req, resp *dns.Msg) {
 	// Make an RPC request
 	args := structs.ServiceSpecificRequest{
 		Datacenter:  datacenter,
 		ServiceName: service,
-		ServiceTag:  tag,
-		TagFilter:   tag != "",
 		QueryOptions: structs.QueryOptions{
 			Token:      d.agent.config.ACLToken,
 			AllowStale: *d.config.AllowStale,
 		},
 	}
+	args.SetFilterTags(tags)
 	var out structs.IndexedCheckServiceNodes
 RPC:
 	if err := d.agent.RPC("Health.ServiceNodes", &args, &out); err != nil {
@@ -861,7 +904,7 @@ func (d *DNSServer) serviceSRVRecords(dc string, nodes structs.CheckServiceNodes
 				Ttl:    uint32(ttl / time.Second),
 			},
 			Priority: 1,
-			Weight:   1,
+			Weight:   uint16(node.Weight()),
 			Port:     uint16(node.Service.Port),
 			Target:   fmt.Sprintf("%s.node.%s.%s", node.Node.Node, dc, d.domain),
 		}
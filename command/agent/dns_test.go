@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -234,6 +235,69 @@ func TestDNS_NodeLookup(t *testing.T) {
 	}
 }
 
+func TestDNS_NodeLookup_TXT(t *testing.T) {
+	dir, srv := makeDNSServer(t)
+	defer os.RemoveAll(dir)
+	defer srv.agent.Shutdown()
+
+	testutil.WaitForLeader(t, srv.agent.RPC, "dc1")
+
+	// Register node with some metadata
+	args := &structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		NodeMeta: map[string]string{
+			"rack": "b",
+			"env":  "prod",
+		},
+	}
+
+	var out struct{}
+	if err := srv.agent.RPC("Catalog.Register", args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("foo.node.consul.", dns.TypeTXT)
+
+	c := new(dns.Client)
+	addr, _ := srv.agent.config.ClientListener("", srv.agent.config.Ports.DNS)
+	in, _, err := c.Exchange(m, addr.String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Should have a TXT record for each pair, sorted by key
+	if len(in.Answer) != 2 {
+		t.Fatalf("Bad: %#v", in)
+	}
+	for i, expected := range []string{"env=prod", "rack=b"} {
+		txt, ok := in.Answer[i].(*dns.TXT)
+		if !ok {
+			t.Fatalf("Bad: %#v", in.Answer[i])
+		}
+		if len(txt.Txt) != 1 || txt.Txt[0] != expected {
+			t.Fatalf("Bad: %#v", in.Answer[i])
+		}
+	}
+
+	// An ANY query should include the address as well
+	m = new(dns.Msg)
+	m.SetQuestion("foo.node.consul.", dns.TypeANY)
+
+	in, _, err = c.Exchange(m, addr.String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(in.Answer) != 3 {
+		t.Fatalf("Bad: %#v", in)
+	}
+	if _, ok := in.Answer[0].(*dns.A); !ok {
+		t.Fatalf("Bad: %#v", in.Answer[0])
+	}
+}
+
 func TestDNS_CaseInsensitiveNodeLookup(t *testing.T) {
 	dir, srv := makeDNSServer(t)
 	defer os.RemoveAll(dir)
@@ -1497,6 +1561,79 @@ func TestDNS_ServiceLookup_TagPeriod(t *testing.T) {
 	}
 }
 
+func TestDNS_ServiceLookup_MultipleTags(t *testing.T) {
+	dir, srv := makeDNSServer(t)
+	defer os.RemoveAll(dir)
+	defer srv.agent.Shutdown()
+
+	testutil.WaitForLeader(t, srv.agent.RPC, "dc1")
+
+	// Register nodes with a mix of tags.
+	services := map[string][]string{
+		"foo": []string{"v1", "primary"},
+		"bar": []string{"v1", "canary"},
+		"baz": []string{"v2", "primary"},
+	}
+	for node, tags := range services {
+		args := &structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				Service: "db",
+				Tags:    tags,
+				Port:    12345,
+			},
+		}
+
+		var out struct{}
+		if err := srv.agent.RPC("Catalog.Register", args, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Tags are joined with "+" in both the standard and RFC 2782 forms,
+	// and "!" excludes a tag.
+	cases := map[string][]string{
+		"v1.db.service.consul.":           []string{"bar", "foo"},
+		"v1+primary.db.service.consul.":   []string{"foo"},
+		"primary+!v1.db.service.consul.":  []string{"baz"},
+		"v1+!canary.db.service.consul.":   []string{"foo"},
+		"_db._v1+primary.service.consul.": []string{"foo"},
+		"_db._!canary.service.consul.":    []string{"baz", "foo"},
+		"v2+canary.db.service.consul.":    []string{},
+	}
+	for question, expected := range cases {
+		m := new(dns.Msg)
+		m.SetQuestion(question, dns.TypeSRV)
+
+		c := new(dns.Client)
+		addr, _ := srv.agent.config.ClientListener("", srv.agent.config.Ports.DNS)
+		in, _, err := c.Exchange(m, addr.String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		var targets []string
+		for _, rr := range in.Answer {
+			srvRec, ok := rr.(*dns.SRV)
+			if !ok {
+				t.Fatalf("Bad: %#v", rr)
+			}
+			targets = append(targets, strings.TrimSuffix(srvRec.Target, ".node.dc1.consul."))
+		}
+		sort.Strings(targets)
+		if len(targets) != len(expected) {
+			t.Fatalf("question %q: bad: %v", question, targets)
+		}
+		for i := range targets {
+			if targets[i] != expected[i] {
+				t.Fatalf("question %q: bad: %v", question, targets)
+			}
+		}
+	}
+}
+
 func TestDNS_ServiceLookup_PreparedQueryNamePeriod(t *testing.T) {
 	dir, srv := makeDNSServer(t)
 	defer os.RemoveAll(dir)
@@ -2416,6 +2553,107 @@ func TestDNS_ServiceLookup_Randomize(t *testing.T) {
 	}
 }
 
+func TestDNS_ServiceLookup_Weights(t *testing.T) {
+	dir, srv := makeDNSServer(t)
+	defer os.RemoveAll(dir)
+	defer srv.agent.Shutdown()
+
+	testutil.WaitForLeader(t, srv.agent.RPC, "dc1")
+
+	// Register a node with weights and a warning check, and one that
+	// uses the defaults.
+	{
+		args := &structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       "foo",
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				Service: "db",
+				Port:    12345,
+				Weights: &structs.Weights{
+					Passing: 10,
+					Warning: 3,
+				},
+			},
+			Check: &structs.HealthCheck{
+				CheckID:   "db",
+				Name:      "db",
+				ServiceID: "db",
+				Status:    structs.HealthWarning,
+			},
+		}
+
+		var out struct{}
+		if err := srv.agent.RPC("Catalog.Register", args, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		args = &structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       "bar",
+			Address:    "127.0.0.2",
+			Service: &structs.NodeService{
+				Service: "db",
+				Port:    12345,
+			},
+		}
+		if err := srv.agent.RPC("Catalog.Register", args, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Register an equivalent prepared query.
+	var id string
+	{
+		args := &structs.PreparedQueryRequest{
+			Datacenter: "dc1",
+			Op:         structs.PreparedQueryCreate,
+			Query: &structs.PreparedQuery{
+				Service: structs.ServiceQuery{
+					Service: "db",
+				},
+			},
+		}
+		if err := srv.agent.RPC("PreparedQuery.Apply", args, &id); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Look up the service directly and via prepared query, and make sure
+	// the SRV weights follow the health of each instance.
+	questions := []string{
+		"db.service.consul.",
+		id + ".query.consul.",
+	}
+	for _, question := range questions {
+		m := new(dns.Msg)
+		m.SetQuestion(question, dns.TypeSRV)
+
+		c := new(dns.Client)
+		addr, _ := srv.agent.config.ClientListener("", srv.agent.config.Ports.DNS)
+		in, _, err := c.Exchange(m, addr.String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		if len(in.Answer) != 2 {
+			t.Fatalf("Bad: %#v", in)
+		}
+
+		weights := make(map[string]uint16)
+		for _, rec := range in.Answer {
+			srvRec, ok := rec.(*dns.SRV)
+			if !ok {
+				t.Fatalf("Bad: %#v", rec)
+			}
+			weights[srvRec.Target] = srvRec.Weight
+		}
+		if weights["foo.node.dc1.consul."] != 3 || weights["bar.node.dc1.consul."] != 1 {
+			t.Fatalf("Bad: %v", weights)
+		}
+	}
+}
+
 func TestDNS_ServiceLookup_Truncate(t *testing.T) {
 	dir, srv := makeDNSServerConfig(t, nil, func(c *DNSConfig) {
 		c.EnableTruncate = true
@@ -2,6 +2,8 @@ package agent
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -162,6 +164,75 @@ RUN_QUERY:
 	return events, nil
 }
 
+// EventStream is used to subscribe to the changes on a topic. The response
+// is a stream of newline-delimited JSON events, starting with a snapshot of
+// the current state unless an index is given to resume from.
+func (s *HTTPServer) EventStream(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	// Only GET supported
+	if req.Method != "GET" {
+		resp.WriteHeader(405)
+		return nil, nil
+	}
+
+	args := structs.StreamRequest{}
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+	args.Topic = structs.StreamTopic(req.URL.Query().Get("topic"))
+	args.Key = req.URL.Query().Get("key")
+	if args.Topic == "" {
+		resp.WriteHeader(400)
+		resp.Write([]byte("Missing topic"))
+		return nil, nil
+	}
+	if args.Key == "" {
+		resp.WriteHeader(400)
+		resp.Write([]byte("Missing key"))
+		return nil, nil
+	}
+
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("Streaming not supported")
+	}
+	notify := resp.(http.CloseNotifier).CloseNotify()
+
+	// Make the first request before writing anything, so that any errors
+	// can still be reported with the right status code.
+	var out structs.IndexedStreamEvents
+	if err := s.agent.RPC("Stream.Events", &args, &out); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &out.QueryMeta)
+	resp.Header().Set("Content-Type", "application/json")
+
+	// Stream events until the connection is closed. Subscribers keep track
+	// of the index of the events they've seen, so errors just end the
+	// stream and leave it to them to reconnect.
+	enc := json.NewEncoder(resp)
+	for {
+		for _, event := range out.Events {
+			if err := enc.Encode(event); err != nil {
+				return nil, nil
+			}
+		}
+		flusher.Flush()
+
+		select {
+		case <-notify:
+			return nil, nil
+		default:
+		}
+
+		args.MinQueryIndex = out.Index
+		out = structs.IndexedStreamEvents{}
+		if err := s.agent.RPC("Stream.Events", &args, &out); err != nil {
+			s.logger.Printf("[ERR] http: Event stream for %s %q failed: %v", args.Topic, args.Key, err)
+			return nil, nil
+		}
+	}
+}
+
 // uuidToUint64 is a bit of a hack to generate a 64bit Consul index.
 // In effect, we take our random UUID, convert it to a 128 bit number,
 // then XOR the high-order and low-order 64bit's together to get the
@@ -2,6 +2,7 @@ package agent
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -298,6 +299,92 @@ func TestEventList_EventBufOrder(t *testing.T) {
 	})
 }
 
+func TestEventStream(t *testing.T) {
+	httpTest(t, func(srv *HTTPServer) {
+		// Check the required parameters.
+		for _, url := range []string{
+			"/v1/event/stream?key=web",
+			"/v1/event/stream?topic=service-health",
+		} {
+			req, err := http.NewRequest("GET", url, nil)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			resp := httptest.NewRecorder()
+			if _, err := srv.EventStream(resp, req); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if resp.Code != 400 {
+				t.Fatalf("bad: %d", resp.Code)
+			}
+		}
+
+		// Register a service.
+		reg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       "foo",
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      "web1",
+				Service: "web",
+			},
+			Check: &structs.HealthCheck{
+				Name:      "web alive",
+				Status:    structs.HealthPassing,
+				ServiceID: "web1",
+			},
+		}
+		var out struct{}
+		if err := srv.agent.RPC("Catalog.Register", &reg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		// Stream through a real server so the response can be flushed
+		// as we go. A short wait lets the handler notice we've gone away
+		// when we're done.
+		ts := httptest.NewServer(http.HandlerFunc(srv.wrap(srv.EventStream)))
+		defer ts.Close()
+		resp, err := http.Get(ts.URL + "/v1/event/stream?topic=service-health&key=web&wait=100ms")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 || resp.Header.Get("X-Consul-Index") == "" {
+			t.Fatalf("bad: %v", resp)
+		}
+
+		dec := json.NewDecoder(resp.Body)
+		next := func() *structs.StreamEvent {
+			var event structs.StreamEvent
+			if err := dec.Decode(&event); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			return &event
+		}
+
+		// We should get the snapshot first.
+		if event := next(); event.Op != structs.StreamAdd ||
+			event.ServiceHealth == nil || event.ServiceHealth.Service.ID != "web1" {
+			t.Fatalf("bad: %#v", event)
+		}
+		snapshot := next()
+		if snapshot.Op != structs.StreamEndOfSnapshot {
+			t.Fatalf("bad: %#v", snapshot)
+		}
+
+		// Then the changes.
+		reg.Check.Status = structs.HealthCritical
+		if err := srv.agent.RPC("Catalog.Register", &reg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		event := next()
+		if event.Op != structs.StreamUpdate || event.Index <= snapshot.Index ||
+			event.ServiceHealth.Checks[0].Status != structs.HealthCritical {
+			t.Fatalf("bad: %#v", event)
+		}
+	})
+}
+
 func TestUUIDToUint64(t *testing.T) {
 	inp := "cb9a81ad-fff6-52ac-92a7-5f70687805ec"
 
@@ -100,16 +100,13 @@ func (s *HTTPServer) HealthServiceNodes(resp http.ResponseWriter, req *http.Requ
 	// Set default DC
 	args := structs.ServiceSpecificRequest{}
 	s.parseSource(req, &args.Source)
+	args.NodeMetaFilters = s.parseMetaFilter(req)
 	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
 		return nil, nil
 	}
 
-	// Check for a tag
-	params := req.URL.Query()
-	if _, ok := params["tag"]; ok {
-		args.ServiceTag = params.Get("tag")
-		args.TagFilter = true
-	}
+	// Check for tags, which may be given more than once
+	parseTagFilter(req, &args)
 
 	// Pull out the service name
 	args.ServiceName = strings.TrimPrefix(req.URL.Path, "/v1/health/service/")
@@ -127,7 +124,7 @@ func (s *HTTPServer) HealthServiceNodes(resp http.ResponseWriter, req *http.Requ
 	}
 
 	// Filter to only passing if specified
-	if _, ok := params[structs.HealthPassing]; ok {
+	if _, ok := req.URL.Query()[structs.HealthPassing]; ok {
 		out.Nodes = filterNonPassing(out.Nodes)
 	}
 
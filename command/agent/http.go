@@ -240,6 +240,11 @@ func (s *HTTPServer) registerHandlers(enableDebug bool) {
 		s.handleFuncMetrics("/v1/acl/clone/", s.wrap(s.ACLClone))
 		s.handleFuncMetrics("/v1/acl/list", s.wrap(s.ACLList))
 		s.handleFuncMetrics("/v1/acl/replication", s.wrap(s.ACLReplicationStatus))
+		s.handleFuncMetrics("/v1/acl/policy/create", s.wrap(s.ACLPolicyCreate))
+		s.handleFuncMetrics("/v1/acl/policy/update", s.wrap(s.ACLPolicyUpdate))
+		s.handleFuncMetrics("/v1/acl/policy/destroy/", s.wrap(s.ACLPolicyDestroy))
+		s.handleFuncMetrics("/v1/acl/policy/info/", s.wrap(s.ACLPolicyGet))
+		s.handleFuncMetrics("/v1/acl/policies", s.wrap(s.ACLPolicyList))
 	} else {
 		s.handleFuncMetrics("/v1/acl/create", s.wrap(aclDisabled))
 		s.handleFuncMetrics("/v1/acl/update", s.wrap(aclDisabled))
@@ -248,6 +253,11 @@ func (s *HTTPServer) registerHandlers(enableDebug bool) {
 		s.handleFuncMetrics("/v1/acl/clone/", s.wrap(aclDisabled))
 		s.handleFuncMetrics("/v1/acl/list", s.wrap(aclDisabled))
 		s.handleFuncMetrics("/v1/acl/replication", s.wrap(aclDisabled))
+		s.handleFuncMetrics("/v1/acl/policy/create", s.wrap(aclDisabled))
+		s.handleFuncMetrics("/v1/acl/policy/update", s.wrap(aclDisabled))
+		s.handleFuncMetrics("/v1/acl/policy/destroy/", s.wrap(aclDisabled))
+		s.handleFuncMetrics("/v1/acl/policy/info/", s.wrap(aclDisabled))
+		s.handleFuncMetrics("/v1/acl/policies", s.wrap(aclDisabled))
 	}
 	s.handleFuncMetrics("/v1/agent/self", s.wrap(s.AgentSelf))
 	s.handleFuncMetrics("/v1/agent/maintenance", s.wrap(s.AgentNodeMaintenance))
@@ -284,6 +294,7 @@ func (s *HTTPServer) registerHandlers(enableDebug bool) {
 	}
 	s.handleFuncMetrics("/v1/event/fire/", s.wrap(s.EventFire))
 	s.handleFuncMetrics("/v1/event/list", s.wrap(s.EventList))
+	s.handleFuncMetrics("/v1/event/stream", s.wrap(s.EventStream))
 	s.handleFuncMetrics("/v1/health/node/", s.wrap(s.HealthNodeChecks))
 	s.handleFuncMetrics("/v1/health/checks/", s.wrap(s.HealthServiceChecks))
 	s.handleFuncMetrics("/v1/health/state/", s.wrap(s.HealthChecksInState))
@@ -292,6 +303,9 @@ func (s *HTTPServer) registerHandlers(enableDebug bool) {
 	s.handleFuncMetrics("/v1/internal/ui/node/", s.wrap(s.UINodeInfo))
 	s.handleFuncMetrics("/v1/internal/ui/services", s.wrap(s.UIServices))
 	s.handleFuncMetrics("/v1/kv/", s.wrap(s.KVSEndpoint))
+	s.handleFuncMetrics("/v1/kv-replication", s.wrap(s.KVSReplicationStatus))
+	s.handleFuncMetrics("/v1/operator/kv/history", s.wrap(s.OperatorKVHistory))
+	s.handleFuncMetrics("/v1/operator/kv/quotas", s.wrap(s.OperatorKVQuotas))
 	s.handleFuncMetrics("/v1/operator/raft/configuration", s.wrap(s.OperatorRaftConfiguration))
 	s.handleFuncMetrics("/v1/operator/raft/peer", s.wrap(s.OperatorRaftPeer))
 	s.handleFuncMetrics("/v1/operator/keyring", s.wrap(s.OperatorKeyringEndpoint))
@@ -373,6 +387,10 @@ func (s *HTTPServer) wrap(handler func(resp http.ResponseWriter, req *http.Reque
 			errMsg := err.Error()
 			if strings.Contains(errMsg, "Permission denied") || strings.Contains(errMsg, "ACL not found") {
 				code = http.StatusForbidden // 403
+			} else if strings.Contains(errMsg, "Invalid filter expression") {
+				code = http.StatusBadRequest // 400
+			} else if strings.Contains(errMsg, "Quota exceeded") {
+				code = http.StatusTooManyRequests // 429
 			}
 
 			resp.WriteHeader(code)
@@ -584,11 +602,34 @@ func (s *HTTPServer) parseSource(req *http.Request, source *structs.QuerySource)
 	}
 }
 
+// parseMetaFilter is used to parse the ?node-meta=key:value query parameter,
+// used for filtering results to nodes with the given metadata key/value.
+func (s *HTTPServer) parseMetaFilter(req *http.Request) map[string]string {
+	if filterList, ok := req.URL.Query()["node-meta"]; ok {
+		filters := make(map[string]string)
+		for _, filter := range filterList {
+			key, value := parseMetaPair(filter)
+			filters[key] = value
+		}
+		return filters
+	}
+	return nil
+}
+
+// parseTagFilter is used to parse the ?tag= query parameter, which may be
+// given more than once, used for filtering services by their tags.
+func parseTagFilter(req *http.Request, args *structs.ServiceSpecificRequest) {
+	if tags, ok := req.URL.Query()["tag"]; ok {
+		args.SetFilterTags(tags)
+	}
+}
+
 // parse is a convenience method for endpoints that need
 // to use both parseWait and parseDC.
 func (s *HTTPServer) parse(resp http.ResponseWriter, req *http.Request, dc *string, b *structs.QueryOptions) bool {
 	s.parseDC(req, dc)
 	s.parseToken(req, &b.Token)
+	b.Filter = req.URL.Query().Get("filter")
 	if parseConsistency(resp, req, b) {
 		return true
 	}
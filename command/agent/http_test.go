@@ -12,6 +12,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
@@ -506,6 +507,35 @@ func TestParseWait_InvalidIndex(t *testing.T) {
 	}
 }
 
+func TestParseTagFilter(t *testing.T) {
+	cases := []struct {
+		query string
+		tag   string
+		tags  []string
+	}{
+		{"", "", nil},
+		{"tag=a", "a", nil},
+		{"tag=a&tag=b", "a", []string{"b"}},
+		{"tag=!a&tag=b&tag=c", "b", []string{"!a", "c"}},
+		{"tag=!a", "", []string{"!a"}},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", "/v1/catalog/service/api?"+c.query, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		var args structs.ServiceSpecificRequest
+		parseTagFilter(req, &args)
+		if args.TagFilter != (c.query != "") {
+			t.Fatalf("bad: %s: %v", c.query, args)
+		}
+		if args.ServiceTag != c.tag || !reflect.DeepEqual(args.ServiceTags, c.tags) {
+			t.Fatalf("bad: %s: %v", c.query, args)
+		}
+	}
+}
+
 func TestParseConsistency(t *testing.T) {
 	resp := httptest.NewRecorder()
 	var b structs.QueryOptions
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/consul/consul/structs"
 )
@@ -28,18 +29,24 @@ func (s *HTTPServer) KVSEndpoint(resp http.ResponseWriter, req *http.Request) (i
 	// Pull out the key name, validation left to each sub-handler
 	args.Key = strings.TrimPrefix(req.URL.Path, "/v1/kv/")
 
-	// Check for a key list
+	// Check for a key list or a key's history
 	keyList := false
+	history := false
 	params := req.URL.Query()
 	if _, ok := params["keys"]; ok {
 		keyList = true
 	}
+	if _, ok := params["history"]; ok {
+		history = true
+	}
 
 	// Switch on the method
 	switch req.Method {
 	case "GET":
 		if keyList {
 			return s.KVSGetKeys(resp, req, &args)
+		} else if history {
+			return s.KVSGetHistory(resp, req, &args)
 		} else {
 			return s.KVSGet(resp, req, &args)
 		}
@@ -64,17 +71,41 @@ func (s *HTTPServer) KVSGet(resp http.ResponseWriter, req *http.Request, args *s
 		return nil, nil
 	}
 
+	// Check for paging, which only applies to a listing
+	if method == "KVS.List" {
+		if done := parseKVSPaging(resp, req, &args.After, &args.Limit); done {
+			return nil, nil
+		}
+	}
+
+	// Check for a point-in-time read, which only works for a single key
+	if _, ok := params["at"]; ok {
+		if conflictingFlags(resp, req, "recurse", "at") {
+			return nil, nil
+		}
+		atVal, err := strconv.ParseUint(params.Get("at"), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		args.AtIndex = atVal
+	}
+
 	// Make the RPC
 	var out structs.IndexedDirEntries
 	if err := s.agent.RPC(method, &args, &out); err != nil {
 		return nil, err
 	}
 	setMeta(resp, &out.QueryMeta)
+	setNextAfter(resp, out.NextAfter)
 
-	// Check if we get a not found
+	// Check if we get a not found. A page can be empty if ACLs filtered
+	// out all of its keys, but there may be more.
 	if len(out.Entries) == 0 {
-		resp.WriteHeader(404)
-		return nil, nil
+		if out.NextAfter == "" {
+			resp.WriteHeader(404)
+			return nil, nil
+		}
+		return structs.DirEntries{}, nil
 	}
 
 	// Check if we are in raw mode with a normal get, write out
@@ -89,6 +120,27 @@ func (s *HTTPServer) KVSGet(resp http.ResponseWriter, req *http.Request, args *s
 	return out.Entries, nil
 }
 
+// KVSGetHistory handles a GET request for the prior versions of a key
+func (s *HTTPServer) KVSGetHistory(resp http.ResponseWriter, req *http.Request, args *structs.KeyRequest) (interface{}, error) {
+	if missingKey(resp, args) {
+		return nil, nil
+	}
+
+	// Make the RPC
+	var out structs.IndexedKVSHistory
+	if err := s.agent.RPC("KVS.History", &args, &out); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &out.QueryMeta)
+
+	// Check if we get a not found
+	if len(out.Entries) == 0 {
+		resp.WriteHeader(404)
+		return nil, nil
+	}
+	return out.Entries, nil
+}
+
 // KVSGetKeys handles a GET request for keys
 func (s *HTTPServer) KVSGetKeys(resp http.ResponseWriter, req *http.Request, args *structs.KeyRequest) (interface{}, error) {
 	// Check for a separator, due to historic spelling error,
@@ -109,6 +161,9 @@ func (s *HTTPServer) KVSGetKeys(resp http.ResponseWriter, req *http.Request, arg
 		Seperator:    sep,
 		QueryOptions: args.QueryOptions,
 	}
+	if done := parseKVSPaging(resp, req, &listArgs.After, &listArgs.Limit); done {
+		return nil, nil
+	}
 
 	// Make the RPC
 	var out structs.IndexedKeyList
@@ -116,10 +171,11 @@ func (s *HTTPServer) KVSGetKeys(resp http.ResponseWriter, req *http.Request, arg
 		return nil, err
 	}
 	setMeta(resp, &out.QueryMeta)
+	setNextAfter(resp, out.NextAfter)
 
 	// Check if we get a not found. We do not generate
 	// not found for the root, but just provide the empty list
-	if len(out.Keys) == 0 && listArgs.Prefix != "" {
+	if len(out.Keys) == 0 && listArgs.Prefix != "" && out.NextAfter == "" {
 		resp.WriteHeader(404)
 		return nil, nil
 	}
@@ -160,6 +216,17 @@ func (s *HTTPServer) KVSPut(resp http.ResponseWriter, req *http.Request, args *s
 		applyReq.DirEnt.Flags = flagVal
 	}
 
+	// Check for a TTL
+	if _, ok := params["ttl"]; ok {
+		ttl := params.Get("ttl")
+		if _, err := time.ParseDuration(ttl); err != nil {
+			resp.WriteHeader(400)
+			resp.Write([]byte(fmt.Sprintf("Invalid TTL: %v", err)))
+			return nil, nil
+		}
+		applyReq.DirEnt.TTL = ttl
+	}
+
 	// Check for cas value
 	if _, ok := params["cas"]; ok {
 		casVal, err := strconv.ParseUint(params.Get("cas"), 10, 64)
@@ -266,6 +333,34 @@ func missingKey(resp http.ResponseWriter, args *structs.KeyRequest) bool {
 	return false
 }
 
+// parseKVSPaging is used to parse the ?after and ?limit parameters used to
+// page through a listing. Returns true if the response has been written
+// because of a bad parameter.
+func parseKVSPaging(resp http.ResponseWriter, req *http.Request, after *string, limit *int) bool {
+	params := req.URL.Query()
+	if _, ok := params["after"]; ok {
+		*after = params.Get("after")
+	}
+	if _, ok := params["limit"]; ok {
+		limitVal, err := strconv.Atoi(params.Get("limit"))
+		if err != nil || limitVal < 1 {
+			resp.WriteHeader(400)
+			resp.Write([]byte(fmt.Sprintf("Invalid limit: %q", params.Get("limit"))))
+			return true
+		}
+		*limit = limitVal
+	}
+	return false
+}
+
+// setNextAfter is used to give the ?after to use to get the next page of a
+// listing, if there is one.
+func setNextAfter(resp http.ResponseWriter, next string) {
+	if next != "" {
+		resp.Header().Set("X-Consul-Next-After", next)
+	}
+}
+
 // conflictingFlags determines if non-composable flags were passed in a request.
 func conflictingFlags(resp http.ResponseWriter, req *http.Request, flags ...string) bool {
 	params := req.URL.Query()
@@ -284,3 +379,25 @@ func conflictingFlags(resp http.ResponseWriter, req *http.Request, flags ...stri
 
 	return false
 }
+
+// KVSReplicationStatus returns the status of the replication of each key
+// prefix that's copied into the datacenter from another datacenter.
+func (s *HTTPServer) KVSReplicationStatus(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	args := structs.DCSpecificRequest{}
+	s.parseSource(req, &args.Source)
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	// Make the request.
+	var out structs.KVSReplicationStatuses
+	if err := s.agent.RPC("KVS.ReplicationStatus", &args, &out); err != nil {
+		return nil, err
+	}
+
+	// Use empty list instead of nil
+	if out == nil {
+		out = make(structs.KVSReplicationStatuses, 0)
+	}
+	return out, nil
+}
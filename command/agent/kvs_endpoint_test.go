@@ -428,6 +428,119 @@ func TestKVSEndpoint_ListKeys(t *testing.T) {
 	}
 }
 
+func TestKVSEndpoint_Paging(t *testing.T) {
+	dir, srv := makeHTTPServer(t)
+	defer os.RemoveAll(dir)
+	defer srv.Shutdown()
+	defer srv.agent.Shutdown()
+
+	testutil.WaitForLeader(t, srv.agent.RPC, "dc1")
+
+	keys := []string{
+		"bar",
+		"baz",
+		"foo/sub1",
+		"foo/sub2",
+		"zip",
+	}
+
+	for _, key := range keys {
+		buf := bytes.NewBuffer([]byte("test"))
+		req, err := http.NewRequest("PUT", "/v1/kv/"+key, buf)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		resp := httptest.NewRecorder()
+		obj, err := srv.KVSEndpoint(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		if res := obj.(bool); !res {
+			t.Fatalf("should work")
+		}
+	}
+
+	{
+		// Page through the entries
+		var got []string
+		after := ""
+		for {
+			url := "/v1/kv/?recurse&limit=2"
+			if after != "" {
+				url += "&after=" + after
+			}
+			req, err := http.NewRequest("GET", url, nil)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			resp := httptest.NewRecorder()
+			obj, err := srv.KVSEndpoint(resp, req)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			assertIndex(t, resp)
+
+			res := obj.(structs.DirEntries)
+			if len(res) > 2 {
+				t.Fatalf("bad: %v", res)
+			}
+			for _, e := range res {
+				got = append(got, e.Key)
+			}
+
+			after = resp.Header().Get("X-Consul-Next-After")
+			if after == "" {
+				break
+			}
+		}
+		if !reflect.DeepEqual(got, keys) {
+			t.Fatalf("bad: %v", got)
+		}
+	}
+
+	{
+		// Get a page of the keys
+		req, err := http.NewRequest("GET", "/v1/kv/?keys&separator=/&after=baz&limit=1", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		resp := httptest.NewRecorder()
+		obj, err := srv.KVSEndpoint(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		assertIndex(t, resp)
+
+		res := obj.([]string)
+		if !reflect.DeepEqual(res, []string{"foo/"}) {
+			t.Fatalf("bad: %v", res)
+		}
+		if next := resp.Header().Get("X-Consul-Next-After"); next != "foo/" {
+			t.Fatalf("bad: %q", next)
+		}
+	}
+
+	{
+		// Bad limits are rejected
+		req, err := http.NewRequest("GET", "/v1/kv/?recurse&limit=0", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		resp := httptest.NewRecorder()
+		if _, err := srv.KVSEndpoint(resp, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.Code != 400 {
+			t.Fatalf("expected 400, got %d", resp.Code)
+		}
+	}
+}
+
 func TestKVSEndpoint_AcquireRelease(t *testing.T) {
 	httpTest(t, func(srv *HTTPServer) {
 		// Acquire the lock
@@ -532,6 +645,154 @@ func TestKVSEndpoint_GET_Raw(t *testing.T) {
 	})
 }
 
+func TestKVSEndpoint_PUT_TTL(t *testing.T) {
+	httpTest(t, func(srv *HTTPServer) {
+		req, err := http.NewRequest("PUT", "/v1/kv/test?ttl=nope", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		resp := httptest.NewRecorder()
+		if _, err := srv.KVSEndpoint(resp, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.Code != 400 {
+			t.Fatalf("expected 400, got %d", resp.Code)
+		}
+		if !bytes.Contains(resp.Body.Bytes(), []byte("Invalid TTL")) {
+			t.Fatalf("expected invalid TTL error")
+		}
+
+		buf := bytes.NewBuffer([]byte("test"))
+		req, err = http.NewRequest("PUT", "/v1/kv/test?ttl=10m", buf)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		resp = httptest.NewRecorder()
+		obj, err := srv.KVSEndpoint(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if res := obj.(bool); !res {
+			t.Fatalf("should work")
+		}
+
+		req, err = http.NewRequest("GET", "/v1/kv/test", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		resp = httptest.NewRecorder()
+		obj, err = srv.KVSEndpoint(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		d := obj.(structs.DirEntries)[0]
+		if d.TTL != "10m" {
+			t.Fatalf("bad: %v", d)
+		}
+	})
+}
+
+func TestKVSEndpoint_History(t *testing.T) {
+	httpTestWithConfig(t, func(srv *HTTPServer) {
+		// Write two versions of a key
+		var indexes []uint64
+		for _, value := range []string{"one", "two"} {
+			buf := bytes.NewBuffer([]byte(value))
+			req, err := http.NewRequest("PUT", "/v1/kv/test", buf)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			resp := httptest.NewRecorder()
+			obj, err := srv.KVSEndpoint(resp, req)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if res := obj.(bool); !res {
+				t.Fatalf("should work")
+			}
+
+			req, err = http.NewRequest("GET", "/v1/kv/test", nil)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			resp = httptest.NewRecorder()
+			obj, err = srv.KVSEndpoint(resp, req)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			indexes = append(indexes, obj.(structs.DirEntries)[0].ModifyIndex)
+		}
+
+		// List the history
+		req, err := http.NewRequest("GET", "/v1/kv/test?history", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp := httptest.NewRecorder()
+		obj, err := srv.KVSEndpoint(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		assertIndex(t, resp)
+		versions := obj.(structs.KVSHistoryEntries)
+		if len(versions) != 1 {
+			t.Fatalf("bad: %v", versions)
+		}
+		if v := versions[0]; string(v.Value) != "one" || v.ModifyIndex != indexes[0] || v.ReplacedIndex != indexes[1] {
+			t.Fatalf("bad: %v", v)
+		}
+
+		// Read the first version back
+		url := fmt.Sprintf("/v1/kv/test?at=%d", indexes[0])
+		req, err = http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp = httptest.NewRecorder()
+		obj, err = srv.KVSEndpoint(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		d := obj.(structs.DirEntries)[0]
+		if string(d.Value) != "one" {
+			t.Fatalf("bad: %v", d)
+		}
+
+		// Keys without history are not found
+		req, err = http.NewRequest("GET", "/v1/kv/nope?history", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp = httptest.NewRecorder()
+		if _, err := srv.KVSEndpoint(resp, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.Code != 404 {
+			t.Fatalf("expected 404, got %d", resp.Code)
+		}
+
+		// Point-in-time reads only work for a single key
+		req, err = http.NewRequest("GET", "/v1/kv/test?recurse&at=1", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp = httptest.NewRecorder()
+		if _, err := srv.KVSEndpoint(resp, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.Code != 400 {
+			t.Fatalf("expected 400, got %d", resp.Code)
+		}
+	}, func(c *Config) {
+		c.KVHistory = []*KVHistoryConfig{
+			&KVHistoryConfig{Prefix: "test"},
+		}
+	})
+}
+
 func TestKVSEndpoint_PUT_ConflictingFlags(t *testing.T) {
 	httpTest(t, func(srv *HTTPServer) {
 		req, err := http.NewRequest("PUT", "/v1/kv/test?cas=0&acquire=xxx", nil)
@@ -573,3 +834,21 @@ func TestKVSEndpoint_DELETE_ConflictingFlags(t *testing.T) {
 		}
 	})
 }
+
+func TestKVSReplicationStatus(t *testing.T) {
+	httpTest(t, func(srv *HTTPServer) {
+		req, err := http.NewRequest("GET", "/v1/kv-replication", nil)
+		resp := httptest.NewRecorder()
+		obj, err := srv.KVSReplicationStatus(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		statuses, ok := obj.(structs.KVSReplicationStatuses)
+		if !ok {
+			t.Fatalf("should work")
+		}
+		if statuses == nil || len(statuses) != 0 {
+			t.Fatalf("bad: %#v", statuses)
+		}
+	})
+}
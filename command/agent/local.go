@@ -47,9 +47,12 @@ type localState struct {
 	iface consul.Interface
 
 	// nodeInfoInSync tracks whether the server has our correct top-level
-	// node information in sync (currently only used for tagged addresses)
+	// node information in sync
 	nodeInfoInSync bool
 
+	// metadata tracks the local node's metadata key/value pairs
+	metadata map[string]string
+
 	// Services tracks the local services
 	services      map[string]*structs.NodeService
 	serviceStatus map[string]syncStatus
@@ -85,6 +88,7 @@ func (l *localState) Init(config *Config, logger *log.Logger) {
 	l.checkTokens = make(map[types.CheckID]string)
 	l.checkCriticalTime = make(map[types.CheckID]time.Time)
 	l.deferCheck = make(map[types.CheckID]*time.Timer)
+	l.metadata = make(map[string]string)
 	l.consulCh = make(chan struct{}, 1)
 	l.triggerCh = make(chan struct{}, 1)
 }
@@ -199,6 +203,19 @@ func (l *localState) Services() map[string]*structs.NodeService {
 	return services
 }
 
+// Metadata returns the local node metadata fields that the
+// agent is aware of and are being kept in sync with the server
+func (l *localState) Metadata() map[string]string {
+	metadata := make(map[string]string)
+	l.RLock()
+	defer l.RUnlock()
+
+	for key, value := range l.metadata {
+		metadata[key] = value
+	}
+	return metadata
+}
+
 // CheckToken is used to return the configured health check token for a
 // Check, or if none is configured, the default agent ACL token.
 func (l *localState) CheckToken(checkID types.CheckID) string {
@@ -415,10 +432,13 @@ func (l *localState) setSyncState() error {
 	l.Lock()
 	defer l.Unlock()
 
-	// Check the node info (currently limited to tagged addresses since
-	// everything else is managed by the Serf layer)
+	// Check the node info (currently limited to the node ID, tagged
+	// addresses and metadata since everything else is managed by the Serf
+	// layer)
 	if out1.NodeServices == nil || out1.NodeServices.Node == nil ||
-		!reflect.DeepEqual(out1.NodeServices.Node.TaggedAddresses, l.config.TaggedAddresses) {
+		out1.NodeServices.Node.ID != types.NodeID(l.config.NodeID) ||
+		!reflect.DeepEqual(out1.NodeServices.Node.TaggedAddresses, l.config.TaggedAddresses) ||
+		!reflect.DeepEqual(out1.NodeServices.Node.Meta, l.metadata) {
 		l.nodeInfoInSync = false
 	}
 
@@ -619,9 +639,11 @@ func (l *localState) deleteCheck(id types.CheckID) error {
 func (l *localState) syncService(id string) error {
 	req := structs.RegisterRequest{
 		Datacenter:      l.config.Datacenter,
+		ID:              types.NodeID(l.config.NodeID),
 		Node:            l.config.NodeName,
 		Address:         l.config.AdvertiseAddr,
 		TaggedAddresses: l.config.TaggedAddresses,
+		NodeMeta:        l.metadata,
 		Service:         l.services[id],
 		WriteRequest:    structs.WriteRequest{Token: l.serviceToken(id)},
 	}
@@ -680,9 +702,11 @@ func (l *localState) syncCheck(id types.CheckID) error {
 
 	req := structs.RegisterRequest{
 		Datacenter:      l.config.Datacenter,
+		ID:              types.NodeID(l.config.NodeID),
 		Node:            l.config.NodeName,
 		Address:         l.config.AdvertiseAddr,
 		TaggedAddresses: l.config.TaggedAddresses,
+		NodeMeta:        l.metadata,
 		Service:         service,
 		Check:           l.checks[id],
 		WriteRequest:    structs.WriteRequest{Token: l.checkToken(id)},
@@ -706,9 +730,11 @@ func (l *localState) syncCheck(id types.CheckID) error {
 func (l *localState) syncNodeInfo() error {
 	req := structs.RegisterRequest{
 		Datacenter:      l.config.Datacenter,
+		ID:              types.NodeID(l.config.NodeID),
 		Node:            l.config.NodeName,
 		Address:         l.config.AdvertiseAddr,
 		TaggedAddresses: l.config.TaggedAddresses,
+		NodeMeta:        l.metadata,
 		WriteRequest:    structs.WriteRequest{Token: l.config.ACLToken},
 	}
 	var out struct{}
@@ -46,6 +46,7 @@ func TestAgentAntiEntropy_Services(t *testing.T) {
 		ID:      "redis",
 		Service: "redis",
 		Tags:    []string{},
+		Meta:    map[string]string{"version": "1.2.3"},
 		Port:    8000,
 	}
 	agent.state.AddService(srv2, "")
@@ -53,6 +54,7 @@ func TestAgentAntiEntropy_Services(t *testing.T) {
 	srv2_mod := new(structs.NodeService)
 	*srv2_mod = *srv2
 	srv2_mod.Port = 9000
+	srv2_mod.Meta = nil
 	args.Service = srv2_mod
 	if err := agent.RPC("Catalog.Register", args, &out); err != nil {
 		t.Fatalf("err: %v", err)
@@ -985,6 +987,7 @@ func TestAgentAntiEntropy_Check_DeferSync(t *testing.T) {
 
 func TestAgentAntiEntropy_NodeInfo(t *testing.T) {
 	conf := nextConfig()
+	conf.Meta = map[string]string{"somekey": "somevalue"}
 	dir, agent := makeAgent(t, conf)
 	defer os.RemoveAll(dir)
 	defer agent.Shutdown()
@@ -1023,6 +1026,14 @@ func TestAgentAntiEntropy_NodeInfo(t *testing.T) {
 		if len(addrs) == 0 || !reflect.DeepEqual(addrs, conf.TaggedAddresses) {
 			return false, fmt.Errorf("bad: %v", addrs)
 		}
+		id := services.NodeServices.Node.ID
+		if id == "" || id != types.NodeID(conf.NodeID) {
+			return false, fmt.Errorf("bad: %v", id)
+		}
+		meta := services.NodeServices.Node.Meta
+		if len(meta) == 0 || !reflect.DeepEqual(meta, conf.Meta) {
+			return false, fmt.Errorf("bad: %v", meta)
+		}
 
 		return true, nil
 	}, func(err error) {
@@ -1047,6 +1058,14 @@ func TestAgentAntiEntropy_NodeInfo(t *testing.T) {
 		if len(addrs) == 0 || !reflect.DeepEqual(addrs, conf.TaggedAddresses) {
 			return false, fmt.Errorf("bad: %v", addrs)
 		}
+		id := services.NodeServices.Node.ID
+		if id == "" || id != types.NodeID(conf.NodeID) {
+			return false, fmt.Errorf("bad: %v", id)
+		}
+		meta := services.NodeServices.Node.Meta
+		if len(meta) == 0 || !reflect.DeepEqual(meta, conf.Meta) {
+			return false, fmt.Errorf("bad: %v", meta)
+		}
 
 		return true, nil
 	}, func(err error) {
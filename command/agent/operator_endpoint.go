@@ -58,6 +58,77 @@ func (s *HTTPServer) OperatorRaftPeer(resp http.ResponseWriter, req *http.Reques
 	return nil, nil
 }
 
+// OperatorKVHistory is used to read and replace the rules that decide which
+// keys have their prior versions kept.
+func (s *HTTPServer) OperatorKVHistory(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	switch req.Method {
+	case "GET":
+		var args structs.DCSpecificRequest
+		if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+			return nil, nil
+		}
+
+		var reply structs.IndexedKVSHistoryRules
+		defer setMeta(resp, &reply.QueryMeta)
+		if err := s.agent.RPC("Operator.KVSHistoryRules", &args, &reply); err != nil {
+			return nil, err
+		}
+
+		// Use empty list instead of nil
+		if reply.Rules == nil {
+			reply.Rules = make(structs.KVSHistoryRules, 0)
+		}
+		return reply.Rules, nil
+
+	case "PUT":
+		args := structs.KVSHistoryRequest{
+			Op: structs.KVSHistorySetRules,
+		}
+		s.parseDC(req, &args.Datacenter)
+		s.parseToken(req, &args.Token)
+		if err := decodeBody(req, &args.Rules, nil); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			resp.Write([]byte(fmt.Sprintf("Request decode failed: %v", err)))
+			return nil, nil
+		}
+
+		var reply struct{}
+		if err := s.agent.RPC("Operator.KVSHistorySetRules", &args, &reply); err != nil {
+			return nil, err
+		}
+		return true, nil
+
+	default:
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return nil, nil
+	}
+}
+
+// OperatorKVQuotas is used to inspect the usage of each configured KV quota.
+func (s *HTTPServer) OperatorKVQuotas(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return nil, nil
+	}
+
+	var args structs.DCSpecificRequest
+	if done := s.parse(resp, req, &args.Datacenter, &args.QueryOptions); done {
+		return nil, nil
+	}
+
+	var reply structs.IndexedKVSQuotaUsage
+	if err := s.agent.RPC("Operator.KVSQuotaUsage", &args, &reply); err != nil {
+		return nil, err
+	}
+	setMeta(resp, &reply.QueryMeta)
+
+	// Use empty list instead of nil
+	if reply.Quotas == nil {
+		reply.Quotas = make([]*structs.KVSQuotaUsage, 0)
+	}
+	return reply.Quotas, nil
+}
+
 type keyringArgs struct {
 	Key   string
 	Token string
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/consul/structs"
 )
@@ -58,6 +60,95 @@ func TestOperator_OperatorRaftPeer(t *testing.T) {
 	})
 }
 
+func TestOperator_OperatorKVHistory(t *testing.T) {
+	httpTest(t, func(srv *HTTPServer) {
+		body := bytes.NewBuffer([]byte(`[{"Prefix": "app/", "Versions": 3}, {"Prefix": "config/", "Window": 3600000000000}]`))
+		req, err := http.NewRequest("PUT", "/v1/operator/kv/history", body)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp := httptest.NewRecorder()
+		obj, err := srv.OperatorKVHistory(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if res := obj.(bool); !res {
+			t.Fatalf("should work")
+		}
+
+		req, err = http.NewRequest("GET", "/v1/operator/kv/history", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp = httptest.NewRecorder()
+		obj, err = srv.OperatorKVHistory(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		assertIndex(t, resp)
+		out, ok := obj.(structs.KVSHistoryRules)
+		if !ok {
+			t.Fatalf("unexpected: %T", obj)
+		}
+		expected := structs.KVSHistoryRules{
+			&structs.KVSHistoryRule{Prefix: "app/", Versions: 3},
+			&structs.KVSHistoryRule{Prefix: "config/", Window: time.Hour},
+		}
+		if !reflect.DeepEqual(out, expected) {
+			t.Fatalf("bad: %v", out)
+		}
+	})
+}
+
+func TestOperator_OperatorKVQuotas(t *testing.T) {
+	httpTestWithConfig(t, func(srv *HTTPServer) {
+		put := func(key string) *httptest.ResponseRecorder {
+			body := bytes.NewBuffer([]byte("test"))
+			req, err := http.NewRequest("PUT", "/v1/kv/"+key, body)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			resp := httptest.NewRecorder()
+			srv.wrap(srv.KVSEndpoint)(resp, req)
+			return resp
+		}
+
+		// Writes over the quota get a 429.
+		if resp := put("tmp/a"); resp.Code != 200 {
+			t.Fatalf("bad code: %d", resp.Code)
+		}
+		resp := put("tmp/b")
+		if resp.Code != 429 || !strings.Contains(resp.Body.String(), "Quota exceeded") {
+			t.Fatalf("bad: %d %s", resp.Code, resp.Body.String())
+		}
+
+		req, err := http.NewRequest("GET", "/v1/operator/kv/quotas", nil)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp = httptest.NewRecorder()
+		obj, err := srv.OperatorKVQuotas(resp, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.Code != 200 {
+			t.Fatalf("bad code: %d", resp.Code)
+		}
+		out, ok := obj.([]*structs.KVSQuotaUsage)
+		if !ok {
+			t.Fatalf("unexpected: %T", obj)
+		}
+		if len(out) != 1 || out[0].Prefix != "tmp/" || out[0].MaxKeys != 1 ||
+			out[0].Keys != 1 || out[0].Bytes != 4 {
+			t.Fatalf("bad: %v", out)
+		}
+	}, func(c *Config) {
+		c.KVQuotas = []*KVQuotaConfig{
+			&KVQuotaConfig{Prefix: "tmp/", MaxKeys: 1},
+		}
+	})
+}
+
 func TestOperator_KeyringInstall(t *testing.T) {
 	oldKey := "H3/9gBxcKKRf45CaI2DlRg=="
 	newKey := "z90lFx3sZZLtTOkutXcwYg=="
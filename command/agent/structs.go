@@ -11,7 +11,9 @@ type ServiceDefinition struct {
 	Name              string
 	Tags              []string
 	Address           string
+	Meta              map[string]string
 	Port              int
+	Weights           *structs.Weights
 	Check             CheckType
 	Checks            CheckTypes
 	Token             string
@@ -24,7 +26,9 @@ func (s *ServiceDefinition) NodeService() *structs.NodeService {
 		Service:           s.Name,
 		Tags:              s.Tags,
 		Address:           s.Address,
+		Meta:              s.Meta,
 		Port:              s.Port,
+		Weights:           s.Weights,
 		EnableTagOverride: s.EnableTagOverride,
 	}
 	if ns.ID == "" && ns.Service != "" {
@@ -8,6 +8,7 @@ import (
 
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/consul/structs"
+	"github.com/hashicorp/consul/types"
 )
 
 const (
@@ -136,6 +137,7 @@ func (s *HTTPServer) convertOps(resp http.ResponseWriter, req *http.Request) (st
 						Value:   in.KV.Value,
 						Flags:   in.KV.Flags,
 						Session: in.KV.Session,
+						TTL:     in.KV.TTL,
 						RaftIndex: structs.RaftIndex{
 							ModifyIndex: in.KV.Index,
 						},
@@ -143,7 +145,86 @@ func (s *HTTPServer) convertOps(resp http.ResponseWriter, req *http.Request) (st
 				},
 			}
 			opsRPC = append(opsRPC, out)
+			continue
 		}
+
+		out := &structs.TxnOp{}
+		switch {
+		case in.Node != nil:
+			node := in.Node.Node
+			out.Node = &structs.TxnNodeOp{
+				Verb: structs.TxnVerb(in.Node.Verb),
+				Node: structs.Node{
+					ID:              types.NodeID(node.ID),
+					Node:            node.Node,
+					Address:         node.Address,
+					TaggedAddresses: node.TaggedAddresses,
+					Meta:            node.Meta,
+					RaftIndex: structs.RaftIndex{
+						ModifyIndex: node.ModifyIndex,
+					},
+				},
+			}
+
+		case in.Service != nil:
+			svc := in.Service.Service
+			out.Service = &structs.TxnServiceOp{
+				Verb: structs.TxnVerb(in.Service.Verb),
+				Node: in.Service.Node,
+				Service: structs.NodeService{
+					ID:                svc.ID,
+					Service:           svc.Service,
+					Tags:              svc.Tags,
+					Address:           svc.Address,
+					Meta:              svc.Meta,
+					Port:              svc.Port,
+					EnableTagOverride: svc.EnableTagOverride,
+					RaftIndex: structs.RaftIndex{
+						ModifyIndex: svc.ModifyIndex,
+					},
+				},
+			}
+			if svc.Weights != nil {
+				out.Service.Service.Weights = &structs.Weights{
+					Passing: svc.Weights.Passing,
+					Warning: svc.Weights.Warning,
+				}
+			}
+
+		case in.Check != nil:
+			check := in.Check.Check
+			out.Check = &structs.TxnCheckOp{
+				Verb: structs.TxnVerb(in.Check.Verb),
+				Check: structs.HealthCheck{
+					Node:      check.Node,
+					CheckID:   types.CheckID(check.CheckID),
+					Name:      check.Name,
+					Status:    check.Status,
+					Notes:     check.Notes,
+					Output:    check.Output,
+					ServiceID: check.ServiceID,
+					RaftIndex: structs.RaftIndex{
+						ModifyIndex: check.ModifyIndex,
+					},
+				},
+			}
+
+		case in.Session != nil:
+			out.Session = &structs.TxnSessionOp{
+				Verb: structs.TxnVerb(in.Session.Verb),
+				Session: structs.Session{
+					ID: in.Session.Session.ID,
+				},
+			}
+
+		default:
+			// Leave the op empty so it fails in the transaction with
+			// an error pointing at it.
+		}
+		if out.IsWrite() {
+			writes += 1
+		}
+		opsRPC = append(opsRPC, out)
 	}
 
 	// Enforce an overall size limit to help prevent abuse.
@@ -166,7 +166,8 @@ func TestTxnEndpoint_KV_Actions(t *testing.T) {
             "Key": "key",
             "Value": "aGVsbG8gd29ybGQ=",
             "Flags": 23,
-            "Session": %q
+            "Session": %q,
+            "TTL": "10m"
         }
     },
     {
@@ -207,6 +208,7 @@ func TestTxnEndpoint_KV_Actions(t *testing.T) {
 							Value:     nil,
 							Flags:     23,
 							Session:   id,
+							TTL:       "10m",
 							LockIndex: 1,
 							RaftIndex: structs.RaftIndex{
 								CreateIndex: index,
@@ -220,6 +222,7 @@ func TestTxnEndpoint_KV_Actions(t *testing.T) {
 							Value:     []byte("hello world"),
 							Flags:     23,
 							Session:   id,
+							TTL:       "10m",
 							LockIndex: 1,
 							RaftIndex: structs.RaftIndex{
 								CreateIndex: index,
@@ -289,6 +292,7 @@ func TestTxnEndpoint_KV_Actions(t *testing.T) {
 								Value:     []byte("hello world"),
 								Flags:     23,
 								Session:   id,
+								TTL:       "10m",
 								LockIndex: 1,
 								RaftIndex: structs.RaftIndex{
 									CreateIndex: index,
@@ -302,6 +306,7 @@ func TestTxnEndpoint_KV_Actions(t *testing.T) {
 								Value:     []byte("hello world"),
 								Flags:     23,
 								Session:   id,
+								TTL:       "10m",
 								LockIndex: 1,
 								RaftIndex: structs.RaftIndex{
 									CreateIndex: index,
@@ -432,3 +437,197 @@ func TestTxnEndpoint_KV_Actions(t *testing.T) {
 		}
 	})
 }
+
+func TestTxnEndpoint_Catalog_Actions(t *testing.T) {
+	httpTest(t, func(srv *HTTPServer) {
+		// Make sure all incoming fields get converted properly to the
+		// internal RPC format.
+		id := makeTestSession(t, srv)
+		{
+			buf := bytes.NewBuffer([]byte(fmt.Sprintf(`
+[
+    {
+        "Node": {
+            "Verb": "set",
+            "Node": {
+                "Node": "node2",
+                "Address": "10.0.0.2",
+                "Meta": {"rack": "a"}
+            }
+        }
+    },
+    {
+        "Service": {
+            "Verb": "set",
+            "Node": "node2",
+            "Service": {
+                "Service": "web",
+                "Tags": ["primary"],
+                "Port": 80,
+                "Weights": {"Passing": 3, "Warning": 1}
+            }
+        }
+    },
+    {
+        "Check": {
+            "Verb": "set",
+            "Check": {
+                "Node": "node2",
+                "Name": "web-alive",
+                "Status": "passing",
+                "ServiceID": "web"
+            }
+        }
+    },
+    {
+        "KV": {
+            "Verb": "lock",
+            "Key": "web/leader",
+            "Session": %q
+        }
+    }
+]
+`, id)))
+			req, err := http.NewRequest("PUT", "/v1/txn", buf)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			resp := httptest.NewRecorder()
+			obj, err := srv.Txn(resp, req)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if resp.Code != 200 {
+				t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+			}
+
+			txnResp, ok := obj.(structs.TxnResponse)
+			if !ok {
+				t.Fatalf("bad type: %T", obj)
+			}
+			if len(txnResp.Results) != 4 {
+				t.Fatalf("bad: %v", txnResp)
+			}
+			node := txnResp.Results[0].Node
+			if node == nil || node.Address != "10.0.0.2" || node.Meta["rack"] != "a" {
+				t.Fatalf("bad: %#v", txnResp.Results[0])
+			}
+			svc := txnResp.Results[1].Service
+			if svc == nil || svc.ID != "web" || svc.Port != 80 ||
+				!reflect.DeepEqual(svc.Tags, []string{"primary"}) ||
+				!reflect.DeepEqual(svc.Weights, &structs.Weights{Passing: 3, Warning: 1}) {
+				t.Fatalf("bad: %#v", txnResp.Results[1])
+			}
+			check := txnResp.Results[2].Check
+			if check == nil || check.CheckID != "web-alive" || check.Status != structs.HealthPassing {
+				t.Fatalf("bad: %#v", txnResp.Results[2])
+			}
+		}
+
+		// A read-only transaction goes through the fast path.
+		{
+			buf := bytes.NewBuffer([]byte(`
+[
+    {
+        "Service": {
+            "Verb": "get",
+            "Node": "node2",
+            "Service": {"ID": "web"}
+        }
+    },
+    {
+        "Session": {
+            "Verb": "get",
+            "Session": {"ID": "` + id + `"}
+        }
+    }
+]
+`))
+			req, err := http.NewRequest("PUT", "/v1/txn", buf)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			resp := httptest.NewRecorder()
+			obj, err := srv.Txn(resp, req)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if resp.Code != 200 {
+				t.Fatalf("expected 200, got %d", resp.Code)
+			}
+
+			txnResp, ok := obj.(structs.TxnReadResponse)
+			if !ok {
+				t.Fatalf("bad type: %T", obj)
+			}
+			if len(txnResp.Results) != 2 ||
+				txnResp.Results[0].Service == nil || txnResp.Results[0].Service.Port != 80 ||
+				txnResp.Results[1].Session == nil || txnResp.Results[1].Session.ID != id {
+				t.Fatalf("bad: %v", txnResp)
+			}
+		}
+
+		// Deregister the service and release its lock in one step, using
+		// a stale index for the service first so the whole thing fails.
+		for _, index := range []uint64{1, 0} {
+			verb := "delete"
+			if index != 0 {
+				verb = "delete-cas"
+			}
+			buf := bytes.NewBuffer([]byte(fmt.Sprintf(`
+[
+    {
+        "Service": {
+            "Verb": %q,
+            "Node": "node2",
+            "Service": {"ID": "web", "ModifyIndex": %d}
+        }
+    },
+    {
+        "Session": {
+            "Verb": "delete",
+            "Session": {"ID": %q}
+        }
+    }
+]
+`, verb, index, id)))
+			req, err := http.NewRequest("PUT", "/v1/txn", buf)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			resp := httptest.NewRecorder()
+			if _, err := srv.Txn(resp, req); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if index != 0 {
+				if resp.Code != 409 || !strings.Contains(resp.Body.String(), "index is stale") {
+					t.Fatalf("bad: %d %s", resp.Code, resp.Body.String())
+				}
+				continue
+			}
+			if resp.Code != 200 {
+				t.Fatalf("expected 200, got %d", resp.Code)
+			}
+		}
+
+		var services structs.IndexedNodeServices
+		args := structs.NodeSpecificRequest{Datacenter: "dc1", Node: "node2"}
+		if err := srv.agent.RPC("Catalog.NodeServices", &args, &services); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, ok := services.NodeServices.Services["web"]; ok {
+			t.Fatalf("bad: %v", services.NodeServices.Services)
+		}
+		var entries structs.IndexedDirEntries
+		getArgs := structs.KeyRequest{Datacenter: "dc1", Key: "web/leader"}
+		if err := srv.agent.RPC("KVS.Get", &getArgs, &entries); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if len(entries.Entries) != 1 || entries.Entries[0].Session != "" {
+			t.Fatalf("bad: %v", entries.Entries)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package command
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// kvExportEntry is the format of a single key-value pair in the documents
+// written by "consul kv export" and read by "consul kv import".
+type kvExportEntry struct {
+	Key   string `json:"key"`
+	Flags uint64 `json:"flags"`
+	Value string `json:"value"`
+}
+
+// toExportEntry converts a key-value pair into the export format, with the
+// value base64-encoded so any data survives the round trip through JSON.
+func toExportEntry(pair *api.KVPair) *kvExportEntry {
+	return &kvExportEntry{
+		Key:   pair.Key,
+		Flags: pair.Flags,
+		Value: base64.StdEncoding.EncodeToString(pair.Value),
+	}
+}
+
+// KVExportCommand is a Command implementation that is used to export a prefix
+// of the key-value store as a JSON document.
+type KVExportCommand struct {
+	Ui cli.Ui
+}
+
+func (c *KVExportCommand) Help() string {
+	helpText := `
+Usage: consul kv export [options] [PREFIX]
+
+  Writes all the key-value pairs under the given prefix to stdout as a JSON
+  document, which can be loaded back with "consul kv import". Each pair has
+  its key, its flags and its value encoded in base64.
+
+  To export the whole tree under "vault/":
+
+      $ consul kv export vault/ > vault.json
+
+  If no prefix is given, the entire key-value store is exported. Sessions,
+  indexes and TTLs are not exported.
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *KVExportCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("export", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	stale := cmdFlags.Bool("stale", false, "")
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	prefix := ""
+
+	// Check for arg validation
+	args = cmdFlags.Args()
+	switch len(args) {
+	case 0:
+		prefix = ""
+	case 1:
+		prefix = args[0]
+	default:
+		c.Ui.Error(fmt.Sprintf("Too many arguments (expected 1, got %d)", len(args)))
+		return 1
+	}
+
+	// Since pairs cannot start with a /, strip it for the user.
+	if len(prefix) > 0 && prefix[0] == '/' {
+		prefix = prefix[1:]
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	pairs, _, err := client.KV().List(prefix, &api.QueryOptions{
+		Datacenter: *datacenter,
+		AllowStale: *stale,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+		return 1
+	}
+
+	exported := make([]*kvExportEntry, 0, len(pairs))
+	for _, pair := range pairs {
+		exported = append(exported, toExportEntry(pair))
+	}
+
+	marshaled, err := json.MarshalIndent(exported, "", "\t")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error exporting KV data: %s", err))
+		return 1
+	}
+
+	c.Ui.Info(string(marshaled))
+	return 0
+}
+
+func (c *KVExportCommand) Synopsis() string {
+	return "Exports a tree from the KV store as JSON"
+}
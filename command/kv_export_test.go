@@ -0,0 +1,85 @@
+package command
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+func TestKVExportCommand_implements(t *testing.T) {
+	var _ cli.Command = &KVExportCommand{}
+}
+
+func TestKVExportCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(KVExportCommand))
+}
+
+func TestKVExportCommand_Validation(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &KVExportCommand{Ui: ui}
+
+	code := c.Run([]string{"foo", "bar"})
+	if code == 0 {
+		t.Fatalf("expected non-zero exit")
+	}
+	if output := ui.ErrorWriter.String(); !strings.Contains(output, "Too many arguments") {
+		t.Fatalf("bad: %#v", output)
+	}
+}
+
+func TestKVExportCommand_Run(t *testing.T) {
+	srv, client := testAgentWithAPIClient(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVExportCommand{Ui: ui}
+
+	keys := map[string]string{
+		"foo/a": "a",
+		"foo/b": "b",
+		"foo/c": "\x00\xff",
+		"bar":   "bar",
+	}
+	for k, v := range keys {
+		pair := &api.KVPair{Key: k, Flags: 42, Value: []byte(v)}
+		if _, err := client.KV().Put(pair, nil); err != nil {
+			t.Fatalf("err: %#v", err)
+		}
+	}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"foo",
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	var exported []*kvExportEntry
+	if err := json.Unmarshal(ui.OutputWriter.Bytes(), &exported); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(exported) != 3 {
+		t.Fatalf("bad: %#v", exported)
+	}
+	for i, key := range []string{"foo/a", "foo/b", "foo/c"} {
+		entry := exported[i]
+		if entry.Key != key || entry.Flags != 42 {
+			t.Fatalf("bad: %#v", entry)
+		}
+		value, err := base64.StdEncoding.DecodeString(entry.Value)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if string(value) != keys[key] {
+			t.Fatalf("bad: %#v", entry)
+		}
+	}
+}
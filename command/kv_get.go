@@ -43,6 +43,17 @@ Usage: consul kv get [options] [KEY_OR_PREFIX]
 
       $ consul kv get -recurse foo
 
+  To read a key as it was at an earlier index, specify the "-at" flag. This
+  uses the key's history, which is only kept for keys covered by one of the KV
+  history rules:
+
+      $ consul kv get -at=42 foo
+
+  Large prefixes can be fetched in pages, which keeps the responses from the
+  servers small, by specifying the "-page-size" flag:
+
+      $ consul kv get -recurse -page-size=1000 foo
+
   This will return all key-vlaue pairs. To just list the keys which start with
   the specified prefix, use the "-keys" option instead:
 
@@ -54,6 +65,10 @@ Usage: consul kv get [options] [KEY_OR_PREFIX]
 
 KV Get Options:
 
+  -at=<int>               Read the key as it was at the given index, using the
+                          key's history. This cannot be combined with the
+                          -keys or -recurse flags.
+
   -detailed               Provide additional metadata about the key in addition
                           to the value such as the ModifyIndex and any flags
                           that may have been set on the key. The default value
@@ -65,6 +80,14 @@ KV Get Options:
                           combined with the -separator option. The default value
                           is false.
 
+  -page-size=<int>        Fetch the keys or key-value pairs from the servers
+                          in pages of this size, rather than in one response.
+                          This is only taken into account when paired with the
+                          -keys or -recurse flags. Since each page is a separate
+                          request, the results may not be a consistent snapshot
+                          if the keys change in the meantime. The default value
+                          is 0, which fetches everything at once.
+
   -recurse                Recursively look at all keys prefixed with the given
                           path. The default value is false.
 
@@ -86,6 +109,8 @@ func (c *KVGetCommand) Run(args []string) int {
 	keys := cmdFlags.Bool("keys", false, "")
 	recurse := cmdFlags.Bool("recurse", false, "")
 	separator := cmdFlags.String("separator", "/", "")
+	at := cmdFlags.Uint64("at", 0, "")
+	pageSize := cmdFlags.Int("page-size", 0, "")
 	httpAddr := HTTPAddrFlag(cmdFlags)
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
@@ -119,6 +144,17 @@ func (c *KVGetCommand) Run(args []string) int {
 		return 1
 	}
 
+	if *pageSize < 0 {
+		c.Ui.Error("Error! -page-size must be >= 0")
+		return 1
+	}
+
+	// Point-in-time reads are only for a single key.
+	if *at > 0 && (*recurse || *keys) {
+		c.Ui.Error("Error! Cannot use -at with -keys or -recurse")
+		return 1
+	}
+
 	// Create and test the HTTP client
 	conf := api.DefaultConfig()
 	conf.Address = *httpAddr
@@ -131,60 +167,86 @@ func (c *KVGetCommand) Run(args []string) int {
 
 	switch {
 	case *keys:
-		keys, _, err := client.KV().Keys(key, *separator, &api.QueryOptions{
+		qo := &api.QueryOptions{
 			Datacenter: *datacenter,
 			AllowStale: *stale,
-		})
-		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
-			return 1
+			Limit:      *pageSize,
 		}
+		for {
+			keys, qm, err := client.KV().Keys(key, *separator, qo)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+				return 1
+			}
 
-		for _, k := range keys {
-			c.Ui.Info(string(k))
-		}
+			for _, k := range keys {
+				c.Ui.Info(string(k))
+			}
 
-		return 0
+			if qm.NextAfter == "" {
+				return 0
+			}
+			qo.After = qm.NextAfter
+		}
 	case *recurse:
-		pairs, _, err := client.KV().List(key, &api.QueryOptions{
+		qo := &api.QueryOptions{
 			Datacenter: *datacenter,
 			AllowStale: *stale,
-		})
-		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
-			return 1
+			Limit:      *pageSize,
 		}
+		first := true
+		for {
+			pairs, qm, err := client.KV().List(key, qo)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+				return 1
+			}
 
-		for i, pair := range pairs {
-			if *detailed {
-				var b bytes.Buffer
-				if err := prettyKVPair(&b, pair); err != nil {
-					c.Ui.Error(fmt.Sprintf("Error rendering KV pair: %s", err))
-					return 1
+			for _, pair := range pairs {
+				if *detailed {
+					var b bytes.Buffer
+					if err := prettyKVPair(&b, pair); err != nil {
+						c.Ui.Error(fmt.Sprintf("Error rendering KV pair: %s", err))
+						return 1
+					}
+
+					if !first {
+						c.Ui.Info("")
+					}
+					c.Ui.Info(b.String())
+				} else {
+					c.Ui.Info(fmt.Sprintf("%s:%s", pair.Key, pair.Value))
 				}
+				first = false
+			}
 
-				c.Ui.Info(b.String())
-
-				if i < len(pairs)-1 {
-					c.Ui.Info("")
-				}
-			} else {
-				c.Ui.Info(fmt.Sprintf("%s:%s", pair.Key, pair.Value))
+			if qm.NextAfter == "" {
+				return 0
 			}
+			qo.After = qm.NextAfter
 		}
-
-		return 0
 	default:
-		pair, _, err := client.KV().Get(key, &api.QueryOptions{
+		qo := &api.QueryOptions{
 			Datacenter: *datacenter,
 			AllowStale: *stale,
-		})
+		}
+
+		var pair *api.KVPair
+		if *at > 0 {
+			pair, _, err = client.KV().GetAt(key, *at, qo)
+		} else {
+			pair, _, err = client.KV().Get(key, qo)
+		}
 		if err != nil {
 			c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
 			return 1
 		}
 
 		if pair == nil {
+			if *at > 0 {
+				c.Ui.Error(fmt.Sprintf("Error! No version of %s is known at index %d", key, *at))
+				return 1
+			}
 			c.Ui.Error(fmt.Sprintf("Error! No key exists at: %s", key))
 			return 1
 		}
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -32,6 +33,14 @@ func TestKVGetCommand_Validation(t *testing.T) {
 			[]string{"foo", "bar", "baz"},
 			"Too many arguments",
 		},
+		"negative page size": {
+			[]string{"-page-size=-1", "-recurse", "foo"},
+			"-page-size must be >= 0",
+		},
+		"at with recurse": {
+			[]string{"-at=5", "-recurse", "foo"},
+			"Cannot use -at",
+		},
 	}
 
 	for name, tc := range cases {
@@ -250,3 +259,98 @@ func TestKVGetCommand_Recurse(t *testing.T) {
 		}
 	}
 }
+
+func TestKVGetCommand_RecursePaged(t *testing.T) {
+	srv, client := testAgentWithAPIClient(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVGetCommand{Ui: ui}
+
+	keys := []string{"foo/a", "foo/b", "foo/c", "foo/d/e"}
+	for _, k := range keys {
+		pair := &api.KVPair{Key: k, Value: []byte(k)}
+		if _, err := client.KV().Put(pair, nil); err != nil {
+			t.Fatalf("err: %#v", err)
+		}
+	}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-recurse",
+		"-page-size=1",
+		"foo",
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	var expected string
+	for _, k := range keys {
+		expected += k + ":" + k + "\n"
+	}
+	if output := ui.OutputWriter.String(); output != expected {
+		t.Fatalf("bad: %#v", output)
+	}
+
+	// Keys can be paged too
+	ui.OutputWriter.Reset()
+	args = []string{
+		"-http-addr=" + srv.httpAddr,
+		"-keys",
+		"-page-size=2",
+		"foo/",
+	}
+
+	code = c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	if output := ui.OutputWriter.String(); output != "foo/a\nfoo/b\nfoo/c\nfoo/d/\n" {
+		t.Fatalf("bad: %#v", output)
+	}
+}
+
+func TestKVGetCommand_At(t *testing.T) {
+	srv, client := testAgentWithKVHistory(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVGetCommand{Ui: ui}
+
+	var indexes []uint64
+	for _, value := range []string{"bar", "baz"} {
+		pair := &api.KVPair{
+			Key:   "foo",
+			Value: []byte(value),
+		}
+		if _, err := client.KV().Put(pair, nil); err != nil {
+			t.Fatalf("err: %#v", err)
+		}
+		pair, _, err := client.KV().Get("foo", nil)
+		if err != nil {
+			t.Fatalf("err: %#v", err)
+		}
+		indexes = append(indexes, pair.ModifyIndex)
+	}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		fmt.Sprintf("-at=%d", indexes[0]),
+		"foo",
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if output != "bar\n" {
+		t.Errorf("bad: %#v", output)
+	}
+}
@@ -0,0 +1,155 @@
+package command
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// KVHistoryCommand is a Command implementation that is used to list the prior
+// versions of a key in the key-value store.
+type KVHistoryCommand struct {
+	Ui cli.Ui
+}
+
+func (c *KVHistoryCommand) Help() string {
+	helpText := `
+Usage: consul kv history [options] KEY
+
+  Lists the prior versions of the given key, oldest first. Prior versions are
+  only kept for keys covered by one of the KV history rules, and the current
+  value of the key is not included.
+
+  To list the prior versions of the key named "foo":
+
+      $ consul kv history foo
+
+  Each version is shown with the index at which it was written. To view all
+  known metadata about each version, including the index at which it was
+  replaced, specify the "-detailed" flag:
+
+      $ consul kv history -detailed foo
+
+  A key can be read as it was at any index within a version's lifetime with
+  "consul kv get -at", or rolled back to it with "consul kv rollback".
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText + `
+
+KV History Options:
+
+  -detailed               Provide additional metadata about each version in
+                          addition to the value, such as the index at which it
+                          was replaced and any flags that were set on it. The
+                          default value is false.
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *KVHistoryCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("history", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	stale := cmdFlags.Bool("stale", false, "")
+	detailed := cmdFlags.Bool("detailed", false, "")
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	key := ""
+
+	// Check for arg validation
+	args = cmdFlags.Args()
+	switch len(args) {
+	case 0:
+		key = ""
+	case 1:
+		key = args[0]
+	default:
+		c.Ui.Error(fmt.Sprintf("Too many arguments (expected 1, got %d)", len(args)))
+		return 1
+	}
+
+	// Strip any leading slash, since pairs cannot start with one.
+	if len(key) > 0 && key[0] == '/' {
+		key = key[1:]
+	}
+	if key == "" {
+		c.Ui.Error("Error! Missing KEY argument")
+		return 1
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	history, _, err := client.KV().History(key, &api.QueryOptions{
+		Datacenter: *datacenter,
+		AllowStale: *stale,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+		return 1
+	}
+
+	if len(history) == 0 {
+		c.Ui.Error(fmt.Sprintf("Error! No history exists for: %s", key))
+		return 1
+	}
+
+	for i, version := range history {
+		if *detailed {
+			var b bytes.Buffer
+			if err := prettyKVHistoryEntry(&b, version); err != nil {
+				c.Ui.Error(fmt.Sprintf("Error rendering KV history: %s", err))
+				return 1
+			}
+
+			c.Ui.Info(b.String())
+
+			if i < len(history)-1 {
+				c.Ui.Info("")
+			}
+		} else {
+			c.Ui.Info(fmt.Sprintf("%d:%s", version.ModifyIndex, version.Value))
+		}
+	}
+	return 0
+}
+
+func (c *KVHistoryCommand) Synopsis() string {
+	return "Lists the prior versions of a key"
+}
+
+func prettyKVHistoryEntry(w io.Writer, version *api.KVHistoryEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 2, 6, ' ', 0)
+	fmt.Fprintf(tw, "CreateIndex\t%d\n", version.CreateIndex)
+	fmt.Fprintf(tw, "Flags\t%d\n", version.Flags)
+	fmt.Fprintf(tw, "Key\t%s\n", version.Key)
+	fmt.Fprintf(tw, "LockIndex\t%d\n", version.LockIndex)
+	fmt.Fprintf(tw, "ModifyIndex\t%d\n", version.ModifyIndex)
+	fmt.Fprintf(tw, "ReplacedIndex\t%d\n", version.ReplacedIndex)
+	if version.Session == "" {
+		fmt.Fprintf(tw, "Session\t-\n")
+	} else {
+		fmt.Fprintf(tw, "Session\t%s\n", version.Session)
+	}
+	fmt.Fprintf(tw, "Value\t%s", version.Value)
+	return tw.Flush()
+}
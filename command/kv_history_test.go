@@ -0,0 +1,183 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/command/agent"
+	"github.com/mitchellh/cli"
+)
+
+// testAgentWithKVHistory starts an agent that keeps the history of every
+// key, along with an API client for it.
+func testAgentWithKVHistory(t *testing.T) (*agentWrapper, *api.Client) {
+	srv := testAgentWithConfig(t, func(c *agent.Config) {
+		c.KVHistory = []*agent.KVHistoryConfig{
+			&agent.KVHistoryConfig{Prefix: ""},
+		}
+	})
+	client, err := api.NewClient(&api.Config{Address: srv.httpAddr})
+	if err != nil {
+		t.Fatalf("consul client: %#v", err)
+	}
+	return srv, client
+}
+
+func TestKVHistoryCommand_implements(t *testing.T) {
+	var _ cli.Command = &KVHistoryCommand{}
+}
+
+func TestKVHistoryCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(KVHistoryCommand))
+}
+
+func TestKVHistoryCommand_Validation(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &KVHistoryCommand{Ui: ui}
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"no key": {
+			[]string{},
+			"Missing KEY argument",
+		},
+		"extra args": {
+			[]string{"foo", "bar", "baz"},
+			"Too many arguments",
+		},
+	}
+
+	for name, tc := range cases {
+		// Ensure our buffer is always clear
+		if ui.ErrorWriter != nil {
+			ui.ErrorWriter.Reset()
+		}
+		if ui.OutputWriter != nil {
+			ui.OutputWriter.Reset()
+		}
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestKVHistoryCommand_Run(t *testing.T) {
+	srv, client := testAgentWithKVHistory(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVHistoryCommand{Ui: ui}
+
+	var indexes []uint64
+	for _, value := range []string{"bar", "baz", "zip"} {
+		pair := &api.KVPair{
+			Key:   "foo",
+			Value: []byte(value),
+		}
+		if _, err := client.KV().Put(pair, nil); err != nil {
+			t.Fatalf("err: %#v", err)
+		}
+		pair, _, err := client.KV().Get("foo", nil)
+		if err != nil {
+			t.Fatalf("err: %#v", err)
+		}
+		indexes = append(indexes, pair.ModifyIndex)
+	}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"foo",
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	for i, value := range []string{"bar", "baz"} {
+		line := fmt.Sprintf("%d:%s", indexes[i], value)
+		if !strings.Contains(output, line) {
+			t.Errorf("bad: %#v missing %q", output, line)
+		}
+	}
+	if strings.Contains(output, "zip") {
+		t.Errorf("bad: %#v", output)
+	}
+}
+
+func TestKVHistoryCommand_Detailed(t *testing.T) {
+	srv, client := testAgentWithKVHistory(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVHistoryCommand{Ui: ui}
+
+	for _, value := range []string{"bar", "baz"} {
+		pair := &api.KVPair{
+			Key:   "foo",
+			Value: []byte(value),
+		}
+		if _, err := client.KV().Put(pair, nil); err != nil {
+			t.Fatalf("err: %#v", err)
+		}
+	}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-detailed",
+		"foo",
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	for _, key := range []string{
+		"CreateIndex",
+		"LockIndex",
+		"ModifyIndex",
+		"ReplacedIndex",
+		"Flags",
+		"Session",
+		"Value",
+	} {
+		if !strings.Contains(output, key) {
+			t.Fatalf("bad %#v, missing %q", output, key)
+		}
+	}
+}
+
+func TestKVHistoryCommand_Missing(t *testing.T) {
+	srv, _ := testAgentWithKVHistory(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVHistoryCommand{Ui: ui}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"not-a-real-key",
+	}
+
+	code := c.Run(args)
+	if code == 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+}
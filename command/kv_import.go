@@ -0,0 +1,221 @@
+package command
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// kvImportBatchSize is the number of keys written in each transaction. This
+// matches the largest transaction the agent's HTTP API will accept.
+const kvImportBatchSize = 64
+
+// KVImportCommand is a Command implementation that is used to load a JSON
+// document written by "consul kv export" into the key-value store.
+type KVImportCommand struct {
+	Ui cli.Ui
+
+	// testStdin is the input for testing.
+	testStdin io.Reader
+}
+
+func (c *KVImportCommand) Help() string {
+	helpText := `
+Usage: consul kv import [options] [DATA]
+
+  Writes the key-value pairs from a JSON document created by "consul kv export"
+  into the key-value store. The data can be consumed from a file on disk by
+  prefixing with the "@" symbol:
+
+      $ consul kv import @vault.json
+
+  Or it can be read from stdin using the "-" symbol, which is also the default
+  if DATA is omitted:
+
+      $ consul kv export vault/ | consul kv import -
+
+  The pairs are written in transactions of up to 64 keys each, so each batch
+  is applied atomically. If a batch fails, the batches before it remain
+  written and the import stops.
+
+  Keys can be moved to a different prefix as they are imported, by removing
+  one prefix and adding another:
+
+      $ consul kv import -strip-prefix=dc1/app/ -prefix=dc2/app/ @app.json
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText + `
+
+KV Import Options:
+
+  -dry-run                Show the keys that would be written, after any
+                          prefix rewriting, without writing anything. The
+                          default value is false.
+
+  -prefix=<string>        Prefix to add to every key before it's written. This
+                          is applied after -strip-prefix. The default value is
+                          empty.
+
+  -strip-prefix=<string>  Prefix to remove from every key before it's written.
+                          Every key in the data must start with this prefix.
+                          The default value is empty.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *KVImportCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("import", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	prefix := cmdFlags.String("prefix", "", "")
+	stripPrefix := cmdFlags.String("strip-prefix", "", "")
+	dryRun := cmdFlags.Bool("dry-run", false, "")
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	args = cmdFlags.Args()
+	data, err := c.dataFromArgs(args)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! %s", err))
+		return 1
+	}
+
+	var entries []*kvExportEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! Cannot parse import data: %s", err))
+		return 1
+	}
+
+	// Check and rewrite every entry before anything is written, so bad data
+	// doesn't leave a partial import behind.
+	ops := make(api.KVTxnOps, 0, len(entries))
+	for _, entry := range entries {
+		if entry == nil {
+			c.Ui.Error("Error! Import data has a null entry")
+			return 1
+		}
+		if !strings.HasPrefix(entry.Key, *stripPrefix) {
+			c.Ui.Error(fmt.Sprintf("Error! Key %q does not start with %q", entry.Key, *stripPrefix))
+			return 1
+		}
+		key := *prefix + strings.TrimPrefix(entry.Key, *stripPrefix)
+		if key == "" || key[0] == '/' {
+			c.Ui.Error(fmt.Sprintf("Error! Key %q would be imported as invalid key %q", entry.Key, key))
+			return 1
+		}
+		value, err := base64.StdEncoding.DecodeString(entry.Value)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error! Cannot decode value of key %q: %s", entry.Key, err))
+			return 1
+		}
+		ops = append(ops, &api.KVTxnOp{
+			Verb:  api.KVSet,
+			Key:   key,
+			Flags: entry.Flags,
+			Value: value,
+		})
+	}
+
+	if *dryRun {
+		for _, op := range ops {
+			c.Ui.Info(fmt.Sprintf("Would import: %s", op.Key))
+		}
+		c.Ui.Info(fmt.Sprintf("Dry run, %d keys would be imported", len(ops)))
+		return 0
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	qo := &api.QueryOptions{
+		Datacenter: *datacenter,
+	}
+	for start := 0; start < len(ops); start += kvImportBatchSize {
+		end := start + kvImportBatchSize
+		if end > len(ops) {
+			end = len(ops)
+		}
+		batch := ops[start:end]
+
+		ok, resp, _, err := client.KV().Txn(batch, qo)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error! Failed writing data: %s", err))
+			c.Ui.Error(fmt.Sprintf("Imported %d of %d keys before the failure", start, len(ops)))
+			return 1
+		}
+		if !ok {
+			for _, txnErr := range resp.Errors {
+				c.Ui.Error(fmt.Sprintf("Error! Failed writing %s: %s", batch[txnErr.OpIndex].Key, txnErr.What))
+			}
+			c.Ui.Error(fmt.Sprintf("Imported %d of %d keys before the failure", start, len(ops)))
+			return 1
+		}
+
+		for _, op := range batch {
+			c.Ui.Info(fmt.Sprintf("Imported: %s", op.Key))
+		}
+	}
+
+	c.Ui.Info(fmt.Sprintf("Success! Imported %d keys", len(ops)))
+	return 0
+}
+
+func (c *KVImportCommand) Synopsis() string {
+	return "Imports a tree stored as JSON to the KV store"
+}
+
+func (c *KVImportCommand) dataFromArgs(args []string) ([]byte, error) {
+	var stdin io.Reader = os.Stdin
+	if c.testStdin != nil {
+		stdin = c.testStdin
+	}
+
+	data := "-"
+	switch len(args) {
+	case 0:
+	case 1:
+		data = args[0]
+	default:
+		return nil, fmt.Errorf("Too many arguments (expected 1, got %d)", len(args))
+	}
+
+	switch {
+	case data == "":
+		return nil, fmt.Errorf("Empty DATA argument")
+	case data[0] == '@':
+		data, err := ioutil.ReadFile(data[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read file: %s", err)
+		}
+		return data, nil
+	case data == "-":
+		var b bytes.Buffer
+		if _, err := io.Copy(&b, stdin); err != nil {
+			return nil, fmt.Errorf("Failed to read stdin: %s", err)
+		}
+		return b.Bytes(), nil
+	default:
+		return []byte(data), nil
+	}
+}
@@ -0,0 +1,191 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+func TestKVImportCommand_implements(t *testing.T) {
+	var _ cli.Command = &KVImportCommand{}
+}
+
+func TestKVImportCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(KVImportCommand))
+}
+
+func TestKVImportCommand_Validation(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &KVImportCommand{Ui: ui}
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"extra args": {
+			[]string{"foo", "bar"},
+			"Too many arguments",
+		},
+		"empty data": {
+			[]string{""},
+			"Empty DATA argument",
+		},
+		"bad json": {
+			[]string{"nope"},
+			"Cannot parse import data",
+		},
+		"bad value": {
+			[]string{`[{"key": "foo", "value": "!!"}]`},
+			"Cannot decode value",
+		},
+		"outside strip prefix": {
+			[]string{"-strip-prefix=bar/", `[{"key": "foo", "value": ""}]`},
+			`Key "foo" does not start with "bar/"`,
+		},
+		"empty key": {
+			[]string{"-strip-prefix=foo", `[{"key": "foo", "value": ""}]`},
+			"invalid key",
+		},
+	}
+
+	for name, tc := range cases {
+		// Ensure our buffer is always clear
+		if ui.ErrorWriter != nil {
+			ui.ErrorWriter.Reset()
+		}
+		if ui.OutputWriter != nil {
+			ui.OutputWriter.Reset()
+		}
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestKVImportCommand_Run(t *testing.T) {
+	srv, client := testAgentWithAPIClient(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	// Export more keys than fit in a single batch
+	var pairs []string
+	for i := 0; i < kvImportBatchSize+10; i++ {
+		pairs = append(pairs, fmt.Sprintf(`{"key": "dc1/app/%03d", "flags": %d, "value": "aGVsbG8="}`, i, i))
+	}
+	data := "[" + strings.Join(pairs, ",") + "]"
+
+	ui := new(cli.MockUi)
+	c := &KVImportCommand{
+		Ui:        ui,
+		testStdin: strings.NewReader(data),
+	}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-strip-prefix=dc1/",
+		"-prefix=dc2/",
+		"-",
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	if output := ui.OutputWriter.String(); !strings.Contains(output, fmt.Sprintf("Imported %d keys", len(pairs))) {
+		t.Fatalf("bad: %#v", output)
+	}
+
+	imported, _, err := client.KV().List("", nil)
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+	if len(imported) != len(pairs) {
+		t.Fatalf("bad: %d", len(imported))
+	}
+	for i, pair := range imported {
+		if pair.Key != fmt.Sprintf("dc2/app/%03d", i) || pair.Flags != uint64(i) || string(pair.Value) != "hello" {
+			t.Fatalf("bad: %#v", pair)
+		}
+	}
+}
+
+func TestKVImportCommand_DryRun(t *testing.T) {
+	srv, client := testAgentWithAPIClient(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVImportCommand{Ui: ui}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-prefix=staging/",
+		"-dry-run",
+		`[{"key": "foo", "flags": 0, "value": "YmFy"}]`,
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+	if output := ui.OutputWriter.String(); !strings.Contains(output, "Would import: staging/foo") {
+		t.Fatalf("bad: %#v", output)
+	}
+
+	pair, _, err := client.KV().Get("staging/foo", nil)
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+	if pair != nil {
+		t.Fatalf("bad: %#v", pair)
+	}
+}
+
+func TestKVImportCommand_ExportRoundTrip(t *testing.T) {
+	srv, client := testAgentWithAPIClient(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	pair := &api.KVPair{Key: "foo/bar", Flags: 7, Value: []byte("\x00baz")}
+	if _, err := client.KV().Put(pair, nil); err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+
+	exportUi := new(cli.MockUi)
+	export := &KVExportCommand{Ui: exportUi}
+	if code := export.Run([]string{"-http-addr=" + srv.httpAddr, "foo/"}); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, exportUi.ErrorWriter.String())
+	}
+
+	ui := new(cli.MockUi)
+	c := &KVImportCommand{
+		Ui:        ui,
+		testStdin: strings.NewReader(exportUi.OutputWriter.String()),
+	}
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-strip-prefix=foo/",
+		"-prefix=copy/",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	copied, _, err := client.KV().Get("copy/bar", nil)
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+	if copied == nil || copied.Flags != 7 || string(copied.Value) != "\x00baz" {
+		t.Fatalf("bad: %#v", copied)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/mitchellh/cli"
@@ -83,6 +84,11 @@ KV Put Options:
                           This is commonly used with the -acquire and -release
                           operations to build robust locking, but it can be set
                           on any key. The default value is empty (no session).
+
+  -ttl=<duration>         How long the key lives after this write before it
+                          is deleted, such as "30s" or "10m". Writing the key
+                          again restarts or removes the TTL. The default value
+                          is empty (no TTL).
 `
 	return strings.TrimSpace(helpText)
 }
@@ -99,6 +105,7 @@ func (c *KVPutCommand) Run(args []string) int {
 	session := cmdFlags.String("session", "", "")
 	acquire := cmdFlags.Bool("acquire", false, "")
 	release := cmdFlags.Bool("release", false, "")
+	ttl := cmdFlags.String("ttl", "", "")
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
@@ -117,6 +124,14 @@ func (c *KVPutCommand) Run(args []string) int {
 		return 1
 	}
 
+	// TTL must be a valid duration
+	if *ttl != "" {
+		if _, err := time.ParseDuration(*ttl); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error! Invalid -ttl: %s", err))
+			return 1
+		}
+	}
+
 	// ModifyIndex is required for CAS
 	if *cas && *modifyIndex == 0 {
 		c.Ui.Error("Must specify -modify-index with -cas!")
@@ -139,6 +154,7 @@ func (c *KVPutCommand) Run(args []string) int {
 		Flags:       *flags,
 		Value:       []byte(data),
 		Session:     *session,
+		TTL:         *ttl,
 	}
 
 	wo := &api.WriteOptions{
@@ -41,6 +41,10 @@ func TestKVPutCommand_Validation(t *testing.T) {
 			[]string{"-cas", "foo"},
 			"Must specify -modify-index",
 		},
+		"-ttl invalid": {
+			[]string{"-ttl", "nope", "foo"},
+			"Invalid -ttl",
+		},
 		"no key": {
 			[]string{},
 			"Missing KEY argument",
@@ -251,6 +255,35 @@ func TestKVPutCommand_Flags(t *testing.T) {
 	}
 }
 
+func TestKVPutCommand_TTL(t *testing.T) {
+	srv, client := testAgentWithAPIClient(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVPutCommand{Ui: ui}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"-ttl", "10m",
+		"foo", "bar",
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	data, _, err := client.KV().Get("foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.TTL != "10m" {
+		t.Errorf("bad: %#v", data.TTL)
+	}
+}
+
 func TestKVPutCommand_CAS(t *testing.T) {
 	srv, client := testAgentWithAPIClient(t)
 	defer srv.Shutdown()
@@ -0,0 +1,145 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+// KVRollbackCommand is a Command implementation that is used to restore a key
+// in the key-value store to a prior version.
+type KVRollbackCommand struct {
+	Ui cli.Ui
+}
+
+func (c *KVRollbackCommand) Help() string {
+	helpText := `
+Usage: consul kv rollback [options] KEY INDEX
+
+  Restores the given key to the value and flags it had at the given index,
+  using the key's history. Prior versions are only kept for keys covered by
+  one of the KV history rules. The indexes of a key's prior versions can be
+  found with "consul kv history".
+
+  To restore the key named "foo" to how it was at index 42:
+
+      $ consul kv rollback foo 42
+
+  The restore is a new write of the old value, so the key gets a new
+  ModifyIndex, and the current version is kept in the history. The write is a
+  check-and-set against the current version, so if the key is modified while
+  rolling back, the rollback fails and has no effect.
+
+  For a full list of options and examples, please see the Consul documentation.
+
+` + apiOptsText + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *KVRollbackCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+	datacenter := cmdFlags.String("datacenter", "", "")
+	token := cmdFlags.String("token", "", "")
+	httpAddr := HTTPAddrFlag(cmdFlags)
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Check for arg validation
+	args = cmdFlags.Args()
+	if len(args) != 2 {
+		c.Ui.Error(fmt.Sprintf("Error! Expected KEY and INDEX arguments, got %d arguments", len(args)))
+		return 1
+	}
+
+	// Strip any leading slash, since pairs cannot start with one.
+	key := args[0]
+	if len(key) > 0 && key[0] == '/' {
+		key = key[1:]
+	}
+	if key == "" {
+		c.Ui.Error("Error! Missing KEY argument")
+		return 1
+	}
+
+	index, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! Invalid INDEX: %s", err))
+		return 1
+	}
+
+	// Create and test the HTTP client
+	conf := api.DefaultConfig()
+	conf.Address = *httpAddr
+	conf.Token = *token
+	client, err := api.NewClient(conf)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+		return 1
+	}
+
+	// Always read from the leader, since the current version is used for
+	// the check-and-set.
+	qo := &api.QueryOptions{
+		Datacenter: *datacenter,
+	}
+	old, _, err := client.KV().GetAt(key, index, qo)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+		return 1
+	}
+	if old == nil {
+		c.Ui.Error(fmt.Sprintf("Error! No version of %s is known at index %d", key, index))
+		return 1
+	}
+
+	// A missing key has a ModifyIndex of 0, which makes the check-and-set
+	// only succeed if the key is still missing.
+	current, _, err := client.KV().Get(key, qo)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error querying Consul agent: %s", err))
+		return 1
+	}
+	var modifyIndex uint64
+	if current != nil {
+		if current.ModifyIndex == old.ModifyIndex {
+			c.Ui.Info(fmt.Sprintf("Success! %s is already at the version from index %d", key, index))
+			return 0
+		}
+		modifyIndex = current.ModifyIndex
+	}
+
+	pair := &api.KVPair{
+		Key:         key,
+		ModifyIndex: modifyIndex,
+		Flags:       old.Flags,
+		Value:       old.Value,
+		TTL:         old.TTL,
+	}
+	wo := &api.WriteOptions{
+		Datacenter: *datacenter,
+		Token:      *token,
+	}
+	ok, _, err := client.KV().CAS(pair, wo)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error! Did not write to %s: %s", key, err))
+		return 1
+	}
+	if !ok {
+		c.Ui.Error(fmt.Sprintf("Error! Did not write to %s: key was modified during rollback", key))
+		return 1
+	}
+
+	c.Ui.Info(fmt.Sprintf("Success! Rolled back %s to its version from index %d", key, index))
+	return 0
+}
+
+func (c *KVRollbackCommand) Synopsis() string {
+	return "Restores a key to a prior version"
+}
@@ -0,0 +1,158 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+func TestKVRollbackCommand_implements(t *testing.T) {
+	var _ cli.Command = &KVRollbackCommand{}
+}
+
+func TestKVRollbackCommand_noTabs(t *testing.T) {
+	assertNoTabs(t, new(KVRollbackCommand))
+}
+
+func TestKVRollbackCommand_Validation(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &KVRollbackCommand{Ui: ui}
+
+	cases := map[string]struct {
+		args   []string
+		output string
+	}{
+		"no args": {
+			[]string{},
+			"Expected KEY and INDEX",
+		},
+		"no index": {
+			[]string{"foo"},
+			"Expected KEY and INDEX",
+		},
+		"extra args": {
+			[]string{"foo", "1", "baz"},
+			"Expected KEY and INDEX",
+		},
+		"empty key": {
+			[]string{"/", "1"},
+			"Missing KEY argument",
+		},
+		"bad index": {
+			[]string{"foo", "nope"},
+			"Invalid INDEX",
+		},
+	}
+
+	for name, tc := range cases {
+		// Ensure our buffer is always clear
+		if ui.ErrorWriter != nil {
+			ui.ErrorWriter.Reset()
+		}
+		if ui.OutputWriter != nil {
+			ui.OutputWriter.Reset()
+		}
+
+		code := c.Run(tc.args)
+		if code == 0 {
+			t.Errorf("%s: expected non-zero exit", name)
+		}
+
+		output := ui.ErrorWriter.String()
+		if !strings.Contains(output, tc.output) {
+			t.Errorf("%s: expected %q to contain %q", name, output, tc.output)
+		}
+	}
+}
+
+func TestKVRollbackCommand_Run(t *testing.T) {
+	srv, client := testAgentWithKVHistory(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVRollbackCommand{Ui: ui}
+
+	var indexes []uint64
+	for i, value := range []string{"bar", "baz"} {
+		pair := &api.KVPair{
+			Key:   "foo",
+			Flags: uint64(i + 1),
+			Value: []byte(value),
+		}
+		if _, err := client.KV().Put(pair, nil); err != nil {
+			t.Fatalf("err: %#v", err)
+		}
+		pair, _, err := client.KV().Get("foo", nil)
+		if err != nil {
+			t.Fatalf("err: %#v", err)
+		}
+		indexes = append(indexes, pair.ModifyIndex)
+	}
+
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"foo",
+		fmt.Sprintf("%d", indexes[0]),
+	}
+
+	code := c.Run(args)
+	if code != 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.ErrorWriter.String())
+	}
+
+	pair, _, err := client.KV().Get("foo", nil)
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+	if string(pair.Value) != "bar" || pair.Flags != 1 {
+		t.Fatalf("bad: %#v", pair)
+	}
+	if pair.ModifyIndex <= indexes[1] {
+		t.Fatalf("bad: %#v", pair)
+	}
+
+	// The version that was rolled back is kept too
+	history, _, err := client.KV().History("foo", nil)
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+	if len(history) != 2 || string(history[1].Value) != "baz" {
+		t.Fatalf("bad: %#v", history)
+	}
+}
+
+func TestKVRollbackCommand_Unknown(t *testing.T) {
+	srv, client := testAgentWithKVHistory(t)
+	defer srv.Shutdown()
+	waitForLeader(t, srv.httpAddr)
+
+	ui := new(cli.MockUi)
+	c := &KVRollbackCommand{Ui: ui}
+
+	pair := &api.KVPair{
+		Key:   "foo",
+		Value: []byte("bar"),
+	}
+	if _, err := client.KV().Put(pair, nil); err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+
+	// There's no version from before the key was written
+	args := []string{
+		"-http-addr=" + srv.httpAddr,
+		"foo",
+		"1",
+	}
+
+	code := c.Run(args)
+	if code == 0 {
+		t.Fatalf("bad: %d. %#v", code, ui.OutputWriter.String())
+	}
+	if output := ui.ErrorWriter.String(); !strings.Contains(output, "No version of foo") {
+		t.Fatalf("bad: %#v", output)
+	}
+}
@@ -18,6 +18,60 @@ func init() {
 	ui := &cli.BasicUi{Writer: os.Stdout}
 
 	Commands = map[string]cli.CommandFactory{
+		"acl": func() (cli.Command, error) {
+			return &command.ACLCommand{
+				Ui: ui,
+			}, nil
+		},
+
+		"acl clone": func() (cli.Command, error) {
+			return &command.ACLCloneCommand{
+				Ui: ui,
+			}, nil
+		},
+
+		"acl create": func() (cli.Command, error) {
+			return &command.ACLCreateCommand{
+				Ui: ui,
+			}, nil
+		},
+
+		"acl delete": func() (cli.Command, error) {
+			return &command.ACLDeleteCommand{
+				Ui: ui,
+			}, nil
+		},
+
+		"acl info": func() (cli.Command, error) {
+			return &command.ACLInfoCommand{
+				Ui: ui,
+			}, nil
+		},
+
+		"acl list": func() (cli.Command, error) {
+			return &command.ACLListCommand{
+				Ui: ui,
+			}, nil
+		},
+
+		"acl replication": func() (cli.Command, error) {
+			return &command.ACLReplicationCommand{
+				Ui: ui,
+			}, nil
+		},
+
+		"acl translate-rules": func() (cli.Command, error) {
+			return &command.ACLTranslateRulesCommand{
+				Ui: ui,
+			}, nil
+		},
+
+		"acl update": func() (cli.Command, error) {
+			return &command.ACLUpdateCommand{
+				Ui: ui,
+			}, nil
+		},
+
 		"agent": func() (cli.Command, error) {
 			return &agent.Command{
 				Revision:          version.GitCommit,
@@ -66,18 +120,42 @@ func init() {
 			}, nil
 		},
 
+		"kv export": func() (cli.Command, error) {
+			return &command.KVExportCommand{
+				Ui: ui,
+			}, nil
+		},
+
 		"kv get": func() (cli.Command, error) {
 			return &command.KVGetCommand{
 				Ui: ui,
 			}, nil
 		},
 
+		"kv history": func() (cli.Command, error) {
+			return &command.KVHistoryCommand{
+				Ui: ui,
+			}, nil
+		},
+
+		"kv import": func() (cli.Command, error) {
+			return &command.KVImportCommand{
+				Ui: ui,
+			}, nil
+		},
+
 		"kv put": func() (cli.Command, error) {
 			return &command.KVPutCommand{
 				Ui: ui,
 			}, nil
 		},
 
+		"kv rollback": func() (cli.Command, error) {
+			return &command.KVRollbackCommand{
+				Ui: ui,
+			}, nil
+		},
+
 		"join": func() (cli.Command, error) {
 			return &command.JoinCommand{
 				Ui: ui,
@@ -10,6 +10,7 @@ import (
 
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/golang-lru"
 )
@@ -50,33 +51,97 @@ type aclCacheEntry struct {
 	ACL     acl.ACL
 	Expires time.Time
 	ETag    string
+
+	// ExpirationTime is when the token itself expires, if ever, which
+	// can be sooner than the cache entry.
+	ExpirationTime *time.Time
+}
+
+// tokenExpired returns true if the cached token has expired.
+func (e *aclCacheEntry) tokenExpired(now time.Time) bool {
+	return e.ExpirationTime != nil && !now.Before(*e.ExpirationTime)
 }
 
 // aclLocalFault is used by the authoritative ACL cache to fault in the rules
 // for an ACL if we take a miss. This goes directly to the state store, so it
 // assumes its running in the ACL datacenter, or in a non-ACL datacenter when
 // using its replicated ACLs during an outage.
-func (s *Server) aclLocalFault(id string) (string, string, error) {
+func (s *Server) aclLocalFault(id string) (string, []string, error) {
+	parent, rules, _, err := s.aclLocalLookup(id)
+	return parent, rules, err
+}
+
+// aclLocalLookup is like aclLocalFault, but also returns the expiration time
+// of the token, if it has one. This is used by the non-authoritative cache
+// when it falls back to replicated ACLs, so it knows when to stop using
+// them.
+func (s *Server) aclLocalLookup(id string) (string, []string, *time.Time, error) {
+	return s.aclFault(id, s.config.Datacenter)
+}
+
+// aclFault looks up the rules for an ACL as they apply to requests made in
+// the given datacenter. This returns the token's own rules followed by the
+// rules of each of its ACL policies that apply in the datacenter, along with
+// the token's expiration time.
+func (s *Server) aclFault(id, dc string) (string, []string, *time.Time, error) {
 	defer metrics.MeasureSince([]string{"consul", "acl", "fault"}, time.Now())
 
-	// Query the state store.
+	// Query the state store. Expired tokens are treated as though they
+	// don't exist, even before the leader reaps them.
 	state := s.fsm.State()
 	_, acl, err := state.ACLGet(id)
 	if err != nil {
-		return "", "", err
+		return "", nil, nil, err
 	}
-	if acl == nil {
-		return "", "", errors.New(aclNotFound)
+	if acl == nil || acl.IsExpired(time.Now()) {
+		return "", nil, nil, errors.New(aclNotFound)
 	}
 
 	// Management tokens have no policy and inherit from the 'manage' root
 	// policy.
 	if acl.Type == structs.ACLTypeManagement {
-		return "manage", "", nil
+		return "manage", nil, acl.ExpirationTime, nil
+	}
+
+	// Gather the rules of the linked policies. A missing policy is an
+	// error rather than being skipped, since policies can't be deleted
+	// while they are in use, and replication brings over the policies
+	// before any ACLs that link to them, so this means our state is
+	// incomplete.
+	rules := []string{acl.Rules}
+	for _, policyID := range acl.Policies {
+		_, policy, err := state.ACLPolicyGet(policyID)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if policy == nil {
+			return "", nil, nil, fmt.Errorf("ACL policy '%s' not found", policyID)
+		}
+		if policy.AppliesTo(dc) {
+			rules = append(rules, policy.Rules)
+		}
 	}
 
 	// Otherwise use the default policy.
-	return s.config.ACLDefaultPolicy, acl.Rules, nil
+	return s.config.ACLDefaultPolicy, rules, acl.ExpirationTime, nil
+}
+
+// aclExpiration returns the expiration time of the given token, if it has
+// one. The authoritative cache only forgets about a token once it's been
+// reaped, so this returns a not found error if the token has expired, and
+// must be checked before using the cache.
+func (s *Server) aclExpiration(id string) (*time.Time, error) {
+	_, acl, err := s.fsm.State().ACLGet(id)
+	if err != nil {
+		return nil, err
+	}
+	if acl == nil {
+		return nil, nil
+	}
+	if acl.IsExpired(time.Now()) {
+		return nil, errors.New(aclNotFound)
+	}
+	return acl.ExpirationTime, nil
 }
 
 // resolveToken is the primary interface used by ACL-checkers (such as an
@@ -102,6 +167,9 @@ func (s *Server) resolveToken(id string) (acl.ACL, error) {
 	// Check if we are the ACL datacenter and the leader, use the
 	// authoritative cache
 	if s.config.Datacenter == authDC && s.IsLeader() {
+		if _, err := s.aclExpiration(id); err != nil {
+			return nil, err
+		}
 		return s.aclAuthCache.GetACL(id)
 	}
 
@@ -109,6 +177,50 @@ func (s *Server) resolveToken(id string) (acl.ACL, error) {
 	return s.aclCache.lookupACL(id, authDC)
 }
 
+// ResolveToken resolves the given token ID to an ACL so the agent can
+// enforce ACLs on requests it serves itself. A nil ACL is returned if ACLs
+// are disabled.
+func (s *Server) ResolveToken(id string) (acl.ACL, error) {
+	return s.resolveToken(id)
+}
+
+// ResolveToken resolves the given token ID to an ACL using the servers, with
+// the results cached the same way as on servers outside the ACL datacenter.
+// A nil ACL is returned if ACLs are disabled.
+func (c *Client) ResolveToken(id string) (acl.ACL, error) {
+	authDC := c.config.ACLDatacenter
+	if len(authDC) == 0 {
+		return nil, nil
+	}
+	defer metrics.MeasureSince([]string{"consul", "acl", "resolveToken"}, time.Now())
+
+	// Handle the anonymous token
+	if len(id) == 0 {
+		id = anonymousToken
+	} else if acl.RootACL(id) != nil {
+		return nil, errors.New(rootDenied)
+	}
+
+	return c.aclCache.lookupACL(id, authDC)
+}
+
+// auditACL records an ACL decision in the audit log, if one is configured.
+// Failing to record a decision is logged but doesn't fail the request.
+func (s *Server) auditACL(token, endpoint, resourceType, resource string, decision audit.Decision) {
+	if err := s.config.AuditLog.Record(token, endpoint, resourceType, resource, decision); err != nil {
+		s.logger.Printf("[ERR] consul.acl: Failed to record audit entry for %s: %v", endpoint, err)
+	}
+}
+
+// auditDenied records a denial in the audit log if the given error is the
+// result of an ACL check, and returns the error unchanged.
+func (s *Server) auditDenied(err error, token, endpoint, resourceType, resource string) error {
+	if err == permissionDeniedErr {
+		s.auditACL(token, endpoint, resourceType, resource, audit.Deny)
+	}
+	return err
+}
+
 // rpcFn is used to make an RPC call to the client or server.
 type rpcFn func(string, interface{}, interface{}) error
 
@@ -128,13 +240,17 @@ type aclCache struct {
 
 	// local is a function used to look for an ACL locally if replication is
 	// enabled. This will be nil if replication isn't enabled.
-	local acl.FaultFunc
+	local aclLocalFn
 }
 
+// aclLocalFn is used to look up the rules for an ACL from the local state
+// store, along with the token's expiration time.
+type aclLocalFn func(id string) (string, []string, *time.Time, error)
+
 // newAclCache returns a new non-authoritative cache for ACLs. This is used for
 // performance, and is used inside the ACL datacenter on non-leader servers, and
 // outside the ACL datacenter everywhere.
-func newAclCache(conf *Config, logger *log.Logger, rpc rpcFn, local acl.FaultFunc) (*aclCache, error) {
+func newAclCache(conf *Config, logger *log.Logger, rpc rpcFn, local aclLocalFn) (*aclCache, error) {
 	var err error
 	cache := &aclCache{
 		config: conf,
@@ -167,8 +283,12 @@ func (c *aclCache) lookupACL(id, authDC string) (acl.ACL, error) {
 		cached = raw.(*aclCacheEntry)
 	}
 
-	// Check for live cache.
-	if cached != nil && time.Now().Before(cached.Expires) {
+	// Check for live cache. If the token has expired we treat it as a miss
+	// even if the entry is live, in case its expiration has been pushed
+	// out, but it won't be used again if it can't be refreshed.
+	now := time.Now()
+	expired := cached != nil && cached.tokenExpired(now)
+	if cached != nil && !expired && now.Before(cached.Expires) {
 		metrics.IncrCounter([]string{"consul", "acl", "cache_hit"}, 1)
 		return cached.ACL, nil
 	} else {
@@ -177,8 +297,9 @@ func (c *aclCache) lookupACL(id, authDC string) (acl.ACL, error) {
 
 	// Attempt to refresh the policy from the ACL datacenter via an RPC.
 	args := structs.ACLPolicyRequest{
-		Datacenter: authDC,
-		ACL:        id,
+		Datacenter:       authDC,
+		ACL:              id,
+		SourceDatacenter: c.config.Datacenter,
 	}
 	if cached != nil {
 		args.ETag = cached.ETag
@@ -196,6 +317,9 @@ func (c *aclCache) lookupACL(id, authDC string) (acl.ACL, error) {
 	} else {
 		c.logger.Printf("[ERR] consul.acl: Failed to get policy from ACL datacenter: %v", err)
 	}
+	if expired {
+		return nil, errors.New(aclNotFound)
+	}
 
 	// TODO (slackpad) - We could do a similar thing *within* the ACL
 	// datacenter if the leader isn't available. We have a local state
@@ -215,7 +339,7 @@ func (c *aclCache) lookupACL(id, authDC string) (acl.ACL, error) {
 	// and the user's policy allows it, we will try locally before we give
 	// up.
 	if c.local != nil && c.config.ACLDownPolicy == "extend-cache" {
-		parent, rules, err := c.local(id)
+		parent, rules, expires, err := c.local(id)
 		if err != nil {
 			// We don't make an exception here for ACLs that aren't
 			// found locally. It seems more robust to use an expired
@@ -226,12 +350,11 @@ func (c *aclCache) lookupACL(id, authDC string) (acl.ACL, error) {
 			goto ACL_DOWN
 		}
 
-		policy, err := acl.Parse(rules)
+		policy, err := acl.ParseAll(rules)
 		if err != nil {
 			c.logger.Printf("[DEBUG] consul.acl: Failed to parse policy for replicated ACL: %v", err)
 			goto ACL_DOWN
 		}
-		policy.ID = acl.RuleID(rules)
 
 		// Fake up an ACL datacenter reply and inject it into the cache.
 		// Note we use the local TTL here, so this'll be used for that
@@ -241,6 +364,7 @@ func (c *aclCache) lookupACL(id, authDC string) (acl.ACL, error) {
 		reply.TTL = c.config.ACLTTL
 		reply.Parent = parent
 		reply.Policy = policy
+		reply.ExpirationTime = expires
 		return c.useACLPolicy(id, authDC, cached, &reply)
 	}
 
@@ -266,6 +390,7 @@ func (c *aclCache) useACLPolicy(id, authDC string, cached *aclCacheEntry, p *str
 		if p.TTL > 0 {
 			cached.Expires = time.Now().Add(p.TTL)
 		}
+		cached.ExpirationTime = p.ExpirationTime
 		return cached.ACL, nil
 	}
 
@@ -298,8 +423,9 @@ func (c *aclCache) useACLPolicy(id, authDC string, cached *aclCacheEntry, p *str
 
 	// Cache the ACL
 	cached = &aclCacheEntry{
-		ACL:  compiled,
-		ETag: p.ETag,
+		ACL:            compiled,
+		ETag:           p.ETag,
+		ExpirationTime: p.ExpirationTime,
 	}
 	if p.TTL > 0 {
 		cached.Expires = time.Now().Add(p.TTL)
@@ -403,7 +529,7 @@ func (f *aclFilter) filterCheckServiceNodes(nodes *structs.CheckServiceNodes) {
 	csn := *nodes
 	for i := 0; i < len(csn); i++ {
 		node := csn[i]
-		if f.allowService(node.Service.Service) {
+		if f.allowNode(node.Node.Node) && f.allowService(node.Service.Service) {
 			continue
 		}
 		f.logger.Printf("[DEBUG] consul: dropping node %q from result due to ACLs", node.Node.Node)
@@ -413,6 +539,22 @@ func (f *aclFilter) filterCheckServiceNodes(nodes *structs.CheckServiceNodes) {
 	*nodes = csn
 }
 
+// filterStreamEvents is used to filter stream events based on ACL rules.
+// Markers without a payload, such as resets, are always kept.
+func (f *aclFilter) filterStreamEvents(events *[]*structs.StreamEvent) {
+	se := *events
+	for i := 0; i < len(se); i++ {
+		csn := se[i].ServiceHealth
+		if csn == nil || (f.allowNode(csn.Node.Node) && f.allowService(csn.Service.Service)) {
+			continue
+		}
+		f.logger.Printf("[DEBUG] consul: dropping node %q from result due to ACLs", csn.Node.Node)
+		se = append(se[:i], se[i+1:]...)
+		i--
+	}
+	*events = se
+}
+
 // filterNodeDump is used to filter through all parts of a node dump and
 // remove elements the provided ACL token cannot access.
 func (f *aclFilter) filterNodeDump(dump *structs.NodeDump) {
@@ -465,6 +607,25 @@ func (f *aclFilter) filterNodes(nodes *structs.Nodes) {
 	*nodes = n
 }
 
+// filterSessions is used to filter a set of sessions based on ACLs.
+func (f *aclFilter) filterSessions(sessions *structs.Sessions) {
+	if !f.enforceVersion8 {
+		return
+	}
+
+	s := *sessions
+	for i := 0; i < len(s); i++ {
+		session := s[i]
+		if f.acl.SessionRead(session.Node) {
+			continue
+		}
+		f.logger.Printf("[DEBUG] consul: dropping session %q from result due to ACLs", session.ID)
+		s = append(s[:i], s[i+1:]...)
+		i--
+	}
+	*sessions = s
+}
+
 // redactPreparedQueryTokens will redact any tokens unless the client has a
 // management token. This eases the transition to delegated authority over
 // prepared queries, since it was easy to capture management tokens in Consul
@@ -568,6 +729,9 @@ func (s *Server) filterACL(token string, subj interface{}) error {
 	case *structs.IndexedServices:
 		filt.filterServices(v.Services)
 
+	case *structs.IndexedSessions:
+		filt.filterSessions(&v.Sessions)
+
 	case *structs.IndexedPreparedQueries:
 		filt.filterPreparedQueries(&v.Queries)
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/go-uuid"
 )
@@ -41,6 +42,12 @@ func aclApplyInternal(srv *Server, args *structs.ACLRequest, reply *string) erro
 			return fmt.Errorf("%s: Cannot modify root ACL", permissionDenied)
 		}
 
+		// The anonymous token is used when no token is given, so it
+		// can't be allowed to expire
+		if args.ACL.ID == anonymousToken && args.ACL.ExpirationTime != nil {
+			return fmt.Errorf("%s: Cannot expire anonymous token", permissionDenied)
+		}
+
 		// Validate the rules compile
 		_, err := acl.Parse(args.ACL.Rules)
 		if err != nil {
@@ -91,7 +98,8 @@ func (a *ACL) Apply(args *structs.ACLRequest, reply *string) error {
 	if acl, err := a.srv.resolveToken(args.Token); err != nil {
 		return err
 	} else if acl == nil || !acl.ACLModify() {
-		return permissionDeniedErr
+		return a.srv.auditDenied(permissionDeniedErr, args.Token, "ACL.Apply", audit.ResourceACL,
+			aclAuditResource(args.ACL.ID))
 	}
 
 	// If no ID is provided, generate a new ID. This must be done prior to
@@ -119,6 +127,30 @@ func (a *ACL) Apply(args *structs.ACLRequest, reply *string) error {
 		}
 	}
 
+	// Turn any expiration TTL into a time, which must also be done before
+	// appending to the Raft log.
+	if args.Op == structs.ACLSet {
+		if err := setACLExpiration(&args.ACL, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	// Make sure any linked policies exist. This isn't part of the internal
+	// apply since replication checks the links against the remote policies
+	// instead.
+	if args.Op == structs.ACLSet {
+		state := a.srv.fsm.State()
+		for _, policyID := range args.ACL.Policies {
+			_, policy, err := state.ACLPolicyGet(policyID)
+			if err != nil {
+				return err
+			}
+			if policy == nil {
+				return fmt.Errorf("Unknown ACL policy '%s'", policyID)
+			}
+		}
+	}
+
 	// Do the apply now that this update is vetted.
 	if err := aclApplyInternal(a.srv, args, reply); err != nil {
 		return err
@@ -129,6 +161,34 @@ func (a *ACL) Apply(args *structs.ACLRequest, reply *string) error {
 		a.srv.aclAuthCache.ClearACL(args.ACL.ID)
 	}
 
+	a.srv.auditACL(args.Token, "ACL.Apply", audit.ResourceACL, aclAuditResource(args.ACL.ID), audit.Allow)
+	return nil
+}
+
+// aclAuditResource names a token in the audit log. Token IDs are secrets,
+// so the token's accessor is used instead.
+func aclAuditResource(id string) string {
+	if id == "" {
+		return ""
+	}
+	return audit.Accessor(id)
+}
+
+// setACLExpiration validates the expiration of an ACL that's being set, and
+// converts an expiration TTL into an expiration time relative to now.
+func setACLExpiration(acl *structs.ACL, now time.Time) error {
+	switch {
+	case acl.ExpirationTTL < 0:
+		return fmt.Errorf("ACL expiration TTL '%s' is negative", acl.ExpirationTTL)
+	case acl.ExpirationTTL > 0 && acl.ExpirationTime != nil:
+		return fmt.Errorf("ACL expiration time and TTL can't both be set")
+	case acl.ExpirationTTL > 0:
+		expires := now.Add(acl.ExpirationTTL).UTC()
+		acl.ExpirationTime = &expires
+		acl.ExpirationTTL = 0
+	case acl.ExpirationTime != nil && acl.IsExpired(now):
+		return fmt.Errorf("ACL expiration time '%s' is in the past", acl.ExpirationTime.Format(time.RFC3339))
+	}
 	return nil
 }
 
@@ -182,10 +242,33 @@ func (a *ACL) GetPolicy(args *structs.ACLPolicyRequest, reply *structs.ACLPolicy
 		return fmt.Errorf(aclDisabled)
 	}
 
-	// Get the policy via the cache
-	parent, policy, err := a.srv.aclAuthCache.GetACLPolicy(args.ACL)
-	if err != nil {
-		return err
+	// Get the policy via the cache. Which ACL policies apply depends on
+	// the datacenter making the request, and the cache only holds our
+	// own view, so requests from other datacenters are faulted in
+	// directly, though the merged policy is still cached.
+	var parent string
+	var policy *acl.Policy
+	var expires *time.Time
+	if dc := args.SourceDatacenter; dc == "" || dc == a.srv.config.Datacenter {
+		var err error
+		if expires, err = a.srv.aclExpiration(args.ACL); err != nil {
+			return err
+		}
+		parent, policy, err = a.srv.aclAuthCache.GetACLPolicy(args.ACL)
+		if err != nil {
+			return err
+		}
+	} else {
+		var rules []string
+		var err error
+		parent, rules, expires, err = a.srv.aclFault(args.ACL, dc)
+		if err != nil {
+			return err
+		}
+		policy, err = a.srv.aclAuthCache.GetMergedPolicy(rules)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Generate an ETag
@@ -195,6 +278,7 @@ func (a *ACL) GetPolicy(args *structs.ACLPolicyRequest, reply *structs.ACLPolicy
 	// Setup the response
 	reply.ETag = etag
 	reply.TTL = conf.ACLTTL
+	reply.ExpirationTime = expires
 	a.srv.setQueryMeta(&reply.QueryMeta)
 
 	// Only send the policy on an Etag mis-match
@@ -221,7 +305,7 @@ func (a *ACL) List(args *structs.DCSpecificRequest,
 	if acl, err := a.srv.resolveToken(args.Token); err != nil {
 		return err
 	} else if acl == nil || !acl.ACLList() {
-		return permissionDeniedErr
+		return a.srv.auditDenied(permissionDeniedErr, args.Token, "ACL.List", audit.ResourceACL, "")
 	}
 
 	// Get the local state
@@ -240,6 +324,168 @@ func (a *ACL) List(args *structs.DCSpecificRequest,
 		})
 }
 
+// PolicyApply is used to create, update or delete an ACL policy.
+func (a *ACL) PolicyApply(args *structs.ACLPolicyEntryRequest, reply *string) error {
+	if done, err := a.srv.forward("ACL.PolicyApply", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"consul", "acl", "policy_apply"}, time.Now())
+
+	// Verify we are allowed to serve this request
+	if a.srv.config.ACLDatacenter != a.srv.config.Datacenter {
+		return fmt.Errorf(aclDisabled)
+	}
+
+	// Verify token is permitted to modify ACLs
+	if acl, err := a.srv.resolveToken(args.Token); err != nil {
+		return err
+	} else if acl == nil || !acl.ACLModify() {
+		return a.srv.auditDenied(permissionDeniedErr, args.Token, "ACL.PolicyApply", audit.ResourceACL,
+			args.Policy.ID)
+	}
+
+	state := a.srv.fsm.State()
+	switch args.Op {
+	case structs.ACLSet:
+		// If no ID is provided, generate a new ID. This must be done
+		// prior to appending to the Raft log, because the ID is not
+		// deterministic.
+		if args.Policy.ID == "" {
+			for {
+				var err error
+				args.Policy.ID, err = uuid.GenerateUUID()
+				if err != nil {
+					a.srv.logger.Printf("[ERR] consul.acl: UUID generation failed: %v", err)
+					return err
+				}
+
+				_, policy, err := state.ACLPolicyGet(args.Policy.ID)
+				if err != nil {
+					a.srv.logger.Printf("[ERR] consul.acl: ACL policy lookup failed: %v", err)
+					return err
+				}
+				if policy == nil {
+					break
+				}
+			}
+		}
+
+		if args.Policy.Name == "" {
+			return fmt.Errorf("Missing ACL policy name")
+		}
+
+		// Validate the rules compile
+		if _, err := acl.Parse(args.Policy.Rules); err != nil {
+			return fmt.Errorf("ACL rule compilation failed: %v", err)
+		}
+
+	case structs.ACLDelete:
+		if args.Policy.ID == "" {
+			return fmt.Errorf("Missing ACL policy ID")
+		}
+
+	default:
+		return fmt.Errorf("Invalid ACL Operation")
+	}
+
+	// Apply the update
+	resp, err := a.srv.raftApply(structs.ACLPolicyEntryRequestType, args)
+	if err != nil {
+		a.srv.logger.Printf("[ERR] consul.acl: Policy apply failed: %v", err)
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	if respString, ok := resp.(string); ok {
+		*reply = respString
+	}
+
+	// Any number of tokens may be linked to the policy, so the whole
+	// cache gets cleared.
+	a.srv.aclAuthCache.Purge()
+
+	a.srv.auditACL(args.Token, "ACL.PolicyApply", audit.ResourceACL, args.Policy.ID, audit.Allow)
+	return nil
+}
+
+// PolicyGet is used to retrieve a single ACL policy.
+func (a *ACL) PolicyGet(args *structs.ACLPolicyEntrySpecificRequest,
+	reply *structs.IndexedACLPolicyEntries) error {
+	if done, err := a.srv.forward("ACL.PolicyGet", args, args, reply); done {
+		return err
+	}
+
+	// Verify we are allowed to serve this request
+	if a.srv.config.ACLDatacenter != a.srv.config.Datacenter {
+		return fmt.Errorf(aclDisabled)
+	}
+
+	// Unlike tokens, policy IDs aren't secrets, so reading a policy
+	// requires the same privileges as listing them.
+	if acl, err := a.srv.resolveToken(args.Token); err != nil {
+		return err
+	} else if acl == nil || !acl.ACLList() {
+		return a.srv.auditDenied(permissionDeniedErr, args.Token, "ACL.PolicyGet", audit.ResourceACL,
+			args.PolicyID)
+	}
+
+	// Get the local state
+	state := a.srv.fsm.State()
+	return a.srv.blockingRPC(&args.QueryOptions,
+		&reply.QueryMeta,
+		state.GetQueryWatch("ACLPolicyGet"),
+		func() error {
+			index, policy, err := state.ACLPolicyGet(args.PolicyID)
+			if err != nil {
+				return err
+			}
+
+			reply.Index = index
+			if policy != nil {
+				reply.Policies = structs.ACLPolicyEntries{policy}
+			} else {
+				reply.Policies = nil
+			}
+			return nil
+		})
+}
+
+// PolicyList is used to list all the ACL policies.
+func (a *ACL) PolicyList(args *structs.DCSpecificRequest,
+	reply *structs.IndexedACLPolicyEntries) error {
+	if done, err := a.srv.forward("ACL.PolicyList", args, args, reply); done {
+		return err
+	}
+
+	// Verify we are allowed to serve this request
+	if a.srv.config.ACLDatacenter != a.srv.config.Datacenter {
+		return fmt.Errorf(aclDisabled)
+	}
+
+	// Verify token is permitted to list ACLs
+	if acl, err := a.srv.resolveToken(args.Token); err != nil {
+		return err
+	} else if acl == nil || !acl.ACLList() {
+		return a.srv.auditDenied(permissionDeniedErr, args.Token, "ACL.PolicyList", audit.ResourceACL, "")
+	}
+
+	// Get the local state
+	state := a.srv.fsm.State()
+	return a.srv.blockingRPC(&args.QueryOptions,
+		&reply.QueryMeta,
+		state.GetQueryWatch("ACLPolicyList"),
+		func() error {
+			index, policies, err := state.ACLPolicyList()
+			if err != nil {
+				return err
+			}
+
+			reply.Index, reply.Policies = index, policies
+			return nil
+		})
+}
+
 // ReplicationStatus is used to retrieve the current ACL replication status.
 func (a *ACL) ReplicationStatus(args *structs.DCSpecificRequest,
 	reply *structs.ACLReplicationStatus) error {
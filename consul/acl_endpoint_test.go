@@ -385,6 +385,98 @@ func TestACLEndpoint_GetPolicy(t *testing.T) {
 	}
 }
 
+func TestACLEndpoint_Expiration(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Bad expirations are rejected.
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+	cases := []struct {
+		acl structs.ACL
+		err string
+	}{
+		{structs.ACL{ExpirationTTL: -time.Second}, "is negative"},
+		{structs.ACL{ExpirationTTL: time.Second, ExpirationTime: &future}, "can't both be set"},
+		{structs.ACL{ExpirationTime: &past}, "is in the past"},
+		{structs.ACL{ID: anonymousToken, ExpirationTime: &future}, "Cannot expire anonymous token"},
+	}
+	for _, c := range cases {
+		arg := structs.ACLRequest{
+			Datacenter:   "dc1",
+			Op:           structs.ACLSet,
+			ACL:          c.acl,
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		arg.ACL.Type = structs.ACLTypeClient
+		var out string
+		err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &out)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Create a token with a TTL, which gets turned into a time.
+	start := time.Now()
+	arg := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name:          "User token",
+			Type:          structs.ACLTypeClient,
+			ExpirationTTL: 500 * time.Millisecond,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var id string
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &id); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, acl, err := s1.fsm.State().ACLGet(id)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if acl.ExpirationTTL != 0 || acl.ExpirationTime == nil ||
+		acl.ExpirationTime.Before(start.Add(500*time.Millisecond)) ||
+		acl.ExpirationTime.After(time.Now().Add(500*time.Millisecond)) {
+		t.Fatalf("bad: %#v", acl)
+	}
+
+	// The policy carries the expiration time.
+	getR := structs.ACLPolicyRequest{
+		Datacenter: "dc1",
+		ACL:        id,
+	}
+	var policy structs.ACLPolicy
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.GetPolicy", &getR, &policy); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if policy.ExpirationTime == nil || !policy.ExpirationTime.Equal(*acl.ExpirationTime) {
+		t.Fatalf("bad: %#v", policy)
+	}
+
+	// Once it expires it's not found, for other datacenters as well.
+	time.Sleep(acl.ExpirationTime.Sub(time.Now()))
+	if _, err := s1.resolveToken(id); err == nil || err.Error() != aclNotFound {
+		t.Fatalf("err: %v", err)
+	}
+	for _, dc := range []string{"", "dc2"} {
+		getR.SourceDatacenter = dc
+		err := msgpackrpc.CallWithCodec(codec, "ACL.GetPolicy", &getR, &policy)
+		if err == nil || !strings.Contains(err.Error(), aclNotFound) {
+			t.Fatalf("err: %v", err)
+		}
+	}
+}
+
 func TestACLEndpoint_List(t *testing.T) {
 	dir1, s1 := testServerWithConfig(t, func(c *Config) {
 		c.ACLDatacenter = "dc1"
@@ -492,3 +584,323 @@ func TestACLEndpoint_ReplicationStatus(t *testing.T) {
 		t.Fatalf("bad: %#v", status)
 	}
 }
+
+func TestACLEndpoint_PolicyApply(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	applyPolicy := func(policy structs.ACLPolicyEntry, op structs.ACLOp) (string, error) {
+		arg := structs.ACLPolicyEntryRequest{
+			Datacenter:   "dc1",
+			Op:           op,
+			Policy:       policy,
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		var out string
+		err := msgpackrpc.CallWithCodec(codec, "ACL.PolicyApply", &arg, &out)
+		return out, err
+	}
+	applyToken := func(token structs.ACL) (string, error) {
+		arg := structs.ACLRequest{
+			Datacenter:   "dc1",
+			Op:           structs.ACLSet,
+			ACL:          token,
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		var out string
+		err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &out)
+		return out, err
+	}
+
+	// Policies need a name and valid rules.
+	if _, err := applyPolicy(structs.ACLPolicyEntry{Rules: ""}, structs.ACLSet); err == nil ||
+		!strings.Contains(err.Error(), "Missing ACL policy name") {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := applyPolicy(structs.ACLPolicyEntry{Name: "bad", Rules: `key "" { policy = "nope" }`}, structs.ACLSet); err == nil ||
+		!strings.Contains(err.Error(), "ACL rule compilation failed") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Create a couple of policies.
+	web, err := applyPolicy(structs.ACLPolicyEntry{
+		Name:  "web",
+		Rules: `key "web/" { policy = "write" }`,
+	}, structs.ACLSet)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	db, err := applyPolicy(structs.ACLPolicyEntry{
+		Name:  "db",
+		Rules: `key "db/" { policy = "read" }`,
+	}, structs.ACLSet)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, policy, err := s1.fsm.State().ACLPolicyGet(web)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if policy == nil || policy.Name != "web" {
+		t.Fatalf("bad: %v", policy)
+	}
+
+	// Tokens can't link to policies that don't exist.
+	if _, err := applyToken(structs.ACL{
+		Name:     "nope",
+		Type:     structs.ACLTypeClient,
+		Policies: []string{"nope"},
+	}); err == nil || !strings.Contains(err.Error(), "Unknown ACL policy") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Make a token that uses both policies along with its own rules.
+	token, err := applyToken(structs.ACL{
+		Name:     "app",
+		Type:     structs.ACLTypeClient,
+		Rules:    `key "app/" { policy = "read" }`,
+		Policies: []string{web, db},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err := s1.resolveToken(token)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !acl.KeyWrite("web/foo") || !acl.KeyRead("db/foo") || acl.KeyWrite("db/foo") ||
+		!acl.KeyRead("app/foo") || acl.KeyRead("other/foo") {
+		t.Fatalf("bad: %v", acl)
+	}
+
+	// Updating a policy changes every token linked to it.
+	if _, err := applyPolicy(structs.ACLPolicyEntry{
+		ID:    db,
+		Name:  "db",
+		Rules: `key "db/" { policy = "write" }`,
+	}, structs.ACLSet); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	acl, err = s1.resolveToken(token)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !acl.KeyWrite("db/foo") {
+		t.Fatalf("bad: %v", acl)
+	}
+
+	// A linked policy can't be deleted.
+	if _, err := applyPolicy(structs.ACLPolicyEntry{ID: web}, structs.ACLDelete); err == nil ||
+		!strings.Contains(err.Error(), "still used by token") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Unlink it and try again.
+	if _, err := applyToken(structs.ACL{
+		ID:       token,
+		Name:     "app",
+		Type:     structs.ACLTypeClient,
+		Policies: []string{db},
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := applyPolicy(structs.ACLPolicyEntry{ID: web}, structs.ACLDelete); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, policy, err = s1.fsm.State().ACLPolicyGet(web)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if policy != nil {
+		t.Fatalf("bad: %v", policy)
+	}
+	acl, err = s1.resolveToken(token)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if acl.KeyRead("web/foo") || !acl.KeyWrite("db/foo") {
+		t.Fatalf("bad: %v", acl)
+	}
+}
+
+func TestACLEndpoint_PolicyApply_Denied(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.ACLPolicyEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		Policy: structs.ACLPolicyEntry{
+			Name: "web",
+		},
+	}
+	var out string
+	err := msgpackrpc.CallWithCodec(codec, "ACL.PolicyApply", &arg, &out)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestACLEndpoint_PolicyGet_PolicyList(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	var ids []string
+	for _, name := range []string{"web", "db"} {
+		arg := structs.ACLPolicyEntryRequest{
+			Datacenter: "dc1",
+			Op:         structs.ACLSet,
+			Policy: structs.ACLPolicyEntry{
+				Name:        name,
+				Datacenters: []string{"dc1"},
+			},
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		var out string
+		if err := msgpackrpc.CallWithCodec(codec, "ACL.PolicyApply", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		ids = append(ids, out)
+	}
+
+	// Get one of them.
+	getR := structs.ACLPolicyEntrySpecificRequest{
+		Datacenter:   "dc1",
+		PolicyID:     ids[0],
+		QueryOptions: structs.QueryOptions{Token: "root"},
+	}
+	var policies structs.IndexedACLPolicyEntries
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.PolicyGet", &getR, &policies); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if policies.Index == 0 || len(policies.Policies) != 1 ||
+		policies.Policies[0].Name != "web" || policies.Policies[0].Datacenters[0] != "dc1" {
+		t.Fatalf("bad: %v", policies)
+	}
+
+	// A missing one comes back empty.
+	getR.PolicyID = "nope"
+	policies = structs.IndexedACLPolicyEntries{}
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.PolicyGet", &getR, &policies); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(policies.Policies) != 0 {
+		t.Fatalf("bad: %v", policies)
+	}
+
+	// List them all.
+	listR := structs.DCSpecificRequest{
+		Datacenter:   "dc1",
+		QueryOptions: structs.QueryOptions{Token: "root"},
+	}
+	policies = structs.IndexedACLPolicyEntries{}
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.PolicyList", &listR, &policies); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if policies.Index == 0 || len(policies.Policies) != 2 {
+		t.Fatalf("bad: %v", policies)
+	}
+
+	// Both need a token that can list ACLs.
+	getR.Token = ""
+	err := msgpackrpc.CallWithCodec(codec, "ACL.PolicyGet", &getR, &policies)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+	listR.Token = ""
+	err = msgpackrpc.CallWithCodec(codec, "ACL.PolicyList", &listR, &policies)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestACLEndpoint_GetPolicy_Datacenters(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Make a policy that only applies in dc2, and link it to a token.
+	policyArg := structs.ACLPolicyEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		Policy: structs.ACLPolicyEntry{
+			Name:        "dc2-only",
+			Rules:       `key "foo/" { policy = "write" }`,
+			Datacenters: []string{"dc2"},
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var policyID string
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.PolicyApply", &policyArg, &policyID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	arg := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name:     "User token",
+			Type:     structs.ACLTypeClient,
+			Policies: []string{policyID},
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var token string
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &token); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The policy doesn't apply in dc1.
+	getR := structs.ACLPolicyRequest{
+		Datacenter: "dc1",
+		ACL:        token,
+	}
+	var out structs.ACLPolicy
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.GetPolicy", &getR, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Policy == nil || len(out.Policy.Keys) != 0 {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// But it does when asked on behalf of dc2.
+	getR.SourceDatacenter = "dc2"
+	var out2 structs.ACLPolicy
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.GetPolicy", &getR, &out2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out2.Policy == nil || len(out2.Policy.Keys) != 1 || out2.ETag == out.ETag {
+		t.Fatalf("bad: %v", out2)
+	}
+}
@@ -137,6 +137,68 @@ func reconcileACLs(local, remote structs.ACLs, lastRemoteIndex uint64) structs.A
 	return changes
 }
 
+// reconcileACLPolicies takes the local and remote ACL policies, and returns
+// the changes required to bring the local policies into sync with the remote
+// ones. The sets are returned apart from the deletes, since the sets must be
+// applied before any tokens linking to them, and the deletes after any
+// tokens stop linking to them. The sets are in the order they were made on
+// the remote side, so a name that moves from one policy to another is given
+// up before it's taken. The lastRemoteIndex hint works the same as for
+// reconcileACLs.
+func reconcileACLPolicies(local, remote structs.ACLPolicyEntries, lastRemoteIndex uint64) ([]*structs.ACLPolicyEntryRequest, []*structs.ACLPolicyEntryRequest) {
+	existing := make(map[string]*structs.ACLPolicyEntry)
+	for _, policy := range local {
+		existing[policy.ID] = policy
+	}
+
+	// Anything remote that's new or changed gets set.
+	sorted := make(structs.ACLPolicyEntries, len(remote))
+	copy(sorted, remote)
+	sort.Sort(aclPolicyIndexSorter(sorted))
+	var sets []*structs.ACLPolicyEntryRequest
+	for _, r := range sorted {
+		l, ok := existing[r.ID]
+		if !ok || (r.RaftIndex.ModifyIndex > lastRemoteIndex && !r.IsSame(l)) {
+			sets = append(sets, &structs.ACLPolicyEntryRequest{
+				Op:     structs.ACLSet,
+				Policy: *r,
+			})
+		}
+		delete(existing, r.ID)
+	}
+
+	// Anything local that's left isn't on the remote side any more.
+	var deletes []*structs.ACLPolicyEntryRequest
+	for _, l := range local {
+		if _, ok := existing[l.ID]; ok {
+			deletes = append(deletes, &structs.ACLPolicyEntryRequest{
+				Op:     structs.ACLDelete,
+				Policy: *l,
+			})
+		}
+	}
+	return sets, deletes
+}
+
+// aclPolicyIndexSorter sorts ACL policies by the index they were last
+// modified at.
+type aclPolicyIndexSorter structs.ACLPolicyEntries
+
+// See sort.Interface.
+func (a aclPolicyIndexSorter) Len() int {
+	return len(a)
+}
+
+// See sort.Interface.
+func (a aclPolicyIndexSorter) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+// See sort.Interface.
+func (a aclPolicyIndexSorter) Less(i, j int) bool {
+	return a[i].ModifyIndex < a[j].ModifyIndex
+}
+
 // FetchLocalACLs returns the ACLs in the local state store.
 func (s *Server) fetchLocalACLs() (structs.ACLs, error) {
 	_, local, err := s.fsm.State().ACLList()
@@ -167,6 +229,35 @@ func (s *Server) fetchRemoteACLs(lastRemoteIndex uint64) (*structs.IndexedACLs,
 	return &remote, nil
 }
 
+// fetchLocalACLPolicies returns the ACL policies in the local state store.
+func (s *Server) fetchLocalACLPolicies() (structs.ACLPolicyEntries, error) {
+	_, local, err := s.fsm.State().ACLPolicyList()
+	if err != nil {
+		return nil, err
+	}
+	return local, nil
+}
+
+// fetchRemoteACLPolicies is used to get the remote set of ACL policies from
+// the ACL datacenter. This doesn't block, since the remote ACL list already
+// blocks on changes to the policies.
+func (s *Server) fetchRemoteACLPolicies() (*structs.IndexedACLPolicyEntries, error) {
+	defer metrics.MeasureSince([]string{"consul", "leader", "fetchRemoteACLPolicies"}, time.Now())
+
+	args := structs.DCSpecificRequest{
+		Datacenter: s.config.ACLDatacenter,
+		QueryOptions: structs.QueryOptions{
+			Token:      s.config.ACLReplicationToken,
+			AllowStale: true,
+		},
+	}
+	var remote structs.IndexedACLPolicyEntries
+	if err := s.RPC("ACL.PolicyList", &args, &remote); err != nil {
+		return nil, err
+	}
+	return &remote, nil
+}
+
 // UpdateLocalACLs is given a list of changes to apply in order to bring the
 // local ACLs in-line with the remote ACLs from the ACL datacenter.
 func (s *Server) updateLocalACLs(changes structs.ACLRequests) error {
@@ -197,6 +288,29 @@ func (s *Server) updateLocalACLs(changes structs.ACLRequests) error {
 	return nil
 }
 
+// updateLocalACLPolicies is given a list of changes to apply in order to
+// bring the local ACL policies in-line with the remote ones from the ACL
+// datacenter. This is rate limited the same way as updateLocalACLs.
+func (s *Server) updateLocalACLPolicies(changes []*structs.ACLPolicyEntryRequest) error {
+	defer metrics.MeasureSince([]string{"consul", "leader", "updateLocalACLPolicies"}, time.Now())
+
+	minTimePerOp := time.Second / time.Duration(s.config.ACLReplicationApplyLimit)
+	for _, change := range changes {
+		start := time.Now()
+		resp, err := s.raftApply(structs.ACLPolicyEntryRequestType, change)
+		if err != nil {
+			return err
+		}
+		if respErr, ok := resp.(error); ok {
+			return respErr
+		}
+
+		elapsed := time.Now().Sub(start)
+		time.Sleep(minTimePerOp - elapsed)
+	}
+	return nil
+}
+
 // replicateACLs is a runs one pass of the algorithm for replicating ACLs from
 // a remote ACL datacenter to local state. If there's any error, this will return
 // 0 for the lastRemoteIndex, which will cause us to immediately do a full sync
@@ -219,10 +333,34 @@ func (s *Server) replicateACLs(lastRemoteIndex uint64) (uint64, error) {
 	// replication process is.
 	defer metrics.MeasureSince([]string{"consul", "leader", "replicateACLs"}, time.Now())
 
+	// The policies are fetched after the ACLs, so they should have any
+	// policies the ACLs link to. If they're from a server that's further
+	// behind then we try again later, rather than bringing over ACLs with
+	// missing policies.
+	remotePolicies, err := s.fetchRemoteACLPolicies()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve remote ACL policies: %v", err)
+	}
+	known := make(map[string]bool)
+	for _, policy := range remotePolicies.Policies {
+		known[policy.ID] = true
+	}
+	for _, acl := range remote.ACLs {
+		for _, policyID := range acl.Policies {
+			if !known[policyID] {
+				return 0, fmt.Errorf("remote ACL policies are behind the remote ACLs, missing policy '%s'", policyID)
+			}
+		}
+	}
+
 	local, err := s.fetchLocalACLs()
 	if err != nil {
 		return 0, fmt.Errorf("failed to retrieve local ACLs: %v", err)
 	}
+	localPolicies, err := s.fetchLocalACLPolicies()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve local ACL policies: %v", err)
+	}
 
 	// If the remote index ever goes backwards, it's a good indication that
 	// the remote side was rebuilt and we should do a full sync since we
@@ -233,11 +371,19 @@ func (s *Server) replicateACLs(lastRemoteIndex uint64) (uint64, error) {
 	}
 
 	// Calculate the changes required to bring the state into sync and then
-	// apply them.
+	// apply them. The policies have to be there before any ACLs link to
+	// them, and can only be deleted once no ACLs link to them.
+	policySets, policyDeletes := reconcileACLPolicies(localPolicies, remotePolicies.Policies, lastRemoteIndex)
+	if err := s.updateLocalACLPolicies(policySets); err != nil {
+		return 0, fmt.Errorf("failed to sync ACL policy changes: %v", err)
+	}
 	changes := reconcileACLs(local, remote.ACLs, lastRemoteIndex)
 	if err := s.updateLocalACLs(changes); err != nil {
 		return 0, fmt.Errorf("failed to sync ACL changes: %v", err)
 	}
+	if err := s.updateLocalACLPolicies(policyDeletes); err != nil {
+		return 0, fmt.Errorf("failed to sync ACL policy changes: %v", err)
+	}
 
 	// Return the index we got back from the remote side, since we've synced
 	// up with the remote state as of that index.
@@ -219,6 +219,47 @@ func TestACLReplication_reconcileACLs(t *testing.T) {
 	}
 }
 
+func TestACLReplication_reconcileACLPolicies(t *testing.T) {
+	policy := func(id, name string, index uint64) *structs.ACLPolicyEntry {
+		return &structs.ACLPolicyEntry{
+			ID:        id,
+			Name:      name,
+			RaftIndex: structs.RaftIndex{ModifyIndex: index},
+		}
+	}
+	local := structs.ACLPolicyEntries{
+		policy("a", "alpha", 1),
+		policy("b", "bravo", 2),
+		policy("c", "charlie", 3),
+	}
+
+	// Policy "a" is unchanged, "b" was renamed and then the new policy
+	// "d" took its old name, and "c" is gone. The rename has to go first.
+	remote := structs.ACLPolicyEntries{
+		policy("a", "alpha", 1),
+		policy("b", "bravo2", 5),
+		policy("d", "bravo", 6),
+	}
+	sets, deletes := reconcileACLPolicies(local, remote, 0)
+	var got []string
+	for _, set := range sets {
+		got = append(got, set.Policy.ID)
+	}
+	if !reflect.DeepEqual(got, []string{"b", "d"}) {
+		t.Fatalf("bad: %v", got)
+	}
+	if len(deletes) != 1 || deletes[0].Op != structs.ACLDelete || deletes[0].Policy.ID != "c" {
+		t.Fatalf("bad: %v", deletes)
+	}
+
+	// Changes at or before the last remote index are skipped, but new
+	// policies are always added.
+	sets, _ = reconcileACLPolicies(local, remote, 8)
+	if len(sets) != 1 || sets[0].Policy.ID != "d" {
+		t.Fatalf("bad: %v", sets)
+	}
+}
+
 func TestACLReplication_updateLocalACLs_RateLimit(t *testing.T) {
 	dir1, s1 := testServerWithConfig(t, func(c *Config) {
 		c.Datacenter = "dc2"
@@ -381,6 +422,23 @@ func TestACLReplication(t *testing.T) {
 			}
 		}
 
+		_, remotePolicies, err := s1.fsm.State().ACLPolicyList()
+		if err != nil {
+			return false, err
+		}
+		_, localPolicies, err := s2.fsm.State().ACLPolicyList()
+		if err != nil {
+			return false, err
+		}
+		if len(remotePolicies) != len(localPolicies) {
+			return false, nil
+		}
+		for i, policy := range remotePolicies {
+			if !policy.IsSame(localPolicies[i]) {
+				return false, nil
+			}
+		}
+
 		var status structs.ACLReplicationStatus
 		s2.aclReplicationStatusLock.RLock()
 		status = s2.aclReplicationStatus
@@ -440,4 +498,70 @@ func TestACLReplication(t *testing.T) {
 	testutil.WaitForResult(checkSame, func(err error) {
 		t.Fatalf("ACLs didn't converge")
 	})
+
+	// Create a policy and a token linked to it.
+	policyArg := structs.ACLPolicyEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		Policy: structs.ACLPolicyEntry{
+			Name:  "web",
+			Rules: testACLPolicy,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var policyID string
+	if err := s1.RPC("ACL.PolicyApply", &policyArg, &policyID); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	arg = structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name:     "Linked token",
+			Type:     structs.ACLTypeClient,
+			Policies: []string{policyID},
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	if err := s1.RPC("ACL.Apply", &arg, &id); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Wait for the replica to converge.
+	testutil.WaitForResult(checkSame, func(err error) {
+		t.Fatalf("ACLs didn't converge")
+	})
+
+	// The linked token can be resolved from the replicated ACLs.
+	if _, rules, _, err := s2.aclLocalLookup(id); err != nil || len(rules) != 2 || rules[1] != testACLPolicy {
+		t.Fatalf("bad: %v %v", rules, err)
+	}
+
+	// Changing just the policy gets replicated too.
+	policyArg.Policy.ID = policyID
+	policyArg.Policy.Rules = ""
+	if err := s1.RPC("ACL.PolicyApply", &policyArg, &dontCare); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testutil.WaitForResult(checkSame, func(err error) {
+		t.Fatalf("ACLs didn't converge")
+	})
+
+	// Delete the token and then the policy.
+	arg = structs.ACLRequest{
+		Datacenter:   "dc1",
+		Op:           structs.ACLDelete,
+		ACL:          structs.ACL{ID: id},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	if err := s1.RPC("ACL.Apply", &arg, &dontCare); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	policyArg.Op = structs.ACLDelete
+	if err := s1.RPC("ACL.PolicyApply", &policyArg, &dontCare); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testutil.WaitForResult(checkSame, func(err error) {
+		t.Fatalf("ACLs didn't converge")
+	})
 }
@@ -1,16 +1,23 @@
 package consul
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
 )
 
 var testACLPolicy = `
@@ -624,6 +631,76 @@ func TestACL_DownPolicy_ExtendCache(t *testing.T) {
 	}
 }
 
+func TestACL_NonAuthority_Expired(t *testing.T) {
+	// Fake up an ACL datacenter that hands out a policy for a token that
+	// expires soon, unless it's been told to fail.
+	expires := time.Now().Add(200 * time.Millisecond)
+	var calls int
+	var rpcErr error
+	rpc := func(method string, args interface{}, reply interface{}) error {
+		calls++
+		if rpcErr != nil {
+			return rpcErr
+		}
+		policy, err := acl.Parse(testACLPolicy)
+		if err != nil {
+			return err
+		}
+		out := reply.(*structs.ACLPolicy)
+		out.ETag = makeACLETag("deny", policy)
+		out.TTL = 30 * time.Second
+		e := expires
+		out.ExpirationTime = &e
+		out.Parent = "deny"
+		out.Policy = policy
+		return nil
+	}
+	config := DefaultConfig()
+	config.ACLDownPolicy = "extend-cache"
+	cache, err := newAclCache(config, log.New(os.Stderr, "", log.LstdFlags), rpc, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The token resolves, and is served from the cache after that.
+	for i := 0; i < 2; i++ {
+		acl, err := cache.lookupACL("token", "dc1")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !acl.KeyRead("foo/test") {
+			t.Fatalf("should allow")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("bad: %d", calls)
+	}
+
+	// Once the token expires the cache isn't used, even though its entry
+	// is still live, and the down policy doesn't extend it.
+	time.Sleep(expires.Sub(time.Now()))
+	rpcErr = errors.New("ACL datacenter is down")
+	if _, err := cache.lookupACL("token", "dc1"); err == nil || err.Error() != aclNotFound {
+		t.Fatalf("err: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("bad: %d", calls)
+	}
+
+	// If the token's expiration was pushed out it's picked up.
+	rpcErr = nil
+	expires = time.Now().Add(time.Minute)
+	if _, err := cache.lookupACL("token", "dc1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := cache.lookupACL("token", "dc1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("bad: %d", calls)
+	}
+}
+
 func TestACL_Replication(t *testing.T) {
 	dir1, s1 := testServerWithConfig(t, func(c *Config) {
 		c.ACLDatacenter = "dc1"
@@ -1004,6 +1081,35 @@ func TestACL_filterCheckServiceNodes(t *testing.T) {
 		t.Fatalf("bad: %#v", nodes[0].Checks)
 	}
 
+	// Allowed to see the service but not the node.
+	policy, err := acl.Parse(`
+service "foo" {
+  policy = "read"
+}
+`)
+	if err != nil {
+		t.Fatalf("err %v", err)
+	}
+	perms, err := acl.New(acl.DenyAll(), policy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// This will work because version 8 ACLs aren't being enforced.
+	filt = newAclFilter(perms, nil, false)
+	filt.filterCheckServiceNodes(&nodes)
+	if len(nodes) != 1 {
+		t.Fatalf("bad: %#v", nodes)
+	}
+
+	// But with version 8 the node will block it.
+	blocked := append(structs.CheckServiceNodes{}, nodes...)
+	filt = newAclFilter(perms, nil, true)
+	filt.filterCheckServiceNodes(&blocked)
+	if len(blocked) != 0 {
+		t.Fatalf("bad: %#v", blocked)
+	}
+
 	// Try restrictive filtering
 	filt = newAclFilter(acl.DenyAll(), nil, false)
 	filt.filterCheckServiceNodes(&nodes)
@@ -1545,3 +1651,140 @@ service "service" {
 		t.Fatalf("err: %v", err)
 	}
 }
+
+func TestACL_AuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consul")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	auditLog, err := audit.NewLogger(&audit.Config{
+		Path:      path,
+		Resources: []string{audit.ResourceACL, audit.ResourceKV, audit.ResourceCatalog},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer auditLog.Close()
+
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+		c.AuditLog = auditLog
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create a token that can only write under foo/.
+	arg := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name:  "User token",
+			Type:  structs.ACLTypeClient,
+			Rules: testACLPolicy,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var id string
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &id); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A write the token is allowed to make.
+	kvArgs := structs.KVSRequest{
+		Datacenter: "dc1",
+		Op:         structs.KVSSet,
+		DirEnt: structs.DirEntry{
+			Key:   "foo/bar",
+			Value: []byte("test"),
+		},
+		WriteRequest: structs.WriteRequest{Token: id},
+	}
+	var ok bool
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &kvArgs, &ok); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// And one it isn't.
+	kvArgs.DirEnt.Key = "nope"
+	err = msgpackrpc.CallWithCodec(codec, "KVS.Apply", &kvArgs, &ok)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A transaction with a denied catalog operation.
+	txnArgs := structs.TxnRequest{
+		Datacenter: "dc1",
+		Ops: structs.TxnOps{
+			&structs.TxnOp{
+				Node: &structs.TxnNodeOp{
+					Verb: structs.TxnSet,
+					Node: structs.Node{Node: "node1", Address: "127.0.0.1"},
+				},
+			},
+		},
+		WriteRequest: structs.WriteRequest{Token: id},
+	}
+	var txnOut structs.TxnResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &txnArgs, &txnOut); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(txnOut.Errors) != 1 {
+		t.Fatalf("bad: %v", txnOut)
+	}
+
+	// Listing tokens is denied. This is recorded for the anonymous
+	// token.
+	listArgs := structs.DCSpecificRequest{Datacenter: "dc1"}
+	var acls structs.IndexedACLs
+	err = msgpackrpc.CallWithCodec(codec, "ACL.List", &listArgs, &acls)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Operator decisions aren't recorded since they aren't configured.
+	var raftOut structs.RaftConfigurationResponse
+	err = msgpackrpc.CallWithCodec(codec, "Operator.RaftGetConfiguration", &listArgs, &raftOut)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The leader registers itself in the catalog, which is recorded too,
+	// so only look at the entries for our requests.
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if strings.Contains(string(buf), id) {
+		t.Fatalf("token leaked: %s", buf)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+		var entry audit.Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if entry.Endpoint == "Catalog.Register" {
+			continue
+		}
+		got = append(got, fmt.Sprintf("%s %s %s %s %s", entry.Accessor, entry.Endpoint,
+			entry.ResourceType, entry.Resource, entry.Decision))
+	}
+	expected := []string{
+		fmt.Sprintf("%s ACL.Apply acl %s allow", audit.Accessor("root"), audit.Accessor(id)),
+		fmt.Sprintf("%s KVS.Apply kv foo/bar allow", audit.Accessor(id)),
+		fmt.Sprintf("%s KVS.Apply kv nope deny", audit.Accessor(id)),
+		fmt.Sprintf("%s Txn.Apply catalog node1 deny", audit.Accessor(id)),
+		"anonymous ACL.List acl  deny",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("bad: %#v", got)
+	}
+}
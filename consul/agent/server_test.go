@@ -56,6 +56,7 @@ func TestIsConsulServer(t *testing.T) {
 		Addr: net.IP([]byte{127, 0, 0, 1}),
 		Tags: map[string]string{
 			"role": "consul",
+			"id":   "asdf",
 			"dc":   "east-aws",
 			"port": "10000",
 			"vsn":  "1",
@@ -68,6 +69,12 @@ func TestIsConsulServer(t *testing.T) {
 	if parts.Name != "foo" {
 		t.Fatalf("bad: %v", parts)
 	}
+	if parts.ID != "asdf" {
+		t.Fatalf("bad: %v", parts.ID)
+	}
+	if parts.RaftVersion != 1 {
+		t.Fatalf("bad: %v", parts.RaftVersion)
+	}
 	if parts.Bootstrap {
 		t.Fatalf("unexpected bootstrap")
 	}
@@ -90,12 +97,16 @@ func TestIsConsulServer(t *testing.T) {
 		t.Fatalf("bad: %v", parts)
 	}
 	m.Tags["expect"] = "3"
+	m.Tags["raft_vsn"] = "3"
 	delete(m.Tags, "bootstrap")
 	delete(m.Tags, "disabled")
 	ok, parts = agent.IsConsulServer(m)
 	if !ok || parts.Expect != 3 {
 		t.Fatalf("bad: %v", parts.Expect)
 	}
+	if parts.RaftVersion != 3 {
+		t.Fatalf("bad: %v", parts.RaftVersion)
+	}
 	if parts.Bootstrap {
 		t.Fatalf("unexpected bootstrap")
 	}
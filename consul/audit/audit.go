@@ -0,0 +1,282 @@
+// Package audit writes a log of ACL decisions, so operators can tell which
+// token was denied access to a resource or made a privileged change to it.
+//
+// Each decision is written as a single line of JSON to a file, which is
+// rotated once it grows past a configured size. Tokens are never written to
+// the log. Instead, each entry carries an accessor derived from the token
+// with a one-way hash, which can be matched against the accessor of a known
+// token but can't be used to recover it.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Decision is the outcome of an ACL check.
+type Decision string
+
+const (
+	Allow Decision = "allow"
+	Deny  Decision = "deny"
+)
+
+// The types of resources that decisions are recorded for.
+const (
+	ResourceACL      = "acl"
+	ResourceAgent    = "agent"
+	ResourceCatalog  = "catalog"
+	ResourceKV       = "kv"
+	ResourceOperator = "operator"
+	ResourceSnapshot = "snapshot"
+)
+
+// ResourceTypes lists all the resource types that can be recorded.
+var ResourceTypes = []string{
+	ResourceACL,
+	ResourceAgent,
+	ResourceCatalog,
+	ResourceKV,
+	ResourceOperator,
+	ResourceSnapshot,
+}
+
+const (
+	// DefaultMaxBytes is the size the log can grow to before it's
+	// rotated, if no size is configured.
+	DefaultMaxBytes = 64 * 1024 * 1024
+
+	// DefaultMaxFiles is the number of rotated logs that are kept, if no
+	// number is configured.
+	DefaultMaxFiles = 5
+)
+
+// anonymousAccessor is the accessor recorded for requests made without a
+// token, which are handled with the anonymous token.
+const anonymousAccessor = "anonymous"
+
+// Accessor returns the identifier that is recorded in place of the given
+// token. It's stable, so all the decisions made with a token can be found,
+// but the token can't be recovered from it.
+func Accessor(token string) string {
+	if token == "" || token == anonymousAccessor {
+		return anonymousAccessor
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Entry is a single decision in the log.
+type Entry struct {
+	// Timestamp is when the decision was made.
+	Timestamp time.Time
+
+	// Accessor identifies the token the decision was made for. See
+	// Accessor.
+	Accessor string
+
+	// Endpoint is the RPC or HTTP endpoint that was called.
+	Endpoint string
+
+	// ResourceType is the type of resource that was being accessed, which
+	// is one of ResourceTypes.
+	ResourceType string
+
+	// Resource names the resource that was being accessed, such as the key
+	// or the node. It's empty if the endpoint doesn't operate on a single
+	// resource.
+	Resource string
+
+	// Decision is whether access was allowed or denied.
+	Decision Decision
+}
+
+// Config configures an audit log.
+type Config struct {
+	// Path is the file the log is written to. Rotated logs are kept next
+	// to it with a numeric suffix, where path.1 is the most recent.
+	Path string
+
+	// MaxBytes is the size the log can grow to before it's rotated. If
+	// it's zero, DefaultMaxBytes is used.
+	MaxBytes int64
+
+	// MaxFiles is the number of rotated logs that are kept. If it's zero,
+	// DefaultMaxFiles is used.
+	MaxFiles int
+
+	// Resources limits the log to decisions about the given resource
+	// types. If it's empty, decisions about all resource types are
+	// recorded.
+	Resources []string
+}
+
+// Logger writes entries to an audit log. A nil Logger is valid and records
+// nothing, so callers don't need to check if auditing is enabled.
+type Logger struct {
+	config    Config
+	resources map[string]bool
+
+	file *os.File
+	size int64
+
+	// lock serializes writes and rotation.
+	lock sync.Mutex
+}
+
+// NewLogger opens the log at the configured path, appending to it if it
+// already exists.
+func NewLogger(config *Config) (*Logger, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("Must provide an audit log path")
+	}
+
+	l := &Logger{config: *config}
+	if l.config.MaxBytes <= 0 {
+		l.config.MaxBytes = DefaultMaxBytes
+	}
+	if l.config.MaxFiles <= 0 {
+		l.config.MaxFiles = DefaultMaxFiles
+	}
+	if len(config.Resources) > 0 {
+		l.resources = make(map[string]bool)
+		for _, resource := range config.Resources {
+			if !ValidResourceType(resource) {
+				return nil, fmt.Errorf("Invalid audit resource type %q", resource)
+			}
+			l.resources[resource] = true
+		}
+	}
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ValidResourceType returns true if the given resource type can be recorded.
+func ValidResourceType(resource string) bool {
+	for _, r := range ResourceTypes {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled returns true if decisions about the given resource type are
+// recorded.
+func (l *Logger) Enabled(resourceType string) bool {
+	if l == nil {
+		return false
+	}
+	return l.resources == nil || l.resources[resourceType]
+}
+
+// Record writes a decision about a resource to the log, if decisions about
+// its type are recorded. The token is recorded as its accessor.
+func (l *Logger) Record(token, endpoint, resourceType, resource string, decision Decision) error {
+	if !l.Enabled(resourceType) {
+		return nil
+	}
+
+	return l.write(&Entry{
+		Timestamp:    time.Now().UTC(),
+		Accessor:     Accessor(token),
+		Endpoint:     endpoint,
+		ResourceType: resourceType,
+		Resource:     resource,
+		Decision:     decision,
+	})
+}
+
+// write appends an entry to the log, rotating it first if the entry would
+// take it past the maximum size.
+func (l *Logger) write(entry *Entry) error {
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+	if l.size > 0 && l.size+int64(len(buf)) > l.config.MaxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(buf)
+	l.size += int64(n)
+	return err
+}
+
+// open opens the log for appending and picks up its current size. The lock
+// must be held, or the logger not yet shared.
+func (l *Logger) open() error {
+	file, err := os.OpenFile(l.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to open audit log: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Failed to open audit log: %v", err)
+	}
+
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the current log aside and starts a new one, dropping the
+// oldest rotated log if there are already too many. The lock must be held.
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+
+	os.Remove(l.rotatedPath(l.config.MaxFiles))
+	for i := l.config.MaxFiles - 1; i > 0; i-- {
+		if err := os.Rename(l.rotatedPath(i), l.rotatedPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Failed to rotate audit log: %v", err)
+		}
+	}
+	if err := os.Rename(l.config.Path, l.rotatedPath(1)); err != nil {
+		return fmt.Errorf("Failed to rotate audit log: %v", err)
+	}
+
+	return l.open()
+}
+
+// rotatedPath returns the path of the n-th most recent rotated log.
+func (l *Logger) rotatedPath(n int) string {
+	return fmt.Sprintf("%s.%d", l.config.Path, n)
+}
+
+// Close closes the log. Any further entries fail to be recorded.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
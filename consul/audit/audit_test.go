@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readEntries(t *testing.T, path string) []*Entry {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return entries
+}
+
+func TestAccessor(t *testing.T) {
+	if got := Accessor(""); got != "anonymous" {
+		t.Fatalf("bad: %s", got)
+	}
+	if got := Accessor("anonymous"); got != "anonymous" {
+		t.Fatalf("bad: %s", got)
+	}
+
+	token := "8f246b77-f3e1-ff88-5b48-8ec93abf3e05"
+	a := Accessor(token)
+	if len(a) != 16 || strings.Contains(a, token) {
+		t.Fatalf("bad: %s", a)
+	}
+	if Accessor(token) != a {
+		t.Fatalf("accessor should be stable")
+	}
+	if Accessor("root") == a {
+		t.Fatalf("accessors should differ")
+	}
+}
+
+func TestLogger_Nil(t *testing.T) {
+	var l *Logger
+	if l.Enabled(ResourceKV) {
+		t.Fatalf("should not be enabled")
+	}
+	if err := l.Record("root", "KVS.Apply", ResourceKV, "foo", Allow); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestLogger_Record(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	l, err := NewLogger(&Config{
+		Path:      path,
+		Resources: []string{ResourceKV, ResourceACL},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := l.Record("secret", "KVS.Apply", ResourceKV, "foo", Deny); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Record("secret", "Catalog.Register", ResourceCatalog, "node1", Allow); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Record("", "ACL.List", ResourceACL, "", Deny); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The catalog entry is left out since it's not configured.
+	entries := readEntries(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("bad: %v", entries)
+	}
+	e := entries[0]
+	if e.Accessor != Accessor("secret") || e.Endpoint != "KVS.Apply" ||
+		e.ResourceType != ResourceKV || e.Resource != "foo" || e.Decision != Deny ||
+		e.Timestamp.IsZero() {
+		t.Fatalf("bad: %#v", e)
+	}
+	e = entries[1]
+	if e.Accessor != "anonymous" || e.Endpoint != "ACL.List" || e.Decision != Deny {
+		t.Fatalf("bad: %#v", e)
+	}
+
+	// The token itself must never be written.
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if strings.Contains(string(buf), "secret") {
+		t.Fatalf("token leaked: %s", buf)
+	}
+
+	// Writing after the log is closed fails.
+	if err := l.Record("secret", "KVS.Apply", ResourceKV, "foo", Deny); err == nil {
+		t.Fatalf("should fail")
+	}
+}
+
+func TestLogger_Rotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	// Each entry is well over 100 bytes, so every write rotates the log.
+	l, err := NewLogger(&Config{
+		Path:     path,
+		MaxBytes: 100,
+		MaxFiles: 2,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := l.Record("root", "KVS.Apply", ResourceKV, key, Allow); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	expect := map[string]string{
+		path:        "d",
+		path + ".1": "c",
+		path + ".2": "b",
+	}
+	for p, key := range expect {
+		entries := readEntries(t, p)
+		if len(entries) != 1 || entries[0].Resource != key {
+			t.Fatalf("bad: %s: %v", p, entries)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("oldest log should have been dropped: %v", err)
+	}
+
+	// Reopening the log appends to it.
+	l.Close()
+	l, err = NewLogger(&Config{Path: path})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+	if err := l.Record("root", "KVS.Apply", ResourceKV, "e", Allow); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if entries := readEntries(t, path); len(entries) != 2 {
+		t.Fatalf("bad: %v", entries)
+	}
+}
+
+func TestNewLogger_Invalid(t *testing.T) {
+	if _, err := NewLogger(&Config{}); err == nil {
+		t.Fatalf("should fail")
+	}
+	if _, err := NewLogger(&Config{Path: "audit.log", Resources: []string{"nope"}}); err == nil ||
+		!strings.Contains(err.Error(), "Invalid audit resource type") {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/types"
 )
@@ -32,6 +33,14 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 		return err
 	}
 
+	// Name what's being registered in the audit log.
+	var resource string
+	if args.Service != nil {
+		resource = catalogResource(args.Node, args.Service.Service)
+	} else {
+		resource = catalogResource(args.Node, "")
+	}
+
 	// Handle a service registration.
 	if args.Service != nil {
 		// If no service id, but service name, use default
@@ -44,6 +53,18 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 			return fmt.Errorf("Must provide service name with ID")
 		}
 
+		// Verify the service metadata is well formed.
+		if err := structs.ValidateMetadata(args.Service.Meta); err != nil {
+			return fmt.Errorf("Invalid service metadata: %v", err)
+		}
+
+		// Verify the weights, if any were given.
+		if args.Service.Weights != nil {
+			if err := args.Service.Weights.Validate(); err != nil {
+				return fmt.Errorf("Invalid service weights: %v", err)
+			}
+		}
+
 		// Apply the ACL policy if any. The 'consul' service is excluded
 		// since it is managed automatically internally (that behavior
 		// is going away after version 0.8). We check this same policy
@@ -51,7 +72,7 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 		// delete this and do all the ACL checks down there.
 		if args.Service.Service != ConsulServiceName {
 			if acl != nil && !acl.ServiceWrite(args.Service.Service) {
-				return permissionDeniedErr
+				return c.srv.auditDenied(permissionDeniedErr, args.Token, "Catalog.Register", audit.ResourceCatalog, resource)
 			}
 		}
 	}
@@ -78,7 +99,7 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 			return fmt.Errorf("Node lookup failed: %v", err)
 		}
 		if err := vetRegisterWithACL(acl, args, ns); err != nil {
-			return err
+			return c.srv.auditDenied(err, args.Token, "Catalog.Register", audit.ResourceCatalog, resource)
 		}
 	}
 
@@ -87,6 +108,9 @@ func (c *Catalog) Register(args *structs.RegisterRequest, reply *struct{}) error
 		return err
 	}
 
+	if acl != nil {
+		c.srv.auditACL(args.Token, "Catalog.Register", audit.ResourceCatalog, resource, audit.Allow)
+	}
 	return nil
 }
 
@@ -129,16 +153,30 @@ func (c *Catalog) Deregister(args *structs.DeregisterRequest, reply *struct{}) e
 		}
 
 		if err := vetDeregisterWithACL(acl, args, ns, nc); err != nil {
-			return err
+			return c.srv.auditDenied(err, args.Token, "Catalog.Deregister", audit.ResourceCatalog,
+				catalogResource(args.Node, args.ServiceID))
 		}
 	}
 
 	if _, err := c.srv.raftApply(structs.DeregisterRequestType, args); err != nil {
 		return err
 	}
+
+	if acl != nil {
+		c.srv.auditACL(args.Token, "Catalog.Deregister", audit.ResourceCatalog,
+			catalogResource(args.Node, args.ServiceID), audit.Allow)
+	}
 	return nil
 }
 
+// catalogResource names a node, or a service on a node, in the audit log.
+func catalogResource(node, service string) string {
+	if service == "" {
+		return node
+	}
+	return node + "/" + service
+}
+
 // ListDatacenters is used to query for the list of known datacenters
 func (c *Catalog) ListDatacenters(args *struct{}, reply *[]string) error {
 	dcs, err := c.srv.getDatacentersByDistance()
@@ -156,6 +194,12 @@ func (c *Catalog) ListNodes(args *structs.DCSpecificRequest, reply *structs.Inde
 		return err
 	}
 
+	// Compile the filter, if one was given
+	f, err := newQueryFilter(&args.QueryOptions, structs.Node{})
+	if err != nil {
+		return err
+	}
+
 	// Get the list of nodes.
 	state := c.srv.fsm.State()
 	return c.srv.blockingRPC(
@@ -163,12 +207,26 @@ func (c *Catalog) ListNodes(args *structs.DCSpecificRequest, reply *structs.Inde
 		&reply.QueryMeta,
 		state.GetQueryWatch("Nodes"),
 		func() error {
-			index, nodes, err := state.Nodes()
+			var index uint64
+			var nodes structs.Nodes
+			var err error
+			if len(args.NodeMetaFilters) > 0 {
+				index, nodes, err = state.NodesByMeta(args.NodeMetaFilters)
+			} else {
+				index, nodes, err = state.Nodes()
+			}
 			if err != nil {
 				return err
 			}
 
 			reply.Index, reply.Nodes = index, nodes
+			if f != nil {
+				raw, err := f.Execute(reply.Nodes)
+				if err != nil {
+					return err
+				}
+				reply.Nodes = raw.(structs.Nodes)
+			}
 			if err := c.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
@@ -210,18 +268,24 @@ func (c *Catalog) ServiceNodes(args *structs.ServiceSpecificRequest, reply *stru
 		return fmt.Errorf("Must provide service name")
 	}
 
+	// Compile the filter, if one was given
+	f, err := newQueryFilter(&args.QueryOptions, structs.ServiceNode{})
+	if err != nil {
+		return err
+	}
+
 	// Get the nodes
 	state := c.srv.fsm.State()
-	err := c.srv.blockingRPC(
+	err = c.srv.blockingRPC(
 		&args.QueryOptions,
 		&reply.QueryMeta,
-		state.GetQueryWatch("ServiceNodes"),
+		state.GetServiceWatch(args.ServiceName),
 		func() error {
 			var index uint64
 			var services structs.ServiceNodes
 			var err error
 			if args.TagFilter {
-				index, services, err = state.ServiceTagNodes(args.ServiceName, args.ServiceTag)
+				index, services, err = state.ServiceTagNodes(args.ServiceName, args.FilterTags())
 			} else {
 				index, services, err = state.ServiceNodes(args.ServiceName)
 			}
@@ -229,6 +293,22 @@ func (c *Catalog) ServiceNodes(args *structs.ServiceSpecificRequest, reply *stru
 				return err
 			}
 			reply.Index, reply.ServiceNodes = index, services
+			if len(args.NodeMetaFilters) > 0 {
+				var filtered structs.ServiceNodes
+				for _, service := range services {
+					if structs.SatisfiesMetaFilters(service.NodeMeta, args.NodeMetaFilters) {
+						filtered = append(filtered, service)
+					}
+				}
+				reply.ServiceNodes = filtered
+			}
+			if f != nil {
+				raw, err := f.Execute(reply.ServiceNodes)
+				if err != nil {
+					return err
+				}
+				reply.ServiceNodes = raw.(structs.ServiceNodes)
+			}
 			if err := c.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
@@ -238,8 +318,8 @@ func (c *Catalog) ServiceNodes(args *structs.ServiceSpecificRequest, reply *stru
 	// Provide some metrics
 	if err == nil {
 		metrics.IncrCounter([]string{"consul", "catalog", "service", "query", args.ServiceName}, 1)
-		if args.ServiceTag != "" {
-			metrics.IncrCounter([]string{"consul", "catalog", "service", "query-tag", args.ServiceName, args.ServiceTag}, 1)
+		for _, tag := range args.FilterTags() {
+			metrics.IncrCounter([]string{"consul", "catalog", "service", "query-tag", args.ServiceName, tag}, 1)
 		}
 		if len(reply.ServiceNodes) == 0 {
 			metrics.IncrCounter([]string{"consul", "catalog", "service", "not-found", args.ServiceName}, 1)
@@ -264,7 +344,7 @@ func (c *Catalog) NodeServices(args *structs.NodeSpecificRequest, reply *structs
 	return c.srv.blockingRPC(
 		&args.QueryOptions,
 		&reply.QueryMeta,
-		state.GetQueryWatch("NodeServices"),
+		state.GetNodeWatch(args.Node),
 		func() error {
 			index, services, err := state.NodeServices(args.Node)
 			if err != nil {
@@ -281,7 +361,8 @@ func (c *Catalog) NodeServices(args *structs.NodeSpecificRequest, reply *structs
 			}
 			if acl != nil && c.srv.config.ACLEnforceVersion8 {
 				if !acl.NodeRead(args.Node) {
-					return permissionDeniedErr
+					return c.srv.auditDenied(permissionDeniedErr, args.Token, "Catalog.NodeServices",
+						audit.ResourceCatalog, catalogResource(args.Node, ""))
 				}
 			}
 
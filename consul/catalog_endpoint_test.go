@@ -49,6 +49,97 @@ func TestCatalog_Register(t *testing.T) {
 	})
 }
 
+func TestCatalog_Register_InvalidServiceMeta(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			Service: "db",
+			Port:    8000,
+			Meta:    map[string]string{"consul-version": "1.2.3"},
+		},
+	}
+	var out struct{}
+
+	err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out)
+	if err == nil || !strings.Contains(err.Error(), "Invalid service metadata") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A valid set of metadata should be accepted and returned.
+	arg.Service.Meta = map[string]string{"version": "1.2.3"}
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "db",
+	}
+	var reply structs.IndexedServiceNodes
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.ServiceNodes", &req, &reply); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(reply.ServiceNodes) != 1 || reply.ServiceNodes[0].ServiceMeta["version"] != "1.2.3" {
+		t.Fatalf("bad: %v", reply.ServiceNodes)
+	}
+}
+
+func TestCatalog_Register_InvalidServiceWeights(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			Service: "db",
+			Port:    8000,
+			Weights: &structs.Weights{Passing: 0, Warning: 1},
+		},
+	}
+	var out struct{}
+
+	err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out)
+	if err == nil || !strings.Contains(err.Error(), "Invalid service weights") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Valid weights should be accepted and returned.
+	arg.Service.Weights = &structs.Weights{Passing: 10, Warning: 1}
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "db",
+	}
+	var reply structs.IndexedServiceNodes
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.ServiceNodes", &req, &reply); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(reply.ServiceNodes) != 1 || reply.ServiceNodes[0].ServiceWeights == nil ||
+		reply.ServiceNodes[0].ServiceWeights.Passing != 10 {
+		t.Fatalf("bad: %v", reply.ServiceNodes)
+	}
+}
+
 func TestCatalog_Register_ACLDeny(t *testing.T) {
 	dir1, s1 := testServerWithConfig(t, func(c *Config) {
 		c.ACLDatacenter = "dc1"
@@ -592,6 +683,125 @@ func TestCatalog_ListNodes(t *testing.T) {
 	}
 }
 
+func TestCatalog_ListNodes_Filter(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	node := &structs.Node{Node: "foo", Address: "127.0.0.1", Meta: map[string]string{"rack": "b"}}
+	if err := s1.fsm.State().EnsureNode(1, node); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	args := structs.DCSpecificRequest{
+		Datacenter: "dc1",
+		QueryOptions: structs.QueryOptions{
+			Filter: `Meta.rack == "b" or Address == "127.0.0.2"`,
+		},
+	}
+	var out structs.IndexedNodes
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.ListNodes", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Nodes) != 1 || out.Nodes[0].Node != "foo" {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// Service nodes are filtered on their own fields.
+	if err := s1.fsm.State().EnsureService(2, "foo", &structs.NodeService{ID: "db", Service: "db", Tags: []string{"primary"}, Port: 5000}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := s1.fsm.State().EnsureService(3, "foo", &structs.NodeService{ID: "db2", Service: "db", Tags: []string{"secondary"}, Port: 5001}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	serviceArgs := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "db",
+		QueryOptions: structs.QueryOptions{
+			Filter: `"primary" not in ServiceTags and NodeMeta.rack == "b"`,
+		},
+	}
+	var services structs.IndexedServiceNodes
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.ServiceNodes", &serviceArgs, &services); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(services.ServiceNodes) != 1 || services.ServiceNodes[0].ServicePort != 5001 {
+		t.Fatalf("bad: %v", services)
+	}
+
+	// Bad expressions are rejected.
+	args.Filter = `Meta.rack ==`
+	var bad structs.IndexedNodes
+	err := msgpackrpc.CallWithCodec(codec, "Catalog.ListNodes", &args, &bad)
+	if err == nil || !strings.Contains(err.Error(), "Invalid filter expression") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestCatalog_ListNodes_MetaFilter(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Add a new node with the right meta k/v pair
+	node := &structs.Node{Node: "foo", Address: "127.0.0.1", Meta: map[string]string{"somekey": "somevalue"}}
+	if err := s1.fsm.State().EnsureNode(1, node); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Filter by a specific meta k/v pair
+	args := structs.DCSpecificRequest{
+		Datacenter: "dc1",
+		NodeMetaFilters: map[string]string{
+			"somekey": "somevalue",
+		},
+	}
+	var out structs.IndexedNodes
+	testutil.WaitForResult(func() (bool, error) {
+		msgpackrpc.CallWithCodec(codec, "Catalog.ListNodes", &args, &out)
+		return len(out.Nodes) == 1, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+
+	// Verify that only the correct node was returned
+	if out.Nodes[0].Node != "foo" {
+		t.Fatalf("bad: %v", out)
+	}
+	if out.Nodes[0].Address != "127.0.0.1" {
+		t.Fatalf("bad: %v", out)
+	}
+	if v, ok := out.Nodes[0].Meta["somekey"]; !ok || v != "somevalue" {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// Now filter on a nonexistent meta k/v pair
+	args = structs.DCSpecificRequest{
+		Datacenter: "dc1",
+		NodeMetaFilters: map[string]string{
+			"somekey": "invalid",
+		},
+	}
+	out = structs.IndexedNodes{}
+	err := msgpackrpc.CallWithCodec(codec, "Catalog.ListNodes", &args, &out)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Should get an empty list of nodes back
+	if len(out.Nodes) != 0 {
+		t.Fatalf("bad: %v", out)
+	}
+}
+
 func TestCatalog_ListNodes_StaleRaad(t *testing.T) {
 	dir1, s1 := testServer(t)
 	defer os.RemoveAll(dir1)
@@ -1181,6 +1391,86 @@ func TestCatalog_ListServiceNodes(t *testing.T) {
 	}
 }
 
+func TestCatalog_ListServiceNodes_NodeMetaFilter(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Add 2 nodes with specific meta maps
+	node := &structs.Node{Node: "foo", Address: "127.0.0.1", Meta: map[string]string{"somekey": "somevalue", "common": "1"}}
+	if err := s1.fsm.State().EnsureNode(1, node); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	node2 := &structs.Node{Node: "bar", Address: "127.0.0.2", Meta: map[string]string{"common": "1"}}
+	if err := s1.fsm.State().EnsureNode(2, node2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := s1.fsm.State().EnsureService(3, "foo", &structs.NodeService{ID: "db", Service: "db", Tags: []string{"primary", "v2"}, Address: "127.0.0.1", Port: 5000}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := s1.fsm.State().EnsureService(4, "bar", &structs.NodeService{ID: "db2", Service: "db", Tags: []string{"secondary", "v2"}, Address: "127.0.0.2", Port: 5000}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		filters  map[string]string
+		services structs.ServiceNodes
+	}{
+		// Basic meta filter
+		{
+			filters:  map[string]string{"somekey": "somevalue"},
+			services: structs.ServiceNodes{&structs.ServiceNode{Node: "foo", ServiceID: "db"}},
+		},
+		// Common meta filter
+		{
+			filters: map[string]string{"common": "1"},
+			services: structs.ServiceNodes{
+				&structs.ServiceNode{Node: "bar", ServiceID: "db2"},
+				&structs.ServiceNode{Node: "foo", ServiceID: "db"},
+			},
+		},
+		// Invalid meta filter
+		{
+			filters:  map[string]string{"invalid": "nope"},
+			services: structs.ServiceNodes{},
+		},
+		// Multiple filter values
+		{
+			filters:  map[string]string{"somekey": "somevalue", "common": "1"},
+			services: structs.ServiceNodes{&structs.ServiceNode{Node: "foo", ServiceID: "db"}},
+		},
+	}
+
+	for _, tc := range cases {
+		args := structs.ServiceSpecificRequest{
+			Datacenter:      "dc1",
+			NodeMetaFilters: tc.filters,
+			ServiceName:     "db",
+		}
+		var out structs.IndexedServiceNodes
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.ServiceNodes", &args, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		if len(out.ServiceNodes) != len(tc.services) {
+			t.Fatalf("bad: %v", out)
+		}
+
+		for i, serviceNode := range out.ServiceNodes {
+			if serviceNode.Node != tc.services[i].Node || serviceNode.ServiceID != tc.services[i].ServiceID {
+				t.Fatalf("bad: %v, %v filters: %v", serviceNode, tc.services[i], tc.filters)
+			}
+			if !structs.SatisfiesMetaFilters(serviceNode.NodeMeta, tc.filters) {
+				t.Fatalf("bad: %v", serviceNode)
+			}
+		}
+	}
+}
+
 func TestCatalog_ListServiceNodes_DistanceSort(t *testing.T) {
 	dir1, s1 := testServer(t)
 	defer os.RemoveAll(dir1)
@@ -70,6 +70,10 @@ type Client struct {
 	// Logger uses the provided LogOutput
 	logger *log.Logger
 
+	// aclCache is a non-authoritative ACL cache used to resolve tokens
+	// for requests served directly by the agent.
+	aclCache *aclCache
+
 	// serf is the Serf cluster maintained inside the DC
 	// which contains all the DC nodes
 	serf *serf.Serf
@@ -120,6 +124,12 @@ func NewClient(config *Config) (*Client, error) {
 		shutdownCh: make(chan struct{}),
 	}
 
+	// Set up the non-authoritative ACL cache. Clients have no local copy
+	// of the ACLs, so lookups always go to the servers.
+	if c.aclCache, err = newAclCache(config, logger, c.RPC, nil); err != nil {
+		return nil, fmt.Errorf("Failed to create ACL cache: %v", err)
+	}
+
 	// Start lan event handlers before lan Serf setup to prevent deadlock
 	go c.lanEventHandler()
 
@@ -144,6 +154,7 @@ func (c *Client) setupSerf(conf *serf.Config, ch chan serf.Event, path string) (
 	conf.NodeName = c.config.NodeName
 	conf.Tags["role"] = "node"
 	conf.Tags["dc"] = c.config.Datacenter
+	conf.Tags["id"] = string(c.config.NodeID)
 	conf.Tags["vsn"] = fmt.Sprintf("%d", c.config.ProtocolVersion)
 	conf.Tags["vsn_min"] = fmt.Sprintf("%d", ProtocolVersionMin)
 	conf.Tags["vsn_max"] = fmt.Sprintf("%d", ProtocolVersionMax)
@@ -11,6 +11,8 @@ import (
 
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/consul/types"
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/net-rpc-msgpackrpc"
 	"github.com/hashicorp/serf/serf"
 )
@@ -18,9 +20,16 @@ import (
 func testClientConfig(t *testing.T, NodeName string) (string, *Config) {
 	dir := tmpDir(t)
 	config := DefaultConfig()
+
+	nodeID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
 	config.Datacenter = "dc1"
 	config.DataDir = dir
 	config.NodeName = NodeName
+	config.NodeID = types.NodeID(nodeID)
 	config.RPCAddr = &net.TCPAddr{
 		IP:   []byte{127, 0, 0, 1},
 		Port: getPort(),
@@ -7,7 +7,10 @@ import (
 	"os"
 	"time"
 
+	"github.com/hashicorp/consul/consul/audit"
+	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/tlsutil"
+	"github.com/hashicorp/consul/types"
 	"github.com/hashicorp/memberlist"
 	"github.com/hashicorp/raft"
 	"github.com/hashicorp/serf/serf"
@@ -66,6 +69,9 @@ type Config struct {
 	// DevMode is used to enable a development server mode.
 	DevMode bool
 
+	// NodeID is a unique identifier for this node across space and time.
+	NodeID types.NodeID
+
 	// Node name is the name we use to advertise. Defaults to hostname.
 	NodeName string
 
@@ -230,6 +236,38 @@ type Config struct {
 	// Minimum Session TTL
 	SessionTTLMin time.Duration
 
+	// KVSHistoryRules lists the key prefixes whose prior versions are
+	// kept. The rules are managed through the Operator endpoint, so the
+	// leader only uses these to seed them if none have been set yet.
+	KVSHistoryRules structs.KVSHistoryRules
+
+	// KVSQuotas limits the keys that can be written under key prefixes.
+	// Quotas are enforced by the leader, so this should be the same on all
+	// servers.
+	KVSQuotas structs.KVSQuotas
+
+	// KVSReplication lists the key prefixes the leader copies from other
+	// datacenters into the local KV store.
+	KVSReplication []*KVSReplicationConfig
+
+	// KVSReplicationInterval is the interval at which KV replication passes
+	// will occur. Queries to the source datacenters may block, so
+	// replication can happen less often than this, but the interval forms
+	// the upper limit to how fast we will go if there was constant churn
+	// on the remote end.
+	KVSReplicationInterval time.Duration
+
+	// KVSReplicationApplyLimit is the max number of KV replication-related
+	// apply operations that we allow during a one second period for each
+	// replicated prefix. This is used to limit the amount of Raft bandwidth
+	// used for replication.
+	KVSReplicationApplyLimit int
+
+	// AuditLog, if set, records ACL decisions made by this server, such as
+	// requests that are denied and privileged writes that succeed. The
+	// caller owns the log and is responsible for closing it.
+	AuditLog *audit.Logger
+
 	// ServerUp callback can be used to trigger a notification that
 	// a Consul server is now up and known about.
 	ServerUp func()
@@ -264,6 +302,16 @@ type Config struct {
 	RPCHoldTimeout time.Duration
 }
 
+// KVSReplicationConfig copies the keys under Prefix in the SourceDatacenter
+// to the same keys under DestPrefix in the local datacenter. Token is used
+// to read the keys from the source datacenter.
+type KVSReplicationConfig struct {
+	SourceDatacenter string
+	Prefix           string
+	DestPrefix       string
+	Token            string
+}
+
 // CheckVersion is used to check if the ProtocolVersion is valid
 func (c *Config) CheckVersion() error {
 	if c.ProtocolVersion < ProtocolVersionMin {
@@ -315,6 +363,8 @@ func DefaultConfig() *Config {
 		ACLDownPolicy:            "extend-cache",
 		ACLReplicationInterval:   30 * time.Second,
 		ACLReplicationApplyLimit: 100, // ops / sec
+		KVSReplicationInterval:   time.Second,
+		KVSReplicationApplyLimit: 500, // ops / sec
 		TombstoneTTL:             15 * time.Minute,
 		TombstoneTTLGranularity:  30 * time.Second,
 		SessionTTLMin:            10 * time.Second,
@@ -0,0 +1,188 @@
+package consul
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul/consul/state"
+	"github.com/hashicorp/consul/consul/structs"
+)
+
+const (
+	// eventBufferSize is the number of recent events each server keeps so
+	// that subscribers can pick up where they left off after reconnecting.
+	// Subscribers that fall further behind than this get a fresh snapshot.
+	eventBufferSize = 4096
+)
+
+// eventPublisher keeps a bounded buffer of the events generated as the FSM
+// applies changes, and wakes up any subscribers that are waiting on them.
+type eventPublisher struct {
+	// size is the maximum number of events to keep.
+	size int
+
+	// events holds the most recent events, in index order.
+	events []*structs.StreamEvent
+
+	// floor is the highest index with events that are no longer in the
+	// buffer. Only subscribers at or beyond it can be sent changes.
+	floor uint64
+
+	// watches is used to wake up subscribers, keyed by topic and key.
+	watches *state.KeyWatchManager
+
+	// lock protects the buffer and floor.
+	lock sync.RWMutex
+}
+
+// newEventPublisher returns a publisher that keeps up to the given number of
+// events.
+func newEventPublisher(size int) *eventPublisher {
+	return &eventPublisher{
+		size:    size,
+		watches: state.NewKeyWatchManager(),
+	}
+}
+
+// watchKey returns the key used to watch for events on a topic and key.
+func watchKey(topic structs.StreamTopic, key string) string {
+	return string(topic) + "/" + key
+}
+
+// Publish adds the given events to the buffer and wakes up any subscribers
+// that are waiting on them. The events must be at or after the index of any
+// previously published events.
+func (p *eventPublisher) Publish(events []*structs.StreamEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	p.lock.Lock()
+	p.events = append(p.events, events...)
+	if n := len(p.events) - p.size; n > 0 {
+		p.floor = p.events[n-1].Index
+		p.events = p.events[n:]
+	}
+	p.lock.Unlock()
+
+	for _, event := range events {
+		p.watches.Notify(watchKey(event.Topic, event.Key))
+	}
+}
+
+// Reset drops all of the buffered events and forces every subscriber that
+// is behind the given index to start over with a snapshot. This is used when
+// the state store is replaced wholesale, such as during a snapshot restore.
+func (p *eventPublisher) Reset(index uint64) {
+	p.lock.Lock()
+	p.events = nil
+	p.floor = index
+	p.lock.Unlock()
+
+	p.watches.NotifyAll()
+}
+
+// Events returns the buffered events on the given topic and key that came
+// after the given index. This returns false if the publisher no longer has
+// all of the events after the index, in which case the subscriber needs a
+// new snapshot.
+func (p *eventPublisher) Events(topic structs.StreamTopic, key string, index uint64) ([]*structs.StreamEvent, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if index < p.floor {
+		return nil, false
+	}
+
+	var events []*structs.StreamEvent
+	start := sort.Search(len(p.events), func(i int) bool {
+		return p.events[i].Index > index
+	})
+	for _, event := range p.events[start:] {
+		if event.Topic == topic && strings.EqualFold(event.Key, key) {
+			events = append(events, event)
+		}
+	}
+	return events, true
+}
+
+// Watch returns a watch that fires when events are published for the given
+// topic and key, or when the publisher is reset.
+func (p *eventPublisher) Watch(topic structs.StreamTopic, key string) state.Watch {
+	return p.watches.NewKeyWatch(watchKey(topic, key))
+}
+
+// serviceHealthEvents compares the health of the services on a node before
+// and after a change, and returns events for the services that were added,
+// updated or removed. Changes that only touch the Raft indexes, such as an
+// idempotent re-registration, don't generate any events.
+func serviceHealthEvents(index uint64, before, after structs.CheckServiceNodes) []*structs.StreamEvent {
+	event := func(op structs.StreamOp, csn structs.CheckServiceNode) *structs.StreamEvent {
+		return &structs.StreamEvent{
+			Topic:         structs.StreamTopicServiceHealth,
+			Key:           csn.Service.Service,
+			Index:         index,
+			Op:            op,
+			ServiceHealth: &csn,
+		}
+	}
+
+	existing := make(map[string]structs.CheckServiceNode)
+	for _, csn := range before {
+		existing[csn.Service.ID] = csn
+	}
+
+	// Removals go first, in case a service was renamed.
+	var events []*structs.StreamEvent
+	current := make(map[string]structs.CheckServiceNode)
+	for _, csn := range after {
+		current[csn.Service.ID] = csn
+	}
+	for _, csn := range before {
+		next, ok := current[csn.Service.ID]
+		if !ok || !strings.EqualFold(next.Service.Service, csn.Service.Service) {
+			events = append(events, event(structs.StreamDelete, csn))
+		}
+	}
+
+	for _, csn := range after {
+		prev, ok := existing[csn.Service.ID]
+		switch {
+		case !ok || !strings.EqualFold(prev.Service.Service, csn.Service.Service):
+			events = append(events, event(structs.StreamAdd, csn))
+		case !sameServiceHealth(prev, csn):
+			events = append(events, event(structs.StreamUpdate, csn))
+		}
+	}
+	return events
+}
+
+// sameServiceHealth returns true if the two entries only differ in their Raft
+// indexes.
+func sameServiceHealth(a, b structs.CheckServiceNode) bool {
+	an, bn := *a.Node, *b.Node
+	an.RaftIndex, bn.RaftIndex = structs.RaftIndex{}, structs.RaftIndex{}
+	if !reflect.DeepEqual(an, bn) {
+		return false
+	}
+
+	as, bs := *a.Service, *b.Service
+	as.RaftIndex, bs.RaftIndex = structs.RaftIndex{}, structs.RaftIndex{}
+	if !reflect.DeepEqual(as, bs) {
+		return false
+	}
+
+	if len(a.Checks) != len(b.Checks) {
+		return false
+	}
+	for i := range a.Checks {
+		ac, bc := *a.Checks[i], *b.Checks[i]
+		ac.RaftIndex, bc.RaftIndex = structs.RaftIndex{}, structs.RaftIndex{}
+		if !reflect.DeepEqual(ac, bc) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package consul
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/consul/state"
+	"github.com/hashicorp/consul/consul/structs"
+)
+
+func testStreamEvent(index uint64, key string) *structs.StreamEvent {
+	return &structs.StreamEvent{
+		Topic: structs.StreamTopicServiceHealth,
+		Key:   key,
+		Index: index,
+		Op:    structs.StreamUpdate,
+	}
+}
+
+func TestEventPublisher_Events(t *testing.T) {
+	p := newEventPublisher(4)
+
+	// Nothing has happened yet, but the subscriber is still up to date.
+	events, ok := p.Events(structs.StreamTopicServiceHealth, "web", 0)
+	if !ok || len(events) != 0 {
+		t.Fatalf("bad: %v %v", events, ok)
+	}
+
+	// Publish a few events, two of them at the same index.
+	p.Publish([]*structs.StreamEvent{
+		testStreamEvent(2, "web"),
+		testStreamEvent(2, "db"),
+	})
+	p.Publish([]*structs.StreamEvent{testStreamEvent(3, "web")})
+	p.Publish(nil)
+
+	// Only the events for the key that come after the index are returned,
+	// and keys are case-insensitive.
+	events, ok = p.Events(structs.StreamTopicServiceHealth, "WEB", 1)
+	if !ok || len(events) != 2 || events[0].Index != 2 || events[1].Index != 3 {
+		t.Fatalf("bad: %v %v", events, ok)
+	}
+	events, ok = p.Events(structs.StreamTopicServiceHealth, "web", 2)
+	if !ok || len(events) != 1 || events[0].Index != 3 {
+		t.Fatalf("bad: %v %v", events, ok)
+	}
+	events, ok = p.Events(structs.StreamTopicServiceHealth, "db", 2)
+	if !ok || len(events) != 0 {
+		t.Fatalf("bad: %v %v", events, ok)
+	}
+	events, ok = p.Events(structs.StreamTopic("nope"), "web", 1)
+	if !ok || len(events) != 0 {
+		t.Fatalf("bad: %v %v", events, ok)
+	}
+
+	// Push the first index out of the buffer. Subscribers that haven't
+	// seen all of it have to start over.
+	p.Publish([]*structs.StreamEvent{
+		testStreamEvent(4, "web"),
+		testStreamEvent(5, "web"),
+	})
+	p.Publish([]*structs.StreamEvent{testStreamEvent(6, "web")})
+	if _, ok := p.Events(structs.StreamTopicServiceHealth, "web", 1); ok {
+		t.Fatalf("should need a snapshot")
+	}
+	events, ok = p.Events(structs.StreamTopicServiceHealth, "web", 2)
+	if !ok || len(events) != 4 || events[0].Index != 3 {
+		t.Fatalf("bad: %v %v", events, ok)
+	}
+
+	// A reset drops everything before the given index.
+	p.Reset(10)
+	if _, ok := p.Events(structs.StreamTopicServiceHealth, "web", 6); ok {
+		t.Fatalf("should need a snapshot")
+	}
+	events, ok = p.Events(structs.StreamTopicServiceHealth, "web", 10)
+	if !ok || len(events) != 0 {
+		t.Fatalf("bad: %v %v", events, ok)
+	}
+}
+
+func TestEventPublisher_Watch(t *testing.T) {
+	p := newEventPublisher(16)
+
+	fired := func(watch state.Watch, f func()) bool {
+		notifyCh := make(chan struct{}, 1)
+		watch.Wait(notifyCh)
+		defer watch.Clear(notifyCh)
+		f()
+		select {
+		case <-notifyCh:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}
+
+	// Only events for the watched key fire the watch.
+	watch := p.Watch(structs.StreamTopicServiceHealth, "web")
+	if fired(watch, func() { p.Publish([]*structs.StreamEvent{testStreamEvent(1, "db")}) }) {
+		t.Fatalf("should not fire")
+	}
+	if !fired(watch, func() { p.Publish([]*structs.StreamEvent{testStreamEvent(2, "Web")}) }) {
+		t.Fatalf("should fire")
+	}
+
+	// A reset fires everything.
+	if !fired(watch, func() { p.Reset(3) }) {
+		t.Fatalf("should fire")
+	}
+}
+
+func TestEventPublisher_serviceHealthEvents(t *testing.T) {
+	node := &structs.Node{Node: "foo", Address: "127.0.0.1"}
+	csn := func(id, name string, status string, index uint64) structs.CheckServiceNode {
+		n := *node
+		n.ModifyIndex = index
+		return structs.CheckServiceNode{
+			Node: &n,
+			Service: &structs.NodeService{
+				ID:        id,
+				Service:   name,
+				RaftIndex: structs.RaftIndex{ModifyIndex: index},
+			},
+			Checks: structs.HealthChecks{
+				&structs.HealthCheck{
+					Node:      "foo",
+					CheckID:   "check",
+					Status:    status,
+					RaftIndex: structs.RaftIndex{ModifyIndex: index},
+				},
+			},
+		}
+	}
+	type summary struct {
+		Key string
+		Op  structs.StreamOp
+		ID  string
+	}
+	summarize := func(events []*structs.StreamEvent) []summary {
+		out := []summary{}
+		for _, event := range events {
+			if event.Index != 10 || event.Topic != structs.StreamTopicServiceHealth {
+				t.Fatalf("bad: %#v", event)
+			}
+			out = append(out, summary{event.Key, event.Op, event.ServiceHealth.Service.ID})
+		}
+		return out
+	}
+
+	before := structs.CheckServiceNodes{
+		csn("web1", "web", structs.HealthPassing, 1),
+		csn("web2", "web", structs.HealthPassing, 1),
+		csn("db1", "db", structs.HealthPassing, 1),
+		csn("cache1", "cache", structs.HealthPassing, 1),
+	}
+	after := structs.CheckServiceNodes{
+		csn("web1", "web", structs.HealthPassing, 10),
+		csn("web2", "web", structs.HealthCritical, 10),
+		csn("db1", "redis", structs.HealthPassing, 10),
+		csn("api1", "api", structs.HealthPassing, 10),
+	}
+	actual := summarize(serviceHealthEvents(10, before, after))
+	expected := []summary{
+		{"db", structs.StreamDelete, "db1"},
+		{"cache", structs.StreamDelete, "cache1"},
+		{"web", structs.StreamUpdate, "web2"},
+		{"redis", structs.StreamAdd, "db1"},
+		{"api", structs.StreamAdd, "api1"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// Node changes affect every service on the node.
+	changed := structs.CheckServiceNodes{
+		csn("web1", "web", structs.HealthPassing, 11),
+	}
+	changed[0].Node.Address = "127.0.0.2"
+	actual = summarize(serviceHealthEvents(10, before[:1], changed))
+	expected = []summary{
+		{"web", structs.StreamUpdate, "web1"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// No events for an idempotent change.
+	if events := serviceHealthEvents(10, before, before); len(events) != 0 {
+		t.Fatalf("bad: %#v", events)
+	}
+}
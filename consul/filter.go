@@ -51,8 +51,9 @@ func FilterKeys(acl acl.ACL, keys []string) []string {
 }
 
 type txnResultsFilter struct {
-	acl     acl.ACL
-	results structs.TxnResults
+	acl             acl.ACL
+	results         structs.TxnResults
+	enforceVersion8 bool
 }
 
 func (t *txnResultsFilter) Len() int {
@@ -61,9 +62,21 @@ func (t *txnResultsFilter) Len() int {
 
 func (t *txnResultsFilter) Filter(i int) bool {
 	result := t.results[i]
-	if result.KV != nil {
+	switch {
+	case result.KV != nil:
 		return !t.acl.KeyRead(result.KV.Key)
-	} else {
+	case result.Node != nil:
+		return !t.acl.NodeRead(result.Node.Node)
+	case result.Service != nil:
+		return !t.acl.ServiceRead(result.Service.Service)
+	case result.Check != nil:
+		if result.Check.ServiceID != "" {
+			return !t.acl.ServiceRead(result.Check.ServiceName)
+		}
+		return !t.acl.NodeRead(result.Check.Node)
+	case result.Session != nil:
+		return t.enforceVersion8 && !t.acl.SessionRead(result.Session.Node)
+	default:
 		return false
 	}
 }
@@ -73,9 +86,10 @@ func (t *txnResultsFilter) Move(dst, src, span int) {
 }
 
 // FilterTxnResults is used to filter a list of transaction results by
-// applying an ACL policy.
-func FilterTxnResults(acl acl.ACL, results structs.TxnResults) structs.TxnResults {
-	rf := txnResultsFilter{acl: acl, results: results}
+// applying an ACL policy. Sessions are only filtered if the version 8 ACL
+// rules are being enforced.
+func FilterTxnResults(acl acl.ACL, results structs.TxnResults, enforceVersion8 bool) structs.TxnResults {
+	rf := txnResultsFilter{acl: acl, results: results, enforceVersion8: enforceVersion8}
 	return results[:FilterEntries(&rf)]
 }
 
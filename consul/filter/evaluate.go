@@ -0,0 +1,304 @@
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// evaluator reports whether a single element satisfies a compiled expression.
+type evaluator func(v reflect.Value) bool
+
+// stepKind is the type of a single step taken when resolving a selector.
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepKey
+	stepEach
+)
+
+// step is a single compiled part of a selector. Fields are resolved to their
+// index ahead of time and map keys are converted to the map's key type, so
+// evaluation doesn't need to do any lookups by name.
+type step struct {
+	kind  stepKind
+	index []int
+	key   reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// indirectType strips any pointers from the given type.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// indirect follows pointers and interfaces, returning an invalid value if a
+// nil is encountered along the way.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// compile turns a parsed expression into an evaluator for the given type.
+func compile(n node, t reflect.Type) (evaluator, error) {
+	switch n := n.(type) {
+	case *andNode:
+		left, err := compile(n.left, t)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compile(n.right, t)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) bool { return left(v) && right(v) }, nil
+
+	case *orNode:
+		left, err := compile(n.left, t)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compile(n.right, t)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) bool { return left(v) || right(v) }, nil
+
+	case *notNode:
+		expr, err := compile(n.expr, t)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) bool { return !expr(v) }, nil
+
+	case *matchNode:
+		return compileMatch(n, t)
+
+	default:
+		return nil, fmt.Errorf("unhandled expression type %T", n)
+	}
+}
+
+// compileSelector resolves the parts of a selector against the given type,
+// returning the steps needed to reach the selected values and their type.
+func compileSelector(parts []string, t reflect.Type) ([]step, reflect.Type, error) {
+	var steps []step
+	for i := 0; i < len(parts); {
+		t = indirectType(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			name := parts[i]
+			field, ok := t.FieldByNameFunc(func(n string) bool {
+				return strings.EqualFold(n, name)
+			})
+			if !ok || field.PkgPath != "" {
+				return nil, nil, fmt.Errorf("%q is not a valid field of %s", name, t.Name())
+			}
+			steps = append(steps, step{kind: stepField, index: field.Index})
+			t = field.Type
+			i++
+
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return nil, nil, fmt.Errorf("can't select %q from a map with non-string keys", parts[i])
+			}
+			key := reflect.ValueOf(parts[i]).Convert(t.Key())
+			steps = append(steps, step{kind: stepKey, key: key})
+			t = t.Elem()
+			i++
+
+		case reflect.Slice, reflect.Array:
+			// Lists are traversed without consuming a part of the
+			// selector, so the rest applies to every element.
+			steps = append(steps, step{kind: stepEach})
+			t = t.Elem()
+
+		default:
+			return nil, nil, fmt.Errorf("can't select %q from a %s value", parts[i], t.Kind())
+		}
+	}
+	return steps, indirectType(t), nil
+}
+
+// collect gathers all the values reached by following the given steps from
+// v. Nil pointers along the way produce no values, and missing map keys
+// produce the zero value of the map's element type.
+func collect(v reflect.Value, steps []step, out []reflect.Value) []reflect.Value {
+	v = indirect(v)
+	if !v.IsValid() {
+		return out
+	}
+	if len(steps) == 0 {
+		return append(out, v)
+	}
+
+	s := steps[0]
+	switch s.kind {
+	case stepField:
+		for _, i := range s.index {
+			v = indirect(v)
+			if !v.IsValid() {
+				return out
+			}
+			v = v.Field(i)
+		}
+		return collect(v, steps[1:], out)
+
+	case stepKey:
+		elem := v.MapIndex(s.key)
+		if !elem.IsValid() {
+			elem = reflect.Zero(v.Type().Elem())
+		}
+		return collect(elem, steps[1:], out)
+
+	case stepEach:
+		for i := 0; i < v.Len(); i++ {
+			out = collect(v.Index(i), steps[1:], out)
+		}
+	}
+	return out
+}
+
+// compileMatch builds an evaluator for a single match, making sure the
+// operator and value make sense for the selected type.
+func compileMatch(m *matchNode, t reflect.Type) (evaluator, error) {
+	selector := strings.Join(m.selector, ".")
+	steps, leaf, err := compileSelector(m.selector, t)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %v", selector, err)
+	}
+
+	var test func(v reflect.Value) bool
+	switch m.op {
+	case opEqual, opNotEqual:
+		test, err = equalTo(leaf, m.value)
+		if err != nil {
+			return nil, fmt.Errorf("can't use %q with selector %q: %v", m.op, selector, err)
+		}
+
+	case opContains, opNotContains:
+		test, err = contains(leaf, m.value)
+		if err != nil {
+			return nil, fmt.Errorf("can't use %q with selector %q: %v", m.op, selector, err)
+		}
+
+	case opIsEmpty, opIsNotEmpty:
+		test = isNotEmpty
+
+	default:
+		return nil, fmt.Errorf("unhandled operator %q", m.op)
+	}
+
+	// The positive operators match if any of the selected values pass,
+	// and the negated ones match only if none of them do.
+	negate := m.op == opNotEqual || m.op == opNotContains || m.op == opIsEmpty
+	return func(v reflect.Value) bool {
+		for _, value := range collect(v, steps, nil) {
+			if test(value) {
+				return !negate
+			}
+		}
+		return negate
+	}, nil
+}
+
+// equalTo returns a test for equality with the given value, which is parsed
+// according to the given type.
+func equalTo(t reflect.Type, value string) (func(v reflect.Value) bool, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) bool { return v.String() == value }, nil
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return func(v reflect.Value) bool { return v.Bool() == b }, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if t == durationType {
+			if d, err := time.ParseDuration(value); err == nil {
+				return func(v reflect.Value) bool { return v.Int() == int64(d) }, nil
+			}
+		}
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		return func(v reflect.Value) bool { return v.Int() == i }, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an unsigned integer", value)
+		}
+		return func(v reflect.Value) bool { return v.Uint() == u }, nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return func(v reflect.Value) bool { return v.Float() == f }, nil
+
+	default:
+		return nil, fmt.Errorf("values of kind %s can't be compared", t.Kind())
+	}
+}
+
+// contains returns a test for a substring of a string, an element of a list
+// or a key of a map.
+func contains(t reflect.Type, value string) (func(v reflect.Value) bool, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) bool { return strings.Contains(v.String(), value) }, nil
+
+	case reflect.Slice, reflect.Array:
+		eq, err := equalTo(indirectType(t.Elem()), value)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) bool {
+			for i := 0; i < v.Len(); i++ {
+				if elem := indirect(v.Index(i)); elem.IsValid() && eq(elem) {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map keys aren't strings")
+		}
+		key := reflect.ValueOf(value).Convert(t.Key())
+		return func(v reflect.Value) bool { return v.MapIndex(key).IsValid() }, nil
+
+	default:
+		return nil, fmt.Errorf("values of kind %s have no contents", t.Kind())
+	}
+}
+
+// isNotEmpty returns true if the given value has a non-zero length, or isn't
+// the zero value for types without a length.
+func isNotEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() > 0
+	default:
+		return !reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+	}
+}
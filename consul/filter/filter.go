@@ -0,0 +1,129 @@
+// Package filter implements a small boolean expression language that is used
+// to filter the results of list endpoints on the server, so clients don't have
+// to fetch a full result set just to throw most of it away.
+//
+// Expressions are made up of matches that are combined with "and", "or" and
+// "not", and grouped with parentheses:
+//
+//	ServiceTags contains "v2" and Node.Meta.rack == "b"
+//
+// Selectors are dotted paths into the data being filtered. Struct fields are
+// matched by name without regard to case, map values are addressed by key, and
+// slices are traversed so that a selector such as Checks.Status yields the
+// status of every check. A match is true if any of the selected values
+// satisfies it, and the negated operators are true only if none of them do.
+//
+// The supported matches are:
+//
+//	Selector == Value           Selector != Value
+//	Selector contains Value     Selector not contains Value
+//	Value in Selector           Value not in Selector
+//	Selector is empty           Selector is not empty
+//
+// Equality works on strings, numbers and booleans. Containment checks for a
+// substring of a string, an element of a list, or a key of a map.
+package filter
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Filter is a compiled expression that has been checked against the type of
+// data it will be applied to.
+type Filter struct {
+	expression string
+	elemType   reflect.Type
+	root       evaluator
+}
+
+// New parses the given expression and checks it against the given data type,
+// which should be an example value of the elements that will be filtered
+// (either a struct or a pointer to one). Any syntax errors or selectors that
+// don't apply to the data type are returned as errors.
+func New(expression string, dataType interface{}) (*Filter, error) {
+	tree, err := parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid filter expression: %v", err)
+	}
+
+	elemType := reflect.TypeOf(dataType)
+	if elemType == nil {
+		return nil, fmt.Errorf("Filter data type must not be nil")
+	}
+	root, err := compile(tree, indirectType(elemType))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid filter expression: %v", err)
+	}
+
+	return &Filter{
+		expression: expression,
+		elemType:   indirectType(elemType),
+		root:       root,
+	}, nil
+}
+
+// String returns the original expression.
+func (f *Filter) String() string {
+	return f.expression
+}
+
+// Match returns true if the given item satisfies the filter. The item must be
+// of the data type the filter was created with, or a pointer to it.
+func (f *Filter) Match(item interface{}) (bool, error) {
+	v := reflect.ValueOf(item)
+	if !v.IsValid() {
+		return false, fmt.Errorf("Filter can't be applied to nil")
+	}
+	if t := indirectType(v.Type()); t != f.elemType {
+		return false, fmt.Errorf("Filter expects %s but got %s", f.elemType, t)
+	}
+	return f.root(v), nil
+}
+
+// Execute applies the filter to the given slice or map and returns a new
+// value of the same type holding only the matching elements. A nil input is
+// returned as-is.
+func (f *Filter) Execute(data interface{}) (interface{}, error) {
+	v := reflect.ValueOf(data)
+	if !v.IsValid() {
+		return data, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		if t := indirectType(v.Type().Elem()); t != f.elemType {
+			return nil, fmt.Errorf("Filter expects %s but got %s", f.elemType, t)
+		}
+		if v.IsNil() {
+			return data, nil
+		}
+
+		out := reflect.MakeSlice(v.Type(), 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if elem := v.Index(i); f.root(elem) {
+				out = reflect.Append(out, elem)
+			}
+		}
+		return out.Interface(), nil
+
+	case reflect.Map:
+		if t := indirectType(v.Type().Elem()); t != f.elemType {
+			return nil, fmt.Errorf("Filter expects %s but got %s", f.elemType, t)
+		}
+		if v.IsNil() {
+			return data, nil
+		}
+
+		out := reflect.MakeMap(v.Type())
+		for _, key := range v.MapKeys() {
+			if elem := v.MapIndex(key); f.root(elem) {
+				out.SetMapIndex(key, elem)
+			}
+		}
+		return out.Interface(), nil
+
+	default:
+		return nil, fmt.Errorf("Filter can only be applied to slices and maps, not %s", v.Type())
+	}
+}
@@ -0,0 +1,192 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCheck struct {
+	Name    string
+	Status  string
+	Timeout time.Duration
+}
+
+type testNode struct {
+	Name    string
+	Port    int
+	Weight  float64
+	Count   uint
+	Enabled bool
+	Tags    []string
+	Meta    map[string]string
+	Checks  []*testCheck
+	Parent  *testNode
+	private string
+}
+
+func testNodes() []*testNode {
+	return []*testNode{
+		&testNode{
+			Name:    "foo",
+			Port:    8000,
+			Weight:  1.5,
+			Count:   3,
+			Enabled: true,
+			Tags:    []string{"v1", "primary"},
+			Meta:    map[string]string{"rack": "a"},
+			Checks: []*testCheck{
+				&testCheck{Name: "ping", Status: "passing", Timeout: 10 * time.Second},
+				&testCheck{Name: "disk", Status: "warning"},
+			},
+		},
+		&testNode{
+			Name: "bar",
+			Port: 8001,
+			Tags: []string{"v2"},
+			Meta: map[string]string{"rack": "b", "zone": "east"},
+			Checks: []*testCheck{
+				&testCheck{Name: "ping", Status: "critical"},
+			},
+			Parent: &testNode{Name: "foo"},
+		},
+		&testNode{
+			Name: "baz",
+		},
+	}
+}
+
+func TestFilter_Execute(t *testing.T) {
+	cases := map[string][]string{
+		`Name == "foo"`:                            []string{"foo"},
+		`name != "foo"`:                            []string{"bar", "baz"},
+		`Port == 8001`:                             []string{"bar"},
+		`Weight == 1.5`:                            []string{"foo"},
+		`Count == 3`:                               []string{"foo"},
+		`Enabled == true`:                          []string{"foo"},
+		`Name contains "a"`:                        []string{"bar", "baz"},
+		`Tags contains "v2"`:                       []string{"bar"},
+		`"v1" in Tags`:                             []string{"foo"},
+		`"v1" not in Tags`:                         []string{"bar", "baz"},
+		`Tags is empty`:                            []string{"baz"},
+		`Tags is not empty`:                        []string{"foo", "bar"},
+		`Meta.rack == "b"`:                         []string{"bar"},
+		`Meta.rack is empty`:                       []string{"baz"},
+		`Meta contains "zone"`:                     []string{"bar"},
+		`Checks.Status == "critical"`:              []string{"bar"},
+		`Checks.Status != "critical"`:              []string{"foo", "baz"},
+		`Checks.Name == "disk"`:                    []string{"foo"},
+		`Checks.Timeout == "10s"`:                  []string{"foo"},
+		`Parent.Name == "foo"`:                     []string{"bar"},
+		`Parent is empty`:                          []string{"foo", "baz"},
+		`Port == 8000 or Port == 8001`:             []string{"foo", "bar"},
+		`Tags contains "v2" and Meta.rack == "b"`:  []string{"bar"},
+		`Tags contains "v2" and Meta.rack == "a"`:  []string{},
+		`not (Name == "foo" or Name == "bar")`:     []string{"baz"},
+		`not Name == "foo" and Meta.rack is empty`: []string{"baz"},
+	}
+	for expr, expected := range cases {
+		f, err := New(expr, &testNode{})
+		if err != nil {
+			t.Fatalf("err: %q: %v", expr, err)
+		}
+
+		raw, err := f.Execute(testNodes())
+		if err != nil {
+			t.Fatalf("err: %q: %v", expr, err)
+		}
+		names := []string{}
+		for _, node := range raw.([]*testNode) {
+			names = append(names, node.Name)
+		}
+		if !reflect.DeepEqual(names, expected) {
+			t.Fatalf("bad: %q: %v", expr, names)
+		}
+	}
+}
+
+func TestFilter_Execute_Map(t *testing.T) {
+	f, err := New(`Tags contains "v2"`, testNode{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	nodes := make(map[string]*testNode)
+	for _, node := range testNodes() {
+		nodes[node.Name] = node
+	}
+	raw, err := f.Execute(nodes)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	filtered := raw.(map[string]*testNode)
+	if len(filtered) != 1 || filtered["bar"] == nil {
+		t.Fatalf("bad: %v", filtered)
+	}
+
+	// Values work as well as pointers, and nil is passed through.
+	raw, err = f.Execute([]testNode{*nodes["foo"], *nodes["bar"]})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if values := raw.([]testNode); len(values) != 1 || values[0].Name != "bar" {
+		t.Fatalf("bad: %v", values)
+	}
+	var empty []*testNode
+	if raw, err = f.Execute(empty); err != nil || raw.([]*testNode) != nil {
+		t.Fatalf("bad: %v %v", raw, err)
+	}
+}
+
+func TestFilter_Execute_BadData(t *testing.T) {
+	f, err := New(`Name == "foo"`, testNode{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := f.Execute([]*testCheck{}); err == nil {
+		t.Fatalf("should fail")
+	}
+	if _, err := f.Execute(testNode{}); err == nil {
+		t.Fatalf("should fail")
+	}
+	if _, err := f.Match(&testCheck{}); err == nil {
+		t.Fatalf("should fail")
+	}
+	if _, err := f.Match(nil); err == nil {
+		t.Fatalf("should fail")
+	}
+	if ok, err := f.Match(testNodes()[0]); err != nil || !ok {
+		t.Fatalf("bad: %v %v", ok, err)
+	}
+}
+
+func TestFilter_New_Errors(t *testing.T) {
+	cases := map[string]string{
+		`Name ==`:                  "expected a value",
+		`Nope == "foo"`:            `invalid selector "Nope"`,
+		`private == "foo"`:         `invalid selector "private"`,
+		`Name.Foo == "foo"`:        `can't select "Foo"`,
+		`Port == "abc"`:            "not an integer",
+		`Enabled == "yes"`:         "not a boolean",
+		`Count == -1`:              "not an unsigned integer",
+		`Weight == "heavy"`:        "not a number",
+		`Tags == "v1"`:             "can't be compared",
+		`Port contains "1"`:        "have no contents",
+		`Checks.Timeout == "fast"`: "not an integer",
+	}
+	for expr, expected := range cases {
+		_, err := New(expr, testNode{})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("bad: %q: %v", expr, err)
+		}
+		if !strings.HasPrefix(err.Error(), "Invalid filter expression") {
+			t.Fatalf("bad: %q: %v", expr, err)
+		}
+	}
+
+	if _, err := New(`Name == "foo"`, nil); err == nil {
+		t.Fatalf("should fail")
+	}
+}
@@ -0,0 +1,444 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenType identifies the kind of a lexed token.
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenWord
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenEqual
+	tokenNotEqual
+)
+
+// token is a single lexical element of an expression, along with the
+// character position where it started so errors can point at the problem.
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.val)
+	default:
+		return fmt.Sprintf("%q", t.val)
+	}
+}
+
+// isWordChar returns true for characters allowed in bare words, which are
+// used for keywords, selectors and unquoted numbers and booleans.
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._-/:", r)
+}
+
+// lex breaks the given expression into a list of tokens, always terminated
+// by an EOF token.
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+
+		case r == '=' || r == '!':
+			if i+1 >= len(runes) || runes[i+1] != '=' {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+			if r == '=' {
+				tokens = append(tokens, token{tokenEqual, "==", i})
+			} else {
+				tokens = append(tokens, token{tokenNotEqual, "!=", i})
+			}
+			i += 2
+
+		case r == '"':
+			start := i
+			var val []rune
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string starting at position %d", start)
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					val = append(val, runes[i])
+					continue
+				}
+				if runes[i] == '"' {
+					i++
+					break
+				}
+				val = append(val, runes[i])
+			}
+			tokens = append(tokens, token{tokenString, string(val), start})
+
+		case isWordChar(r):
+			start := i
+			for i < len(runes) && isWordChar(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[start:i]), start})
+
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+	tokens = append(tokens, token{tokenEOF, "", len(runes)})
+	return tokens, nil
+}
+
+// operator is a comparison that can be applied to a selector.
+type operator int
+
+const (
+	opEqual operator = iota
+	opNotEqual
+	opContains
+	opNotContains
+	opIsEmpty
+	opIsNotEmpty
+)
+
+func (o operator) String() string {
+	switch o {
+	case opEqual:
+		return "=="
+	case opNotEqual:
+		return "!="
+	case opContains:
+		return "contains"
+	case opNotContains:
+		return "not contains"
+	case opIsEmpty:
+		return "is empty"
+	case opIsNotEmpty:
+		return "is not empty"
+	default:
+		return "unknown"
+	}
+}
+
+// node is an element of the parsed expression tree.
+type node interface{}
+
+// andNode is true if both sides are true.
+type andNode struct {
+	left, right node
+}
+
+// orNode is true if either side is true.
+type orNode struct {
+	left, right node
+}
+
+// notNode inverts the result of the wrapped expression.
+type notNode struct {
+	expr node
+}
+
+// matchNode applies an operator to the values found by a selector. The value
+// is empty for the unary "is empty" operators.
+type matchNode struct {
+	selector []string
+	op       operator
+	value    string
+}
+
+// keywords can't be used as selectors or bare values.
+var keywords = map[string]bool{
+	"and":      true,
+	"or":       true,
+	"not":      true,
+	"in":       true,
+	"contains": true,
+	"is":       true,
+	"empty":    true,
+}
+
+// parser is a simple recursive descent parser for the grammar below:
+//
+//	Expression := Or
+//	Or         := And { "or" And }
+//	And        := Not { "and" Not }
+//	Not        := "not" Not | "(" Or ")" | Match
+//	Match      := Selector ( "==" | "!=" ) Value
+//	            | Selector [ "not" ] "contains" Value
+//	            | Selector "is" [ "not" ] "empty"
+//	            | Value [ "not" ] "in" Selector
+//
+// Values are double-quoted strings, or bare numbers and booleans.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse turns the given expression into a tree.
+func parse(expr string) (node, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// peekKeyword returns true if the next token is the given keyword.
+func (p *parser) peekKeyword(keyword string) bool {
+	t := p.peek()
+	return t.typ == tokenWord && t.val == keyword
+}
+
+// expectKeyword consumes the given keyword or returns an error.
+func (p *parser) expectKeyword(keyword string) error {
+	if t := p.next(); t.typ != tokenWord || t.val != keyword {
+		return fmt.Errorf("expected %q but found %s at position %d", keyword, t, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	return fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peekKeyword("not") {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{expr}, nil
+	}
+
+	if p.peek().typ == tokenLParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.typ != tokenRParen {
+			return nil, fmt.Errorf("expected \")\" but found %s at position %d", t, t.pos)
+		}
+		return expr, nil
+	}
+
+	return p.parseMatch()
+}
+
+func (p *parser) parseMatch() (node, error) {
+	first := p.next()
+	switch first.typ {
+	case tokenString:
+		// This can only be the start of an "in" match.
+		return p.parseIn(first.val)
+
+	case tokenWord:
+		if keywords[first.val] {
+			return nil, p.unexpected(first)
+		}
+
+	default:
+		return nil, p.unexpected(first)
+	}
+
+	// A bare number or boolean followed by "in" is a value.
+	if p.peekKeyword("in") || (p.peekKeyword("not") && p.tokens[p.pos+1].val == "in") {
+		if _, ok := bareValue(first.val); ok {
+			return p.parseIn(first.val)
+		}
+	}
+
+	selector, err := parseSelector(first)
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.next()
+	switch {
+	case t.typ == tokenEqual || t.typ == tokenNotEqual:
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		op := opEqual
+		if t.typ == tokenNotEqual {
+			op = opNotEqual
+		}
+		return &matchNode{selector, op, value}, nil
+
+	case t.typ == tokenWord && t.val == "contains":
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return &matchNode{selector, opContains, value}, nil
+
+	case t.typ == tokenWord && t.val == "not":
+		if err := p.expectKeyword("contains"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return &matchNode{selector, opNotContains, value}, nil
+
+	case t.typ == tokenWord && t.val == "is":
+		op := opIsEmpty
+		if p.peekKeyword("not") {
+			p.next()
+			op = opIsNotEmpty
+		}
+		if err := p.expectKeyword("empty"); err != nil {
+			return nil, err
+		}
+		return &matchNode{selector, op, ""}, nil
+
+	default:
+		return nil, fmt.Errorf("expected an operator after selector %q but found %s at position %d",
+			first.val, t, t.pos)
+	}
+}
+
+// parseIn handles the remainder of a "Value [not] in Selector" match.
+func (p *parser) parseIn(value string) (node, error) {
+	op := opContains
+	if p.peekKeyword("not") {
+		p.next()
+		op = opNotContains
+	}
+	if err := p.expectKeyword("in"); err != nil {
+		return nil, err
+	}
+
+	t := p.next()
+	if t.typ != tokenWord || keywords[t.val] {
+		return nil, fmt.Errorf("expected a selector but found %s at position %d", t, t.pos)
+	}
+	selector, err := parseSelector(t)
+	if err != nil {
+		return nil, err
+	}
+	return &matchNode{selector, op, value}, nil
+}
+
+// parseValue consumes a quoted string or a bare number or boolean.
+func (p *parser) parseValue() (string, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenString:
+		return t.val, nil
+
+	case tokenWord:
+		if value, ok := bareValue(t.val); ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("expected a value but found %s at position %d (strings must be quoted)", t, t.pos)
+
+	default:
+		return "", fmt.Errorf("expected a value but found %s at position %d", t, t.pos)
+	}
+}
+
+// bareValue returns the given word if it can be used as an unquoted value.
+func bareValue(word string) (string, bool) {
+	if word == "true" || word == "false" {
+		return word, true
+	}
+	if _, err := strconv.ParseFloat(word, 64); err == nil {
+		return word, true
+	}
+	return "", false
+}
+
+// parseSelector splits a dotted selector into its parts.
+func parseSelector(t token) ([]string, error) {
+	parts := strings.Split(t.val, ".")
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("invalid selector %q at position %d", t.val, t.pos)
+		}
+	}
+	if !unicode.IsLetter([]rune(parts[0])[0]) {
+		return nil, fmt.Errorf("invalid selector %q at position %d", t.val, t.pos)
+	}
+	return parts, nil
+}
@@ -0,0 +1,140 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilter_lex(t *testing.T) {
+	tokens, err := lex(`(Node.Meta.rack == "b\"c") != foo`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := []token{
+		{tokenLParen, "(", 0},
+		{tokenWord, "Node.Meta.rack", 1},
+		{tokenEqual, "==", 16},
+		{tokenString, `b"c`, 19},
+		{tokenRParen, ")", 25},
+		{tokenNotEqual, "!=", 27},
+		{tokenWord, "foo", 30},
+		{tokenEOF, "", 33},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("bad: %#v", tokens)
+	}
+
+	bad := []string{
+		`foo = "bar"`,
+		`foo ! "bar"`,
+		`foo == "bar`,
+		`foo == 'bar'`,
+	}
+	for _, expr := range bad {
+		if _, err := lex(expr); err == nil {
+			t.Fatalf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestFilter_parse(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected node
+	}{
+		{
+			`Node == "foo"`,
+			&matchNode{[]string{"Node"}, opEqual, "foo"},
+		},
+		{
+			`Port != 8000`,
+			&matchNode{[]string{"Port"}, opNotEqual, "8000"},
+		},
+		{
+			`ServiceTags contains "v2"`,
+			&matchNode{[]string{"ServiceTags"}, opContains, "v2"},
+		},
+		{
+			`ServiceTags not contains "v2"`,
+			&matchNode{[]string{"ServiceTags"}, opNotContains, "v2"},
+		},
+		{
+			`"v2" in ServiceTags`,
+			&matchNode{[]string{"ServiceTags"}, opContains, "v2"},
+		},
+		{
+			`"v2" not in ServiceTags`,
+			&matchNode{[]string{"ServiceTags"}, opNotContains, "v2"},
+		},
+		{
+			`8000 in Ports`,
+			&matchNode{[]string{"Ports"}, opContains, "8000"},
+		},
+		{
+			`Node.Meta.rack is empty`,
+			&matchNode{[]string{"Node", "Meta", "rack"}, opIsEmpty, ""},
+		},
+		{
+			`Node.Meta.rack is not empty`,
+			&matchNode{[]string{"Node", "Meta", "rack"}, opIsNotEmpty, ""},
+		},
+		{
+			`a == "1" or b == "2" and c == "3"`,
+			&orNode{
+				&matchNode{[]string{"a"}, opEqual, "1"},
+				&andNode{
+					&matchNode{[]string{"b"}, opEqual, "2"},
+					&matchNode{[]string{"c"}, opEqual, "3"},
+				},
+			},
+		},
+		{
+			`(a == "1" or b == "2") and not c == "3"`,
+			&andNode{
+				&orNode{
+					&matchNode{[]string{"a"}, opEqual, "1"},
+					&matchNode{[]string{"b"}, opEqual, "2"},
+				},
+				&notNode{&matchNode{[]string{"c"}, opEqual, "3"}},
+			},
+		},
+	}
+	for _, c := range cases {
+		tree, err := parse(c.expr)
+		if err != nil {
+			t.Fatalf("err: %q: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(tree, c.expected) {
+			t.Fatalf("bad: %q: %#v", c.expr, tree)
+		}
+	}
+}
+
+func TestFilter_parse_Errors(t *testing.T) {
+	cases := map[string]string{
+		``:                      "empty expression",
+		`   `:                   "empty expression",
+		`Node`:                  "expected an operator",
+		`Node == `:              "expected a value",
+		`Node == foo`:           "strings must be quoted",
+		`Node == "foo" and`:     "unexpected end of expression",
+		`Node == "foo" "bar"`:   "unexpected \"bar\"",
+		`(Node == "foo"`:        "expected \")\"",
+		`Node is "foo"`:         "expected \"empty\"",
+		`Node not == "foo"`:     "expected \"contains\"",
+		`"foo" in`:              "expected a selector",
+		`"foo" == Node`:         "expected \"in\"",
+		`and == "foo"`:          "unexpected \"and\"",
+		`Node..Meta == "foo"`:   "invalid selector",
+		`1Node == "foo"`:        "invalid selector",
+		`Node == "foo" or or x`: "unexpected \"or\"",
+	}
+	for expr, expected := range cases {
+		_, err := parse(expr)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("bad: %q: %v", expr, err)
+		}
+	}
+}
@@ -109,7 +109,7 @@ func TestFilter_TxnResults(t *testing.T) {
 			results = append(results, &structs.TxnResult{KV: &structs.DirEntry{Key: in}})
 		}
 
-		results = FilterTxnResults(aclR, results)
+		results = FilterTxnResults(aclR, results, false)
 		var outL []string
 		for _, r := range results {
 			outL = append(outL, r.KV.Key)
@@ -123,10 +123,39 @@ func TestFilter_TxnResults(t *testing.T) {
 	// Run a non-KV result.
 	results := structs.TxnResults{}
 	results = append(results, &structs.TxnResult{})
-	results = FilterTxnResults(aclR, results)
+	results = FilterTxnResults(aclR, results, false)
 	if len(results) != 1 {
 		t.Fatalf("should not have filtered non-KV result")
 	}
+
+	// Run catalog results, which are filtered by node and service.
+	results = structs.TxnResults{
+		&structs.TxnResult{Node: &structs.Node{Node: "foo"}},
+		&structs.TxnResult{Service: &structs.NodeService{Service: "foo"}},
+		&structs.TxnResult{Check: &structs.HealthCheck{Node: "foo"}},
+		&structs.TxnResult{Check: &structs.HealthCheck{Node: "foo", ServiceID: "bar", ServiceName: "bar"}},
+		&structs.TxnResult{Session: &structs.Session{Node: "foo"}},
+	}
+	policy, _ = acl.Parse(`service "bar" { policy = "read" }`)
+	aclR, _ = acl.New(acl.DenyAll(), policy)
+	results = FilterTxnResults(aclR, results, false)
+	if len(results) != 2 || results[0].Check == nil || results[1].Session == nil {
+		t.Fatalf("bad: %v", results)
+	}
+
+	// Sessions get filtered once the version 8 rules are enforced.
+	results = FilterTxnResults(aclR, results, true)
+	if len(results) != 1 || results[0].Check == nil {
+		t.Fatalf("bad: %v", results)
+	}
+	policy, _ = acl.Parse(`session "foo" { policy = "read" }`)
+	aclR, _ = acl.New(acl.DenyAll(), policy)
+	results = FilterTxnResults(aclR, structs.TxnResults{
+		&structs.TxnResult{Session: &structs.Session{Node: "foo"}},
+	}, true)
+	if len(results) != 1 || results[0].Session == nil {
+		t.Fatalf("bad: %v", results)
+	}
 }
 
 var testFilterRules = `
@@ -26,6 +26,7 @@ type consulFSM struct {
 	path      string
 	state     *state.StateStore
 	gc        *state.TombstoneGC
+	publisher *eventPublisher
 }
 
 // consulSnapshot is used to provide a snapshot of the current
@@ -54,6 +55,7 @@ func NewFSM(gc *state.TombstoneGC, logOutput io.Writer) (*consulFSM, error) {
 		logger:    log.New(logOutput, "", log.LstdFlags),
 		state:     stateNew,
 		gc:        gc,
+		publisher: newEventPublisher(eventBufferSize),
 	}
 	return fsm, nil
 }
@@ -63,6 +65,12 @@ func (c *consulFSM) State() *state.StateStore {
 	return c.state
 }
 
+// Publisher is used to return a handle to the events generated by changes
+// to the state.
+func (c *consulFSM) Publisher() *eventPublisher {
+	return c.publisher
+}
+
 func (c *consulFSM) Apply(log *raft.Log) interface{} {
 	buf := log.Data
 	msgType := structs.MessageType(buf[0])
@@ -95,6 +103,12 @@ func (c *consulFSM) Apply(log *raft.Log) interface{} {
 		return c.applyPreparedQueryOperation(buf[1:], log.Index)
 	case structs.TxnRequestType:
 		return c.applyTxn(buf[1:], log.Index)
+	case structs.KVSHistoryRequestType:
+		return c.applyKVSHistoryOperation(buf[1:], log.Index)
+	case structs.ACLPolicyEntryRequestType:
+		return c.applyACLPolicyOperation(buf[1:], log.Index)
+	case structs.ACLReapRequestType:
+		return c.applyACLReap(buf[1:], log.Index)
 	default:
 		if ignoreUnknown {
 			c.logger.Printf("[WARN] consul.fsm: ignoring unknown message type (%d), upgrade to newer version", msgType)
@@ -113,7 +127,10 @@ func (c *consulFSM) applyRegister(buf []byte, index uint64) interface{} {
 	}
 
 	// Apply all updates in a single transaction
-	if err := c.state.EnsureRegistration(index, &req); err != nil {
+	err := c.publishServiceHealth(index, []string{req.Node}, func() error {
+		return c.state.EnsureRegistration(index, &req)
+	})
+	if err != nil {
 		c.logger.Printf("[INFO] consul.fsm: EnsureRegistration failed: %v", err)
 		return err
 	}
@@ -131,17 +148,26 @@ func (c *consulFSM) applyDeregister(buf []byte, index uint64) interface{} {
 	// here is also baked into vetDeregisterWithACL() in acl.go, so if you
 	// make changes here, be sure to also adjust the code over there.
 	if req.ServiceID != "" {
-		if err := c.state.DeleteService(index, req.Node, req.ServiceID); err != nil {
+		err := c.publishServiceHealth(index, []string{req.Node}, func() error {
+			return c.state.DeleteService(index, req.Node, req.ServiceID)
+		})
+		if err != nil {
 			c.logger.Printf("[INFO] consul.fsm: DeleteNodeService failed: %v", err)
 			return err
 		}
 	} else if req.CheckID != "" {
-		if err := c.state.DeleteCheck(index, req.Node, req.CheckID); err != nil {
+		err := c.publishServiceHealth(index, []string{req.Node}, func() error {
+			return c.state.DeleteCheck(index, req.Node, req.CheckID)
+		})
+		if err != nil {
 			c.logger.Printf("[INFO] consul.fsm: DeleteNodeCheck failed: %v", err)
 			return err
 		}
 	} else {
-		if err := c.state.DeleteNode(index, req.Node); err != nil {
+		err := c.publishServiceHealth(index, []string{req.Node}, func() error {
+			return c.state.DeleteNode(index, req.Node)
+		})
+		if err != nil {
 			c.logger.Printf("[INFO] consul.fsm: DeleteNode failed: %v", err)
 			return err
 		}
@@ -149,6 +175,45 @@ func (c *consulFSM) applyDeregister(buf []byte, index uint64) interface{} {
 	return nil
 }
 
+// publishServiceHealth runs the given update to the state store and publishes
+// events for any changes it made to the services on the given nodes. The
+// events never affect the outcome of the update; if we can't work out what
+// changed then subscribers are forced to start over with a snapshot.
+func (c *consulFSM) publishServiceHealth(index uint64, nodes []string, update func() error) error {
+	var lookupErr error
+	var failed string
+	before := make([]structs.CheckServiceNodes, len(nodes))
+	for i, node := range nodes {
+		if _, before[i], lookupErr = c.state.NodeCheckServiceNodes(node); lookupErr != nil {
+			failed = node
+			break
+		}
+	}
+	if err := update(); err != nil {
+		return err
+	}
+
+	var events []*structs.StreamEvent
+	if lookupErr == nil {
+		for i, node := range nodes {
+			var after structs.CheckServiceNodes
+			if _, after, lookupErr = c.state.NodeCheckServiceNodes(node); lookupErr != nil {
+				failed = node
+				break
+			}
+			events = append(events, serviceHealthEvents(index, before[i], after)...)
+		}
+	}
+	if lookupErr != nil {
+		c.logger.Printf("[WARN] consul.fsm: Failed to look up services for node %q, resetting event streams: %v",
+			failed, lookupErr)
+		c.publisher.Reset(index)
+		return nil
+	}
+	c.publisher.Publish(events)
+	return nil
+}
+
 func (c *consulFSM) applyKVSOperation(buf []byte, index uint64) interface{} {
 	var req structs.KVSRequest
 	if err := structs.Decode(buf, &req); err != nil {
@@ -239,6 +304,38 @@ func (c *consulFSM) applyACLOperation(buf []byte, index uint64) interface{} {
 	}
 }
 
+func (c *consulFSM) applyACLPolicyOperation(buf []byte, index uint64) interface{} {
+	var req structs.ACLPolicyEntryRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+	defer metrics.MeasureSince([]string{"consul", "fsm", "acl_policy", string(req.Op)}, time.Now())
+	switch req.Op {
+	case structs.ACLSet:
+		if err := c.state.ACLPolicySet(index, &req.Policy); err != nil {
+			return err
+		}
+		return req.Policy.ID
+	case structs.ACLDelete:
+		return c.state.ACLPolicyDelete(index, req.Policy.ID)
+	default:
+		c.logger.Printf("[WARN] consul.fsm: Invalid ACL policy operation '%s'", req.Op)
+		return fmt.Errorf("Invalid ACL policy operation '%s'", req.Op)
+	}
+}
+
+// applyACLReap deletes a batch of expired ACLs. Like the coordinate batch
+// update, this has a single purpose so it doesn't follow the opcode
+// convention.
+func (c *consulFSM) applyACLReap(buf []byte, index uint64) interface{} {
+	var req structs.ACLReapRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+	defer metrics.MeasureSince([]string{"consul", "fsm", "acl", "reap"}, time.Now())
+	return c.state.ACLReap(index, req.ACLs, req.Time)
+}
+
 func (c *consulFSM) applyTombstoneOperation(buf []byte, index uint64) interface{} {
 	var req structs.TombstoneRequest
 	if err := structs.Decode(buf, &req); err != nil {
@@ -296,10 +393,65 @@ func (c *consulFSM) applyTxn(buf []byte, index uint64) interface{} {
 		panic(fmt.Errorf("failed to decode request: %v", err))
 	}
 	defer metrics.MeasureSince([]string{"consul", "fsm", "txn"}, time.Now())
-	results, errors := c.state.TxnRW(index, req.Ops)
+
+	// A failed transaction doesn't change anything, so it won't make any
+	// events either.
+	var results structs.TxnResults
+	var errors structs.TxnErrors
+	c.publishServiceHealth(index, txnNodes(req.Ops), func() error {
+		results, errors = c.state.TxnRW(index, req.Ops)
+		return nil
+	})
 	return structs.TxnResponse{results, errors}
 }
 
+// txnNodes returns the nodes touched by the catalog operations in the given
+// transaction, without duplicates.
+func txnNodes(ops structs.TxnOps) []string {
+	var nodes []string
+	seen := make(map[string]bool)
+	for _, op := range ops {
+		var node string
+		switch {
+		case op.Node != nil:
+			node = op.Node.Node.Node
+		case op.Service != nil:
+			node = op.Service.Node
+		case op.Check != nil:
+			node = op.Check.Check.Node
+		default:
+			continue
+		}
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// applyKVSHistoryOperation applies the given KVS history operation to the
+// state store.
+func (c *consulFSM) applyKVSHistoryOperation(buf []byte, index uint64) interface{} {
+	var req structs.KVSHistoryRequest
+	if err := structs.Decode(buf, &req); err != nil {
+		panic(fmt.Errorf("failed to decode request: %v", err))
+	}
+
+	defer metrics.MeasureSince([]string{"consul", "fsm", "kvs-history", string(req.Op)}, time.Now())
+	switch req.Op {
+	case structs.KVSHistorySetRules:
+		return c.state.KVSHistorySetRules(index, req.Rules)
+	case structs.KVSHistoryPrune:
+		return c.state.KVSHistoryPrune(index, req.Prefix, req.Index)
+	case structs.KVSHistoryPruneUncovered:
+		return c.state.KVSHistoryPruneUncovered(index, req.Index)
+	default:
+		c.logger.Printf("[WARN] consul.fsm: Invalid KVS history operation '%s'", req.Op)
+		return fmt.Errorf("Invalid KVS history operation '%s'", req.Op)
+	}
+}
+
 func (c *consulFSM) Snapshot() (raft.FSMSnapshot, error) {
 	defer func(start time.Time) {
 		c.logger.Printf("[INFO] consul.fsm: snapshot created in %v", time.Now().Sub(start))
@@ -316,6 +468,10 @@ func (c *consulFSM) Restore(old io.ReadCloser) error {
 	if err != nil {
 		return err
 	}
+	prefixes, err := c.state.KVSUsagePrefixes()
+	if err != nil {
+		return err
+	}
 	c.state = stateNew
 
 	// Set up a new restore transaction
@@ -397,6 +553,15 @@ func (c *consulFSM) Restore(old io.ReadCloser) error {
 				return err
 			}
 
+		case structs.ACLPolicyEntryRequestType:
+			var req structs.ACLPolicyEntry
+			if err := dec.Decode(&req); err != nil {
+				return err
+			}
+			if err := restore.ACLPolicy(&req); err != nil {
+				return err
+			}
+
 		case structs.CoordinateBatchUpdateType:
 			var req structs.Coordinates
 			if err := dec.Decode(&req); err != nil {
@@ -416,12 +581,48 @@ func (c *consulFSM) Restore(old io.ReadCloser) error {
 				return err
 			}
 
+		case structs.KVSHistoryRequestType:
+			var req structs.KVSHistoryRule
+			if err := dec.Decode(&req); err != nil {
+				return err
+			}
+			if err := restore.KVSHistoryRule(&req); err != nil {
+				return err
+			}
+
+		case structs.KVSHistoryRulesIndexType:
+			var idx uint64
+			if err := dec.Decode(&idx); err != nil {
+				return err
+			}
+			if err := restore.KVSHistoryRulesIndex(idx); err != nil {
+				return err
+			}
+
+		case structs.KVSHistoryEntryType:
+			var req structs.KVSHistoryEntry
+			if err := dec.Decode(&req); err != nil {
+				return err
+			}
+			if err := restore.KVSHistory(&req); err != nil {
+				return err
+			}
+
 		default:
 			return fmt.Errorf("Unrecognized msg type: %v", msgType)
 		}
 	}
 
 	restore.Commit()
+
+	// Keep counting the usage of the same KV prefixes as before.
+	if err := c.state.KVSTrackUsage(prefixes); err != nil {
+		return err
+	}
+
+	// Any buffered events are meaningless against the new state, so make
+	// subscribers start over.
+	c.publisher.Reset(header.LastIndex)
 	return nil
 }
 
@@ -455,6 +656,11 @@ func (s *consulSnapshot) Persist(sink raft.SnapshotSink) error {
 		return err
 	}
 
+	if err := s.persistACLPolicies(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
+
 	if err := s.persistKVs(sink, encoder); err != nil {
 		sink.Cancel()
 		return err
@@ -470,6 +676,11 @@ func (s *consulSnapshot) Persist(sink raft.SnapshotSink) error {
 		return err
 	}
 
+	if err := s.persistKVSHistory(sink, encoder); err != nil {
+		sink.Cancel()
+		return err
+	}
+
 	return nil
 }
 
@@ -486,9 +697,11 @@ func (s *consulSnapshot) persistNodes(sink raft.SnapshotSink,
 	for node := nodes.Next(); node != nil; node = nodes.Next() {
 		n := node.(*structs.Node)
 		req := structs.RegisterRequest{
+			ID:              n.ID,
 			Node:            n.Node,
 			Address:         n.Address,
 			TaggedAddresses: n.TaggedAddresses,
+			NodeMeta:        n.Meta,
 		}
 
 		// Register the node itself
@@ -574,6 +787,22 @@ func (s *consulSnapshot) persistACLs(sink raft.SnapshotSink,
 	return nil
 }
 
+func (s *consulSnapshot) persistACLPolicies(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	policies, err := s.state.ACLPolicies()
+	if err != nil {
+		return err
+	}
+
+	for policy := policies.Next(); policy != nil; policy = policies.Next() {
+		sink.Write([]byte{byte(structs.ACLPolicyEntryRequestType)})
+		if err := encoder.Encode(policy.(*structs.ACLPolicyEntry)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *consulSnapshot) persistKVs(sink raft.SnapshotSink,
 	encoder *codec.Encoder) error {
 	entries, err := s.state.KVs()
@@ -633,6 +862,41 @@ func (s *consulSnapshot) persistPreparedQueries(sink raft.SnapshotSink,
 	return nil
 }
 
+func (s *consulSnapshot) persistKVSHistory(sink raft.SnapshotSink,
+	encoder *codec.Encoder) error {
+	if idx := s.state.KVSHistoryRulesIndex(); idx != 0 {
+		sink.Write([]byte{byte(structs.KVSHistoryRulesIndexType)})
+		if err := encoder.Encode(idx); err != nil {
+			return err
+		}
+	}
+
+	rules, err := s.state.KVSHistoryRules()
+	if err != nil {
+		return err
+	}
+
+	for rule := rules.Next(); rule != nil; rule = rules.Next() {
+		sink.Write([]byte{byte(structs.KVSHistoryRequestType)})
+		if err := encoder.Encode(rule.(*structs.KVSHistoryRule)); err != nil {
+			return err
+		}
+	}
+
+	entries, err := s.state.KVSHistory()
+	if err != nil {
+		return err
+	}
+
+	for entry := entries.Next(); entry != nil; entry = entries.Next() {
+		sink.Write([]byte{byte(structs.KVSHistoryEntryType)})
+		if err := encoder.Encode(entry.(*structs.KVSHistoryEntry)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *consulSnapshot) Release() {
 	s.state.Close()
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/consul/state"
 	"github.com/hashicorp/consul/consul/structs"
@@ -353,6 +354,301 @@ func TestFSM_DeregisterNode(t *testing.T) {
 	}
 }
 
+func TestFSM_ServiceHealthEvents(t *testing.T) {
+	fsm, err := NewFSM(nil, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	apply := func(index uint64, msgType structs.MessageType, req interface{}) {
+		buf, err := structs.Encode(msgType, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		log := &raft.Log{Index: index, Term: 1, Type: raft.LogCommand, Data: buf}
+		if resp := fsm.Apply(log); resp != nil {
+			t.Fatalf("resp: %v", resp)
+		}
+	}
+	events := func(key string, index uint64) []structs.StreamOp {
+		events, ok := fsm.Publisher().Events(structs.StreamTopicServiceHealth, key, index)
+		if !ok {
+			t.Fatalf("should have events")
+		}
+		var ops []structs.StreamOp
+		for _, event := range events {
+			ops = append(ops, event.Op)
+		}
+		return ops
+	}
+
+	// Register a service, then fail its check.
+	req := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			ID:      "db",
+			Service: "db",
+			Port:    8000,
+		},
+		Check: &structs.HealthCheck{
+			Node:      "foo",
+			CheckID:   "db",
+			Name:      "db connectivity",
+			Status:    structs.HealthPassing,
+			ServiceID: "db",
+		},
+	}
+	apply(1, structs.RegisterRequestType, req)
+	apply(2, structs.RegisterRequestType, req)
+	req.Check.Status = structs.HealthCritical
+	apply(3, structs.RegisterRequestType, req)
+
+	// A node-level check affects the service as well.
+	apply(4, structs.RegisterRequestType, structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Check: &structs.HealthCheck{
+			Node:    "foo",
+			CheckID: "mem",
+			Name:    "memory utilization",
+			Status:  structs.HealthPassing,
+		},
+	})
+
+	// Deregistering the node deletes the service.
+	apply(5, structs.DeregisterRequestType, structs.DeregisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+	})
+
+	// The idempotent registration shouldn't have made an event.
+	expected := []structs.StreamOp{
+		structs.StreamAdd,
+		structs.StreamUpdate,
+		structs.StreamUpdate,
+		structs.StreamDelete,
+	}
+	if ops := events("db", 0); !reflect.DeepEqual(ops, expected) {
+		t.Fatalf("bad: %v", ops)
+	}
+	if ops := events("db", 3); !reflect.DeepEqual(ops, expected[2:]) {
+		t.Fatalf("bad: %v", ops)
+	}
+
+	// Restoring a snapshot makes subscribers start over.
+	snap, err := fsm.Snapshot()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer snap.Release()
+	buf := bytes.NewBuffer(nil)
+	sink := &MockSink{buf, false}
+	if err := snap.Persist(sink); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := fsm.Restore(sink); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := fsm.Publisher().Events(structs.StreamTopicServiceHealth, "db", 4); ok {
+		t.Fatalf("should need a snapshot")
+	}
+}
+
+func TestFSM_ServiceHealthEvents_Txn(t *testing.T) {
+	fsm, err := NewFSM(nil, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	apply := func(index uint64, ops structs.TxnOps) structs.TxnResponse {
+		buf, err := structs.Encode(structs.TxnRequestType, structs.TxnRequest{Datacenter: "dc1", Ops: ops})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		log := &raft.Log{Index: index, Term: 1, Type: raft.LogCommand, Data: buf}
+		resp, ok := fsm.Apply(log).(structs.TxnResponse)
+		if !ok {
+			t.Fatalf("bad response type")
+		}
+		return resp
+	}
+
+	// Subscribe before anything happens.
+	notifyCh := make(chan struct{}, 1)
+	watch := fsm.Publisher().Watch(structs.StreamTopicServiceHealth, "db")
+	watch.Wait(notifyCh)
+	defer watch.Clear(notifyCh)
+
+	// Register services on two nodes in a single transaction.
+	ops := structs.TxnOps{}
+	for _, node := range []string{"foo", "bar"} {
+		ops = append(ops,
+			&structs.TxnOp{
+				Node: &structs.TxnNodeOp{
+					Verb: structs.TxnSet,
+					Node: structs.Node{Node: node, Address: "127.0.0.1"},
+				},
+			},
+			&structs.TxnOp{
+				Service: &structs.TxnServiceOp{
+					Verb:    structs.TxnSet,
+					Node:    node,
+					Service: structs.NodeService{ID: "db", Service: "db", Port: 8000},
+				},
+			},
+			&structs.TxnOp{
+				Check: &structs.TxnCheckOp{
+					Verb: structs.TxnSet,
+					Check: structs.HealthCheck{
+						Node:      node,
+						CheckID:   "db",
+						Name:      "db connectivity",
+						Status:    structs.HealthPassing,
+						ServiceID: "db",
+					},
+				},
+			})
+	}
+	if resp := apply(1, ops); len(resp.Errors) != 0 {
+		t.Fatalf("bad: %v", resp.Errors)
+	}
+	select {
+	case <-notifyCh:
+	default:
+		t.Fatalf("should fire")
+	}
+
+	// There should be an add for the service on each node.
+	events, ok := fsm.Publisher().Events(structs.StreamTopicServiceHealth, "db", 0)
+	if !ok {
+		t.Fatalf("should have events")
+	}
+	var got []string
+	for _, event := range events {
+		got = append(got, string(event.Op)+" "+event.ServiceHealth.Node.Node)
+	}
+	expected := []string{
+		string(structs.StreamAdd) + " foo",
+		string(structs.StreamAdd) + " bar",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	// A transaction that fails doesn't change anything, so it shouldn't
+	// make any events.
+	ops = structs.TxnOps{
+		&structs.TxnOp{
+			Service: &structs.TxnServiceOp{
+				Verb:    structs.TxnDelete,
+				Node:    "foo",
+				Service: structs.NodeService{ID: "db"},
+			},
+		},
+		&structs.TxnOp{
+			Node: &structs.TxnNodeOp{
+				Verb: structs.TxnCAS,
+				Node: structs.Node{Node: "bar", Address: "127.0.0.2"},
+			},
+		},
+	}
+	if resp := apply(2, ops); len(resp.Errors) == 0 {
+		t.Fatalf("should fail")
+	}
+	events, ok = fsm.Publisher().Events(structs.StreamTopicServiceHealth, "db", 1)
+	if !ok || len(events) != 0 {
+		t.Fatalf("bad: %v", events)
+	}
+}
+
+func TestFSM_SnapshotRestore_KVSUsage(t *testing.T) {
+	fsm, err := NewFSM(nil, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Track a prefix and write a key under it.
+	if err := fsm.state.KVSTrackUsage([]string{"tmp/"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	fsm.state.KVSSet(1, &structs.DirEntry{Key: "tmp/a", Value: []byte("test")})
+
+	// Snapshot and restore.
+	snap, err := fsm.Snapshot()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer snap.Release()
+	buf := bytes.NewBuffer(nil)
+	sink := &MockSink{buf, false}
+	if err := snap.Persist(sink); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := fsm.Restore(sink); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The new state store should still be tracking the prefix.
+	prefixes, err := fsm.state.KVSUsagePrefixes()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(prefixes, []string{"tmp/"}) {
+		t.Fatalf("bad: %v", prefixes)
+	}
+	_, keys, size, err := fsm.state.KVSUsage("tmp/")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if keys != 1 || size != 4 {
+		t.Fatalf("bad: %d %d", keys, size)
+	}
+}
+
+func TestFSM_SnapshotRestore_KVSHistoryRules(t *testing.T) {
+	fsm, err := NewFSM(nil, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Store an empty rule set, which still has an index.
+	if err := fsm.state.KVSHistorySetRules(5, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Snapshot and restore.
+	snap, err := fsm.Snapshot()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer snap.Release()
+	buf := bytes.NewBuffer(nil)
+	sink := &MockSink{buf, false}
+	if err := snap.Persist(sink); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	fsm2, err := NewFSM(nil, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := fsm2.Restore(sink); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The index should survive, so the rules aren't seeded again from the
+	// configuration.
+	idx, rules, err := fsm2.state.KVSHistoryRules()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if idx != 5 || len(rules) != 0 {
+		t.Fatalf("bad: %d %#v", idx, rules)
+	}
+}
+
 func TestFSM_SnapshotRestore(t *testing.T) {
 	fsm, err := NewFSM(nil, os.Stderr)
 	if err != nil {
@@ -361,9 +657,9 @@ func TestFSM_SnapshotRestore(t *testing.T) {
 
 	// Add some state
 	fsm.state.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"})
-	fsm.state.EnsureNode(2, &structs.Node{Node: "baz", Address: "127.0.0.2", TaggedAddresses: map[string]string{"hello": "1.2.3.4"}})
+	fsm.state.EnsureNode(2, &structs.Node{Node: "baz", Address: "127.0.0.2", TaggedAddresses: map[string]string{"hello": "1.2.3.4"}, Meta: map[string]string{"testMeta": "testing123"}})
 	fsm.state.EnsureService(3, "foo", &structs.NodeService{ID: "web", Service: "web", Tags: nil, Address: "127.0.0.1", Port: 80})
-	fsm.state.EnsureService(4, "foo", &structs.NodeService{ID: "db", Service: "db", Tags: []string{"primary"}, Address: "127.0.0.1", Port: 5000})
+	fsm.state.EnsureService(4, "foo", &structs.NodeService{ID: "db", Service: "db", Tags: []string{"primary"}, Address: "127.0.0.1", Port: 5000, Meta: map[string]string{"version": "1.2.3"}})
 	fsm.state.EnsureService(5, "baz", &structs.NodeService{ID: "web", Service: "web", Tags: nil, Address: "127.0.0.2", Port: 80})
 	fsm.state.EnsureService(6, "baz", &structs.NodeService{ID: "db", Service: "db", Tags: []string{"secondary"}, Address: "127.0.0.2", Port: 5000})
 	fsm.state.EnsureCheck(7, &structs.HealthCheck{
@@ -379,7 +675,9 @@ func TestFSM_SnapshotRestore(t *testing.T) {
 	})
 	session := &structs.Session{ID: generateUUID(), Node: "foo"}
 	fsm.state.SessionCreate(9, session)
-	acl := &structs.ACL{ID: generateUUID(), Name: "User Token"}
+	policy := &structs.ACLPolicyEntry{ID: generateUUID(), Name: "web", Datacenters: []string{"dc1"}}
+	fsm.state.ACLPolicySet(10, policy)
+	acl := &structs.ACL{ID: generateUUID(), Name: "User Token", Policies: []string{policy.ID}}
 	fsm.state.ACLSet(10, acl)
 
 	fsm.state.KVSSet(11, &structs.DirEntry{
@@ -423,6 +721,17 @@ func TestFSM_SnapshotRestore(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 
+	historyRules := structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: "/test", Versions: 3},
+	}
+	if err := fsm.state.KVSHistorySetRules(15, historyRules); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	fsm.state.KVSSet(16, &structs.DirEntry{
+		Key:   "/test",
+		Value: []byte("bar"),
+	})
+
 	// Snapshot
 	snap, err := fsm.Snapshot()
 	if err != nil {
@@ -459,7 +768,9 @@ func TestFSM_SnapshotRestore(t *testing.T) {
 	if nodes[0].Node != "baz" ||
 		nodes[0].Address != "127.0.0.2" ||
 		len(nodes[0].TaggedAddresses) != 1 ||
-		nodes[0].TaggedAddresses["hello"] != "1.2.3.4" {
+		nodes[0].TaggedAddresses["hello"] != "1.2.3.4" ||
+		len(nodes[0].Meta) != 1 ||
+		nodes[0].Meta["testMeta"] != "testing123" {
 		t.Fatalf("bad: %v", nodes[0])
 	}
 	if nodes[1].Node != "foo" ||
@@ -481,6 +792,9 @@ func TestFSM_SnapshotRestore(t *testing.T) {
 	if fooSrv.Services["db"].Port != 5000 {
 		t.Fatalf("Bad: %v", fooSrv)
 	}
+	if fooSrv.Services["db"].Meta["version"] != "1.2.3" {
+		t.Fatalf("Bad: %v", fooSrv)
+	}
 
 	_, checks, err := fsm2.state.NodeChecks("foo")
 	if err != nil {
@@ -495,10 +809,29 @@ func TestFSM_SnapshotRestore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
-	if string(d.Value) != "foo" {
+	if string(d.Value) != "bar" {
 		t.Fatalf("bad: %v", d)
 	}
 
+	// Verify the key's history is restored
+	idx, rules, err := fsm2.state.KVSHistoryRules()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if idx != 15 {
+		t.Fatalf("bad index: %d", idx)
+	}
+	if !reflect.DeepEqual(rules, historyRules) {
+		t.Fatalf("bad: %#v", rules)
+	}
+	_, versions, err := fsm2.state.KVSHistory("/test")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(versions) != 1 || string(versions[0].Value) != "foo" || versions[0].ReplacedIndex != 16 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
 	// Verify session is restored
 	idx, s, err := fsm2.state.SessionGet(session.ID)
 	if err != nil {
@@ -522,6 +855,18 @@ func TestFSM_SnapshotRestore(t *testing.T) {
 	if a.ModifyIndex <= 1 {
 		t.Fatalf("bad index: %d", idx)
 	}
+	if len(a.Policies) != 1 || a.Policies[0] != policy.ID {
+		t.Fatalf("bad: %v", a)
+	}
+
+	// Verify ACL policies are restored
+	_, p, err := fsm2.state.ACLPolicyGet(policy.ID)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if p == nil || p.Name != "web" || !reflect.DeepEqual(p.Datacenters, []string{"dc1"}) {
+		t.Fatalf("bad: %v", p)
+	}
 
 	// Verify tombstones are restored
 	func() {
@@ -599,6 +944,106 @@ func TestFSM_KVSSet(t *testing.T) {
 	}
 }
 
+func TestFSM_KVSHistory(t *testing.T) {
+	fsm, err := NewFSM(nil, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Set the rules
+	req := structs.KVSHistoryRequest{
+		Datacenter: "dc1",
+		Op:         structs.KVSHistorySetRules,
+		Rules: structs.KVSHistoryRules{
+			&structs.KVSHistoryRule{Prefix: "/test/", Window: time.Hour},
+		},
+	}
+	buf, err := structs.Encode(structs.KVSHistoryRequestType, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp := fsm.Apply(makeLog(buf))
+	if resp != nil {
+		t.Fatalf("resp: %v", resp)
+	}
+	_, rules, err := fsm.state.KVSHistoryRules()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(rules, req.Rules) {
+		t.Fatalf("bad: %#v", rules)
+	}
+
+	// Keep a version of a key
+	fsm.state.KVSSet(1, &structs.DirEntry{Key: "/test/path", Value: []byte("a")})
+	fsm.state.KVSSet(2, &structs.DirEntry{Key: "/test/path", Value: []byte("b")})
+	_, versions, err := fsm.state.KVSHistory("/test/path")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// Prune it
+	req = structs.KVSHistoryRequest{
+		Datacenter: "dc1",
+		Op:         structs.KVSHistoryPrune,
+		Prefix:     "/test/",
+		Index:      3,
+	}
+	buf, err = structs.Encode(structs.KVSHistoryRequestType, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp = fsm.Apply(makeLog(buf))
+	if resp != nil {
+		t.Fatalf("resp: %v", resp)
+	}
+	_, versions, err = fsm.state.KVSHistory("/test/path")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(versions) != 0 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// Keep another version, then drop the rules, which leaves it alone
+	fsm.state.KVSSet(4, &structs.DirEntry{Key: "/test/path", Value: []byte("c")})
+	if err := fsm.state.KVSHistorySetRules(5, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, versions, err = fsm.state.KVSHistory("/test/path")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// Prune the uncovered version
+	req = structs.KVSHistoryRequest{
+		Datacenter: "dc1",
+		Op:         structs.KVSHistoryPruneUncovered,
+		Index:      5,
+	}
+	buf, err = structs.Encode(structs.KVSHistoryRequestType, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp = fsm.Apply(makeLog(buf))
+	if resp != nil {
+		t.Fatalf("resp: %v", resp)
+	}
+	_, versions, err = fsm.state.KVSHistory("/test/path")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(versions) != 0 {
+		t.Fatalf("bad: %#v", versions)
+	}
+}
+
 func TestFSM_KVSDelete(t *testing.T) {
 	fsm, err := NewFSM(nil, os.Stderr)
 	if err != nil {
@@ -1026,6 +1471,67 @@ func TestFSM_KVSUnlock(t *testing.T) {
 	}
 }
 
+func TestFSM_ACLPolicy_Set_Delete(t *testing.T) {
+	fsm, err := NewFSM(nil, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Create a new policy
+	req := structs.ACLPolicyEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		Policy: structs.ACLPolicyEntry{
+			ID:    generateUUID(),
+			Name:  "web",
+			Rules: `service "web" { policy = "write" }`,
+		},
+	}
+	buf, err := structs.Encode(structs.ACLPolicyEntryRequestType, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp := fsm.Apply(makeLog(buf))
+	if err, ok := resp.(error); ok {
+		t.Fatalf("resp: %v", err)
+	}
+
+	// Get the policy
+	id := resp.(string)
+	_, policy, err := fsm.state.ACLPolicyGet(id)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if policy == nil || policy.Name != "web" || policy.Rules != req.Policy.Rules {
+		t.Fatalf("bad: %v", policy)
+	}
+
+	// Try to destroy
+	destroy := structs.ACLPolicyEntryRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLDelete,
+		Policy: structs.ACLPolicyEntry{
+			ID: id,
+		},
+	}
+	buf, err = structs.Encode(structs.ACLPolicyEntryRequestType, destroy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp = fsm.Apply(makeLog(buf))
+	if resp != nil {
+		t.Fatalf("resp: %v", resp)
+	}
+
+	_, policy, err = fsm.state.ACLPolicyGet(id)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if policy != nil {
+		t.Fatalf("should be destroyed")
+	}
+}
+
 func TestFSM_ACL_Set_Delete(t *testing.T) {
 	fsm, err := NewFSM(nil, os.Stderr)
 	if err != nil {
@@ -1098,6 +1604,74 @@ func TestFSM_ACL_Set_Delete(t *testing.T) {
 	}
 }
 
+func TestFSM_ACL_Reap(t *testing.T) {
+	fsm, err := NewFSM(nil, os.Stderr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Create an ACL that expires.
+	expires := time.Now().Add(time.Minute)
+	req := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			ID:             generateUUID(),
+			Name:           "User token",
+			Type:           structs.ACLTypeClient,
+			ExpirationTime: &expires,
+		},
+	}
+	buf, err := structs.Encode(structs.ACLRequestType, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp := fsm.Apply(makeLog(buf))
+	if err, ok := resp.(error); ok {
+		t.Fatalf("resp: %v", err)
+	}
+	id := resp.(string)
+
+	// The expiration time should survive the trip through the log.
+	_, acl, err := fsm.state.ACLGet(id)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if acl == nil || acl.ExpirationTime == nil || !acl.ExpirationTime.Equal(expires) {
+		t.Fatalf("bad: %#v", acl)
+	}
+
+	// Reaping before it expires does nothing.
+	reap := structs.ACLReapRequest{
+		Datacenter: "dc1",
+		ACLs:       []string{id},
+		Time:       time.Now(),
+	}
+	buf, err = structs.Encode(structs.ACLReapRequestType, reap)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp := fsm.Apply(makeLog(buf)); resp != nil {
+		t.Fatalf("resp: %v", resp)
+	}
+	if _, acl, err := fsm.state.ACLGet(id); err != nil || acl == nil {
+		t.Fatalf("bad: %#v %v", acl, err)
+	}
+
+	// Reaping after it expires deletes it.
+	reap.Time = expires
+	buf, err = structs.Encode(structs.ACLReapRequestType, reap)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp := fsm.Apply(makeLog(buf)); resp != nil {
+		t.Fatalf("resp: %v", resp)
+	}
+	if _, acl, err := fsm.state.ACLGet(id); err != nil || acl != nil {
+		t.Fatalf("bad: %#v %v", acl, err)
+	}
+}
+
 func TestFSM_PreparedQuery_CRUD(t *testing.T) {
 	fsm, err := NewFSM(nil, os.Stderr)
 	if err != nil {
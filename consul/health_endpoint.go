@@ -18,6 +18,12 @@ func (h *Health) ChecksInState(args *structs.ChecksInStateRequest,
 		return err
 	}
 
+	// Compile the filter, if one was given
+	f, err := newQueryFilter(&args.QueryOptions, structs.HealthCheck{})
+	if err != nil {
+		return err
+	}
+
 	// Get the state specific checks
 	state := h.srv.fsm.State()
 	return h.srv.blockingRPC(
@@ -30,6 +36,13 @@ func (h *Health) ChecksInState(args *structs.ChecksInStateRequest,
 				return err
 			}
 			reply.Index, reply.HealthChecks = index, checks
+			if f != nil {
+				raw, err := f.Execute(reply.HealthChecks)
+				if err != nil {
+					return err
+				}
+				reply.HealthChecks = raw.(structs.HealthChecks)
+			}
 			if err := h.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
@@ -44,18 +57,31 @@ func (h *Health) NodeChecks(args *structs.NodeSpecificRequest,
 		return err
 	}
 
+	// Compile the filter, if one was given
+	f, err := newQueryFilter(&args.QueryOptions, structs.HealthCheck{})
+	if err != nil {
+		return err
+	}
+
 	// Get the node checks
 	state := h.srv.fsm.State()
 	return h.srv.blockingRPC(
 		&args.QueryOptions,
 		&reply.QueryMeta,
-		state.GetQueryWatch("NodeChecks"),
+		state.GetNodeWatch(args.Node),
 		func() error {
 			index, checks, err := state.NodeChecks(args.Node)
 			if err != nil {
 				return err
 			}
 			reply.Index, reply.HealthChecks = index, checks
+			if f != nil {
+				raw, err := f.Execute(reply.HealthChecks)
+				if err != nil {
+					return err
+				}
+				reply.HealthChecks = raw.(structs.HealthChecks)
+			}
 			return h.srv.filterACL(args.Token, reply)
 		})
 }
@@ -73,18 +99,31 @@ func (h *Health) ServiceChecks(args *structs.ServiceSpecificRequest,
 		return err
 	}
 
+	// Compile the filter, if one was given
+	f, err := newQueryFilter(&args.QueryOptions, structs.HealthCheck{})
+	if err != nil {
+		return err
+	}
+
 	// Get the service checks
 	state := h.srv.fsm.State()
 	return h.srv.blockingRPC(
 		&args.QueryOptions,
 		&reply.QueryMeta,
-		state.GetQueryWatch("ServiceChecks"),
+		state.GetServiceWatch(args.ServiceName),
 		func() error {
 			index, checks, err := state.ServiceChecks(args.ServiceName)
 			if err != nil {
 				return err
 			}
 			reply.Index, reply.HealthChecks = index, checks
+			if f != nil {
+				raw, err := f.Execute(reply.HealthChecks)
+				if err != nil {
+					return err
+				}
+				reply.HealthChecks = raw.(structs.HealthChecks)
+			}
 			if err := h.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
@@ -103,18 +142,24 @@ func (h *Health) ServiceNodes(args *structs.ServiceSpecificRequest, reply *struc
 		return fmt.Errorf("Must provide service name")
 	}
 
+	// Compile the filter, if one was given
+	f, err := newQueryFilter(&args.QueryOptions, structs.CheckServiceNode{})
+	if err != nil {
+		return err
+	}
+
 	// Get the nodes
 	state := h.srv.fsm.State()
-	err := h.srv.blockingRPC(
+	err = h.srv.blockingRPC(
 		&args.QueryOptions,
 		&reply.QueryMeta,
-		state.GetQueryWatch("CheckServiceNodes"),
+		state.GetServiceWatch(args.ServiceName),
 		func() error {
 			var index uint64
 			var nodes structs.CheckServiceNodes
 			var err error
 			if args.TagFilter {
-				index, nodes, err = state.CheckServiceTagNodes(args.ServiceName, args.ServiceTag)
+				index, nodes, err = state.CheckServiceTagNodes(args.ServiceName, args.FilterTags())
 			} else {
 				index, nodes, err = state.CheckServiceNodes(args.ServiceName)
 			}
@@ -123,17 +168,36 @@ func (h *Health) ServiceNodes(args *structs.ServiceSpecificRequest, reply *struc
 			}
 
 			reply.Index, reply.Nodes = index, nodes
+			if len(args.NodeMetaFilters) > 0 {
+				reply.Nodes = nodeMetaFilter(args.NodeMetaFilters, nodes)
+			}
+			if f != nil {
+				raw, err := f.Execute(reply.Nodes)
+				if err != nil {
+					return err
+				}
+				reply.Nodes = raw.(structs.CheckServiceNodes)
+			}
 			if err := h.srv.filterACL(args.Token, reply); err != nil {
 				return err
 			}
+
+			// Shuffle the results according to the service weights, if
+			// any are set. Otherwise the order is left alone, so watchers
+			// don't see changes that are just a different shuffle. If
+			// coordinates are available this will be overridden by the
+			// distance sort, which is stable so ties stay shuffled.
+			if reply.Nodes.HasWeights() {
+				reply.Nodes.Shuffle()
+			}
 			return h.srv.sortNodesByDistanceFrom(args.Source, reply.Nodes)
 		})
 
 	// Provide some metrics
 	if err == nil {
 		metrics.IncrCounter([]string{"consul", "health", "service", "query", args.ServiceName}, 1)
-		if args.ServiceTag != "" {
-			metrics.IncrCounter([]string{"consul", "health", "service", "query-tag", args.ServiceName, args.ServiceTag}, 1)
+		for _, tag := range args.FilterTags() {
+			metrics.IncrCounter([]string{"consul", "health", "service", "query-tag", args.ServiceName, tag}, 1)
 		}
 		if len(reply.Nodes) == 0 {
 			metrics.IncrCounter([]string{"consul", "health", "service", "not-found", args.ServiceName}, 1)
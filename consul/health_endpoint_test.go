@@ -2,6 +2,7 @@ package consul
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -392,6 +393,269 @@ func TestHealth_ServiceNodes(t *testing.T) {
 	}
 }
 
+func TestHealth_ServiceNodes_NodeMetaFilter(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	arg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		NodeMeta: map[string]string{
+			"somekey": "somevalue",
+			"common":  "1",
+		},
+		Service: &structs.NodeService{
+			ID:      "db",
+			Service: "db",
+		},
+		Check: &structs.HealthCheck{
+			Name:      "db connect",
+			Status:    structs.HealthPassing,
+			ServiceID: "db",
+		},
+	}
+	var out struct{}
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	arg = structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "bar",
+		Address:    "127.0.0.2",
+		NodeMeta: map[string]string{
+			"common": "1",
+		},
+		Service: &structs.NodeService{
+			ID:      "db",
+			Service: "db",
+		},
+		Check: &structs.HealthCheck{
+			Name:      "db connect",
+			Status:    structs.HealthWarning,
+			ServiceID: "db",
+		},
+	}
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		filters map[string]string
+		nodes   structs.CheckServiceNodes
+	}{
+		{
+			filters: map[string]string{"somekey": "somevalue"},
+			nodes: structs.CheckServiceNodes{
+				structs.CheckServiceNode{
+					Node:   &structs.Node{Node: "foo"},
+					Checks: structs.HealthChecks{&structs.HealthCheck{Name: "db connect", Status: structs.HealthPassing}},
+				},
+			},
+		},
+		{
+			filters: map[string]string{"common": "1"},
+			nodes: structs.CheckServiceNodes{
+				structs.CheckServiceNode{
+					Node:   &structs.Node{Node: "bar"},
+					Checks: structs.HealthChecks{&structs.HealthCheck{Name: "db connect", Status: structs.HealthWarning}},
+				},
+				structs.CheckServiceNode{
+					Node:   &structs.Node{Node: "foo"},
+					Checks: structs.HealthChecks{&structs.HealthCheck{Name: "db connect", Status: structs.HealthPassing}},
+				},
+			},
+		},
+		{
+			filters: map[string]string{"invalid": "nope"},
+			nodes:   structs.CheckServiceNodes{},
+		},
+	}
+
+	for _, tc := range cases {
+		var out structs.IndexedCheckServiceNodes
+		req := structs.ServiceSpecificRequest{
+			Datacenter:      "dc1",
+			NodeMetaFilters: tc.filters,
+			ServiceName:     "db",
+		}
+		if err := msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		if len(out.Nodes) != len(tc.nodes) {
+			t.Fatalf("bad: %v, %v, filters: %v", out.Nodes, tc.nodes, tc.filters)
+		}
+
+		for i, node := range out.Nodes {
+			checks := tc.nodes[i].Checks
+			if len(node.Checks) != len(checks) {
+				t.Fatalf("bad: %v, %v, filters: %v", node.Checks, checks, tc.filters)
+			}
+			for j, check := range node.Checks {
+				if check.Name != checks[j].Name || check.Status != checks[j].Status {
+					t.Fatalf("bad: %v, %v, filters: %v", check, checks[j], tc.filters)
+				}
+			}
+		}
+	}
+}
+
+func TestHealth_ServiceNodes_Filter(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	for node, status := range map[string]string{
+		"foo": structs.HealthPassing,
+		"bar": structs.HealthCritical,
+	} {
+		arg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.1",
+			NodeMeta:   map[string]string{"rack": "b"},
+			Service: &structs.NodeService{
+				ID:      "db",
+				Service: "db",
+				Tags:    []string{"v2"},
+			},
+			Check: &structs.HealthCheck{
+				Name:      "db connect",
+				Status:    status,
+				ServiceID: "db",
+			},
+		}
+		var out struct{}
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	req := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "db",
+		QueryOptions: structs.QueryOptions{
+			Filter: `Service.Tags contains "v2" and Node.Meta.rack == "b" and Checks.Status != "critical"`,
+		},
+	}
+	var out structs.IndexedCheckServiceNodes
+	if err := msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Nodes) != 1 || out.Nodes[0].Node.Node != "foo" {
+		t.Fatalf("bad: %v", out.Nodes)
+	}
+
+	// Checks can be filtered as well.
+	checksReq := structs.ChecksInStateRequest{
+		Datacenter: "dc1",
+		State:      structs.HealthAny,
+		QueryOptions: structs.QueryOptions{
+			Filter: `Node == "bar" and ServiceName == "db"`,
+		},
+	}
+	var checks structs.IndexedHealthChecks
+	if err := msgpackrpc.CallWithCodec(codec, "Health.ChecksInState", &checksReq, &checks); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(checks.HealthChecks) != 1 || checks.HealthChecks[0].Status != structs.HealthCritical {
+		t.Fatalf("bad: %v", checks.HealthChecks)
+	}
+
+	// Invalid expressions are rejected.
+	req.Filter = `Service.Nope == "v2"`
+	var bad structs.IndexedCheckServiceNodes
+	err := msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &req, &bad)
+	if err == nil || !strings.Contains(err.Error(), "Invalid filter expression") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestHealth_ServiceNodes_Blocking(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Register the service we are going to watch.
+	state := s1.fsm.State()
+	if err := state.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := state.EnsureService(2, "foo", &structs.NodeService{ID: "web", Service: "web", Port: 80}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	args := structs.ServiceSpecificRequest{
+		Datacenter:  "dc1",
+		ServiceName: "web",
+	}
+	var out structs.IndexedCheckServiceNodes
+	if err := msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Setup a blocking query.
+	args.MinQueryIndex = out.Index
+	args.MaxQueryTime = 2 * time.Second
+
+	// Change an unrelated service first, which shouldn't wake up the
+	// query, and then add a check to the service we're watching.
+	idx := out.Index
+	start := time.Now()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if err := state.EnsureService(idx+1, "foo", &structs.NodeService{ID: "db", Service: "db", Port: 5000}); err != nil {
+			t.Errorf("err: %v", err)
+			return
+		}
+
+		time.Sleep(200 * time.Millisecond)
+		check := &structs.HealthCheck{
+			Node:      "foo",
+			CheckID:   "web-check",
+			Status:    structs.HealthCritical,
+			ServiceID: "web",
+		}
+		if err := state.EnsureCheck(idx+2, check); err != nil {
+			t.Errorf("err: %v", err)
+			return
+		}
+	}()
+
+	out = structs.IndexedCheckServiceNodes{}
+	if err := msgpackrpc.CallWithCodec(codec, "Health.ServiceNodes", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Should have slept through the unrelated change.
+	if elapsed := time.Now().Sub(start); elapsed < 300*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("bad: %v", elapsed)
+	}
+
+	// The index should still cover all the tables.
+	if out.Index != idx+2 {
+		t.Fatalf("bad: %v", out)
+	}
+	if len(out.Nodes) != 1 || len(out.Nodes[0].Checks) != 1 {
+		t.Fatalf("bad: %v", out)
+	}
+}
+
 func TestHealth_ServiceNodes_DistanceSort(t *testing.T) {
 	dir1, s1 := testServer(t)
 	defer os.RemoveAll(dir1)
@@ -26,7 +26,7 @@ func (m *Internal) NodeInfo(args *structs.NodeSpecificRequest,
 	return m.srv.blockingRPC(
 		&args.QueryOptions,
 		&reply.QueryMeta,
-		state.GetQueryWatch("NodeInfo"),
+		state.GetNodeWatch(args.Node),
 		func() error {
 			index, dump, err := state.NodeInfo(args.Node)
 			if err != nil {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/structs"
 )
 
@@ -23,6 +24,17 @@ func kvsPreApply(srv *Server, acl acl.ACL, op structs.KVSOp, dirEnt *structs.Dir
 		return false, fmt.Errorf("Must provide key")
 	}
 
+	// Verify the TTL, if one was given.
+	if dirEnt.TTL != "" {
+		ttl, err := time.ParseDuration(dirEnt.TTL)
+		if err != nil {
+			return false, fmt.Errorf("Invalid KV TTL '%s': %v", dirEnt.TTL, err)
+		}
+		if ttl < 0 {
+			return false, fmt.Errorf("Invalid KV TTL '%s': must not be negative", dirEnt.TTL)
+		}
+	}
+
 	// Apply the ACL policy if any.
 	if acl != nil {
 		switch op {
@@ -82,15 +94,24 @@ func (k *KVS) Apply(args *structs.KVSRequest, reply *bool) error {
 	}
 	ok, err := kvsPreApply(k.srv, acl, args.Op, &args.DirEnt)
 	if err != nil {
-		return err
+		return k.srv.auditDenied(err, args.Token, "KVS.Apply", audit.ResourceKV, args.DirEnt.Key)
 	}
 	if !ok {
 		*reply = false
 		return nil
 	}
 
+	// Make sure the write stays within the KV quotas.
+	quota := newKVSQuotaTracker(k.srv, args.Token)
+	quota.lock(args.Op, args.DirEnt.Key)
+	if err := quota.apply(args.Op, &args.DirEnt); err != nil {
+		quota.unlock()
+		return err
+	}
+
 	// Apply the update.
 	resp, err := k.srv.raftApply(structs.KVSRequestType, args)
+	quota.unlock()
 	if err != nil {
 		k.srv.logger.Printf("[ERR] consul.kvs: Apply failed: %v", err)
 		return err
@@ -99,9 +120,23 @@ func (k *KVS) Apply(args *structs.KVSRequest, reply *bool) error {
 		return respErr
 	}
 
+	// The write may have set, changed or removed the key's TTL, so bring
+	// its expiration timer up to date.
+	if err := k.srv.resetKVSTimer(args.DirEnt.Key, nil); err != nil {
+		k.srv.logger.Printf("[ERR] consul.kvs: Failed to reset TTL for %q: %v", args.DirEnt.Key, err)
+	}
+
 	// Check if the return type is a bool.
+	applied := true
 	if respBool, ok := resp.(bool); ok {
 		*reply = respBool
+		applied = respBool
+	}
+
+	// Record the write, unless it was a CAS or lock operation that didn't
+	// go through.
+	if acl != nil && applied {
+		k.srv.auditACL(args.Token, "KVS.Apply", audit.ResourceKV, args.DirEnt.Key, audit.Allow)
 	}
 	return nil
 }
@@ -124,7 +159,14 @@ func (k *KVS) Get(args *structs.KeyRequest, reply *structs.IndexedDirEntries) er
 		&reply.QueryMeta,
 		state.GetKVSWatch(args.Key),
 		func() error {
-			index, ent, err := state.KVSGet(args.Key)
+			var index uint64
+			var ent *structs.DirEntry
+			var err error
+			if args.AtIndex > 0 {
+				index, ent, err = state.KVSGetAt(args.Key, args.AtIndex)
+			} else {
+				index, ent, err = state.KVSGet(args.Key)
+			}
 			if err != nil {
 				return err
 			}
@@ -148,6 +190,45 @@ func (k *KVS) Get(args *structs.KeyRequest, reply *structs.IndexedDirEntries) er
 		})
 }
 
+// History is used to list the prior versions of a key that have been kept
+// by its history rule.
+func (k *KVS) History(args *structs.KeyRequest, reply *structs.IndexedKVSHistory) error {
+	if done, err := k.srv.forward("KVS.History", args, args, reply); done {
+		return err
+	}
+
+	acl, err := k.srv.resolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+
+	// Get the local state
+	state := k.srv.fsm.State()
+	return k.srv.blockingRPC(
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		state.GetKVSWatch(args.Key),
+		func() error {
+			index, entries, err := state.KVSHistory(args.Key)
+			if err != nil {
+				return err
+			}
+			if acl != nil && !acl.KeyRead(args.Key) {
+				entries = nil
+			}
+
+			// Must provide non-zero index to prevent blocking
+			// Index 1 is impossible anyways (due to Raft internals)
+			if index == 0 {
+				reply.Index = 1
+			} else {
+				reply.Index = index
+			}
+			reply.Entries = entries
+			return nil
+		})
+}
+
 // List is used to list all keys with a given prefix.
 func (k *KVS) List(args *structs.KeyRequest, reply *structs.IndexedDirEntries) error {
 	if done, err := k.srv.forward("KVS.List", args, args, reply); done {
@@ -166,13 +247,17 @@ func (k *KVS) List(args *structs.KeyRequest, reply *structs.IndexedDirEntries) e
 		&reply.QueryMeta,
 		state.GetKVSWatch(args.Key),
 		func() error {
-			index, ent, err := state.KVSList(args.Key)
+			index, ent, next, err := state.KVSListPage(args.Key, args.After, args.Limit)
 			if err != nil {
 				return err
 			}
+
+			// Pages are cut before the ACL filter, so a page may come
+			// back short or even empty while there are still more.
 			if acl != nil {
 				ent = FilterDirEnt(acl, ent)
 			}
+			reply.NextAfter = next
 
 			if len(ent) == 0 {
 				// Must provide non-zero index to prevent blocking
@@ -209,10 +294,11 @@ func (k *KVS) ListKeys(args *structs.KeyListRequest, reply *structs.IndexedKeyLi
 		&reply.QueryMeta,
 		state.GetKVSWatch(args.Prefix),
 		func() error {
-			index, keys, err := state.KVSListKeys(args.Prefix, args.Seperator)
+			index, keys, next, err := state.KVSListKeysPage(args.Prefix, args.Seperator, args.After, args.Limit)
 			if err != nil {
 				return err
 			}
+			reply.NextAfter = next
 
 			// Must provide non-zero index to prevent blocking
 			// Index 1 is impossible anyways (due to Raft internals)
@@ -229,3 +315,30 @@ func (k *KVS) ListKeys(args *structs.KeyListRequest, reply *structs.IndexedKeyLi
 			return nil
 		})
 }
+
+// ReplicationStatus is used to retrieve the current KV replication status,
+// with an entry for each replicated prefix.
+func (k *KVS) ReplicationStatus(args *structs.DCSpecificRequest,
+	reply *structs.KVSReplicationStatuses) error {
+	// This must be sent to the leader, so we fix the args since we are
+	// re-using a structure where we don't support all the options.
+	args.RequireConsistent = true
+	args.AllowStale = false
+	if done, err := k.srv.forward("KVS.ReplicationStatus", args, args, reply); done {
+		return err
+	}
+
+	// As with ACL replication, there's no token required here so that
+	// health checks can query this.
+
+	// Poll the latest status.
+	k.srv.kvsReplicationStatusLock.RLock()
+	statuses := make(structs.KVSReplicationStatuses, 0, len(k.srv.kvsReplicationStatus))
+	for _, status := range k.srv.kvsReplicationStatus {
+		status := status
+		statuses = append(statuses, &status)
+	}
+	k.srv.kvsReplicationStatusLock.RUnlock()
+	*reply = statuses
+	return nil
+}
@@ -1,8 +1,11 @@
 package consul
 
 import (
+	"fmt"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -129,6 +132,98 @@ func TestKVS_Apply_ACLDeny(t *testing.T) {
 	}
 }
 
+func TestKVS_Apply_Quota(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.KVSQuotas = structs.KVSQuotas{
+			&structs.KVSQuota{Prefix: "tmp/", MaxKeys: 1},
+		}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	apply := func(op structs.KVSOp, key string) error {
+		arg := structs.KVSRequest{
+			Datacenter: "dc1",
+			Op:         op,
+			DirEnt: structs.DirEntry{
+				Key:   key,
+				Value: []byte("test"),
+			},
+		}
+		var out bool
+		return msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out)
+	}
+
+	// The first key fits, the second doesn't.
+	if err := apply(structs.KVSSet, "tmp/a"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	err := apply(structs.KVSSet, "tmp/b")
+	if err == nil || !strings.Contains(err.Error(), quotaExceeded) {
+		t.Fatalf("err: %v", err)
+	}
+	_, d, err := s1.fsm.State().KVSGet("tmp/b")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if d != nil {
+		t.Fatalf("should not be written: %v", d)
+	}
+
+	// Deleting the first key makes room.
+	if err := apply(structs.KVSDelete, "tmp/a"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := apply(structs.KVSSet, "tmp/b"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestKVS_Apply_Quota_Concurrent(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.KVSQuotas = structs.KVSQuotas{
+			&structs.KVSQuota{Prefix: "tmp/", MaxKeys: 3},
+		}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Race a bunch of writes against the quota.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			arg := structs.KVSRequest{
+				Datacenter: "dc1",
+				Op:         structs.KVSSet,
+				DirEnt: structs.DirEntry{
+					Key:   fmt.Sprintf("tmp/%d", i),
+					Value: []byte("test"),
+				},
+			}
+			var out bool
+			s1.RPC("KVS.Apply", &arg, &out)
+		}(i)
+	}
+	wg.Wait()
+
+	// Only the keys that fit should have made it in.
+	_, keys, _, err := s1.fsm.State().KVSUsage("tmp/")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if keys != 3 {
+		t.Fatalf("bad: %d", keys)
+	}
+}
+
 func TestKVS_Get(t *testing.T) {
 	dir1, s1 := testServer(t)
 	defer os.RemoveAll(dir1)
@@ -292,6 +387,88 @@ func TestKVSEndpoint_List(t *testing.T) {
 	}
 }
 
+func TestKVSEndpoint_List_Paging(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	keys := []string{
+		"/test/key1",
+		"/test/key2",
+		"/test/sub/key3",
+	}
+
+	for _, key := range keys {
+		arg := structs.KVSRequest{
+			Datacenter: "dc1",
+			Op:         structs.KVSSet,
+			DirEnt: structs.DirEntry{
+				Key:   key,
+				Flags: 1,
+			},
+		}
+		var out bool
+		if err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Page through the entries
+	getR := structs.KeyRequest{
+		Datacenter: "dc1",
+		Key:        "/test",
+		Limit:      2,
+	}
+	var dirent structs.IndexedDirEntries
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.List", &getR, &dirent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(dirent.Entries) != 2 || dirent.NextAfter != keys[1] {
+		t.Fatalf("Bad: %v", dirent)
+	}
+	index := dirent.Index
+
+	getR.After = dirent.NextAfter
+	dirent = structs.IndexedDirEntries{}
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.List", &getR, &dirent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(dirent.Entries) != 1 || dirent.Entries[0].Key != keys[2] || dirent.NextAfter != "" {
+		t.Fatalf("Bad: %v", dirent)
+	}
+	if dirent.Index != index {
+		t.Fatalf("Bad: %v", dirent)
+	}
+
+	// Page through the keys
+	listR := structs.KeyListRequest{
+		Datacenter: "dc1",
+		Prefix:     "/test/",
+		Seperator:  "/",
+		Limit:      1,
+	}
+	var seen []string
+	for {
+		var keyList structs.IndexedKeyList
+		if err := msgpackrpc.CallWithCodec(codec, "KVS.ListKeys", &listR, &keyList); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		seen = append(seen, keyList.Keys...)
+		if keyList.NextAfter == "" {
+			break
+		}
+		listR.After = keyList.NextAfter
+	}
+	expected := []string{"/test/key1", "/test/key2", "/test/sub/"}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Fatalf("Bad: %v", seen)
+	}
+}
+
 func TestKVSEndpoint_List_Blocking(t *testing.T) {
 	dir1, s1 := testServer(t)
 	defer os.RemoveAll(dir1)
@@ -830,3 +1007,39 @@ key "test/priv" {
 	policy = "read"
 }
 `
+
+func TestKVSEndpoint_ReplicationStatus(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.KVSReplication = []*KVSReplicationConfig{
+			&KVSReplicationConfig{SourceDatacenter: "dc2", Prefix: "app/", DestPrefix: "dc2/app/"},
+		}
+		c.KVSReplicationInterval = 0
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	getR := structs.DCSpecificRequest{
+		Datacenter: "dc1",
+	}
+	testutil.WaitForResult(func() (bool, error) {
+		var statuses structs.KVSReplicationStatuses
+		if err := msgpackrpc.CallWithCodec(codec, "KVS.ReplicationStatus", &getR, &statuses); err != nil {
+			return false, err
+		}
+		if len(statuses) != 1 {
+			return false, fmt.Errorf("bad: %#v", statuses)
+		}
+		status := statuses[0]
+		if !status.Running || status.SourceDatacenter != "dc2" ||
+			status.Prefix != "app/" || status.DestPrefix != "dc2/app/" {
+			return false, fmt.Errorf("bad: %#v", status)
+		}
+		return true, nil
+	}, func(err error) {
+		t.Fatalf("err: %v", err)
+	})
+}
@@ -0,0 +1,224 @@
+package consul
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/consul/structs"
+)
+
+// kvsHistorySample ties a Raft index to the time the leader saw it. History
+// windows are given in time but versions are tracked by index, so these are
+// used to turn a window into an index to prune before.
+type kvsHistorySample struct {
+	time  time.Time
+	index uint64
+}
+
+// kvsHistoryUncoveredWindow is how long the versions of keys that are no
+// longer covered by a history rule are kept after they were replaced. This
+// gives operators a chance to put back a rule that was changed by mistake
+// before its history is lost.
+const kvsHistoryUncoveredWindow = 72 * time.Hour
+
+// initializeKVSHistory is used when a leader is newly elected to seed the KV
+// history rules from our configuration. The rules are managed through the
+// Operator endpoint once they've been set, so the configuration is only used
+// if no rules have been stored yet. This way a server with a different
+// configuration can't change the rules just by being elected.
+func (s *Server) initializeKVSHistory() error {
+	// The samples taken during a previous term may be stale, so start
+	// over. This means versions may be kept a little longer than their
+	// window after a leader election.
+	s.kvsHistorySamples = nil
+
+	state := s.fsm.State()
+	idx, rules, err := state.KVSHistoryRules()
+	if err != nil {
+		return err
+	}
+	if idx != 0 || len(rules) != 0 {
+		if !kvsHistoryRulesEqual(rules, s.config.KVSHistoryRules) {
+			s.logger.Printf("[WARN] consul: KV history rules differ from the configured rules, which are only used to seed them")
+		}
+		return nil
+	}
+	if len(s.config.KVSHistoryRules) == 0 {
+		return nil
+	}
+
+	req := structs.KVSHistoryRequest{
+		Datacenter: s.config.Datacenter,
+		Op:         structs.KVSHistorySetRules,
+		Rules:      s.config.KVSHistoryRules,
+	}
+	resp, err := s.raftApply(structs.KVSHistoryRequestType, &req)
+	if err != nil {
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	s.logger.Printf("[INFO] consul: seeded KV history rules from configuration")
+	return nil
+}
+
+// validateKVSHistoryRules makes sure the given rules are sane, with a single
+// rule for each prefix.
+func validateKVSHistoryRules(rules structs.KVSHistoryRules) error {
+	prefixes := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if rule == nil {
+			return fmt.Errorf("KV history rules can't be empty")
+		}
+		if _, ok := prefixes[rule.Prefix]; ok {
+			return fmt.Errorf("KV history rule for prefix %q is defined more than once", rule.Prefix)
+		}
+		prefixes[rule.Prefix] = struct{}{}
+
+		if rule.Versions < 0 {
+			return fmt.Errorf("KV history versions for prefix %q must be >= 0", rule.Prefix)
+		}
+		if rule.Window < 0 {
+			return fmt.Errorf("KV history window for prefix %q must be >= 0", rule.Prefix)
+		}
+	}
+	return nil
+}
+
+// kvsHistoryRulesEqual returns true if the given sets of rules are the same,
+// ignoring their order.
+func kvsHistoryRulesEqual(a, b structs.KVSHistoryRules) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	byPrefix := make(map[string]*structs.KVSHistoryRule, len(a))
+	for _, rule := range a {
+		byPrefix[rule.Prefix] = rule
+	}
+	for _, rule := range b {
+		other, ok := byPrefix[rule.Prefix]
+		if !ok || *other != *rule {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneKVSHistory is invoked periodically by the leader to drop the prior
+// versions of keys that have been replaced for longer than their rule's
+// window.
+func (s *Server) pruneKVSHistory() error {
+	now := time.Now()
+	s.kvsHistorySamples = append(s.kvsHistorySamples, kvsHistorySample{now, s.raft.LastIndex()})
+
+	state := s.fsm.State()
+	_, rules, err := state.KVSHistoryRules()
+	if err != nil {
+		return err
+	}
+
+	maxWindow := kvsHistoryUncoveredWindow
+	for _, rule := range rules {
+		if rule.Window <= 0 {
+			continue
+		}
+		if rule.Window > maxWindow {
+			maxWindow = rule.Window
+		}
+
+		// Anything replaced before the newest index we saw at least a
+		// window ago has been replaced for at least that long.
+		cutoff := s.kvsHistoryCutoff(now.Add(-rule.Window))
+		if cutoff == 0 {
+			continue
+		}
+		if err := s.pruneKVSHistoryRule(rule.Prefix, cutoff); err != nil {
+			return err
+		}
+	}
+
+	// Versions that were left behind when the rules changed get a window
+	// of their own.
+	if cutoff := s.kvsHistoryCutoff(now.Add(-kvsHistoryUncoveredWindow)); cutoff != 0 {
+		if err := s.pruneKVSHistoryUncovered(cutoff); err != nil {
+			return err
+		}
+	}
+
+	// Drop the samples that are too old to matter for any window, keeping
+	// the newest of them since it's the cutoff for the longest window.
+	horizon := now.Add(-maxWindow)
+	for len(s.kvsHistorySamples) > 1 && !s.kvsHistorySamples[1].time.After(horizon) {
+		s.kvsHistorySamples = s.kvsHistorySamples[1:]
+	}
+	return nil
+}
+
+// kvsHistoryCutoff returns the newest index that was seen no later than the
+// given time, or zero if there isn't one.
+func (s *Server) kvsHistoryCutoff(before time.Time) uint64 {
+	var cutoff uint64
+	for _, sample := range s.kvsHistorySamples {
+		if sample.time.After(before) {
+			break
+		}
+		cutoff = sample.index
+	}
+	return cutoff
+}
+
+// pruneKVSHistoryRule drops the versions kept by the rule with the given
+// prefix that were replaced before the given index. It only goes through
+// Raft if there's something to drop.
+func (s *Server) pruneKVSHistoryRule(prefix string, index uint64) error {
+	state := s.fsm.State()
+	prunable, err := state.KVSHistoryPrunable(prefix, index)
+	if err != nil {
+		return err
+	}
+	if !prunable {
+		return nil
+	}
+
+	return s.applyKVSHistoryPrune(&structs.KVSHistoryRequest{
+		Datacenter: s.config.Datacenter,
+		Op:         structs.KVSHistoryPrune,
+		Prefix:     prefix,
+		Index:      index,
+	})
+}
+
+// pruneKVSHistoryUncovered drops the versions of keys that aren't covered
+// by a history rule that were replaced before the given index. It only goes
+// through Raft if there's something to drop.
+func (s *Server) pruneKVSHistoryUncovered(index uint64) error {
+	state := s.fsm.State()
+	prunable, err := state.KVSHistoryUncoveredPrunable(index)
+	if err != nil {
+		return err
+	}
+	if !prunable {
+		return nil
+	}
+
+	return s.applyKVSHistoryPrune(&structs.KVSHistoryRequest{
+		Datacenter: s.config.Datacenter,
+		Op:         structs.KVSHistoryPruneUncovered,
+		Index:      index,
+	})
+}
+
+// applyKVSHistoryPrune applies the given prune request through Raft.
+func (s *Server) applyKVSHistoryPrune(req *structs.KVSHistoryRequest) error {
+	defer metrics.MeasureSince([]string{"consul", "kvs_history", "prune"}, time.Now())
+	resp, err := s.raftApply(structs.KVSHistoryRequestType, req)
+	if err != nil {
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package consul
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/consul/structs"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+)
+
+func TestInitializeKVSHistory(t *testing.T) {
+	rules := structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: "foo/", Versions: 3},
+		&structs.KVSHistoryRule{Prefix: "bar/", Window: time.Hour},
+	}
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.KVSHistoryRules = rules
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// The leader should have seeded the rules
+	state := s1.fsm.State()
+	idx, out, err := state.KVSHistoryRules()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !kvsHistoryRulesEqual(out, rules) {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// Doing it again is a no-op
+	if err := s1.initializeKVSHistory(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	idx2, _, err := state.KVSHistoryRules()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if idx2 != idx {
+		t.Fatalf("bad index: %d != %d", idx2, idx)
+	}
+
+	// Once the rules are set, a leader with a different config leaves
+	// them alone, even if the stored rules are empty
+	for _, config := range []structs.KVSHistoryRules{nil, rules[:1]} {
+		s1.config.KVSHistoryRules = config
+		if err := s1.initializeKVSHistory(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		_, out, err = state.KVSHistoryRules()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !kvsHistoryRulesEqual(out, rules) {
+			t.Fatalf("bad: %#v", out)
+		}
+	}
+	if err := state.KVSHistorySetRules(idx+1, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s1.config.KVSHistoryRules = rules
+	if err := s1.initializeKVSHistory(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, out, err = state.KVSHistoryRules()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out) != 0 {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
+func TestPruneKVSHistory(t *testing.T) {
+	rules := structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: "foo/", Window: time.Hour},
+		&structs.KVSHistoryRule{Prefix: "foo/bar/", Window: 3 * time.Hour},
+	}
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.KVSHistoryRules = rules
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Write a couple of versions of a key under each rule
+	for _, key := range []string{"foo/a", "foo/a", "foo/bar/b", "foo/bar/b"} {
+		arg := structs.KVSRequest{
+			Datacenter: "dc1",
+			Op:         structs.KVSSet,
+			DirEnt: structs.DirEntry{
+				Key:   key,
+				Value: []byte("test"),
+			},
+		}
+		var out bool
+		if err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Pretend we saw the current index two hours ago
+	s1.kvsHistorySamples = []kvsHistorySample{
+		{time.Now().Add(-2 * time.Hour), s1.raft.LastIndex()},
+	}
+	if err := s1.pruneKVSHistory(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Only the version under the shorter window should be pruned
+	state := s1.fsm.State()
+	_, versions, err := state.KVSHistory("foo/a")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(versions) != 0 {
+		t.Fatalf("bad: %#v", versions)
+	}
+	_, versions, err = state.KVSHistory("foo/bar/b")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// Both samples are still needed for the longer window
+	if len(s1.kvsHistorySamples) != 2 {
+		t.Fatalf("bad: %#v", s1.kvsHistorySamples)
+	}
+
+	// Replacing the rules leaves the version under foo/bar/ behind until
+	// it's been uncovered for long enough
+	rules = structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: "other/", Window: time.Hour},
+	}
+	if err := state.KVSHistorySetRules(s1.raft.LastIndex()+1, rules); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s1.kvsHistorySamples = []kvsHistorySample{
+		{time.Now().Add(-kvsHistoryUncoveredWindow / 2), s1.raft.LastIndex()},
+	}
+	if err := s1.pruneKVSHistory(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, versions, err = state.KVSHistory("foo/bar/b")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	s1.kvsHistorySamples = []kvsHistorySample{
+		{time.Now().Add(-kvsHistoryUncoveredWindow), s1.raft.LastIndex()},
+	}
+	if err := s1.pruneKVSHistory(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, versions, err = state.KVSHistory("foo/bar/b")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(versions) != 0 {
+		t.Fatalf("bad: %#v", versions)
+	}
+}
+
+func TestKVS_History(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.KVSHistoryRules = structs.KVSHistoryRules{
+			&structs.KVSHistoryRule{Prefix: ""},
+		}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Write two versions of a key
+	var indexes []uint64
+	for _, value := range []string{"one", "two"} {
+		arg := structs.KVSRequest{
+			Datacenter: "dc1",
+			Op:         structs.KVSSet,
+			DirEnt: structs.DirEntry{
+				Key:   "test",
+				Value: []byte(value),
+			},
+		}
+		var out bool
+		if err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		_, d, err := s1.fsm.State().KVSGet("test")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		indexes = append(indexes, d.ModifyIndex)
+	}
+
+	// The first version should be in the history
+	getR := structs.KeyRequest{
+		Datacenter: "dc1",
+		Key:        "test",
+	}
+	var history structs.IndexedKVSHistory
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.History", &getR, &history); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if history.Index != indexes[1] {
+		t.Fatalf("bad index: %d", history.Index)
+	}
+	if len(history.Entries) != 1 {
+		t.Fatalf("bad: %v", history)
+	}
+	if e := history.Entries[0]; string(e.Value) != "one" || e.ModifyIndex != indexes[0] || e.ReplacedIndex != indexes[1] {
+		t.Fatalf("bad: %v", e)
+	}
+
+	// Read the key as of the first version
+	getR.AtIndex = indexes[0]
+	var dirent structs.IndexedDirEntries
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.Get", &getR, &dirent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(dirent.Entries) != 1 || string(dirent.Entries[0].Value) != "one" {
+		t.Fatalf("bad: %v", dirent)
+	}
+
+	// Before the key was written there's nothing
+	getR.AtIndex = indexes[0] - 1
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.Get", &getR, &dirent); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(dirent.Entries) != 0 {
+		t.Fatalf("bad: %v", dirent)
+	}
+}
+
+func TestKVS_History_ACLDeny(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+		c.KVSHistoryRules = structs.KVSHistoryRules{
+			&structs.KVSHistoryRule{Prefix: ""},
+		}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	for i := 0; i < 2; i++ {
+		arg := structs.KVSRequest{
+			Datacenter: "dc1",
+			Op:         structs.KVSSet,
+			DirEnt: structs.DirEntry{
+				Key:   "zip",
+				Value: []byte("test"),
+			},
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		var out bool
+		if err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// The anonymous token can't see the history
+	getR := structs.KeyRequest{
+		Datacenter: "dc1",
+		Key:        "zip",
+	}
+	var history structs.IndexedKVSHistory
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.History", &getR, &history); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(history.Entries) != 0 {
+		t.Fatalf("bad: %v", history)
+	}
+
+	// The master token can
+	getR.Token = "root"
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.History", &getR, &history); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(history.Entries) != 1 {
+		t.Fatalf("bad: %v", history)
+	}
+}
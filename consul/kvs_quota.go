@@ -0,0 +1,231 @@
+package consul
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/consul/state"
+	"github.com/hashicorp/consul/consul/structs"
+)
+
+const (
+	// quotaExceeded is the prefix of the errors returned when a write would
+	// take a prefix over one of its KV quotas.
+	quotaExceeded = "Quota exceeded"
+)
+
+// kvsQuotaValue is the state of a key as seen by a kvsQuotaTracker.
+type kvsQuotaValue struct {
+	exists bool
+	size   int
+}
+
+// kvsQuotaTracker checks a series of KV writes against the quotas that
+// apply to the token making them. It keeps track of the writes it has
+// seen, so every operation in a transaction is checked against the usage
+// left by the ones before it.
+type kvsQuotaTracker struct {
+	srv    *Server
+	state  *state.StateStore
+	quotas structs.KVSQuotas
+
+	// usage holds the usage of each quota, indexed the same way as the
+	// quotas. It's loaded the first time a quota is touched.
+	usage []*structs.KVSQuotaUsage
+
+	// values holds the keys written so far, which shadow the state store.
+	values map[string]kvsQuotaValue
+
+	// locked is set while the tracker holds the server's quota lock.
+	locked bool
+}
+
+// newKVSQuotaTracker returns a tracker for writes made with the given token.
+func newKVSQuotaTracker(srv *Server, token string) *kvsQuotaTracker {
+	var quotas structs.KVSQuotas
+	for _, quota := range srv.config.KVSQuotas {
+		if quota.Token == "" || quota.Token == token {
+			quotas = append(quotas, quota)
+		}
+	}
+	return &kvsQuotaTracker{
+		srv:    srv,
+		state:  srv.fsm.State(),
+		quotas: quotas,
+		usage:  make([]*structs.KVSQuotaUsage, len(quotas)),
+		values: make(map[string]kvsQuotaValue),
+	}
+}
+
+// lock keeps other writes from being checked against the quotas until
+// unlock is called. It should be called for every operation before the first
+// call to apply, and held until the writes have been applied, so the usage
+// they were checked against is still current. The lock is only taken, once,
+// if one of the operations writes under a prefix with a quota, so writes to
+// the rest of the KV store don't wait on each other.
+func (t *kvsQuotaTracker) lock(op structs.KVSOp, key string) {
+	if t.locked || !t.covers(op, key) {
+		return
+	}
+	t.srv.kvsQuotaLock.Lock()
+	t.locked = true
+}
+
+// unlock releases the lock taken by lock, if any.
+func (t *kvsQuotaTracker) unlock() {
+	if t.locked {
+		t.srv.kvsQuotaLock.Unlock()
+		t.locked = false
+	}
+}
+
+// covers returns true if the given KV operation writes to a key under any of
+// the quotas. Deleting a tree covers the quotas for prefixes within it, too.
+func (t *kvsQuotaTracker) covers(op structs.KVSOp, key string) bool {
+	if !op.IsWrite() {
+		return false
+	}
+	for _, quota := range t.quotas {
+		if strings.HasPrefix(key, quota.Prefix) ||
+			(op == structs.KVSDeleteTree && strings.HasPrefix(quota.Prefix, key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply checks the given KV operation against the quotas and, if it's
+// allowed, records its effect on their usage.
+func (t *kvsQuotaTracker) apply(op structs.KVSOp, dirEnt *structs.DirEntry) error {
+	if len(t.quotas) == 0 {
+		return nil
+	}
+
+	switch op {
+	case structs.KVSSet, structs.KVSCAS, structs.KVSLock, structs.KVSUnlock:
+		return t.write(dirEnt.Key, kvsQuotaValue{true, len(dirEnt.Value)})
+
+	case structs.KVSDelete, structs.KVSDeleteCAS:
+		return t.write(dirEnt.Key, kvsQuotaValue{})
+
+	case structs.KVSDeleteTree:
+		// Gather every key under the prefix, including the ones written
+		// earlier that aren't in the state store yet.
+		_, entries, err := t.state.KVSList(dirEnt.Key)
+		if err != nil {
+			return err
+		}
+		keys := make(map[string]struct{})
+		for _, entry := range entries {
+			keys[entry.Key] = struct{}{}
+		}
+		for key := range t.values {
+			if strings.HasPrefix(key, dirEnt.Key) {
+				keys[key] = struct{}{}
+			}
+		}
+		for key := range keys {
+			if err := t.write(key, kvsQuotaValue{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// write checks the change of a key to the given value against the quotas
+// covering it. Only changes that add keys or bytes are limited, so a prefix
+// that's over its quota can always be cleaned up.
+func (t *kvsQuotaTracker) write(key string, value kvsQuotaValue) error {
+	prev, err := t.value(key)
+	if err != nil {
+		return err
+	}
+
+	keys := 0
+	if value.exists {
+		keys++
+	}
+	if prev.exists {
+		keys--
+	}
+	bytes := int64(value.size - prev.size)
+
+	// Check every quota before touching any usage, so a rejected write
+	// leaves the tracker as it was.
+	var usages []*structs.KVSQuotaUsage
+	for i, quota := range t.quotas {
+		if !strings.HasPrefix(key, quota.Prefix) {
+			continue
+		}
+		usage, err := t.quotaUsage(i)
+		if err != nil {
+			return err
+		}
+		if value.exists && quota.MaxValueSize > 0 && value.size > quota.MaxValueSize {
+			return fmt.Errorf("%s: value for key %q is %d bytes, over the limit of %d for prefix %q",
+				quotaExceeded, key, value.size, quota.MaxValueSize, quota.Prefix)
+		}
+		if keys > 0 && quota.MaxKeys > 0 && usage.Keys+keys > quota.MaxKeys {
+			return fmt.Errorf("%s: prefix %q is limited to %d keys",
+				quotaExceeded, quota.Prefix, quota.MaxKeys)
+		}
+		if bytes > 0 && quota.MaxBytes > 0 && usage.Bytes+bytes > quota.MaxBytes {
+			return fmt.Errorf("%s: prefix %q is limited to %d bytes of values",
+				quotaExceeded, quota.Prefix, quota.MaxBytes)
+		}
+		usages = append(usages, usage)
+	}
+
+	for _, usage := range usages {
+		usage.Keys += keys
+		usage.Bytes += bytes
+	}
+	t.values[key] = value
+	return nil
+}
+
+// value returns the current state of the given key.
+func (t *kvsQuotaTracker) value(key string) (kvsQuotaValue, error) {
+	if value, ok := t.values[key]; ok {
+		return value, nil
+	}
+	_, entry, err := t.state.KVSGet(key)
+	if err != nil {
+		return kvsQuotaValue{}, err
+	}
+	if entry == nil {
+		return kvsQuotaValue{}, nil
+	}
+	return kvsQuotaValue{true, len(entry.Value)}, nil
+}
+
+// quotaUsage returns the usage of the i-th quota, loading it from the state
+// store if needed.
+func (t *kvsQuotaTracker) quotaUsage(i int) (*structs.KVSQuotaUsage, error) {
+	if t.usage[i] == nil {
+		usage, err := kvsQuotaUsage(t.state, t.quotas[i])
+		if err != nil {
+			return nil, err
+		}
+		t.usage[i] = usage
+	}
+	return t.usage[i], nil
+}
+
+// kvsQuotaUsage returns the current usage of the given quota.
+func kvsQuotaUsage(state *state.StateStore, quota *structs.KVSQuota) (*structs.KVSQuotaUsage, error) {
+	_, keys, bytes, err := state.KVSUsage(quota.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &structs.KVSQuotaUsage{
+		Prefix:       quota.Prefix,
+		TokenScoped:  quota.Token != "",
+		MaxKeys:      quota.MaxKeys,
+		MaxBytes:     quota.MaxBytes,
+		MaxValueSize: quota.MaxValueSize,
+		Keys:         keys,
+		Bytes:        bytes,
+	}, nil
+}
@@ -0,0 +1,163 @@
+package consul
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/consul/structs"
+	"github.com/hashicorp/consul/testutil"
+)
+
+func TestKVSQuotaTracker(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.KVSQuotas = structs.KVSQuotas{
+			&structs.KVSQuota{Prefix: "tmp/", MaxKeys: 3},
+			&structs.KVSQuota{Prefix: "tmp/big/", MaxBytes: 10, MaxValueSize: 6},
+			&structs.KVSQuota{Prefix: "tmp/", Token: "job", MaxKeys: 1},
+		}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	state := s1.fsm.State()
+	if err := state.KVSSet(1, &structs.DirEntry{Key: "tmp/a", Value: []byte("a")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	set := func(key, value string) (structs.KVSOp, *structs.DirEntry) {
+		return structs.KVSSet, &structs.DirEntry{Key: key, Value: []byte(value)}
+	}
+	expectErr := func(err error, what string) {
+		if err == nil || !strings.HasPrefix(err.Error(), quotaExceeded) ||
+			!strings.Contains(err.Error(), what) {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// The key count includes what's in the state store and what was
+	// written earlier, and overwrites don't add keys.
+	tracker := newKVSQuotaTracker(s1, "")
+	if err := tracker.apply(set("tmp/b", "b")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := tracker.apply(set("tmp/a", "aa")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := tracker.apply(set("tmp/c", "c")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expectErr(tracker.apply(set("tmp/d", "d")), "limited to 3 keys")
+
+	// Keys outside the prefix aren't limited.
+	if err := tracker.apply(set("other/d", "d")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Deletes free up room, including a whole tree.
+	if err := tracker.apply(structs.KVSDelete, &structs.DirEntry{Key: "tmp/c"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := tracker.apply(set("tmp/d", "d")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := tracker.apply(structs.KVSDeleteTree, &structs.DirEntry{Key: "tmp/"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, key := range []string{"tmp/e", "tmp/f", "tmp/g"} {
+		if err := tracker.apply(set(key, "")); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Byte limits are checked against the total and each value.
+	tracker = newKVSQuotaTracker(s1, "")
+	expectErr(tracker.apply(set("tmp/big/a", "1234567")), "is 7 bytes")
+	if err := tracker.apply(set("tmp/big/a", "123456")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expectErr(tracker.apply(set("tmp/big/b", "12345")), "limited to 10 bytes")
+	if err := tracker.apply(set("tmp/big/a", "1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := tracker.apply(set("tmp/big/b", "12345")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Token-scoped quotas only apply to their token, and are checked
+	// along with the other quotas.
+	tracker = newKVSQuotaTracker(s1, "job")
+	expectErr(tracker.apply(set("tmp/b", "b")), "limited to 1 keys")
+	if err := tracker.apply(set("tmp/a", "changed")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Reads and checks don't count.
+	tracker = newKVSQuotaTracker(s1, "job")
+	if err := tracker.apply(structs.KVSCheckIndex, &structs.DirEntry{Key: "tmp/b"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := tracker.apply(structs.KVSGetTree, &structs.DirEntry{Key: "tmp/"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestKVSQuotaTracker_Lock(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.KVSQuotas = structs.KVSQuotas{
+			&structs.KVSQuota{Prefix: "tmp/", MaxKeys: 3},
+			&structs.KVSQuota{Prefix: "other/", Token: "job", MaxKeys: 1},
+		}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	// Only writes under a prefix with a quota for the token are covered.
+	tracker := newKVSQuotaTracker(s1, "")
+	cases := []struct {
+		op      structs.KVSOp
+		key     string
+		covered bool
+	}{
+		{structs.KVSSet, "tmp/a", true},
+		{structs.KVSDelete, "tmp/a", true},
+		{structs.KVSGet, "tmp/a", false},
+		{structs.KVSCheckIndex, "tmp/a", false},
+		{structs.KVSSet, "tmpfoo", false},
+		{structs.KVSSet, "other/a", false},
+		{structs.KVSDeleteTree, "tmp/sub/", true},
+		{structs.KVSDeleteTree, "t", true},
+		{structs.KVSDeleteTree, "", true},
+		{structs.KVSDeleteTree, "foo/", false},
+	}
+	for i, c := range cases {
+		if covered := tracker.covers(c.op, c.key); covered != c.covered {
+			t.Fatalf("case %d: bad: %v", i, covered)
+		}
+	}
+
+	// Writes that aren't covered don't take the lock, so they don't wait
+	// behind writes under a quota.
+	s1.kvsQuotaLock.Lock()
+	tracker.lock(structs.KVSSet, "foo")
+	if tracker.locked {
+		t.Fatalf("should not be locked")
+	}
+	s1.kvsQuotaLock.Unlock()
+
+	// A covered write takes it once, no matter how many operations are
+	// covered.
+	tracker.lock(structs.KVSSet, "tmp/a")
+	tracker.lock(structs.KVSSet, "tmp/b")
+	if !tracker.locked {
+		t.Fatalf("should be locked")
+	}
+	tracker.unlock()
+	tracker.unlock()
+	if tracker.locked {
+		t.Fatalf("should not be locked")
+	}
+	s1.kvsQuotaLock.Lock()
+	s1.kvsQuotaLock.Unlock()
+}
@@ -0,0 +1,302 @@
+package consul
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/consul/structs"
+	"github.com/hashicorp/consul/lib"
+)
+
+// dirEntrySorter sorts a list of KV entries by key.
+type dirEntrySorter structs.DirEntries
+
+// See sort.Interface.
+func (d dirEntrySorter) Len() int {
+	return len(d)
+}
+
+// See sort.Interface.
+func (d dirEntrySorter) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+}
+
+// See sort.Interface.
+func (d dirEntrySorter) Less(i, j int) bool {
+	return d[i].Key < d[j].Key
+}
+
+// reconcileKVs takes the local entries under the destination prefix and the
+// remote entries under the source prefix, and produces a list of changes
+// required in order to bring the local entries into sync with the remote
+// ones. Remote keys are moved from the source prefix to the destination
+// prefix before they are compared. As with reconcileACLs, lastRemoteIndex is
+// a hint that replication has succeeded up to that remote index, and setting
+// it to 0 will force a full compare of all the entries.
+func reconcileKVs(local, remote structs.DirEntries, prefix, destPrefix string, lastRemoteIndex uint64) []*structs.KVSRequest {
+	// Rewrite the remote keys into the local key space. We make copies so
+	// we don't disturb the caller's entries.
+	moved := make(structs.DirEntries, 0, len(remote))
+	for _, entry := range remote {
+		if !strings.HasPrefix(entry.Key, prefix) {
+			continue
+		}
+		clone := entry.Clone()
+		clone.Key = destPrefix + strings.TrimPrefix(entry.Key, prefix)
+		moved = append(moved, clone)
+	}
+
+	// Sorting is crucial for correctness, so we make sure things are sorted
+	// even though they should be already, since the remote entries come from
+	// other servers.
+	sorted := make(structs.DirEntries, len(local))
+	copy(sorted, local)
+	sort.Sort(dirEntrySorter(sorted))
+	sort.Sort(dirEntrySorter(moved))
+
+	set := func(entry *structs.DirEntry) *structs.KVSRequest {
+		return &structs.KVSRequest{
+			Op: structs.KVSSet,
+			DirEnt: structs.DirEntry{
+				Key:   entry.Key,
+				Flags: entry.Flags,
+				Value: entry.Value,
+			},
+		}
+	}
+	del := func(entry *structs.DirEntry) *structs.KVSRequest {
+		return &structs.KVSRequest{
+			Op: structs.KVSDelete,
+			DirEnt: structs.DirEntry{
+				Key: entry.Key,
+			},
+		}
+	}
+
+	// Run through both lists and reconcile them.
+	var changes []*structs.KVSRequest
+	l, r := 0, 0
+	for l < len(sorted) || r < len(moved) {
+		switch {
+		// If the local list is exhausted or is ahead, the remote side has
+		// a key we don't, so we add it.
+		case l == len(sorted) || (r < len(moved) && sorted[l].Key > moved[r].Key):
+			changes = append(changes, set(moved[r]))
+			r++
+
+		// If the remote list is exhausted or is ahead, we have a key the
+		// remote side doesn't, so we delete it.
+		case r == len(moved) || sorted[l].Key < moved[r].Key:
+			changes = append(changes, del(sorted[l]))
+			l++
+
+		// Both sides have the key, so we might need to compare them.
+		default:
+			lent, rent := sorted[l], moved[r]
+			if rent.ModifyIndex > lastRemoteIndex &&
+				(lent.Flags != rent.Flags || !bytes.Equal(lent.Value, rent.Value)) {
+				changes = append(changes, set(rent))
+			}
+			l++
+			r++
+		}
+	}
+	return changes
+}
+
+// fetchLocalKVs returns the entries under the given prefix in the local
+// state store.
+func (s *Server) fetchLocalKVs(prefix string) (structs.DirEntries, error) {
+	_, local, err := s.fsm.State().KVSList(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return local, nil
+}
+
+// fetchRemoteKVs is used to get the remote entries for a replicated prefix
+// from its source datacenter. The lastIndex parameter is a hint about which
+// remote index we have replicated to, so this is expected to block until
+// something changes.
+func (s *Server) fetchRemoteKVs(config *KVSReplicationConfig, lastRemoteIndex uint64) (*structs.IndexedDirEntries, error) {
+	defer metrics.MeasureSince([]string{"consul", "leader", "fetchRemoteKVs"}, time.Now())
+
+	args := structs.KeyRequest{
+		Datacenter: config.SourceDatacenter,
+		Key:        config.Prefix,
+		QueryOptions: structs.QueryOptions{
+			Token:         config.Token,
+			MinQueryIndex: lastRemoteIndex,
+			AllowStale:    true,
+		},
+	}
+	var remote structs.IndexedDirEntries
+	if err := s.RPC("KVS.List", &args, &remote); err != nil {
+		return nil, err
+	}
+	return &remote, nil
+}
+
+// updateLocalKVs is given a list of changes to apply in order to bring the
+// local entries in-line with the remote entries from the source datacenter.
+func (s *Server) updateLocalKVs(changes []*structs.KVSRequest) error {
+	defer metrics.MeasureSince([]string{"consul", "leader", "updateLocalKVs"}, time.Now())
+
+	minTimePerOp := time.Second / time.Duration(s.config.KVSReplicationApplyLimit)
+	for _, change := range changes {
+		// As with ACL replication, each change is applied on its own. If
+		// an apply fails in the middle, the next replication pass will
+		// do a full sync and clean up.
+		start := time.Now()
+		change.Datacenter = s.config.Datacenter
+		resp, err := s.raftApply(structs.KVSRequestType, change)
+		if err != nil {
+			return err
+		}
+		if respErr, ok := resp.(error); ok {
+			return respErr
+		}
+
+		// A local key may have had a TTL, which the replicated write
+		// removes.
+		if err := s.resetKVSTimer(change.DirEnt.Key, nil); err != nil {
+			s.logger.Printf("[ERR] consul: Failed to reset TTL for %q: %v", change.DirEnt.Key, err)
+		}
+
+		// Do a smooth rate limit to wait out the min time allowed for
+		// each op. If this op took longer than the min, then the sleep
+		// time will be negative and we will just move on.
+		elapsed := time.Now().Sub(start)
+		time.Sleep(minTimePerOp - elapsed)
+	}
+	return nil
+}
+
+// replicateKVs runs one pass of the algorithm for replicating a prefix from
+// its source datacenter to local state. If there's any error, this will
+// return 0 for the lastRemoteIndex, which will cause us to immediately do a
+// full sync next time.
+func (s *Server) replicateKVs(config *KVSReplicationConfig, lastRemoteIndex uint64) (uint64, error) {
+	remote, err := s.fetchRemoteKVs(config, lastRemoteIndex)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve remote keys: %v", err)
+	}
+
+	// This will be pretty common because we will be blocking for a long time
+	// and may have lost leadership, so lets control the message here instead
+	// of returning deeper error messages from from Raft.
+	if !s.IsLeader() {
+		return 0, fmt.Errorf("no longer cluster leader")
+	}
+
+	// Measure everything after the remote query, which can block for long
+	// periods of time.
+	defer metrics.MeasureSince([]string{"consul", "leader", "replicateKVs"}, time.Now())
+
+	local, err := s.fetchLocalKVs(config.DestPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve local keys: %v", err)
+	}
+
+	// If the remote index ever goes backwards, it's a good indication that
+	// the remote side was rebuilt and we should do a full sync.
+	if remote.QueryMeta.Index < lastRemoteIndex {
+		s.logger.Printf("[WARN] consul: KV replication of %q remote index moved backwards (%d to %d), forcing a full sync", config.Prefix, lastRemoteIndex, remote.QueryMeta.Index)
+		lastRemoteIndex = 0
+	}
+
+	changes := reconcileKVs(local, remote.Entries, config.Prefix, config.DestPrefix, lastRemoteIndex)
+	if err := s.updateLocalKVs(changes); err != nil {
+		return 0, fmt.Errorf("failed to sync KV changes: %v", err)
+	}
+	return remote.QueryMeta.Index, nil
+}
+
+// updateKVSReplicationStatus safely updates the status of the i-th
+// replicated prefix.
+func (s *Server) updateKVSReplicationStatus(i int, status structs.KVSReplicationStatus) {
+	// Fixup the times to shed some useless precision to ease formattting,
+	// and always report UTC.
+	status.LastError = status.LastError.Round(time.Second).UTC()
+	status.LastSuccess = status.LastSuccess.Round(time.Second).UTC()
+
+	s.kvsReplicationStatusLock.Lock()
+	s.kvsReplicationStatus[i] = status
+	s.kvsReplicationStatusLock.Unlock()
+}
+
+// runKVSReplication is a long-running goroutine that will attempt to
+// replicate the i-th configured prefix while the server is the leader,
+// until the shutdown channel closes. It works the same way as
+// runACLReplication.
+func (s *Server) runKVSReplication(i int, config *KVSReplicationConfig) {
+	var status structs.KVSReplicationStatus
+	status.SourceDatacenter = config.SourceDatacenter
+	status.Prefix = config.Prefix
+	status.DestPrefix = config.DestPrefix
+	s.updateKVSReplicationStatus(i, status)
+
+	// Show that it's not running on the way out.
+	defer func() {
+		status.Running = false
+		s.updateKVSReplicationStatus(i, status)
+	}()
+
+	// Give each replicator a random initial phase for good measure.
+	select {
+	case <-s.shutdownCh:
+		return
+
+	case <-time.After(lib.RandomStagger(s.config.KVSReplicationInterval)):
+	}
+
+	var lastRemoteIndex uint64
+	replicate := func() {
+		if !status.Running {
+			lastRemoteIndex = 0 // Re-sync everything.
+			status.Running = true
+			s.updateKVSReplicationStatus(i, status)
+			s.logger.Printf("[INFO] consul: KV replication of %q from %s started", config.Prefix, config.SourceDatacenter)
+		}
+
+		index, err := s.replicateKVs(config, lastRemoteIndex)
+		if err != nil {
+			lastRemoteIndex = 0 // Re-sync everything.
+			status.LastError = time.Now()
+			s.updateKVSReplicationStatus(i, status)
+			s.logger.Printf("[WARN] consul: KV replication of %q error (will retry if still leader): %v", config.Prefix, err)
+		} else {
+			lastRemoteIndex = index
+			status.ReplicatedIndex = index
+			status.LastSuccess = time.Now()
+			s.updateKVSReplicationStatus(i, status)
+			s.logger.Printf("[DEBUG] consul: KV replication of %q completed through remote index %d", config.Prefix, index)
+		}
+	}
+	pause := func() {
+		if status.Running {
+			lastRemoteIndex = 0 // Re-sync everything.
+			status.Running = false
+			s.updateKVSReplicationStatus(i, status)
+			s.logger.Printf("[INFO] consul: KV replication of %q stopped (no longer leader)", config.Prefix)
+		}
+	}
+
+	for {
+		select {
+		case <-s.shutdownCh:
+			return
+
+		case <-time.After(s.config.KVSReplicationInterval):
+			if s.IsLeader() {
+				replicate()
+			} else {
+				pause()
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package consul
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/consul/consul/structs"
+	"github.com/hashicorp/consul/testutil"
+)
+
+func TestKVSReplication_reconcileKVs(t *testing.T) {
+	entry := func(key, value string, index uint64) *structs.DirEntry {
+		return &structs.DirEntry{
+			Key:   key,
+			Value: []byte(value),
+			RaftIndex: structs.RaftIndex{
+				ModifyIndex: index,
+			},
+		}
+	}
+	parse := func(changes []*structs.KVSRequest) []string {
+		var out []string
+		for _, change := range changes {
+			out = append(out, fmt.Sprintf("%s %s=%s", change.Op, change.DirEnt.Key, change.DirEnt.Value))
+		}
+		return out
+	}
+
+	// Remote entries are out of order to make sure they get sorted.
+	local := structs.DirEntries{
+		entry("dc2/app/a", "1", 5),
+		entry("dc2/app/b", "old", 6),
+		entry("dc2/app/c", "3", 7),
+		entry("dc2/app/e", "5", 8),
+	}
+	remote := structs.DirEntries{
+		entry("app/d", "4", 12),
+		entry("app/b", "2", 11),
+		entry("app/a", "1", 10),
+		entry("app/e", "changed", 3),
+	}
+
+	// A full sync picks up every difference.
+	changes := reconcileKVs(local, remote, "app/", "dc2/app/", 0)
+	expected := []string{
+		"set dc2/app/b=2",
+		"delete dc2/app/c=",
+		"set dc2/app/d=4",
+		"set dc2/app/e=changed",
+	}
+	if got := parse(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	// The last remote index skips entries that haven't changed since the
+	// last pass.
+	changes = reconcileKVs(local, remote, "app/", "dc2/app/", 10)
+	expected = []string{
+		"set dc2/app/b=2",
+		"delete dc2/app/c=",
+		"set dc2/app/d=4",
+	}
+	if got := parse(changes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	// Flags count as a difference.
+	flagged := entry("app/a", "1", 10)
+	flagged.Flags = 42
+	changes = reconcileKVs(local[:1], structs.DirEntries{flagged}, "app/", "dc2/app/", 0)
+	if len(changes) != 1 || changes[0].DirEnt.Flags != 42 {
+		t.Fatalf("bad: %v", parse(changes))
+	}
+
+	// The caller's entries are left alone.
+	if remote[0].Key != "app/d" || local[1].Key != "dc2/app/b" {
+		t.Fatalf("bad: %v %v", remote, local)
+	}
+
+	// Nothing to do when both sides are empty.
+	if changes := reconcileKVs(nil, nil, "app/", "dc2/app/", 0); len(changes) != 0 {
+		t.Fatalf("bad: %v", parse(changes))
+	}
+}
+
+func TestKVSReplication(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	dir2, s2 := testServerWithConfig(t, func(c *Config) {
+		c.Datacenter = "dc2"
+		c.KVSReplication = []*KVSReplicationConfig{
+			&KVSReplicationConfig{SourceDatacenter: "dc1", Prefix: "app/", DestPrefix: "dc1/app/"},
+		}
+		c.KVSReplicationInterval = 0
+		c.KVSReplicationApplyLimit = 1000000
+	})
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+
+	// Try to join.
+	addr := fmt.Sprintf("127.0.0.1:%d",
+		s1.config.SerfWANConfig.MemberlistConfig.BindPort)
+	if _, err := s2.JoinWAN([]string{addr}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+	testutil.WaitForLeader(t, s1.RPC, "dc2")
+
+	apply := func(srv *Server, dc string, op structs.KVSOp, key, value string) {
+		arg := structs.KVSRequest{
+			Datacenter: dc,
+			Op:         op,
+			DirEnt: structs.DirEntry{
+				Key:   key,
+				Value: []byte(value),
+			},
+		}
+		var out bool
+		if err := srv.RPC("KVS.Apply", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Write some keys in and out of the replicated prefix, and a stray
+	// local key that should be cleaned up.
+	for i := 0; i < 100; i++ {
+		apply(s1, "dc1", structs.KVSSet, fmt.Sprintf("app/%d", i), fmt.Sprintf("v%d", i))
+	}
+	apply(s1, "dc1", structs.KVSSet, "other/key", "nope")
+	apply(s2, "dc2", structs.KVSSet, "dc1/app/stray", "stray")
+
+	checkSame := func() (bool, error) {
+		index, remote, err := s1.fsm.State().KVSList("app/")
+		if err != nil {
+			return false, err
+		}
+		_, local, err := s2.fsm.State().KVSList("dc1/app/")
+		if err != nil {
+			return false, err
+		}
+		if len(remote) != len(local) {
+			return false, nil
+		}
+		for i, entry := range remote {
+			if local[i].Key != "dc1/"+entry.Key || !bytes.Equal(local[i].Value, entry.Value) {
+				return false, nil
+			}
+		}
+
+		var status structs.KVSReplicationStatus
+		s2.kvsReplicationStatusLock.RLock()
+		status = s2.kvsReplicationStatus[0]
+		s2.kvsReplicationStatusLock.RUnlock()
+		if !status.Running || status.ReplicatedIndex < index ||
+			status.SourceDatacenter != "dc1" {
+			return false, nil
+		}
+
+		return true, nil
+	}
+
+	// Wait for the replica to converge.
+	testutil.WaitForResult(checkSame, func(err error) {
+		t.Fatalf("keys didn't converge")
+	})
+
+	// Keys outside the prefix aren't copied.
+	if _, e, err := s2.fsm.State().KVSGet("dc1/other/key"); err != nil || e != nil {
+		t.Fatalf("bad: %v %v", e, err)
+	}
+	if _, e, err := s2.fsm.State().KVSGet("other/key"); err != nil || e != nil {
+		t.Fatalf("bad: %v %v", e, err)
+	}
+
+	// Update and delete some keys.
+	apply(s1, "dc1", structs.KVSSet, "app/1", "changed")
+	apply(s1, "dc1", structs.KVSDelete, "app/2", "")
+	apply(s1, "dc1", structs.KVSDeleteTree, "app/5", "")
+
+	// Wait for the replica to converge.
+	testutil.WaitForResult(checkSame, func(err error) {
+		t.Fatalf("keys didn't converge")
+	})
+	_, e, err := s2.fsm.State().KVSGet("dc1/app/1")
+	if err != nil || e == nil || string(e.Value) != "changed" {
+		t.Fatalf("bad: %v %v", e, err)
+	}
+}
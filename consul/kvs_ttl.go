@@ -0,0 +1,170 @@
+package consul
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/consul/structs"
+)
+
+// kvsTimer tracks the expiration of a KV entry as of the index it was
+// last written at.
+type kvsTimer struct {
+	timer *time.Timer
+	index uint64
+}
+
+// initializeKVSTimers is used when a leader is newly elected to create
+// a new map to track KV entry expiration and to reset all the timers from
+// the previously known set of entries.
+func (s *Server) initializeKVSTimers() error {
+	// Scan all the KV entries and reset the timers of those with a TTL
+	state := s.fsm.State()
+	_, entries, err := state.KVSList("")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.TTL == "" {
+			continue
+		}
+		if err := s.resetKVSTimer(entry.Key, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetKVSTimer is used to restart the TTL of a KV entry after it's been
+// written. The entry will be faulted in if not given. If the entry no
+// longer exists or has no TTL, any timer for the key is cleared, and if
+// it hasn't been written since its timer was started, the timer is left
+// alone.
+func (s *Server) resetKVSTimer(key string, entry *structs.DirEntry) error {
+	// Fault the entry in if not given
+	if entry == nil {
+		state := s.fsm.State()
+		_, e, err := state.KVSGet(key)
+		if err != nil {
+			return err
+		}
+		entry = e
+	}
+
+	// Clear the timer if the entry is gone or has no TTL, fast-path some
+	// common inputs
+	if entry == nil {
+		return s.clearKVSTimer(key)
+	}
+	switch entry.TTL {
+	case "", "0", "0s", "0m", "0h":
+		return s.clearKVSTimer(key)
+	}
+
+	// Parse the TTL, and skip if zero time
+	ttl, err := time.ParseDuration(entry.TTL)
+	if err != nil {
+		return fmt.Errorf("Invalid KV TTL '%s': %v", entry.TTL, err)
+	}
+	if ttl == 0 {
+		return s.clearKVSTimer(key)
+	}
+
+	// Reset the KV timer
+	s.kvsTimersLock.Lock()
+	defer s.kvsTimersLock.Unlock()
+	s.resetKVSTimerLocked(key, entry.ModifyIndex, ttl)
+	return nil
+}
+
+// resetKVSTimerLocked is used to reset a KV timer assuming the
+// kvsTimersLock is already held
+func (s *Server) resetKVSTimerLocked(key string, index uint64, ttl time.Duration) {
+	// Ensure a timer map exists
+	if s.kvsTimers == nil {
+		s.kvsTimers = make(map[string]*kvsTimer)
+	}
+
+	// Replace any existing timer rather than resetting it, since the new
+	// timer needs to expire the entry as of its latest modify index.
+	if existing, ok := s.kvsTimers[key]; ok {
+		if existing.index == index {
+			return
+		}
+		existing.timer.Stop()
+	}
+
+	// Create a new timer to track expiration of this entry
+	timer := time.AfterFunc(ttl, func() {
+		s.expireKVS(key, index)
+	})
+	s.kvsTimers[key] = &kvsTimer{timer, index}
+}
+
+// expireKVS is invoked when a KV entry's TTL is reached and we need to
+// delete it. The delete is a check-and-set on the index the TTL was
+// started at, so an entry that's been written again in the meantime is
+// left alone.
+func (s *Server) expireKVS(key string, index uint64) {
+	defer metrics.MeasureSince([]string{"consul", "kvs_ttl", "expire"}, time.Now())
+
+	// Create a KV delete request
+	args := structs.KVSRequest{
+		Datacenter: s.config.Datacenter,
+		Op:         structs.KVSDeleteCAS,
+		DirEnt: structs.DirEntry{
+			Key: key,
+			RaftIndex: structs.RaftIndex{
+				ModifyIndex: index,
+			},
+		},
+	}
+
+	// Retry with exponential backoff to expire the entry
+	for attempt := uint(0); attempt < maxInvalidateAttempts; attempt++ {
+		_, err := s.raftApply(structs.KVSRequestType, args)
+		if err == nil {
+			s.logger.Printf("[DEBUG] consul.kvs: KV entry %q TTL expired", key)
+
+			// Bring the timer in line with whatever is there now. This
+			// clears it if the entry is gone.
+			if s.IsLeader() {
+				if err := s.resetKVSTimer(key, nil); err != nil {
+					s.logger.Printf("[ERR] consul.kvs: Failed to reset TTL for KV entry %q: %v", key, err)
+				}
+			}
+			return
+		}
+
+		s.logger.Printf("[ERR] consul.kvs: Expiration failed: %v", err)
+		time.Sleep((1 << attempt) * invalidateRetryBase)
+	}
+	s.logger.Printf("[ERR] consul.kvs: maximum expire attempts reached for KV entry: %q", key)
+}
+
+// clearKVSTimer is used to clear the KV timer for a single key. This is
+// used when an entry is deleted or rewritten without a TTL.
+func (s *Server) clearKVSTimer(key string) error {
+	s.kvsTimersLock.Lock()
+	defer s.kvsTimersLock.Unlock()
+
+	if existing, ok := s.kvsTimers[key]; ok {
+		existing.timer.Stop()
+		delete(s.kvsTimers, key)
+	}
+	return nil
+}
+
+// clearAllKVSTimers is used when a leader is stepping down and we no
+// longer need to track any KV timers.
+func (s *Server) clearAllKVSTimers() error {
+	s.kvsTimersLock.Lock()
+	defer s.kvsTimersLock.Unlock()
+
+	for _, t := range s.kvsTimers {
+		t.timer.Stop()
+	}
+	s.kvsTimers = nil
+	return nil
+}
@@ -0,0 +1,223 @@
+package consul
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/consul/structs"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+)
+
+func TestInitializeKVSTimers(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	state := s1.fsm.State()
+	if err := state.KVSSet(100, &structs.DirEntry{Key: "foo", TTL: "10s"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := state.KVSSet(101, &structs.DirEntry{Key: "bar"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Reset the KV timers
+	if err := s1.initializeKVSTimers(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Check that only the entry with a TTL has a timer
+	if timer, ok := s1.kvsTimers["foo"]; !ok || timer.index != 100 {
+		t.Fatalf("bad: %v", timer)
+	}
+	if _, ok := s1.kvsTimers["bar"]; ok {
+		t.Fatalf("should not have KV timer")
+	}
+
+	// Stepping down clears the timers
+	if err := s1.clearAllKVSTimers(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(s1.kvsTimers) != 0 {
+		t.Fatalf("bad: %v", s1.kvsTimers)
+	}
+}
+
+func TestResetKVSTimer_InvalidTTL(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	err := s1.resetKVSTimer("foo", &structs.DirEntry{Key: "foo", TTL: "foo"})
+	if err == nil || !strings.Contains(err.Error(), "Invalid KV TTL") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestResetKVSTimer_SameIndex(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	entry := &structs.DirEntry{
+		Key: "foo",
+		TTL: "10s",
+		RaftIndex: structs.RaftIndex{
+			ModifyIndex: 100,
+		},
+	}
+	if err := s1.resetKVSTimer("foo", entry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	timer := s1.kvsTimers["foo"]
+
+	// Resetting at the same index leaves the timer alone
+	if err := s1.resetKVSTimer("foo", entry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if s1.kvsTimers["foo"] != timer {
+		t.Fatalf("timer should not have been replaced")
+	}
+
+	// A newer write replaces it
+	entry.ModifyIndex = 101
+	if err := s1.resetKVSTimer("foo", entry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if s1.kvsTimers["foo"] == timer || s1.kvsTimers["foo"].index != 101 {
+		t.Fatalf("timer should have been replaced")
+	}
+
+	// Removing the TTL clears it
+	entry.TTL = ""
+	if err := s1.resetKVSTimer("foo", entry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := s1.kvsTimers["foo"]; ok {
+		t.Fatalf("should not have KV timer")
+	}
+}
+
+func TestKVS_Apply_TTL(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Invalid TTLs are rejected
+	arg := structs.KVSRequest{
+		Datacenter: "dc1",
+		Op:         structs.KVSSet,
+		DirEnt: structs.DirEntry{
+			Key:   "test",
+			Value: []byte("test"),
+			TTL:   "nope",
+		},
+	}
+	var out bool
+	err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out)
+	if err == nil || !strings.Contains(err.Error(), "Invalid KV TTL") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Write a key with a TTL, and another that's rewritten without one
+	arg.DirEnt.TTL = "50ms"
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	arg.DirEnt.Key = "keep"
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	arg.DirEnt.TTL = ""
+	if err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The key with the TTL should be deleted
+	state := s1.fsm.State()
+	testutil.WaitForResult(func() (bool, error) {
+		_, d, err := state.KVSGet("test")
+		return d == nil, err
+	}, func(err error) {
+		t.Fatalf("key should have expired")
+	})
+
+	// The rewritten key should be left alone
+	time.Sleep(100 * time.Millisecond)
+	_, d, err := state.KVSGet("keep")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if d == nil || d.TTL != "" {
+		t.Fatalf("bad: %v", d)
+	}
+
+	s1.kvsTimersLock.Lock()
+	num := len(s1.kvsTimers)
+	s1.kvsTimersLock.Unlock()
+	if num != 0 {
+		t.Fatalf("should not have KV timers: %d", num)
+	}
+}
+
+func TestTxn_Apply_TTL(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Invalid TTLs are rejected
+	arg := structs.TxnRequest{
+		Datacenter: "dc1",
+		Ops: structs.TxnOps{
+			&structs.TxnOp{
+				KV: &structs.TxnKVOp{
+					Verb: structs.KVSSet,
+					DirEnt: structs.DirEntry{
+						Key:   "test",
+						Value: []byte("test"),
+						TTL:   "nope",
+					},
+				},
+			},
+		},
+	}
+	var out structs.TxnResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != 1 || !strings.Contains(out.Errors[0].What, "Invalid KV TTL") {
+		t.Fatalf("bad: %v", out)
+	}
+
+	// Write a key with a TTL, which should be kept and then expire
+	arg.Ops[0].KV.DirEnt.TTL = "50ms"
+	out = structs.TxnResponse{}
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != 0 || len(out.Results) != 1 || out.Results[0].KV.TTL != "50ms" {
+		t.Fatalf("bad: %v", out)
+	}
+	state := s1.fsm.State()
+	testutil.WaitForResult(func() (bool, error) {
+		_, d, err := state.KVSGet("test")
+		return d == nil, err
+	}, func(err error) {
+		t.Fatalf("key should have expired")
+	})
+}
@@ -96,6 +96,18 @@ RECONCILE:
 		goto WAIT
 	}
 
+	// Drop any KV history that's aged out. This isn't critical, so a
+	// failure doesn't hold up the reconcile.
+	if err := s.pruneKVSHistory(); err != nil {
+		s.logger.Printf("[ERR] consul: failed to prune KV history: %v", err)
+	}
+
+	// Likewise reap any ACLs that have expired. Lookups already treat
+	// them as missing, so this is just cleanup.
+	if err := s.reapExpiredACLs(); err != nil {
+		s.logger.Printf("[ERR] consul: failed to reap expired ACLs: %v", err)
+	}
+
 	// Initial reconcile worked, now we can process the channel
 	// updates
 	reconcileCh = s.reconcileCh
@@ -152,6 +164,21 @@ func (s *Server) establishLeadership() error {
 			err)
 		return err
 	}
+
+	// Setup the KV timers in the same way, so entries with a TTL are
+	// expired by the new leader.
+	if err := s.initializeKVSTimers(); err != nil {
+		s.logger.Printf("[ERR] consul: KV Timers initialization failed: %v",
+			err)
+		return err
+	}
+
+	// Seed the KV history rules from our configuration if they've never
+	// been set.
+	if err := s.initializeKVSHistory(); err != nil {
+		s.logger.Printf("[ERR] consul: KV history initialization failed: %v", err)
+		return err
+	}
 	return nil
 }
 
@@ -167,6 +194,12 @@ func (s *Server) revokeLeadership() error {
 		s.logger.Printf("[ERR] consul: Clearing session timers failed: %v", err)
 		return err
 	}
+
+	// Likewise for the KV timers.
+	if err := s.clearAllKVSTimers(); err != nil {
+		s.logger.Printf("[ERR] consul: Clearing KV timers failed: %v", err)
+		return err
+	}
 	return nil
 }
 
@@ -237,6 +270,63 @@ func (s *Server) initializeACL() error {
 	return nil
 }
 
+// aclReapBatchSize is the most expired ACLs that are deleted in a single
+// Raft transaction.
+const aclReapBatchSize = 128
+
+// reapExpiredACLs is invoked periodically by the leader to delete any ACLs
+// that have expired. Only the ACL datacenter does this, other datacenters
+// pick up the deletes through replication.
+func (s *Server) reapExpiredACLs() error {
+	authDC := s.config.ACLDatacenter
+	if len(authDC) == 0 || authDC != s.config.Datacenter {
+		return nil
+	}
+
+	now := time.Now()
+	state := s.fsm.State()
+	_, acls, err := state.ACLList()
+	if err != nil {
+		return err
+	}
+	var expired []string
+	for _, acl := range acls {
+		if acl.IsExpired(now) {
+			expired = append(expired, acl.ID)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	defer metrics.MeasureSince([]string{"consul", "leader", "reapExpiredACLs"}, time.Now())
+	for len(expired) > 0 {
+		batch := expired
+		if len(batch) > aclReapBatchSize {
+			batch = batch[:aclReapBatchSize]
+		}
+		expired = expired[len(batch):]
+
+		req := structs.ACLReapRequest{
+			Datacenter: authDC,
+			ACLs:       batch,
+			Time:       now,
+		}
+		resp, err := s.raftApply(structs.ACLReapRequestType, &req)
+		if err != nil {
+			return err
+		}
+		if respErr, ok := resp.(error); ok {
+			return respErr
+		}
+		for _, id := range batch {
+			s.aclAuthCache.ClearACL(id)
+		}
+		s.logger.Printf("[DEBUG] consul: reaped %d expired ACLs", len(batch))
+	}
+	return nil
+}
+
 // reconcile is used to reconcile the differences between Serf
 // membership and what is reflected in our strongly consistent store.
 // Mainly we need to ensure all live nodes are registered, all failed
@@ -381,7 +471,8 @@ func (s *Server) handleAliveMember(member serf.Member) error {
 	if err != nil {
 		return err
 	}
-	if node != nil && node.Address == member.Addr.String() {
+	if node != nil && node.Address == member.Addr.String() &&
+		node.ID == types.NodeID(member.Tags["id"]) {
 		// Check if the associated service is available
 		if service != nil {
 			match := false
@@ -418,6 +509,7 @@ AFTER_CHECK:
 	// Register with the catalog
 	req := structs.RegisterRequest{
 		Datacenter: s.config.Datacenter,
+		ID:         types.NodeID(member.Tags["id"]),
 		Node:       member.Name,
 		Address:    member.Addr.String(),
 		Service:    service,
@@ -499,7 +591,7 @@ func (s *Server) handleDeregisterMember(reason string, member serf.Member) error
 
 	// Remove from Raft peers if this was a server
 	if valid, parts := agent.IsConsulServer(member); valid {
-		if err := s.removeConsulServer(member, parts.Port); err != nil {
+		if err := s.removeConsulServer(member, parts); err != nil {
 			return err
 		}
 	}
@@ -543,24 +635,58 @@ func (s *Server) joinConsulServer(m serf.Member, parts *agent.Server) error {
 		}
 	}
 
-	// TODO (slackpad) - This will need to be changed once we support node IDs.
-	addr := (&net.TCPAddr{IP: m.Addr, Port: parts.Port}).String()
+	// Look at the protocol spoken by all the servers so we know which
+	// membership APIs we can use.
+	minRaftProtocol, err := ServerMinRaftProtocol(s.serfLAN.Members())
+	if err != nil {
+		return err
+	}
 
 	// See if it's already in the configuration. It's harmless to re-add it
 	// but we want to avoid doing that if possible to prevent useless Raft
-	// log entries.
+	// log entries. If the address is the same but the ID changed, remove the
+	// old server before adding the new one.
+	addr := (&net.TCPAddr{IP: m.Addr, Port: parts.Port}).String()
 	configFuture := s.raft.GetConfiguration()
 	if err := configFuture.Error(); err != nil {
 		s.logger.Printf("[ERR] consul: failed to get raft configuration: %v", err)
 		return err
 	}
 	for _, server := range configFuture.Configuration().Servers {
-		if server.Address == raft.ServerAddress(addr) {
+		// Servers are tracked purely by address until everyone speaks
+		// version 3 of the Raft protocol.
+		if server.Address == raft.ServerAddress(addr) && (minRaftProtocol < 3 || parts.RaftVersion < 3) {
 			return nil
 		}
+
+		// If the address or ID matches an existing server, see if we
+		// need to remove the old one first.
+		if server.Address == raft.ServerAddress(addr) || server.ID == raft.ServerID(parts.ID) {
+			// Exit with no-op if this is being called on an existing server.
+			if server.Address == raft.ServerAddress(addr) && server.ID == raft.ServerID(parts.ID) {
+				return nil
+			}
+
+			future := s.raft.RemoveServer(server.ID, 0, 0)
+			if err := future.Error(); err != nil {
+				s.logger.Printf("[ERR] consul: failed to remove stale raft peer '%v' (%v): %v",
+					server.ID, server.Address, err)
+				return err
+			}
+			s.logger.Printf("[INFO] consul: removed stale raft peer '%v' (%v)", server.ID, server.Address)
+		}
 	}
 
 	// Attempt to add as a peer
+	if minRaftProtocol >= 3 {
+		addFuture := s.raft.AddVoter(raft.ServerID(parts.ID), raft.ServerAddress(addr), 0, 0)
+		if err := addFuture.Error(); err != nil {
+			s.logger.Printf("[ERR] consul: failed to add raft peer: %v", err)
+			return err
+		}
+		return nil
+	}
+
 	addFuture := s.raft.AddPeer(raft.ServerAddress(addr))
 	if err := addFuture.Error(); err != nil {
 		s.logger.Printf("[ERR] consul: failed to add raft peer: %v", err)
@@ -570,9 +696,8 @@ func (s *Server) joinConsulServer(m serf.Member, parts *agent.Server) error {
 }
 
 // removeConsulServer is used to try to remove a consul server that has left
-func (s *Server) removeConsulServer(m serf.Member, port int) error {
-	// TODO (slackpad) - This will need to be changed once we support node IDs.
-	addr := (&net.TCPAddr{IP: m.Addr, Port: port}).String()
+func (s *Server) removeConsulServer(m serf.Member, parts *agent.Server) error {
+	addr := (&net.TCPAddr{IP: m.Addr, Port: parts.Port}).String()
 
 	// See if it's already in the configuration. It's harmless to re-remove it
 	// but we want to avoid doing that if possible to prevent useless Raft
@@ -583,19 +708,27 @@ func (s *Server) removeConsulServer(m serf.Member, port int) error {
 		return err
 	}
 	for _, server := range configFuture.Configuration().Servers {
-		if server.Address == raft.ServerAddress(addr) {
-			goto REMOVE
+		// Servers added under version 3 of the Raft protocol are known by
+		// their node ID, older ones by their address.
+		if (parts.ID == "" || server.ID != raft.ServerID(parts.ID)) &&
+			server.Address != raft.ServerAddress(addr) {
+			continue
 		}
-	}
-	return nil
 
-REMOVE:
-	// Attempt to remove as a peer.
-	future := s.raft.RemovePeer(raft.ServerAddress(addr))
-	if err := future.Error(); err != nil {
-		s.logger.Printf("[ERR] consul: failed to remove raft peer '%v': %v",
-			addr, err)
-		return err
+		// Use the ID-based API if our version of the Raft protocol
+		// supports it, otherwise fall back to removing by address.
+		var future raft.Future
+		if s.config.RaftConfig.ProtocolVersion >= 2 {
+			future = s.raft.RemoveServer(server.ID, 0, 0)
+		} else {
+			future = s.raft.RemovePeer(server.Address)
+		}
+		if err := future.Error(); err != nil {
+			s.logger.Printf("[ERR] consul: failed to remove raft peer '%v': %v",
+				server.ID, err)
+			return err
+		}
+		return nil
 	}
 	return nil
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/testutil"
 	"github.com/hashicorp/net-rpc-msgpackrpc"
+	"github.com/hashicorp/raft"
 	"github.com/hashicorp/serf/serf"
 )
 
@@ -43,6 +44,15 @@ func TestLeader_RegisterMember(t *testing.T) {
 		t.Fatalf("client not registered")
 	})
 
+	// Client should be registered with its node ID
+	_, node, err := state.GetNode(c1.config.NodeName)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if node.ID != c1.config.NodeID {
+		t.Fatalf("bad: %v", node)
+	}
+
 	// Should have a check
 	_, checks, err := state.NodeChecks(c1.config.NodeName)
 	if err != nil {
@@ -62,7 +72,7 @@ func TestLeader_RegisterMember(t *testing.T) {
 	}
 
 	// Server should be registered
-	_, node, err := state.GetNode(s1.config.NodeName)
+	_, node, err = state.GetNode(s1.config.NodeName)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -368,6 +378,76 @@ func TestLeader_LeftServer(t *testing.T) {
 	})
 }
 
+func TestLeader_RaftProtocol3_ServerIDs(t *testing.T) {
+	conf := func(c *Config) {
+		c.RaftConfig.ProtocolVersion = 3
+	}
+	dir1, s1 := testServerWithConfig(t, conf)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	dir2, s2 := testServerWithConfig(t, func(c *Config) {
+		conf(c)
+		c.Bootstrap = false
+	})
+	defer os.RemoveAll(dir2)
+	defer s2.Shutdown()
+
+	dir3, s3 := testServerWithConfig(t, func(c *Config) {
+		conf(c)
+		c.Bootstrap = false
+	})
+	defer os.RemoveAll(dir3)
+	defer s3.Shutdown()
+	servers := []*Server{s1, s2, s3}
+
+	// Try to join
+	addr := fmt.Sprintf("127.0.0.1:%d",
+		s1.config.SerfLANConfig.MemberlistConfig.BindPort)
+	if _, err := s2.JoinLAN([]string{addr}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := s3.JoinLAN([]string{addr}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, s := range servers {
+		testutil.WaitForResult(func() (bool, error) {
+			peers, _ := s.numPeers()
+			return peers == 3, nil
+		}, func(err error) {
+			t.Fatalf("should have 3 peers")
+		})
+	}
+
+	// Every server should be tracked by its node ID.
+	future := s1.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ids := make(map[raft.ServerID]bool)
+	for _, server := range future.Configuration().Servers {
+		ids[server.ID] = true
+	}
+	for _, s := range servers {
+		if !ids[raft.ServerID(s.config.NodeID)] {
+			t.Fatalf("missing server %q in %v", s.config.NodeID, future.Configuration())
+		}
+	}
+
+	// Remove a follower and make sure it's removed by its ID.
+	s3.Shutdown()
+	if err := s1.RemoveFailedNode(s3.config.NodeName); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testutil.WaitForResult(func() (bool, error) {
+		peers, _ := s1.numPeers()
+		return peers == 2, fmt.Errorf("%d", peers)
+	}, func(err error) {
+		t.Fatalf("err: %s", err)
+	})
+}
+
 func TestLeader_LeftLeader(t *testing.T) {
 	dir1, s1 := testServer(t)
 	defer os.RemoveAll(dir1)
@@ -616,3 +696,70 @@ func TestLeader_ReapTombstones(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	})
 }
+
+func TestLeader_ReapExpiredACLs(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create enough expired ACLs to need more than one batch, plus one
+	// that expires later. These go straight to Raft since the endpoint
+	// won't take an expiration time in the past.
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	for i := 0; i < aclReapBatchSize+2; i++ {
+		req := structs.ACLRequest{
+			Datacenter: "dc1",
+			Op:         structs.ACLSet,
+			ACL: structs.ACL{
+				ID:             fmt.Sprintf("expired-%d", i),
+				Type:           structs.ACLTypeClient,
+				ExpirationTime: &past,
+			},
+		}
+		if _, err := s1.raftApply(structs.ACLRequestType, &req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	req := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			ID:             "later",
+			Type:           structs.ACLTypeClient,
+			ExpirationTime: &future,
+		},
+	}
+	if _, err := s1.raftApply(structs.ACLRequestType, &req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The expired ACLs can't be used even before they are reaped.
+	if _, err := s1.resolveToken("expired-0"); err == nil || err.Error() != aclNotFound {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Reap, which should leave the anonymous, master and unexpired ACLs.
+	if err := s1.reapExpiredACLs(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	state := s1.fsm.State()
+	_, acls, err := state.ACLList()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(acls) != 3 {
+		t.Fatalf("bad: %#v", acls)
+	}
+	for _, acl := range acls {
+		switch acl.ID {
+		case anonymousToken, "root", "later":
+		default:
+			t.Fatalf("bad: %#v", acl)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 
 	"github.com/hashicorp/consul/consul/agent"
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/raft"
 	"github.com/hashicorp/serf/serf"
@@ -27,7 +28,7 @@ func (op *Operator) RaftGetConfiguration(args *structs.DCSpecificRequest, reply
 		return err
 	}
 	if acl != nil && !acl.OperatorRead() {
-		return permissionDeniedErr
+		return op.srv.auditDenied(permissionDeniedErr, args.Token, "Operator.RaftGetConfiguration", audit.ResourceOperator, "")
 	}
 
 	// We can't fetch the leader and the configuration atomically with
@@ -86,12 +87,13 @@ func (op *Operator) RaftRemovePeerByAddress(args *structs.RaftPeerByAddressReque
 		return err
 	}
 	if acl != nil && !acl.OperatorWrite() {
-		return permissionDeniedErr
+		return op.srv.auditDenied(permissionDeniedErr, args.Token, "Operator.RaftRemovePeerByAddress", audit.ResourceOperator, string(args.Address))
 	}
 
 	// Since this is an operation designed for humans to use, we will return
 	// an error if the supplied address isn't among the peers since it's
 	// likely they screwed up.
+	var id raft.ServerID
 	{
 		future := op.srv.raft.GetConfiguration()
 		if err := future.Error(); err != nil {
@@ -99,6 +101,7 @@ func (op *Operator) RaftRemovePeerByAddress(args *structs.RaftPeerByAddressReque
 		}
 		for _, s := range future.Configuration().Servers {
 			if s.Address == args.Address {
+				id = s.ID
 				goto REMOVE
 			}
 		}
@@ -115,7 +118,15 @@ REMOVE:
 	// doing if you are calling this. If you remove a peer that's known to
 	// Serf, for example, it will come back when the leader does a reconcile
 	// pass.
-	future := op.srv.raft.RemovePeer(args.Address)
+	//
+	// Under version 3 of the Raft protocol servers are tracked by ID, so
+	// we have to use the ID-based API to remove them.
+	var future raft.Future
+	if op.srv.config.RaftConfig.ProtocolVersion >= 3 {
+		future = op.srv.raft.RemoveServer(id, 0, 0)
+	} else {
+		future = op.srv.raft.RemovePeer(args.Address)
+	}
 	if err := future.Error(); err != nil {
 		op.srv.logger.Printf("[WARN] consul.operator: Failed to remove Raft peer %q: %v",
 			args.Address, err)
@@ -123,5 +134,110 @@ REMOVE:
 	}
 
 	op.srv.logger.Printf("[WARN] consul.operator: Removed Raft peer %q", args.Address)
+	if acl != nil {
+		op.srv.auditACL(args.Token, "Operator.RaftRemovePeerByAddress", audit.ResourceOperator,
+			string(args.Address), audit.Allow)
+	}
+	return nil
+}
+
+// KVSHistoryRules is used to list the rules that decide which keys have
+// their prior versions kept.
+func (op *Operator) KVSHistoryRules(args *structs.DCSpecificRequest, reply *structs.IndexedKVSHistoryRules) error {
+	if done, err := op.srv.forward("Operator.KVSHistoryRules", args, args, reply); done {
+		return err
+	}
+
+	// This action requires operator read access.
+	acl, err := op.srv.resolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if acl != nil && !acl.OperatorRead() {
+		return op.srv.auditDenied(permissionDeniedErr, args.Token, "Operator.KVSHistoryRules", audit.ResourceOperator, "")
+	}
+
+	state := op.srv.fsm.State()
+	return op.srv.blockingRPC(
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		state.GetQueryWatch("KVSHistoryRules"),
+		func() error {
+			index, rules, err := state.KVSHistoryRules()
+			if err != nil {
+				return err
+			}
+			reply.Index, reply.Rules = index, rules
+			return nil
+		})
+}
+
+// KVSHistorySetRules is used to replace the rules that decide which keys
+// have their prior versions kept. Versions that have already been kept are
+// left alone, and those that are no longer covered by a rule are pruned
+// once they're older than a fixed window.
+func (op *Operator) KVSHistorySetRules(args *structs.KVSHistoryRequest, reply *struct{}) error {
+	if done, err := op.srv.forward("Operator.KVSHistorySetRules", args, args, reply); done {
+		return err
+	}
+
+	// This action requires operator write access.
+	acl, err := op.srv.resolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if acl != nil && !acl.OperatorWrite() {
+		return op.srv.auditDenied(permissionDeniedErr, args.Token, "Operator.KVSHistorySetRules", audit.ResourceOperator, "")
+	}
+
+	// Pruning is up to the leader, so only the rules can be set here.
+	if args.Op != structs.KVSHistorySetRules {
+		return fmt.Errorf("Invalid KVS history operation '%s'", args.Op)
+	}
+	if err := validateKVSHistoryRules(args.Rules); err != nil {
+		return err
+	}
+
+	resp, err := op.srv.raftApply(structs.KVSHistoryRequestType, args)
+	if err != nil {
+		op.srv.logger.Printf("[ERR] consul.operator: Apply failed: %v", err)
+		return err
+	}
+	if respErr, ok := resp.(error); ok {
+		return respErr
+	}
+
+	if acl != nil {
+		op.srv.auditACL(args.Token, "Operator.KVSHistorySetRules", audit.ResourceOperator, "", audit.Allow)
+	}
+	return nil
+}
+
+// KVSQuotaUsage is used to retrieve the current usage of each configured KV
+// quota.
+func (op *Operator) KVSQuotaUsage(args *structs.DCSpecificRequest, reply *structs.IndexedKVSQuotaUsage) error {
+	if done, err := op.srv.forward("Operator.KVSQuotaUsage", args, args, reply); done {
+		return err
+	}
+
+	// This action requires operator read access.
+	acl, err := op.srv.resolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if acl != nil && !acl.OperatorRead() {
+		return op.srv.auditDenied(permissionDeniedErr, args.Token, "Operator.KVSQuotaUsage", audit.ResourceOperator, "")
+	}
+
+	state := op.srv.fsm.State()
+	reply.Quotas = make([]*structs.KVSQuotaUsage, 0, len(op.srv.config.KVSQuotas))
+	for _, quota := range op.srv.config.KVSQuotas {
+		usage, err := kvsQuotaUsage(state, quota)
+		if err != nil {
+			return err
+		}
+		reply.Quotas = append(reply.Quotas, usage)
+	}
+	op.srv.setQueryMeta(&reply.QueryMeta)
 	return nil
 }
@@ -243,3 +243,142 @@ func TestOperator_RaftRemovePeerByAddress_ACLDeny(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 }
+
+func TestOperator_KVSHistoryRules(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Make a request with no token to make sure it gets denied.
+	arg := structs.KVSHistoryRequest{
+		Datacenter: "dc1",
+		Op:         structs.KVSHistorySetRules,
+		Rules: structs.KVSHistoryRules{
+			&structs.KVSHistoryRule{Prefix: "app/", Versions: 3},
+		},
+	}
+	var reply struct{}
+	err := msgpackrpc.CallWithCodec(codec, "Operator.KVSHistorySetRules", &arg, &reply)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Now it should go through.
+	arg.Token = "root"
+	if err := msgpackrpc.CallWithCodec(codec, "Operator.KVSHistorySetRules", &arg, &reply); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Reading the rules also needs a token.
+	getArg := structs.DCSpecificRequest{
+		Datacenter: "dc1",
+	}
+	var rules structs.IndexedKVSHistoryRules
+	err = msgpackrpc.CallWithCodec(codec, "Operator.KVSHistoryRules", &getArg, &rules)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+	getArg.Token = "root"
+	if err := msgpackrpc.CallWithCodec(codec, "Operator.KVSHistoryRules", &getArg, &rules); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rules.Index == 0 || !reflect.DeepEqual(rules.Rules, arg.Rules) {
+		t.Fatalf("bad: %#v", rules)
+	}
+
+	// Bad rules and pruning are rejected.
+	bad := []structs.KVSHistoryRequest{
+		{Op: structs.KVSHistorySetRules, Rules: structs.KVSHistoryRules{
+			&structs.KVSHistoryRule{Prefix: "app/"},
+			&structs.KVSHistoryRule{Prefix: "app/"},
+		}},
+		{Op: structs.KVSHistorySetRules, Rules: structs.KVSHistoryRules{
+			&structs.KVSHistoryRule{Prefix: "app/", Versions: -1},
+		}},
+		{Op: structs.KVSHistoryPrune, Prefix: "app/", Index: 100},
+	}
+	for _, req := range bad {
+		req.Datacenter = "dc1"
+		req.Token = "root"
+		if err := msgpackrpc.CallWithCodec(codec, "Operator.KVSHistorySetRules", &req, &reply); err == nil {
+			t.Fatalf("should fail: %#v", req)
+		}
+	}
+}
+
+func TestOperator_KVSQuotaUsage(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+		c.KVSQuotas = structs.KVSQuotas{
+			&structs.KVSQuota{Prefix: "tmp/", MaxKeys: 10},
+			&structs.KVSQuota{Prefix: "tmp/", Token: "secret", MaxBytes: 100, MaxValueSize: 10},
+		}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	for _, key := range []string{"tmp/a", "tmp/b", "other"} {
+		arg := structs.KVSRequest{
+			Datacenter: "dc1",
+			Op:         structs.KVSSet,
+			DirEnt: structs.DirEntry{
+				Key:   key,
+				Value: []byte("hello"),
+			},
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		var out bool
+		if err := msgpackrpc.CallWithCodec(codec, "KVS.Apply", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Make a request with no token to make sure it gets denied.
+	arg := structs.DCSpecificRequest{
+		Datacenter: "dc1",
+	}
+	var reply structs.IndexedKVSQuotaUsage
+	err := msgpackrpc.CallWithCodec(codec, "Operator.KVSQuotaUsage", &arg, &reply)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Now it should go through.
+	arg.Token = "root"
+	if err := msgpackrpc.CallWithCodec(codec, "Operator.KVSQuotaUsage", &arg, &reply); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := []*structs.KVSQuotaUsage{
+		&structs.KVSQuotaUsage{
+			Prefix:  "tmp/",
+			MaxKeys: 10,
+			Keys:    2,
+			Bytes:   10,
+		},
+		&structs.KVSQuotaUsage{
+			Prefix:       "tmp/",
+			TokenScoped:  true,
+			MaxBytes:     100,
+			MaxValueSize: 10,
+			Keys:         2,
+			Bytes:        10,
+		},
+	}
+	if !reflect.DeepEqual(reply.Quotas, expected) {
+		t.Fatalf("bad: %v", reply.Quotas)
+	}
+}
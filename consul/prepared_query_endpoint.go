@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"strings"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -177,6 +176,8 @@ func parseService(svc *structs.ServiceQuery) error {
 	//   at execution time.
 	// - OnlyPassing is just a boolean so doesn't need further validation.
 	// - Tags is a free-form list of tags and doesn't need further validation.
+	// - NodeMeta is a free-form map of key/value pairs and doesn't need
+	//   further validation.
 
 	return nil
 }
@@ -486,6 +487,11 @@ func (p *PreparedQuery) execute(query *structs.PreparedQuery,
 	// Filter out any unhealthy nodes.
 	nodes = nodes.Filter(query.Service.OnlyPassing)
 
+	// Apply the node metadata filters, if any.
+	if len(query.Service.NodeMeta) > 0 {
+		nodes = nodeMetaFilter(query.Service.NodeMeta, nodes)
+	}
+
 	// Apply the tag filters, if any.
 	if len(query.Service.Tags) > 0 {
 		nodes = tagFilter(query.Service.Tags, nodes)
@@ -506,49 +512,18 @@ func (p *PreparedQuery) execute(query *structs.PreparedQuery,
 // ALL the given tags, and NONE of the forbidden tags (prefixed with !). Note
 // for performance this modifies the original slice.
 func tagFilter(tags []string, nodes structs.CheckServiceNodes) structs.CheckServiceNodes {
-	// Build up lists of required and disallowed tags.
-	must, not := make([]string, 0), make([]string, 0)
-	for _, tag := range tags {
-		tag = strings.ToLower(tag)
-		if strings.HasPrefix(tag, "!") {
-			tag = tag[1:]
-			not = append(not, tag)
-		} else {
-			must = append(must, tag)
-		}
-	}
+	filter := structs.NewServiceTagFilter(tags)
 
 	n := len(nodes)
 	for i := 0; i < n; i++ {
-		node := nodes[i]
-
-		// Index the tags so lookups this way are cheaper.
-		index := make(map[string]struct{})
-		if node.Service != nil {
-			for _, tag := range node.Service.Tags {
-				tag = strings.ToLower(tag)
-				index[tag] = struct{}{}
-			}
-		}
-
-		// Bail if any of the required tags are missing.
-		for _, tag := range must {
-			if _, ok := index[tag]; !ok {
-				goto DELETE
-			}
+		var serviceTags []string
+		if nodes[i].Service != nil {
+			serviceTags = nodes[i].Service.Tags
 		}
-
-		// Bail if any of the disallowed tags are present.
-		for _, tag := range not {
-			if _, ok := index[tag]; ok {
-				goto DELETE
-			}
+		if filter.Matches(serviceTags) {
+			continue
 		}
 
-		// At this point, the service is ok to leave in the list.
-		continue
-
-	DELETE:
 		nodes[i], nodes[n-1] = nodes[n-1], structs.CheckServiceNode{}
 		n--
 		i--
@@ -556,6 +531,18 @@ func tagFilter(tags []string, nodes structs.CheckServiceNodes) structs.CheckServ
 	return nodes[:n]
 }
 
+// nodeMetaFilter returns a list of the nodes who satisfy the given metadata
+// key/value pairs.
+func nodeMetaFilter(filters map[string]string, nodes structs.CheckServiceNodes) structs.CheckServiceNodes {
+	var filtered structs.CheckServiceNodes
+	for _, node := range nodes {
+		if structs.SatisfiesMetaFilters(node.Node.Meta, filters) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
 // queryServer is a wrapper that makes it easier to test the failover logic.
 type queryServer interface {
 	GetLogger() *log.Logger
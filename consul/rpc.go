@@ -11,6 +11,7 @@ import (
 
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/consul/agent"
+	"github.com/hashicorp/consul/consul/filter"
 	"github.com/hashicorp/consul/consul/state"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/lib"
@@ -440,3 +441,13 @@ func (s *Server) consistentRead() error {
 	future := s.raft.VerifyLeader()
 	return future.Error()
 }
+
+// newQueryFilter compiles the filter expression given with a query against
+// the type of the elements it will be applied to. This returns nil if no
+// filter was given.
+func newQueryFilter(q *structs.QueryOptions, dataType interface{}) (*filter.Filter, error) {
+	if q.Filter == "" {
+		return nil, nil
+	}
+	return filter.New(q.Filter, dataType)
+}
@@ -277,14 +277,27 @@ func (s *Server) maybeBootstrap() {
 		}
 	}
 
+	// Servers are only identified by their node IDs once they all speak
+	// version 3 or later of the Raft protocol.
+	minRaftVersion := 0
+	for i, server := range servers {
+		if i == 0 || server.RaftVersion < minRaftVersion {
+			minRaftVersion = server.RaftVersion
+		}
+	}
+
 	// Attempt a live bootstrap!
 	var configuration raft.Configuration
 	var addrs []string
 	for _, server := range servers {
 		addr := server.Addr.String()
 		addrs = append(addrs, addr)
+		id := raft.ServerID(addr)
+		if minRaftVersion >= 3 {
+			id = raft.ServerID(server.ID)
+		}
 		peer := raft.Server{
-			ID:      raft.ServerID(addr),
+			ID:      id,
 			Address: raft.ServerAddress(addr),
 		}
 		configuration.Servers = append(configuration.Servers, peer)
@@ -144,6 +144,21 @@ type Server struct {
 	sessionTimers     map[string]*time.Timer
 	sessionTimersLock sync.Mutex
 
+	// kvsTimers track the expiration time of each KV entry that has a
+	// TTL. On expiration, the entry is deleted via a check-and-set delete
+	// so that it's only removed if it hasn't been written since.
+	kvsTimers     map[string]*kvsTimer
+	kvsTimersLock sync.Mutex
+
+	// kvsQuotaLock is held while a write under a prefix with a KV quota is
+	// checked against the quotas and applied, so concurrent writes can't
+	// both use up the same room under a quota.
+	kvsQuotaLock sync.Mutex
+
+	// kvsHistorySamples are taken by the leader to turn KV history
+	// windows into indexes. They are only touched by the leader loop.
+	kvsHistorySamples []kvsHistorySample
+
 	// tombstoneGC is used to track the pending GC invocations
 	// for the KV tombstones
 	tombstoneGC *state.TombstoneGC
@@ -153,6 +168,12 @@ type Server struct {
 	aclReplicationStatus     structs.ACLReplicationStatus
 	aclReplicationStatusLock sync.RWMutex
 
+	// kvsReplicationStatus (and its associated lock) provide information
+	// about the health of the KV replication goroutines, with one entry
+	// for each entry in the config's KVSReplication list.
+	kvsReplicationStatus     []structs.KVSReplicationStatus
+	kvsReplicationStatusLock sync.RWMutex
+
 	// shutdown and the associated members here are used in orchestrating
 	// a clean shutdown. The shutdownCh is never written to, only closed to
 	// indicate a shutdown has been initiated.
@@ -173,6 +194,7 @@ type endpoints struct {
 	PreparedQuery *PreparedQuery
 	Session       *Session
 	Status        *Status
+	Stream        *Stream
 	Txn           *Txn
 }
 
@@ -189,6 +211,11 @@ func NewServer(config *Config) (*Server, error) {
 		return nil, fmt.Errorf("Config must provide a DataDir")
 	}
 
+	// Newer versions of the Raft protocol identify servers by node ID.
+	if config.RaftConfig.ProtocolVersion >= 3 && config.NodeID == "" {
+		return nil, fmt.Errorf("Config must provide a NodeID to use Raft protocol version 3")
+	}
+
 	// Sanity check the ACLs.
 	if err := config.CheckACL(); err != nil {
 		return nil, err
@@ -244,9 +271,9 @@ func NewServer(config *Config) (*Server, error) {
 
 	// Set up the non-authoritative ACL cache. A nil local function is given
 	// if ACL replication isn't enabled.
-	var local acl.FaultFunc
+	var local aclLocalFn
 	if s.IsACLReplicationEnabled() {
-		local = s.aclLocalFault
+		local = s.aclLocalLookup
 	}
 	if s.aclCache, err = newAclCache(config, logger, s.RPC, local); err != nil {
 		s.Shutdown()
@@ -288,6 +315,12 @@ func NewServer(config *Config) (*Server, error) {
 		go s.runACLReplication()
 	}
 
+	// Start KV replication, one goroutine per replicated prefix.
+	s.kvsReplicationStatus = make([]structs.KVSReplicationStatus, len(config.KVSReplication))
+	for i, replication := range config.KVSReplication {
+		go s.runKVSReplication(i, replication)
+	}
+
 	// Start listening for RPC requests.
 	go s.listen()
 
@@ -308,9 +341,11 @@ func (s *Server) setupSerf(conf *serf.Config, ch chan serf.Event, path string, w
 	}
 	conf.Tags["role"] = "consul"
 	conf.Tags["dc"] = s.config.Datacenter
+	conf.Tags["id"] = string(s.config.NodeID)
 	conf.Tags["vsn"] = fmt.Sprintf("%d", s.config.ProtocolVersion)
 	conf.Tags["vsn_min"] = fmt.Sprintf("%d", ProtocolVersionMin)
 	conf.Tags["vsn_max"] = fmt.Sprintf("%d", ProtocolVersionMax)
+	conf.Tags["raft_vsn"] = fmt.Sprintf("%d", s.config.RaftConfig.ProtocolVersion)
 	conf.Tags["build"] = s.config.Build
 	conf.Tags["port"] = fmt.Sprintf("%d", addr.Port)
 	if s.config.Bootstrap {
@@ -365,6 +400,16 @@ func (s *Server) setupRaft() error {
 		return err
 	}
 
+	// Keep a running count of the usage under the KV quotas so they can
+	// be checked without scanning the keys.
+	var quotaPrefixes []string
+	for _, quota := range s.config.KVSQuotas {
+		quotaPrefixes = append(quotaPrefixes, quota.Prefix)
+	}
+	if err := s.fsm.State().KVSTrackUsage(quotaPrefixes); err != nil {
+		return err
+	}
+
 	// Create a transport layer.
 	trans := raft.NewNetworkTransport(s.raftLayer, 3, 10*time.Second, s.config.LogOutput)
 	s.raftTransport = trans
@@ -372,9 +417,13 @@ func (s *Server) setupRaft() error {
 	// Make sure we set the LogOutput.
 	s.config.RaftConfig.LogOutput = s.config.LogOutput
 
-	// Our version of Raft protocol requires the LocalID to match the network
-	// address of the transport.
+	// Versions of the Raft protocol below 3 require the LocalID to match
+	// the network address of the transport. From version 3 on we use our
+	// node ID, so a server keeps its identity if its address changes.
 	s.config.RaftConfig.LocalID = raft.ServerID(trans.LocalAddr())
+	if s.config.RaftConfig.ProtocolVersion >= 3 {
+		s.config.RaftConfig.LocalID = raft.ServerID(s.config.NodeID)
+	}
 
 	// Build an all in-memory setup for dev mode, otherwise prepare a full
 	// disk-based setup.
@@ -467,12 +516,10 @@ func (s *Server) setupRaft() error {
 			return err
 		}
 		if !hasState {
-			// TODO (slackpad) - This will need to be updated when
-			// we add support for node IDs.
 			configuration := raft.Configuration{
 				Servers: []raft.Server{
 					raft.Server{
-						ID:      raft.ServerID(trans.LocalAddr()),
+						ID:      s.config.RaftConfig.LocalID,
 						Address: trans.LocalAddr(),
 					},
 				},
@@ -508,6 +555,7 @@ func (s *Server) setupRPC(tlsWrap tlsutil.DCWrapper) error {
 	s.endpoints.PreparedQuery = &PreparedQuery{s}
 	s.endpoints.Session = &Session{s}
 	s.endpoints.Status = &Status{s}
+	s.endpoints.Stream = &Stream{s}
 	s.endpoints.Txn = &Txn{s}
 
 	// Register the handlers
@@ -521,6 +569,7 @@ func (s *Server) setupRPC(tlsWrap tlsutil.DCWrapper) error {
 	s.rpcServer.Register(s.endpoints.PreparedQuery)
 	s.rpcServer.Register(s.endpoints.Session)
 	s.rpcServer.Register(s.endpoints.Status)
+	s.rpcServer.Register(s.endpoints.Stream)
 	s.rpcServer.Register(s.endpoints.Txn)
 
 	list, err := net.ListenTCP("tcp", s.config.RPCAddr)
@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/consul/types"
+	"github.com/hashicorp/go-uuid"
 )
 
 var nextPort int32 = 15000
@@ -38,7 +40,13 @@ func testServerConfig(t *testing.T, NodeName string) (string, *Config) {
 	dir := tmpDir(t)
 	config := DefaultConfig()
 
+	nodeID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
 	config.NodeName = NodeName
+	config.NodeID = types.NodeID(nodeID)
 	config.Bootstrap = true
 	config.Datacenter = "dc1"
 	config.DataDir = dir
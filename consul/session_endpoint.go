@@ -30,6 +30,33 @@ func (s *Session) Apply(args *structs.SessionRequest, reply *string) error {
 		return fmt.Errorf("Must provide Node")
 	}
 
+	// Fetch the ACL token, if any, and apply the policy.
+	acl, err := s.srv.resolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if acl != nil && s.srv.config.ACLEnforceVersion8 {
+		switch args.Op {
+		case structs.SessionDestroy:
+			state := s.srv.fsm.State()
+			_, existing, err := state.SessionGet(args.Session.ID)
+			if err != nil {
+				return fmt.Errorf("Session lookup failed: %v", err)
+			}
+			if existing != nil && !acl.SessionWrite(existing.Node) {
+				return permissionDeniedErr
+			}
+
+		case structs.SessionCreate:
+			if !acl.SessionWrite(args.Session.Node) {
+				return permissionDeniedErr
+			}
+
+		default:
+			return fmt.Errorf("Invalid session operation %q", args.Op)
+		}
+	}
+
 	// Ensure that the specified behavior is allowed
 	switch args.Session.Behavior {
 	case "":
@@ -130,6 +157,9 @@ func (s *Session) Get(args *structs.SessionSpecificRequest,
 			} else {
 				reply.Sessions = nil
 			}
+			if err := s.srv.filterACL(args.Token, reply); err != nil {
+				return err
+			}
 			return nil
 		})
 }
@@ -154,6 +184,9 @@ func (s *Session) List(args *structs.DCSpecificRequest,
 			}
 
 			reply.Index, reply.Sessions = index, sessions
+			if err := s.srv.filterACL(args.Token, reply); err != nil {
+				return err
+			}
 			return nil
 		})
 }
@@ -178,6 +211,9 @@ func (s *Session) NodeSessions(args *structs.NodeSpecificRequest,
 			}
 
 			reply.Index, reply.Sessions = index, sessions
+			if err := s.srv.filterACL(args.Token, reply); err != nil {
+				return err
+			}
 			return nil
 		})
 }
@@ -200,6 +236,17 @@ func (s *Session) Renew(args *structs.SessionSpecificRequest,
 	// Reset the session TTL timer
 	reply.Index = index
 	if session != nil {
+		// Fetch the ACL token, if any, and apply the policy.
+		acl, err := s.srv.resolveToken(args.Token)
+		if err != nil {
+			return err
+		}
+		if acl != nil && s.srv.config.ACLEnforceVersion8 {
+			if !acl.SessionWrite(session.Node) {
+				return permissionDeniedErr
+			}
+		}
+
 		reply.Sessions = structs.Sessions{session}
 		if err := s.srv.resetSessionTimer(args.Session, session); err != nil {
 			s.srv.logger.Printf("[ERR] consul.session: Session renew failed: %v", err)
@@ -2,6 +2,7 @@ package consul
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -70,6 +71,109 @@ func TestSessionEndpoint_Apply(t *testing.T) {
 	}
 }
 
+func TestSessionEndpoint_Apply_ACLDeny(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+		c.ACLEnforceVersion8 = false
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create the ACL.
+	req := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name: "User token",
+			Type: structs.ACLTypeClient,
+			Rules: `
+session "foo" {
+	policy = "write"
+}
+`,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var token string
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &req, &token); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Just add nodes.
+	s1.fsm.State().EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"})
+	s1.fsm.State().EnsureNode(2, &structs.Node{Node: "bar", Address: "127.0.0.1"})
+
+	// Try to create on the "bar" node without a token, which should go
+	// through since we have the version 8 ACLs turned off.
+	arg := structs.SessionRequest{
+		Datacenter: "dc1",
+		Op:         structs.SessionCreate,
+		Session: structs.Session{
+			Node: "bar",
+			Name: "my-session",
+		},
+	}
+	var id1 string
+	if err := msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &id1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Now turn on version 8 enforcement and try again.
+	s1.config.ACLEnforceVersion8 = true
+	var out string
+	err := msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &out)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The token can't create a session on "bar" either.
+	arg.Token = token
+	err = msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &out)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// It can on "foo", though.
+	arg.Session.Node = "foo"
+	var id2 string
+	if err := msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &id2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Destroying the "bar" session should be denied, since the node of
+	// the existing session is what gets checked.
+	arg.Op = structs.SessionDestroy
+	arg.Session.ID = id1
+	err = msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &out)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Destroying the "foo" session should work.
+	arg.Session.ID = id2
+	if err := msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Renewing the "bar" session should be denied, too.
+	renew := structs.SessionSpecificRequest{
+		Datacenter:   "dc1",
+		Session:      id1,
+		QueryOptions: structs.QueryOptions{Token: token},
+	}
+	var sessions structs.IndexedSessions
+	err = msgpackrpc.CallWithCodec(codec, "Session.Renew", &renew, &sessions)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestSessionEndpoint_DeleteApply(t *testing.T) {
 	dir1, s1 := testServer(t)
 	defer os.RemoveAll(dir1)
@@ -227,6 +331,121 @@ func TestSessionEndpoint_List(t *testing.T) {
 	}
 }
 
+func TestSessionEndpoint_List_ACLFilter(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+		c.ACLEnforceVersion8 = false
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create the ACL.
+	req := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name: "User token",
+			Type: structs.ACLTypeClient,
+			Rules: `
+session "foo" {
+	policy = "read"
+}
+`,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var token string
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &req, &token); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Create a session on each of two nodes.
+	s1.fsm.State().EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"})
+	s1.fsm.State().EnsureNode(2, &structs.Node{Node: "bar", Address: "127.0.0.1"})
+	ids := make(map[string]string)
+	for _, node := range []string{"foo", "bar"} {
+		arg := structs.SessionRequest{
+			Datacenter: "dc1",
+			Op:         structs.SessionCreate,
+			Session: structs.Session{
+				Node: node,
+			},
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		var out string
+		if err := msgpackrpc.CallWithCodec(codec, "Session.Apply", &arg, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		ids[node] = out
+	}
+
+	// Both sessions are visible with the version 8 ACLs turned off.
+	listR := structs.DCSpecificRequest{
+		Datacenter:   "dc1",
+		QueryOptions: structs.QueryOptions{Token: token},
+	}
+	var sessions structs.IndexedSessions
+	if err := msgpackrpc.CallWithCodec(codec, "Session.List", &listR, &sessions); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(sessions.Sessions) != 2 {
+		t.Fatalf("bad: %v", sessions.Sessions)
+	}
+
+	// Now turn on version 8 enforcement and only the "foo" session
+	// should be visible.
+	s1.config.ACLEnforceVersion8 = true
+	sessions = structs.IndexedSessions{}
+	if err := msgpackrpc.CallWithCodec(codec, "Session.List", &listR, &sessions); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(sessions.Sessions) != 1 || sessions.Sessions[0].ID != ids["foo"] {
+		t.Fatalf("bad: %v", sessions.Sessions)
+	}
+
+	// Get filters the same way.
+	getR := structs.SessionSpecificRequest{
+		Datacenter:   "dc1",
+		Session:      ids["bar"],
+		QueryOptions: structs.QueryOptions{Token: token},
+	}
+	sessions = structs.IndexedSessions{}
+	if err := msgpackrpc.CallWithCodec(codec, "Session.Get", &getR, &sessions); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(sessions.Sessions) != 0 {
+		t.Fatalf("bad: %v", sessions.Sessions)
+	}
+	getR.Session = ids["foo"]
+	sessions = structs.IndexedSessions{}
+	if err := msgpackrpc.CallWithCodec(codec, "Session.Get", &getR, &sessions); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(sessions.Sessions) != 1 {
+		t.Fatalf("bad: %v", sessions.Sessions)
+	}
+
+	// So does NodeSessions.
+	nodeR := structs.NodeSpecificRequest{
+		Datacenter:   "dc1",
+		Node:         "bar",
+		QueryOptions: structs.QueryOptions{Token: token},
+	}
+	sessions = structs.IndexedSessions{}
+	if err := msgpackrpc.CallWithCodec(codec, "Session.NodeSessions", &nodeR, &sessions); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(sessions.Sessions) != 0 {
+		t.Fatalf("bad: %v", sessions.Sessions)
+	}
+}
+
 func TestSessionEndpoint_ApplyTimers(t *testing.T) {
 	dir1, s1 := testServer(t)
 	defer os.RemoveAll(dir1)
@@ -15,6 +15,7 @@ import (
 	"io/ioutil"
 	"net"
 
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/structs"
 	"github.com/hashicorp/consul/snapshot"
 	"github.com/hashicorp/go-msgpack/codec"
@@ -49,10 +50,13 @@ func (s *Server) dispatchSnapshotRequest(args *structs.SnapshotRequest, in io.Re
 	// Verify token is allowed to operate on snapshots. There's only a
 	// single ACL sense here (not read and write) since reading gets you
 	// all the ACLs and you could escalate from there.
-	if acl, err := s.resolveToken(args.Token); err != nil {
+	acl, err := s.resolveToken(args.Token)
+	if err != nil {
 		return nil, err
-	} else if acl != nil && !acl.Snapshot() {
-		return nil, permissionDeniedErr
+	}
+	if acl != nil && !acl.Snapshot() {
+		return nil, s.auditDenied(permissionDeniedErr, args.Token, snapshotEndpoint(args.Op),
+			audit.ResourceSnapshot, "")
 	}
 
 	// Dispatch the operation.
@@ -71,6 +75,9 @@ func (s *Server) dispatchSnapshotRequest(args *structs.SnapshotRequest, in io.Re
 		// Take the snapshot and capture the index.
 		snap, err := snapshot.New(s.logger, s.raft)
 		reply.Index = snap.Index()
+		if err == nil && acl != nil {
+			s.auditACL(args.Token, snapshotEndpoint(args.Op), audit.ResourceSnapshot, "", audit.Allow)
+		}
 		return snap, err
 
 	case structs.SnapshotRestore:
@@ -98,6 +105,9 @@ func (s *Server) dispatchSnapshotRequest(args *structs.SnapshotRequest, in io.Re
 		if err := s.establishLeadership(); err != nil {
 			return nil, err
 		}
+		if acl != nil {
+			s.auditACL(args.Token, snapshotEndpoint(args.Op), audit.ResourceSnapshot, "", audit.Allow)
+		}
 
 		// Give the caller back an empty reader since there's nothing to
 		// stream back.
@@ -108,6 +118,18 @@ func (s *Server) dispatchSnapshotRequest(args *structs.SnapshotRequest, in io.Re
 	}
 }
 
+// snapshotEndpoint names the snapshot operation in the audit log.
+func snapshotEndpoint(op structs.SnapshotOp) string {
+	switch op {
+	case structs.SnapshotSave:
+		return "Snapshot.Save"
+	case structs.SnapshotRestore:
+		return "Snapshot.Restore"
+	default:
+		return "Snapshot"
+	}
+}
+
 // handleSnapshotRequest reads the request from the conn and dispatches it. This
 // will be called from a goroutine after an incoming stream is determined to be
 // a snapshot request.
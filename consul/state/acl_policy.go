@@ -0,0 +1,205 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/consul/structs"
+	"github.com/hashicorp/go-memdb"
+)
+
+// ACLPolicies is used to pull all the ACL policies from the snapshot.
+func (s *StateSnapshot) ACLPolicies() (memdb.ResultIterator, error) {
+	iter, err := s.tx.Get("acl-policies", "id")
+	if err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// ACLPolicy is used when restoring from a snapshot. For general inserts, use
+// ACLPolicySet.
+func (s *StateRestore) ACLPolicy(policy *structs.ACLPolicyEntry) error {
+	if err := s.tx.Insert("acl-policies", policy); err != nil {
+		return fmt.Errorf("failed restoring acl policy: %s", err)
+	}
+
+	if err := indexUpdateMaxTxn(s.tx, policy.ModifyIndex, "acl-policies"); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+
+	s.watches.Arm("acl-policies")
+	return nil
+}
+
+// ACLPolicySet is used to create or update an ACL policy.
+func (s *StateStore) ACLPolicySet(idx uint64, policy *structs.ACLPolicyEntry) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if err := s.aclPolicySetTxn(tx, idx, policy); err != nil {
+		return err
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// aclPolicySetTxn is the inner method used to insert an ACL policy with the
+// proper indexes into the state store.
+func (s *StateStore) aclPolicySetTxn(tx *memdb.Txn, idx uint64, policy *structs.ACLPolicyEntry) error {
+	// Check that the ID is set.
+	if policy.ID == "" {
+		return ErrMissingACLPolicyID
+	}
+
+	// Check for an existing policy.
+	existing, err := tx.First("acl-policies", "id", policy.ID)
+	if err != nil {
+		return fmt.Errorf("failed acl policy lookup: %s", err)
+	}
+
+	// Set the indexes.
+	if existing != nil {
+		policy.CreateIndex = existing.(*structs.ACLPolicyEntry).CreateIndex
+		policy.ModifyIndex = idx
+	} else {
+		policy.CreateIndex = idx
+		policy.ModifyIndex = idx
+	}
+
+	// Verify that the name isn't used by another policy.
+	other, err := tx.First("acl-policies", "name", policy.Name)
+	if err != nil {
+		return fmt.Errorf("failed acl policy lookup: %s", err)
+	}
+	if other != nil && other.(*structs.ACLPolicyEntry).ID != policy.ID {
+		return fmt.Errorf("name '%s' aliases an existing acl policy name", policy.Name)
+	}
+
+	// Insert the policy.
+	if err := tx.Insert("acl-policies", policy); err != nil {
+		return fmt.Errorf("failed inserting acl policy: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{"acl-policies", idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+
+	tx.Defer(func() { s.tableWatches["acl-policies"].Notify() })
+	return nil
+}
+
+// ACLPolicyGet is used to look up an existing ACL policy by ID.
+func (s *StateStore) ACLPolicyGet(policyID string) (uint64, *structs.ACLPolicyEntry, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	// Get the table index.
+	idx := maxIndexTxn(tx, s.getWatchTables("ACLPolicyGet")...)
+
+	// Look up the policy by its ID.
+	policy, err := tx.First("acl-policies", "id", policyID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed acl policy lookup: %s", err)
+	}
+	if policy != nil {
+		return idx, policy.(*structs.ACLPolicyEntry), nil
+	}
+	return idx, nil, nil
+}
+
+// ACLPolicyGetByName is used to look up an existing ACL policy by name.
+func (s *StateStore) ACLPolicyGetByName(name string) (uint64, *structs.ACLPolicyEntry, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	// Get the table index.
+	idx := maxIndexTxn(tx, s.getWatchTables("ACLPolicyGet")...)
+
+	// Look up the policy by its name.
+	policy, err := tx.First("acl-policies", "name", name)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed acl policy lookup: %s", err)
+	}
+	if policy != nil {
+		return idx, policy.(*structs.ACLPolicyEntry), nil
+	}
+	return idx, nil, nil
+}
+
+// ACLPolicyList is used to list out all of the ACL policies in the state
+// store.
+func (s *StateStore) ACLPolicyList() (uint64, structs.ACLPolicyEntries, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	// Get the table index.
+	idx := maxIndexTxn(tx, s.getWatchTables("ACLPolicyList")...)
+
+	// Query all of the policies in the state store.
+	policies, err := tx.Get("acl-policies", "id")
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed acl policy lookup: %s", err)
+	}
+
+	// Go over all of the policies and build the response.
+	var result structs.ACLPolicyEntries
+	for policy := policies.Next(); policy != nil; policy = policies.Next() {
+		result = append(result, policy.(*structs.ACLPolicyEntry))
+	}
+	return idx, result, nil
+}
+
+// ACLPolicyDelete is used to remove an existing ACL policy from the state
+// store. If the policy does not exist this is a no-op and no error is
+// returned. A policy that's still linked to a token can't be deleted.
+func (s *StateStore) ACLPolicyDelete(idx uint64, policyID string) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	if err := s.aclPolicyDeleteTxn(tx, idx, policyID); err != nil {
+		return err
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// aclPolicyDeleteTxn is used to delete an ACL policy from the state store
+// within an existing transaction.
+func (s *StateStore) aclPolicyDeleteTxn(tx *memdb.Txn, idx uint64, policyID string) error {
+	// Look up the existing policy.
+	policy, err := tx.First("acl-policies", "id", policyID)
+	if err != nil {
+		return fmt.Errorf("failed acl policy lookup: %s", err)
+	}
+	if policy == nil {
+		return nil
+	}
+
+	// Make sure no token still links to the policy. There's no index on
+	// the links, so we scan the tokens, which is fine for something this
+	// rare.
+	acls, err := s.aclListTxn(tx)
+	if err != nil {
+		return err
+	}
+	for _, acl := range acls {
+		for _, id := range acl.Policies {
+			if id == policyID {
+				return fmt.Errorf("acl policy '%s' is still used by token '%s'",
+					policy.(*structs.ACLPolicyEntry).Name, acl.Name)
+			}
+		}
+	}
+
+	// Delete the policy and update the index.
+	if err := tx.Delete("acl-policies", policy); err != nil {
+		return fmt.Errorf("failed deleting acl policy: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{"acl-policies", idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+
+	tx.Defer(func() { s.tableWatches["acl-policies"].Notify() })
+	return nil
+}
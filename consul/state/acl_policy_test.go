@@ -0,0 +1,279 @@
+package state
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/consul/structs"
+)
+
+func TestStateStore_ACLPolicySet_ACLPolicyGet(t *testing.T) {
+	s := testStateStore(t)
+
+	// Querying with no results returns nil.
+	idx, res, err := s.ACLPolicyGet("nope")
+	if idx != 0 || res != nil || err != nil {
+		t.Fatalf("expected (0, nil, nil), got: (%d, %#v, %#v)", idx, res, err)
+	}
+
+	// Inserting a policy with an empty ID is disallowed.
+	if err := s.ACLPolicySet(1, &structs.ACLPolicyEntry{Name: "web"}); err != ErrMissingACLPolicyID {
+		t.Fatalf("expected %#v, got: %#v", ErrMissingACLPolicyID, err)
+	}
+	if idx := s.maxIndex("acl-policies"); idx != 0 {
+		t.Fatalf("bad index: %d", idx)
+	}
+
+	// Inserting a valid policy works.
+	policy := &structs.ACLPolicyEntry{
+		ID:    "policy1",
+		Name:  "web",
+		Rules: `service "web" { policy = "write" }`,
+	}
+	if err := s.ACLPolicySet(1, policy); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx := s.maxIndex("acl-policies"); idx != 1 {
+		t.Fatalf("bad index: %d", idx)
+	}
+
+	// Read it back by ID and by name, which is case-insensitive.
+	expect := &structs.ACLPolicyEntry{
+		ID:    "policy1",
+		Name:  "web",
+		Rules: `service "web" { policy = "write" }`,
+		RaftIndex: structs.RaftIndex{
+			CreateIndex: 1,
+			ModifyIndex: 1,
+		},
+	}
+	idx, res, err = s.ACLPolicyGet("policy1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 1 || !reflect.DeepEqual(res, expect) {
+		t.Fatalf("bad: %d %#v", idx, res)
+	}
+	idx, res, err = s.ACLPolicyGetByName("WEB")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 1 || !reflect.DeepEqual(res, expect) {
+		t.Fatalf("bad: %d %#v", idx, res)
+	}
+
+	// Another policy can't take the same name.
+	err = s.ACLPolicySet(2, &structs.ACLPolicyEntry{ID: "policy2", Name: "web"})
+	if err == nil || !strings.Contains(err.Error(), "aliases an existing acl policy name") {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Update the policy, which keeps the create index.
+	policy = &structs.ACLPolicyEntry{
+		ID:          "policy1",
+		Name:        "web-dc1",
+		Rules:       `service "web" { policy = "read" }`,
+		Datacenters: []string{"dc1"},
+	}
+	if err := s.ACLPolicySet(3, policy); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expect = &structs.ACLPolicyEntry{
+		ID:          "policy1",
+		Name:        "web-dc1",
+		Rules:       `service "web" { policy = "read" }`,
+		Datacenters: []string{"dc1"},
+		RaftIndex: structs.RaftIndex{
+			CreateIndex: 1,
+			ModifyIndex: 3,
+		},
+	}
+	idx, res, err = s.ACLPolicyGet("policy1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 3 || !reflect.DeepEqual(res, expect) {
+		t.Fatalf("bad: %d %#v", idx, res)
+	}
+
+	// The old name is free now.
+	if _, res, err := s.ACLPolicyGetByName("web"); err != nil || res != nil {
+		t.Fatalf("bad: %#v %v", res, err)
+	}
+}
+
+func TestStateStore_ACLPolicyList(t *testing.T) {
+	s := testStateStore(t)
+
+	// Listing when no policies exist returns nil.
+	idx, res, err := s.ACLPolicyList()
+	if idx != 0 || res != nil || err != nil {
+		t.Fatalf("expected (0, nil, nil), got: (%d, %#v, %#v)", idx, res, err)
+	}
+
+	// Insert some policies.
+	policies := structs.ACLPolicyEntries{
+		&structs.ACLPolicyEntry{
+			ID:    "policy1",
+			Name:  "web",
+			Rules: "rules1",
+			RaftIndex: structs.RaftIndex{
+				CreateIndex: 1,
+				ModifyIndex: 1,
+			},
+		},
+		&structs.ACLPolicyEntry{
+			ID:    "policy2",
+			Name:  "db",
+			Rules: "rules2",
+			RaftIndex: structs.RaftIndex{
+				CreateIndex: 2,
+				ModifyIndex: 2,
+			},
+		},
+	}
+	for _, policy := range policies {
+		if err := s.ACLPolicySet(policy.ModifyIndex, policy); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Query the policies, which come back sorted by ID.
+	idx, res, err = s.ACLPolicyList()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 2 {
+		t.Fatalf("bad index: %d", idx)
+	}
+	if !reflect.DeepEqual(res, policies) {
+		t.Fatalf("bad: %#v", res)
+	}
+}
+
+func TestStateStore_ACLPolicyDelete(t *testing.T) {
+	s := testStateStore(t)
+
+	// Calling delete on a policy which doesn't exist returns nil.
+	if err := s.ACLPolicyDelete(1, "nope"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx := s.maxIndex("acl-policies"); idx != 0 {
+		t.Fatalf("bad index: %d", idx)
+	}
+
+	// Insert a policy and link it to a token.
+	if err := s.ACLPolicySet(1, &structs.ACLPolicyEntry{ID: "policy1", Name: "web"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	token := &structs.ACL{ID: "acl1", Name: "web token", Policies: []string{"policy1"}}
+	if err := s.ACLSet(2, token); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The policy can't be deleted while it's linked.
+	err := s.ACLPolicyDelete(3, "policy1")
+	if err == nil || !strings.Contains(err.Error(), "still used by token 'web token'") {
+		t.Fatalf("err: %v", err)
+	}
+	if idx := s.maxIndex("acl-policies"); idx != 1 {
+		t.Fatalf("bad index: %d", idx)
+	}
+
+	// Unlink it and try again.
+	token = &structs.ACL{ID: "acl1", Name: "web token"}
+	if err := s.ACLSet(3, token); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.ACLPolicyDelete(4, "policy1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx := s.maxIndex("acl-policies"); idx != 4 {
+		t.Fatalf("bad index: %d", idx)
+	}
+	if _, res, err := s.ACLPolicyGet("policy1"); err != nil || res != nil {
+		t.Fatalf("bad: %#v %v", res, err)
+	}
+}
+
+func TestStateStore_ACLPolicy_Snapshot_Restore(t *testing.T) {
+	s := testStateStore(t)
+
+	// Insert some policies.
+	policies := structs.ACLPolicyEntries{
+		&structs.ACLPolicyEntry{
+			ID:    "policy1",
+			Name:  "web",
+			Rules: "rules1",
+			RaftIndex: structs.RaftIndex{
+				CreateIndex: 1,
+				ModifyIndex: 1,
+			},
+		},
+		&structs.ACLPolicyEntry{
+			ID:          "policy2",
+			Name:        "db",
+			Rules:       "rules2",
+			Datacenters: []string{"dc2"},
+			RaftIndex: structs.RaftIndex{
+				CreateIndex: 2,
+				ModifyIndex: 2,
+			},
+		},
+	}
+	for _, policy := range policies {
+		if err := s.ACLPolicySet(policy.ModifyIndex, policy); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Snapshot the policies.
+	snap := s.Snapshot()
+	defer snap.Close()
+
+	// Alter the real state store.
+	if err := s.ACLPolicyDelete(3, "policy1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Verify the snapshot.
+	if idx := snap.LastIndex(); idx != 2 {
+		t.Fatalf("bad index: %d", idx)
+	}
+	iter, err := snap.ACLPolicies()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var dump structs.ACLPolicyEntries
+	for policy := iter.Next(); policy != nil; policy = iter.Next() {
+		dump = append(dump, policy.(*structs.ACLPolicyEntry))
+	}
+	if !reflect.DeepEqual(dump, policies) {
+		t.Fatalf("bad: %#v", dump)
+	}
+
+	// Restore the values into a new state store.
+	func() {
+		s := testStateStore(t)
+		restore := s.Restore()
+		for _, policy := range dump {
+			if err := restore.ACLPolicy(policy); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+		restore.Commit()
+
+		// Read the restored policies back out and verify that they match.
+		idx, res, err := s.ACLPolicyList()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if idx != 2 {
+			t.Fatalf("bad index: %d", idx)
+		}
+		if !reflect.DeepEqual(res, policies) {
+			t.Fatalf("bad: %#v", res)
+		}
+	}()
+}
@@ -23,6 +23,33 @@ func (s *StateSnapshot) Tombstones() (memdb.ResultIterator, error) {
 	return s.store.kvsGraveyard.DumpTxn(s.tx)
 }
 
+// KVSHistory is used to pull all the prior versions of KVS entries for use
+// during snapshots.
+func (s *StateSnapshot) KVSHistory() (memdb.ResultIterator, error) {
+	iter, err := s.tx.Get("kvs_history", "id_prefix", "")
+	if err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// KVSHistoryRules is used to pull the KVS history rules for use during
+// snapshots.
+func (s *StateSnapshot) KVSHistoryRules() (memdb.ResultIterator, error) {
+	iter, err := s.tx.Get("kvs_history_rules", "id_prefix", "")
+	if err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// KVSHistoryRulesIndex is used to pull the index of the last change to the
+// KVS history rules for use during snapshots. This is kept even if there are
+// no rules, so an empty rule set that was stored on purpose survives.
+func (s *StateSnapshot) KVSHistoryRulesIndex() uint64 {
+	return maxIndexTxn(s.tx, "kvs_history_rules")
+}
+
 // KVS is used when restoring from a snapshot. Use KVSSet for general inserts.
 func (s *StateRestore) KVS(entry *structs.DirEntry) error {
 	if err := s.tx.Insert("kvs", entry); err != nil {
@@ -38,6 +65,37 @@ func (s *StateRestore) KVS(entry *structs.DirEntry) error {
 	return nil
 }
 
+// KVSHistory is used when restoring a prior version of a KVS entry from a
+// snapshot.
+func (s *StateRestore) KVSHistory(entry *structs.KVSHistoryEntry) error {
+	if err := s.tx.Insert("kvs_history", entry); err != nil {
+		return fmt.Errorf("failed inserting kvs history entry: %s", err)
+	}
+
+	if err := indexUpdateMaxTxn(s.tx, entry.ReplacedIndex, "kvs_history"); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
+// KVSHistoryRule is used when restoring a KVS history rule from a snapshot.
+// Use KVSHistorySetRules for general inserts.
+func (s *StateRestore) KVSHistoryRule(rule *structs.KVSHistoryRule) error {
+	if err := s.tx.Insert("kvs_history_rules", rule); err != nil {
+		return fmt.Errorf("failed inserting kvs history rule: %s", err)
+	}
+	return nil
+}
+
+// KVSHistoryRulesIndex is used when restoring the index of the last change to
+// the KVS history rules from a snapshot.
+func (s *StateRestore) KVSHistoryRulesIndex(idx uint64) error {
+	if err := indexUpdateMaxTxn(s.tx, idx, "kvs_history_rules"); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return nil
+}
+
 // Tombstone is used when restoring from a snapshot. For general inserts, use
 // Graveyard.InsertTxn.
 func (s *StateRestore) Tombstone(stone *Tombstone) error {
@@ -88,9 +146,13 @@ func (s *StateStore) kvsSetTxn(tx *memdb.Txn, idx uint64, entry *structs.DirEntr
 		return fmt.Errorf("failed kvs lookup: %s", err)
 	}
 
-	// Set the indexes.
+	// Set the indexes, and keep the version being replaced if its history
+	// is wanted.
 	if existing != nil {
 		entry.CreateIndex = existing.(*structs.DirEntry).CreateIndex
+		if err := s.kvsHistoryRecordTxn(tx, idx, existing.(*structs.DirEntry)); err != nil {
+			return err
+		}
 	} else {
 		entry.CreateIndex = idx
 	}
@@ -106,6 +168,15 @@ func (s *StateStore) kvsSetTxn(tx *memdb.Txn, idx uint64, entry *structs.DirEntr
 		}
 	}
 
+	// Keep the usage of the prefixes covering the key up to date.
+	keys, bytes := 1, int64(len(entry.Value))
+	if existing != nil {
+		keys, bytes = 0, bytes-int64(len(existing.(*structs.DirEntry).Value))
+	}
+	if err := kvsUsageUpdateTxn(tx, entry.Key, keys, bytes); err != nil {
+		return err
+	}
+
 	// Store the kv pair in the state store and update the index.
 	if err := tx.Insert("kvs", entry); err != nil {
 		return fmt.Errorf("failed inserting kvs entry: %s", err)
@@ -154,16 +225,208 @@ func (s *StateStore) KVSList(prefix string) (uint64, structs.DirEntries, error)
 	return s.kvsListTxn(tx, prefix)
 }
 
+// KVSUsage returns the number of keys under the given prefix and the total
+// size of their values. This is cheap for prefixes given to KVSTrackUsage,
+// and scans the keys otherwise.
+func (s *StateStore) KVSUsage(prefix string) (uint64, int, int64, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, "kvs", "tombstones")
+
+	usage, err := tx.First("kvs_usage", "id", prefix)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed kvs usage lookup: %s", err)
+	}
+	if usage != nil {
+		u := usage.(*KVSUsageEntry)
+		return idx, u.Keys, u.Bytes, nil
+	}
+
+	keys, bytes, err := kvsUsageScanTxn(tx, prefix)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return idx, keys, bytes, nil
+}
+
+// KVSUsageEntry keeps a running count of the keys under a prefix and the
+// total size of their values.
+type KVSUsageEntry struct {
+	Prefix string
+	Keys   int
+	Bytes  int64
+}
+
+// KVSTrackUsage sets the prefixes whose usage is kept up to date as keys are
+// written, so KVSUsage doesn't have to scan them. The usage isn't part of
+// snapshots since it can always be counted again from the keys.
+func (s *StateStore) KVSTrackUsage(prefixes []string) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	// Stop tracking any prefixes that aren't wanted any more.
+	wanted := make(map[string]bool)
+	for _, prefix := range prefixes {
+		wanted[prefix] = true
+	}
+	existing, err := tx.Get("kvs_usage", "id")
+	if err != nil {
+		return fmt.Errorf("failed kvs usage lookup: %s", err)
+	}
+	var objs []interface{}
+	for usage := existing.Next(); usage != nil; usage = existing.Next() {
+		if prefix := usage.(*KVSUsageEntry).Prefix; wanted[prefix] {
+			delete(wanted, prefix)
+		} else {
+			objs = append(objs, usage)
+		}
+	}
+	for _, obj := range objs {
+		if err := tx.Delete("kvs_usage", obj); err != nil {
+			return fmt.Errorf("failed deleting kvs usage: %s", err)
+		}
+	}
+
+	// Count up the usage of the new ones.
+	for prefix := range wanted {
+		keys, bytes, err := kvsUsageScanTxn(tx, prefix)
+		if err != nil {
+			return err
+		}
+		usage := &KVSUsageEntry{Prefix: prefix, Keys: keys, Bytes: bytes}
+		if err := tx.Insert("kvs_usage", usage); err != nil {
+			return fmt.Errorf("failed inserting kvs usage: %s", err)
+		}
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// KVSUsagePrefixes returns the prefixes given to KVSTrackUsage.
+func (s *StateStore) KVSUsagePrefixes() ([]string, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	usages, err := tx.Get("kvs_usage", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed kvs usage lookup: %s", err)
+	}
+	var prefixes []string
+	for usage := usages.Next(); usage != nil; usage = usages.Next() {
+		prefixes = append(prefixes, usage.(*KVSUsageEntry).Prefix)
+	}
+	return prefixes, nil
+}
+
+// kvsUsageScanTxn counts the keys under the given prefix and the total size
+// of their values.
+func kvsUsageScanTxn(tx *memdb.Txn, prefix string) (int, int64, error) {
+	entries, err := tx.Get("kvs", "id_prefix", prefix)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed kvs lookup: %s", err)
+	}
+	var keys int
+	var bytes int64
+	for entry := entries.Next(); entry != nil; entry = entries.Next() {
+		keys++
+		bytes += int64(len(entry.(*structs.DirEntry).Value))
+	}
+	return keys, bytes, nil
+}
+
+// kvsUsageUpdateTxn applies a change in the number of keys and the size of
+// their values at the given key to the usage of every tracked prefix that
+// covers it.
+func kvsUsageUpdateTxn(tx *memdb.Txn, key string, keys int, bytes int64) error {
+	if keys == 0 && bytes == 0 {
+		return nil
+	}
+
+	usages, err := tx.Get("kvs_usage", "id")
+	if err != nil {
+		return fmt.Errorf("failed kvs usage lookup: %s", err)
+	}
+	var updated []*KVSUsageEntry
+	for usage := usages.Next(); usage != nil; usage = usages.Next() {
+		u := usage.(*KVSUsageEntry)
+		if strings.HasPrefix(key, u.Prefix) {
+			updated = append(updated, &KVSUsageEntry{
+				Prefix: u.Prefix,
+				Keys:   u.Keys + keys,
+				Bytes:  u.Bytes + bytes,
+			})
+		}
+	}
+
+	// Do the updates in a separate loop so we don't trash the iterator.
+	for _, usage := range updated {
+		if err := tx.Insert("kvs_usage", usage); err != nil {
+			return fmt.Errorf("failed updating kvs usage: %s", err)
+		}
+	}
+	return nil
+}
+
+// KVSListPage is used to list a page of the keys under a given prefix, in
+// key order. Only keys sorting after the given cursor are returned, up to
+// the given limit, where an empty cursor starts at the beginning and a zero
+// limit returns everything. If there are more keys, the key to use as the
+// cursor for the next page is also returned. A page only looks at the keys
+// it returns, so the returned index is the one for the whole KVS table. It's
+// the same for every page and can be used to block on changes anywhere under
+// the prefix.
+func (s *StateStore) KVSListPage(prefix, after string, limit int) (uint64, structs.DirEntries, string, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	return s.kvsListPageTxn(tx, prefix, after, limit)
+}
+
 // kvsListTxn is the inner method that gets a list of KVS entries matching a
 // prefix.
 func (s *StateStore) kvsListTxn(tx *memdb.Txn, prefix string) (uint64, structs.DirEntries, error) {
+	idx, ents, _, err := s.kvsListPageTxn(tx, prefix, "", 0)
+	return idx, ents, err
+}
+
+// kvsListPageTxn is the inner method that gets a page of the KVS entries
+// matching a prefix.
+func (s *StateStore) kvsListPageTxn(tx *memdb.Txn, prefix, after string, limit int) (uint64, structs.DirEntries, string, error) {
 	// Get the table indexes.
 	idx := maxIndexTxn(tx, "kvs", "tombstones")
 
+	// For a page, seek to the cursor and stop once we know if there are
+	// more entries. The table indexes above already cover the graveyard.
+	if after != "" || limit > 0 {
+		iter, err := newKVSSeekIterator(tx, prefix, after, false)
+		if err != nil {
+			return 0, nil, "", err
+		}
+		var ents structs.DirEntries
+		var next string
+		for {
+			e, err := iter.Next()
+			if err != nil {
+				return 0, nil, "", err
+			}
+			if e == nil {
+				break
+			}
+			if limit > 0 && len(ents) == limit {
+				next = ents[limit-1].Key
+				break
+			}
+			ents = append(ents, e)
+		}
+		return idx, ents, next, nil
+	}
+
 	// Query the prefix and list the available keys
 	entries, err := tx.Get("kvs", "id_prefix", prefix)
 	if err != nil {
-		return 0, nil, fmt.Errorf("failed kvs lookup: %s", err)
+		return 0, nil, "", fmt.Errorf("failed kvs lookup: %s", err)
 	}
 
 	// Gather all of the keys found in the store
@@ -182,7 +445,7 @@ func (s *StateStore) kvsListTxn(tx *memdb.Txn, prefix string) (uint64, structs.D
 	if prefix != "" {
 		gindex, err := s.kvsGraveyard.GetMaxIndexTxn(tx, prefix)
 		if err != nil {
-			return 0, nil, fmt.Errorf("failed graveyard lookup: %s", err)
+			return 0, nil, "", fmt.Errorf("failed graveyard lookup: %s", err)
 		}
 		if gindex > lindex {
 			lindex = gindex
@@ -196,7 +459,7 @@ func (s *StateStore) kvsListTxn(tx *memdb.Txn, prefix string) (uint64, structs.D
 	if lindex != 0 {
 		idx = lindex
 	}
-	return idx, ents, nil
+	return idx, ents, "", nil
 }
 
 // KVSListKeys is used to query the KV store for keys matching the given prefix.
@@ -204,21 +467,65 @@ func (s *StateStore) kvsListTxn(tx *memdb.Txn, prefix string) (uint64, structs.D
 // of the response so that only a subset of the prefix is returned. In this
 // mode, the keys which are omitted are still counted in the returned index.
 func (s *StateStore) KVSListKeys(prefix, sep string) (uint64, []string, error) {
+	idx, keys, _, err := s.KVSListKeysPage(prefix, sep, "", 0)
+	return idx, keys, err
+}
+
+// KVSListKeysPage is used to list a page of the keys matching the given
+// prefix, sliced off at the optional separator as for KVSListKeys. The
+// cursor, limit, returned cursor for the next page and returned index work
+// the same as for KVSListPage, and apply to the sliced keys.
+func (s *StateStore) KVSListKeysPage(prefix, sep, after string, limit int) (uint64, []string, string, error) {
 	tx := s.db.Txn(false)
 	defer tx.Abort()
 
 	// Get the table indexes.
 	idx := maxIndexTxn(tx, "kvs", "tombstones")
 
+	// For a page, seek to the cursor and stop once we know if there are
+	// more keys. Every key under a sliced off key slices to the same key,
+	// so once we've seen one we seek past the rest of them.
+	if after != "" || limit > 0 {
+		_, skip := kvsSliceKey(after, prefix, sep)
+		iter, err := newKVSSeekIterator(tx, prefix, after, skip)
+		if err != nil {
+			return 0, nil, "", err
+		}
+		var keys []string
+		var next string
+		for {
+			e, err := iter.Next()
+			if err != nil {
+				return 0, nil, "", err
+			}
+			if e == nil {
+				break
+			}
+
+			key, sliced := kvsSliceKey(e.Key, prefix, sep)
+			if sliced {
+				if iter, err = newKVSSeekIterator(tx, prefix, key, true); err != nil {
+					return 0, nil, "", err
+				}
+			}
+			if key <= after {
+				continue
+			}
+			if limit > 0 && len(keys) == limit {
+				next = keys[limit-1]
+				break
+			}
+			keys = append(keys, key)
+		}
+		return idx, keys, next, nil
+	}
+
 	// Fetch keys using the specified prefix
 	entries, err := tx.Get("kvs", "id_prefix", prefix)
 	if err != nil {
-		return 0, nil, fmt.Errorf("failed kvs lookup: %s", err)
+		return 0, nil, "", fmt.Errorf("failed kvs lookup: %s", err)
 	}
 
-	prefixLen := len(prefix)
-	sepLen := len(sep)
-
 	var keys []string
 	var lindex uint64
 	var last string
@@ -230,24 +537,13 @@ func (s *StateStore) KVSListKeys(prefix, sep string) (uint64, []string, error) {
 			lindex = e.ModifyIndex
 		}
 
-		// Always accumulate if no separator provided
-		if sepLen == 0 {
-			keys = append(keys, e.Key)
-			continue
-		}
-
-		// Parse and de-duplicate the returned keys based on the
-		// key separator, if provided.
-		after := e.Key[prefixLen:]
-		sepIdx := strings.Index(after, sep)
-		if sepIdx > -1 {
-			key := e.Key[:prefixLen+sepIdx+sepLen]
-			if key != last {
-				keys = append(keys, key)
-				last = key
-			}
-		} else {
-			keys = append(keys, e.Key)
+		// Parse the returned key based on the key separator. Since the
+		// entries are in order, the sliced keys are too, so duplicates
+		// are always next to each other.
+		key, _ := kvsSliceKey(e.Key, prefix, sep)
+		if key != last {
+			keys = append(keys, key)
+			last = key
 		}
 	}
 
@@ -256,7 +552,7 @@ func (s *StateStore) KVSListKeys(prefix, sep string) (uint64, []string, error) {
 	if prefix != "" {
 		gindex, err := s.kvsGraveyard.GetMaxIndexTxn(tx, prefix)
 		if err != nil {
-			return 0, nil, fmt.Errorf("failed graveyard lookup: %s", err)
+			return 0, nil, "", fmt.Errorf("failed graveyard lookup: %s", err)
 		}
 		if gindex > lindex {
 			lindex = gindex
@@ -270,7 +566,102 @@ func (s *StateStore) KVSListKeys(prefix, sep string) (uint64, []string, error) {
 	if lindex != 0 {
 		idx = lindex
 	}
-	return idx, keys, nil
+	return idx, keys, "", nil
+}
+
+// kvsSliceKey slices off the part of the given key after the first separator
+// that follows the prefix, if there is one. It also returns whether the key
+// was sliced, in which case every key under the sliced key slices to it too.
+func kvsSliceKey(key, prefix, sep string) (string, bool) {
+	if sep == "" || !strings.HasPrefix(key, prefix) {
+		return key, false
+	}
+	sepIdx := strings.Index(key[len(prefix):], sep)
+	if sepIdx == -1 {
+		return key, false
+	}
+	return key[:len(prefix)+sepIdx+len(sep)], true
+}
+
+// kvsSeekIterator walks the KVS entries under a prefix whose keys sort after
+// a given key, in key order. Our version of go-memdb can't seek to a lower
+// bound, so this uses a prefix lookup for each possible successor of the
+// key, one byte at a time. This is a bounded number of lookups no matter how
+// many keys there are, and since the lookups return results in order we can
+// stop as soon as we have enough.
+type kvsSeekIterator struct {
+	tx        *memdb.Txn
+	after     string
+	exclusive bool
+	floor     int
+	pos       int
+	next      int
+	iter      memdb.ResultIterator
+}
+
+// newKVSSeekIterator returns an iterator over the KVS entries under the given
+// prefix whose keys sort after the given key. If skip is set then the keys
+// under the given key are skipped as well.
+func newKVSSeekIterator(tx *memdb.Txn, prefix, after string, skip bool) (*kvsSeekIterator, error) {
+	it := &kvsSeekIterator{tx: tx, floor: len(prefix), next: 256}
+	switch {
+	case after < prefix:
+		// Everything under the prefix sorts after the key.
+		it.after, it.pos = prefix, -1
+	case !strings.HasPrefix(after, prefix):
+		// Nothing under the prefix sorts after the key.
+		it.pos = -1
+		return it, nil
+	default:
+		it.after, it.exclusive, it.pos = after, true, len(after)
+		if skip {
+			return it, nil
+		}
+	}
+
+	// The keys under the given key come first, so start with those.
+	iter, err := tx.Get("kvs", "id_prefix", it.after)
+	if err != nil {
+		return nil, fmt.Errorf("failed kvs lookup: %s", err)
+	}
+	it.iter = iter
+	return it, nil
+}
+
+// Next returns the next entry, or nil once there are no more.
+func (it *kvsSeekIterator) Next() (*structs.DirEntry, error) {
+	for {
+		if it.iter != nil {
+			if raw := it.iter.Next(); raw != nil {
+				e := raw.(*structs.DirEntry)
+				if it.exclusive && e.Key == it.after {
+					continue
+				}
+				return e, nil
+			}
+			it.iter = nil
+		}
+
+		// Move on to the next successor of the key, trying each greater
+		// byte at the current position before backing up a byte.
+		for it.next > 255 && it.pos >= it.floor {
+			it.pos--
+			if it.pos >= it.floor {
+				it.next = int(it.after[it.pos]) + 1
+			}
+		}
+		if it.pos < it.floor {
+			return nil, nil
+		}
+		seek := it.after[:it.pos] + string([]byte{byte(it.next)})
+		it.next++
+
+		iter, err := it.tx.Get("kvs", "id_prefix", seek)
+		if err != nil {
+			return nil, fmt.Errorf("failed kvs lookup: %s", err)
+		}
+		it.iter = iter
+	}
 }
 
 // KVSDelete is used to perform a shallow delete on a single key in the
@@ -305,10 +696,19 @@ func (s *StateStore) kvsDeleteTxn(tx *memdb.Txn, idx uint64, key string) error {
 		return fmt.Errorf("failed adding to graveyard: %s", err)
 	}
 
+	// Keep the deleted version if its history is wanted.
+	if err := s.kvsHistoryRecordTxn(tx, idx, entry.(*structs.DirEntry)); err != nil {
+		return err
+	}
+
 	// Delete the entry and update the index.
 	if err := tx.Delete("kvs", entry); err != nil {
 		return fmt.Errorf("failed deleting kvs entry: %s", err)
 	}
+	e := entry.(*structs.DirEntry)
+	if err := kvsUsageUpdateTxn(tx, e.Key, -1, -int64(len(e.Value))); err != nil {
+		return err
+	}
 	if err := tx.Insert("index", &IndexEntry{"kvs", idx}); err != nil {
 		return fmt.Errorf("failed updating index: %s", err)
 	}
@@ -445,9 +845,16 @@ func (s *StateStore) kvsDeleteTreeTxn(tx *memdb.Txn, idx uint64, prefix string)
 	// Do the actual deletes in a separate loop so we don't trash the
 	// iterator as we go.
 	for _, obj := range objs {
+		e := obj.(*structs.DirEntry)
 		if err := tx.Delete("kvs", obj); err != nil {
 			return fmt.Errorf("failed deleting kvs entry: %s", err)
 		}
+		if err := kvsUsageUpdateTxn(tx, e.Key, -1, -int64(len(e.Value))); err != nil {
+			return err
+		}
+		if err := s.kvsHistoryRecordTxn(tx, idx, e); err != nil {
+			return err
+		}
 	}
 
 	// Update the index
@@ -622,3 +1029,310 @@ func (s *StateStore) kvsCheckIndexTxn(tx *memdb.Txn, key string, cidx uint64) (*
 
 	return e, nil
 }
+
+// KVSGetAt is used to retrieve a key/value pair as it was at the given
+// index. Versions older than the current one are only known if the key is
+// covered by a history rule, so nil is returned for a key that wasn't set
+// at that index or whose version has not been kept.
+func (s *StateStore) KVSGetAt(key string, at uint64) (uint64, *structs.DirEntry, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	// Get the current entry, which is the answer if it was already in
+	// place at the given index.
+	idx, entry, err := s.kvsGetTxn(tx, key)
+	if err != nil {
+		return 0, nil, err
+	}
+	if entry != nil && entry.ModifyIndex <= at {
+		return idx, entry, nil
+	}
+
+	// Otherwise look for the version that was current at the index.
+	versions, err := kvsHistoryVersionsTxn(tx, key)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, version := range versions {
+		if version.ModifyIndex <= at && at < version.ReplacedIndex {
+			return idx, &structs.DirEntry{
+				LockIndex: version.LockIndex,
+				Key:       version.Key,
+				Flags:     version.Flags,
+				Value:     version.Value,
+				Session:   version.Session,
+				TTL:       version.TTL,
+				RaftIndex: version.RaftIndex,
+			}, nil
+		}
+	}
+	return idx, nil, nil
+}
+
+// KVSHistory is used to list the prior versions of a key that have been
+// kept by its history rule, oldest first.
+func (s *StateStore) KVSHistory(key string) (uint64, structs.KVSHistoryEntries, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	// The history changes along with the key, and when it's pruned.
+	idx := maxIndexTxn(tx, "kvs", "tombstones", "kvs_history")
+
+	versions, err := kvsHistoryVersionsTxn(tx, key)
+	if err != nil {
+		return 0, nil, err
+	}
+	return idx, versions, nil
+}
+
+// KVSHistoryRules is used to list the rules that decide which keys have
+// their history kept.
+func (s *StateStore) KVSHistoryRules() (uint64, structs.KVSHistoryRules, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	idx := maxIndexTxn(tx, "kvs_history_rules")
+
+	rules, err := tx.Get("kvs_history_rules", "id_prefix", "")
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed kvs history rule lookup: %s", err)
+	}
+	var out structs.KVSHistoryRules
+	for rule := rules.Next(); rule != nil; rule = rules.Next() {
+		out = append(out, rule.(*structs.KVSHistoryRule))
+	}
+	return idx, out, nil
+}
+
+// KVSHistorySetRules replaces the KVS history rules. Versions that have
+// already been kept are left alone, even if they're no longer covered by a
+// rule or there are more of them than their new rule keeps. Versions are
+// only ever dropped by pruning, or when a newer version of the key is kept.
+func (s *StateStore) KVSHistorySetRules(idx uint64, rules structs.KVSHistoryRules) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	// Swap out the rules.
+	if _, err := tx.DeleteAll("kvs_history_rules", "id_prefix", ""); err != nil {
+		return fmt.Errorf("failed deleting kvs history rules: %s", err)
+	}
+	for _, rule := range rules {
+		if err := tx.Insert("kvs_history_rules", rule); err != nil {
+			return fmt.Errorf("failed inserting kvs history rule: %s", err)
+		}
+	}
+	if err := tx.Insert("index", &IndexEntry{"kvs_history_rules", idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+
+	tx.Defer(func() { s.tableWatches["kvs_history_rules"].Notify() })
+	tx.Commit()
+	return nil
+}
+
+// KVSHistoryPrunable returns true if any of the versions kept by the rule
+// with the given prefix were replaced before the given index.
+func (s *StateStore) KVSHistoryPrunable(prefix string, index uint64) (bool, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	objs, err := kvsHistoryPrunableTxn(tx, prefix, index, kvsHistoryRuleOwns(prefix))
+	if err != nil {
+		return false, err
+	}
+	return len(objs) > 0, nil
+}
+
+// KVSHistoryPrune drops the versions kept by the rule with the given
+// prefix that were replaced before the given index. This is used to expire
+// versions once they're older than the rule's window.
+func (s *StateStore) KVSHistoryPrune(idx uint64, prefix string, index uint64) error {
+	return s.kvsHistoryPrune(idx, prefix, index, kvsHistoryRuleOwns(prefix))
+}
+
+// KVSHistoryUncoveredPrunable returns true if any versions of keys that
+// aren't covered by a history rule were replaced before the given index.
+func (s *StateStore) KVSHistoryUncoveredPrunable(index uint64) (bool, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	objs, err := kvsHistoryPrunableTxn(tx, "", index, kvsHistoryUncovered)
+	if err != nil {
+		return false, err
+	}
+	return len(objs) > 0, nil
+}
+
+// KVSHistoryPruneUncovered drops the versions of keys that aren't covered
+// by a history rule that were replaced before the given index. These are
+// left behind when the rules change.
+func (s *StateStore) KVSHistoryPruneUncovered(idx uint64, index uint64) error {
+	return s.kvsHistoryPrune(idx, "", index, kvsHistoryUncovered)
+}
+
+// kvsHistoryPrune drops the versions under the given prefix that were
+// replaced before the given index and whose covering rule is matched by
+// the given function.
+func (s *StateStore) kvsHistoryPrune(idx uint64, prefix string, index uint64,
+	match func(*structs.KVSHistoryRule) bool) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	objs, err := kvsHistoryPrunableTxn(tx, prefix, index, match)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		if err := tx.Delete("kvs_history", obj); err != nil {
+			return fmt.Errorf("failed deleting kvs history entry: %s", err)
+		}
+	}
+	if len(objs) > 0 {
+		if err := tx.Insert("index", &IndexEntry{"kvs_history", idx}); err != nil {
+			return fmt.Errorf("failed updating index: %s", err)
+		}
+
+		// Wake up anyone watching the history of the pruned keys.
+		tx.Defer(func() {
+			for _, obj := range objs {
+				s.kvsWatch.Notify(obj.Key, false)
+			}
+		})
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// kvsHistoryRuleOwns returns a function that matches the rule with the
+// given prefix, which leaves out versions under the prefix that belong to
+// a more specific rule.
+func kvsHistoryRuleOwns(prefix string) func(*structs.KVSHistoryRule) bool {
+	return func(rule *structs.KVSHistoryRule) bool {
+		return rule != nil && rule.Prefix == prefix
+	}
+}
+
+// kvsHistoryUncovered matches versions of keys that no rule covers.
+func kvsHistoryUncovered(rule *structs.KVSHistoryRule) bool {
+	return rule == nil
+}
+
+// kvsHistoryPrunableTxn returns the versions under the given prefix that
+// were replaced before the given index and whose covering rule, which is
+// nil if there isn't one, is matched by the given function.
+func kvsHistoryPrunableTxn(tx *memdb.Txn, prefix string, index uint64,
+	match func(*structs.KVSHistoryRule) bool) ([]*structs.KVSHistoryEntry, error) {
+	iter, err := tx.Get("kvs_history", "id_prefix", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed kvs history lookup: %s", err)
+	}
+	var objs []*structs.KVSHistoryEntry
+	for obj := iter.Next(); obj != nil; obj = iter.Next() {
+		version := obj.(*structs.KVSHistoryEntry)
+		if version.ReplacedIndex >= index {
+			continue
+		}
+		rule, err := kvsHistoryRuleTxn(tx, version.Key)
+		if err != nil {
+			return nil, err
+		}
+		if match(rule) {
+			objs = append(objs, version)
+		}
+	}
+	return objs, nil
+}
+
+// kvsHistoryRecordTxn keeps the given version of a KV entry, which is being
+// replaced at the given index, if a history rule covers its key.
+func (s *StateStore) kvsHistoryRecordTxn(tx *memdb.Txn, idx uint64, entry *structs.DirEntry) error {
+	// A version that's replaced at the index it was written at, such as
+	// by a later operation in the same transaction, was never visible.
+	if entry.ModifyIndex == idx {
+		return nil
+	}
+
+	rule, err := kvsHistoryRuleTxn(tx, entry.Key)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return nil
+	}
+
+	version := &structs.KVSHistoryEntry{
+		LockIndex:     entry.LockIndex,
+		Key:           entry.Key,
+		Flags:         entry.Flags,
+		Value:         entry.Value,
+		Session:       entry.Session,
+		TTL:           entry.TTL,
+		ReplacedIndex: idx,
+		RaftIndex:     entry.RaftIndex,
+	}
+	if err := tx.Insert("kvs_history", version); err != nil {
+		return fmt.Errorf("failed inserting kvs history entry: %s", err)
+	}
+	if err := tx.Insert("index", &IndexEntry{"kvs_history", idx}); err != nil {
+		return fmt.Errorf("failed updating index: %s", err)
+	}
+	return kvsHistoryTrimTxn(tx, entry.Key, rule.Versions)
+}
+
+// kvsHistoryRuleTxn returns the history rule that covers the given key, or
+// nil if there isn't one. The rule with the longest matching prefix wins.
+func kvsHistoryRuleTxn(tx *memdb.Txn, key string) (*structs.KVSHistoryRule, error) {
+	rules, err := tx.Get("kvs_history_rules", "id_prefix", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed kvs history rule lookup: %s", err)
+	}
+
+	var match *structs.KVSHistoryRule
+	for obj := rules.Next(); obj != nil; obj = rules.Next() {
+		rule := obj.(*structs.KVSHistoryRule)
+		if !strings.HasPrefix(key, rule.Prefix) {
+			continue
+		}
+		if match == nil || len(rule.Prefix) > len(match.Prefix) {
+			match = rule
+		}
+	}
+	return match, nil
+}
+
+// kvsHistoryVersionsTxn returns the kept versions of the given key, oldest
+// first.
+func kvsHistoryVersionsTxn(tx *memdb.Txn, key string) (structs.KVSHistoryEntries, error) {
+	// The index terminates each key with a null, so this only matches the
+	// versions of this exact key.
+	iter, err := tx.Get("kvs_history", "id_prefix", key+"\x00")
+	if err != nil {
+		return nil, fmt.Errorf("failed kvs history lookup: %s", err)
+	}
+
+	var versions structs.KVSHistoryEntries
+	for version := iter.Next(); version != nil; version = iter.Next() {
+		versions = append(versions, version.(*structs.KVSHistoryEntry))
+	}
+	return versions, nil
+}
+
+// kvsHistoryTrimTxn drops the oldest kept versions of the given key so at
+// most the given number are left, where zero means there's no limit.
+func kvsHistoryTrimTxn(tx *memdb.Txn, key string, versions int) error {
+	if versions <= 0 {
+		return nil
+	}
+
+	existing, err := kvsHistoryVersionsTxn(tx, key)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(existing)-versions; i++ {
+		if err := tx.Delete("kvs_history", existing[i]); err != nil {
+			return fmt.Errorf("failed deleting kvs history entry: %s", err)
+		}
+	}
+	return nil
+}
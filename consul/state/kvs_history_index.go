@@ -0,0 +1,104 @@
+package state
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/hashicorp/consul/consul/structs"
+)
+
+// KVSHistoryIndex is a custom memdb indexer used to index the prior
+// versions of KV entries by key and then by ModifyIndex, so that a prefix
+// scan returns the versions in the order they were written.
+type KVSHistoryIndex struct {
+}
+
+// FromObject is used to compute the index key when inserting or updating an
+// object.
+func (*KVSHistoryIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	entry, ok := obj.(*structs.KVSHistoryEntry)
+	if !ok {
+		return false, nil, fmt.Errorf("invalid object given to index as KV history entry")
+	}
+
+	// Terminate the key with a null so one key isn't a prefix of another's
+	// versions, then add the index in a sortable form.
+	out := make([]byte, len(entry.Key)+9)
+	copy(out, entry.Key)
+	binary.BigEndian.PutUint64(out[len(entry.Key)+1:], entry.ModifyIndex)
+	return true, out, nil
+}
+
+// FromArgs is used when querying for an exact match, given the key and
+// the ModifyIndex of the version.
+func (*KVSHistoryIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("must provide a key and an index")
+	}
+	key, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a string: %#v", args[0])
+	}
+	index, ok := args[1].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a uint64: %#v", args[1])
+	}
+	_, out, err := (&KVSHistoryIndex{}).FromObject(&structs.KVSHistoryEntry{
+		Key: key,
+		RaftIndex: structs.RaftIndex{
+			ModifyIndex: index,
+		},
+	})
+	return out, err
+}
+
+// PrefixFromArgs is used when doing a prefix scan for the versions of all
+// the keys under a prefix.
+func (*KVSHistoryIndex) PrefixFromArgs(args ...interface{}) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("must provide only a single argument")
+	}
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a string: %#v", args[0])
+	}
+	return []byte(arg), nil
+}
+
+// KVSHistoryRuleIndex is a custom memdb indexer used to index the KV
+// history rules by prefix. The built-in string indexer doesn't allow the
+// empty prefix, which is used for a rule covering every key.
+type KVSHistoryRuleIndex struct {
+}
+
+// FromObject is used to compute the index key when inserting or updating an
+// object.
+func (*KVSHistoryRuleIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	rule, ok := obj.(*structs.KVSHistoryRule)
+	if !ok {
+		return false, nil, fmt.Errorf("invalid object given to index as KV history rule")
+	}
+
+	// Always prepend a null so that we can represent even an empty prefix.
+	out := "\x00" + rule.Prefix
+	return true, []byte(out), nil
+}
+
+// FromArgs is used when querying for an exact match. Since we don't add any
+// suffix we can just call the prefix version.
+func (r *KVSHistoryRuleIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return r.PrefixFromArgs(args...)
+}
+
+// PrefixFromArgs is used when doing a prefix scan for an object.
+func (*KVSHistoryRuleIndex) PrefixFromArgs(args ...interface{}) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("must provide only a single argument")
+	}
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a string: %#v", args[0])
+	}
+	arg = "\x00" + arg
+	return []byte(arg), nil
+}
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -425,6 +426,129 @@ func TestStateStore_KVSList(t *testing.T) {
 	}
 }
 
+func TestStateStore_KVSUsage(t *testing.T) {
+	s := testStateStore(t)
+
+	// An empty KVS has no usage.
+	idx, keys, bytes, err := s.KVSUsage("")
+	if idx != 0 || keys != 0 || bytes != 0 || err != nil {
+		t.Fatalf("bad: %d %d %d %v", idx, keys, bytes, err)
+	}
+
+	// Create some KVS entries.
+	testSetKey(t, s, 1, "foo", "foo")
+	testSetKey(t, s, 2, "foo/bar", "bar")
+	testSetKey(t, s, 3, "foo/bar/zip", "zippy")
+	testSetKey(t, s, 4, "other", "")
+
+	// Count everything.
+	idx, keys, bytes, err = s.KVSUsage("")
+	if idx != 4 || keys != 4 || bytes != 11 || err != nil {
+		t.Fatalf("bad: %d %d %d %v", idx, keys, bytes, err)
+	}
+
+	// Count a prefix.
+	idx, keys, bytes, err = s.KVSUsage("foo/")
+	if idx != 4 || keys != 2 || bytes != 8 || err != nil {
+		t.Fatalf("bad: %d %d %d %v", idx, keys, bytes, err)
+	}
+
+	// Deleted keys no longer count.
+	if err := s.KVSDelete(5, "foo/bar"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	idx, keys, bytes, err = s.KVSUsage("foo/")
+	if idx != 5 || keys != 1 || bytes != 5 || err != nil {
+		t.Fatalf("bad: %d %d %d %v", idx, keys, bytes, err)
+	}
+}
+
+func TestStateStore_KVSTrackUsage(t *testing.T) {
+	s := testStateStore(t)
+
+	// Start tracking after some keys are already there.
+	testSetKey(t, s, 1, "foo/a", "aaa")
+	testSetKey(t, s, 2, "bar/a", "a")
+	if err := s.KVSTrackUsage([]string{"foo/", "bar/", ""}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	verify := func(prefix string, keys int, bytes int64) {
+		_, k, b, err := s.KVSUsage(prefix)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if k != keys || b != bytes {
+			t.Fatalf("prefix %q bad: %d %d", prefix, k, b)
+		}
+
+		// The running count should match a fresh one.
+		tx := s.db.Txn(false)
+		defer tx.Abort()
+		k, b, err = kvsUsageScanTxn(tx, prefix)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if k != keys || b != bytes {
+			t.Fatalf("prefix %q bad scan: %d %d", prefix, k, b)
+		}
+	}
+	verify("foo/", 1, 3)
+	verify("bar/", 1, 1)
+	verify("", 2, 4)
+
+	// Adding and updating keys.
+	testSetKey(t, s, 3, "foo/b", "bb")
+	testSetKey(t, s, 4, "foo/a", "a")
+	testSetKey(t, s, 5, "foo/c/d", "dddd")
+	verify("foo/", 3, 7)
+	verify("", 4, 8)
+
+	// Deleting keys.
+	if err := s.KVSDelete(6, "foo/b"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	verify("foo/", 2, 5)
+	if err := s.KVSDeleteTree(7, "foo/c/"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	verify("foo/", 1, 1)
+	verify("bar/", 1, 1)
+	verify("", 2, 2)
+
+	// A transaction that fails doesn't change the usage.
+	ops := structs.TxnOps{
+		&structs.TxnOp{
+			KV: &structs.TxnKVOp{
+				Verb:   structs.KVSSet,
+				DirEnt: structs.DirEntry{Key: "foo/x", Value: []byte("xx")},
+			},
+		},
+		&structs.TxnOp{
+			KV: &structs.TxnKVOp{
+				Verb:   structs.KVSCheckIndex,
+				DirEnt: structs.DirEntry{Key: "foo/a", RaftIndex: structs.RaftIndex{ModifyIndex: 1}},
+			},
+		},
+	}
+	if _, errors := s.TxnRW(8, ops); len(errors) == 0 {
+		t.Fatalf("should fail")
+	}
+	verify("foo/", 1, 1)
+
+	// Dropping a prefix stops tracking it.
+	if err := s.KVSTrackUsage([]string{"foo/"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	prefixes, err := s.KVSUsagePrefixes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(prefixes, []string{"foo/"}) {
+		t.Fatalf("bad: %v", prefixes)
+	}
+	verify("bar/", 1, 1)
+}
+
 func TestStateStore_KVSListKeys(t *testing.T) {
 	s := testStateStore(t)
 
@@ -534,6 +658,164 @@ func TestStateStore_KVSListKeys(t *testing.T) {
 	}
 }
 
+func TestStateStore_KVSListPage(t *testing.T) {
+	s := testStateStore(t)
+
+	// Create some KVS entries
+	testSetKey(t, s, 1, "foo/a", "a")
+	testSetKey(t, s, 2, "foo/b", "b")
+	testSetKey(t, s, 3, "foo/c", "c")
+	testSetKey(t, s, 4, "foo/d", "d")
+	testSetKey(t, s, 5, "zip", "zip")
+
+	// Page through the prefix
+	cases := []struct {
+		after string
+		limit int
+		keys  []string
+		next  string
+	}{
+		{"", 0, []string{"foo/a", "foo/b", "foo/c", "foo/d"}, ""},
+		{"", 2, []string{"foo/a", "foo/b"}, "foo/b"},
+		{"foo/b", 2, []string{"foo/c", "foo/d"}, ""},
+		{"foo/a", 4, []string{"foo/b", "foo/c", "foo/d"}, ""},
+		{"foo/bb", 1, []string{"foo/c"}, "foo/c"},
+		{"foo/d", 1, nil, ""},
+		{"foo/\xff", 1, nil, ""},
+		{"fo", 1, []string{"foo/a"}, "foo/a"},
+		{"fop", 1, nil, ""},
+	}
+	for _, tc := range cases {
+		idx, entries, next, err := s.KVSListPage("foo/", tc.after, tc.limit)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		// A page uses the table index, and a full listing uses the
+		// index for the prefix.
+		expected := uint64(5)
+		if tc.after == "" && tc.limit == 0 {
+			expected = 4
+		}
+		if idx != expected {
+			t.Fatalf("bad index: %d", idx)
+		}
+		var keys []string
+		for _, entry := range entries {
+			keys = append(keys, entry.Key)
+		}
+		if !reflect.DeepEqual(keys, tc.keys) || next != tc.next {
+			t.Fatalf("after %q limit %d bad: %v %q", tc.after, tc.limit, keys, next)
+		}
+	}
+}
+
+func TestStateStore_KVSListPage_Walk(t *testing.T) {
+	s := testStateStore(t)
+
+	// Create keys that exercise the byte by byte seeking.
+	keys := []string{
+		"foo", "foo/", "foo/a", "foo/a/b", "foo/a/b/c", "foo/a\xff",
+		"foo/a\xff\xff", "foo/b/", "foo/b/1", "foo/b/2", "foo/b0",
+		"foo/\xff", "foo/\xff/x", "foo0", "zip",
+	}
+	for i, key := range keys {
+		testSetKey(t, s, uint64(i+1), key, key)
+	}
+
+	// Walking the pages should give the same results as a full listing.
+	for _, limit := range []int{1, 2, 3, 100} {
+		_, full, err := s.KVSList("foo/")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		var paged structs.DirEntries
+		var after string
+		for {
+			_, entries, next, err := s.KVSListPage("foo/", after, limit)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			paged = append(paged, entries...)
+			if next == "" {
+				break
+			}
+			after = next
+		}
+		if !reflect.DeepEqual(paged, full) {
+			t.Fatalf("limit %d bad: %v", limit, paged)
+		}
+
+		_, fullKeys, err := s.KVSListKeys("foo/", "/")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		var pagedKeys []string
+		after = ""
+		for {
+			_, keys, next, err := s.KVSListKeysPage("foo/", "/", after, limit)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			pagedKeys = append(pagedKeys, keys...)
+			if next == "" {
+				break
+			}
+			after = next
+		}
+		if !reflect.DeepEqual(pagedKeys, fullKeys) {
+			t.Fatalf("limit %d bad: %v %v", limit, pagedKeys, fullKeys)
+		}
+	}
+}
+
+func TestStateStore_KVSListKeysPage(t *testing.T) {
+	s := testStateStore(t)
+
+	// Create some KVS entries
+	testSetKey(t, s, 1, "foo/a", "a")
+	testSetKey(t, s, 2, "foo/b/1", "b1")
+	testSetKey(t, s, 3, "foo/b/2", "b2")
+	testSetKey(t, s, 4, "foo/c", "c")
+	testSetKey(t, s, 5, "foo/d/1", "d1")
+
+	// Page through the prefix using a separator
+	cases := []struct {
+		after string
+		limit int
+		keys  []string
+		next  string
+	}{
+		{"", 0, []string{"foo/a", "foo/b/", "foo/c", "foo/d/"}, ""},
+		{"", 2, []string{"foo/a", "foo/b/"}, "foo/b/"},
+		{"foo/b/", 1, []string{"foo/c"}, "foo/c"},
+		{"foo/c", 1, []string{"foo/d/"}, ""},
+		{"foo/b/1", 1, []string{"foo/c"}, "foo/c"},
+		{"foo/b", 3, []string{"foo/b/", "foo/c", "foo/d/"}, ""},
+	}
+	for _, tc := range cases {
+		idx, keys, next, err := s.KVSListKeysPage("foo/", "/", tc.after, tc.limit)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if idx != 5 {
+			t.Fatalf("bad index: %d", idx)
+		}
+		if !reflect.DeepEqual(keys, tc.keys) || next != tc.next {
+			t.Fatalf("after %q limit %d bad: %v %q", tc.after, tc.limit, keys, next)
+		}
+	}
+
+	// Without a separator every key is its own entry
+	_, keys, next, err := s.KVSListKeysPage("foo/", "", "foo/b/1", 2)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"foo/b/2", "foo/c"}) || next != "foo/c" {
+		t.Fatalf("bad: %v %q", keys, next)
+	}
+}
+
 func TestStateStore_KVSDelete(t *testing.T) {
 	s := testStateStore(t)
 
@@ -1538,3 +1820,415 @@ func TestStateStore_Tombstone_Snapshot_Restore(t *testing.T) {
 		}
 	}()
 }
+
+func TestStateStore_KVSHistory(t *testing.T) {
+	s := testStateStore(t)
+
+	// Keep two versions under foo/ and everything under foo/bar/.
+	rules := structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: "foo/", Versions: 2},
+		&structs.KVSHistoryRule{Prefix: "foo/bar/"},
+	}
+	verifyWatch(t, s.GetQueryWatch("KVSHistoryRules"), func() {
+		if err := s.KVSHistorySetRules(1, rules); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	})
+	idx, out, err := s.KVSHistoryRules()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 1 || !reflect.DeepEqual(out, rules) {
+		t.Fatalf("bad: %d %#v", idx, out)
+	}
+
+	// Write a few versions of some keys.
+	for i, key := range []string{"foo/a", "foo/a", "foo/a", "foo/a", "foo/bar/b", "foo/bar/b", "foo/bar/b", "other"} {
+		entry := &structs.DirEntry{Key: key, Value: []byte(fmt.Sprintf("%d", i+2))}
+		if err := s.KVSSet(uint64(i+2), entry); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := s.KVSSet(10, &structs.DirEntry{Key: "other", Value: []byte("10")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Only the last two replaced versions of foo/a are kept.
+	idx, versions, err := s.KVSHistory("foo/a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 10 || len(versions) != 2 {
+		t.Fatalf("bad: %d %#v", idx, versions)
+	}
+	if string(versions[0].Value) != "3" || versions[0].ModifyIndex != 3 || versions[0].ReplacedIndex != 4 {
+		t.Fatalf("bad: %#v", versions[0])
+	}
+	if string(versions[1].Value) != "4" || versions[1].ModifyIndex != 4 || versions[1].ReplacedIndex != 5 {
+		t.Fatalf("bad: %#v", versions[1])
+	}
+
+	// The more specific rule keeps everything.
+	_, versions, err = s.KVSHistory("foo/bar/b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// Keys without a rule have no history.
+	_, versions, err = s.KVSHistory("other")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 0 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// Deletes keep the deleted version.
+	if err := s.KVSDelete(11, "foo/a"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.KVSDeleteTree(12, "foo/bar/"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, versions, err = s.KVSHistory("foo/a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 2 || string(versions[1].Value) != "5" || versions[1].ReplacedIndex != 11 {
+		t.Fatalf("bad: %#v", versions)
+	}
+	_, versions, err = s.KVSHistory("foo/bar/b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 3 || string(versions[2].Value) != "8" || versions[2].ReplacedIndex != 12 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// Changing the rules leaves the kept versions alone.
+	rules = structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: "foo/bar/", Versions: 1},
+	}
+	if err := s.KVSHistorySetRules(13, rules); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, versions, err = s.KVSHistory("foo/a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("bad: %#v", versions)
+	}
+	_, versions, err = s.KVSHistory("foo/bar/b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// The next version that's kept brings the key in line with its new
+	// rule.
+	if err := s.KVSSet(14, &structs.DirEntry{Key: "foo/bar/b", Value: []byte("14")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.KVSSet(15, &structs.DirEntry{Key: "foo/bar/b", Value: []byte("15")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, versions, err = s.KVSHistory("foo/bar/b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 1 || string(versions[0].Value) != "14" {
+		t.Fatalf("bad: %#v", versions)
+	}
+
+	// Versions that are no longer covered are only dropped by pruning.
+	ok, err := s.KVSHistoryUncoveredPrunable(11)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !ok {
+		t.Fatalf("should be prunable")
+	}
+	if err := s.KVSHistoryPruneUncovered(16, 100); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	idx, versions, err = s.KVSHistory("foo/a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 16 || len(versions) != 0 {
+		t.Fatalf("bad: %d %#v", idx, versions)
+	}
+	_, versions, err = s.KVSHistory("foo/bar/b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("bad: %#v", versions)
+	}
+}
+
+func TestStateStore_KVSHistory_Txn(t *testing.T) {
+	s := testStateStore(t)
+
+	rules := structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: ""},
+	}
+	if err := s.KVSHistorySetRules(1, rules); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.KVSSet(2, &structs.DirEntry{Key: "foo", Value: []byte("a")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Versions written and replaced in the same transaction were never
+	// visible, so only the version from before it is kept.
+	ops := structs.TxnOps{
+		&structs.TxnOp{
+			KV: &structs.TxnKVOp{
+				Verb:   structs.KVSSet,
+				DirEnt: structs.DirEntry{Key: "foo", Value: []byte("b")},
+			},
+		},
+		&structs.TxnOp{
+			KV: &structs.TxnKVOp{
+				Verb:   structs.KVSSet,
+				DirEnt: structs.DirEntry{Key: "foo", Value: []byte("c")},
+			},
+		},
+	}
+	if _, errors := s.TxnRW(3, ops); len(errors) != 0 {
+		t.Fatalf("err: %v", errors)
+	}
+	_, versions, err := s.KVSHistory("foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 1 || string(versions[0].Value) != "a" {
+		t.Fatalf("bad: %#v", versions)
+	}
+}
+
+func TestStateStore_KVSGetAt(t *testing.T) {
+	s := testStateStore(t)
+
+	rules := structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: "foo"},
+	}
+	if err := s.KVSHistorySetRules(1, rules); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.KVSSet(2, &structs.DirEntry{Key: "foo", Value: []byte("a"), Flags: 1}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.KVSSet(4, &structs.DirEntry{Key: "foo", Value: []byte("b")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.KVSDelete(6, "foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.KVSSet(8, &structs.DirEntry{Key: "foo", Value: []byte("c")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		at    uint64
+		value string
+	}{
+		{1, ""},
+		{2, "a"},
+		{3, "a"},
+		{4, "b"},
+		{5, "b"},
+		{6, ""},
+		{7, ""},
+		{8, "c"},
+		{100, "c"},
+	}
+	for _, tc := range cases {
+		idx, entry, err := s.KVSGetAt("foo", tc.at)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if idx != 8 {
+			t.Fatalf("bad index: %d", idx)
+		}
+		if tc.value == "" {
+			if entry != nil {
+				t.Fatalf("at %d bad: %#v", tc.at, entry)
+			}
+			continue
+		}
+		if entry == nil || string(entry.Value) != tc.value {
+			t.Fatalf("at %d bad: %#v", tc.at, entry)
+		}
+	}
+
+	// The version's details come along.
+	_, entry, err := s.KVSGetAt("foo", 3)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry.Flags != 1 || entry.CreateIndex != 2 || entry.ModifyIndex != 2 {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func TestStateStore_KVSHistoryPrune(t *testing.T) {
+	s := testStateStore(t)
+
+	rules := structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: "foo/", Window: time.Hour},
+		&structs.KVSHistoryRule{Prefix: "foo/bar/"},
+	}
+	if err := s.KVSHistorySetRules(1, rules); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for i, key := range []string{"foo/a", "foo/bar/b", "foo/a", "foo/bar/b", "foo/a", "foo/bar/b"} {
+		if err := s.KVSSet(uint64(i+2), &structs.DirEntry{Key: key}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Nothing was replaced before index 4.
+	ok, err := s.KVSHistoryPrunable("foo/", 4)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ok {
+		t.Fatalf("should not be prunable")
+	}
+
+	// Pruning only touches the versions kept by the given rule.
+	ok, err = s.KVSHistoryPrunable("foo/", 5)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !ok {
+		t.Fatalf("should be prunable")
+	}
+	verifyWatch(t, s.GetKVSWatch("foo/a"), func() {
+		if err := s.KVSHistoryPrune(10, "foo/", 100); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	})
+	idx, versions, err := s.KVSHistory("foo/a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 10 || len(versions) != 0 {
+		t.Fatalf("bad: %d %#v", idx, versions)
+	}
+	_, versions, err = s.KVSHistory("foo/bar/b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("bad: %#v", versions)
+	}
+}
+
+func TestStateStore_KVSHistory_Snapshot_Restore(t *testing.T) {
+	s := testStateStore(t)
+
+	rules := structs.KVSHistoryRules{
+		&structs.KVSHistoryRule{Prefix: "", Versions: 5, Window: time.Hour},
+	}
+	if err := s.KVSHistorySetRules(1, rules); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for i := 2; i < 5; i++ {
+		entry := &structs.DirEntry{Key: "foo", Value: []byte(fmt.Sprintf("%d", i))}
+		if err := s.KVSSet(uint64(i), entry); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	_, versions, err := s.KVSHistory("foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Snapshot the history.
+	snap := s.Snapshot()
+	defer snap.Close()
+
+	// Alter the real state store.
+	if err := s.KVSDelete(5, "foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Verify the snapshot.
+	iter, err := snap.KVSHistoryRules()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var ruleDump structs.KVSHistoryRules
+	for rule := iter.Next(); rule != nil; rule = iter.Next() {
+		ruleDump = append(ruleDump, rule.(*structs.KVSHistoryRule))
+	}
+	if !reflect.DeepEqual(ruleDump, rules) {
+		t.Fatalf("bad: %#v", ruleDump)
+	}
+	rulesIdx := snap.KVSHistoryRulesIndex()
+	if rulesIdx != 1 {
+		t.Fatalf("bad index: %d", rulesIdx)
+	}
+	iter, err = snap.KVSHistory()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var dump structs.KVSHistoryEntries
+	for entry := iter.Next(); entry != nil; entry = iter.Next() {
+		dump = append(dump, entry.(*structs.KVSHistoryEntry))
+	}
+	if !reflect.DeepEqual(dump, versions) {
+		t.Fatalf("bad: %#v", dump)
+	}
+
+	// Restore the values into a new state store.
+	func() {
+		s := testStateStore(t)
+		restore := s.Restore()
+		if err := restore.KVSHistoryRulesIndex(rulesIdx); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		for _, rule := range ruleDump {
+			if err := restore.KVSHistoryRule(rule); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+		for _, entry := range dump {
+			if err := restore.KVSHistory(entry); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+		restore.Commit()
+
+		// Read the restored history back out and verify it matches.
+		idx, res, err := s.KVSHistoryRules()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if idx != 1 {
+			t.Fatalf("bad index: %d", idx)
+		}
+		if !reflect.DeepEqual(res, rules) {
+			t.Fatalf("bad: %#v", res)
+		}
+		_, out, err := s.KVSHistory("foo")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !reflect.DeepEqual(out, versions) {
+			t.Fatalf("bad: %#v", out)
+		}
+		if idx := s.maxIndex("kvs_history"); idx != 4 {
+			t.Fatalf("bad index: %d", idx)
+		}
+	}()
+}
@@ -0,0 +1,43 @@
+package state
+
+import (
+	"fmt"
+)
+
+// KVSUsageIndex is a custom memdb indexer used to index the KV usage
+// counters by prefix. The built-in string indexer doesn't allow the empty
+// prefix, which is used for a quota covering every key.
+type KVSUsageIndex struct {
+}
+
+// FromObject is used to compute the index key when inserting or updating an
+// object.
+func (*KVSUsageIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	usage, ok := obj.(*KVSUsageEntry)
+	if !ok {
+		return false, nil, fmt.Errorf("invalid object given to index as KV usage")
+	}
+
+	// Always prepend a null so that we can represent even an empty prefix.
+	out := "\x00" + usage.Prefix
+	return true, []byte(out), nil
+}
+
+// FromArgs is used when querying for an exact match. Since we don't add any
+// suffix we can just call the prefix version.
+func (u *KVSUsageIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return u.PrefixFromArgs(args...)
+}
+
+// PrefixFromArgs is used when doing a prefix scan for an object.
+func (*KVSUsageIndex) PrefixFromArgs(args ...interface{}) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("must provide only a single argument")
+	}
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a string: %#v", args[0])
+	}
+	arg = "\x00" + arg
+	return []byte(arg), nil
+}
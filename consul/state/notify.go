@@ -47,6 +47,13 @@ func (n *NotifyGroup) Clear(ch chan struct{}) {
 	delete(n.notify, ch)
 }
 
+// Empty returns true if there are no channels waiting on the group.
+func (n *NotifyGroup) Empty() bool {
+	n.l.Lock()
+	defer n.l.Unlock()
+	return len(n.notify) == 0
+}
+
 // WaitCh allocates a channel that is subscribed to notifications
 func (n *NotifyGroup) WaitCh() chan struct{} {
 	ch := make(chan struct{}, 1)
@@ -25,10 +25,14 @@ func stateStoreSchema() *memdb.DBSchema {
 		servicesTableSchema,
 		checksTableSchema,
 		kvsTableSchema,
+		kvsHistoryTableSchema,
+		kvsHistoryRulesTableSchema,
+		kvsUsageTableSchema,
 		tombstonesTableSchema,
 		sessionsTableSchema,
 		sessionChecksTableSchema,
 		aclsTableSchema,
+		aclPoliciesTableSchema,
 		coordinatesTableSchema,
 		preparedQueriesTableSchema,
 	}
@@ -227,6 +231,55 @@ func kvsTableSchema() *memdb.TableSchema {
 	}
 }
 
+// kvsHistoryTableSchema returns a new table schema used for storing the
+// prior versions of KV entries that are covered by a history rule.
+func kvsHistoryTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "kvs_history",
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer:      &KVSHistoryIndex{},
+			},
+		},
+	}
+}
+
+// kvsHistoryRulesTableSchema returns a new table schema used for storing
+// the rules that decide which KV entries have their history kept.
+func kvsHistoryRulesTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "kvs_history_rules",
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer:      &KVSHistoryRuleIndex{},
+			},
+		},
+	}
+}
+
+// kvsUsageTableSchema returns a new table schema used for keeping a running
+// count of the usage of KV prefixes. This is derived from the KV entries so
+// it's not part of snapshots.
+func kvsUsageTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "kvs_usage",
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer:      &KVSUsageIndex{},
+			},
+		},
+	}
+}
+
 // tombstonesTableSchema returns a new table schema used for
 // storing tombstones during KV delete operations to prevent
 // the index from sliding backwards.
@@ -348,6 +401,34 @@ func aclsTableSchema() *memdb.TableSchema {
 	}
 }
 
+// aclPoliciesTableSchema returns a new table schema used for storing the
+// ACL policies that can be linked to tokens.
+func aclPoliciesTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "acl-policies",
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:         "id",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "ID",
+					Lowercase: false,
+				},
+			},
+			"name": &memdb.IndexSchema{
+				Name:         "name",
+				AllowMissing: false,
+				Unique:       true,
+				Indexer: &memdb.StringFieldIndex{
+					Field:     "Name",
+					Lowercase: true,
+				},
+			},
+		},
+	}
+}
+
 // coordinatesTableSchema returns a new table schema used for storing
 // network coordinates.
 func coordinatesTableSchema() *memdb.TableSchema {
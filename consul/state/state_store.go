@@ -3,7 +3,7 @@ package state
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"reflect"
 	"time"
 
 	"github.com/hashicorp/consul/consul/structs"
@@ -29,6 +29,10 @@ var (
 	// an ACL with an empty ID.
 	ErrMissingACLID = errors.New("Missing ACL ID")
 
+	// ErrMissingACLPolicyID is returned when an ACL policy set is called
+	// on a policy with an empty ID.
+	ErrMissingACLPolicyID = errors.New("Missing ACL policy ID")
+
 	// ErrMissingQueryID is returned when a Query set is called on
 	// a Query with an empty ID.
 	ErrMissingQueryID = errors.New("Missing Query ID")
@@ -48,6 +52,15 @@ type StateStore struct {
 	// kvsWatch holds the special prefix watch for the key value store.
 	kvsWatch *PrefixWatchManager
 
+	// serviceWatch holds watches for individual services, by name. These
+	// fire when anything in the catalog or health view of the service
+	// changes, including the nodes and node-level checks it depends on.
+	serviceWatch *KeyWatchManager
+
+	// nodeWatch holds watches for individual nodes, by name. These fire
+	// when the node, or any of its services or checks change.
+	nodeWatch *KeyWatchManager
+
 	// kvsGraveyard manages tombstones for the key value store.
 	kvsGraveyard *Graveyard
 
@@ -112,6 +125,8 @@ func NewStateStore(gc *TombstoneGC) (*StateStore, error) {
 		db:           db,
 		tableWatches: tableWatches,
 		kvsWatch:     NewPrefixWatchManager(),
+		serviceWatch: NewKeyWatchManager(),
+		nodeWatch:    NewKeyWatchManager(),
 		kvsGraveyard: NewGraveyard(gc),
 		lockDelay:    NewDelay(),
 	}
@@ -218,6 +233,8 @@ func (s *StateRestore) Commit() {
 	// Fire off a single KVS watch instead of a zillion prefix ones, and use
 	// a dumb watch manager to single-fire all the full table watches.
 	s.tx.Defer(func() { s.store.kvsWatch.Notify("", true) })
+	s.tx.Defer(func() { s.store.serviceWatch.NotifyAll() })
+	s.tx.Defer(func() { s.store.nodeWatch.NotifyAll() })
 	s.tx.Defer(func() { s.watches.Notify() })
 
 	s.tx.Commit()
@@ -353,14 +370,22 @@ func (s *StateStore) getWatchTables(method string) []string {
 		return []string{"nodes", "services"}
 	case "NodeCheck", "NodeChecks", "ServiceChecks", "ChecksInState":
 		return []string{"checks"}
-	case "CheckServiceNodes", "NodeInfo", "NodeDump":
+	case "CheckServiceNodes", "NodeCheckServiceNodes", "NodeInfo", "NodeDump":
 		return []string{"nodes", "services", "checks"}
 	case "SessionGet", "SessionList", "NodeSessions":
 		return []string{"sessions"}
-	case "ACLGet", "ACLList":
+	case "ACLGet":
 		return []string{"acls"}
+	case "ACLList":
+		// The rules of the tokens come from their policies too, and
+		// this also lets ACL replication find out about policy changes.
+		return []string{"acls", "acl-policies"}
+	case "ACLPolicyGet", "ACLPolicyList":
+		return []string{"acl-policies"}
 	case "Coordinates":
 		return []string{"coordinates"}
+	case "KVSHistoryRules":
+		return []string{"kvs_history_rules"}
 	case "PreparedQueryGet", "PreparedQueryResolve", "PreparedQueryList":
 		return []string{"prepared-queries"}
 	}
@@ -399,6 +424,36 @@ func (s *StateStore) GetKVSWatch(prefix string) Watch {
 	return s.kvsWatch.NewPrefixWatch(prefix)
 }
 
+// GetServiceWatch returns a watch that fires when the catalog or health
+// information for the given service changes. This is much narrower than the
+// query watches for the service endpoints, which fire when anything in the
+// nodes, services or checks tables changes.
+func (s *StateStore) GetServiceWatch(service string) Watch {
+	return s.serviceWatch.NewKeyWatch(service)
+}
+
+// GetNodeWatch returns a watch that fires when the given node, or any of its
+// services or checks change.
+func (s *StateStore) GetNodeWatch(node string) Watch {
+	return s.nodeWatch.NewKeyWatch(node)
+}
+
+// armNodeWatches arms the watch for the given node, as well as the watches
+// for all the services registered on it, since they include node-level
+// information like the address and node checks.
+func (s *StateStore) armNodeWatches(tx *memdb.Txn, watches *DumbWatchManager, node string) error {
+	watches.ArmKey(s.nodeWatch, node)
+
+	services, err := tx.Get("services", "node", node)
+	if err != nil {
+		return fmt.Errorf("failed service lookup: %s", err)
+	}
+	for service := services.Next(); service != nil; service = services.Next() {
+		watches.ArmKey(s.serviceWatch, service.(*structs.ServiceNode).ServiceName)
+	}
+	return nil
+}
+
 // EnsureRegistration is used to make sure a node, service, and check
 // registration is performed within a single transaction to avoid race
 // conditions on state updates.
@@ -423,9 +478,11 @@ func (s *StateStore) ensureRegistrationTxn(tx *memdb.Txn, idx uint64, watches *D
 	req *structs.RegisterRequest) error {
 	// Add the node.
 	node := &structs.Node{
+		ID:              req.ID,
 		Node:            req.Node,
 		Address:         req.Address,
 		TaggedAddresses: req.TaggedAddresses,
+		Meta:            req.NodeMeta,
 	}
 	if err := s.ensureNodeTxn(tx, idx, watches, node); err != nil {
 		return fmt.Errorf("failed inserting node: %s", err)
@@ -488,11 +545,30 @@ func (s *StateStore) ensureNodeTxn(tx *memdb.Txn, idx uint64, watches *DumbWatch
 
 	// Get the indexes
 	if existing != nil {
+		// A node name can't be claimed by a different node ID. If no ID
+		// was given, keep the one we already have.
+		existingID := existing.(*structs.Node).ID
+		if node.ID == "" {
+			node.ID = existingID
+		} else if existingID != "" && node.ID != existingID {
+			return fmt.Errorf("node name %q is in use by another node ID %q",
+				node.Node, existingID)
+		}
+
 		node.CreateIndex = existing.(*structs.Node).CreateIndex
 		node.ModifyIndex = idx
+
+		// Nodes are re-written with every registration, so only wake
+		// up the node and service watches if something changed.
+		if nodeChanged(existing.(*structs.Node), node) {
+			if err := s.armNodeWatches(tx, watches, node.Node); err != nil {
+				return err
+			}
+		}
 	} else {
 		node.CreateIndex = idx
 		node.ModifyIndex = idx
+		watches.ArmKey(s.nodeWatch, node.Node)
 	}
 
 	// Insert the node and update the index
@@ -507,6 +583,15 @@ func (s *StateStore) ensureNodeTxn(tx *memdb.Txn, idx uint64, watches *DumbWatch
 	return nil
 }
 
+// nodeChanged returns true if the given node differs from the existing one
+// in any of the fields visible to queries.
+func nodeChanged(existing, node *structs.Node) bool {
+	return existing.ID != node.ID ||
+		existing.Address != node.Address ||
+		!reflect.DeepEqual(existing.TaggedAddresses, node.TaggedAddresses) ||
+		!reflect.DeepEqual(existing.Meta, node.Meta)
+}
+
 // GetNode is used to retrieve a node registration by node ID.
 func (s *StateStore) GetNode(id string) (uint64, *structs.Node, error) {
 	tx := s.db.Txn(false)
@@ -548,6 +633,33 @@ func (s *StateStore) Nodes() (uint64, structs.Nodes, error) {
 	return idx, results, nil
 }
 
+// NodesByMeta is used to return all nodes with the given metadata key/value
+// pairs. A node must match all of the given filters to be returned.
+func (s *StateStore) NodesByMeta(filters map[string]string) (uint64, structs.Nodes, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	// Get the table index.
+	idx := maxIndexTxn(tx, s.getWatchTables("Nodes")...)
+
+	// Retrieve all of the nodes. Metadata is a free-form map so memdb
+	// can't index it for us; we scan and filter instead.
+	nodes, err := tx.Get("nodes", "id")
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed nodes lookup: %s", err)
+	}
+
+	// Create and return the filtered nodes list.
+	var results structs.Nodes
+	for node := nodes.Next(); node != nil; node = nodes.Next() {
+		n := node.(*structs.Node)
+		if structs.SatisfiesMetaFilters(n.Meta, filters) {
+			results = append(results, n)
+		}
+	}
+	return idx, results, nil
+}
+
 // DeleteNode is used to delete a given node by its ID.
 func (s *StateStore) DeleteNode(idx uint64, nodeID string) error {
 	tx := s.db.Txn(true)
@@ -577,6 +689,9 @@ func (s *StateStore) deleteNodeTxn(tx *memdb.Txn, idx uint64, nodeID string) err
 	// Use a watch manager since the inner functions can perform multiple
 	// ops per table.
 	watches := NewDumbWatchManager(s.tableWatches)
+	if err := s.armNodeWatches(tx, watches, nodeID); err != nil {
+		return err
+	}
 
 	// Delete all services associated with the node and update the service index.
 	services, err := tx.Get("services", "node", nodeID)
@@ -692,6 +807,10 @@ func (s *StateStore) ensureServiceTxn(tx *memdb.Txn, idx uint64, watches *DumbWa
 	if existing != nil {
 		entry.CreateIndex = existing.(*structs.ServiceNode).CreateIndex
 		entry.ModifyIndex = idx
+
+		// If the service was renamed then watchers of the old name
+		// need to know it's gone.
+		watches.ArmKey(s.serviceWatch, existing.(*structs.ServiceNode).ServiceName)
 	} else {
 		entry.CreateIndex = idx
 		entry.ModifyIndex = idx
@@ -715,6 +834,8 @@ func (s *StateStore) ensureServiceTxn(tx *memdb.Txn, idx uint64, watches *DumbWa
 	}
 
 	watches.Arm("services")
+	watches.ArmKey(s.serviceWatch, entry.ServiceName)
+	watches.ArmKey(s.nodeWatch, node)
 	return nil
 }
 
@@ -785,8 +906,10 @@ func (s *StateStore) ServiceNodes(serviceName string) (uint64, structs.ServiceNo
 }
 
 // ServiceTagNodes returns the nodes associated with a given service, filtering
-// out services that don't contain the given tag.
-func (s *StateStore) ServiceTagNodes(service, tag string) (uint64, structs.ServiceNodes, error) {
+// out services that don't have ALL of the given tags, or that have any of the
+// tags prefixed with "!". The service index is used to narrow the candidates
+// before the tags are checked.
+func (s *StateStore) ServiceTagNodes(service string, tags []string) (uint64, structs.ServiceNodes, error) {
 	tx := s.db.Txn(false)
 	defer tx.Abort()
 
@@ -800,10 +923,11 @@ func (s *StateStore) ServiceTagNodes(service, tag string) (uint64, structs.Servi
 	}
 
 	// Gather all the services and apply the tag filter.
+	filter := structs.NewServiceTagFilter(tags)
 	var results structs.ServiceNodes
 	for service := services.Next(); service != nil; service = services.Next() {
 		svc := service.(*structs.ServiceNode)
-		if !serviceTagFilter(svc, tag) {
+		if filter.Matches(svc.ServiceTags) {
 			results = append(results, svc)
 		}
 	}
@@ -816,22 +940,6 @@ func (s *StateStore) ServiceTagNodes(service, tag string) (uint64, structs.Servi
 	return idx, results, nil
 }
 
-// serviceTagFilter returns true (should filter) if the given service node
-// doesn't contain the given tag.
-func serviceTagFilter(sn *structs.ServiceNode, tag string) bool {
-	tag = strings.ToLower(tag)
-
-	// Look for the lower cased version of the tag.
-	for _, t := range sn.ServiceTags {
-		if strings.ToLower(t) == tag {
-			return false
-		}
-	}
-
-	// If we didn't hit the tag above then we should filter.
-	return true
-}
-
 // parseServiceNodes iterates over a services query and fills in the node details,
 // returning a ServiceNodes slice.
 func (s *StateStore) parseServiceNodes(tx *memdb.Txn, services structs.ServiceNodes) (structs.ServiceNodes, error) {
@@ -854,6 +962,7 @@ func (s *StateStore) parseServiceNodes(tx *memdb.Txn, services structs.ServiceNo
 		node := n.(*structs.Node)
 		s.Address = node.Address
 		s.TaggedAddresses = node.TaggedAddresses
+		s.NodeMeta = node.Meta
 
 		results = append(results, s)
 	}
@@ -981,6 +1090,8 @@ func (s *StateStore) deleteServiceTxn(tx *memdb.Txn, idx uint64, watches *DumbWa
 	}
 
 	watches.Arm("services")
+	watches.ArmKey(s.serviceWatch, service.(*structs.ServiceNode).ServiceName)
+	watches.ArmKey(s.nodeWatch, nodeID)
 	return nil
 }
 
@@ -1080,10 +1191,33 @@ func (s *StateStore) ensureCheckTxn(tx *memdb.Txn, idx uint64, watches *DumbWatc
 		return fmt.Errorf("failed updating index: %s", err)
 	}
 
+	// If the check moved to a different service then the old one is
+	// affected as well.
+	if existing != nil && existing.(*structs.HealthCheck).ServiceID != hc.ServiceID {
+		if err := s.armCheckWatches(tx, watches, existing.(*structs.HealthCheck)); err != nil {
+			return err
+		}
+	}
+	if err := s.armCheckWatches(tx, watches, hc); err != nil {
+		return err
+	}
+
 	watches.Arm("checks")
 	return nil
 }
 
+// armCheckWatches arms the watches affected by a change to the given check.
+// Node-level checks affect the health of every service on the node.
+func (s *StateStore) armCheckWatches(tx *memdb.Txn, watches *DumbWatchManager, hc *structs.HealthCheck) error {
+	if hc.ServiceID == "" {
+		return s.armNodeWatches(tx, watches, hc.Node)
+	}
+
+	watches.ArmKey(s.nodeWatch, hc.Node)
+	watches.ArmKey(s.serviceWatch, hc.ServiceName)
+	return nil
+}
+
 // NodeCheck is used to retrieve a specific check associated with the given
 // node.
 func (s *StateStore) NodeCheck(nodeID string, checkID types.CheckID) (uint64, *structs.HealthCheck, error) {
@@ -1212,6 +1346,9 @@ func (s *StateStore) deleteCheckTxn(tx *memdb.Txn, idx uint64, watches *DumbWatc
 	if err := tx.Insert("index", &IndexEntry{"checks", idx}); err != nil {
 		return fmt.Errorf("failed updating index: %s", err)
 	}
+	if err := s.armCheckWatches(tx, watches, hc.(*structs.HealthCheck)); err != nil {
+		return err
+	}
 
 	// Delete any sessions for this check.
 	mappings, err := tx.Get("session_checks", "node_check", node, string(checkID))
@@ -1260,10 +1397,11 @@ func (s *StateStore) CheckServiceNodes(serviceName string) (uint64, structs.Chec
 }
 
 // CheckServiceTagNodes is used to query all nodes and checks for a given
-// service, filtering out services that don't contain the given tag. The results
+// service, filtering out services that don't satisfy the given tags (see
+// ServiceTagNodes for the matching rules). The results
 // are compounded into a CheckServiceNodes, and the index returned is the maximum
 // index observed over any node, check, or service in the result set.
-func (s *StateStore) CheckServiceTagNodes(serviceName, tag string) (uint64, structs.CheckServiceNodes, error) {
+func (s *StateStore) CheckServiceTagNodes(serviceName string, tags []string) (uint64, structs.CheckServiceNodes, error) {
 	tx := s.db.Txn(false)
 	defer tx.Abort()
 
@@ -1277,16 +1415,40 @@ func (s *StateStore) CheckServiceTagNodes(serviceName, tag string) (uint64, stru
 	}
 
 	// Return the results, filtering by tag.
+	filter := structs.NewServiceTagFilter(tags)
 	var results structs.ServiceNodes
 	for service := services.Next(); service != nil; service = services.Next() {
 		svc := service.(*structs.ServiceNode)
-		if !serviceTagFilter(svc, tag) {
+		if filter.Matches(svc.ServiceTags) {
 			results = append(results, svc)
 		}
 	}
 	return s.parseCheckServiceNodes(tx, idx, results, err)
 }
 
+// NodeCheckServiceNodes is used to query the nodes and checks for all of the
+// services registered on a given node, in the same form as CheckServiceNodes.
+func (s *StateStore) NodeCheckServiceNodes(nodeID string) (uint64, structs.CheckServiceNodes, error) {
+	tx := s.db.Txn(false)
+	defer tx.Abort()
+
+	// Get the table index.
+	idx := maxIndexTxn(tx, s.getWatchTables("NodeCheckServiceNodes")...)
+
+	// Query the state store for the node's services.
+	services, err := tx.Get("services", "node", nodeID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed service lookup: %s", err)
+	}
+
+	// Return the results.
+	var results structs.ServiceNodes
+	for service := services.Next(); service != nil; service = services.Next() {
+		results = append(results, service.(*structs.ServiceNode))
+	}
+	return s.parseCheckServiceNodes(tx, idx, results, err)
+}
+
 // parseCheckServiceNodes is used to parse through a given set of services,
 // and query for an associated node and a set of checks. This is the inner
 // method used to return a rich set of results from a more simple query.
@@ -1389,9 +1551,11 @@ func (s *StateStore) parseNodes(tx *memdb.Txn, idx uint64,
 
 		// Create the wrapped node
 		dump := &structs.NodeInfo{
+			ID:              node.ID,
 			Node:            node.Node,
 			Address:         node.Address,
 			TaggedAddresses: node.TaggedAddresses,
+			Meta:            node.Meta,
 		}
 
 		// Query the node services
@@ -1859,6 +2023,31 @@ func (s *StateStore) aclDeleteTxn(tx *memdb.Txn, idx uint64, aclID string) error
 	return nil
 }
 
+// ACLReap is used to delete a batch of expired ACLs in a single
+// transaction. Only ACLs that had expired as of the given time are deleted,
+// so an ACL whose expiration was pushed out after the batch was made is
+// left alone.
+func (s *StateStore) ACLReap(idx uint64, aclIDs []string, now time.Time) error {
+	tx := s.db.Txn(true)
+	defer tx.Abort()
+
+	for _, aclID := range aclIDs {
+		acl, err := tx.First("acls", "id", aclID)
+		if err != nil {
+			return fmt.Errorf("failed acl lookup: %s", err)
+		}
+		if acl == nil || !acl.(*structs.ACL).IsExpired(now) {
+			continue
+		}
+		if err := s.aclDeleteTxn(tx, idx, aclID); err != nil {
+			return err
+		}
+	}
+
+	tx.Commit()
+	return nil
+}
+
 // CoordinateGetRaw queries for the coordinate of the given node. This is an
 // unusual state store method because it just returns the raw coordinate or
 // nil, none of the Raft or node information is returned. This hits the 90%
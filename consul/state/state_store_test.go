@@ -241,6 +241,9 @@ func TestStateStore_EnsureRegistration(t *testing.T) {
 		TaggedAddresses: map[string]string{
 			"hello": "world",
 		},
+		NodeMeta: map[string]string{
+			"somekey": "somevalue",
+		},
 	}
 	if err := s.EnsureRegistration(1, req); err != nil {
 		t.Fatalf("err: %s", err)
@@ -255,6 +258,7 @@ func TestStateStore_EnsureRegistration(t *testing.T) {
 		if out.Node != "node1" || out.Address != "1.2.3.4" ||
 			len(out.TaggedAddresses) != 1 ||
 			out.TaggedAddresses["hello"] != "world" ||
+			out.Meta["somekey"] != "somevalue" ||
 			out.CreateIndex != created || out.ModifyIndex != modified {
 			t.Fatalf("bad node returned: %#v", out)
 		}
@@ -691,6 +695,62 @@ func TestStateStore_EnsureNode(t *testing.T) {
 	}
 }
 
+func TestStateStore_EnsureNode_ID(t *testing.T) {
+	s := testStateStore(t)
+
+	// Register a node with an ID.
+	id := types.NodeID("cda916bc-a357-4a19-b886-59419fcee50c")
+	in := &structs.Node{
+		ID:      id,
+		Node:    "node1",
+		Address: "1.1.1.1",
+	}
+	if err := s.EnsureNode(1, in); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, out, err := s.GetNode("node1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.ID != id {
+		t.Fatalf("bad node returned: %#v", out)
+	}
+
+	// An update without an ID should keep the existing one.
+	in = &structs.Node{
+		Node:    "node1",
+		Address: "1.1.1.2",
+	}
+	if err := s.EnsureNode(2, in); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, out, err = s.GetNode("node1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.ID != id || out.Address != "1.1.1.2" {
+		t.Fatalf("bad node returned: %#v", out)
+	}
+
+	// A different ID for the same node name should be rejected.
+	in = &structs.Node{
+		ID:      types.NodeID("2b6d8f8e-6e4c-4bcd-8d9a-0a3c5a2a9d41"),
+		Node:    "node1",
+		Address: "1.1.1.3",
+	}
+	err = s.EnsureNode(3, in)
+	if err == nil || !strings.Contains(err.Error(), "in use by another node ID") {
+		t.Fatalf("err: %v", err)
+	}
+	idx, out, err := s.GetNode("node1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.ID != id || out.Address != "1.1.1.2" || idx != 2 {
+		t.Fatalf("bad node returned: %#v", out)
+	}
+}
+
 func TestStateStore_GetNodes(t *testing.T) {
 	s := testStateStore(t)
 
@@ -733,6 +793,72 @@ func TestStateStore_GetNodes(t *testing.T) {
 	}
 }
 
+func TestStateStore_GetNodesByMeta(t *testing.T) {
+	s := testStateStore(t)
+
+	// Listing with no results returns nil
+	idx, res, err := s.NodesByMeta(map[string]string{"somekey": "somevalue"})
+	if idx != 0 || res != nil || err != nil {
+		t.Fatalf("expected (0, nil, nil), got: (%d, %#v, %#v)", idx, res, err)
+	}
+
+	// Create some nodes in the state store
+	node0 := &structs.Node{Node: "node0", Address: "127.0.0.1", Meta: map[string]string{"role": "client", "common": "1"}}
+	if err := s.EnsureNode(0, node0); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	node1 := &structs.Node{Node: "node1", Address: "127.0.0.1", Meta: map[string]string{"role": "server", "common": "1"}}
+	if err := s.EnsureNode(1, node1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		filters map[string]string
+		nodes   []string
+	}{
+		// Simple meta filter
+		{
+			filters: map[string]string{"role": "server"},
+			nodes:   []string{"node1"},
+		},
+		// Common meta filter
+		{
+			filters: map[string]string{"common": "1"},
+			nodes:   []string{"node0", "node1"},
+		},
+		// Invalid meta filter
+		{
+			filters: map[string]string{"invalid": "nope"},
+			nodes:   []string{},
+		},
+		// Multiple meta filters
+		{
+			filters: map[string]string{"role": "client", "common": "1"},
+			nodes:   []string{"node0"},
+		},
+	}
+
+	for _, tc := range cases {
+		idx, result, err := s.NodesByMeta(tc.filters)
+		if err != nil {
+			t.Fatalf("bad: %v", err)
+		}
+		if idx != 1 {
+			t.Fatalf("bad index: %d", idx)
+		}
+
+		if len(result) != len(tc.nodes) {
+			t.Fatalf("bad: %v %v", result, tc.nodes)
+		}
+
+		for i, node := range result {
+			if node.Node != tc.nodes[i] {
+				t.Fatalf("bad: %v %v", node.Node, tc.nodes[i])
+			}
+		}
+	}
+}
+
 func BenchmarkGetNodes(b *testing.B) {
 	s, err := NewStateStore(nil)
 	if err != nil {
@@ -1174,7 +1300,7 @@ func TestStateStore_ServiceTagNodes(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	idx, nodes, err := s.ServiceTagNodes("db", "master")
+	idx, nodes, err := s.ServiceTagNodes("db", []string{"master"})
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -1221,7 +1347,7 @@ func TestStateStore_ServiceTagNodes_MultipleTags(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	idx, nodes, err := s.ServiceTagNodes("db", "master")
+	idx, nodes, err := s.ServiceTagNodes("db", []string{"master"})
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -1244,7 +1370,7 @@ func TestStateStore_ServiceTagNodes_MultipleTags(t *testing.T) {
 		t.Fatalf("bad: %v", nodes)
 	}
 
-	idx, nodes, err = s.ServiceTagNodes("db", "v2")
+	idx, nodes, err = s.ServiceTagNodes("db", []string{"v2"})
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -1255,7 +1381,7 @@ func TestStateStore_ServiceTagNodes_MultipleTags(t *testing.T) {
 		t.Fatalf("bad: %v", nodes)
 	}
 
-	idx, nodes, err = s.ServiceTagNodes("db", "dev")
+	idx, nodes, err = s.ServiceTagNodes("db", []string{"dev"})
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -1277,6 +1403,47 @@ func TestStateStore_ServiceTagNodes_MultipleTags(t *testing.T) {
 	if nodes[0].ServicePort != 8001 {
 		t.Fatalf("bad: %v", nodes)
 	}
+
+	// All of the given tags must be present.
+	idx, nodes, err = s.ServiceTagNodes("db", []string{"v2", "slave"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 19 {
+		t.Fatalf("bad: %v", idx)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("bad: %v", nodes)
+	}
+	for _, node := range nodes {
+		if !lib.StrContains(node.ServiceTags, "slave") {
+			t.Fatalf("bad: %v", node)
+		}
+	}
+
+	// Negated tags exclude services, and matching ignores case.
+	idx, nodes, err = s.ServiceTagNodes("db", []string{"V2", "!DEV", "!master"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 19 {
+		t.Fatalf("bad: %v", idx)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("bad: %v", nodes)
+	}
+	if nodes[0].Node != "bar" || nodes[0].ServiceID != "db" {
+		t.Fatalf("bad: %v", nodes)
+	}
+
+	// No service satisfies conflicting tags.
+	idx, nodes, err = s.ServiceTagNodes("db", []string{"master", "slave"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(nodes) != 0 {
+		t.Fatalf("bad: %v", nodes)
+	}
 }
 
 func TestStateStore_DeleteService(t *testing.T) {
@@ -1425,6 +1592,129 @@ func TestStateStore_Service_Watches(t *testing.T) {
 	})
 }
 
+func TestStateStore_ServiceWatch(t *testing.T) {
+	s := testStateStore(t)
+
+	testRegisterNode(t, s, 0, "node1")
+	testRegisterNode(t, s, 1, "node2")
+	testRegisterService(t, s, 2, "node1", "web")
+	testRegisterService(t, s, 3, "node2", "db")
+	testRegisterCheck(t, s, 4, "node1", "web", "web-check", structs.HealthPassing)
+
+	// Registering a new instance of the service fires the watch, but
+	// other services don't.
+	verifyWatch(t, s.GetServiceWatch("web"), func() {
+		verifyNoWatch(t, s.GetServiceWatch("db"), func() {
+			testRegisterService(t, s, 5, "node2", "web")
+		})
+	})
+
+	// Service checks only affect their own service.
+	verifyWatch(t, s.GetServiceWatch("WEB"), func() {
+		verifyNoWatch(t, s.GetServiceWatch("db"), func() {
+			testRegisterCheck(t, s, 6, "node1", "web", "web-check", structs.HealthCritical)
+		})
+	})
+
+	// Node-level checks affect every service on the node.
+	verifyWatch(t, s.GetServiceWatch("web"), func() {
+		verifyWatch(t, s.GetServiceWatch("db"), func() {
+			testRegisterCheck(t, s, 7, "node2", "", "serf", structs.HealthCritical)
+		})
+	})
+
+	// Changing the node's address affects its services, but re-writing
+	// the same node doesn't.
+	verifyWatch(t, s.GetServiceWatch("db"), func() {
+		verifyNoWatch(t, s.GetServiceWatch("nope"), func() {
+			if err := s.EnsureNode(8, &structs.Node{Node: "node2", Address: "2.2.2.2"}); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		})
+	})
+	verifyNoWatch(t, s.GetServiceWatch("db"), func() {
+		if err := s.EnsureNode(9, &structs.Node{Node: "node2", Address: "2.2.2.2"}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	})
+
+	// Renaming a service fires watches for both names.
+	verifyWatch(t, s.GetServiceWatch("db"), func() {
+		verifyWatch(t, s.GetServiceWatch("db2"), func() {
+			ns := &structs.NodeService{ID: "db", Service: "db2"}
+			if err := s.EnsureService(10, "node2", ns); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		})
+	})
+
+	// Deleting the node fires watches for all of its services.
+	verifyWatch(t, s.GetServiceWatch("web"), func() {
+		verifyWatch(t, s.GetServiceWatch("db2"), func() {
+			if err := s.DeleteNode(11, "node2"); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		})
+	})
+
+	// Restoring a snapshot fires everything.
+	snap := s.Snapshot()
+	defer snap.Close()
+	verifyWatch(t, s.GetServiceWatch("web"), func() {
+		verifyWatch(t, s.GetServiceWatch("nope"), func() {
+			restore := s.Restore()
+			restore.Commit()
+		})
+	})
+}
+
+func TestStateStore_NodeWatch(t *testing.T) {
+	s := testStateStore(t)
+
+	// Creating a node fires its watch.
+	verifyWatch(t, s.GetNodeWatch("node1"), func() {
+		verifyNoWatch(t, s.GetNodeWatch("node2"), func() {
+			testRegisterNode(t, s, 0, "node1")
+		})
+	})
+	testRegisterNode(t, s, 1, "node2")
+
+	// Services and checks on the node fire its watch.
+	verifyWatch(t, s.GetNodeWatch("node1"), func() {
+		verifyNoWatch(t, s.GetNodeWatch("node2"), func() {
+			testRegisterService(t, s, 2, "node1", "web")
+		})
+	})
+	verifyWatch(t, s.GetNodeWatch("node1"), func() {
+		verifyNoWatch(t, s.GetNodeWatch("node2"), func() {
+			testRegisterCheck(t, s, 3, "node1", "web", "check1", structs.HealthPassing)
+		})
+	})
+	verifyWatch(t, s.GetNodeWatch("node1"), func() {
+		verifyNoWatch(t, s.GetNodeWatch("node2"), func() {
+			if err := s.DeleteCheck(4, "node1", "check1"); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		})
+	})
+	verifyWatch(t, s.GetNodeWatch("node1"), func() {
+		verifyNoWatch(t, s.GetNodeWatch("node2"), func() {
+			if err := s.DeleteService(5, "node1", "web"); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		})
+	})
+
+	// Deleting the node fires its watch.
+	verifyWatch(t, s.GetNodeWatch("node1"), func() {
+		verifyNoWatch(t, s.GetNodeWatch("node2"), func() {
+			if err := s.DeleteNode(6, "node1"); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		})
+	})
+}
+
 func TestStateStore_EnsureCheck(t *testing.T) {
 	s := testStateStore(t)
 
@@ -1751,6 +2041,49 @@ func TestStateStore_CheckServiceNodes(t *testing.T) {
 	}
 }
 
+func TestStateStore_NodeCheckServiceNodes(t *testing.T) {
+	s := testStateStore(t)
+
+	// Querying with no matches gives an empty response
+	idx, res, err := s.NodeCheckServiceNodes("node1")
+	if idx != 0 || res != nil || err != nil {
+		t.Fatalf("expected (0, nil, nil), got: (%d, %#v, %#v)", idx, res, err)
+	}
+
+	// Register a couple of services on one node, and one on another.
+	testRegisterNode(t, s, 0, "node1")
+	testRegisterNode(t, s, 1, "node2")
+	testRegisterCheck(t, s, 2, "node1", "", "check1", structs.HealthPassing)
+	testRegisterService(t, s, 3, "node1", "service1")
+	testRegisterService(t, s, 4, "node1", "service2")
+	testRegisterService(t, s, 5, "node2", "service3")
+	testRegisterCheck(t, s, 6, "node1", "service1", "check2", structs.HealthCritical)
+
+	// Only the services on the requested node are returned, each with
+	// the node-level checks plus their own.
+	idx, results, err := s.NodeCheckServiceNodes("node1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 6 {
+		t.Fatalf("bad index: %d", idx)
+	}
+	if n := len(results); n != 2 {
+		t.Fatalf("expected 2 results, got: %d", n)
+	}
+	if results[0].Service.ID != "service1" || len(results[0].Checks) != 2 {
+		t.Fatalf("bad: %#v", results[0])
+	}
+	if results[1].Service.ID != "service2" || len(results[1].Checks) != 1 {
+		t.Fatalf("bad: %#v", results[1])
+	}
+	for _, csn := range results {
+		if csn.Node == nil || csn.Node.Node != "node1" {
+			t.Fatalf("bad: %#v", csn)
+		}
+	}
+}
+
 func BenchmarkCheckServiceNodes(b *testing.B) {
 	s, err := NewStateStore(nil)
 	if err != nil {
@@ -1817,7 +2150,7 @@ func TestStateStore_CheckServiceTagNodes(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	idx, nodes, err := s.CheckServiceTagNodes("db", "master")
+	idx, nodes, err := s.CheckServiceTagNodes("db", []string{"master"})
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -1842,6 +2175,24 @@ func TestStateStore_CheckServiceTagNodes(t *testing.T) {
 	if nodes[0].Checks[1].CheckID != "db" {
 		t.Fatalf("Bad: %v", nodes[0])
 	}
+
+	// Add another instance without the tag and exclude the first one.
+	if err := s.EnsureService(5, "foo", &structs.NodeService{ID: "db2", Service: "db", Tags: []string{"slave"}, Address: "", Port: 8001}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	idx, nodes, err = s.CheckServiceTagNodes("db", []string{"!master"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 5 {
+		t.Fatalf("bad: %v", idx)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("Bad: %v", nodes)
+	}
+	if nodes[0].Service.ID != "db2" {
+		t.Fatalf("Bad: %v", nodes[0])
+	}
 }
 
 func TestStateStore_Check_Snapshot(t *testing.T) {
@@ -3176,6 +3527,49 @@ func TestStateStore_ACLDelete(t *testing.T) {
 	}
 }
 
+func TestStateStore_ACLReap(t *testing.T) {
+	s := testStateStore(t)
+
+	// Insert an ACL that never expires, one that has expired, and one
+	// that expires later.
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	acls := structs.ACLs{
+		&structs.ACL{ID: "acl1"},
+		&structs.ACL{ID: "acl2", ExpirationTime: &past},
+		&structs.ACL{ID: "acl3", ExpirationTime: &future},
+	}
+	for i, acl := range acls {
+		if err := s.ACLSet(uint64(i+1), acl); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Reap all of them, plus one that doesn't exist. Only the expired
+	// one should be deleted.
+	if err := s.ACLReap(4, []string{"acl1", "acl2", "acl3", "nope"}, now); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx := s.maxIndex("acls"); idx != 4 {
+		t.Fatalf("bad index: %d", idx)
+	}
+	_, res, err := s.ACLList()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(res) != 2 || res[0].ID != "acl1" || res[1].ID != "acl3" {
+		t.Fatalf("bad: %#v", res)
+	}
+
+	// Reaping with nothing expired leaves the index alone.
+	if err := s.ACLReap(5, []string{"acl1", "acl3"}, now); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx := s.maxIndex("acls"); idx != 4 {
+		t.Fatalf("bad index: %d", idx)
+	}
+}
+
 func TestStateStore_ACL_Snapshot_Restore(t *testing.T) {
 	s := testStateStore(t)
 
@@ -103,18 +103,253 @@ func (s *StateStore) txnKVS(tx *memdb.Txn, idx uint64, op *structs.TxnKVOp) (str
 	return nil, nil
 }
 
+// txnCASMatches returns true if a check-and-set against the given index
+// should go ahead, given whether the object exists and its current
+// ModifyIndex. An index of 0 means the object must not exist yet.
+func txnCASMatches(found bool, existingIndex, index uint64) bool {
+	if index == 0 {
+		return !found
+	}
+	return found && existingIndex == index
+}
+
+// txnNode handles all node-related operations.
+func (s *StateStore) txnNode(tx *memdb.Txn, idx uint64, watches *DumbWatchManager,
+	op *structs.TxnNodeOp) (structs.TxnResults, error) {
+	// Look up the existing node, which all the verbs need.
+	raw, err := tx.First("nodes", "id", op.Node.Node)
+	if err != nil {
+		return nil, fmt.Errorf("node lookup failed: %s", err)
+	}
+	existing, found := raw.(*structs.Node)
+	var existingIndex uint64
+	if found {
+		existingIndex = existing.ModifyIndex
+	}
+
+	var entry *structs.Node
+	switch op.Verb {
+	case structs.TxnGet:
+		entry = existing
+		if !found {
+			err = fmt.Errorf("node %q doesn't exist", op.Node.Node)
+		}
+
+	case structs.TxnSet:
+		entry = &op.Node
+		err = s.ensureNodeTxn(tx, idx, watches, entry)
+
+	case structs.TxnCAS:
+		if !txnCASMatches(found, existingIndex, op.Node.ModifyIndex) {
+			err = fmt.Errorf("failed to set node %q, index is stale", op.Node.Node)
+			break
+		}
+		entry = &op.Node
+		err = s.ensureNodeTxn(tx, idx, watches, entry)
+
+	case structs.TxnDelete:
+		err = s.deleteNodeTxn(tx, idx, op.Node.Node)
+
+	case structs.TxnDeleteCAS:
+		if found && existingIndex != op.Node.ModifyIndex {
+			err = fmt.Errorf("failed to delete node %q, index is stale", op.Node.Node)
+			break
+		}
+		err = s.deleteNodeTxn(tx, idx, op.Node.Node)
+
+	default:
+		err = fmt.Errorf("unknown node verb %q", op.Verb)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		result := structs.TxnResult{Node: entry}
+		return structs.TxnResults{&result}, nil
+	}
+	return nil, nil
+}
+
+// txnService handles all service-related operations.
+func (s *StateStore) txnService(tx *memdb.Txn, idx uint64, watches *DumbWatchManager,
+	op *structs.TxnServiceOp) (structs.TxnResults, error) {
+	// Look up the existing service, which all the verbs need.
+	raw, err := tx.First("services", "id", op.Node, op.Service.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed service lookup: %s", err)
+	}
+	existing, found := raw.(*structs.ServiceNode)
+	var existingIndex uint64
+	if found {
+		existingIndex = existing.ModifyIndex
+	}
+
+	// The writes store a service node, so they read the service back out
+	// to get the result with its indexes.
+	write := false
+	switch op.Verb {
+	case structs.TxnGet:
+		if !found {
+			err = fmt.Errorf("service %q on node %q doesn't exist", op.Service.ID, op.Node)
+		}
+
+	case structs.TxnSet:
+		write = true
+		err = s.ensureServiceTxn(tx, idx, watches, op.Node, &op.Service)
+
+	case structs.TxnCAS:
+		if !txnCASMatches(found, existingIndex, op.Service.ModifyIndex) {
+			err = fmt.Errorf("failed to set service %q on node %q, index is stale", op.Service.ID, op.Node)
+			break
+		}
+		write = true
+		err = s.ensureServiceTxn(tx, idx, watches, op.Node, &op.Service)
+
+	case structs.TxnDelete:
+		found = false
+		err = s.deleteServiceTxn(tx, idx, watches, op.Node, op.Service.ID)
+
+	case structs.TxnDeleteCAS:
+		if found && existingIndex != op.Service.ModifyIndex {
+			err = fmt.Errorf("failed to delete service %q on node %q, index is stale", op.Service.ID, op.Node)
+			break
+		}
+		found = false
+		err = s.deleteServiceTxn(tx, idx, watches, op.Node, op.Service.ID)
+
+	default:
+		err = fmt.Errorf("unknown service verb %q", op.Verb)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if write {
+		raw, err := tx.First("services", "id", op.Node, op.Service.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed service lookup: %s", err)
+		}
+		existing, found = raw.(*structs.ServiceNode)
+	}
+	if found {
+		result := structs.TxnResult{Service: existing.ToNodeService()}
+		return structs.TxnResults{&result}, nil
+	}
+	return nil, nil
+}
+
+// txnCheck handles all health check-related operations.
+func (s *StateStore) txnCheck(tx *memdb.Txn, idx uint64, watches *DumbWatchManager,
+	op *structs.TxnCheckOp) (structs.TxnResults, error) {
+	// Look up the existing check, which all the verbs need.
+	raw, err := tx.First("checks", "id", op.Check.Node, string(op.Check.CheckID))
+	if err != nil {
+		return nil, fmt.Errorf("failed health check lookup: %s", err)
+	}
+	existing, found := raw.(*structs.HealthCheck)
+	var existingIndex uint64
+	if found {
+		existingIndex = existing.ModifyIndex
+	}
+
+	var entry *structs.HealthCheck
+	switch op.Verb {
+	case structs.TxnGet:
+		entry = existing
+		if !found {
+			err = fmt.Errorf("check %q on node %q doesn't exist", op.Check.CheckID, op.Check.Node)
+		}
+
+	case structs.TxnSet:
+		entry = &op.Check
+		err = s.ensureCheckTxn(tx, idx, watches, entry)
+
+	case structs.TxnCAS:
+		if !txnCASMatches(found, existingIndex, op.Check.ModifyIndex) {
+			err = fmt.Errorf("failed to set check %q on node %q, index is stale", op.Check.CheckID, op.Check.Node)
+			break
+		}
+		entry = &op.Check
+		err = s.ensureCheckTxn(tx, idx, watches, entry)
+
+	case structs.TxnDelete:
+		err = s.deleteCheckTxn(tx, idx, watches, op.Check.Node, op.Check.CheckID)
+
+	case structs.TxnDeleteCAS:
+		if found && existingIndex != op.Check.ModifyIndex {
+			err = fmt.Errorf("failed to delete check %q on node %q, index is stale", op.Check.CheckID, op.Check.Node)
+			break
+		}
+		err = s.deleteCheckTxn(tx, idx, watches, op.Check.Node, op.Check.CheckID)
+
+	default:
+		err = fmt.Errorf("unknown check verb %q", op.Verb)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		result := structs.TxnResult{Check: entry}
+		return structs.TxnResults{&result}, nil
+	}
+	return nil, nil
+}
+
+// txnSession handles all session-related operations. Deleting a session
+// invalidates it, so any locks it holds are released or deleted according
+// to its behavior, just like destroying it through the session endpoint.
+func (s *StateStore) txnSession(tx *memdb.Txn, idx uint64, watches *DumbWatchManager,
+	op *structs.TxnSessionOp) (structs.TxnResults, error) {
+	switch op.Verb {
+	case structs.TxnGet:
+		raw, err := tx.First("sessions", "id", op.Session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed session lookup: %s", err)
+		}
+		if raw == nil {
+			return nil, fmt.Errorf("session %q doesn't exist", op.Session.ID)
+		}
+		result := structs.TxnResult{Session: raw.(*structs.Session)}
+		return structs.TxnResults{&result}, nil
+
+	case structs.TxnDelete:
+		if err := s.deleteSessionTxn(tx, idx, watches, op.Session.ID); err != nil {
+			return nil, err
+		}
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown session verb %q", op.Verb)
+	}
+}
+
 // txnDispatch runs the given operations inside the state store transaction.
 func (s *StateStore) txnDispatch(tx *memdb.Txn, idx uint64, ops structs.TxnOps) (structs.TxnResults, structs.TxnErrors) {
 	results := make(structs.TxnResults, 0, len(ops))
 	errors := make(structs.TxnErrors, 0, len(ops))
+
+	// Use a watch manager since the catalog operations can perform
+	// multiple ops per table.
+	watches := NewDumbWatchManager(s.tableWatches)
 	for i, op := range ops {
 		var ret structs.TxnResults
 		var err error
 
 		// Dispatch based on the type of operation.
-		if op.KV != nil {
+		switch {
+		case op.KV != nil:
 			ret, err = s.txnKVS(tx, idx, op.KV)
-		} else {
+		case op.Node != nil:
+			ret, err = s.txnNode(tx, idx, watches, op.Node)
+		case op.Service != nil:
+			ret, err = s.txnService(tx, idx, watches, op.Service)
+		case op.Check != nil:
+			ret, err = s.txnCheck(tx, idx, watches, op.Check)
+		case op.Session != nil:
+			ret, err = s.txnSession(tx, idx, watches, op.Session)
+		default:
 			err = fmt.Errorf("no operation specified")
 		}
 
@@ -132,6 +367,7 @@ func (s *StateStore) txnDispatch(tx *memdb.Txn, idx uint64, ops structs.TxnOps)
 		return nil, errors
 	}
 
+	tx.Defer(func() { watches.Notify() })
 	return results, nil
 }
 
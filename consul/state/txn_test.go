@@ -791,4 +791,343 @@ func TestStateStore_Txn_Watches(t *testing.T) {
 			}
 		})
 	})
+
+	// Verify that catalog operations trigger their watches along with the
+	// KV ones.
+	testRegisterNode(t, s, 17, "node1")
+	verifyWatch(t, s.GetServiceWatch("web"), func() {
+		verifyWatch(t, s.GetQueryWatch("NodeServices"), func() {
+			verifyWatch(t, s.GetKVSWatch("multi/one"), func() {
+				ops := structs.TxnOps{
+					&structs.TxnOp{
+						Service: &structs.TxnServiceOp{
+							Verb:    structs.TxnSet,
+							Node:    "node1",
+							Service: structs.NodeService{ID: "web", Service: "web"},
+						},
+					},
+					&structs.TxnOp{
+						KV: &structs.TxnKVOp{
+							Verb: structs.KVSSet,
+							DirEnt: structs.DirEntry{
+								Key:   "multi/one",
+								Value: []byte("web"),
+							},
+						},
+					},
+				}
+				results, errors := s.TxnRW(18, ops)
+				if len(results) != len(ops) {
+					t.Fatalf("bad len: %d != %d", len(results), len(ops))
+				}
+				if len(errors) != 0 {
+					t.Fatalf("bad len: %d != 0", len(errors))
+				}
+			})
+		})
+	})
+}
+
+func TestStateStore_Txn_Catalog(t *testing.T) {
+	s := testStateStore(t)
+
+	// Register a node with a service and some checks.
+	testRegisterNode(t, s, 1, "node1")
+	testRegisterService(t, s, 2, "node1", "web")
+	testRegisterCheck(t, s, 3, "node1", "web", "web-check", structs.HealthPassing)
+	testRegisterCheck(t, s, 4, "node1", "", "node-check", structs.HealthPassing)
+
+	// Set up a transaction that hits every write and read.
+	ops := structs.TxnOps{
+		&structs.TxnOp{
+			Node: &structs.TxnNodeOp{
+				Verb: structs.TxnGet,
+				Node: structs.Node{Node: "node1"},
+			},
+		},
+		&structs.TxnOp{
+			Node: &structs.TxnNodeOp{
+				Verb: structs.TxnSet,
+				Node: structs.Node{Node: "node2", Address: "2.2.2.2"},
+			},
+		},
+		&structs.TxnOp{
+			Service: &structs.TxnServiceOp{
+				Verb:    structs.TxnSet,
+				Node:    "node2",
+				Service: structs.NodeService{ID: "db", Service: "db", Port: 5432},
+			},
+		},
+		&structs.TxnOp{
+			Check: &structs.TxnCheckOp{
+				Verb: structs.TxnCAS,
+				Check: structs.HealthCheck{
+					Node:      "node2",
+					CheckID:   "db-check",
+					ServiceID: "db",
+					Status:    structs.HealthPassing,
+				},
+			},
+		},
+		&structs.TxnOp{
+			Service: &structs.TxnServiceOp{
+				Verb: structs.TxnCAS,
+				Node: "node1",
+				Service: structs.NodeService{
+					ID:      "web",
+					Service: "web",
+					Port:    8080,
+					RaftIndex: structs.RaftIndex{
+						ModifyIndex: 2,
+					},
+				},
+			},
+		},
+		&structs.TxnOp{
+			Check: &structs.TxnCheckOp{
+				Verb: structs.TxnDeleteCAS,
+				Check: structs.HealthCheck{
+					Node:    "node1",
+					CheckID: "node-check",
+					RaftIndex: structs.RaftIndex{
+						ModifyIndex: 4,
+					},
+				},
+			},
+		},
+		&structs.TxnOp{
+			Check: &structs.TxnCheckOp{
+				Verb:  structs.TxnGet,
+				Check: structs.HealthCheck{Node: "node1", CheckID: "web-check"},
+			},
+		},
+	}
+	results, errors := s.TxnRW(5, ops)
+	if len(errors) > 0 {
+		t.Fatalf("err: %v", errors)
+	}
+
+	// Make sure the response looks as expected.
+	if len(results) != 6 {
+		t.Fatalf("bad: %v", results)
+	}
+	if n := results[0].Node; n == nil || n.Node != "node1" || n.ModifyIndex != 1 {
+		t.Fatalf("bad: %#v", results[0])
+	}
+	if n := results[1].Node; n == nil || n.Node != "node2" || n.Address != "2.2.2.2" ||
+		n.CreateIndex != 5 || n.ModifyIndex != 5 {
+		t.Fatalf("bad: %#v", results[1])
+	}
+	if svc := results[2].Service; svc == nil || svc.ID != "db" || svc.Port != 5432 ||
+		svc.CreateIndex != 5 || svc.ModifyIndex != 5 {
+		t.Fatalf("bad: %#v", results[2])
+	}
+	if chk := results[3].Check; chk == nil || chk.CheckID != "db-check" ||
+		chk.ServiceName != "db" || chk.ModifyIndex != 5 {
+		t.Fatalf("bad: %#v", results[3])
+	}
+	if svc := results[4].Service; svc == nil || svc.ID != "web" || svc.Port != 8080 ||
+		svc.CreateIndex != 2 || svc.ModifyIndex != 5 {
+		t.Fatalf("bad: %#v", results[4])
+	}
+	if chk := results[5].Check; chk == nil || chk.CheckID != "web-check" || chk.ModifyIndex != 3 {
+		t.Fatalf("bad: %#v", results[5])
+	}
+
+	// Pull the resulting state store contents.
+	idx, checks, err := s.NodeChecks("node1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 5 {
+		t.Fatalf("bad index: %d", idx)
+	}
+	if len(checks) != 1 || checks[0].CheckID != "web-check" {
+		t.Fatalf("bad: %v", checks)
+	}
+	_, svc, err := s.NodeService("node2", "db")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if svc == nil || svc.Port != 5432 {
+		t.Fatalf("bad: %#v", svc)
+	}
+}
+
+func TestStateStore_Txn_Catalog_Rollback(t *testing.T) {
+	s := testStateStore(t)
+
+	testRegisterNode(t, s, 1, "node1")
+	testRegisterService(t, s, 2, "node1", "web")
+	testRegisterCheck(t, s, 3, "node1", "web", "web-check", structs.HealthPassing)
+
+	// Set up a transaction that hits every failure mode.
+	ops := structs.TxnOps{
+		&structs.TxnOp{
+			Node: &structs.TxnNodeOp{
+				Verb: structs.TxnSet,
+				Node: structs.Node{Node: "node2", Address: "2.2.2.2"},
+			},
+		},
+		&structs.TxnOp{
+			Node: &structs.TxnNodeOp{
+				Verb: structs.TxnGet,
+				Node: structs.Node{Node: "nope"},
+			},
+		},
+		&structs.TxnOp{
+			Node: &structs.TxnNodeOp{
+				Verb: structs.TxnCAS,
+				Node: structs.Node{Node: "node1", Address: "1.1.1.1"},
+			},
+		},
+		&structs.TxnOp{
+			Service: &structs.TxnServiceOp{
+				Verb: structs.TxnDeleteCAS,
+				Node: "node1",
+				Service: structs.NodeService{
+					ID: "web",
+					RaftIndex: structs.RaftIndex{
+						ModifyIndex: 1,
+					},
+				},
+			},
+		},
+		&structs.TxnOp{
+			Service: &structs.TxnServiceOp{
+				Verb:    structs.TxnSet,
+				Node:    "nope",
+				Service: structs.NodeService{ID: "db", Service: "db"},
+			},
+		},
+		&structs.TxnOp{
+			Check: &structs.TxnCheckOp{
+				Verb: structs.TxnCAS,
+				Check: structs.HealthCheck{
+					Node:    "node1",
+					CheckID: "web-check",
+					RaftIndex: structs.RaftIndex{
+						ModifyIndex: 2,
+					},
+				},
+			},
+		},
+		&structs.TxnOp{
+			Session: &structs.TxnSessionOp{
+				Verb:    structs.TxnSet,
+				Session: structs.Session{ID: testUUID()},
+			},
+		},
+		&structs.TxnOp{},
+	}
+	results, errors := s.TxnRW(4, ops)
+	if len(results) != 0 {
+		t.Fatalf("bad: %v", results)
+	}
+	expected := []string{
+		`node "nope" doesn't exist`,
+		`failed to set node "node1", index is stale`,
+		`failed to delete service "web" on node "node1", index is stale`,
+		"Missing node registration",
+		`failed to set check "web-check" on node "node1", index is stale`,
+		`unknown session verb "set"`,
+		"no operation specified",
+	}
+	if len(errors) != len(expected) {
+		t.Fatalf("bad len: %d != %d", len(errors), len(expected))
+	}
+	for i, msg := range expected {
+		if errors[i].OpIndex != i+1 {
+			t.Fatalf("bad index: %d != %d", errors[i].OpIndex, i+1)
+		}
+		if !strings.Contains(errors[i].Error(), msg) {
+			t.Fatalf("bad %d: %v", i, errors[i].Error())
+		}
+	}
+
+	// Nothing should have been written.
+	idx, nodes, err := s.Nodes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if idx != 1 || len(nodes) != 1 {
+		t.Fatalf("bad: %d %v", idx, nodes)
+	}
+}
+
+func TestStateStore_Txn_Session(t *testing.T) {
+	s := testStateStore(t)
+
+	// Register a service instance that holds a lock through a session.
+	testRegisterNode(t, s, 1, "node1")
+	testRegisterService(t, s, 2, "node1", "web")
+	session := testUUID()
+	if err := s.SessionCreate(3, &structs.Session{ID: session, Node: "node1"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ok, err := s.KVSLock(4, &structs.DirEntry{Key: "web/leader", Session: session})
+	if !ok || err != nil {
+		t.Fatalf("didn't get the lock: %v %s", ok, err)
+	}
+
+	// Read the session back.
+	results, errors := s.TxnRO(structs.TxnOps{
+		&structs.TxnOp{
+			Session: &structs.TxnSessionOp{
+				Verb:    structs.TxnGet,
+				Session: structs.Session{ID: session},
+			},
+		},
+	})
+	if len(errors) > 0 {
+		t.Fatalf("err: %v", errors)
+	}
+	if len(results) != 1 || results[0].Session == nil || results[0].Session.ID != session {
+		t.Fatalf("bad: %v", results)
+	}
+
+	// Deregister the instance and release its lock in one step.
+	results, errors = s.TxnRW(5, structs.TxnOps{
+		&structs.TxnOp{
+			Service: &structs.TxnServiceOp{
+				Verb:    structs.TxnDelete,
+				Node:    "node1",
+				Service: structs.NodeService{ID: "web"},
+			},
+		},
+		&structs.TxnOp{
+			Session: &structs.TxnSessionOp{
+				Verb:    structs.TxnDelete,
+				Session: structs.Session{ID: session},
+			},
+		},
+	})
+	if len(errors) > 0 {
+		t.Fatalf("err: %v", errors)
+	}
+	if len(results) != 0 {
+		t.Fatalf("bad: %v", results)
+	}
+
+	_, svc, err := s.NodeService("node1", "web")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if svc != nil {
+		t.Fatalf("bad: %#v", svc)
+	}
+	_, sess, err := s.SessionGet(session)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if sess != nil {
+		t.Fatalf("bad: %#v", sess)
+	}
+	_, entry, err := s.KVSGet("web/leader")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry == nil || entry.Session != "" || entry.ModifyIndex != 5 {
+		t.Fatalf("bad: %#v", entry)
+	}
 }
@@ -2,6 +2,7 @@ package state
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/armon/go-radix"
@@ -43,15 +44,19 @@ func (w *FullTableWatch) Notify() {
 }
 
 // DumbWatchManager is a wrapper that allows nested code to arm full table
-// watches multiple times but fire them only once. This doesn't have any
-// way to clear the state, and it's not thread-safe, so it should be used once
-// and thrown away inside the context of a single thread.
+// and key watches multiple times but fire them only once. This doesn't have
+// any way to clear the state, and it's not thread-safe, so it should be used
+// once and thrown away inside the context of a single thread.
 type DumbWatchManager struct {
 	// tableWatches holds the full table watches.
 	tableWatches map[string]*FullTableWatch
 
 	// armed tracks whether the table should be notified.
 	armed map[string]bool
+
+	// armedKeys tracks the keys that should be notified, for each key
+	// watch manager.
+	armedKeys map[*KeyWatchManager]map[string]struct{}
 }
 
 // NewDumbWatchManager returns a new dumb watch manager.
@@ -59,6 +64,7 @@ func NewDumbWatchManager(tableWatches map[string]*FullTableWatch) *DumbWatchMana
 	return &DumbWatchManager{
 		tableWatches: tableWatches,
 		armed:        make(map[string]bool),
+		armedKeys:    make(map[*KeyWatchManager]map[string]struct{}),
 	}
 }
 
@@ -73,11 +79,26 @@ func (d *DumbWatchManager) Arm(table string) {
 	}
 }
 
-// Notify fires watches for all the armed tables.
+// ArmKey arms the watch for the given key in a key watch manager.
+func (d *DumbWatchManager) ArmKey(manager *KeyWatchManager, key string) {
+	keys, ok := d.armedKeys[manager]
+	if !ok {
+		keys = make(map[string]struct{})
+		d.armedKeys[manager] = keys
+	}
+	keys[strings.ToLower(key)] = struct{}{}
+}
+
+// Notify fires watches for all the armed tables and keys.
 func (d *DumbWatchManager) Notify() {
 	for table, _ := range d.armed {
 		d.tableWatches[table].Notify()
 	}
+	for manager, keys := range d.armedKeys {
+		for key := range keys {
+			manager.Notify(key)
+		}
+	}
 }
 
 // PrefixWatch provides a Watch-compatible interface for a PrefixWatchManager,
@@ -190,6 +211,107 @@ func (w *PrefixWatchManager) Notify(prefix string, subtree bool) {
 	// with a function that clears out any notify groups that are empty.
 }
 
+// KeyWatch provides a Watch-compatible interface for a KeyWatchManager,
+// bound to a specific key.
+type KeyWatch struct {
+	// manager is the underlying watch manager.
+	manager *KeyWatchManager
+
+	// key is the key we are watching.
+	key string
+}
+
+// Wait registers the given channel with the notify group for our key.
+func (w *KeyWatch) Wait(notifyCh chan struct{}) {
+	w.manager.Wait(w.key, notifyCh)
+}
+
+// Clear deregisters the given channel from the notify group for our key.
+func (w *KeyWatch) Clear(notifyCh chan struct{}) {
+	w.manager.Clear(w.key, notifyCh)
+}
+
+// KeyWatchManager maintains a notify group for each of a set of exact keys,
+// such as service or node names. Unlike a PrefixWatchManager, notifying a
+// key only wakes up watchers of that exact key. Keys are case-insensitive
+// to match the way the state store indexes names.
+type KeyWatchManager struct {
+	// watches has the set of notify groups, indexed by key.
+	watches map[string]*NotifyGroup
+
+	// lock protects the watches map.
+	lock sync.Mutex
+}
+
+// NewKeyWatchManager returns a new key watch manager.
+func NewKeyWatchManager() *KeyWatchManager {
+	return &KeyWatchManager{
+		watches: make(map[string]*NotifyGroup),
+	}
+}
+
+// NewKeyWatch returns a Watch-compatible interface for watching the given
+// key.
+func (w *KeyWatchManager) NewKeyWatch(key string) Watch {
+	return &KeyWatch{
+		manager: w,
+		key:     strings.ToLower(key),
+	}
+}
+
+// Wait registers the given channel on a key.
+func (w *KeyWatchManager) Wait(key string, notifyCh chan struct{}) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	key = strings.ToLower(key)
+	group, ok := w.watches[key]
+	if !ok {
+		group = &NotifyGroup{}
+		w.watches[key] = group
+	}
+	group.Wait(notifyCh)
+}
+
+// Clear deregisters the given channel from the notify group for a key (if
+// one exists), and cleans up the group once nobody is waiting on it.
+func (w *KeyWatchManager) Clear(key string, notifyCh chan struct{}) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	key = strings.ToLower(key)
+	if group, ok := w.watches[key]; ok {
+		group.Clear(notifyCh)
+		if group.Empty() {
+			delete(w.watches, key)
+		}
+	}
+}
+
+// Notify wakes up all the watchers associated with the given key.
+func (w *KeyWatchManager) Notify(key string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	key = strings.ToLower(key)
+	if group, ok := w.watches[key]; ok {
+		group.Notify()
+		delete(w.watches, key)
+	}
+}
+
+// NotifyAll wakes up all the watchers for every key, such as when the
+// entire state store is being replaced during a restore.
+func (w *KeyWatchManager) NotifyAll() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, group := range w.watches {
+		group.Notify()
+	}
+	w.watches = make(map[string]*NotifyGroup)
+}
+
 // MultiWatch wraps several watches and allows any of them to trigger the
 // caller.
 type MultiWatch struct {
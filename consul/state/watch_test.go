@@ -165,6 +165,74 @@ func TestWatch_DumbWatchManager(t *testing.T) {
 	}()
 }
 
+func TestWatch_DumbWatchManager_Keys(t *testing.T) {
+	watches := map[string]*FullTableWatch{
+		"alice": NewFullTableWatch(),
+	}
+	keys := NewKeyWatchManager()
+
+	// Armed keys fire along with the tables, and only once.
+	w := NewDumbWatchManager(watches)
+	verifyWatch(t, watches["alice"], func() {
+		verifyWatch(t, keys.NewKeyWatch("foo"), func() {
+			verifyNoWatch(t, keys.NewKeyWatch("bar"), func() {
+				w.Arm("alice")
+				w.ArmKey(keys, "FOO")
+				w.ArmKey(keys, "foo")
+				w.Notify()
+			})
+		})
+	})
+}
+
+func TestWatch_KeyWatchManager(t *testing.T) {
+	w := NewKeyWatchManager()
+
+	// Only watchers of the exact key should fire, and case shouldn't
+	// matter.
+	verifyWatch(t, w.NewKeyWatch("web"), func() {
+		verifyNoWatch(t, w.NewKeyWatch("web2"), func() {
+			verifyNoWatch(t, w.NewKeyWatch("we"), func() {
+				w.Notify("WEB")
+			})
+		})
+	})
+
+	// Notify-all should wake up everyone.
+	verifyWatch(t, w.NewKeyWatch("web"), func() {
+		verifyWatch(t, w.NewKeyWatch("db"), func() {
+			w.NotifyAll()
+		})
+	})
+
+	// Groups should be cleaned up after a notify.
+	if len(w.watches) != 0 {
+		t.Fatalf("bad: %v", w.watches)
+	}
+
+	// Groups should be cleaned up once the last watcher is cleared.
+	watch := w.NewKeyWatch("web")
+	ch1, ch2 := make(chan struct{}, 1), make(chan struct{}, 1)
+	watch.Wait(ch1)
+	watch.Wait(ch2)
+	watch.Clear(ch1)
+	if len(w.watches) != 1 {
+		t.Fatalf("bad: %v", w.watches)
+	}
+	watch.Clear(ch2)
+	if len(w.watches) != 0 {
+		t.Fatalf("bad: %v", w.watches)
+	}
+	w.Notify("web")
+	select {
+	case <-ch1:
+		t.Fatalf("watch should not have been notified")
+	case <-ch2:
+		t.Fatalf("watch should not have been notified")
+	default:
+	}
+}
+
 func verifyWatches(t *testing.T, w *PrefixWatchManager, expected string) {
 	var found []string
 	fn := func(k string, v interface{}) bool {
@@ -0,0 +1,108 @@
+package consul
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/consul/consul/structs"
+)
+
+// Stream endpoint is used to subscribe to changes to the state store.
+type Stream struct {
+	srv *Server
+}
+
+// Events is used to get the changes on a topic for a given key. A request
+// without an index gets a snapshot of the current state, followed by an
+// end-of-snapshot marker. A request with an index blocks until there are
+// changes after it. If the server no longer has all of the changes since the
+// requested index, a reset marker and a fresh snapshot are returned instead.
+func (s *Stream) Events(args *structs.StreamRequest, reply *structs.IndexedStreamEvents) error {
+	if done, err := s.srv.forward("Stream.Events", args, args, reply); done {
+		return err
+	}
+	defer metrics.MeasureSince([]string{"consul", "stream", "events"}, time.Now())
+
+	// Verify the arguments
+	if args.Topic != structs.StreamTopicServiceHealth {
+		return fmt.Errorf("Unknown event stream topic %q", args.Topic)
+	}
+	if args.Key == "" {
+		return fmt.Errorf("Must provide a key")
+	}
+
+	// Subscribers need read access to the service.
+	acl, err := s.srv.resolveToken(args.Token)
+	if err != nil {
+		return err
+	}
+	if acl != nil && !acl.ServiceRead(args.Key) {
+		return permissionDeniedErr
+	}
+
+	// Subscribers only see the nodes they are allowed to read.
+	var filt *aclFilter
+	if acl != nil {
+		filt = newAclFilter(acl, s.srv.logger, s.srv.config.ACLEnforceVersion8)
+	}
+
+	publisher := s.srv.fsm.Publisher()
+	return s.srv.blockingRPC(
+		&args.QueryOptions,
+		&reply.QueryMeta,
+		publisher.Watch(args.Topic, args.Key),
+		func() error {
+			// Send just the changes if we can.
+			if args.MinQueryIndex > 0 {
+				events, ok := publisher.Events(args.Topic, args.Key, args.MinQueryIndex)
+				if ok {
+					reply.Index = args.MinQueryIndex
+					if len(events) > 0 {
+						reply.Index = events[len(events)-1].Index
+					}
+					if filt != nil {
+						filt.filterStreamEvents(&events)
+					}
+					reply.Events = events
+					return nil
+				}
+			}
+
+			// Otherwise start the subscriber over with a snapshot.
+			index, nodes, err := s.srv.fsm.State().CheckServiceNodes(args.Key)
+			if err != nil {
+				return err
+			}
+			if filt != nil {
+				filt.filterCheckServiceNodes(&nodes)
+			}
+
+			// The subscriber resumes from the snapshot's index, and a zero
+			// index would get it another snapshot, so never hand that out.
+			if index == 0 {
+				index = 1
+			}
+			event := func(op structs.StreamOp, csn *structs.CheckServiceNode) *structs.StreamEvent {
+				return &structs.StreamEvent{
+					Topic:         args.Topic,
+					Key:           args.Key,
+					Index:         index,
+					Op:            op,
+					ServiceHealth: csn,
+				}
+			}
+
+			var events []*structs.StreamEvent
+			if args.MinQueryIndex > 0 {
+				events = append(events, event(structs.StreamReset, nil))
+			}
+			for i := range nodes {
+				events = append(events, event(structs.StreamAdd, &nodes[i]))
+			}
+			events = append(events, event(structs.StreamEndOfSnapshot, nil))
+
+			reply.Index, reply.Events = index, events
+			return nil
+		})
+}
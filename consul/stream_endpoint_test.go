@@ -0,0 +1,299 @@
+package consul
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/consul/structs"
+	"github.com/hashicorp/consul/testutil"
+	"github.com/hashicorp/net-rpc-msgpackrpc"
+)
+
+func TestStream_Events(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Register a service.
+	reg := structs.RegisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		Address:    "127.0.0.1",
+		Service: &structs.NodeService{
+			ID:      "web1",
+			Service: "web",
+			Port:    80,
+		},
+		Check: &structs.HealthCheck{
+			Name:      "web alive",
+			Status:    structs.HealthPassing,
+			ServiceID: "web1",
+		},
+	}
+	var unused struct{}
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &reg, &unused); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Start off with a snapshot.
+	args := structs.StreamRequest{
+		Datacenter: "dc1",
+		Topic:      structs.StreamTopicServiceHealth,
+		Key:        "web",
+	}
+	var out structs.IndexedStreamEvents
+	if err := msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Events) != 2 || out.Index == 0 {
+		t.Fatalf("bad: %#v", out)
+	}
+	add, end := out.Events[0], out.Events[1]
+	if add.Op != structs.StreamAdd || add.Index != out.Index ||
+		add.ServiceHealth == nil || add.ServiceHealth.Service.ID != "web1" ||
+		len(add.ServiceHealth.Checks) != 1 {
+		t.Fatalf("bad: %#v", add)
+	}
+	if end.Op != structs.StreamEndOfSnapshot || end.Index != out.Index || end.ServiceHealth != nil {
+		t.Fatalf("bad: %#v", end)
+	}
+
+	// Block for changes after the snapshot. An unrelated service shouldn't
+	// wake us up, but a failing check should.
+	args.MinQueryIndex = out.Index
+	args.MaxQueryTime = 2 * time.Second
+	start := time.Now()
+	go func() {
+		codec := rpcClient(t, s1)
+		defer codec.Close()
+
+		time.Sleep(100 * time.Millisecond)
+		other := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       "foo",
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      "db1",
+				Service: "db",
+			},
+		}
+		var unused struct{}
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &other, &unused); err != nil {
+			t.Errorf("err: %v", err)
+			return
+		}
+
+		time.Sleep(200 * time.Millisecond)
+		reg.Check.Status = structs.HealthCritical
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &reg, &unused); err != nil {
+			t.Errorf("err: %v", err)
+		}
+	}()
+
+	out = structs.IndexedStreamEvents{}
+	if err := msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if elapsed := time.Now().Sub(start); elapsed < 300*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("bad: %v", elapsed)
+	}
+	if len(out.Events) != 1 || out.Index <= args.MinQueryIndex {
+		t.Fatalf("bad: %#v", out)
+	}
+	update := out.Events[0]
+	if update.Op != structs.StreamUpdate || update.Index != out.Index ||
+		update.ServiceHealth.Checks[0].Status != structs.HealthCritical {
+		t.Fatalf("bad: %#v", update)
+	}
+
+	// Deregister the service.
+	dereg := structs.DeregisterRequest{
+		Datacenter: "dc1",
+		Node:       "foo",
+		ServiceID:  "web1",
+	}
+	if err := msgpackrpc.CallWithCodec(codec, "Catalog.Deregister", &dereg, &unused); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	args.MinQueryIndex = out.Index
+	out = structs.IndexedStreamEvents{}
+	if err := msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Events) != 1 || out.Events[0].Op != structs.StreamDelete ||
+		out.Events[0].ServiceHealth.Service.ID != "web1" {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// Simulate the server losing track of the changes, which should get
+	// us a reset and a fresh, now empty, snapshot.
+	s1.fsm.Publisher().Reset(out.Index + 1)
+	args.MinQueryIndex = out.Index
+	out = structs.IndexedStreamEvents{}
+	if err := msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Events) != 2 ||
+		out.Events[0].Op != structs.StreamReset ||
+		out.Events[1].Op != structs.StreamEndOfSnapshot {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
+func TestStream_Events_BadRequest(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	args := structs.StreamRequest{
+		Datacenter: "dc1",
+		Topic:      structs.StreamTopic("nope"),
+		Key:        "web",
+	}
+	var out structs.IndexedStreamEvents
+	err := msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out)
+	if err == nil || !strings.Contains(err.Error(), "Unknown event stream topic") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	args.Topic, args.Key = structs.StreamTopicServiceHealth, ""
+	err = msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out)
+	if err == nil || !strings.Contains(err.Error(), "Must provide a key") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestStream_Events_ACLDeny(t *testing.T) {
+	dir, token, srv, codec := testACLFilterServer(t)
+	defer os.RemoveAll(dir)
+	defer srv.Shutdown()
+	defer codec.Close()
+
+	args := structs.StreamRequest{
+		Datacenter:   "dc1",
+		Topic:        structs.StreamTopicServiceHealth,
+		Key:          "foo",
+		QueryOptions: structs.QueryOptions{Token: token},
+	}
+	var out structs.IndexedStreamEvents
+	if err := msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Events) != 2 || out.Events[0].Op != structs.StreamAdd {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	args.Key = "bar"
+	out = structs.IndexedStreamEvents{}
+	err := msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out)
+	if err == nil || !strings.Contains(err.Error(), permissionDenied) {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestStream_Events_ACLNodeFilter(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+		c.ACLEnforceVersion8 = false
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create a token that can read the service, but only one of the nodes
+	// it runs on.
+	arg := structs.ACLRequest{
+		Datacenter: "dc1",
+		Op:         structs.ACLSet,
+		ACL: structs.ACL{
+			Name: "User token",
+			Type: structs.ACLTypeClient,
+			Rules: `
+service "foo" {
+	policy = "read"
+}
+node "allowed" {
+	policy = "read"
+}
+`,
+		},
+		WriteRequest: structs.WriteRequest{Token: "root"},
+	}
+	var token string
+	if err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &token); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Register the service on both nodes.
+	register := func(node, status string) {
+		reg := structs.RegisterRequest{
+			Datacenter: "dc1",
+			Node:       node,
+			Address:    "127.0.0.1",
+			Service: &structs.NodeService{
+				ID:      "foo",
+				Service: "foo",
+			},
+			Check: &structs.HealthCheck{
+				CheckID:   "service:foo",
+				Name:      "service:foo",
+				ServiceID: "foo",
+				Status:    status,
+			},
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		var unused struct{}
+		if err := msgpackrpc.CallWithCodec(codec, "Catalog.Register", &reg, &unused); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	register("allowed", structs.HealthPassing)
+	register("denied", structs.HealthPassing)
+
+	// Turn on version 8 enforcement, and the snapshot should only have the
+	// node we can read.
+	s1.config.ACLEnforceVersion8 = true
+	args := structs.StreamRequest{
+		Datacenter:   "dc1",
+		Topic:        structs.StreamTopicServiceHealth,
+		Key:          "foo",
+		QueryOptions: structs.QueryOptions{Token: token},
+	}
+	var out structs.IndexedStreamEvents
+	if err := msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Events) != 2 || out.Events[0].Op != structs.StreamAdd ||
+		out.Events[0].ServiceHealth.Node.Node != "allowed" ||
+		out.Events[1].Op != structs.StreamEndOfSnapshot {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// The same goes for the changes after it.
+	register("denied", structs.HealthCritical)
+	register("allowed", structs.HealthCritical)
+	args.MinQueryIndex = out.Index
+	out = structs.IndexedStreamEvents{}
+	if err := msgpackrpc.CallWithCodec(codec, "Stream.Events", &args, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Events) != 1 || out.Events[0].Op != structs.StreamUpdate ||
+		out.Events[0].ServiceHealth.Node.Node != "allowed" {
+		t.Fatalf("bad: %#v", out)
+	}
+}
@@ -0,0 +1,67 @@
+package structs
+
+// StreamTopic identifies a class of state store changes that can be
+// subscribed to.
+type StreamTopic string
+
+const (
+	// StreamTopicServiceHealth carries the health of individual service
+	// instances, keyed by service name. Each event holds the node, the
+	// service and all the checks that apply to it.
+	StreamTopicServiceHealth StreamTopic = "service-health"
+)
+
+// StreamOp describes what a stream event means to a subscriber.
+type StreamOp string
+
+const (
+	// StreamAdd, StreamUpdate and StreamDelete are used for changes to a
+	// single item. A snapshot is made up of StreamAdd events.
+	StreamAdd    StreamOp = "add"
+	StreamUpdate StreamOp = "update"
+	StreamDelete StreamOp = "delete"
+
+	// StreamEndOfSnapshot follows the events that make up a snapshot of the
+	// current state, after which only changes are sent.
+	StreamEndOfSnapshot StreamOp = "end-of-snapshot"
+
+	// StreamReset is sent when a subscriber resumes from an index that the
+	// server can no longer serve changes from. The subscriber should throw
+	// away what it has, as a fresh snapshot follows.
+	StreamReset StreamOp = "reset"
+)
+
+// StreamEvent is a single change on a topic. All the events generated by a
+// single Raft log entry share the same index, as do all the events of a
+// snapshot.
+type StreamEvent struct {
+	Topic StreamTopic
+	Key   string
+	Index uint64
+	Op    StreamOp
+
+	// ServiceHealth is set for add, update and delete events on the
+	// service health topic. For deletes it holds the last known state.
+	ServiceHealth *CheckServiceNode `json:",omitempty"`
+}
+
+// StreamRequest is used to fetch the events on a topic for a given key. If
+// MinQueryIndex is zero, a snapshot of the current state is returned, and
+// otherwise the request blocks until there are events after that index.
+type StreamRequest struct {
+	Datacenter string
+	Topic      StreamTopic
+	Key        string
+	QueryOptions
+}
+
+func (r *StreamRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// IndexedStreamEvents is the response to a StreamRequest. The index is the
+// one the subscriber should ask for next.
+type IndexedStreamEvents struct {
+	Events []*StreamEvent
+	QueryMeta
+}
@@ -3,8 +3,12 @@ package structs
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/consul/acl"
@@ -38,6 +42,11 @@ const (
 	CoordinateBatchUpdateType
 	PreparedQueryRequestType
 	TxnRequestType
+	KVSHistoryRequestType
+	KVSHistoryEntryType // Only used in snapshots
+	ACLPolicyEntryRequestType
+	ACLReapRequestType
+	KVSHistoryRulesIndexType // Only used in snapshots
 )
 
 const (
@@ -67,6 +76,21 @@ const (
 	ServiceMaintPrefix = "_service_maintenance:"
 )
 
+const (
+	// MetaKeyReservedPrefix is the prefix used for metadata keys that are
+	// reserved for internal use by Consul.
+	MetaKeyReservedPrefix = "consul-"
+
+	// Limits on the number and size of metadata entries, which keep the
+	// catalog and snapshots from growing without bound.
+	MetaMaxKeyPairs    = 64
+	MetaKeyMaxLength   = 128
+	MetaValueMaxLength = 512
+)
+
+// metaKeyFormat checks if a metadata key string is valid.
+var metaKeyFormat = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
 func ValidStatus(s string) bool {
 	return s == HealthPassing ||
 		s == HealthWarning ||
@@ -116,6 +140,11 @@ type QueryOptions struct {
 	// If set, the leader must verify leadership prior to
 	// servicing the request. Prevents a stale read.
 	RequireConsistent bool
+
+	// Filter is an optional expression used to filter the results of
+	// list endpoints on the server. See the filter package for the
+	// supported syntax.
+	Filter string
 }
 
 // QueryOption only applies to reads, so always true
@@ -170,9 +199,11 @@ type QueryMeta struct {
 // is provided, the node is registered.
 type RegisterRequest struct {
 	Datacenter      string
+	ID              types.NodeID
 	Node            string
 	Address         string
 	TaggedAddresses map[string]string
+	NodeMeta        map[string]string
 	Service         *NodeService
 	Check           *HealthCheck
 	Checks          HealthChecks
@@ -192,10 +223,13 @@ func (r *RegisterRequest) ChangesNode(node *Node) bool {
 		return true
 	}
 
-	// Check if any of the node-level fields are being changed.
-	if r.Node != node.Node ||
+	// Check if any of the node-level fields are being changed. An empty
+	// ID leaves the node's existing ID alone.
+	if (r.ID != "" && r.ID != node.ID) ||
+		r.Node != node.Node ||
 		r.Address != node.Address ||
-		!reflect.DeepEqual(r.TaggedAddresses, node.TaggedAddresses) {
+		!reflect.DeepEqual(r.TaggedAddresses, node.TaggedAddresses) ||
+		!reflect.DeepEqual(r.NodeMeta, node.Meta) {
 		return true
 	}
 
@@ -227,8 +261,9 @@ type QuerySource struct {
 
 // DCSpecificRequest is used to query about a specific DC
 type DCSpecificRequest struct {
-	Datacenter string
-	Source     QuerySource
+	Datacenter      string
+	NodeMetaFilters map[string]string
+	Source          QuerySource
 	QueryOptions
 }
 
@@ -238,11 +273,13 @@ func (r *DCSpecificRequest) RequestDatacenter() string {
 
 // ServiceSpecificRequest is used to query about a specific service
 type ServiceSpecificRequest struct {
-	Datacenter  string
-	ServiceName string
-	ServiceTag  string
-	TagFilter   bool // Controls tag filtering
-	Source      QuerySource
+	Datacenter      string
+	NodeMetaFilters map[string]string
+	ServiceName     string
+	ServiceTag      string
+	ServiceTags     []string
+	TagFilter       bool // Controls tag filtering
+	Source          QuerySource
 	QueryOptions
 }
 
@@ -250,6 +287,35 @@ func (r *ServiceSpecificRequest) RequestDatacenter() string {
 	return r.Datacenter
 }
 
+// FilterTags returns the full set of tags to filter on, folding in the single
+// ServiceTag that older clients send along with any ServiceTags. Tags prefixed
+// with "!" must not be present on the service.
+func (r *ServiceSpecificRequest) FilterTags() []string {
+	if r.ServiceTag == "" {
+		return r.ServiceTags
+	}
+	tags := make([]string, 0, len(r.ServiceTags)+1)
+	tags = append(tags, r.ServiceTag)
+	return append(tags, r.ServiceTags...)
+}
+
+// SetFilterTags sets up the request to filter on the given tags. The first
+// tag that isn't negated is sent as the single ServiceTag, so servers that
+// predate multiple tags still filter on it during an upgrade instead of
+// ignoring the tags altogether.
+func (r *ServiceSpecificRequest) SetFilterTags(tags []string) {
+	r.TagFilter = len(tags) > 0
+	r.ServiceTag = ""
+	r.ServiceTags = nil
+	for _, tag := range tags {
+		if r.ServiceTag == "" && !strings.HasPrefix(tag, "!") {
+			r.ServiceTag = tag
+			continue
+		}
+		r.ServiceTags = append(r.ServiceTags, tag)
+	}
+}
+
 // NodeSpecificRequest is used to request the information about a single node
 type NodeSpecificRequest struct {
 	Datacenter string
@@ -275,52 +341,167 @@ func (r *ChecksInStateRequest) RequestDatacenter() string {
 
 // Used to return information about a node
 type Node struct {
+	ID              types.NodeID
 	Node            string
 	Address         string
 	TaggedAddresses map[string]string
+	Meta            map[string]string
 
 	RaftIndex
 }
 type Nodes []*Node
 
+// ValidateMetadata validates a set of metadata key/value pairs, such as
+// those attached to a node or a service.
+func ValidateMetadata(meta map[string]string) error {
+	if len(meta) > MetaMaxKeyPairs {
+		return fmt.Errorf("Metadata cannot contain more than %d key/value pairs", MetaMaxKeyPairs)
+	}
+
+	for key, value := range meta {
+		if err := validateMetaPair(key, value); err != nil {
+			return fmt.Errorf("Couldn't load metadata pair ('%s', '%s'): %s", key, value, err)
+		}
+	}
+
+	return nil
+}
+
+// validateMetaPair checks that the given key/value pair is in a valid format.
+func validateMetaPair(key, value string) error {
+	if key == "" {
+		return fmt.Errorf("Key cannot be blank")
+	}
+	if !metaKeyFormat.MatchString(key) {
+		return fmt.Errorf("Key contains invalid characters")
+	}
+	if len(key) > MetaKeyMaxLength {
+		return fmt.Errorf("Key is too long (limit: %d characters)", MetaKeyMaxLength)
+	}
+	if strings.HasPrefix(key, MetaKeyReservedPrefix) {
+		return fmt.Errorf("Key prefix '%s' is reserved for internal use", MetaKeyReservedPrefix)
+	}
+	if len(value) > MetaValueMaxLength {
+		return fmt.Errorf("Value is too long (limit: %d characters)", MetaValueMaxLength)
+	}
+	return nil
+}
+
+// SatisfiesMetaFilters returns true if the metadata map contains the given
+// filters, or if there are no filters given.
+func SatisfiesMetaFilters(meta map[string]string, filters map[string]string) bool {
+	for key, value := range filters {
+		if v, ok := meta[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ServiceTagFilter is a compiled set of tag constraints. A service satisfies
+// the filter if it has ALL of the required tags and NONE of the forbidden
+// tags, which are given with a "!" prefix. Tags are compared without regard
+// to case.
+type ServiceTagFilter struct {
+	must []string
+	not  []string
+}
+
+// NewServiceTagFilter builds a filter from the given list of tags.
+func NewServiceTagFilter(tags []string) *ServiceTagFilter {
+	f := &ServiceTagFilter{}
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if strings.HasPrefix(tag, "!") {
+			f.not = append(f.not, tag[1:])
+		} else {
+			f.must = append(f.must, tag)
+		}
+	}
+	return f
+}
+
+// Matches returns true if the given service tags satisfy the filter.
+func (f *ServiceTagFilter) Matches(tags []string) bool {
+	// Index the tags so lookups this way are cheaper.
+	index := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		index[strings.ToLower(tag)] = struct{}{}
+	}
+
+	// Bail if any of the required tags are missing.
+	for _, tag := range f.must {
+		if _, ok := index[tag]; !ok {
+			return false
+		}
+	}
+
+	// Bail if any of the disallowed tags are present.
+	for _, tag := range f.not {
+		if _, ok := index[tag]; ok {
+			return false
+		}
+	}
+	return true
+}
+
 // Used to return information about a provided services.
 // Maps service name to available tags
 type Services map[string][]string
 
-// ServiceNode represents a node that is part of a service. Address and
-// TaggedAddresses are node-related fields that are always empty in the state
-// store and are filled in on the way out by parseServiceNodes(). This is also
-// why PartialClone() skips them, because we know they are blank already so it
-// would be a waste of time to copy them.
+// ServiceNode represents a node that is part of a service. Address,
+// TaggedAddresses and NodeMeta are node-related fields that are always empty
+// in the state store and are filled in on the way out by parseServiceNodes().
+// This is also why PartialClone() skips them, because we know they are blank
+// already so it would be a waste of time to copy them.
 type ServiceNode struct {
 	Node                     string
 	Address                  string
 	TaggedAddresses          map[string]string
+	NodeMeta                 map[string]string
 	ServiceID                string
 	ServiceName              string
 	ServiceTags              []string
 	ServiceAddress           string
+	ServiceMeta              map[string]string
 	ServicePort              int
+	ServiceWeights           *Weights
 	ServiceEnableTagOverride bool
 
 	RaftIndex
 }
 
 // PartialClone() returns a clone of the given service node, minus the node-
-// related fields that get filled in later, Address and TaggedAddresses.
+// related fields that get filled in later, Address, TaggedAddresses and
+// NodeMeta.
 func (s *ServiceNode) PartialClone() *ServiceNode {
 	tags := make([]string, len(s.ServiceTags))
 	copy(tags, s.ServiceTags)
+	var nsmeta map[string]string
+	if s.ServiceMeta != nil {
+		nsmeta = make(map[string]string, len(s.ServiceMeta))
+		for k, v := range s.ServiceMeta {
+			nsmeta[k] = v
+		}
+	}
+	var weights *Weights
+	if s.ServiceWeights != nil {
+		w := *s.ServiceWeights
+		weights = &w
+	}
 
 	return &ServiceNode{
 		Node: s.Node,
 		// Skip Address, see above.
 		// Skip TaggedAddresses, see above.
+		// Skip NodeMeta, see above.
 		ServiceID:                s.ServiceID,
 		ServiceName:              s.ServiceName,
 		ServiceTags:              tags,
 		ServiceAddress:           s.ServiceAddress,
 		ServicePort:              s.ServicePort,
+		ServiceMeta:              nsmeta,
+		ServiceWeights:           weights,
 		ServiceEnableTagOverride: s.ServiceEnableTagOverride,
 		RaftIndex: RaftIndex{
 			CreateIndex: s.CreateIndex,
@@ -337,6 +518,8 @@ func (s *ServiceNode) ToNodeService() *NodeService {
 		Tags:              s.ServiceTags,
 		Address:           s.ServiceAddress,
 		Port:              s.ServicePort,
+		Meta:              s.ServiceMeta,
+		Weights:           s.ServiceWeights,
 		EnableTagOverride: s.ServiceEnableTagOverride,
 		RaftIndex: RaftIndex{
 			CreateIndex: s.CreateIndex,
@@ -353,12 +536,44 @@ type NodeService struct {
 	Service           string
 	Tags              []string
 	Address           string
+	Meta              map[string]string
 	Port              int
+	Weights           *Weights
 	EnableTagOverride bool
 
 	RaftIndex
 }
 
+// Weights give the relative share of traffic that a service instance should
+// get, depending on the state of its health checks. Services without any
+// weights use DefaultWeights.
+type Weights struct {
+	Passing int
+	Warning int
+}
+
+// DefaultWeights are the weights used by services that don't specify any.
+var DefaultWeights = Weights{
+	Passing: 1,
+	Warning: 1,
+}
+
+// Validate makes sure the weights make sense. A service that's passing has
+// to get some traffic, but instances with warnings can be taken out of the
+// rotation with a zero weight.
+func (w *Weights) Validate() error {
+	if w.Passing < 1 {
+		return fmt.Errorf("Passing weight must be greater than 0")
+	}
+	if w.Warning < 0 {
+		return fmt.Errorf("Warning weight must not be negative")
+	}
+	if w.Passing > math.MaxUint16 || w.Warning > math.MaxUint16 {
+		return fmt.Errorf("Weights must not be greater than %d", math.MaxUint16)
+	}
+	return nil
+}
+
 // IsSame checks if one NodeService is the same as another, without looking
 // at the Raft information (that's why we didn't call it IsEqual). This is
 // useful for seeing if an update would be idempotent for all the functional
@@ -369,6 +584,8 @@ func (s *NodeService) IsSame(other *NodeService) bool {
 		!reflect.DeepEqual(s.Tags, other.Tags) ||
 		s.Address != other.Address ||
 		s.Port != other.Port ||
+		!reflect.DeepEqual(s.Meta, other.Meta) ||
+		!reflect.DeepEqual(s.Weights, other.Weights) ||
 		s.EnableTagOverride != other.EnableTagOverride {
 		return false
 	}
@@ -382,11 +599,14 @@ func (s *NodeService) ToServiceNode(node string) *ServiceNode {
 		Node: node,
 		// Skip Address, see ServiceNode definition.
 		// Skip TaggedAddresses, see ServiceNode definition.
+		// Skip NodeMeta, see ServiceNode definition.
 		ServiceID:                s.ID,
 		ServiceName:              s.Service,
 		ServiceTags:              s.Tags,
 		ServiceAddress:           s.Address,
 		ServicePort:              s.Port,
+		ServiceMeta:              s.Meta,
+		ServiceWeights:           s.Weights,
 		ServiceEnableTagOverride: s.EnableTagOverride,
 		RaftIndex: RaftIndex{
 			CreateIndex: s.CreateIndex,
@@ -452,12 +672,80 @@ type CheckServiceNode struct {
 }
 type CheckServiceNodes []CheckServiceNode
 
-// Shuffle does an in-place random shuffle using the Fisher-Yates algorithm.
+// Weight returns the share of traffic the instance should get, based on the
+// service's weights and the worst status of its checks. Instances with any
+// critical checks get a weight of zero.
+func (c *CheckServiceNode) Weight() int {
+	weights := DefaultWeights
+	if c.Service != nil && c.Service.Weights != nil {
+		weights = *c.Service.Weights
+	}
+
+	weight := weights.Passing
+	for _, check := range c.Checks {
+		switch check.Status {
+		case HealthCritical:
+			return 0
+		case HealthWarning:
+			weight = weights.Warning
+		}
+	}
+	return weight
+}
+
+// HasWeights returns true if any of the service instances have weights set.
+func (nodes CheckServiceNodes) HasWeights() bool {
+	for _, node := range nodes {
+		if node.Service != nil && node.Service.Weights != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Shuffle does an in-place weighted random shuffle, so that the chance of an
+// instance coming first is proportional to its weight. Instances with a
+// weight of zero always come last, in random order. When all the weights are
+// equal this is a uniform shuffle.
 func (nodes CheckServiceNodes) Shuffle() {
+	// Start with a uniform Fisher-Yates shuffle so that ties are broken
+	// randomly.
 	for i := len(nodes) - 1; i > 0; i-- {
 		j := rand.Int31n(int32(i + 1))
 		nodes[i], nodes[j] = nodes[j], nodes[i]
 	}
+
+	// Then order by exponentially distributed keys scaled by the weights,
+	// which is equivalent to repeatedly picking an instance at random in
+	// proportion to its weight.
+	keys := make([]float64, len(nodes))
+	for i := range nodes {
+		if weight := nodes[i].Weight(); weight > 0 {
+			keys[i] = rand.ExpFloat64() / float64(weight)
+		} else {
+			keys[i] = math.Inf(1)
+		}
+	}
+	sort.Stable(&weightedNodes{nodes, keys})
+}
+
+// weightedNodes is used to sort nodes by their shuffle keys.
+type weightedNodes struct {
+	nodes CheckServiceNodes
+	keys  []float64
+}
+
+func (w *weightedNodes) Len() int {
+	return len(w.nodes)
+}
+
+func (w *weightedNodes) Less(i, j int) bool {
+	return w.keys[i] < w.keys[j]
+}
+
+func (w *weightedNodes) Swap(i, j int) {
+	w.nodes[i], w.nodes[j] = w.nodes[j], w.nodes[i]
+	w.keys[i], w.keys[j] = w.keys[j], w.keys[i]
 }
 
 // Filter removes nodes that are failing health checks (and any non-passing
@@ -485,9 +773,11 @@ OUTER:
 // a node. This is currently used for the UI only, as it is
 // rather expensive to generate.
 type NodeInfo struct {
+	ID              types.NodeID
 	Node            string
 	Address         string
 	TaggedAddresses map[string]string
+	Meta            map[string]string
 	Services        []*NodeService
 	Checks          HealthChecks
 }
@@ -541,6 +831,10 @@ type DirEntry struct {
 	Value     []byte
 	Session   string `json:",omitempty"`
 
+	// TTL, if set, is how long the entry lives after it was last written
+	// before the leader deletes it.
+	TTL string `json:",omitempty"`
+
 	RaftIndex
 }
 
@@ -552,6 +846,7 @@ func (d *DirEntry) Clone() *DirEntry {
 		Flags:     d.Flags,
 		Value:     d.Value,
 		Session:   d.Session,
+		TTL:       d.TTL,
 		RaftIndex: RaftIndex{
 			CreateIndex: d.CreateIndex,
 			ModifyIndex: d.ModifyIndex,
@@ -607,6 +902,16 @@ func (r *KVSRequest) RequestDatacenter() string {
 type KeyRequest struct {
 	Datacenter string
 	Key        string
+
+	// AtIndex, if set, makes a single key lookup return the key as it was
+	// at the given index, using the key's history.
+	AtIndex uint64
+
+	// After and Limit page through a listing. Only keys sorting after
+	// After are returned, up to Limit of them if it's >0.
+	After string
+	Limit int
+
 	QueryOptions
 }
 
@@ -619,6 +924,11 @@ type KeyListRequest struct {
 	Datacenter string
 	Prefix     string
 	Seperator  string
+
+	// After and Limit page through the keys, as for KeyRequest.
+	After string
+	Limit int
+
 	QueryOptions
 }
 
@@ -626,13 +936,132 @@ func (r *KeyListRequest) RequestDatacenter() string {
 	return r.Datacenter
 }
 
+// IndexedDirEntries is the result of a key lookup or listing. NextAfter is
+// set if a listing was cut off by its limit, and is the After to use for
+// the next page.
 type IndexedDirEntries struct {
-	Entries DirEntries
+	Entries   DirEntries
+	NextAfter string
 	QueryMeta
 }
 
 type IndexedKeyList struct {
-	Keys []string
+	Keys      []string
+	NextAfter string
+	QueryMeta
+}
+
+// KVSHistoryRule keeps the prior versions of the keys under a prefix.
+// Versions, if >0, is how many prior versions of each key are kept, and
+// Window, if >0, is how long a version is kept after it's replaced. If
+// both are set, a version is dropped as soon as either limit is reached.
+type KVSHistoryRule struct {
+	Prefix   string
+	Versions int
+	Window   time.Duration
+}
+
+type KVSHistoryRules []*KVSHistoryRule
+
+// KVSQuota limits the keys under a prefix. MaxKeys limits the number of
+// keys, MaxBytes limits the total size of their values, and MaxValueSize
+// limits the size of each value, where a zero leaves that dimension
+// unlimited. If Token is set, the quota only applies to writes made with
+// that token, though its usage still counts every key under the prefix.
+type KVSQuota struct {
+	Prefix       string
+	Token        string
+	MaxKeys      int
+	MaxBytes     int64
+	MaxValueSize int
+}
+
+type KVSQuotas []*KVSQuota
+
+// KVSQuotaUsage reports the current usage of the prefix of a KV quota
+// along with its limits. The quota's token is left out, so TokenScoped
+// only says whether it has one.
+type KVSQuotaUsage struct {
+	Prefix       string
+	TokenScoped  bool
+	MaxKeys      int
+	MaxBytes     int64
+	MaxValueSize int
+	Keys         int
+	Bytes        int64
+}
+
+// IndexedKVSQuotaUsage has the usage of each configured KV quota.
+type IndexedKVSQuotaUsage struct {
+	Quotas []*KVSQuotaUsage
+	QueryMeta
+}
+
+// KVSReplicationStatus provides information about the health of the
+// replication of one key prefix from another datacenter.
+type KVSReplicationStatus struct {
+	Running          bool
+	SourceDatacenter string
+	Prefix           string
+	DestPrefix       string
+	ReplicatedIndex  uint64
+	LastSuccess      time.Time
+	LastError        time.Time
+}
+
+// KVSReplicationStatuses is a list of KV replication statuses, one for
+// each replicated prefix.
+type KVSReplicationStatuses []*KVSReplicationStatus
+
+// KVSHistoryEntry is a prior version of a key, kept by a history rule.
+// ReplacedIndex is the index at which the version was overwritten or
+// deleted, so the version was current from its ModifyIndex up to, but not
+// including, its ReplacedIndex.
+type KVSHistoryEntry struct {
+	LockIndex     uint64
+	Key           string
+	Flags         uint64
+	Value         []byte
+	Session       string `json:",omitempty"`
+	TTL           string `json:",omitempty"`
+	ReplacedIndex uint64
+
+	RaftIndex
+}
+
+type KVSHistoryEntries []*KVSHistoryEntry
+
+type KVSHistoryOp string
+
+const (
+	KVSHistorySetRules       KVSHistoryOp = "set-rules"
+	KVSHistoryPrune                       = "prune"
+	KVSHistoryPruneUncovered              = "prune-uncovered"
+)
+
+// KVSHistoryRequest is used to change the history rules. It's also used by
+// the leader to prune the versions kept by the rule with the given Prefix,
+// or the versions that no rule covers, that were replaced before Index.
+type KVSHistoryRequest struct {
+	Datacenter string
+	Op         KVSHistoryOp
+	Rules      KVSHistoryRules
+	Prefix     string
+	Index      uint64
+	WriteRequest
+}
+
+func (r *KVSHistoryRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+type IndexedKVSHistory struct {
+	Entries KVSHistoryEntries
+	QueryMeta
+}
+
+type IndexedKVSHistoryRules struct {
+	Rules KVSHistoryRules
 	QueryMeta
 }
 
@@ -698,17 +1127,36 @@ type IndexedSessions struct {
 	QueryMeta
 }
 
-// ACL is used to represent a token and its rules
+// ACL is used to represent a token and its rules. Policies holds the IDs
+// of any ACL policies linked to the token, whose rules are merged with the
+// token's own rules.
 type ACL struct {
-	ID    string
-	Name  string
-	Type  string
-	Rules string
+	ID       string
+	Name     string
+	Type     string
+	Rules    string
+	Policies []string
+
+	// ExpirationTime is when the token expires, after which it's treated
+	// as though it doesn't exist until the leader reaps it. A nil time
+	// means the token never expires.
+	ExpirationTime *time.Time `json:",omitempty"`
+
+	// ExpirationTTL may be given instead of an ExpirationTime when setting
+	// a token, to have it expire this long after the change is applied.
+	// It's converted to an ExpirationTime and isn't stored.
+	ExpirationTTL time.Duration `json:",omitempty"`
 
 	RaftIndex
 }
 type ACLs []*ACL
 
+// IsExpired returns true if the ACL has an expiration time at or before
+// the given time.
+func (a *ACL) IsExpired(now time.Time) bool {
+	return a.ExpirationTime != nil && !now.Before(*a.ExpirationTime)
+}
+
 type ACLOp string
 
 const (
@@ -725,10 +1173,25 @@ func (a *ACL) IsSame(other *ACL) bool {
 	if a.ID != other.ID ||
 		a.Name != other.Name ||
 		a.Type != other.Type ||
-		a.Rules != other.Rules {
+		a.Rules != other.Rules ||
+		len(a.Policies) != len(other.Policies) {
 		return false
 	}
 
+	switch {
+	case a.ExpirationTime == nil && other.ExpirationTime == nil:
+	case a.ExpirationTime == nil || other.ExpirationTime == nil:
+		return false
+	case !a.ExpirationTime.Equal(*other.ExpirationTime):
+		return false
+	}
+
+	for i := range a.Policies {
+		if a.Policies[i] != other.Policies[i] {
+			return false
+		}
+	}
+
 	return true
 }
 
@@ -747,6 +1210,22 @@ func (r *ACLRequest) RequestDatacenter() string {
 // ACLRequests is a list of ACL change requests.
 type ACLRequests []*ACLRequest
 
+// ACLReapRequest is used by the leader to delete a batch of expired ACLs.
+type ACLReapRequest struct {
+	Datacenter string
+	ACLs       []string
+
+	// Time is the leader's clock when the batch was made. Only ACLs that
+	// had expired by then are deleted, so an ACL whose expiration was
+	// pushed out in the meantime is left alone.
+	Time time.Time
+	WriteRequest
+}
+
+func (r *ACLReapRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
 // ACLSpecificRequest is used to request an ACL by ID
 type ACLSpecificRequest struct {
 	Datacenter string
@@ -764,6 +1243,11 @@ type ACLPolicyRequest struct {
 	Datacenter string
 	ACL        string
 	ETag       string
+
+	// SourceDatacenter is the datacenter the policy will be enforced in,
+	// which decides which of the token's ACL policies apply. If empty,
+	// the ACL datacenter is assumed.
+	SourceDatacenter string
 	QueryOptions
 }
 
@@ -781,6 +1265,83 @@ type ACLPolicy struct {
 	Parent string
 	Policy *acl.Policy
 	TTL    time.Duration
+
+	// ExpirationTime is the expiration time of the token, if any, so that
+	// caches don't keep using the policy past it.
+	ExpirationTime *time.Time
+	QueryMeta
+}
+
+// ACLPolicyEntry is a named set of ACL rules that can be linked to any
+// number of tokens. If Datacenters is given, the policy only applies to
+// requests made in those datacenters.
+type ACLPolicyEntry struct {
+	ID          string
+	Name        string
+	Rules       string
+	Datacenters []string
+
+	RaftIndex
+}
+type ACLPolicyEntries []*ACLPolicyEntry
+
+// IsSame checks if one policy is the same as another, without looking at
+// the Raft information.
+func (p *ACLPolicyEntry) IsSame(other *ACLPolicyEntry) bool {
+	if p.ID != other.ID ||
+		p.Name != other.Name ||
+		p.Rules != other.Rules ||
+		len(p.Datacenters) != len(other.Datacenters) {
+		return false
+	}
+
+	for i := range p.Datacenters {
+		if p.Datacenters[i] != other.Datacenters[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AppliesTo returns true if the policy applies in the given datacenter.
+func (p *ACLPolicyEntry) AppliesTo(dc string) bool {
+	if len(p.Datacenters) == 0 {
+		return true
+	}
+	for _, d := range p.Datacenters {
+		if d == dc {
+			return true
+		}
+	}
+	return false
+}
+
+// ACLPolicyEntryRequest is used to create, update or delete an ACL policy.
+type ACLPolicyEntryRequest struct {
+	Datacenter string
+	Op         ACLOp
+	Policy     ACLPolicyEntry
+	WriteRequest
+}
+
+func (r *ACLPolicyEntryRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+// ACLPolicyEntrySpecificRequest is used to request an ACL policy by ID.
+type ACLPolicyEntrySpecificRequest struct {
+	Datacenter string
+	PolicyID   string
+	QueryOptions
+}
+
+func (r *ACLPolicyEntrySpecificRequest) RequestDatacenter() string {
+	return r.Datacenter
+}
+
+type IndexedACLPolicyEntries struct {
+	Policies ACLPolicyEntries
 	QueryMeta
 }
 
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/types"
 )
@@ -103,19 +104,51 @@ func TestStructs_ACL_IsSame(t *testing.T) {
 	check(func() { other.Name = "nope" }, func() { other.Name = "An ACL for testing" })
 	check(func() { other.Type = "management" }, func() { other.Type = "client" })
 	check(func() { other.Rules = "" }, func() { other.Rules = "service \"\" { policy = \"read\" }" })
+
+	expires := time.Unix(1500000000, 0)
+	check(func() { other.ExpirationTime = &expires }, func() { other.ExpirationTime = nil })
+
+	acl.ExpirationTime = &expires
+	other.ExpirationTime = &expires
+	later := expires.Add(time.Second)
+	check(func() { other.ExpirationTime = &later }, func() { other.ExpirationTime = &expires })
+}
+
+func TestStructs_ACL_IsExpired(t *testing.T) {
+	acl := &ACL{ID: "guid"}
+	if acl.IsExpired(time.Now()) {
+		t.Fatalf("should not expire without an expiration time")
+	}
+
+	expires := time.Unix(1500000000, 0)
+	acl.ExpirationTime = &expires
+	if acl.IsExpired(expires.Add(-time.Second)) {
+		t.Fatalf("should not be expired yet")
+	}
+	if !acl.IsExpired(expires) || !acl.IsExpired(expires.Add(time.Second)) {
+		t.Fatalf("should be expired")
+	}
 }
 
 func TestStructs_RegisterRequest_ChangesNode(t *testing.T) {
 	req := &RegisterRequest{
+		ID:              types.NodeID("40e4a748-2192-161a-0510-9bf59fe950b5"),
 		Node:            "test",
 		Address:         "127.0.0.1",
 		TaggedAddresses: make(map[string]string),
+		NodeMeta: map[string]string{
+			"role": "server",
+		},
 	}
 
 	node := &Node{
+		ID:              types.NodeID("40e4a748-2192-161a-0510-9bf59fe950b5"),
 		Node:            "test",
 		Address:         "127.0.0.1",
 		TaggedAddresses: make(map[string]string),
+		Meta: map[string]string{
+			"role": "server",
+		},
 	}
 
 	check := func(twiddle, restore func()) {
@@ -134,15 +167,110 @@ func TestStructs_RegisterRequest_ChangesNode(t *testing.T) {
 		}
 	}
 
+	check(func() { req.ID = "nope" }, func() { req.ID = types.NodeID("40e4a748-2192-161a-0510-9bf59fe950b5") })
 	check(func() { req.Node = "nope" }, func() { req.Node = "test" })
 	check(func() { req.Address = "127.0.0.2" }, func() { req.Address = "127.0.0.1" })
 	check(func() { req.TaggedAddresses["wan"] = "nope" }, func() { delete(req.TaggedAddresses, "wan") })
+	check(func() { req.NodeMeta["invalid"] = "nope" }, func() { delete(req.NodeMeta, "invalid") })
+
+	// An empty ID leaves the existing ID alone.
+	req.ID = ""
+	if req.ChangesNode(node) {
+		t.Fatalf("should not change")
+	}
 
 	if !req.ChangesNode(nil) {
 		t.Fatalf("should change")
 	}
 }
 
+func TestStructs_validateMetaPair(t *testing.T) {
+	longKey := strings.Repeat("a", MetaKeyMaxLength+1)
+	longValue := strings.Repeat("b", MetaValueMaxLength+1)
+	pairs := []struct {
+		Key   string
+		Value string
+		Error string
+	}{
+		// valid pair
+		{"key", "value", ""},
+		// invalid, blank key
+		{"", "value", "cannot be blank"},
+		// allowed special chars in key name
+		{"k_e-y", "value", ""},
+		// disallowed special chars in key name
+		{"(%key&)", "value", "invalid characters"},
+		// key too long
+		{longKey, "value", "Key is too long"},
+		// reserved prefix
+		{MetaKeyReservedPrefix + "key", "value", "reserved for internal use"},
+		// value too long
+		{"key", longValue, "Value is too long"},
+	}
+
+	for _, pair := range pairs {
+		err := validateMetaPair(pair.Key, pair.Value)
+		if pair.Error == "" && err != nil {
+			t.Fatalf("should have succeeded: %v, %v", pair, err)
+		} else if pair.Error != "" && (err == nil || !strings.Contains(err.Error(), pair.Error)) {
+			t.Fatalf("should have failed: %v, %v", pair, err)
+		}
+	}
+}
+
+func TestStructs_ValidateMetadata(t *testing.T) {
+	meta := make(map[string]string)
+	for i := 0; i < MetaMaxKeyPairs; i++ {
+		meta[fmt.Sprintf("key%d", i)] = "value"
+	}
+	if err := ValidateMetadata(meta); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// One more pair should put us over the limit
+	meta["toomany"] = "value"
+	if err := ValidateMetadata(meta); err == nil {
+		t.Fatalf("should have failed")
+	}
+}
+
+func TestStructs_SatisfiesMetaFilters(t *testing.T) {
+	meta := map[string]string{
+		"rack":     "b",
+		"instance": "m3.large",
+	}
+
+	// No filters should always be satisfied.
+	if !SatisfiesMetaFilters(meta, nil) {
+		t.Fatalf("should match")
+	}
+	if !SatisfiesMetaFilters(nil, nil) {
+		t.Fatalf("should match")
+	}
+
+	// All of the filters must match.
+	if !SatisfiesMetaFilters(meta, map[string]string{"rack": "b"}) {
+		t.Fatalf("should match")
+	}
+	if !SatisfiesMetaFilters(meta, map[string]string{"rack": "b", "instance": "m3.large"}) {
+		t.Fatalf("should match")
+	}
+	if SatisfiesMetaFilters(meta, map[string]string{"rack": "b", "instance": "m3.xlarge"}) {
+		t.Fatalf("should not match")
+	}
+	if SatisfiesMetaFilters(meta, map[string]string{"rack": "a"}) {
+		t.Fatalf("should not match")
+	}
+
+	// Missing keys never match, even against an empty value.
+	if SatisfiesMetaFilters(meta, map[string]string{"env": ""}) {
+		t.Fatalf("should not match")
+	}
+	if SatisfiesMetaFilters(nil, map[string]string{"rack": "b"}) {
+		t.Fatalf("should not match")
+	}
+}
+
 // testServiceNode gives a fully filled out ServiceNode instance.
 func testServiceNode() *ServiceNode {
 	return &ServiceNode{
@@ -151,11 +279,21 @@ func testServiceNode() *ServiceNode {
 		TaggedAddresses: map[string]string{
 			"hello": "world",
 		},
-		ServiceID:                "service1",
-		ServiceName:              "dogs",
-		ServiceTags:              []string{"prod", "v1"},
-		ServiceAddress:           "127.0.0.2",
-		ServicePort:              8080,
+		NodeMeta: map[string]string{
+			"tag": "value",
+		},
+		ServiceID:      "service1",
+		ServiceName:    "dogs",
+		ServiceTags:    []string{"prod", "v1"},
+		ServiceAddress: "127.0.0.2",
+		ServicePort:    8080,
+		ServiceMeta: map[string]string{
+			"version": "1.2.3",
+		},
+		ServiceWeights: &Weights{
+			Passing: 10,
+			Warning: 1,
+		},
 		ServiceEnableTagOverride: true,
 		RaftIndex: RaftIndex{
 			CreateIndex: 1,
@@ -172,12 +310,13 @@ func TestStructs_ServiceNode_PartialClone(t *testing.T) {
 	// Make sure the parts that weren't supposed to be cloned didn't get
 	// copied over, then zero-value them out so we can do a DeepEqual() on
 	// the rest of the contents.
-	if clone.Address != "" || len(clone.TaggedAddresses) != 0 {
+	if clone.Address != "" || len(clone.TaggedAddresses) != 0 || len(clone.NodeMeta) != 0 {
 		t.Fatalf("bad: %v", clone)
 	}
 
 	sn.Address = ""
 	sn.TaggedAddresses = nil
+	sn.NodeMeta = nil
 	if !reflect.DeepEqual(sn, clone) {
 		t.Fatalf("bad: %v", clone)
 	}
@@ -186,6 +325,18 @@ func TestStructs_ServiceNode_PartialClone(t *testing.T) {
 	if reflect.DeepEqual(sn, clone) {
 		t.Fatalf("clone wasn't independent of the original")
 	}
+
+	sn.ServiceTags = clone.ServiceTags
+	sn.ServiceMeta["version"] = "4.5.6"
+	if reflect.DeepEqual(sn, clone) {
+		t.Fatalf("clone wasn't independent of the original")
+	}
+
+	sn.ServiceMeta = clone.ServiceMeta
+	sn.ServiceWeights.Passing = 5
+	if reflect.DeepEqual(sn, clone) {
+		t.Fatalf("clone wasn't independent of the original")
+	}
 }
 
 func TestStructs_ServiceNode_Conversions(t *testing.T) {
@@ -193,10 +344,11 @@ func TestStructs_ServiceNode_Conversions(t *testing.T) {
 
 	sn2 := sn.ToNodeService().ToServiceNode("node1")
 
-	// These two fields get lost in the conversion, so we have to zero-value
+	// These fields get lost in the conversion, so we have to zero-value
 	// them out before we do the compare.
 	sn.Address = ""
 	sn.TaggedAddresses = nil
+	sn.NodeMeta = nil
 	if !reflect.DeepEqual(sn, sn2) {
 		t.Fatalf("bad: %v", sn2)
 	}
@@ -209,6 +361,7 @@ func TestStructs_NodeService_IsSame(t *testing.T) {
 		Tags:              []string{"foo", "bar"},
 		Address:           "127.0.0.1",
 		Port:              1234,
+		Meta:              map[string]string{"version": "1.2.3"},
 		EnableTagOverride: true,
 	}
 	if !ns.IsSame(ns) {
@@ -221,6 +374,7 @@ func TestStructs_NodeService_IsSame(t *testing.T) {
 		Tags:              []string{"foo", "bar"},
 		Address:           "127.0.0.1",
 		Port:              1234,
+		Meta:              map[string]string{"version": "1.2.3"},
 		EnableTagOverride: true,
 		RaftIndex: RaftIndex{
 			CreateIndex: 1,
@@ -254,6 +408,9 @@ func TestStructs_NodeService_IsSame(t *testing.T) {
 	check(func() { other.Address = "XXX" }, func() { other.Address = "127.0.0.1" })
 	check(func() { other.Port = 9999 }, func() { other.Port = 1234 })
 	check(func() { other.EnableTagOverride = false }, func() { other.EnableTagOverride = true })
+	check(func() { other.Meta = nil }, func() { other.Meta = map[string]string{"version": "1.2.3"} })
+	check(func() { other.Meta = map[string]string{"version": "4.5.6"} }, func() { other.Meta = map[string]string{"version": "1.2.3"} })
+	check(func() { other.Weights = &Weights{Passing: 2, Warning: 1} }, func() { other.Weights = nil })
 }
 
 func TestStructs_HealthCheck_IsSame(t *testing.T) {
@@ -388,6 +545,105 @@ func TestStructs_CheckServiceNodes_Shuffle(t *testing.T) {
 	}
 }
 
+func TestStructs_Weights_Validate(t *testing.T) {
+	cases := []struct {
+		Weights Weights
+		Err     string
+	}{
+		{Weights{Passing: 1, Warning: 1}, ""},
+		{Weights{Passing: 10, Warning: 0}, ""},
+		{Weights{Passing: 0, Warning: 1}, "greater than 0"},
+		{Weights{Passing: 1, Warning: -1}, "must not be negative"},
+		{Weights{Passing: 65536, Warning: 1}, "must not be greater than"},
+		{Weights{Passing: 1, Warning: 65536}, "must not be greater than"},
+	}
+	for _, c := range cases {
+		err := c.Weights.Validate()
+		if c.Err == "" && err != nil {
+			t.Fatalf("bad: %v %v", c.Weights, err)
+		}
+		if c.Err != "" && (err == nil || !strings.Contains(err.Error(), c.Err)) {
+			t.Fatalf("bad: %v %v", c.Weights, err)
+		}
+	}
+}
+
+func TestStructs_CheckServiceNode_Weight(t *testing.T) {
+	node := CheckServiceNode{
+		Node:    &Node{Node: "node1"},
+		Service: &NodeService{ID: "web1", Service: "web"},
+	}
+	check := func(expected int, statuses ...string) {
+		node.Checks = nil
+		for _, status := range statuses {
+			node.Checks = append(node.Checks, &HealthCheck{Status: status})
+		}
+		if actual := node.Weight(); actual != expected {
+			t.Fatalf("bad: %d != %d for %v", actual, expected, statuses)
+		}
+	}
+
+	// Services without weights use the defaults.
+	check(1)
+	check(1, HealthPassing)
+	check(1, HealthPassing, HealthWarning)
+	check(0, HealthWarning, HealthCritical)
+
+	node.Service.Weights = &Weights{Passing: 10, Warning: 3}
+	check(10)
+	check(10, HealthPassing)
+	check(3, HealthPassing, HealthWarning)
+	check(0, HealthCritical, HealthPassing)
+}
+
+func TestStructs_CheckServiceNodes_HasWeights(t *testing.T) {
+	nodes := CheckServiceNodes{
+		CheckServiceNode{Node: &Node{Node: "node1"}},
+		CheckServiceNode{Node: &Node{Node: "node2"}, Service: &NodeService{ID: "web"}},
+	}
+	if nodes.HasWeights() {
+		t.Fatalf("should not have weights")
+	}
+
+	nodes[1].Service.Weights = &Weights{Passing: 1, Warning: 1}
+	if !nodes.HasWeights() {
+		t.Fatalf("should have weights")
+	}
+}
+
+func TestStructs_CheckServiceNodes_Shuffle_Weighted(t *testing.T) {
+	node := func(name string, passing int, status string) CheckServiceNode {
+		return CheckServiceNode{
+			Node: &Node{Node: name},
+			Service: &NodeService{
+				ID:      "web",
+				Service: "web",
+				Weights: &Weights{Passing: passing, Warning: 0},
+			},
+			Checks: HealthChecks{&HealthCheck{Status: status}},
+		}
+	}
+	nodes := CheckServiceNodes{
+		node("heavy", 9, HealthPassing),
+		node("light", 1, HealthPassing),
+		node("warning", 5, HealthWarning),
+	}
+
+	// The heavy node should come first about nine times out of ten, and
+	// the node with a zero weight should always come last.
+	first := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		nodes.Shuffle()
+		first[nodes[0].Node.Node]++
+		if nodes[2].Node.Node != "warning" {
+			t.Fatalf("bad: %v", nodes[2].Node)
+		}
+	}
+	if first["heavy"] < 800 || first["light"] < 50 {
+		t.Fatalf("bad: %v", first)
+	}
+}
+
 func TestStructs_CheckServiceNodes_Filter(t *testing.T) {
 	nodes := CheckServiceNodes{
 		CheckServiceNode{
@@ -480,3 +736,68 @@ func TestStructs_DirEntry_Clone(t *testing.T) {
 		t.Fatalf("clone wasn't independent of the original")
 	}
 }
+
+func TestStructs_ServiceSpecificRequest_FilterTags(t *testing.T) {
+	req := &ServiceSpecificRequest{}
+	if tags := req.FilterTags(); len(tags) != 0 {
+		t.Fatalf("bad: %v", tags)
+	}
+
+	req.ServiceTags = []string{"v1", "!canary"}
+	if tags := req.FilterTags(); !reflect.DeepEqual(tags, []string{"v1", "!canary"}) {
+		t.Fatalf("bad: %v", tags)
+	}
+
+	// The legacy single tag is folded in with the rest.
+	req.ServiceTag = "master"
+	if tags := req.FilterTags(); !reflect.DeepEqual(tags, []string{"master", "v1", "!canary"}) {
+		t.Fatalf("bad: %v", tags)
+	}
+}
+
+func TestStructs_ServiceSpecificRequest_SetFilterTags(t *testing.T) {
+	cases := []struct {
+		tags       []string
+		filter     bool
+		serviceTag string
+		rest       []string
+	}{
+		{nil, false, "", nil},
+		{[]string{"v1"}, true, "v1", nil},
+		{[]string{"!canary", "v1", "web"}, true, "v1", []string{"!canary", "web"}},
+		{[]string{"!canary"}, true, "", []string{"!canary"}},
+	}
+	for _, tc := range cases {
+		req := &ServiceSpecificRequest{ServiceTag: "stale", ServiceTags: []string{"stale"}}
+		req.SetFilterTags(tc.tags)
+		if req.TagFilter != tc.filter || req.ServiceTag != tc.serviceTag || !reflect.DeepEqual(req.ServiceTags, tc.rest) {
+			t.Fatalf("tags %v bad: %#v", tc.tags, req)
+		}
+	}
+}
+
+func TestStructs_ServiceTagFilter(t *testing.T) {
+	cases := []struct {
+		filter []string
+		tags   []string
+		match  bool
+	}{
+		{nil, nil, true},
+		{nil, []string{"foo"}, true},
+		{[]string{"foo"}, nil, false},
+		{[]string{"foo"}, []string{"foo", "bar"}, true},
+		{[]string{"FOO"}, []string{"foo"}, true},
+		{[]string{"foo", "bar"}, []string{"foo"}, false},
+		{[]string{"foo", "bar"}, []string{"bar", "foo"}, true},
+		{[]string{"!foo"}, nil, true},
+		{[]string{"!foo"}, []string{"Foo"}, false},
+		{[]string{"bar", "!foo"}, []string{"bar"}, true},
+		{[]string{"bar", "!foo"}, []string{"bar", "foo"}, false},
+	}
+	for i, c := range cases {
+		filter := NewServiceTagFilter(c.filter)
+		if match := filter.Matches(c.tags); match != c.match {
+			t.Fatalf("case %d: expected %v, got %v", i, c.match, match)
+		}
+	}
+}
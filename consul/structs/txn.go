@@ -15,10 +15,79 @@ type TxnKVOp struct {
 // inside a transaction.
 type TxnKVResult *DirEntry
 
+// TxnVerb is the operation performed on a node, service, check or session
+// inside a transaction. Not every verb is supported for every type.
+type TxnVerb string
+
+const (
+	TxnGet       TxnVerb = "get"
+	TxnSet       TxnVerb = "set"
+	TxnCAS       TxnVerb = "cas"
+	TxnDelete    TxnVerb = "delete"
+	TxnDeleteCAS TxnVerb = "delete-cas"
+)
+
+// IsWrite returns true if the given operation alters the state store.
+func (v TxnVerb) IsWrite() bool {
+	return v != TxnGet
+}
+
+// TxnNodeOp is used to define a single operation on a node inside a
+// transaction. The node is identified by its name.
+type TxnNodeOp struct {
+	Verb TxnVerb
+	Node Node
+}
+
+// TxnServiceOp is used to define a single operation on a service inside a
+// transaction. The service is identified by the node name and service ID.
+type TxnServiceOp struct {
+	Verb    TxnVerb
+	Node    string
+	Service NodeService
+}
+
+// TxnCheckOp is used to define a single operation on a health check inside
+// a transaction. The check is identified by its node name and check ID.
+type TxnCheckOp struct {
+	Verb  TxnVerb
+	Check HealthCheck
+}
+
+// TxnSessionOp is used to define a single operation on a session inside a
+// transaction. Sessions can only be read or deleted, since they need a
+// generated ID and a TTL timer when they are created.
+type TxnSessionOp struct {
+	Verb    TxnVerb
+	Session Session
+}
+
 // TxnOp is used to define a single operation inside a transaction. Only one
 // of the types should be filled out per entry.
 type TxnOp struct {
-	KV *TxnKVOp
+	KV      *TxnKVOp
+	Node    *TxnNodeOp
+	Service *TxnServiceOp
+	Check   *TxnCheckOp
+	Session *TxnSessionOp
+}
+
+// IsWrite returns true if the given operation alters the state store.
+func (op *TxnOp) IsWrite() bool {
+	switch {
+	case op.KV != nil:
+		return op.KV.Verb.IsWrite()
+	case op.Node != nil:
+		return op.Node.Verb.IsWrite()
+	case op.Service != nil:
+		return op.Service.Verb.IsWrite()
+	case op.Check != nil:
+		return op.Check.Verb.IsWrite()
+	case op.Session != nil:
+		return op.Session.Verb.IsWrite()
+	default:
+		return false
+	}
 }
 
 // TxnOps is a list of operations within a transaction.
@@ -66,7 +135,11 @@ type TxnErrors []*TxnError
 // TxnResult is used to define the result of a given operation inside a
 // transaction. Only one of the types should be filled out per entry.
 type TxnResult struct {
-	KV TxnKVResult
+	KV      TxnKVResult
+	Node    *Node
+	Service *NodeService
+	Check   *HealthCheck
+	Session *Session
 }
 
 // TxnResults is a list of TxnResult entries.
@@ -6,7 +6,9 @@ import (
 
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/consul/acl"
+	"github.com/hashicorp/consul/consul/audit"
 	"github.com/hashicorp/consul/consul/structs"
+	"github.com/hashicorp/consul/types"
 )
 
 // Txn endpoint is used to perform multi-object atomic transactions.
@@ -14,27 +16,268 @@ type Txn struct {
 	srv *Server
 }
 
+// txnServiceKey identifies a service registered on a node.
+type txnServiceKey struct {
+	node string
+	id   string
+}
+
 // preCheck is used to verify the incoming operations before any further
 // processing takes place. This checks things like ACLs.
 func (t *Txn) preCheck(acl acl.ACL, ops structs.TxnOps) structs.TxnErrors {
 	var errors structs.TxnErrors
 
-	// Perform the pre-apply checks for any KV operations.
+	// Track the names of the services written by the transaction, so
+	// checks can be registered along with their service.
+	services := make(map[txnServiceKey]string)
+
+	// Perform the pre-apply checks for each type of operation.
 	for i, op := range ops {
-		if op.KV != nil {
-			ok, err := kvsPreApply(t.srv, acl, op.KV.Verb, &op.KV.DirEnt)
-			if err != nil {
-				errors = append(errors, &structs.TxnError{i, err.Error()})
-			} else if !ok {
+		var err error
+		switch {
+		case op.KV != nil:
+			var ok bool
+			ok, err = kvsPreApply(t.srv, acl, op.KV.Verb, &op.KV.DirEnt)
+			if err == nil && !ok {
 				err = fmt.Errorf("failed to lock key %q due to lock delay", op.KV.DirEnt.Key)
-				errors = append(errors, &structs.TxnError{i, err.Error()})
 			}
+		case op.Node != nil:
+			err = t.vetNodeOp(acl, op.Node)
+		case op.Service != nil:
+			err = t.vetServiceOp(acl, op.Service)
+			if err == nil && (op.Service.Verb == structs.TxnSet || op.Service.Verb == structs.TxnCAS) {
+				key := txnServiceKey{op.Service.Node, op.Service.Service.ID}
+				services[key] = op.Service.Service.Service
+			}
+		case op.Check != nil:
+			err = t.vetCheckOp(acl, op.Check, services)
+		case op.Session != nil:
+			err = t.vetSessionOp(acl, op.Session)
+		}
+		if err != nil {
+			errors = append(errors, &structs.TxnError{i, err.Error()})
 		}
 	}
 
 	return errors
 }
 
+// checkQuotas checks the KV operations in the transaction against the KV
+// quotas in the given tracker, in order, so each operation sees the usage
+// left by the ones before it.
+func (t *Txn) checkQuotas(quota *kvsQuotaTracker, ops structs.TxnOps) structs.TxnErrors {
+	var errors structs.TxnErrors
+	for i, op := range ops {
+		if op.KV == nil {
+			continue
+		}
+		if err := quota.apply(op.KV.Verb, &op.KV.DirEnt); err != nil {
+			errors = append(errors, &structs.TxnError{i, err.Error()})
+		}
+	}
+	return errors
+}
+
+// vetNodeOp verifies a node operation and applies the ACL policy to it.
+// Filtering for GETs is done on the output side.
+func (t *Txn) vetNodeOp(acl acl.ACL, op *structs.TxnNodeOp) error {
+	if op.Node.Node == "" {
+		return fmt.Errorf("Must provide node")
+	}
+	if op.Verb == structs.TxnSet || op.Verb == structs.TxnCAS {
+		if op.Node.Address == "" {
+			return fmt.Errorf("Must provide address for node %q", op.Node.Node)
+		}
+		if err := structs.ValidateMetadata(op.Node.Meta); err != nil {
+			return fmt.Errorf("Invalid node metadata: %v", err)
+		}
+	}
+
+	if acl != nil && op.Verb.IsWrite() && !acl.NodeWrite(op.Node.Node) {
+		return permissionDeniedErr
+	}
+	return nil
+}
+
+// vetServiceOp verifies a service operation and applies the ACL policy to
+// it. Filtering for GETs is done on the output side.
+func (t *Txn) vetServiceOp(acl acl.ACL, op *structs.TxnServiceOp) error {
+	if op.Node == "" {
+		return fmt.Errorf("Must provide node")
+	}
+
+	// If no service id, but service name, use default
+	if op.Service.ID == "" && op.Service.Service != "" {
+		op.Service.ID = op.Service.Service
+	}
+	if op.Service.ID == "" {
+		return fmt.Errorf("Must provide service ID")
+	}
+
+	set := op.Verb == structs.TxnSet || op.Verb == structs.TxnCAS
+	if set {
+		if op.Service.Service == "" {
+			return fmt.Errorf("Must provide service name with ID")
+		}
+		if err := structs.ValidateMetadata(op.Service.Meta); err != nil {
+			return fmt.Errorf("Invalid service metadata: %v", err)
+		}
+		if op.Service.Weights != nil {
+			if err := op.Service.Weights.Validate(); err != nil {
+				return fmt.Errorf("Invalid service weights: %v", err)
+			}
+		}
+	}
+
+	if acl == nil || !op.Verb.IsWrite() {
+		return nil
+	}
+	if set && !acl.ServiceWrite(op.Service.Service) {
+		return permissionDeniedErr
+	}
+
+	// Modifying an existing service also requires write permissions for
+	// the name it's registered under, since a set can rename it.
+	state := t.srv.fsm.State()
+	_, existing, err := state.NodeService(op.Node, op.Service.ID)
+	if err != nil {
+		return fmt.Errorf("Service lookup failed: %v", err)
+	}
+	if existing != nil && !acl.ServiceWrite(existing.Service) {
+		return permissionDeniedErr
+	}
+	return nil
+}
+
+// vetCheckOp verifies a health check operation and applies the ACL policy
+// to it. Node-level checks require node write, and service-level checks
+// require service write. The services written earlier in the transaction
+// are given so a check can be registered along with its service. Filtering
+// for GETs is done on the output side.
+func (t *Txn) vetCheckOp(acl acl.ACL, op *structs.TxnCheckOp, services map[txnServiceKey]string) error {
+	if op.Check.Node == "" {
+		return fmt.Errorf("Must provide node")
+	}
+	if op.Check.CheckID == "" && op.Check.Name != "" {
+		op.Check.CheckID = types.CheckID(op.Check.Name)
+	}
+	if op.Check.CheckID == "" {
+		return fmt.Errorf("Must provide check ID")
+	}
+
+	if acl == nil || !op.Verb.IsWrite() {
+		return nil
+	}
+	state := t.srv.fsm.State()
+
+	// Vet the check as it will be written.
+	if op.Verb == structs.TxnSet || op.Verb == structs.TxnCAS {
+		if op.Check.ServiceID == "" {
+			if !acl.NodeWrite(op.Check.Node) {
+				return permissionDeniedErr
+			}
+		} else {
+			name, ok := services[txnServiceKey{op.Check.Node, op.Check.ServiceID}]
+			if !ok {
+				_, service, err := state.NodeService(op.Check.Node, op.Check.ServiceID)
+				if err != nil {
+					return fmt.Errorf("Service lookup failed: %v", err)
+				}
+				if service == nil {
+					return fmt.Errorf("Unknown service '%s' for check '%s'",
+						op.Check.ServiceID, op.Check.CheckID)
+				}
+				name = service.Service
+			}
+			if !acl.ServiceWrite(name) {
+				return permissionDeniedErr
+			}
+		}
+	}
+
+	// Vet the existing check, which could belong to a different service.
+	_, existing, err := state.NodeCheck(op.Check.Node, op.Check.CheckID)
+	if err != nil {
+		return fmt.Errorf("Check lookup failed: %v", err)
+	}
+	if existing != nil {
+		if existing.ServiceID == "" {
+			if !acl.NodeWrite(existing.Node) {
+				return permissionDeniedErr
+			}
+		} else if !acl.ServiceWrite(existing.ServiceName) {
+			return permissionDeniedErr
+		}
+	}
+	return nil
+}
+
+// vetSessionOp verifies a session operation and applies the ACL policy to
+// it once the version 8 rules are enforced. Writes need session write access
+// to the node the session is on. Filtering for GETs is done on the output
+// side.
+func (t *Txn) vetSessionOp(acl acl.ACL, op *structs.TxnSessionOp) error {
+	if op.Session.ID == "" {
+		return fmt.Errorf("Must provide ID")
+	}
+
+	if acl == nil || !t.srv.config.ACLEnforceVersion8 || !op.Verb.IsWrite() {
+		return nil
+	}
+	state := t.srv.fsm.State()
+	_, existing, err := state.SessionGet(op.Session.ID)
+	if err != nil {
+		return fmt.Errorf("Session lookup failed: %v", err)
+	}
+	if existing != nil && !acl.SessionWrite(existing.Node) {
+		return permissionDeniedErr
+	}
+	return nil
+}
+
+// txnAuditResource returns the type and name of the resource a transaction
+// operation accesses, for the audit log. Session operations aren't recorded.
+func txnAuditResource(op *structs.TxnOp) (string, string, bool) {
+	switch {
+	case op.KV != nil:
+		return audit.ResourceKV, op.KV.DirEnt.Key, true
+	case op.Node != nil:
+		return audit.ResourceCatalog, catalogResource(op.Node.Node.Node, ""), true
+	case op.Service != nil:
+		return audit.ResourceCatalog, catalogResource(op.Service.Node, op.Service.Service.Service), true
+	case op.Check != nil:
+		return audit.ResourceCatalog, catalogResource(op.Check.Check.Node, op.Check.Check.ServiceName), true
+	default:
+		return "", "", false
+	}
+}
+
+// auditDenied records the operations that failed their ACL checks in the
+// audit log.
+func (t *Txn) auditDenied(token, endpoint string, ops structs.TxnOps, errors structs.TxnErrors) {
+	for _, e := range errors {
+		if e.What != permissionDenied || e.OpIndex < 0 || e.OpIndex >= len(ops) {
+			continue
+		}
+		if resourceType, resource, ok := txnAuditResource(ops[e.OpIndex]); ok {
+			t.srv.auditACL(token, endpoint, resourceType, resource, audit.Deny)
+		}
+	}
+}
+
+// auditWrites records the writes made by a transaction that went through in
+// the audit log.
+func (t *Txn) auditWrites(token string, ops structs.TxnOps) {
+	for _, op := range ops {
+		if !op.IsWrite() {
+			continue
+		}
+		if resourceType, resource, ok := txnAuditResource(op); ok {
+			t.srv.auditACL(token, "Txn.Apply", resourceType, resource, audit.Allow)
+		}
+	}
+}
+
 // Apply is used to apply multiple operations in a single, atomic transaction.
 func (t *Txn) Apply(args *structs.TxnRequest, reply *structs.TxnResponse) error {
 	if done, err := t.srv.forward("Txn.Apply", args, args, reply); done {
@@ -49,11 +292,26 @@ func (t *Txn) Apply(args *structs.TxnRequest, reply *structs.TxnResponse) error
 	}
 	reply.Errors = t.preCheck(acl, args.Ops)
 	if len(reply.Errors) > 0 {
+		t.auditDenied(args.Token, "Txn.Apply", args.Ops, reply.Errors)
+		return nil
+	}
+
+	// Make sure the writes stay within the KV quotas.
+	quota := newKVSQuotaTracker(t.srv, args.Token)
+	for _, op := range args.Ops {
+		if op.KV != nil {
+			quota.lock(op.KV.Verb, op.KV.DirEnt.Key)
+		}
+	}
+	reply.Errors = t.checkQuotas(quota, args.Ops)
+	if len(reply.Errors) > 0 {
+		quota.unlock()
 		return nil
 	}
 
 	// Apply the update.
 	resp, err := t.srv.raftApply(structs.TxnRequestType, args)
+	quota.unlock()
 	if err != nil {
 		t.srv.logger.Printf("[ERR] consul.txn: Apply failed: %v", err)
 		return err
@@ -62,11 +320,34 @@ func (t *Txn) Apply(args *structs.TxnRequest, reply *structs.TxnResponse) error
 		return respErr
 	}
 
+	// Bring the expiration timers of any keys that were written up to
+	// date.
+	for _, op := range args.Ops {
+		if op.KV != nil && op.KV.DirEnt.Key != "" {
+			if err := t.srv.resetKVSTimer(op.KV.DirEnt.Key, nil); err != nil {
+				t.srv.logger.Printf("[ERR] consul.txn: Failed to reset TTL for %q: %v", op.KV.DirEnt.Key, err)
+			}
+		}
+	}
+
 	// Convert the return type. This should be a cheap copy since we are
 	// just taking the two slices.
 	if txnResp, ok := resp.(structs.TxnResponse); ok {
+		// If the transaction went through, clear the timers of any
+		// sessions it deleted, and record its writes.
+		if len(txnResp.Errors) == 0 {
+			for _, op := range args.Ops {
+				if op.Session != nil && op.Session.Verb == structs.TxnDelete {
+					t.srv.clearSessionTimer(op.Session.Session.ID)
+				}
+			}
+			if acl != nil {
+				t.auditWrites(args.Token, args.Ops)
+			}
+		}
+
 		if acl != nil {
-			txnResp.Results = FilterTxnResults(acl, txnResp.Results)
+			txnResp.Results = FilterTxnResults(acl, txnResp.Results, t.srv.config.ACLEnforceVersion8)
 		}
 		*reply = txnResp
 	} else {
@@ -100,6 +381,7 @@ func (t *Txn) Read(args *structs.TxnReadRequest, reply *structs.TxnReadResponse)
 	}
 	reply.Errors = t.preCheck(acl, args.Ops)
 	if len(reply.Errors) > 0 {
+		t.auditDenied(args.Token, "Txn.Read", args.Ops, reply.Errors)
 		return nil
 	}
 
@@ -107,7 +389,7 @@ func (t *Txn) Read(args *structs.TxnReadRequest, reply *structs.TxnReadResponse)
 	state := t.srv.fsm.State()
 	reply.Results, reply.Errors = state.TxnRO(args.Ops)
 	if acl != nil {
-		reply.Results = FilterTxnResults(acl, reply.Results)
+		reply.Results = FilterTxnResults(acl, reply.Results, t.srv.config.ACLEnforceVersion8)
 	}
 	return nil
 }
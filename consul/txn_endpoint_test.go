@@ -344,6 +344,77 @@ func TestTxn_Apply_LockDelay(t *testing.T) {
 	}
 }
 
+func TestTxn_Apply_Quota(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.KVSQuotas = structs.KVSQuotas{
+			&structs.KVSQuota{Prefix: "tmp/", MaxKeys: 2},
+		}
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	set := func(key string) *structs.TxnOp {
+		return &structs.TxnOp{
+			KV: &structs.TxnKVOp{
+				Verb: structs.KVSSet,
+				DirEnt: structs.DirEntry{
+					Key:   key,
+					Value: []byte("test"),
+				},
+			},
+		}
+	}
+
+	// The third key in the transaction is over the quota, so the whole
+	// transaction is rejected.
+	arg := structs.TxnRequest{
+		Datacenter: "dc1",
+		Ops: structs.TxnOps{
+			set("tmp/a"),
+			set("tmp/b"),
+			set("other"),
+			set("tmp/c"),
+		},
+	}
+	var out structs.TxnResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != 1 || out.Errors[0].OpIndex != 3 ||
+		!strings.Contains(out.Errors[0].What, quotaExceeded) {
+		t.Fatalf("bad: %v", out.Errors)
+	}
+	_, d, err := s1.fsm.State().KVSGet("tmp/a")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if d != nil {
+		t.Fatalf("should not be written: %v", d)
+	}
+
+	// Deleting the tree first makes room.
+	arg.Ops = append(structs.TxnOps{
+		&structs.TxnOp{
+			KV: &structs.TxnKVOp{
+				Verb: structs.KVSDeleteTree,
+				DirEnt: structs.DirEntry{
+					Key: "tmp/",
+				},
+			},
+		},
+	}, arg.Ops[0], arg.Ops[1])
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != 0 {
+		t.Fatalf("bad: %v", out.Errors)
+	}
+}
+
 func TestTxn_Read(t *testing.T) {
 	dir1, s1 := testServer(t)
 	defer os.RemoveAll(dir1)
@@ -516,3 +587,402 @@ func TestTxn_Read_ACLDeny(t *testing.T) {
 		t.Fatalf("bad %v", out)
 	}
 }
+
+func TestTxn_Apply_Catalog(t *testing.T) {
+	dir1, s1 := testServer(t)
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Register a service instance that holds a lock through a session
+	// with a TTL.
+	state := s1.fsm.State()
+	if err := state.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := state.EnsureService(2, "foo", &structs.NodeService{ID: "web", Service: "web"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sessionArg := structs.SessionRequest{
+		Datacenter: "dc1",
+		Op:         structs.SessionCreate,
+		Session: structs.Session{
+			Node: "foo",
+			TTL:  "10s",
+		},
+	}
+	var session string
+	if err := msgpackrpc.CallWithCodec(codec, "Session.Apply", &sessionArg, &session); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := state.KVSLock(3, &structs.DirEntry{Key: "web/leader", Session: session}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Deregister the instance and release its lock in one step, and
+	// register a replacement.
+	arg := structs.TxnRequest{
+		Datacenter: "dc1",
+		Ops: structs.TxnOps{
+			&structs.TxnOp{
+				Service: &structs.TxnServiceOp{
+					Verb:    structs.TxnDelete,
+					Node:    "foo",
+					Service: structs.NodeService{ID: "web"},
+				},
+			},
+			&structs.TxnOp{
+				Session: &structs.TxnSessionOp{
+					Verb:    structs.TxnDelete,
+					Session: structs.Session{ID: session},
+				},
+			},
+			&structs.TxnOp{
+				Service: &structs.TxnServiceOp{
+					Verb:    structs.TxnSet,
+					Node:    "foo",
+					Service: structs.NodeService{Service: "web2"},
+				},
+			},
+			&structs.TxnOp{
+				Check: &structs.TxnCheckOp{
+					Verb: structs.TxnSet,
+					Check: structs.HealthCheck{
+						Node:      "foo",
+						Name:      "web2-check",
+						ServiceID: "web2",
+						Status:    structs.HealthPassing,
+					},
+				},
+			},
+		},
+	}
+	var out structs.TxnResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != 0 {
+		t.Fatalf("bad: %v", out.Errors)
+	}
+
+	// The IDs should have been defaulted from the names.
+	if len(out.Results) != 2 {
+		t.Fatalf("bad: %v", out.Results)
+	}
+	if svc := out.Results[0].Service; svc == nil || svc.ID != "web2" {
+		t.Fatalf("bad: %#v", out.Results[0])
+	}
+	if chk := out.Results[1].Check; chk == nil || chk.CheckID != "web2-check" || chk.ServiceName != "web2" {
+		t.Fatalf("bad: %#v", out.Results[1])
+	}
+
+	// Verify the state store directly.
+	_, services, err := state.NodeServices("foo")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := services.Services["web"]; ok || len(services.Services) != 1 {
+		t.Fatalf("bad: %v", services.Services)
+	}
+	_, d, err := state.KVSGet("web/leader")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if d == nil || d.Session != "" {
+		t.Fatalf("bad: %v", d)
+	}
+
+	// The deleted session's timer should be gone.
+	s1.sessionTimersLock.Lock()
+	_, ok := s1.sessionTimers[session]
+	s1.sessionTimersLock.Unlock()
+	if ok {
+		t.Fatalf("session timer should have been cleared")
+	}
+}
+
+func TestTxn_Apply_Catalog_ACLDeny(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Register a node with a service and a node-level check.
+	state := s1.fsm.State()
+	if err := state.EnsureNode(1, &structs.Node{Node: "foo", Address: "127.0.0.1"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := state.EnsureService(2, "foo", &structs.NodeService{ID: "web", Service: "web"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := state.EnsureCheck(3, &structs.HealthCheck{Node: "foo", CheckID: "node-check"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Create an ACL that can only write the "web" service.
+	var id string
+	{
+		arg := structs.ACLRequest{
+			Datacenter: "dc1",
+			Op:         structs.ACLSet,
+			ACL: structs.ACL{
+				Name: "User token",
+				Type: structs.ACLTypeClient,
+				Rules: `
+service "web" {
+	policy = "write"
+}
+`,
+			},
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		if err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &id); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Set up a transaction where every operation should get blocked due to
+	// ACLs.
+	arg := structs.TxnRequest{
+		Datacenter: "dc1",
+		Ops: structs.TxnOps{
+			&structs.TxnOp{
+				Node: &structs.TxnNodeOp{
+					Verb: structs.TxnSet,
+					Node: structs.Node{Node: "foo", Address: "127.0.0.2"},
+				},
+			},
+			&structs.TxnOp{
+				Node: &structs.TxnNodeOp{
+					Verb: structs.TxnDelete,
+					Node: structs.Node{Node: "foo"},
+				},
+			},
+			&structs.TxnOp{
+				Service: &structs.TxnServiceOp{
+					Verb:    structs.TxnSet,
+					Node:    "foo",
+					Service: structs.NodeService{ID: "db", Service: "db"},
+				},
+			},
+			&structs.TxnOp{
+				Service: &structs.TxnServiceOp{
+					Verb:    structs.TxnSet,
+					Node:    "foo",
+					Service: structs.NodeService{ID: "web", Service: "renamed"},
+				},
+			},
+			&structs.TxnOp{
+				Check: &structs.TxnCheckOp{
+					Verb:  structs.TxnSet,
+					Check: structs.HealthCheck{Node: "foo", CheckID: "another"},
+				},
+			},
+			&structs.TxnOp{
+				Check: &structs.TxnCheckOp{
+					Verb: structs.TxnSet,
+					Check: structs.HealthCheck{
+						Node:      "foo",
+						CheckID:   "node-check",
+						ServiceID: "web",
+					},
+				},
+			},
+		},
+		WriteRequest: structs.WriteRequest{
+			Token: id,
+		},
+	}
+	var out structs.TxnResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != len(arg.Ops) {
+		t.Fatalf("bad: %v", out.Errors)
+	}
+	for i, err := range out.Errors {
+		if err.OpIndex != i || !strings.Contains(err.What, permissionDenied) {
+			t.Fatalf("bad: %v", err)
+		}
+	}
+
+	// Writes to the service the token covers go through, even for a check
+	// registered along with a new instance, but reads of the node it can't
+	// see are filtered out.
+	arg.Ops = structs.TxnOps{
+		&structs.TxnOp{
+			Service: &structs.TxnServiceOp{
+				Verb:    structs.TxnSet,
+				Node:    "foo",
+				Service: structs.NodeService{ID: "web2", Service: "web", Port: 80},
+			},
+		},
+		&structs.TxnOp{
+			Check: &structs.TxnCheckOp{
+				Verb: structs.TxnSet,
+				Check: structs.HealthCheck{
+					Node:      "foo",
+					CheckID:   "web2-check",
+					ServiceID: "web2",
+				},
+			},
+		},
+		&structs.TxnOp{
+			Node: &structs.TxnNodeOp{
+				Verb: structs.TxnGet,
+				Node: structs.Node{Node: "foo"},
+			},
+		},
+		&structs.TxnOp{
+			Check: &structs.TxnCheckOp{
+				Verb:  structs.TxnGet,
+				Check: structs.HealthCheck{Node: "foo", CheckID: "node-check"},
+			},
+		},
+	}
+	out = structs.TxnResponse{}
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != 0 {
+		t.Fatalf("bad: %v", out.Errors)
+	}
+	if len(out.Results) != 2 || out.Results[0].Service == nil || out.Results[0].Service.Port != 80 ||
+		out.Results[1].Check == nil || out.Results[1].Check.ServiceName != "web" {
+		t.Fatalf("bad: %v", out.Results)
+	}
+}
+
+func TestTxn_Apply_Session_ACLDeny(t *testing.T) {
+	dir1, s1 := testServerWithConfig(t, func(c *Config) {
+		c.ACLDatacenter = "dc1"
+		c.ACLMasterToken = "root"
+		c.ACLDefaultPolicy = "deny"
+		c.ACLEnforceVersion8 = false
+	})
+	defer os.RemoveAll(dir1)
+	defer s1.Shutdown()
+	codec := rpcClient(t, s1)
+	defer codec.Close()
+
+	testutil.WaitForLeader(t, s1.RPC, "dc1")
+
+	// Create a session on each of two nodes.
+	state := s1.fsm.State()
+	for i, node := range []string{"foo", "bar"} {
+		if err := state.EnsureNode(uint64(2*i+1), &structs.Node{Node: node, Address: "127.0.0.1"}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		session := &structs.Session{ID: generateUUID(), Node: node}
+		if err := state.SessionCreate(uint64(2*i+2), session); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	_, sessions, err := state.SessionList()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ids := make(map[string]string)
+	for _, session := range sessions {
+		ids[session.Node] = session.ID
+	}
+
+	// Create an ACL that can only read sessions on "foo".
+	var id string
+	{
+		arg := structs.ACLRequest{
+			Datacenter: "dc1",
+			Op:         structs.ACLSet,
+			ACL: structs.ACL{
+				Name: "User token",
+				Type: structs.ACLTypeClient,
+				Rules: `
+session "foo" {
+	policy = "read"
+}
+`,
+			},
+			WriteRequest: structs.WriteRequest{Token: "root"},
+		}
+		if err := msgpackrpc.CallWithCodec(codec, "ACL.Apply", &arg, &id); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// Both sessions can be read without version 8 enforcement.
+	arg := structs.TxnRequest{
+		Datacenter: "dc1",
+		Ops: structs.TxnOps{
+			&structs.TxnOp{
+				Session: &structs.TxnSessionOp{
+					Verb:    structs.TxnGet,
+					Session: structs.Session{ID: ids["foo"]},
+				},
+			},
+			&structs.TxnOp{
+				Session: &structs.TxnSessionOp{
+					Verb:    structs.TxnGet,
+					Session: structs.Session{ID: ids["bar"]},
+				},
+			},
+		},
+		WriteRequest: structs.WriteRequest{
+			Token: id,
+		},
+	}
+	var out structs.TxnResponse
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != 0 || len(out.Results) != 2 {
+		t.Fatalf("bad: %v %v", out.Errors, out.Results)
+	}
+
+	// With enforcement on, the "bar" session is filtered out.
+	s1.config.ACLEnforceVersion8 = true
+	out = structs.TxnResponse{}
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != 0 || len(out.Results) != 1 || out.Results[0].Session.Node != "foo" {
+		t.Fatalf("bad: %v %v", out.Errors, out.Results)
+	}
+
+	// Deleting either session is denied, since the token can only read.
+	arg.Ops = structs.TxnOps{
+		&structs.TxnOp{
+			Session: &structs.TxnSessionOp{
+				Verb:    structs.TxnDelete,
+				Session: structs.Session{ID: ids["foo"]},
+			},
+		},
+		&structs.TxnOp{
+			Session: &structs.TxnSessionOp{
+				Verb:    structs.TxnDelete,
+				Session: structs.Session{ID: ids["bar"]},
+			},
+		},
+	}
+	out = structs.TxnResponse{}
+	if err := msgpackrpc.CallWithCodec(codec, "Txn.Apply", &arg, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(out.Errors) != len(arg.Ops) {
+		t.Fatalf("bad: %v", out.Errors)
+	}
+	for i, err := range out.Errors {
+		if err.OpIndex != i || !strings.Contains(err.What, permissionDenied) {
+			t.Fatalf("bad: %v", err)
+		}
+	}
+}
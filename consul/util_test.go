@@ -325,3 +325,72 @@ func TestGetPublicIPv6(t *testing.T) {
 		}
 	}
 }
+
+func TestUtil_ServerMinRaftProtocol(t *testing.T) {
+	cases := []struct {
+		members  []serf.Member
+		expected int
+		err      bool
+	}{
+		// No servers, only a client.
+		{
+			members: []serf.Member{
+				serf.Member{
+					Tags:   map[string]string{"role": "node", "raft_vsn": "3"},
+					Status: serf.StatusAlive,
+				},
+			},
+			expected: -1,
+			err:      true,
+		},
+		// A server that doesn't advertise a version is assumed to speak 1.
+		{
+			members: []serf.Member{
+				serf.Member{
+					Tags:   map[string]string{"role": "consul"},
+					Status: serf.StatusAlive,
+				},
+				serf.Member{
+					Tags:   map[string]string{"role": "consul", "raft_vsn": "3"},
+					Status: serf.StatusAlive,
+				},
+			},
+			expected: 1,
+		},
+		// Servers that aren't alive don't count.
+		{
+			members: []serf.Member{
+				serf.Member{
+					Tags:   map[string]string{"role": "consul", "raft_vsn": "2"},
+					Status: serf.StatusFailed,
+				},
+				serf.Member{
+					Tags:   map[string]string{"role": "consul", "raft_vsn": "3"},
+					Status: serf.StatusAlive,
+				},
+			},
+			expected: 3,
+		},
+		// A bad version tag is an error.
+		{
+			members: []serf.Member{
+				serf.Member{
+					Tags:   map[string]string{"role": "consul", "raft_vsn": "nope"},
+					Status: serf.StatusAlive,
+				},
+			},
+			expected: -1,
+			err:      true,
+		},
+	}
+
+	for i, tc := range cases {
+		actual, err := ServerMinRaftProtocol(tc.members)
+		if (err != nil) != tc.err {
+			t.Fatalf("case %d: err: %v", i, err)
+		}
+		if actual != tc.expected {
+			t.Fatalf("case %d: expected %d, got %d", i, tc.expected, actual)
+		}
+	}
+}
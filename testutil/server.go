@@ -50,9 +50,27 @@ type TestAddressConfig struct {
 	HTTP string `json:"http,omitempty"`
 }
 
+// TestKVHistoryConfig configures the prior versions kept for the keys
+// under a prefix.
+type TestKVHistoryConfig struct {
+	Prefix   string `json:"prefix"`
+	Versions int    `json:"versions,omitempty"`
+	Window   string `json:"window,omitempty"`
+}
+
+// TestKVQuotaConfig configures a quota on the keys under a prefix.
+type TestKVQuotaConfig struct {
+	Prefix       string `json:"prefix"`
+	Token        string `json:"token,omitempty"`
+	MaxKeys      int    `json:"max_keys,omitempty"`
+	MaxBytes     int64  `json:"max_bytes,omitempty"`
+	MaxValueSize int    `json:"max_value_size,omitempty"`
+}
+
 // TestServerConfig is the main server configuration struct.
 type TestServerConfig struct {
 	NodeName          string                 `json:"node_name"`
+	NodeMeta          map[string]string      `json:"node_meta,omitempty"`
 	Performance       *TestPerformanceConfig `json:"performance,omitempty"`
 	Bootstrap         bool                   `json:"bootstrap,omitempty"`
 	Server            bool                   `json:"server,omitempty"`
@@ -67,6 +85,8 @@ type TestServerConfig struct {
 	ACLDatacenter     string                 `json:"acl_datacenter,omitempty"`
 	ACLDefaultPolicy  string                 `json:"acl_default_policy,omitempty"`
 	Encrypt           string                 `json:"encrypt,omitempty"`
+	KVHistory         []*TestKVHistoryConfig `json:"kv_history,omitempty"`
+	KVQuotas          []*TestKVQuotaConfig   `json:"kv_quotas,omitempty"`
 	Stdout, Stderr    io.Writer              `json:"-"`
 	Args              []string               `json:"-"`
 }